@@ -0,0 +1,338 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buraksezer/olric/internal/dmap"
+)
+
+// adminServer serves the HTTP admin API, see config.Config.AdminAddr.
+type adminServer struct {
+	db       *Olric
+	client   *EmbeddedClient
+	token    string
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+
+	// In-flight long operations, see /operations.
+	operations *operationRegistry
+}
+
+func newAdminServer(db *Olric) *adminServer {
+	a := &adminServer{
+		db:     db,
+		client: db.NewEmbeddedClient(),
+		token:  db.config.AdminToken,
+		mux:    http.NewServeMux(),
+
+		operations: newOperationRegistry(),
+	}
+	a.mux.HandleFunc("/healthz", a.healthzHandler)
+	a.mux.HandleFunc("/readyz", a.readyzHandler)
+	a.mux.HandleFunc("/routing-table", a.authorize(a.routingTableHandler))
+	a.mux.HandleFunc("/stats", a.authorize(a.statsHandler))
+	a.mux.HandleFunc("/dmaps", a.authorize(a.dmapsHandler))
+	a.mux.HandleFunc("/dmaps/", a.authorize(a.destroyDMapHandler))
+	a.mux.HandleFunc("/rebalance", a.authorize(a.rebalanceHandler))
+	a.mux.HandleFunc("/locks", a.authorize(a.forceUnlockHandler))
+	a.mux.HandleFunc("/operations", a.authorize(a.operationsHandler))
+	a.mux.HandleFunc("/operations/", a.authorize(a.cancelOperationHandler))
+	a.server = &http.Server{Handler: a.mux}
+	return a
+}
+
+// start binds the admin address and serves the requests in the background.
+func (a *adminServer) start() error {
+	l, err := net.Listen("tcp", a.db.config.AdminAddr)
+	if err != nil {
+		return err
+	}
+	a.listener = l
+
+	a.db.wg.Add(1)
+	go func() {
+		defer a.db.wg.Done()
+		err := a.server.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.db.log.V(2).Printf("[ERROR] Admin API failed: %v", err)
+		}
+	}()
+	a.db.log.V(2).Printf("[INFO] Admin API is listening on %s", l.Addr())
+	return nil
+}
+
+func (a *adminServer) shutdown(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
+}
+
+// authorize checks the bearer token of the request. The destructive operations
+// are refused if there is no token in the configuration.
+func (a *adminServer) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.token == "" {
+			if r.Method != http.MethodGet {
+				writeAdminError(w, http.StatusForbidden, errors.New("destructive operations require an admin token"))
+				return
+			}
+			next(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeAdminError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeAdminError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return false
+	}
+	return true
+}
+
+// healthzHandler reports whether the member is alive. It fails after the
+// member starts shutting down.
+func (a *adminServer) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	select {
+	case <-a.db.ctx.Done():
+		writeAdminError(w, http.StatusServiceUnavailable, errors.New("shutting down"))
+	default:
+		writeAdminJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// Readiness is the response of /readyz.
+type Readiness struct {
+	Ready        bool            `json:"ready"`
+	Bootstrapped bool            `json:"bootstrapped"`
+	Quorum       bool            `json:"quorum"`
+	Rebalance    RebalanceStatus `json:"rebalance"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// readyzHandler reports whether the member serves the requests. The member
+// is ready if it's bootstrapped and it has the member count quorum. The
+// rebalance status is informational, it doesn't fail the check.
+func (a *adminServer) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	res := Readiness{
+		Bootstrapped: a.db.rt.IsBootstrapped(),
+		Rebalance:    a.db.rebalanceStatus(),
+	}
+	err := a.db.rt.CheckMemberCountQuorum()
+	res.Quorum = err == nil
+	if err != nil {
+		res.Error = convertClusterError(err).Error()
+	} else if !res.Bootstrapped {
+		res.Error = "not bootstrapped"
+	}
+	res.Ready = res.Error == ""
+	status := http.StatusOK
+	if !res.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeAdminJSON(w, status, res)
+}
+
+func (a *adminServer) routingTableHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	rt, err := a.client.RoutingTable(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, rt)
+}
+
+// statsHandler returns the stats of this member, or the member in the
+// address query parameter.
+func (a *adminServer) statsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var options []StatsOption
+	if r.URL.Query().Get("runtime") == "true" {
+		options = append(options, CollectRuntime())
+	}
+	address := r.URL.Query().Get("address")
+	ctx, done := a.operations.begin(r.Context(), "stats", address)
+	defer done()
+
+	s, err := a.client.Stats(ctx, address, options...)
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, s)
+}
+
+// dmapsHandler returns the sorted names of the DMaps that have at least one
+// fragment on a cluster member.
+func (a *adminServer) dmapsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	list, err := a.client.ListDMaps(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	result := make([]string, 0, len(list))
+	for _, info := range list {
+		result = append(result, info.Name)
+	}
+	writeAdminJSON(w, http.StatusOK, result)
+}
+
+// destroyDMapHandler destroys the DMap in the path, /dmaps/<name>.
+func (a *adminServer) destroyDMapHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/dmaps/")
+	if name == "" {
+		writeAdminError(w, http.StatusBadRequest, errors.New("dmap name is empty"))
+		return
+	}
+	dm, err := a.client.NewDMap(name)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, done := a.operations.begin(r.Context(), "destroy", name)
+	defer done()
+
+	if err = dm.Destroy(ctx); err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	a.db.log.V(2).Printf("[INFO] DMap: %s has been destroyed by the admin API", name)
+	writeAdminJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// forceUnlockHandler releases the lock on the key without its token. The DMap
+// and the key are given in the dmap and key query parameters.
+func (a *adminServer) forceUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+	name, key := r.URL.Query().Get("dmap"), r.URL.Query().Get("key")
+	if name == "" || key == "" {
+		writeAdminError(w, http.StatusBadRequest, errors.New("dmap and key are required"))
+		return
+	}
+	dm, err := a.db.dmap.NewDMap(name)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = dm.ForceUnlock(r.Context(), key, a.token)
+	if errors.Is(err, dmap.ErrNoSuchLock) {
+		writeAdminError(w, http.StatusNotFound, ErrNoSuchLock)
+		return
+	}
+	if err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	a.db.log.V(2).Printf("[INFO] Lock on key: %s on DMap: %s has been released by the admin API", key, name)
+	writeAdminJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// rebalanceHandler recalculates the routing table and triggers the balancers
+// of the cluster members. It waits for the members to move their partitions
+// if the wait query parameter is true.
+func (a *adminServer) rebalanceHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	ctx, done := a.operations.begin(r.Context(), "rebalance", "")
+	defer done()
+
+	if err := a.db.rebalance(ctx); err != nil {
+		writeAdminError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	a.db.log.V(2).Printf("[INFO] Rebalancing has been triggered by the admin API")
+	if r.URL.Query().Get("wait") == "true" {
+		if err := a.db.awaitRebalance(ctx); err != nil {
+			writeAdminError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// operationsHandler returns the in-flight long operations that are started
+// by the admin API on this member.
+func (a *adminServer) operationsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, a.operations.list())
+}
+
+// cancelOperationHandler cancels the operation in the path,
+// /operations/<id>. The operation returns the progress it has made.
+func (a *adminServer) cancelOperationHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/operations/"), 10, 64)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, errors.New("invalid operation id"))
+		return
+	}
+	if !a.operations.cancel(id) {
+		writeAdminError(w, http.StatusNotFound, errors.New("operation not found"))
+		return
+	}
+	a.db.log.V(2).Printf("[INFO] Operation: %d has been canceled by the admin API", id)
+	writeAdminJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
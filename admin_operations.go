@@ -0,0 +1,114 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operation is a long-running operation that is started by the admin API,
+// see /operations.
+type Operation struct {
+	// ID identifies the operation on this member.
+	ID uint64 `json:"id"`
+
+	// Kind is the type of the operation: destroy, stats or rebalance.
+	Kind string `json:"kind"`
+
+	// Target is the name of the DMap or the address of the member that the
+	// operation runs on. It's empty if the operation runs on the cluster.
+	Target string `json:"target,omitempty"`
+
+	// StartedAt is the time that the operation is started at.
+	StartedAt time.Time `json:"started_at"`
+}
+
+type runningOperation struct {
+	Operation
+	cancel context.CancelFunc
+}
+
+// operationRegistry keeps the in-flight operations of the admin API, so they
+// can be listed and canceled.
+type operationRegistry struct {
+	mtx        sync.Mutex
+	lastID     uint64
+	operations map[uint64]*runningOperation
+}
+
+func newOperationRegistry() *operationRegistry {
+	return &operationRegistry{
+		operations: make(map[uint64]*runningOperation),
+	}
+}
+
+// begin registers a new operation and returns its context, which is canceled
+// by cancel. The returned function has to be called when the operation ends.
+func (r *operationRegistry) begin(ctx context.Context, kind, target string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.lastID++
+	id := r.lastID
+	r.operations[id] = &runningOperation{
+		Operation: Operation{
+			ID:        id,
+			Kind:      kind,
+			Target:    target,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	return ctx, func() {
+		cancel()
+		r.mtx.Lock()
+		delete(r.operations, id)
+		r.mtx.Unlock()
+	}
+}
+
+// list returns the in-flight operations in the order they are started.
+func (r *operationRegistry) list() []Operation {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	result := make([]Operation, 0, len(r.operations))
+	for _, op := range r.operations {
+		result = append(result, op.Operation)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// cancel cancels the context of the operation. It returns false if there is
+// no such operation.
+func (r *operationRegistry) cancel(id uint64) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	op, ok := r.operations[id]
+	if !ok {
+		return false
+	}
+	op.cancel()
+	return true
+}
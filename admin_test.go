@@ -0,0 +1,235 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func adminRequest(t *testing.T, db *Olric, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	db.admin.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdmin_Probes(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/healthz", db.admin.listener.Addr()))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	rec := adminRequest(t, db, http.MethodGet, "/readyz", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var r Readiness
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &r))
+	require.True(t, r.Ready)
+	require.True(t, r.Bootstrapped)
+	require.True(t, r.Quorum)
+
+	t.Run("Quorum lost", func(t *testing.T) {
+		db.config.MemberCountQuorum = 2
+		defer func() {
+			db.config.MemberCountQuorum = 1
+		}()
+		rec := adminRequest(t, db, http.MethodGet, "/readyz", "")
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		var r Readiness
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &r))
+		require.False(t, r.Ready)
+		require.False(t, r.Quorum)
+		require.Equal(t, ErrClusterQuorum.Error(), r.Error)
+	})
+}
+
+func TestAdmin_Auth(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	c.AdminToken = "secret"
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	rec := adminRequest(t, db, http.MethodGet, "/routing-table", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodGet, "/routing-table", "wrong")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodGet, "/routing-table", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var rt RoutingTable
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rt))
+	require.Len(t, rt, int(c.PartitionCount))
+
+	// The probes don't require the token.
+	rec = adminRequest(t, db, http.MethodGet, "/healthz", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAdmin_DestructiveOperationsWithoutToken(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	rec := adminRequest(t, db, http.MethodGet, "/stats", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodDelete, "/dmaps/mydmap", "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodPost, "/rebalance", "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodDelete, "/locks?dmap=mydmap&key=mykey", "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAdmin_ForceUnlock(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	c.AdminToken = "secret"
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	lx, err := dm.Lock(ctx, "mykey", time.Second)
+	require.NoError(t, err)
+
+	rec := adminRequest(t, db, http.MethodDelete, "/locks?dmap=mydmap&key=mykey", "wrong")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodDelete, "/locks?dmap=mydmap", "secret")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodDelete, "/locks?dmap=mydmap&key=mykey", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.ErrorIs(t, lx.Unlock(ctx), ErrNoSuchLock)
+
+	rec = adminRequest(t, db, http.MethodDelete, "/locks?dmap=mydmap&key=mykey", "secret")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_DMaps(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	c.AdminToken = "secret"
+	db := cluster.addMemberWithConfig(t, c, "")
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	for _, name := range []string{"mydmap-2", "mydmap-1"} {
+		_, err := db2.NewEmbeddedClient().NewDMap(name)
+		require.NoError(t, err)
+		dm, err := db.NewEmbeddedClient().NewDMap(name)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			_, err = dm.Put(ctx, testutil.ToKey(i), i)
+			require.NoError(t, err)
+		}
+	}
+
+	rec := adminRequest(t, db, http.MethodGet, "/dmaps", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var names []string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &names))
+	require.Equal(t, []string{"mydmap-1", "mydmap-2"}, names)
+
+	rec = adminRequest(t, db, http.MethodGet, "/dmaps/mydmap-1", "secret")
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodDelete, "/dmaps/mydmap-1", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap-1")
+	require.NoError(t, err)
+	_, err = dm.Get(ctx, testutil.ToKey(1))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestAdmin_Rebalance(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	cluster.addMember(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	c.AdminToken = "secret"
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	// This member isn't the coordinator, the command is sent to the coordinator.
+	rec := adminRequest(t, db, http.MethodPost, "/rebalance", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodPost, "/rebalance?wait=true", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest(t, db, http.MethodGet, "/rebalance", "secret")
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAdmin_Operations(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.AdminAddr = "127.0.0.1:0"
+	c.AdminToken = "secret"
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx, done := db.admin.operations.begin(context.Background(), "destroy", "mydmap")
+	defer done()
+
+	rec := adminRequest(t, db, http.MethodGet, "/operations", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var operations []Operation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &operations))
+	require.Len(t, operations, 1)
+	require.Equal(t, "destroy", operations[0].Kind)
+	require.Equal(t, "mydmap", operations[0].Target)
+
+	rec = adminRequest(t, db, http.MethodDelete, fmt.Sprintf("/operations/%d", operations[0].ID), "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	// The canceled operation returns its progress.
+	_, err := db.NewEmbeddedClient().Stats(ctx, "")
+	var pe *ProgressError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, "stats", pe.Operation)
+
+	done()
+	rec = adminRequest(t, db, http.MethodGet, "/operations", "secret")
+	require.Equal(t, "[]\n", rec.Body.String())
+
+	rec = adminRequest(t, db, http.MethodDelete, fmt.Sprintf("/operations/%d", operations[0].ID), "secret")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
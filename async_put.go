@@ -0,0 +1,95 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+)
+
+// DefaultMaxInflightPuts is the default maximum number of the PutAsync calls
+// that are waiting for an acknowledgement, see WithMaxInflightPuts.
+const DefaultMaxInflightPuts = 1024
+
+// WithMaxInflightPuts sets the maximum number of the PutAsync calls that are
+// waiting for an acknowledgement. PutAsync blocks when the limit is reached,
+// until a write completes or the context is done. The default value is
+// DefaultMaxInflightPuts.
+func WithMaxInflightPuts(n int) EmbeddedClientOption {
+	return func(cfg *embeddedClientConfig) {
+		cfg.maxInflightPuts = n
+	}
+}
+
+// PutFuture is the result of a PutAsync call.
+type PutFuture struct {
+	done chan struct{}
+	pc   *PutConfig
+	err  error
+}
+
+func newPutFuture() *PutFuture {
+	return &PutFuture{done: make(chan struct{})}
+}
+
+func (f *PutFuture) resolve(pc *PutConfig, err error) {
+	f.pc, f.err = pc, err
+	close(f.done)
+}
+
+// Done returns a channel that is closed when the write is acknowledged or
+// failed. It can be used to wait for many futures in a select statement.
+func (f *PutFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the write is acknowledged and returns the result of Put.
+// It returns the context's error if the context is done before that, the
+// write goes on in the background.
+func (f *PutFuture) Wait(ctx context.Context) (*PutConfig, error) {
+	select {
+	case <-f.done:
+		return f.pc, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PutAsync is like Put, but it doesn't wait for the acknowledgement. The
+// returned future is resolved when Put returns, so the write is replicated
+// as configured by ReplicationMode and WriteQuorum. The number of the pending
+// writes is limited by WithMaxInflightPuts, PutAsync blocks until there is a
+// free slot or the context is done. In the latter case, the returned future
+// is resolved with the context's error.
+//
+// The context is used by the write too, so it shouldn't be canceled before the
+// future is resolved. It's not safe to modify the contents of the arguments
+// before the future is resolved.
+func (dm *EmbeddedDMap) PutAsync(ctx context.Context, key string, value interface{}, options ...PutOption) *PutFuture {
+	f := newPutFuture()
+	select {
+	case dm.client.inflightPuts <- struct{}{}:
+	case <-ctx.Done():
+		f.resolve(nil, ctx.Err())
+		return f
+	}
+
+	go func() {
+		defer func() {
+			<-dm.client.inflightPuts
+		}()
+		f.resolve(dm.Put(ctx, key, value, options...))
+	}()
+	return f
+}
@@ -0,0 +1,74 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_DMap_PutAsync(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient(WithMaxInflightPuts(4))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var futures []*PutFuture
+	for i := 0; i < 100; i++ {
+		futures = append(futures, dm.PutAsync(ctx, testutil.ToKey(i), i))
+	}
+	for _, f := range futures {
+		<-f.Done()
+		_, err = f.Wait(ctx)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		gr, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
+
+func TestEmbeddedClient_DMap_PutAsync_Backpressure(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient(WithMaxInflightPuts(1))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Occupy the only slot.
+	e.inflightPuts <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f := dm.PutAsync(ctx, "mykey", "myvalue")
+	_, err = f.Wait(context.Background())
+	require.ErrorIs(t, err, context.Canceled)
+
+	<-e.inflightPuts
+	f = dm.PutAsync(context.Background(), "mykey", "myvalue")
+	_, err = f.Wait(context.Background())
+	require.NoError(t, err)
+}
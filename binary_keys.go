@@ -0,0 +1,50 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"time"
+)
+
+// The keys are stored and sent over the network as they are, a string key
+// may contain arbitrary bytes. The methods below convert the byte slices to
+// strings without any encoding. The keys returned by Query can be converted
+// back with []byte(key).
+
+// PutBinaryKey is like Put, but the key is an arbitrary byte slice. It is safe
+// to modify the contents of the key after PutBinaryKey returns.
+func (dm *EmbeddedDMap) PutBinaryKey(ctx context.Context, key []byte, value interface{}, options ...PutOption) (*PutConfig, error) {
+	return dm.Put(ctx, string(key), value, options...)
+}
+
+// GetBinaryKey is like Get, but the key is an arbitrary byte slice.
+func (dm *EmbeddedDMap) GetBinaryKey(ctx context.Context, key []byte) (*GetResponse, error) {
+	return dm.Get(ctx, string(key))
+}
+
+// DeleteBinaryKeys is like Delete, but the keys are arbitrary byte slices.
+func (dm *EmbeddedDMap) DeleteBinaryKeys(ctx context.Context, keys ...[]byte) (int, error) {
+	strKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		strKeys = append(strKeys, string(key))
+	}
+	return dm.Delete(ctx, strKeys...)
+}
+
+// ExpireBinaryKey is like Expire, but the key is an arbitrary byte slice.
+func (dm *EmbeddedDMap) ExpireBinaryKey(ctx context.Context, key []byte, timeout time.Duration) error {
+	return dm.Expire(ctx, string(key), timeout)
+}
@@ -0,0 +1,67 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedDMap_BinaryKeys(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db1.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Not valid UTF-8, some of them contain the RESP delimiters.
+	var keys [][]byte
+	for i := 0; i < 32; i++ {
+		keys = append(keys, []byte{0xff, 0xfe, byte(i), '\r', '\n', 0x00, byte(255 - i)})
+	}
+
+	for i, key := range keys {
+		_, err = dm.PutBinaryKey(ctx, key, i)
+		require.NoError(t, err)
+	}
+
+	for i, key := range keys {
+		gr, err := dm.GetBinaryKey(ctx, key)
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+
+	require.NoError(t, dm.ExpireBinaryKey(ctx, keys[0], time.Millisecond))
+	<-time.After(10 * time.Millisecond)
+	_, err = dm.GetBinaryKey(ctx, keys[0])
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	count, err := dm.DeleteBinaryKeys(ctx, keys[1:]...)
+	require.NoError(t, err)
+	require.Equal(t, len(keys)-1, count)
+	for _, key := range keys[1:] {
+		_, err = dm.GetBinaryKey(ctx, key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
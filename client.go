@@ -16,9 +16,11 @@ package olric
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/buraksezer/olric/stats"
 )
@@ -39,6 +41,92 @@ type Member struct {
 	// Role of the member in the cluster. There is only one coordinator member
 	// in a healthy cluster.
 	Coordinator bool
+
+	// Tags is the arbitrary key/value metadata of the member, see
+	// config.Config.MemberTags.
+	Tags map[string]string
+
+	// Health is the state of the member as seen by the failure detector of
+	// this member: "alive" or "suspect".
+	Health string
+}
+
+// AccessStats is the access statistics of an entry. It's useful to decide
+// which entries to preload or to move to another tier.
+type AccessStats struct {
+	// Key is the key of the entry.
+	Key string
+
+	// Hits is the estimated number of reads of the entry.
+	Hits int64
+
+	// LastAccess is the time of the last read or write access.
+	LastAccess time.Time
+}
+
+// HotKey is a frequently accessed key of a DMap.
+type HotKey struct {
+	// Key is the accessed key.
+	Key string
+
+	// QPS is the approximate number of reads and writes per second. It's
+	// estimated with a count-min sketch on the partition owner, so it may be
+	// higher than the real rate.
+	QPS float64
+
+	// PartID is the ID of the partition that the key belongs to.
+	PartID uint64
+
+	// Owner is the name of the partition owner.
+	Owner string
+}
+
+// Tombstone describes the deletion of a key. It's kept by the partition owner
+// for config.DMap.TombstoneRetention after the key is deleted.
+type Tombstone struct {
+	// Key is the key of the deleted entry.
+	Key string
+
+	// DeletedAt is the time of the deletion.
+	DeletedAt time.Time
+
+	// DeletedBy is the address of the client that deleted the key. It's the
+	// address of the member for the embedded clients.
+	DeletedBy string
+
+	// Owner is the partition owner that applied the deletion.
+	Owner string
+}
+
+// LockInfo describes an acquired lock, see DMap.LockInfo.
+type LockInfo struct {
+	// TokenHash is the hex encoded SHA-256 hash of the holder's token. The
+	// holder can compare it with the hash of its own token.
+	TokenHash string
+
+	// AcquiredAt is the time of the acquisition.
+	AcquiredAt time.Time
+
+	// TTL is the remaining time until the lock is released automatically.
+	// It's zero if the lock has no timeout.
+	TTL time.Duration
+}
+
+// Tx is a transaction on a DMap, see DMap.Tx. The writes are buffered until
+// the transaction is committed, Get returns the buffered value of a key if
+// there is any. It's not safe for concurrent use.
+type Tx interface {
+	// Get returns the value of the key. It returns ErrKeyNotFound if the key
+	// doesn't exist or it's deleted by the transaction.
+	Get(key string) (*GetResponse, error)
+
+	// Put sets the value of the key when the transaction is committed. Only
+	// EX, PX, EXAT and PXAT are supported as options, it returns
+	// ErrTxPutOption for the others.
+	Put(key string, value interface{}, options ...PutOption) error
+
+	// Delete deletes the key when the transaction is committed.
+	Delete(key string) error
 }
 
 // Iterator defines an interface to implement iterators on the distributed maps.
@@ -50,6 +138,13 @@ type Iterator interface {
 	// Key returns a key name from the distributed map.
 	Key() string
 
+	// Value fetches the current value of the key. It returns ErrKeyNotFound
+	// if the key is deleted after it's scanned.
+	Value() (*GetResponse, error)
+
+	// Err returns the error that stopped the iteration, if there is any.
+	Err() error
+
 	// Close stops the iteration and releases allocated resources.
 	Close()
 }
@@ -76,6 +171,36 @@ func TS(ts int64) PutOption {
 	}
 }
 
+// Tags attaches the given tags to the entry. Entries can be deleted by their
+// tags with DeleteByTag. A Put without Tags clears the tags of the key.
+func Tags(tags ...string) PutOption {
+	return func(cfg *dmap.PutConfig) {
+		cfg.Tags = tags
+	}
+}
+
+// TTLJitter cuts a random part of the TTL, up to the given percent of it, so
+// the keys that are written together with the same TTL don't expire together.
+// The entry never outlives the given TTL. The percent is capped at 100.
+func TTLJitter(percent float64) PutOption {
+	return func(cfg *dmap.PutConfig) {
+		cfg.HasTTLJitter = true
+		cfg.TTLJitter = percent
+	}
+}
+
+// SlidingExpiration sets the TTL of the entry, and the partition owner resets
+// it on every read. It replaces EX, PX, EXAT and PXAT. The sliding expiration
+// is stored with the entry, a backup owner keeps resetting the TTL after it
+// takes over the partition. The TTL is reset once a hundredth of d has passed
+// since the previous reset, and d is rounded up to milliseconds.
+func SlidingExpiration(d time.Duration) PutOption {
+	return func(cfg *dmap.PutConfig) {
+		cfg.HasSliding = true
+		cfg.Sliding = d
+	}
+}
+
 // EX sets the specified expire time, in seconds.
 func EX(ex time.Duration) PutOption {
 	return func(cfg *dmap.PutConfig) {
@@ -124,6 +249,11 @@ func XX() PutOption {
 
 type dmapConfig struct {
 	storageEntryImplementation func() storage.Entry
+	monotonicReads             bool
+	linearizableReads          bool
+	mirrorTarget               DMap
+	mirrorSampleRate           float64
+	readFrom                   ReadPreference
 }
 
 // DMapOption is a function for defining options to control behavior of distributed map instances.
@@ -136,6 +266,44 @@ func StorageEntryImplementation(e func() storage.Entry) DMapOption {
 	}
 }
 
+// MonotonicReads makes Get return ErrStaleRead if the entry is older than the
+// one seen by a previous Get on the same DMap instance. The timestamps of the
+// entries are used to compare the versions, see GetResponse.Timestamp. The
+// latest 65536 keys read are remembered, a key that is forgotten is accepted
+// with any timestamp on its next read.
+func MonotonicReads() DMapOption {
+	return func(cfg *dmapConfig) {
+		cfg.monotonicReads = true
+	}
+}
+
+// LinearizableReads makes the partition owners confirm that they still own
+// the partitions before serving Get and GetEntry. The owner checks its routing
+// table against the cluster coordinator, it costs an extra round-trip if the
+// owner is not the coordinator. It returns ErrStaleRoutingTable during an
+// ownership transfer, the default retry policy retries it. See WithRetry.
+//
+// It reduces the stale reads from the former owners. It's not a full consensus,
+// the reads are still stale if the routing table changes during the read.
+func LinearizableReads() DMapOption {
+	return func(cfg *dmapConfig) {
+		cfg.linearizableReads = true
+	}
+}
+
+// Mirror copies a sample of the writes (Put, Delete and Expire) to the target
+// DMap asynchronously. sampleRate is the fraction of the keys that are
+// mirrored, between 0 and 1. The target may belong to a different cluster, so
+// a new configuration or storage engine can be validated against production
+// traffic. The errors on the target are not returned to the callers, see
+// EmbeddedDMap.MirrorStats.
+func Mirror(target DMap, sampleRate float64) DMapOption {
+	return func(cfg *dmapConfig) {
+		cfg.mirrorTarget = target
+		cfg.mirrorSampleRate = sampleRate
+	}
+}
+
 // ScanOption is a function for defining options to control behavior of the SCAN command.
 type ScanOption func(*dmap.ScanConfig)
 
@@ -158,8 +326,71 @@ func Match(s string) ScanOption {
 	}
 }
 
+type lockConfig struct {
+	blocking bool
+}
+
+// LockOption is a function for defining options to control behavior of the
+// Lock and LockWithTimeout methods.
+type LockOption func(*lockConfig)
+
+// WithBlocking makes Lock wait until the lock is acquired or ctx is done,
+// the deadline is ignored. The waiters are queued on the partition owner
+// and acquire the lock in the order of arrival.
+func WithBlocking() LockOption {
+	return func(cfg *lockConfig) {
+		cfg.blocking = true
+	}
+}
+
 type PutConfig = dmap.PutConfig
 
+// EntryProcessor runs against an entry on its partition owner, see
+// EmbeddedClient.RegisterEntryProcessor and DMap.Execute.
+type EntryProcessor = dmap.EntryProcessor
+
+// ProcessorEntry is the entry that is passed to an EntryProcessor. Call
+// SetValue or Delete to modify it.
+type ProcessorEntry = dmap.ProcessorEntry
+
+// KeyMigration is the set of the keys of a DMap that moved between the members
+// while the cluster is rebalanced.
+type KeyMigration = dmap.KeyMigration
+
+// KeyMigrationListener is notified when the keys leave or arrive on a member,
+// see EmbeddedClient.RegisterKeyMigrationListener.
+type KeyMigrationListener = dmap.KeyMigrationListener
+
+// RetentionReport is the result of the last retention run of a DMap on a member.
+type RetentionReport = dmap.RetentionReport
+
+// WarmupEntry is an entry that is preloaded by DMap.Warmup.
+type WarmupEntry = dmap.WarmupEntry
+
+// WarmupSource provides the entries to DMap.Warmup. Next returns io.EOF after
+// the last entry.
+type WarmupSource = dmap.WarmupSource
+
+// DMapInfo describes a DMap that has entries on the cluster, see
+// EmbeddedClient.ListDMaps.
+type DMapInfo = dmap.DMapInfo
+
+// ProgressError is returned by a long operation, like DMap.Destroy or
+// EmbeddedClient.Stats, that is interrupted by the cancellation of its
+// context. It reports the number of the completed steps.
+type ProgressError = dmap.ProgressError
+
+// SlowLogEntry is a command that took longer than config.Config.SlowLogThreshold
+// on a member, see EmbeddedClient.SlowLog.
+type SlowLogEntry = server.SlowLogEntry
+
+// ServerCommand is a command that is received by a member, see CommandHook.
+type ServerCommand = server.Command
+
+// CommandHook is called before a member serves a command, see
+// EmbeddedClient.RegisterCommandHook.
+type CommandHook = server.CommandHook
+
 // DMap defines methods to access and manipulate distributed maps.
 type DMap interface {
 	// Name exposes name of the DMap.
@@ -170,16 +401,65 @@ type DMap interface {
 	// It is safe to modify the contents of the arguments after Put returns but not before.
 	Put(ctx context.Context, key string, value interface{}, options ...PutOption) (*PutConfig, error)
 
+	// SetAndPublish sets the value for the given key and publishes the message
+	// to the channel. The partition owner publishes the message after the write
+	// is committed. If the message cannot be published, the write is kept and
+	// the error is returned. The delivery is at-least-once: retrying the call
+	// may deliver the message more than once, and the readers may see the new
+	// value before the message is delivered.
+	SetAndPublish(ctx context.Context, key string, value interface{}, channel, message string, options ...PutOption) error
+
+	// PutBinaryKey is like Put, but the key is an arbitrary byte slice. The
+	// keys are binary-safe everywhere, they don't have to be valid UTF-8.
+	PutBinaryKey(ctx context.Context, key []byte, value interface{}, options ...PutOption) (*PutConfig, error)
+
+	// GetBinaryKey is like Get, but the key is an arbitrary byte slice.
+	GetBinaryKey(ctx context.Context, key []byte) (*GetResponse, error)
+
+	// DeleteBinaryKeys is like Delete, but the keys are arbitrary byte slices.
+	DeleteBinaryKeys(ctx context.Context, keys ...[]byte) (int, error)
+
+	// ExpireBinaryKey is like Expire, but the key is an arbitrary byte slice.
+	ExpireBinaryKey(ctx context.Context, key []byte, timeout time.Duration) error
+
+	// PutAsync is like Put, but it returns a future instead of waiting for the
+	// acknowledgement. The number of the pending writes is limited, PutAsync
+	// blocks when the limit is reached. See WithMaxInflightPuts.
+	PutAsync(ctx context.Context, key string, value interface{}, options ...PutOption) *PutFuture
+
 	// Get gets the value for the given key. It returns ErrKeyNotFound if the DB
 	// does not contain the key. It's thread-safe. It is safe to modify the contents
 	// of the returned value. See GetResponse for the details.
-	Get(ctx context.Context, key string) (*GetResponse, error)
+	//
+	// The reads are served by the partition owner by default, see ReadFrom and
+	// WithReadFrom to read from the backup owners.
+	Get(ctx context.Context, key string, options ...GetOption) (*GetResponse, error)
+
+	// GetMulti gets the values of the given keys. It doesn't fail the whole
+	// batch if a partition owner is unavailable, the result of every key has
+	// its own error. See GetMultiResult and MaxFailures.
+	GetMulti(ctx context.Context, keys []string, options ...GetMultiOption) (map[string]GetMultiResult, error)
+
+	// GetEntry is like Get, but it returns the metadata of the entry too:
+	// timestamp, TTL, last access time and the member that served the read.
+	// It returns ErrKeyNotFound if the DB does not contain the key.
+	GetEntry(ctx context.Context, key string, options ...GetOption) (*Entry, error)
 
 	// Delete deletes values for the given keys. Delete will not return error
 	// if key doesn't exist. It's thread-safe. It is safe to modify the contents
 	// of the argument after Delete returns.
 	Delete(ctx context.Context, keys ...string) (int, error)
 
+	// GetTombstone returns the tombstone of a deleted key. It returns
+	// ErrKeyNotFound if the key was not deleted within the retention period,
+	// or it's written again after the deletion. It returns
+	// ErrTombstonesDisabled if config.DMap.TombstoneRetention is not set.
+	GetTombstone(ctx context.Context, key string) (*Tombstone, error)
+
+	// DeleteByTag deletes all entries that are stored with the given tag and
+	// returns the number of deleted entries. See Tags.
+	DeleteByTag(ctx context.Context, tag string) (int, error)
+
 	// Expire updates the expiry for the given key. It returns ErrKeyNotFound if
 	// the DB does not contain the key. It's thread-safe.
 	Expire(ctx context.Context, key string, timeout time.Duration) error
@@ -188,11 +468,12 @@ type DMap interface {
 	// this dmap.
 	//
 	// It returns immediately if it acquires the lock for the given key. Otherwise,
-	// it waits until deadline.
+	// it waits until deadline. The waiters acquire the lock in the order of
+	// arrival. See WithBlocking to wait until ctx is done.
 	//
 	// You should know that the locks are approximate, and only to be used for
 	// non-critical purposes.
-	Lock(ctx context.Context, key string, deadline time.Duration) (LockContext, error)
+	Lock(ctx context.Context, key string, deadline time.Duration, options ...LockOption) (LockContext, error)
 
 	// LockWithTimeout sets a lock for the given key. If the lock is still unreleased
 	// the end of given period of time,
@@ -204,7 +485,20 @@ type DMap interface {
 	//
 	// You should know that the locks are approximate, and only to be used for
 	// non-critical purposes.
-	LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration) (LockContext, error)
+	LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration, options ...LockOption) (LockContext, error)
+
+	// LockInfo returns the hash of the holder token, the acquisition time and
+	// the remaining TTL of the lock on the given key. It returns ErrNoSuchLock
+	// if the key is not locked. Use the force-unlock endpoint of the admin API
+	// to break a lock whose holder crashed without a timeout.
+	LockInfo(ctx context.Context, key string) (*LockInfo, error)
+
+	// MultiLock sets locks for the given keys and returns a single LockContext
+	// to manage all of them. The keys are locked in a deterministic order to
+	// avoid deadlocks between the callers. If one of the locks cannot be
+	// acquired until deadline, the acquired ones are released and
+	// ErrLockNotAcquired is returned.
+	MultiLock(ctx context.Context, keys []string, deadline time.Duration) (LockContext, error)
 
 	// Destroy flushes the given DMap on the cluster. You should know that there
 	// is no global lock on DMaps. So if you call Put/PutEx and Destroy methods
@@ -212,10 +506,112 @@ type DMap interface {
 	Destroy(ctx context.Context) error
 
 	Function(ctx context.Context, label string, function string, arg []byte) ([]byte, error)
+
+	// Execute runs the entry processor against the entry on the owner of the
+	// key, and returns its result. The processor holds the lock of the key
+	// while it runs, so a read-modify-write takes a single round trip.
+	// It returns ErrProcessorNotFound if the processor is not registered on the owner.
+	Execute(ctx context.Context, key, processor string, args []byte) ([]byte, error)
+
+	// Tx runs fn and commits its writes atomically on the partition owner.
+	// The keys of a transaction have to be on the same partition. If HashTags
+	// is enabled for the DMap, the keys with the same hash tag, e.g.
+	// {user:1}.profile and {user:1}.orders, are stored on the same partition.
+	// Nothing is written if fn returns an error.
+	// It returns ErrTxConflict if a key that fn reads is modified before the
+	// commit, fn may be run again. It returns ErrCrossPartitionTx if the keys
+	// are on different partitions.
+	Tx(ctx context.Context, fn func(tx Tx) error) error
+
+	// IncrMany atomically adds the deltas to the integer values of the keys and
+	// returns the new values. A missing key is treated as zero. The keys are
+	// grouped by their partition owners, and one request is sent to every owner.
+	// Every key is incremented atomically but the batch is not, some keys may
+	// be incremented if an error is returned. It returns ErrNotInteger if a
+	// value is not an integer.
+	IncrMany(ctx context.Context, deltas map[string]int) (map[string]int, error)
+
+	// Warmup preloads the DMap with the entries of the source after a cold
+	// start, and returns the number of the stored entries. The entries are
+	// sent to their partition owners in batches, and the owners replicate a
+	// batch to the backup owners at once. The keys that already exist are
+	// not overwritten. The entries are not propagated to the Writer of the
+	// DMap, and the LRU eviction and the quotas are not applied.
+	Warmup(ctx context.Context, source WarmupSource) (int, error)
+
+	// Export writes the entries of the DMap to w in a versioned binary format,
+	// and returns the number of the written entries. The entries keep their
+	// values as stored, TTLs, timestamps and tags. The writes during the export
+	// may be missed. It returns ErrStaleRoutingTable if the partitions move
+	// before the export is completed, the export should be started again.
+	Export(ctx context.Context, w io.Writer) (int, error)
+
+	// Import stores the entries of a stream written by Export, possibly from a
+	// different DMap or cluster, and returns the number of the stored entries.
+	// The existing keys are overwritten and the expired entries are skipped.
+	// The entries are sent to their partition owners in batches like Warmup,
+	// they are not propagated to the Writer of the DMap. It returns
+	// ErrInvalidExport if the stream cannot be read.
+	Import(ctx context.Context, r io.Reader) (int, error)
+
+	// Query returns the entries whose values match the filter, by key. The
+	// filter runs on the partition owners, so only the matching entries are
+	// sent over the network. The values have to be JSON or MessagePack encoded
+	// objects, see NewJSONCodec and NewMsgpackCodec. A filter is a list of
+	// conditions joined with AND, e.g. `age >= 30 AND address.city = "Berlin"`.
+	// It returns ErrInvalidFilter if the filter cannot be parsed, and
+	// ErrResponseTooLarge if the result exceeds config.Config.MaxResponseSize.
+	Query(ctx context.Context, filter string) (map[string]*GetResponse, error)
+
+	// QueryPage is like Query, but it returns the result in pages that fit in
+	// config.Config.MaxResponseSize. The cursor of the first page is zero. It
+	// returns the cursor of the next page, or zero if the page is the last one.
+	QueryPage(ctx context.Context, filter string, cursor uint64) (map[string]*GetResponse, uint64, error)
+
+	// Scan returns an iterator over the keys of the DMap. The partitions are
+	// scanned one by one on their owners, in pages of Count keys. A key that
+	// exists during the whole iteration is returned exactly once, even if the
+	// routing table changes in the meantime. See Count and Match.
+	Scan(ctx context.Context, options ...ScanOption) (Iterator, error)
+
+	// Hottest returns the n most frequently read entries of the DMap, in
+	// descending order. The reads are sampled on the partition owners, see
+	// config.DMap.AccessSampleRate. It returns ErrAccessStatsDisabled if the
+	// sampling is not enabled for the DMap.
+	Hottest(ctx context.Context, n int) ([]AccessStats, error)
+
+	// Coldest returns the n least frequently read entries of the DMap, in
+	// ascending order. The entries with the same number of reads are ordered
+	// by their last access time. It returns ErrAccessStatsDisabled if the
+	// sampling is not enabled for the DMap.
+	Coldest(ctx context.Context, n int) ([]AccessStats, error)
+
+	// HSet sets a field of the hash that is stored at key. The hash is modified
+	// atomically on the partition owner, so the other fields are not sent over
+	// the network. value type is arbitrary. It returns ErrNotHash if the key
+	// holds a value that is set by Put.
+	HSet(ctx context.Context, key, field string, value interface{}) error
+
+	// HGet returns the value of a field. It returns ErrKeyNotFound if the key
+	// doesn't exist, and ErrFieldNotFound if the field is not in the hash.
+	HGet(ctx context.Context, key, field string) (*HashField, error)
+
+	// HDel deletes the fields of the hash and returns the number of the deleted
+	// fields. The key is deleted when the hash becomes empty.
+	HDel(ctx context.Context, key string, fields ...string) (int, error)
+
+	// HGetAll returns all the fields of the hash that is stored at key.
+	HGetAll(ctx context.Context, key string) (map[string]*HashField, error)
+
+	// HIncrBy atomically adds delta to the integer value of a field and returns
+	// the new value. A missing field is treated as zero.
+	HIncrBy(ctx context.Context, key, field string, delta int) (int, error)
 }
 
 type statsConfig struct {
 	CollectRuntime bool
+	NoPartitions   bool
+	DMaps          []string
 }
 
 // StatsOption is a function for defining options to control behavior of the STATS command.
@@ -228,6 +624,27 @@ func CollectRuntime() StatsOption {
 	}
 }
 
+// StatsFilter narrows down the statistics returned by a cluster member.
+type StatsFilter struct {
+	// DMaps keeps the statistics of these DMaps only, in the partitions and
+	// the SLOs. The partitions without any of them are dropped. All DMaps
+	// are kept if it's empty.
+	DMaps []string
+
+	// Partitions keeps the partition and backup statistics. They are the
+	// bulk of the statistics on large clusters.
+	Partitions bool
+}
+
+// FilterStats is a StatsOption for filtering the statistics on the cluster
+// member, before they are sent.
+func FilterStats(f StatsFilter) StatsOption {
+	return func(cfg *statsConfig) {
+		cfg.NoPartitions = !f.Partitions
+		cfg.DMaps = f.DMaps
+	}
+}
+
 type pubsubConfig struct {
 	Address string
 }
@@ -253,9 +670,31 @@ type Client interface {
 	// NewPubSub returns a new PubSub client with the given options.
 	NewPubSub(options ...PubSubOption) (*PubSub, error)
 
+	// NewQueue returns a new Queue client with the given options.
+	NewQueue(name string, options ...QueueOption) (Queue, error)
+
+	// NewSet returns a new Set client.
+	NewSet(name string) (Set, error)
+
+	// NewSortedSet returns a new SortedSet client.
+	NewSortedSet(name string) (SortedSet, error)
+
 	// Stats returns stats.Stats with the given options.
 	Stats(ctx context.Context, address string, options ...StatsOption) (stats.Stats, error)
 
+	// StatsDelta returns the counters that are changed since the delta of the
+	// given cursor. The delta is computed on the member, see stats.Delta.
+	StatsDelta(ctx context.Context, address string, cursor stats.DeltaCursor, options ...StatsOption) (stats.Delta, error)
+
+	// HotKeys returns the n most frequently accessed keys of the given DMap
+	// with their approximate access rates. It returns ErrHotKeysDisabled if
+	// config.DMap.HotKeysWindow is not set for the DMap.
+	HotKeys(ctx context.Context, dmap string, n int) ([]HotKey, error)
+
+	// ListDMaps returns the DMaps that have entries anywhere in the cluster,
+	// sorted by name, with their number of entries and configured policies.
+	ListDMaps(ctx context.Context) ([]DMapInfo, error)
+
 	// Ping sends a ping message to an Olric node. Returns PONG if message is empty,
 	// otherwise return a copy of the message as a bulk. This command is often used to test
 	// if a connection is still alive, or to measure latency.
@@ -264,6 +703,10 @@ type Client interface {
 	// RoutingTable returns the latest version of the routing table.
 	RoutingTable(ctx context.Context) (RoutingTable, error)
 
+	// LocateKey returns the partition of the key in the given DMap with its
+	// primary, previous and backup owners, by the latest routing table.
+	LocateKey(ctx context.Context, dmap, key string) (KeyLocation, error)
+
 	// Members returns a thread-safe list of cluster members.
 	Members(ctx context.Context) ([]Member, error)
 
@@ -0,0 +1,152 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CommandMetrics is a snapshot of the client-observed metrics of a command.
+type CommandMetrics struct {
+	// Calls is the number of the calls, including the failed ones.
+	Calls int64
+
+	// Errors is the number of the failed calls. ErrKeyNotFound is not counted
+	// as an error.
+	Errors int64
+
+	// Retries is the number of the retried attempts, see WithRetry.
+	Retries int64
+
+	// Hedges is the number of the calls that sent a second request to a
+	// backup owner, see WithHedgedReads.
+	Hedges int64
+
+	// TotalLatency is the sum of the latencies of the calls. The latency of a
+	// call includes the retries and the backoff periods.
+	TotalLatency time.Duration
+
+	// MaxLatency is the latency of the slowest call.
+	MaxLatency time.Duration
+}
+
+// AvgLatency returns the average latency of the calls.
+func (c CommandMetrics) AvgLatency() time.Duration {
+	if c.Calls == 0 {
+		return 0
+	}
+	return c.TotalLatency / time.Duration(c.Calls)
+}
+
+// ClientMetrics is a snapshot of the client-observed metrics of an EmbeddedClient.
+type ClientMetrics struct {
+	// Commands is the metrics of the commands by name, e.g. "Put". Get is
+	// recorded as GetEntry.
+	Commands map[string]CommandMetrics
+
+	// Retries is the total number of the retried attempts.
+	Retries int64
+
+	// Hedges is the total number of the hedged requests.
+	Hedges int64
+}
+
+type clientMetrics struct {
+	mtx      sync.Mutex
+	commands map[string]*CommandMetrics
+}
+
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{
+		commands: make(map[string]*CommandMetrics),
+	}
+}
+
+func (m *clientMetrics) command(name string) *CommandMetrics {
+	c, ok := m.commands[name]
+	if !ok {
+		c = &CommandMetrics{}
+		m.commands[name] = c
+	}
+	return c
+}
+
+func (m *clientMetrics) record(command string, latency time.Duration, retries int, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c := m.command(command)
+	c.Calls++
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		c.Errors++
+	}
+	c.Retries += int64(retries)
+	c.TotalLatency += latency
+	if latency > c.MaxLatency {
+		c.MaxLatency = latency
+	}
+}
+
+func (m *clientMetrics) recordHedge(command string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.command(command).Hedges++
+}
+
+func (m *clientMetrics) snapshot() ClientMetrics {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	result := ClientMetrics{
+		Commands: make(map[string]CommandMetrics, len(m.commands)),
+	}
+	for name, c := range m.commands {
+		result.Commands[name] = *c
+		result.Retries += c.Retries
+		result.Hedges += c.Hedges
+	}
+	return result
+}
+
+// do runs a command with the retry policy of the client and records its metrics.
+// Every attempt goes through the interceptors.
+func (e *EmbeddedClient) do(ctx context.Context, cmd *ClientCommand, f commandFunc) error {
+	var attempts int
+	start := time.Now()
+	err := e.retry.do(ctx, func() error {
+		attempts++
+		return e.intercept(ctx, cmd, f)
+	})
+	e.metrics.record(cmd.Name, time.Since(start), attempts-1, err)
+	return err
+}
+
+// observe runs a command that is not retried and records its metrics.
+func (e *EmbeddedClient) observe(ctx context.Context, cmd *ClientCommand, f commandFunc) error {
+	start := time.Now()
+	err := e.intercept(ctx, cmd, f)
+	e.metrics.record(cmd.Name, time.Since(start), 0, err)
+	return err
+}
+
+// Metrics returns the metrics of the commands that are observed by this client,
+// so the usage of Olric can be monitored on the application side.
+func (e *EmbeddedClient) Metrics() ClientMetrics {
+	return e.metrics.snapshot()
+}
@@ -0,0 +1,80 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_Metrics(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, "mykey", i)
+		require.NoError(t, err)
+	}
+	_, err = dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	_, err = dm.Get(ctx, "unknown")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = dm.Execute(ctx, "mykey", "unknown", nil)
+	require.ErrorIs(t, err, ErrProcessorNotFound)
+
+	m := e.Metrics()
+	require.Equal(t, int64(10), m.Commands["Put"].Calls)
+	require.Zero(t, m.Commands["Put"].Errors)
+	require.NotZero(t, m.Commands["Put"].MaxLatency)
+	require.NotZero(t, m.Commands["Put"].AvgLatency())
+
+	require.Equal(t, int64(2), m.Commands["GetEntry"].Calls)
+	require.Zero(t, m.Commands["GetEntry"].Errors)
+
+	require.Equal(t, int64(1), m.Commands["Execute"].Calls)
+	require.Equal(t, int64(1), m.Commands["Execute"].Errors)
+	require.Zero(t, m.Retries)
+}
+
+func TestClientMetrics_Retries(t *testing.T) {
+	e := &EmbeddedClient{
+		retry:   testRetryPolicy(3),
+		metrics: newClientMetrics(),
+	}
+
+	var calls int
+	err := e.do(context.Background(), &ClientCommand{Name: "Put"}, func(context.Context, *ClientCommand) error {
+		calls++
+		if calls < 3 {
+			return ErrWriteQuorum
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	m := e.Metrics()
+	require.Equal(t, int64(1), m.Commands["Put"].Calls)
+	require.Equal(t, int64(2), m.Commands["Put"].Retries)
+	require.Equal(t, int64(2), m.Retries)
+	require.GreaterOrEqual(t, m.Commands["Put"].TotalLatency, 3*time.Millisecond)
+}
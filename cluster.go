@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/tidwall/redcon"
 )
@@ -26,14 +28,103 @@ import (
 type Route struct {
 	PrimaryOwners []string
 	ReplicaOwners []string
+
+	// Tags holds the tags of the owners that have any, by the member name.
+	// See config.Config.MemberTags.
+	Tags map[string]map[string]string
+}
+
+func (r *Route) addTags(member discovery.Member) {
+	if len(member.Tags) == 0 {
+		return
+	}
+	if r.Tags == nil {
+		r.Tags = make(map[string]map[string]string)
+	}
+	r.Tags[member.Name] = member.Tags
 }
 
 type RoutingTable map[uint64]Route
 
+// RouteOfTag returns the partition ID and the owners of the keys with the given
+// hash tag. The keys that contain the tag between curly braces, e.g. "user:1"
+// in "{user:1}.profile", are stored on the same partition in all DMaps that
+// enable HashTags.
+func (r RoutingTable) RouteOfTag(tag string) (uint64, Route) {
+	partID := partitions.TagPartitionID(tag, uint64(len(r)))
+	return partID, r[partID]
+}
+
+// GroupKeysByOwner groups the keys of the given DMap by the names of their
+// current primary owners. It's useful to batch the requests or to process the
+// keys close to their owners. The keys of a partition without an owner are
+// not returned. hashTags has to be equal to HashTags of the DMap.
+func (r RoutingTable) GroupKeysByOwner(dmap string, hashTags bool, keys ...string) map[string][]string {
+	result := make(map[string][]string)
+	if len(r) == 0 {
+		return result
+	}
+	for _, key := range keys {
+		route, ok := r[partitions.KeyPartitionID(dmap, key, uint64(len(r)), hashTags)]
+		if !ok || len(route.PrimaryOwners) == 0 {
+			continue
+		}
+		// The last one is the current owner, the others are the previous owners.
+		owner := route.PrimaryOwners[len(route.PrimaryOwners)-1]
+		result[owner] = append(result[owner], key)
+	}
+	return result
+}
+
+// KeyLocation is the partition of a key and its owners, see
+// RoutingTable.LocateKey.
+type KeyLocation struct {
+	// PartitionID is the ID of the partition that the key belongs to.
+	PartitionID uint64
+
+	// PrimaryOwner is the name of the current primary owner. It's empty if
+	// the partition doesn't have an owner.
+	PrimaryOwner string
+
+	// PreviousOwners is the names of the former primary owners that still
+	// hold some keys of the partition, while the cluster is rebalanced.
+	PreviousOwners []string
+
+	// BackupOwners is the names of the members that keep the backups of the
+	// partition.
+	BackupOwners []string
+}
+
+// LocateKey returns the partition of the key in the given DMap and the owners
+// of the partition. It's useful to schedule the work where the data lives, or
+// to find the owner of a hot key. hashTags has to be equal to HashTags of
+// the DMap.
+func (r RoutingTable) LocateKey(dmap, key string, hashTags bool) KeyLocation {
+	if len(r) == 0 {
+		return KeyLocation{}
+	}
+	partID := partitions.KeyPartitionID(dmap, key, uint64(len(r)), hashTags)
+	route := r[partID]
+
+	loc := KeyLocation{
+		PartitionID:  partID,
+		BackupOwners: route.ReplicaOwners,
+	}
+	if n := len(route.PrimaryOwners); n > 0 {
+		// The last one is the current owner, the others are the previous owners.
+		loc.PrimaryOwner = route.PrimaryOwners[n-1]
+		loc.PreviousOwners = route.PrimaryOwners[:n-1]
+	}
+	return loc
+}
+
 func mapToRoutingTable(slice []interface{}) (RoutingTable, error) {
 	rt := make(RoutingTable)
 	for _, raw := range slice {
 		item := raw.([]interface{})
+		if len(item) < 3 {
+			return nil, fmt.Errorf("invalid route: %v", item)
+		}
 		rawPartID, rawPrimaryOwners, rawReplicaOwners := item[0], item[1], item[2]
 		var partID uint64
 		switch rawPartID.(type) {
@@ -73,11 +164,70 @@ func mapToRoutingTable(slice []interface{}) (RoutingTable, error) {
 			}
 			r.ReplicaOwners = append(r.ReplicaOwners, owner)
 		}
+
+		// The tags of the owners are sent by the newer members.
+		if len(item) > 3 {
+			tags, err := mapToRouteTags(item[3])
+			if err != nil {
+				return nil, err
+			}
+			r.Tags = tags
+		}
 		rt[partID] = r
 	}
 	return rt, nil
 }
 
+// mapToRouteTags parses an array of the member names followed by an array of
+// their tags in key/value pairs.
+func mapToRouteTags(raw interface{}) (map[string]map[string]string, error) {
+	slice, ok := raw.([]interface{})
+	if !ok || len(slice)%2 != 0 {
+		return nil, fmt.Errorf("invalid tags: %v", raw)
+	}
+	if len(slice) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]map[string]string)
+	for i := 0; i < len(slice); i += 2 {
+		name, ok := slice[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid member name: %v", slice[i])
+		}
+		pairs, ok := slice[i+1].([]interface{})
+		if !ok || len(pairs)%2 != 0 {
+			return nil, fmt.Errorf("invalid tags of %s: %v", name, slice[i+1])
+		}
+		tags := make(map[string]string)
+		for j := 0; j < len(pairs); j += 2 {
+			key, ok := pairs[j].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid tag key: %v", pairs[j])
+			}
+			value, ok := pairs[j+1].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid tag value: %v", pairs[j+1])
+			}
+			tags[key] = value
+		}
+		result[name] = tags
+	}
+	return result, nil
+}
+
+func writeRouteTags(conn redcon.Conn, tags map[string]map[string]string) {
+	conn.WriteArray(len(tags) * 2)
+	for name, memberTags := range tags {
+		conn.WriteBulkString(name)
+		conn.WriteArray(len(memberTags) * 2)
+		for key, value := range memberTags {
+			conn.WriteBulkString(key)
+			conn.WriteBulkString(value)
+		}
+	}
+}
+
 func (db *Olric) clusterRoutingTableCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	_, err := protocol.ParseClusterRoutingTable(cmd)
 	if err != nil {
@@ -89,7 +239,7 @@ func (db *Olric) clusterRoutingTableCommandHandler(conn redcon.Conn, cmd redcon.
 		conn.WriteArray(int(db.config.PartitionCount))
 		rt := db.fillRoutingTable()
 		for partID := uint64(0); partID < db.config.PartitionCount; partID++ {
-			conn.WriteArray(3)
+			conn.WriteArray(4)
 			conn.WriteUint64(partID)
 
 			r := rt[partID]
@@ -104,6 +254,7 @@ func (db *Olric) clusterRoutingTableCommandHandler(conn redcon.Conn, cmd redcon.
 			for _, owner := range replicaOwners {
 				conn.WriteBulkString(owner)
 			}
+			writeRouteTags(conn, r.Tags)
 		}
 		return
 	}
@@ -131,10 +282,12 @@ func (db *Olric) fillRoutingTable() RoutingTable {
 		primaryOwners := db.primary.PartitionOwnersByID(partID)
 		for _, owner := range primaryOwners {
 			r.PrimaryOwners = append(r.PrimaryOwners, owner.String())
+			r.addTags(owner)
 		}
 		replicaOwners := db.backup.PartitionOwnersByID(partID)
 		for _, owner := range replicaOwners {
 			r.ReplicaOwners = append(r.ReplicaOwners, owner.String())
+			r.addTags(owner)
 		}
 		rt[partID] = r
 	}
@@ -0,0 +1,53 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/events"
+	"github.com/buraksezer/olric/internal/eventbus"
+)
+
+// ClusterEvents delivers the membership events seen by this member:
+// *events.NodeJoinEvent, *events.NodeLeftEvent and
+// *events.RoutingTableUpdatedEvent. The routing table is updated after the
+// members join or leave, fetch it with RoutingTable when it's received.
+//
+// The same events are published to the cluster.events channel if
+// config.Config.EnableClusterEventsChannel is set, subscribe to it with
+// PubSub on the other processes.
+type ClusterEvents struct {
+	sub    *eventbus.Subscription
+	cancel context.CancelFunc
+}
+
+// Events returns the channel that the events are delivered to. It's closed
+// after Close is called, the context is canceled or the member is shut down.
+func (c *ClusterEvents) Events() <-chan events.Event {
+	return c.sub.Events()
+}
+
+// Dropped returns the number of the events that are dropped because the
+// receiver fell behind.
+func (c *ClusterEvents) Dropped() int64 {
+	return c.sub.Dropped()
+}
+
+// Close cancels the subscription.
+func (c *ClusterEvents) Close() {
+	c.cancel()
+	c.sub.Close()
+}
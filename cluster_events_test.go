@@ -0,0 +1,57 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_ClusterEvents(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ce := db.NewEmbeddedClient().ClusterEvents(ctx, 0)
+
+	db2 := cluster.addMember(t)
+
+	var joined, updated bool
+	for !joined || !updated {
+		select {
+		case ev := <-ce.Events():
+			switch v := ev.(type) {
+			case *events.NodeJoinEvent:
+				require.Equal(t, db2.rt.This().String(), v.NodeJoin)
+				joined = true
+			case *events.RoutingTableUpdatedEvent:
+				require.Equal(t, db.rt.This().String(), v.Source)
+				updated = true
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, joined: %v, updated: %v", joined, updated)
+		}
+	}
+
+	// The stream is closed when the context is canceled.
+	cancel()
+	for range ce.Events() {
+	}
+	ce.Close()
+}
@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
 	"github.com/buraksezer/olric"
 	"github.com/buraksezer/olric/cmd/olricd/server"
@@ -41,6 +43,9 @@ Options:
   -c, --config  Sets configuration file path. Default is olricd-local.yaml in the
                 current folder. Set OLRICD_CONFIG to overwrite it.
 
+Send SIGHUP to reload log level, DMap limits, client timeouts and quorum
+sizes from the configuration file without a restart.
+
 The Go runtime version %s
 Report bugs to https://github.com/buraksezer/olric/issues
 `
@@ -64,6 +69,24 @@ const (
 	EnvConfigFile = "OLRICD_CONFIG"
 )
 
+// reloadOnSIGHUP loads the configuration file again and applies it to the
+// running server, every time the process receives SIGHUP.
+func reloadOnSIGHUP(s *server.Olricd, c *config.Config, path string) {
+	reloadCh := make(chan os.Signal, 1)
+	signal.Notify(reloadCh, syscall.SIGHUP)
+	for range reloadCh {
+		c.Logger.Printf("[INFO] Signal caught: SIGHUP, reloading %s", path)
+		nc, err := config.Load(path)
+		if err != nil {
+			c.Logger.Printf("[ERROR] Failed to load the configuration file: %s: %v", path, err)
+			continue
+		}
+		if err = s.ReloadConfig(context.Background(), nc); err != nil {
+			c.Logger.Printf("[ERROR] Failed to reload the configuration: %v", err)
+		}
+	}
+}
+
 func main() {
 	args := &arguments{}
 
@@ -120,6 +143,8 @@ func main() {
 		c.Logger.Fatalf("[ERROR] Failed to create a new Olric instance: %v", err)
 	}
 
+	go reloadOnSIGHUP(s, c, args.config)
+
 	if err = s.Start(); err != nil {
 		c.Logger.Printf("[ERROR] Failed to start Olric: %v", err)
 
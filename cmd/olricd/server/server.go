@@ -53,6 +53,9 @@ func (s *Olricd) waitForInterrupt() {
 	signal.Notify(shutDownChan, syscall.SIGTERM, syscall.SIGINT)
 	ch := <-shutDownChan
 	s.log.Printf("[INFO] Signal catched: %s", ch.String())
+	if s.config.ShutdownDrainTimeout > 0 {
+		s.log.Printf("[INFO] Draining the node for up to %s", s.config.ShutdownDrainTimeout)
+	}
 
 	// Awaits for shutdown
 	s.errGr.Go(func() error {
@@ -95,6 +98,12 @@ func (s *Olricd) Start() error {
 	return s.errGr.Wait()
 }
 
+// ReloadConfig applies the reloadable subset of the given configuration to the
+// running instance. See olric.EmbeddedClient.ReloadConfig for the details.
+func (s *Olricd) ReloadConfig(ctx context.Context, c *config.Config) error {
+	return s.db.NewEmbeddedClient().ReloadConfig(ctx, c)
+}
+
 // Shutdown stops background servers and leaves the cluster.
 func (s *Olricd) Shutdown(ctx context.Context) error {
 	return s.db.Shutdown(ctx)
@@ -0,0 +1,105 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes the values before they are sent to the cluster, and decodes
+// them in GetResponse.Scan. The values are stored as byte slices, so every
+// client that reads a DMap has to use the same codec.
+type Codec interface {
+	// Encode returns the encoded form of v.
+	Encode(v interface{}) ([]byte, error)
+
+	// Decode decodes data into v. v has to be a pointer.
+	Decode(data []byte, v interface{}) error
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Decode(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// NewMsgpackCodec returns a Codec that uses MessagePack.
+func NewMsgpackCodec() Codec {
+	return msgpackCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// NewJSONCodec returns a Codec that uses encoding/json.
+func NewJSONCodec() Codec {
+	return jsonCodec{}
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// NewGobCodec returns a Codec that uses encoding/gob. The concrete types that
+// are stored in interface values have to be registered with gob.Register.
+func NewGobCodec() Codec {
+	return gobCodec{}
+}
+
+type embeddedClientConfig struct {
+	codec           Codec
+	retry           *retryPolicy
+	maxInflightPuts int
+	hedge           *hedgePolicy
+	interceptors    []Interceptor
+}
+
+// EmbeddedClientOption is a function for defining options to control
+// behavior of EmbeddedClient instances.
+type EmbeddedClientOption func(*embeddedClientConfig)
+
+// WithCodec sets the codec that encodes the values in Put and decodes them in
+// GetResponse.Scan. Without a codec, the values are encoded with the built-in
+// encoder and decoded by the GetResponse helpers.
+func WithCodec(c Codec) EmbeddedClientOption {
+	return func(cfg *embeddedClientConfig) {
+		cfg.codec = c
+	}
+}
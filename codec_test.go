@@ -0,0 +1,84 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type codecTestUser struct {
+	Name string
+	Age  int
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	codecs := map[string]Codec{
+		"msgpack": NewMsgpackCodec(),
+		"json":    NewJSONCodec(),
+		"gob":     NewGobCodec(),
+	}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := c.Encode(codecTestUser{Name: "alice", Age: 30})
+			require.NoError(t, err)
+
+			var u codecTestUser
+			require.NoError(t, c.Decode(data, &u))
+			require.Equal(t, codecTestUser{Name: "alice", Age: 30}, u)
+		})
+	}
+}
+
+func TestEmbeddedClient_WithCodec(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient(WithCodec(NewJSONCodec())).NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := db2.NewEmbeddedClient(WithCodec(NewJSONCodec())).NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "user-1", codecTestUser{Name: "alice", Age: 30})
+	require.NoError(t, err)
+	_, err = dm.Put(ctx, "greeting", "hello")
+	require.NoError(t, err)
+
+	gr, err := dm2.Get(ctx, "user-1")
+	require.NoError(t, err)
+	var u codecTestUser
+	require.NoError(t, gr.Scan(&u))
+	require.Equal(t, codecTestUser{Name: "alice", Age: 30}, u)
+
+	// The helpers decode through the codec too.
+	gr, err = dm2.Get(ctx, "greeting")
+	require.NoError(t, err)
+	greeting, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "hello", greeting)
+
+	// The value is stored as it's encoded by the codec.
+	raw, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	gr, err = raw.Get(ctx, "greeting")
+	require.NoError(t, err)
+	greeting, err = gr.String()
+	require.NoError(t, err)
+	require.Equal(t, `"hello"`, greeting)
+}
@@ -0,0 +1,57 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (db *Olric) compactCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParseCompact(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	moved, err := db.dmap.Compact(db.ctx)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	db.log.V(2).Printf("[INFO] Compaction has moved %d entries", moved)
+	conn.WriteInt(moved)
+}
+
+// compact runs the compaction on every cluster member and returns the total
+// number of moved entries.
+func (db *Olric) compact(ctx context.Context) (int, error) {
+	var total int
+	for _, member := range db.rt.Discovery().GetMembers() {
+		cmd := protocol.NewCompact().Command(ctx)
+		rc := db.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return total, processProtocolError(err)
+		}
+		moved, err := cmd.Result()
+		if err != nil {
+			return total, processProtocolError(err)
+		}
+		total += int(moved)
+	}
+	return total, nil
+}
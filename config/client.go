@@ -33,6 +33,10 @@ const (
 	DefaultMinRetryBackoff = 8 * time.Millisecond
 	DefaultMaxRetryBackoff = 512 * time.Millisecond
 	DefaultMaxRetries      = 3
+
+	DefaultMaxAdaptiveTimeout = 30 * time.Second
+
+	DefaultHealthCheckFailureThreshold = 3
 )
 
 // Client denotes configuration for TCP clients in Olric and the official Golang client.
@@ -108,6 +112,43 @@ type Client struct {
 
 	// Limiter interface used to implemented circuit breaker or rate limiter.
 	Limiter redis.Limiter
+
+	// PreDial establishes the connections to the cluster members in advance,
+	// at startup and when a member joins the cluster. MinIdleConns connections
+	// are opened to every member, at least one. So the first requests after a
+	// deployment don't pay the connection-establishment latency.
+	// Default is false.
+	PreDial bool
+
+	// HealthCheckInterval is the interval between the health-check pings that
+	// are sent to every cluster member. The connection that fails the ping is
+	// dropped, and the pool of a member is closed after
+	// HealthCheckFailureThreshold consecutive failures. It's created again on
+	// demand. The pool statistics are exposed in stats.Stats.ClientPools. Zero
+	// disables health checks.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckFailureThreshold is the number of consecutive failed
+	// health-check pings after which the pool of a member is closed.
+	// Default is 3.
+	HealthCheckFailureThreshold int
+
+	// AdaptiveTimeout derives the read and write timeouts of every cluster
+	// member from its round-trip time (RTT) estimate, instead of using
+	// ReadTimeout and WriteTimeout for all of them. The timeout of a member is
+	// SRTT + 4 * RTTVAR, the smoothed RTT and its variation, clamped to
+	// MinAdaptiveTimeout and MaxAdaptiveTimeout. It's doubled after every
+	// timeout until the member replies again. The estimates are exposed in
+	// stats.Stats.ClientPools. Default is false.
+	AdaptiveTimeout bool
+
+	// MinAdaptiveTimeout is the floor of the adaptive timeouts.
+	// Default is ReadTimeout.
+	MinAdaptiveTimeout time.Duration
+
+	// MaxAdaptiveTimeout is the ceiling of the adaptive timeouts.
+	// Default is 30 seconds.
+	MaxAdaptiveTimeout time.Duration
 }
 
 // NewClient returns a new configuration object for clients.
@@ -158,9 +199,18 @@ func (c *Client) Sanitize() error {
 	if c.IdleTimeout == 0 {
 		c.IdleTimeout = DefaultIdleTimeout
 	}
+	if c.MinAdaptiveTimeout == 0 {
+		c.MinAdaptiveTimeout = c.ReadTimeout
+	}
+	if c.MaxAdaptiveTimeout == 0 {
+		c.MaxAdaptiveTimeout = DefaultMaxAdaptiveTimeout
+	}
 	if c.IdleCheckFrequency == 0 {
 		c.IdleCheckFrequency = time.Minute
 	}
+	if c.HealthCheckFailureThreshold == 0 {
+		c.HealthCheckFailureThreshold = DefaultHealthCheckFailureThreshold
+	}
 
 	if c.MaxRetries == -1 {
 		c.MaxRetries = 0
@@ -184,7 +234,21 @@ func (c *Client) Sanitize() error {
 }
 
 // Validate finds errors in the current configuration.
-func (c *Client) Validate() error { return nil }
+func (c *Client) Validate() error {
+	if c.HealthCheckInterval < 0 {
+		return fmt.Errorf("cannot specify HealthCheckInterval less than zero")
+	}
+	if c.HealthCheckFailureThreshold < 0 {
+		return fmt.Errorf("cannot specify HealthCheckFailureThreshold less than zero")
+	}
+	if c.MinAdaptiveTimeout < 0 {
+		return fmt.Errorf("cannot specify MinAdaptiveTimeout less than zero")
+	}
+	if c.MaxAdaptiveTimeout < c.MinAdaptiveTimeout {
+		return fmt.Errorf("MaxAdaptiveTimeout cannot be less than MinAdaptiveTimeout")
+	}
+	return nil
+}
 
 func (c *Client) RedisOptions() *redis.Options {
 	return &redis.Options{
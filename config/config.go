@@ -15,6 +15,7 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -23,6 +24,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/buraksezer/olric/hasher"
 	"github.com/hashicorp/memberlist"
 )
@@ -49,6 +51,17 @@ const (
 	AsyncReplicationMode = 1
 )
 
+const (
+	// ReadOnlyQuorumLossMode makes a member reject the writes with
+	// ErrClusterQuorum if it cannot see MemberCountQuorum members. It still
+	// serves the reads. The default mode is ReadOnlyQuorumLossMode.
+	ReadOnlyQuorumLossMode = 0
+
+	// UnavailableQuorumLossMode makes a member reject the reads and the writes
+	// with ErrClusterQuorum if it cannot see MemberCountQuorum members.
+	UnavailableQuorumLossMode = 1
+)
+
 const (
 	LogLevelDebug = "DEBUG"
 	LogLevelWarn  = "WARN"
@@ -138,6 +151,26 @@ const (
 	// DefaultTriggerBalancerInterval is interval between two sequential call of balancer worker.
 	DefaultTriggerBalancerInterval = 15 * time.Second
 
+	// DefaultMaxConcurrentPartitionTransfers is the default number of partitions
+	// that the balancer moves at the same time.
+	DefaultMaxConcurrentPartitionTransfers = 1
+
+	// DefaultOwnershipHistorySize is the default number of partition ownership
+	// changes that the cluster coordinator keeps.
+	DefaultOwnershipHistorySize = 1000
+
+	// DefaultSlowLogMaxLen is the default number of the slow commands that a
+	// member keeps.
+	DefaultSlowLogMaxLen = 128
+
+	// DefaultExpirationScanInterval is the default value of interval between
+	// two sequential scans of an eviction worker. It's 100ms by default.
+	DefaultExpirationScanInterval = 100 * time.Millisecond
+
+	// DefaultExpirationSampleSize is the default number of keys sampled per
+	// round by an eviction worker.
+	DefaultExpirationSampleSize = 20
+
 	// DefaultCheckEmptyFragmentsInterval is the default value of interval between
 	// two sequential call of empty fragment cleaner. It's one minute by default.
 	DefaultCheckEmptyFragmentsInterval = time.Minute
@@ -147,6 +180,35 @@ const (
 	// its work is done. It's 10 minutes by default.
 	DefaultTriggerCompactionInterval = 10 * time.Minute
 
+	// DefaultMaxClockDrift is the default value of MaxClockDrift.
+	DefaultMaxClockDrift = time.Minute
+
+	// DefaultRetentionInterval is the default value of interval between two
+	// sequential runs of the retention policies. It's one minute by default.
+	DefaultRetentionInterval = time.Minute
+
+	// DefaultWriteBehindDelay is the default value of interval between two
+	// sequential flushes of the write-behind queue of a DMap. It's one second
+	// by default.
+	DefaultWriteBehindDelay = time.Second
+
+	// DefaultWriteBehindBatchSize is the default maximum number of the writes
+	// that are passed to a Writer in a single call. It's 100 by default.
+	DefaultWriteBehindBatchSize = 100
+
+	// DefaultLatencySLOObjective is the default target ratio of the operations
+	// that complete within the latency SLO of a DMap.
+	DefaultLatencySLOObjective = 0.99
+
+	// DefaultLatencySLOWindow is the default rolling window that the latency
+	// SLO attainment of a DMap is computed over.
+	DefaultLatencySLOWindow = 5 * time.Minute
+
+	// MinLatencySLOWindow is the minimum rolling window of the latency SLO of
+	// a DMap. The window is divided into slots, a shorter one cannot be
+	// divided evenly.
+	MinLatencySLOWindow = time.Second
+
 	// DefaultLeaveTimeout is the default value of maximum amount of time before
 	DefaultLeaveTimeout = 5 * time.Second
 
@@ -161,8 +223,19 @@ const (
 	// order to take the connection open, the option will prevent unexpected
 	// connection closed events.
 	DefaultKeepAlivePeriod = 300 * time.Second
+
+	// MaxMemberTagsSize is the maximum total size of the keys and values of
+	// MemberTags in bytes. The tags are gossiped in the metadata of the member,
+	// which is limited to 512 bytes by memberlist.
+	MaxMemberTagsSize = 256
 )
 
+// AddressResolver returns the addresses of the cluster members, in host:port
+// form. It's called again for every join attempt.
+type AddressResolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
 // Config is the configuration to create a Olric instance.
 type Config struct {
 	// Interface denotes a binding interface. It can be used instead of BindAddr
@@ -214,10 +287,129 @@ type Config struct {
 	// TriggerBalancerInterval is interval between two sequential call of balancer worker.
 	TriggerBalancerInterval time.Duration
 
+	// MaxConcurrentPartitionTransfers is the maximum number of partitions that
+	// the balancer moves to their new owners at the same time. It's 1 by default.
+	MaxConcurrentPartitionTransfers int
+
+	// PartitionTransferBandwidth limits the outgoing data rate of the balancer,
+	// in bytes per second. Zero means no limit.
+	PartitionTransferBandwidth int64
+
+	// PartitionTransferEntryRate limits the number of entries that the balancer
+	// sends per second. Zero means no limit.
+	PartitionTransferEntryRate int64
+
+	// ImportBandwidth and ImportEntryRate limit the rate of the data that a
+	// member imports, in bytes and entries per second: the fragments received
+	// from the other members while the partitions are migrated or their backups
+	// are re-created, and the snapshots restored by Undo. MaxConcurrentImports
+	// is the maximum number of fragments that are imported at the same time.
+	// The fragments of the other members are rejected above it and sent again
+	// by their balancers, Undo waits for its turn. Zero means no limit for all
+	// of them.
+	ImportBandwidth      int64
+	ImportEntryRate      int64
+	MaxConcurrentImports int
+
+	// BalancerWindowStart and BalancerWindowEnd define a daily window, as offsets
+	// from midnight in local time, in which the balancer is allowed to move
+	// partitions. The window may wrap around midnight, e.g. 22h to 6h. If both of
+	// them are zero, the balancer runs at any time of the day.
+	BalancerWindowStart time.Duration
+	BalancerWindowEnd   time.Duration
+
+	// DeadMemberTimeout is the time to wait for a member that left the cluster.
+	// When it's exceeded, the coordinator forgets the member and the partition
+	// owners re-create the lost backups on the remaining members. Zero disables
+	// the cleanup.
+	DeadMemberTimeout time.Duration
+
+	// ShutdownDrainTimeout is the maximum time spent on Shutdown to hand off
+	// the primary partitions of the member and to finish the in-flight
+	// requests. The new connections and requests are rejected with a
+	// retryable error in the meantime. Zero disables draining.
+	ShutdownDrainTimeout time.Duration
+
+	// AnalyticsReplica makes this member an analytics replica. It doesn't own
+	// any partition, the partition owners replicate the writes of all DMaps to
+	// it asynchronously, and DMap.Query and DMap.Scan run on the analytics
+	// replicas instead of the partition owners if there is any. So the
+	// expensive queries don't slow down the reads and writes. The replicas are
+	// eventually consistent, a query may miss the latest writes. An analytics
+	// replica cannot form a cluster alone, it serves the requests after a
+	// member that owns the partitions joins the cluster.
+	AnalyticsReplica bool
+
+	// MemberTags is the arbitrary key/value metadata of this member, like its
+	// role, version or zone. The tags are gossiped to the cluster with the
+	// member and returned by the routing table, the stats and the list of the
+	// members, so the tooling can identify the members. Their total size
+	// cannot exceed MaxMemberTagsSize bytes.
+	MemberTags map[string]string
+
+	// OwnershipHistorySize is the number of partition ownership changes that
+	// the cluster coordinator keeps to debug rebalancing decisions. The oldest
+	// changes are dropped first. Default is 1000.
+	OwnershipHistorySize int
+
+	// SlowLogThreshold is the minimum duration of a command to be recorded in
+	// the slow log of the member. Zero disables the slow log.
+	SlowLogThreshold time.Duration
+
+	// SlowLogMaxLen is the number of the slow commands that the member keeps.
+	// The oldest ones are dropped first. Default is 128.
+	SlowLogMaxLen int
+
+	// WriteCoalesceDelay is the maximum duration that a response waits for
+	// the next responses on the same connection, so they are sent to network
+	// with a single write. It trades latency for fewer write syscalls under
+	// high concurrency. The responses only wait if more commands are waiting
+	// to be read on the connection. Zero disables coalescing.
+	WriteCoalesceDelay time.Duration
+
+	// CommandTimeout is the maximum duration of a command on the server side.
+	// It bounds the commands of the clients that don't set a deadline. If the
+	// client sets an earlier deadline, it's used instead. The commands that
+	// are still queued at the deadline are not executed, and the ones that wait
+	// or call the other members are aborted. Both fail with ErrDeadlineExceeded.
+	// It doesn't apply to the blocking commands, dm.lock and queue.bpop, they
+	// wait as long as the client asks for. Zero disables it.
+	CommandTimeout time.Duration
+
+	// CommandTimeouts overrides CommandTimeout for the given commands, e.g.
+	// "dm.query". Zero disables the timeout of the command.
+	CommandTimeouts map[string]time.Duration
+
+	// AdminAddr is the address of the HTTP admin API, in host:port form. It
+	// serves the health checks, the routing table, the stats and the
+	// destructive cluster operations. Empty disables the admin API.
+	AdminAddr string
+
+	// AdminToken is the bearer token of the admin API. If it's set, the
+	// requests except the health checks have to send it in the Authorization
+	// header. If it's empty, the read-only endpoints are served without
+	// authentication and the destructive operations are disabled.
+	AdminToken string
+
+	// MaxResponseSize is the maximum size of a single response of Scan and
+	// Query in bytes. A scan batch is cut when it's reached, Query returns
+	// the result in pages. Zero means no limit.
+	MaxResponseSize int
+
+	// Namespaces holds the quotas and the access rules of the tenants, by
+	// namespace name. A namespace doesn't have to be configured to be used,
+	// it isolates the DMap names only in that case.
+	Namespaces map[string]Namespace
+
 	// The list of host:port which are used by memberlist for discovery.
-	// Don't confuse it with Name.
+	// Don't confuse it with Name. An entry in dns+srv://name form is
+	// resolved with a DNS SRV lookup for every join attempt.
 	Peers []string
 
+	// PeerResolver returns more peers for every join attempt, in addition
+	// to Peers. It keeps the seeds fresh when the members are replaced.
+	PeerResolver AddressResolver
+
 	// PartitionCount is 271, by default.
 	PartitionCount uint64
 
@@ -233,9 +425,28 @@ type Config struct {
 	// Minimum number of members to form a cluster and run any query on the cluster.
 	MemberCountQuorum int32
 
+	// QuorumLossMode determines how a member behaves when it cannot see
+	// MemberCountQuorum members, e.g. it's on the minority side of a network
+	// partition. Default value is ReadOnlyQuorumLossMode.
+	QuorumLossMode int
+
+	// BootstrapQuorum is the minimum number of members that a member has to
+	// see before it serves writes for the first time. It prevents a freshly
+	// restarted member from accepting writes on an empty dataset while the
+	// rest of the cluster is still unreachable. Unlike MemberCountQuorum, it's
+	// only checked until it's reached once. Zero disables it.
+	BootstrapQuorum int32
+
 	// Switch to control read-repair algorithm which helps to reduce entropy.
 	ReadRepair bool
 
+	// MaxClockDrift is the maximum duration that a timestamp received from
+	// another member can be ahead of the wall clock of this member. The
+	// writes and the replicas with the timestamps further ahead are rejected,
+	// so a member with a broken clock cannot push the clocks of the others
+	// into the future. Default is 1 minute, -1 disables the check.
+	MaxClockDrift time.Duration
+
 	// Default value is SyncReplicationMode.
 	ReplicationMode int
 
@@ -249,11 +460,17 @@ type Config struct {
 	// * node-left-event
 	// * fragment-migration-event
 	// * fragment-received-event
+	// * routing-table-updated-event
 	//
 	// If you want to receive these events, set true to EnableClusterEventsChannel and subscribe to
 	// cluster.events channel. Default is false.
 	EnableClusterEventsChannel bool
 
+	// Hasher is used to distribute the keys among the partitions. All members
+	// of a cluster must use the same hash function, a member with a different
+	// Hasher is rejected while joining the cluster. The clients embedded into
+	// a member use its Hasher.
+	//
 	// Default hasher is github.com/cespare/xxhash/v2
 	Hasher hasher.Hasher
 
@@ -319,6 +536,13 @@ type Config struct {
 	// You have to use NewMemberlistConfig to create a new one.
 	// Then, you may need to modify it to tune for your environment.
 	MemberlistConfig *memberlist.Config
+
+	// MetricsSink receives the metrics of memberlist. memberlist reports to
+	// the process-wide go-metrics instance, Olric installs a sink there to
+	// collect the gossip statistics and passes the metrics to the sinks of
+	// the members. Set it instead of calling metrics.NewGlobal, that would
+	// replace the sink of Olric.
+	MetricsSink metrics.MetricSink
 }
 
 // Validate finds errors in the current configuration.
@@ -349,6 +573,14 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cannot specify MemberCountQuorum smaller than MinimumMemberCountQuorum")
 	}
 
+	if c.QuorumLossMode != ReadOnlyQuorumLossMode && c.QuorumLossMode != UnavailableQuorumLossMode {
+		return fmt.Errorf("invalid QuorumLossMode: %d", c.QuorumLossMode)
+	}
+
+	if c.BootstrapQuorum < 0 {
+		return fmt.Errorf("cannot specify BootstrapQuorum less than zero")
+	}
+
 	if c.BindAddr == "" {
 		return fmt.Errorf("bindAddr cannot be empty")
 	}
@@ -380,6 +612,97 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid LogLevel: %s", c.LogLevel)
 	}
 
+	if c.PartitionTransferBandwidth < 0 {
+		return fmt.Errorf("cannot specify PartitionTransferBandwidth less than zero")
+	}
+
+	if c.PartitionTransferEntryRate < 0 {
+		return fmt.Errorf("cannot specify PartitionTransferEntryRate less than zero")
+	}
+
+	if c.ImportBandwidth < 0 {
+		return fmt.Errorf("cannot specify ImportBandwidth less than zero")
+	}
+
+	if c.ImportEntryRate < 0 {
+		return fmt.Errorf("cannot specify ImportEntryRate less than zero")
+	}
+
+	if c.MaxConcurrentImports < 0 {
+		return fmt.Errorf("cannot specify MaxConcurrentImports less than zero")
+	}
+
+	if c.DeadMemberTimeout < 0 {
+		return fmt.Errorf("cannot specify DeadMemberTimeout less than zero")
+	}
+
+	if c.ShutdownDrainTimeout < 0 {
+		return fmt.Errorf("cannot specify ShutdownDrainTimeout less than zero")
+	}
+
+	if c.SlowLogThreshold < 0 {
+		return fmt.Errorf("cannot specify SlowLogThreshold less than zero")
+	}
+	if c.MaxClockDrift < 0 {
+		return fmt.Errorf("cannot specify MaxClockDrift less than zero")
+	}
+
+	if c.SlowLogMaxLen < 0 {
+		return fmt.Errorf("cannot specify SlowLogMaxLen less than zero")
+	}
+
+	if c.WriteCoalesceDelay < 0 {
+		return fmt.Errorf("cannot specify WriteCoalesceDelay less than zero")
+	}
+
+	if c.CommandTimeout < 0 {
+		return fmt.Errorf("cannot specify CommandTimeout less than zero")
+	}
+
+	for command, timeout := range c.CommandTimeouts {
+		if timeout < 0 {
+			return fmt.Errorf("cannot specify CommandTimeouts less than zero: %s", command)
+		}
+	}
+
+	if c.AdminAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			return fmt.Errorf("invalid AdminAddr: %w", err)
+		}
+	}
+
+	if c.MaxResponseSize < 0 {
+		return fmt.Errorf("cannot specify MaxResponseSize less than zero")
+	}
+
+	var tagsSize int
+	for key, value := range c.MemberTags {
+		if key == "" {
+			return fmt.Errorf("MemberTags cannot contain an empty key")
+		}
+		tagsSize += len(key) + len(value)
+	}
+	if tagsSize > MaxMemberTagsSize {
+		return fmt.Errorf("MemberTags cannot exceed %d bytes", MaxMemberTagsSize)
+	}
+
+	for name, ns := range c.Namespaces {
+		if err := ValidateNamespaceName(name); err != nil {
+			return err
+		}
+		if err := ns.Validate(); err != nil {
+			return fmt.Errorf("failed to validate namespace: %s: %w", name, err)
+		}
+	}
+
+	day := 24 * time.Hour
+	if c.BalancerWindowStart < 0 || c.BalancerWindowStart >= day {
+		return fmt.Errorf("BalancerWindowStart has to be between 0 and 24h")
+	}
+	if c.BalancerWindowEnd < 0 || c.BalancerWindowEnd >= day {
+		return fmt.Errorf("BalancerWindowEnd has to be between 0 and 24h")
+	}
+
 	return nil
 }
 
@@ -469,6 +792,25 @@ func (c *Config) Sanitize() error {
 		c.TriggerBalancerInterval = DefaultTriggerBalancerInterval
 	}
 
+	if c.MaxConcurrentPartitionTransfers <= 0 {
+		c.MaxConcurrentPartitionTransfers = DefaultMaxConcurrentPartitionTransfers
+	}
+
+	if c.OwnershipHistorySize <= 0 {
+		c.OwnershipHistorySize = DefaultOwnershipHistorySize
+	}
+
+	if c.SlowLogMaxLen == 0 {
+		c.SlowLogMaxLen = DefaultSlowLogMaxLen
+	}
+
+	switch c.MaxClockDrift {
+	case -1:
+		c.MaxClockDrift = 0
+	case 0:
+		c.MaxClockDrift = DefaultMaxClockDrift
+	}
+
 	if c.KeepAlivePeriod == 0 {
 		c.KeepAlivePeriod = DefaultKeepAlivePeriod
 	}
@@ -522,6 +864,7 @@ func New(env string) *Config {
 		BindAddr:          "0.0.0.0",
 		BindPort:          DefaultPort,
 		ReadRepair:        false,
+		MaxClockDrift:     DefaultMaxClockDrift,
 		ReplicaCount:      1,
 		WriteQuorum:       1,
 		ReadQuorum:        1,
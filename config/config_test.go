@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,6 +55,9 @@ client:
   poolTimeout: 4s
   idleTimeout: 6m
   idleCheckFrequency: 8m
+  adaptiveTimeout: true
+  minAdaptiveTimeout: 1s
+  maxAdaptiveTimeout: 10s
 
 logging:
   verbosity: 6
@@ -168,6 +172,9 @@ func TestConfig(t *testing.T) {
 	c.Client.PoolTimeout = 4 * time.Second
 	c.Client.IdleTimeout = 6 * time.Minute
 	c.Client.IdleCheckFrequency = 8 * time.Minute
+	c.Client.AdaptiveTimeout = true
+	c.Client.MinAdaptiveTimeout = time.Second
+	c.Client.MaxAdaptiveTimeout = 10 * time.Second
 
 	c.LogVerbosity = 6
 	c.LogLevel = "DEBUG"
@@ -230,6 +237,53 @@ func TestConfig(t *testing.T) {
 	require.Equal(t, c, lc)
 }
 
+func TestConfig_Client_AdaptiveTimeout(t *testing.T) {
+	c := NewClient()
+	require.Equal(t, c.ReadTimeout, c.MinAdaptiveTimeout)
+	require.Equal(t, DefaultMaxAdaptiveTimeout, c.MaxAdaptiveTimeout)
+	require.NoError(t, c.Validate())
+
+	c.MaxAdaptiveTimeout = time.Second
+	require.Error(t, c.Validate())
+}
+
+func TestConfig_Validate_ImportThrottles(t *testing.T) {
+	c := New("local")
+	c.MaxConcurrentImports = 2
+	c.ImportBandwidth = 1 << 20
+	require.NoError(t, c.Validate())
+
+	c.ImportEntryRate = -1
+	require.Error(t, c.Validate())
+}
+
+func TestConfig_Validate_MemberTags(t *testing.T) {
+	c := New("local")
+	c.MemberTags = map[string]string{"role": "cache", "version": "v0.5.0"}
+	require.NoError(t, c.Validate())
+
+	c.MemberTags[""] = "empty"
+	require.Error(t, c.Validate())
+
+	c.MemberTags = map[string]string{"description": strings.Repeat("a", MaxMemberTagsSize)}
+	require.Error(t, c.Validate())
+}
+
+func TestConfig_Validate_Namespaces(t *testing.T) {
+	c := New("local")
+	c.Namespaces = map[string]Namespace{"team-a": {MaxKeys: 1000, MaxInuse: 1 << 20}}
+	require.NoError(t, c.Validate())
+
+	c.Namespaces["team-b"] = Namespace{MaxKeys: -1}
+	require.Error(t, c.Validate())
+
+	c.Namespaces = map[string]Namespace{"team/a": {}}
+	require.Error(t, c.Validate())
+
+	c.Namespaces = map[string]Namespace{"team-a": {ACL: map[string]NamespaceAccess{"secret": "admin"}}}
+	require.Error(t, c.Validate())
+}
+
 func TestConfig_Initialize(t *testing.T) {
 	c := &Config{}
 	require.NoError(t, c.Sanitize())
@@ -15,16 +15,159 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"time"
+
+	"github.com/buraksezer/olric/internal/schema"
+	"github.com/buraksezer/olric/pkg/codec"
 )
 
 // EvictionPolicy denotes eviction policy. Currently: LRU or NONE.
 type EvictionPolicy string
 
+// QuotaPolicy denotes what happens when a write exceeds MaxKeys or MaxInuse
+// of a DMap. Currently: QuotaEvict or QuotaReject.
+type QuotaPolicy string
+
+const (
+	// QuotaEvict makes room for the new entries with EvictionPolicy. The
+	// quotas are not enforced if EvictionPolicy is NONE.
+	QuotaEvict QuotaPolicy = "evict"
+
+	// QuotaReject rejects the writes of the new keys once MaxKeys is
+	// exceeded, and all writes once MaxInuse is exceeded. The quotas are
+	// checked against the primary copies on every partition owner.
+	QuotaReject QuotaPolicy = "reject"
+)
+
 // Function defines the signature of a custom function.
 type Function func(key string, currentState, arg []byte) (newState []byte, result []byte, err error)
 
+// Loader loads the value of a key that is not in the DMap, see DMap.Loader.
+// It returns a nil value if the key doesn't exist in the source either. ttl
+// is the TTL of the loaded entry, zero means no expiry.
+type Loader func(ctx context.Context, dmap, key string) (value []byte, ttl time.Duration, err error)
+
+// WriteMode denotes how the writes of a DMap are propagated to its Writer.
+type WriteMode string
+
+const (
+	// WriteThrough propagates the writes synchronously. Put and Delete fail
+	// if the Writer returns an error, and the DMap is not modified. A write
+	// that fails after the Writer returns, e.g. on replication, is kept by
+	// the external store.
+	WriteThrough WriteMode = "write-through"
+
+	// WriteBehind queues the writes on the partition owners and propagates
+	// them in batches in the background. The failed batches are retried.
+	// The queues are kept in memory, the pending writes are lost if a member
+	// crashes, and the failed writes are not retried on Shutdown. The queue
+	// of a moved partition is flushed by its previous owner, so the writes
+	// of a key may reach the external store out of order while a partition
+	// is moved.
+	WriteBehind WriteMode = "write-behind"
+)
+
+// WriteOp is a write that is propagated to an external store, see Writer.
+type WriteOp struct {
+	// Key is the key of the entry.
+	Key string
+
+	// Value is the value of the entry. It's nil if Delete is true.
+	Value []byte
+
+	// Delete is true if the entry is deleted.
+	Delete bool
+}
+
+// Writer propagates the writes of a DMap to an external store, see DMap.Writer.
+// The writes of a key are passed in the order they are applied.
+type Writer func(ctx context.Context, dmap string, ops []WriteOp) error
+
+// Transformer is a stage of the value transformation pipeline of a DMap, see
+// DMap.Transformers. A stage may compress, encrypt, validate or redact the
+// values.
+type Transformer interface {
+	// Transform is called by the partition owner before a value is stored.
+	// Return a non-nil error to reject the write.
+	Transform(dmap, key string, value []byte) ([]byte, error)
+
+	// Reverse reverts Transform before a value is returned. The stages that
+	// don't modify the value, or modify it irreversibly, return it as it is.
+	Reverse(dmap, key string, value []byte) ([]byte, error)
+}
+
+// ConflictEntry is a version of a key in a conflict, see ConflictResolver.
+type ConflictEntry struct {
+	// Key is the key of the entry.
+	Key string
+
+	// Value is the value of the entry.
+	Value []byte
+
+	// TTL is the expiry time of the entry in Unix milliseconds, zero means
+	// no expiry.
+	TTL int64
+
+	// Timestamp is the time of the last write in Unix nanoseconds. It is taken
+	// from the hybrid logical clock of the member that wrote the entry, so it
+	// is ordered correctly even if the wall clocks of the members are skewed.
+	Timestamp int64
+}
+
+// ConflictResolver decides the version of a key that is kept when two members
+// have different versions of it, e.g. after a network partition heals and
+// the partitions are merged, see DMap.ConflictResolver.
+type ConflictResolver interface {
+	// Resolve is called on the member that merges the versions. local is the
+	// version of the member, remote is the received one. The returned entry is
+	// stored. A merged value should get the later timestamp of the two, so it
+	// wins on the other members too. Return a non-nil error to fall back to
+	// LastWriteWins.
+	Resolve(dmap string, local, remote ConflictEntry) (ConflictEntry, error)
+}
+
+// ConflictResolverFunc is an adapter to allow the use of ordinary functions
+// as ConflictResolver.
+type ConflictResolverFunc func(dmap string, local, remote ConflictEntry) (ConflictEntry, error)
+
+// Resolve calls f(dmap, local, remote).
+func (f ConflictResolverFunc) Resolve(dmap string, local, remote ConflictEntry) (ConflictEntry, error) {
+	return f(dmap, local, remote)
+}
+
+// LastWriteWins keeps the version with the later timestamp, the local one if
+// the timestamps are equal. It's the default ConflictResolver.
+var LastWriteWins ConflictResolver = ConflictResolverFunc(func(_ string, local, remote ConflictEntry) (ConflictEntry, error) {
+	if remote.Timestamp > local.Timestamp {
+		return remote, nil
+	}
+	return local, nil
+})
+
+// RateLimit limits the operations on the keys of a DMap that match KeyPattern,
+// see DMap.RateLimits.
+type RateLimit struct {
+	// KeyPattern is a regular expression. The rule applies to the keys that
+	// match it, an empty pattern matches all the keys.
+	KeyPattern string
+
+	// ReadsPerSecond is the maximum number of reads per second on a member.
+	// Zero means no limit.
+	ReadsPerSecond int64
+
+	// WritesPerSecond is the maximum number of writes per second on a member.
+	// Zero means no limit.
+	WritesPerSecond int64
+
+	// PerClient gives every client a separate budget, instead of sharing it
+	// between all the clients. The clients are identified by their addresses,
+	// every EmbeddedClient is a separate client.
+	PerClient bool
+}
+
 // Important note on DMap and DMaps structs:
 // Golang does not provide the typical notion of inheritance.
 // because of that I preferred to define the types explicitly.
@@ -68,8 +211,165 @@ type DMap struct {
 	// Set as LRU to enable LRU eviction policy.
 	EvictionPolicy EvictionPolicy
 
+	// QuotaPolicy determines how MaxKeys and MaxInuse are enforced. It
+	// overrides DMaps.QuotaPolicy if it's not empty.
+	QuotaPolicy QuotaPolicy
+
 	// Function is useful to set custom functions per DMap instance.
 	Functions map[string]Function
+
+	// ChangeLogSize is the number of mutations kept in the change log of every
+	// partition of this DMap. Watch consumers read the mutation stream from
+	// there. Zero disables change data capture.
+	ChangeLogSize int
+
+	// TombstoneRetention is the period that the tombstones of the deleted
+	// keys are kept. It overrides DMaps.TombstoneRetention if it's not zero.
+	TombstoneRetention time.Duration
+
+	// StrictExpiry enables the strict expiry mode for this DMap, see
+	// DMaps.StrictExpiry.
+	StrictExpiry bool
+
+	// HashTags enables the Redis-style hash tags for the keys of this DMap.
+	// The keys with the same tag between curly braces, e.g. "user:1" in
+	// "{user:1}.profile", are stored on the same partition. It has to be the
+	// same on all members and cannot be changed at runtime.
+	HashTags bool
+
+	// KeyPattern is a regular expression that every key written to this DMap
+	// has to match. Writes with non-matching keys are rejected.
+	KeyPattern string
+
+	// KeyValidator is called for every key written to this DMap, after KeyPattern
+	// is checked. Return a non-nil error to reject the key.
+	KeyValidator func(key string) error
+
+	// RateLimits throttles the reads and the writes of this DMap, to protect
+	// the cluster from a single runaway tenant. The limits are enforced by the
+	// member that receives the operation, before it's forwarded to the
+	// partition owner, so a cluster serves up to the limit times the number of
+	// members. The first rule whose KeyPattern matches the key applies, and the
+	// keys that don't match any rule are not limited. The rejected operations
+	// return ErrRateLimited. Get, GetEntry, the reads from the backups and the
+	// nearest owner and the hash reads are counted as reads; Put, Expire,
+	// Delete, Execute, the functions and the hash writes are counted as
+	// writes.
+	RateLimits []RateLimit
+
+	// LatencySLO is the latency target of the operations on this DMap. The
+	// partition owners count the operations that complete within the target
+	// as good and the others as bad, the counted operations are the same as
+	// RateLimits. Zero disables SLO tracking.
+	LatencySLO time.Duration
+
+	// LatencySLOObjective is the target ratio of the good operations, like
+	// 0.999. An SLOViolationEvent is published to the local events when the
+	// attainment falls below it. It's DefaultLatencySLOObjective by default.
+	LatencySLOObjective float64
+
+	// LatencySLOWindow is the rolling window that the attainment is computed
+	// over. It's DefaultLatencySLOWindow by default and it cannot be less
+	// than MinLatencySLOWindow.
+	LatencySLOWindow time.Duration
+
+	// Codec is the name of a registered codec. It overrides DMaps.Codec.
+	Codec string
+
+	// CodecThreshold is the minimum size of a value in bytes to be encoded by
+	// Codec. It overrides DMaps.CodecThreshold if it's not zero.
+	CodecThreshold int
+
+	// MaxValueSize is the maximum size of a value in bytes. It overrides
+	// DMaps.MaxValueSize if it's not zero.
+	MaxValueSize int
+
+	// ChunkSize is the size of the chunks of the large values. It overrides
+	// DMaps.ChunkSize if it's not zero, see DMaps.ChunkSize.
+	ChunkSize int
+
+	// RetentionMaxAge is the retention period of the entries. The entries that
+	// are written before this period are deleted by the retention janitor,
+	// regardless of their TTL. It overrides DMaps.RetentionMaxAge if it's not
+	// zero.
+	RetentionMaxAge time.Duration
+
+	// RetentionMaxEntriesPerMember caps the number of entries of this DMap in
+	// the partitions owned by a member. Every member enforces it separately,
+	// so the cluster keeps up to the number of members times this value. The
+	// oldest entries are deleted first when the cap is exceeded. Unlike
+	// MaxKeys, writes are never rejected. It overrides
+	// DMaps.RetentionMaxEntriesPerMember if it's not zero.
+	RetentionMaxEntriesPerMember int
+
+	// RetentionDryRun reports the entries that the retention policies would
+	// delete without deleting them. DMaps.RetentionDryRun applies too.
+	RetentionDryRun bool
+
+	// AccessSampleRate is the fraction of the reads that are counted in the
+	// access statistics of the entries, between 0 and 1. The statistics are
+	// kept on the partition owners and they are used by Hottest and Coldest.
+	// Zero disables it.
+	AccessSampleRate float64
+
+	// HotKeysWindow is the measurement window of the hot key tracker. The
+	// partition owners count the reads and the writes of the keys with a
+	// count-min sketch, and HotKeys reports the most frequently accessed
+	// keys with their approximate QPS over the last window. Zero disables it.
+	HotKeysWindow time.Duration
+
+	// ValueSchema is a JSON Schema document that every value written to this
+	// DMap has to match. The values are validated on the partition owners, so
+	// they have to be JSON or MessagePack encoded. Only a subset of JSON Schema
+	// is supported: type, enum, properties, required, additionalProperties,
+	// items, minimum, maximum, minLength, maxLength, minItems and maxItems.
+	ValueSchema string
+
+	// Loader is called by the partition owner on a cache miss. The loaded
+	// value is stored in the DMap and returned to the caller, so the DMap works
+	// as a read-through cache. The concurrent misses of a key on a member are
+	// collapsed into a single call.
+	Loader Loader
+
+	// RefreshAhead reloads an entry in the background if it's read when it's
+	// about to expire in the given period. The stale value is returned in
+	// the meantime. It requires Loader. Zero disables it.
+	RefreshAhead time.Duration
+
+	// Writer is called by the partition owner for the Put and Delete calls,
+	// so the DMap works as a write-through or write-behind cache depending
+	// on WriteMode. Expire calls are not propagated.
+	Writer Writer
+
+	// WriteMode is WriteThrough or WriteBehind. It's WriteThrough by default.
+	WriteMode WriteMode
+
+	// WriteBehindDelay is the interval between two sequential flushes of the
+	// write-behind queue. The writes of a key in the same interval are
+	// coalesced, only the last one is propagated. It's
+	// DefaultWriteBehindDelay by default.
+	WriteBehindDelay time.Duration
+
+	// WriteBehindBatchSize is the maximum number of the writes that are passed
+	// to the Writer in a single call. It's DefaultWriteBehindBatchSize by
+	// default.
+	WriteBehindBatchSize int
+
+	// Transformers is the ordered value transformation pipeline of this DMap.
+	// The stages are applied in order before a value is stored, and reversed
+	// in the opposite order before it's returned, so the policies are
+	// enforced regardless of the clients. The pipeline is not recorded with
+	// the entries, every member has to use the same pipeline and changing it
+	// makes the stored values unreadable.
+	Transformers []Transformer
+
+	// ConflictResolver decides the version of a key that is kept when the
+	// members have different versions of it: the partitions are merged after
+	// a network partition heals, a read collects the versions of the owners,
+	// anti-entropy compares the backups and the analytics replicas receive the
+	// writes of the moved partitions. It's called with the values after the
+	// transformation pipeline is reverted. It's LastWriteWins by default.
+	ConflictResolver ConflictResolver
 }
 
 // Sanitize sets default values to empty configuration variables, if it's possible.
@@ -86,6 +386,15 @@ func (dm *DMap) Sanitize() error {
 	if dm.MaxKeys < 0 {
 		dm.MaxKeys = 0
 	}
+	if dm.ChangeLogSize < 0 {
+		dm.ChangeLogSize = 0
+	}
+	if dm.TombstoneRetention < 0 {
+		dm.TombstoneRetention = 0
+	}
+	if dm.RetentionMaxEntriesPerMember < 0 {
+		dm.RetentionMaxEntriesPerMember = 0
+	}
 
 	if dm.Engine == nil {
 		dm.Engine = NewEngine()
@@ -104,6 +413,103 @@ func (dm *DMap) Validate() error {
 		return fmt.Errorf("failed to validate storage engine configuration: %w", err)
 	}
 
+	if dm.KeyPattern != "" {
+		if _, err := regexp.Compile(dm.KeyPattern); err != nil {
+			return fmt.Errorf("invalid KeyPattern: %w", err)
+		}
+	}
+
+	if dm.Codec != "" {
+		if _, err := codec.GetByName(dm.Codec); err != nil {
+			return err
+		}
+	}
+
+	if dm.CodecThreshold < 0 {
+		return fmt.Errorf("CodecThreshold cannot be negative: %d", dm.CodecThreshold)
+	}
+
+	if dm.MaxValueSize < 0 || dm.ChunkSize < 0 {
+		return fmt.Errorf("MaxValueSize and ChunkSize cannot be negative")
+	}
+
+	if dm.RetentionMaxAge < 0 {
+		return fmt.Errorf("RetentionMaxAge cannot be negative: %s", dm.RetentionMaxAge)
+	}
+
+	if dm.AccessSampleRate < 0 || dm.AccessSampleRate > 1 {
+		return fmt.Errorf("AccessSampleRate has to be between 0 and 1: %v", dm.AccessSampleRate)
+	}
+
+	if dm.HotKeysWindow < 0 {
+		return fmt.Errorf("HotKeysWindow cannot be negative: %s", dm.HotKeysWindow)
+	}
+
+	if dm.RefreshAhead < 0 {
+		return fmt.Errorf("RefreshAhead cannot be negative: %s", dm.RefreshAhead)
+	}
+
+	if dm.RefreshAhead > 0 && dm.Loader == nil {
+		return fmt.Errorf("RefreshAhead requires a Loader")
+	}
+
+	if dm.ValueSchema != "" {
+		if _, err := schema.Compile(dm.ValueSchema); err != nil {
+			return fmt.Errorf("invalid ValueSchema: %w", err)
+		}
+	}
+
+	if err := validateQuotaPolicy(dm.QuotaPolicy); err != nil {
+		return err
+	}
+
+	if err := dm.validateWriter(); err != nil {
+		return err
+	}
+
+	if err := dm.validateTransformers(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateQuotaPolicy(p QuotaPolicy) error {
+	switch p {
+	case "", QuotaEvict, QuotaReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid QuotaPolicy: %s", p)
+	}
+}
+
+func (dm *DMap) validateTransformers() error {
+	for i, t := range dm.Transformers {
+		if t == nil {
+			return fmt.Errorf("Transformer cannot be nil: %d", i)
+		}
+	}
+	return nil
+}
+
+func (dm *DMap) validateWriter() error {
+	switch dm.WriteMode {
+	case "", WriteThrough, WriteBehind:
+	default:
+		return fmt.Errorf("invalid WriteMode: %s", dm.WriteMode)
+	}
+
+	if dm.WriteMode != "" && dm.Writer == nil {
+		return fmt.Errorf("WriteMode requires a Writer")
+	}
+
+	if dm.WriteBehindDelay < 0 {
+		return fmt.Errorf("WriteBehindDelay cannot be negative: %s", dm.WriteBehindDelay)
+	}
+
+	if dm.WriteBehindBatchSize < 0 {
+		return fmt.Errorf("WriteBehindBatchSize cannot be negative: %d", dm.WriteBehindBatchSize)
+	}
 	return nil
 }
 
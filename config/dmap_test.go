@@ -15,8 +15,10 @@
 package config
 
 import (
-	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfig_DMap(t *testing.T) {
@@ -34,3 +36,96 @@ func TestConfig_DMap(t *testing.T) {
 	require.Equal(t, EvictionPolicy("NONE"), d.EvictionPolicy)
 	require.NotNil(t, d.Engine)
 }
+
+func TestConfig_DMap_Retention(t *testing.T) {
+	d := &DMap{RetentionMaxEntriesPerMember: -1}
+	require.NoError(t, d.Sanitize())
+	require.Equal(t, 0, d.RetentionMaxEntriesPerMember)
+
+	d.RetentionMaxAge = -time.Second
+	require.Error(t, d.Validate())
+}
+
+func TestConfig_DMap_RateLimits(t *testing.T) {
+	dc := &DMaps{Custom: map[string]DMap{"mydmap": {
+		RateLimits: []RateLimit{{KeyPattern: "^user:", WritesPerSecond: 100}},
+	}}}
+	require.NoError(t, dc.Sanitize())
+	require.NoError(t, dc.Validate())
+
+	dc.Custom["mydmap"] = DMap{RateLimits: []RateLimit{{ReadsPerSecond: -1}}}
+	require.Error(t, dc.Validate())
+
+	dc.Custom["mydmap"] = DMap{RateLimits: []RateLimit{{KeyPattern: "(", ReadsPerSecond: 1}}}
+	require.Error(t, dc.Validate())
+}
+
+func TestConfig_DMap_LastWriteWins(t *testing.T) {
+	local := ConflictEntry{Key: "mykey", Value: []byte("local"), Timestamp: 2}
+	remote := ConflictEntry{Key: "mykey", Value: []byte("remote"), Timestamp: 1}
+
+	resolved, err := LastWriteWins.Resolve("mydmap", local, remote)
+	require.NoError(t, err)
+	require.Equal(t, local, resolved)
+
+	remote.Timestamp = 3
+	resolved, err = LastWriteWins.Resolve("mydmap", local, remote)
+	require.NoError(t, err)
+	require.Equal(t, remote, resolved)
+
+	// The local version wins a tie.
+	remote.Timestamp = 2
+	resolved, err = LastWriteWins.Resolve("mydmap", local, remote)
+	require.NoError(t, err)
+	require.Equal(t, local, resolved)
+}
+
+func TestConfig_DMap_QuotaPolicy(t *testing.T) {
+	dc := &DMaps{}
+	require.NoError(t, dc.Sanitize())
+	require.Equal(t, QuotaEvict, dc.QuotaPolicy)
+
+	dc.Custom["mydmap"] = DMap{MaxKeys: 10, QuotaPolicy: QuotaReject}
+	require.NoError(t, dc.Validate())
+
+	dc.Custom["mydmap"] = DMap{QuotaPolicy: "drop"}
+	require.Error(t, dc.Validate())
+}
+
+func TestConfig_DMap_LatencySLOWindow(t *testing.T) {
+	dc := &DMaps{}
+	require.NoError(t, dc.Sanitize())
+
+	dc.Custom["mydmap"] = DMap{LatencySLO: time.Millisecond, LatencySLOWindow: time.Second}
+	require.NoError(t, dc.Validate())
+
+	dc.Custom["mydmap"] = DMap{LatencySLO: time.Millisecond, LatencySLOWindow: 5 * time.Nanosecond}
+	require.Error(t, dc.Validate())
+}
+
+func TestConfig_DMap_MaxValueSize_ChunkSize(t *testing.T) {
+	dc := &DMaps{MaxValueSize: 1 << 20, ChunkSize: 1 << 16}
+	require.NoError(t, dc.Sanitize())
+	require.NoError(t, dc.Validate())
+
+	dc.Custom["mydmap"] = DMap{ChunkSize: -1}
+	require.Error(t, dc.Validate())
+
+	dc.ChunkSize = -1
+	delete(dc.Custom, "mydmap")
+	require.Error(t, dc.Validate())
+}
+
+func TestConfig_DMaps_Expiration(t *testing.T) {
+	dc := &DMaps{}
+	require.NoError(t, dc.Sanitize())
+	require.Equal(t, DefaultExpirationScanInterval, dc.ExpirationScanInterval)
+	require.Equal(t, DefaultExpirationSampleSize, dc.ExpirationSampleSize)
+	require.NoError(t, dc.Validate())
+
+	dc.ExpirationMaxCPUFraction = 0.25
+	require.NoError(t, dc.Validate())
+
+	dc.ExpirationMaxCPUFraction = 1.5
+	require.Error(t, dc.Validate())
+}
@@ -16,8 +16,12 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"runtime"
 	"time"
+
+	"github.com/buraksezer/olric/internal/schema"
+	"github.com/buraksezer/olric/pkg/codec"
 )
 
 // DMaps denotes a global configuration for DMaps. You can still overwrite it by
@@ -33,6 +37,26 @@ type DMaps struct {
 	//	// different values per DMap.
 	NumEvictionWorkers int64
 
+	// ExpirationScanInterval is the interval between two sequential scans of an
+	// eviction worker to find the expired and idle keys. It's 100ms by default.
+	// This is a global configuration variable. So you cannot set different
+	// values per DMap.
+	ExpirationScanInterval time.Duration
+
+	// ExpirationSampleSize is the number of keys sampled per round by a scan.
+	// The scan starts another round on the same fragment if more than 25% of
+	// the sampled keys were expired, up to five rounds. It's 20 by default.
+	// This is a global configuration variable. So you cannot set different
+	// values per DMap.
+	ExpirationSampleSize int
+
+	// ExpirationMaxCPUFraction limits the fraction of time that an eviction
+	// worker spends on scanning, it must be between 0 and 1. A worker waits
+	// longer than ExpirationScanInterval after a long scan to keep the limit.
+	// Zero disables it. This is a global configuration variable. So you
+	// cannot set different values per DMap.
+	ExpirationMaxCPUFraction float64
+
 	// MaxIdleDuration denotes maximum time for each entry to stay idle in the DMap.
 	// It limits the lifetime of the entries relative to the time of the last
 	// read or write access performed on them. The entries whose idle period exceeds
@@ -64,6 +88,10 @@ type DMaps struct {
 	// Set as LRU to enable LRU eviction policy.
 	EvictionPolicy EvictionPolicy
 
+	// QuotaPolicy determines how MaxKeys and MaxInuse are enforced, see
+	// QuotaEvict and QuotaReject. It's QuotaEvict by default.
+	QuotaPolicy QuotaPolicy
+
 	// CheckEmptyFragmentsInterval is the interval between two sequential calls of empty
 	// fragment cleaner. This is a global configuration variable. So you cannot set
 	// different values per DMap.
@@ -74,6 +102,93 @@ type DMaps struct {
 	// different values per DMap.
 	TriggerCompactionInterval time.Duration
 
+	// RetentionInterval is the interval between two sequential runs of the
+	// retention policies, see RetentionMaxAge and RetentionMaxEntriesPerMember.
+	// This is a global configuration variable. So you cannot set different
+	// values per DMap.
+	RetentionInterval time.Duration
+
+	// DestroySnapshotRetention keeps the data of a destroyed DMap on the members
+	// for the given period, so it can be restored by Undo. The snapshot is not
+	// a copy, the fragments of the DMap are set aside, so it doesn't cost more
+	// memory than keeping the DMap until the snapshot is expired. Zero disables
+	// it. This is a global configuration variable. So you cannot set different
+	// values per DMap.
+	DestroySnapshotRetention time.Duration
+
+	// AntiEntropyInterval is the interval between two sequential runs of the
+	// anti-entropy worker. The partition owners compare the checksums of their
+	// fragments with the backup owners and repair the drifted entries on the
+	// backups, which may be missed in async replication mode. Zero disables
+	// it. This is a global configuration variable. So you cannot set different
+	// values per DMap.
+	AntiEntropyInterval time.Duration
+
+	// ChangeLogSize denotes the number of mutations retained per partition of
+	// a DMap for change data capture. Consumers that fall further behind than
+	// this have to start over. It's zero by default, that means disabled.
+	ChangeLogSize int
+
+	// TombstoneRetention is the period that the partition owners keep the
+	// tombstones of the deleted keys, see DMap.GetTombstone. The tombstones
+	// are kept in memory and they are not moved with the partitions. Zero
+	// disables them.
+	TombstoneRetention time.Duration
+
+	// StrictExpiry guarantees that an expired entry is never returned by any
+	// read, including the reads from the backups, the scans and the atomic
+	// operations, even before the eviction workers remove it. The entries that
+	// are received from the other members are checked against the local clock
+	// again. It's disabled by default.
+	StrictExpiry bool
+
+	// Codec is the name of the codec that encodes the values before they are
+	// stored, see the codec package for the registry. The values are stored
+	// as they are by default.
+	Codec string
+
+	// CodecThreshold is the minimum size of a value in bytes to be encoded by
+	// Codec. The smaller values are stored as they are, compressing them is
+	// not worth it. Zero encodes all the values.
+	CodecThreshold int
+
+	// MaxValueSize is the maximum size of a value in bytes. The writes of the
+	// larger values are rejected with ErrValueTooLarge. Zero means no limit.
+	MaxValueSize int
+
+	// ChunkSize splits the values that are larger than ChunkSize bytes into
+	// chunks of ChunkSize bytes. The chunks are stored as separate entries,
+	// so they are distributed among the partitions, and Get reassembles
+	// them. NX, XX and sliding TTLs are not supported for the chunked
+	// values. Zero disables chunking.
+	ChunkSize int
+
+	// RetentionMaxAge is the retention period of the entries of all DMaps,
+	// see DMap.RetentionMaxAge. Zero disables it.
+	RetentionMaxAge time.Duration
+
+	// RetentionMaxEntriesPerMember caps the number of entries of every DMap in
+	// the partitions owned by a member, see DMap.RetentionMaxEntriesPerMember.
+	// Zero disables it.
+	RetentionMaxEntriesPerMember int
+
+	// RetentionDryRun reports the entries that the retention policies would
+	// delete without deleting them.
+	RetentionDryRun bool
+
+	// OnEntryExpired is called when a partition owner removes an entry because
+	// its TTL or MaxIdleDuration is exceeded. The value is decoded. It's called
+	// in a new goroutine on the member that removes the entry. The expired
+	// entries are removed by the eviction workers in the background or by the
+	// reads on the partition owner, so it may be called some time after the
+	// expiration.
+	OnEntryExpired func(dmap, key string, value []byte)
+
+	// OnEntryEvicted is called when a partition owner removes an entry to free
+	// up space for the new entries, see MaxKeys, MaxInuse and EvictionPolicy.
+	// It's called the same way as OnEntryExpired.
+	OnEntryEvicted func(dmap, key string, value []byte)
+
 	// Custom is useful to set custom cache config per DMap instance.
 	Custom map[string]DMap
 }
@@ -96,6 +211,10 @@ func (dm *DMaps) Sanitize() error {
 		dm.LRUSamples = DefaultLRUSamples
 	}
 
+	if dm.QuotaPolicy == "" {
+		dm.QuotaPolicy = QuotaEvict
+	}
+
 	if dm.MaxInuse < 0 {
 		dm.MaxInuse = 0
 	}
@@ -104,10 +223,26 @@ func (dm *DMaps) Sanitize() error {
 		dm.MaxKeys = 0
 	}
 
+	if dm.ChangeLogSize < 0 {
+		dm.ChangeLogSize = 0
+	}
+
+	if dm.TombstoneRetention < 0 {
+		dm.TombstoneRetention = 0
+	}
+
 	if dm.NumEvictionWorkers <= 0 {
 		dm.NumEvictionWorkers = int64(runtime.NumCPU())
 	}
 
+	if dm.ExpirationScanInterval <= 0 {
+		dm.ExpirationScanInterval = DefaultExpirationScanInterval
+	}
+
+	if dm.ExpirationSampleSize <= 0 {
+		dm.ExpirationSampleSize = DefaultExpirationSampleSize
+	}
+
 	if dm.CheckEmptyFragmentsInterval.Microseconds() == 0 {
 		dm.CheckEmptyFragmentsInterval = DefaultCheckEmptyFragmentsInterval
 	}
@@ -116,6 +251,20 @@ func (dm *DMaps) Sanitize() error {
 		dm.TriggerCompactionInterval = DefaultTriggerCompactionInterval
 	}
 
+	if dm.RetentionInterval <= 0 {
+		dm.RetentionInterval = DefaultRetentionInterval
+	}
+	if dm.RetentionMaxEntriesPerMember < 0 {
+		dm.RetentionMaxEntriesPerMember = 0
+	}
+	if dm.DestroySnapshotRetention < 0 {
+		dm.DestroySnapshotRetention = 0
+	}
+
+	if dm.AntiEntropyInterval < 0 {
+		dm.AntiEntropyInterval = 0
+	}
+
 	for _, d := range dm.Custom {
 		if err := d.Sanitize(); err != nil {
 			return err
@@ -133,6 +282,79 @@ func (dm *DMaps) Validate() error {
 	if err := dm.Engine.Validate(); err != nil {
 		return fmt.Errorf("failed to validate storage engine configuration: %w", err)
 	}
+	if dm.Codec != "" {
+		if _, err := codec.GetByName(dm.Codec); err != nil {
+			return err
+		}
+	}
+	if dm.CodecThreshold < 0 {
+		return fmt.Errorf("CodecThreshold cannot be negative: %d", dm.CodecThreshold)
+	}
+	if dm.MaxValueSize < 0 || dm.ChunkSize < 0 {
+		return fmt.Errorf("MaxValueSize and ChunkSize cannot be negative")
+	}
+	if dm.ExpirationMaxCPUFraction < 0 || dm.ExpirationMaxCPUFraction > 1 {
+		return fmt.Errorf("ExpirationMaxCPUFraction must be between 0 and 1: %v", dm.ExpirationMaxCPUFraction)
+	}
+	if dm.RetentionMaxAge < 0 {
+		return fmt.Errorf("RetentionMaxAge cannot be negative: %s", dm.RetentionMaxAge)
+	}
+	if err := validateQuotaPolicy(dm.QuotaPolicy); err != nil {
+		return err
+	}
+	for name, d := range dm.Custom {
+		if d.Codec != "" {
+			if _, err := codec.GetByName(d.Codec); err != nil {
+				return fmt.Errorf("invalid Codec for DMap: %s: %w", name, err)
+			}
+		}
+		if d.CodecThreshold < 0 {
+			return fmt.Errorf("CodecThreshold cannot be negative for DMap: %s: %d", name, d.CodecThreshold)
+		}
+		if d.MaxValueSize < 0 || d.ChunkSize < 0 {
+			return fmt.Errorf("MaxValueSize and ChunkSize cannot be negative for DMap: %s", name)
+		}
+		if d.RefreshAhead > 0 && d.Loader == nil {
+			return fmt.Errorf("RefreshAhead requires a Loader for DMap: %s", name)
+		}
+		if err := d.validateWriter(); err != nil {
+			return fmt.Errorf("%w for DMap: %s", err, name)
+		}
+		if err := d.validateTransformers(); err != nil {
+			return fmt.Errorf("%w for DMap: %s", err, name)
+		}
+		if err := validateQuotaPolicy(d.QuotaPolicy); err != nil {
+			return fmt.Errorf("%w for DMap: %s", err, name)
+		}
+		if d.ValueSchema != "" {
+			if _, err := schema.Compile(d.ValueSchema); err != nil {
+				return fmt.Errorf("invalid ValueSchema for DMap: %s: %w", name, err)
+			}
+		}
+		if d.LatencySLO < 0 || d.LatencySLOWindow < 0 {
+			return fmt.Errorf("LatencySLO and LatencySLOWindow cannot be negative for DMap: %s", name)
+		}
+		if d.LatencySLOWindow > 0 && d.LatencySLOWindow < MinLatencySLOWindow {
+			return fmt.Errorf("LatencySLOWindow cannot be less than %s for DMap: %s", MinLatencySLOWindow, name)
+		}
+		if d.LatencySLOObjective < 0 || d.LatencySLOObjective > 1 {
+			return fmt.Errorf("LatencySLOObjective has to be between 0 and 1 for DMap: %s", name)
+		}
+		for _, rl := range d.RateLimits {
+			if rl.ReadsPerSecond < 0 || rl.WritesPerSecond < 0 {
+				return fmt.Errorf("RateLimits cannot be negative for DMap: %s", name)
+			}
+			if _, err := regexp.Compile(rl.KeyPattern); err != nil {
+				return fmt.Errorf("invalid KeyPattern in RateLimits for DMap: %s: %w", name, err)
+			}
+		}
+		if d.KeyPattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(d.KeyPattern); err != nil {
+			return fmt.Errorf("invalid KeyPattern for DMap: %s: %w", name, err)
+		}
+	}
 	return nil
 }
 
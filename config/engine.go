@@ -30,7 +30,20 @@ type Engine struct {
 
 	// Config is a map that contains configuration of the storage engines, for
 	// both plugins and imported ones. If you want to use a storage engine other
-	// than the default one, you must set configuration for it.
+	// than the default one, you must set configuration for it. Add a
+	// storage.Observer with storage.ObserverKey to receive the table
+	// allocation, compaction and eviction events.
+	//
+	// The default engine accepts a memory budget in bytes: maxInuse limits the
+	// tables allocated on a member, clusterMaxInuse is divided evenly between
+	// the members. The smaller one is used if both of them are set. Recycled
+	// tables are released and keys are evicted from the DMaps with LRU eviction
+	// policy when 90% of the budget is allocated, writes are rejected with
+	// ErrOutOfMemory when it's exhausted. It's read from DMaps.Engine only.
+	//
+	// garbageRatio is the ratio of the garbage to the allocated memory of a
+	// table that triggers its compaction, 0.40 by default. A lower ratio keeps
+	// the DMaps with heavy deletes smaller at the cost of more compaction runs.
 	Config map[string]interface{}
 }
 
@@ -48,7 +61,12 @@ func (s *Engine) Validate() error {
 	if s.Config == nil {
 		s.Config = make(map[string]interface{})
 	}
-	return nil
+	c := storage.NewConfig(s.Config)
+	if _, _, err := kvstore.MemoryLimits(c); err != nil {
+		return err
+	}
+	_, err := kvstore.GarbageRatio(c)
+	return err
 }
 
 // Sanitize sets default values to empty configuration variables, if it's possible.
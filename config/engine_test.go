@@ -31,3 +31,29 @@ func TestEngine_Dont_Overwrite_TableSize(t *testing.T) {
 	require.NoError(t, e.Validate())
 	require.Equal(t, 1235, e.Config["tableSize"])
 }
+
+func TestEngine_Validate_MemoryBudget(t *testing.T) {
+	e := NewEngine()
+	e.Config = map[string]interface{}{
+		"maxInuse":        1 << 30,
+		"clusterMaxInuse": uint64(4 << 30),
+	}
+	require.NoError(t, e.Validate())
+
+	e.Config["maxInuse"] = "1G"
+	require.Error(t, e.Validate())
+}
+
+func TestEngine_Validate_GarbageRatio(t *testing.T) {
+	e := NewEngine()
+	e.Config = map[string]interface{}{
+		"garbageRatio": 0.25,
+	}
+	require.NoError(t, e.Validate())
+
+	e.Config["garbageRatio"] = 1.5
+	require.Error(t, e.Validate())
+
+	e.Config["garbageRatio"] = "0.25"
+	require.Error(t, e.Validate())
+}
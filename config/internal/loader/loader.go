@@ -17,41 +17,80 @@ package loader
 import "gopkg.in/yaml.v2"
 
 type olricd struct {
-	Name                       string  `yaml:"name"`
-	BindAddr                   string  `yaml:"bindAddr"`
-	BindPort                   int     `yaml:"bindPort"`
-	Interface                  string  `yaml:"interface"`
-	ReplicationMode            int     `yaml:"replicationMode"`
-	PartitionCount             uint64  `yaml:"partitionCount"`
-	LoadFactor                 float64 `yaml:"loadFactor"`
-	KeepAlivePeriod            string  `yaml:"keepAlivePeriod"`
-	IdleClose                  string  `yaml:"idleClose"`
-	BootstrapTimeout           string  `yaml:"bootstrapTimeout"`
-	ReplicaCount               int     `yaml:"replicaCount"`
-	WriteQuorum                int     `yaml:"writeQuorum"`
-	ReadQuorum                 int     `yaml:"readQuorum"`
-	ReadRepair                 bool    `yaml:"readRepair"`
-	MemberCountQuorum          int32   `yaml:"memberCountQuorum"`
-	RoutingTablePushInterval   string  `yaml:"routingTablePushInterval"`
-	TriggerBalancerInterval    string  `yaml:"triggerBalancerInterval"`
-	LeaveTimeout               string  `yaml:"leaveTimeout"`
-	EnableClusterEventsChannel bool    `yaml:"enableClusterEventsChannel"`
+	Name                       string               `yaml:"name"`
+	BindAddr                   string               `yaml:"bindAddr"`
+	BindPort                   int                  `yaml:"bindPort"`
+	Interface                  string               `yaml:"interface"`
+	ReplicationMode            int                  `yaml:"replicationMode"`
+	PartitionCount             uint64               `yaml:"partitionCount"`
+	LoadFactor                 float64              `yaml:"loadFactor"`
+	KeepAlivePeriod            string               `yaml:"keepAlivePeriod"`
+	IdleClose                  string               `yaml:"idleClose"`
+	BootstrapTimeout           string               `yaml:"bootstrapTimeout"`
+	ReplicaCount               int                  `yaml:"replicaCount"`
+	WriteQuorum                int                  `yaml:"writeQuorum"`
+	ReadQuorum                 int                  `yaml:"readQuorum"`
+	ReadRepair                 bool                 `yaml:"readRepair"`
+	MaxClockDrift              string               `yaml:"maxClockDrift"`
+	MemberCountQuorum          int32                `yaml:"memberCountQuorum"`
+	QuorumLossMode             int                  `yaml:"quorumLossMode"`
+	BootstrapQuorum            int32                `yaml:"bootstrapQuorum"`
+	RoutingTablePushInterval   string               `yaml:"routingTablePushInterval"`
+	TriggerBalancerInterval    string               `yaml:"triggerBalancerInterval"`
+	MaxConcurrentTransfers     int                  `yaml:"maxConcurrentPartitionTransfers"`
+	TransferBandwidth          int64                `yaml:"partitionTransferBandwidth"`
+	TransferEntryRate          int64                `yaml:"partitionTransferEntryRate"`
+	ImportBandwidth            int64                `yaml:"importBandwidth"`
+	ImportEntryRate            int64                `yaml:"importEntryRate"`
+	MaxConcurrentImports       int                  `yaml:"maxConcurrentImports"`
+	BalancerWindowStart        string               `yaml:"balancerWindowStart"`
+	BalancerWindowEnd          string               `yaml:"balancerWindowEnd"`
+	LeaveTimeout               string               `yaml:"leaveTimeout"`
+	DeadMemberTimeout          string               `yaml:"deadMemberTimeout"`
+	ShutdownDrainTimeout       string               `yaml:"shutdownDrainTimeout"`
+	OwnershipHistorySize       int                  `yaml:"ownershipHistorySize"`
+	SlowLogThreshold           string               `yaml:"slowLogThreshold"`
+	MaxResponseSize            int                  `yaml:"maxResponseSize"`
+	SlowLogMaxLen              int                  `yaml:"slowLogMaxLen"`
+	WriteCoalesceDelay         string               `yaml:"writeCoalesceDelay"`
+	CommandTimeout             string               `yaml:"commandTimeout"`
+	CommandTimeouts            map[string]string    `yaml:"commandTimeouts"`
+	AdminAddr                  string               `yaml:"adminAddr"`
+	AdminToken                 string               `yaml:"adminToken"`
+	EnableClusterEventsChannel bool                 `yaml:"enableClusterEventsChannel"`
+	AnalyticsReplica           bool                 `yaml:"analyticsReplica"`
+	MemberTags                 map[string]string    `yaml:"memberTags"`
+	Namespaces                 map[string]namespace `yaml:"namespaces"`
+}
+
+type namespace struct {
+	MaxKeys  int               `yaml:"maxKeys"`
+	MaxInuse int               `yaml:"maxInuse"`
+	ReadOnly bool              `yaml:"readOnly"`
+	ACL      map[string]string `yaml:"acl"`
 }
 
 type client struct {
-	DialTimeout        string `yaml:"dialTimeout"`
-	ReadTimeout        string `yaml:"readTimeout"`
-	WriteTimeout       string `yaml:"writeTimeout"`
-	MaxRetries         int    `yaml:"maxRetries"`
-	MinRetryBackoff    string `yaml:"minRetryBackoff"`
-	MaxRetryBackoff    string `yaml:"maxRetryBackoff"`
-	PoolFIFO           bool   `yaml:"poolFIFO"`
-	PoolSize           int    `yaml:"poolSize"`
-	MinIdleConns       int    `yaml:"minIdleConns"`
-	MaxConnAge         string `yaml:"maxConnAge"`
-	PoolTimeout        string `yaml:"poolTimeout"`
-	IdleTimeout        string `yaml:"idleTimeout"`
-	IdleCheckFrequency string `yaml:"idleCheckFrequency"`
+	DialTimeout         string `yaml:"dialTimeout"`
+	ReadTimeout         string `yaml:"readTimeout"`
+	WriteTimeout        string `yaml:"writeTimeout"`
+	MaxRetries          int    `yaml:"maxRetries"`
+	MinRetryBackoff     string `yaml:"minRetryBackoff"`
+	MaxRetryBackoff     string `yaml:"maxRetryBackoff"`
+	PoolFIFO            bool   `yaml:"poolFIFO"`
+	PoolSize            int    `yaml:"poolSize"`
+	MinIdleConns        int    `yaml:"minIdleConns"`
+	MaxConnAge          string `yaml:"maxConnAge"`
+	PoolTimeout         string `yaml:"poolTimeout"`
+	IdleTimeout         string `yaml:"idleTimeout"`
+	IdleCheckFrequency  string `yaml:"idleCheckFrequency"`
+	PreDial             bool   `yaml:"preDial"`
+	HealthCheckInterval string `yaml:"healthCheckInterval"`
+	AdaptiveTimeout     bool   `yaml:"adaptiveTimeout"`
+	MinAdaptiveTimeout  string `yaml:"minAdaptiveTimeout"`
+	MaxAdaptiveTimeout  string `yaml:"maxAdaptiveTimeout"`
+
+	HealthCheckFailureThreshold int `yaml:"healthCheckFailureThreshold"`
 }
 
 // logging contains configuration variables of logging section of config file.
@@ -97,28 +136,72 @@ type engine struct {
 	Config map[string]interface{} `yaml:"config"`
 }
 
+type rateLimit struct {
+	KeyPattern      string `yaml:"keyPattern"`
+	ReadsPerSecond  int64  `yaml:"readsPerSecond"`
+	WritesPerSecond int64  `yaml:"writesPerSecond"`
+	PerClient       bool   `yaml:"perClient"`
+}
+
 type dmap struct {
-	Engine          *engine `yaml:"engine"`
-	MaxIdleDuration string  `yaml:"maxIdleDuration"`
-	TTLDuration     string  `yaml:"ttlDuration"`
-	MaxKeys         int     `yaml:"maxKeys"`
-	MaxInuse        int     `yaml:"maxInuse"`
-	LRUSamples      int     `yaml:"lruSamples"`
-	EvictionPolicy  string  `yaml:"evictionPolicy"`
+	Engine                       *engine     `yaml:"engine"`
+	MaxIdleDuration              string      `yaml:"maxIdleDuration"`
+	TTLDuration                  string      `yaml:"ttlDuration"`
+	MaxKeys                      int         `yaml:"maxKeys"`
+	MaxInuse                     int         `yaml:"maxInuse"`
+	LRUSamples                   int         `yaml:"lruSamples"`
+	EvictionPolicy               string      `yaml:"evictionPolicy"`
+	QuotaPolicy                  string      `yaml:"quotaPolicy"`
+	ChangeLogSize                int         `yaml:"changeLogSize"`
+	TombstoneRetention           string      `yaml:"tombstoneRetention"`
+	StrictExpiry                 bool        `yaml:"strictExpiry"`
+	HashTags                     bool        `yaml:"hashTags"`
+	KeyPattern                   string      `yaml:"keyPattern"`
+	RateLimits                   []rateLimit `yaml:"rateLimits"`
+	LatencySLO                   string      `yaml:"latencySLO"`
+	LatencySLOObjective          float64     `yaml:"latencySLOObjective"`
+	LatencySLOWindow             string      `yaml:"latencySLOWindow"`
+	Codec                        string      `yaml:"codec"`
+	CodecThreshold               int         `yaml:"codecThreshold"`
+	MaxValueSize                 int         `yaml:"maxValueSize"`
+	ChunkSize                    int         `yaml:"chunkSize"`
+	RetentionMaxAge              string      `yaml:"retentionMaxAge"`
+	RetentionMaxEntriesPerMember int         `yaml:"retentionMaxEntriesPerMember"`
+	RetentionDryRun              bool        `yaml:"retentionDryRun"`
+	AccessSampleRate             float64     `yaml:"accessSampleRate"`
+	HotKeysWindow                string      `yaml:"hotKeysWindow"`
+	ValueSchema                  string      `yaml:"valueSchema"`
 }
 
 type dmaps struct {
-	Engine                      *engine         `yaml:"engine"`
-	NumEvictionWorkers          int64           `yaml:"numEvictionWorkers"`
-	MaxIdleDuration             string          `yaml:"maxIdleDuration"`
-	TTLDuration                 string          `yaml:"ttlDuration"`
-	MaxKeys                     int             `yaml:"maxKeys"`
-	MaxInuse                    int             `yaml:"maxInuse"`
-	LRUSamples                  int             `yaml:"lruSamples"`
-	EvictionPolicy              string          `yaml:"evictionPolicy"`
-	CheckEmptyFragmentsInterval string          `yaml:"checkEmptyFragmentsInterval"`
-	TriggerCompactionInterval   string          `yaml:"triggerCompactionInterval"`
-	Custom                      map[string]dmap `yaml:"custom"`
+	Engine                       *engine         `yaml:"engine"`
+	NumEvictionWorkers           int64           `yaml:"numEvictionWorkers"`
+	ExpirationScanInterval       string          `yaml:"expirationScanInterval"`
+	ExpirationSampleSize         int             `yaml:"expirationSampleSize"`
+	ExpirationMaxCPUFraction     float64         `yaml:"expirationMaxCPUFraction"`
+	MaxIdleDuration              string          `yaml:"maxIdleDuration"`
+	TTLDuration                  string          `yaml:"ttlDuration"`
+	MaxKeys                      int             `yaml:"maxKeys"`
+	MaxInuse                     int             `yaml:"maxInuse"`
+	LRUSamples                   int             `yaml:"lruSamples"`
+	EvictionPolicy               string          `yaml:"evictionPolicy"`
+	QuotaPolicy                  string          `yaml:"quotaPolicy"`
+	CheckEmptyFragmentsInterval  string          `yaml:"checkEmptyFragmentsInterval"`
+	TriggerCompactionInterval    string          `yaml:"triggerCompactionInterval"`
+	RetentionInterval            string          `yaml:"retentionInterval"`
+	DestroySnapshotRetention     string          `yaml:"destroySnapshotRetention"`
+	AntiEntropyInterval          string          `yaml:"antiEntropyInterval"`
+	ChangeLogSize                int             `yaml:"changeLogSize"`
+	TombstoneRetention           string          `yaml:"tombstoneRetention"`
+	StrictExpiry                 bool            `yaml:"strictExpiry"`
+	Codec                        string          `yaml:"codec"`
+	CodecThreshold               int             `yaml:"codecThreshold"`
+	MaxValueSize                 int             `yaml:"maxValueSize"`
+	ChunkSize                    int             `yaml:"chunkSize"`
+	RetentionMaxAge              string          `yaml:"retentionMaxAge"`
+	RetentionMaxEntriesPerMember int             `yaml:"retentionMaxEntriesPerMember"`
+	RetentionDryRun              bool            `yaml:"retentionDryRun"`
+	Custom                       map[string]dmap `yaml:"custom"`
 }
 
 type serviceDiscovery map[string]interface{}
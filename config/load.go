@@ -77,6 +77,14 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.TTLDuration = ttlDuration
 	}
 
+	if c.DMaps.ExpirationScanInterval != "" {
+		expirationScanInterval, err := time.ParseDuration(c.DMaps.ExpirationScanInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.expirationScanInterval")
+		}
+		res.ExpirationScanInterval = expirationScanInterval
+	}
+
 	if c.DMaps.CheckEmptyFragmentsInterval != "" {
 		checkEmptyFragmentsInterval, err := time.ParseDuration(c.DMaps.CheckEmptyFragmentsInterval)
 		if err != nil {
@@ -93,11 +101,62 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.TriggerCompactionInterval = triggerCompactionInterval
 	}
 
+	if c.DMaps.RetentionInterval != "" {
+		retentionInterval, err := time.ParseDuration(c.DMaps.RetentionInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.retentionInterval")
+		}
+		res.RetentionInterval = retentionInterval
+	}
+
+	if c.DMaps.RetentionMaxAge != "" {
+		retentionMaxAge, err := time.ParseDuration(c.DMaps.RetentionMaxAge)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.retentionMaxAge")
+		}
+		res.RetentionMaxAge = retentionMaxAge
+	}
+
+	if c.DMaps.DestroySnapshotRetention != "" {
+		destroySnapshotRetention, err := time.ParseDuration(c.DMaps.DestroySnapshotRetention)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.destroySnapshotRetention")
+		}
+		res.DestroySnapshotRetention = destroySnapshotRetention
+	}
+
+	if c.DMaps.AntiEntropyInterval != "" {
+		antiEntropyInterval, err := time.ParseDuration(c.DMaps.AntiEntropyInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.antiEntropyInterval")
+		}
+		res.AntiEntropyInterval = antiEntropyInterval
+	}
+
+	if c.DMaps.TombstoneRetention != "" {
+		tombstoneRetention, err := time.ParseDuration(c.DMaps.TombstoneRetention)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.tombstoneRetention")
+		}
+		res.TombstoneRetention = tombstoneRetention
+	}
+
 	res.NumEvictionWorkers = c.DMaps.NumEvictionWorkers
+	res.ExpirationSampleSize = c.DMaps.ExpirationSampleSize
+	res.ExpirationMaxCPUFraction = c.DMaps.ExpirationMaxCPUFraction
 	res.MaxKeys = c.DMaps.MaxKeys
 	res.MaxInuse = c.DMaps.MaxInuse
 	res.EvictionPolicy = EvictionPolicy(c.DMaps.EvictionPolicy)
+	res.QuotaPolicy = QuotaPolicy(c.DMaps.QuotaPolicy)
 	res.LRUSamples = c.DMaps.LRUSamples
+	res.ChangeLogSize = c.DMaps.ChangeLogSize
+	res.Codec = c.DMaps.Codec
+	res.CodecThreshold = c.DMaps.CodecThreshold
+	res.MaxValueSize = c.DMaps.MaxValueSize
+	res.ChunkSize = c.DMaps.ChunkSize
+	res.StrictExpiry = c.DMaps.StrictExpiry
+	res.RetentionMaxEntriesPerMember = c.DMaps.RetentionMaxEntriesPerMember
+	res.RetentionDryRun = c.DMaps.RetentionDryRun
 
 	if c.DMaps.Engine != nil {
 		e := NewEngine()
@@ -113,7 +172,24 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 				MaxInuse:       dc.MaxInuse,
 				MaxKeys:        dc.MaxKeys,
 				EvictionPolicy: EvictionPolicy(dc.EvictionPolicy),
+				QuotaPolicy:    QuotaPolicy(dc.QuotaPolicy),
 				LRUSamples:     dc.LRUSamples,
+				ChangeLogSize:  dc.ChangeLogSize,
+				KeyPattern:     dc.KeyPattern,
+				Codec:          dc.Codec,
+				CodecThreshold: dc.CodecThreshold,
+				MaxValueSize:   dc.MaxValueSize,
+				ChunkSize:      dc.ChunkSize,
+				StrictExpiry:   dc.StrictExpiry,
+				HashTags:       dc.HashTags,
+
+				RetentionMaxEntriesPerMember: dc.RetentionMaxEntriesPerMember,
+				RetentionDryRun:              dc.RetentionDryRun,
+
+				AccessSampleRate: dc.AccessSampleRate,
+				ValueSchema:      dc.ValueSchema,
+
+				LatencySLOObjective: dc.LatencySLOObjective,
 			}
 			if dc.Engine != nil {
 				e := NewEngine()
@@ -121,6 +197,14 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 				e.Config = dc.Engine.Config
 				cc.Engine = e
 			}
+			for _, rl := range dc.RateLimits {
+				cc.RateLimits = append(cc.RateLimits, RateLimit{
+					KeyPattern:      rl.KeyPattern,
+					ReadsPerSecond:  rl.ReadsPerSecond,
+					WritesPerSecond: rl.WritesPerSecond,
+					PerClient:       rl.PerClient,
+				})
+			}
 			if dc.MaxIdleDuration != "" {
 				maxIdleDuration, err := time.ParseDuration(dc.MaxIdleDuration)
 				if err != nil {
@@ -135,6 +219,41 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 				}
 				cc.TTLDuration = ttlDuration
 			}
+			if dc.RetentionMaxAge != "" {
+				retentionMaxAge, err := time.ParseDuration(dc.RetentionMaxAge)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.RetentionMaxAge", name)
+				}
+				cc.RetentionMaxAge = retentionMaxAge
+			}
+			if dc.TombstoneRetention != "" {
+				tombstoneRetention, err := time.ParseDuration(dc.TombstoneRetention)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.TombstoneRetention", name)
+				}
+				cc.TombstoneRetention = tombstoneRetention
+			}
+			if dc.LatencySLO != "" {
+				latencySLO, err := time.ParseDuration(dc.LatencySLO)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.LatencySLO", name)
+				}
+				cc.LatencySLO = latencySLO
+			}
+			if dc.HotKeysWindow != "" {
+				hotKeysWindow, err := time.ParseDuration(dc.HotKeysWindow)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.HotKeysWindow", name)
+				}
+				cc.HotKeysWindow = hotKeysWindow
+			}
+			if dc.LatencySLOWindow != "" {
+				latencySLOWindow, err := time.ParseDuration(dc.LatencySLOWindow)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.LatencySLOWindow", name)
+				}
+				cc.LatencySLOWindow = latencySLOWindow
+			}
 			res.Custom[name] = cc
 		}
 	}
@@ -354,6 +473,22 @@ func Load(filename string) (*Config, error) {
 		}
 	}
 
+	var balancerWindowStart, balancerWindowEnd time.Duration
+	if c.Olricd.BalancerWindowStart != "" {
+		balancerWindowStart, err = time.ParseDuration(c.Olricd.BalancerWindowStart)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.balancerWindowStart: '%s'", c.Olricd.BalancerWindowStart))
+		}
+	}
+	if c.Olricd.BalancerWindowEnd != "" {
+		balancerWindowEnd, err = time.ParseDuration(c.Olricd.BalancerWindowEnd)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.balancerWindowEnd: '%s'", c.Olricd.BalancerWindowEnd))
+		}
+	}
+
 	if c.Olricd.LeaveTimeout != "" {
 		leaveTimeout, err = time.ParseDuration(c.Olricd.LeaveTimeout)
 		if err != nil {
@@ -362,6 +497,93 @@ func Load(filename string) (*Config, error) {
 		}
 	}
 
+	var deadMemberTimeout time.Duration
+	if c.Olricd.DeadMemberTimeout != "" {
+		deadMemberTimeout, err = time.ParseDuration(c.Olricd.DeadMemberTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.deadMemberTimeout: '%s'", c.Olricd.DeadMemberTimeout))
+		}
+	}
+
+	var shutdownDrainTimeout time.Duration
+	if c.Olricd.ShutdownDrainTimeout != "" {
+		shutdownDrainTimeout, err = time.ParseDuration(c.Olricd.ShutdownDrainTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.shutdownDrainTimeout: '%s'", c.Olricd.ShutdownDrainTimeout))
+		}
+	}
+
+	var maxClockDrift time.Duration
+	if c.Olricd.MaxClockDrift != "" {
+		maxClockDrift, err = time.ParseDuration(c.Olricd.MaxClockDrift)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.maxClockDrift: '%s'", c.Olricd.MaxClockDrift))
+		}
+	}
+
+	var slowLogThreshold time.Duration
+	if c.Olricd.SlowLogThreshold != "" {
+		slowLogThreshold, err = time.ParseDuration(c.Olricd.SlowLogThreshold)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.slowLogThreshold: '%s'", c.Olricd.SlowLogThreshold))
+		}
+	}
+
+	var writeCoalesceDelay time.Duration
+	if c.Olricd.WriteCoalesceDelay != "" {
+		writeCoalesceDelay, err = time.ParseDuration(c.Olricd.WriteCoalesceDelay)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.writeCoalesceDelay: '%s'", c.Olricd.WriteCoalesceDelay))
+		}
+	}
+
+	var commandTimeout time.Duration
+	if c.Olricd.CommandTimeout != "" {
+		commandTimeout, err = time.ParseDuration(c.Olricd.CommandTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.commandTimeout: '%s'", c.Olricd.CommandTimeout))
+		}
+	}
+
+	var commandTimeouts map[string]time.Duration
+	for command, raw := range c.Olricd.CommandTimeouts {
+		if commandTimeouts == nil {
+			commandTimeouts = make(map[string]time.Duration)
+		}
+		timeout, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, errors.WithMessage(err,
+				fmt.Sprintf("failed to parse olricd.commandTimeouts.%s: '%s'", command, raw))
+		}
+		commandTimeouts[command] = timeout
+	}
+
+	var namespaces map[string]Namespace
+	for name, ns := range c.Olricd.Namespaces {
+		if namespaces == nil {
+			namespaces = make(map[string]Namespace)
+		}
+		var acl map[string]NamespaceAccess
+		for token, access := range ns.ACL {
+			if acl == nil {
+				acl = make(map[string]NamespaceAccess)
+			}
+			acl[token] = NamespaceAccess(access)
+		}
+		namespaces[name] = Namespace{
+			MaxKeys:  ns.MaxKeys,
+			MaxInuse: ns.MaxInuse,
+			ReadOnly: ns.ReadOnly,
+			ACL:      acl,
+		}
+	}
+
 	clientConfig := Client{}
 	err = mapYamlToConfig(&clientConfig, &c.Client)
 	if err != nil {
@@ -374,37 +596,62 @@ func Load(filename string) (*Config, error) {
 	}
 
 	cfg := &Config{
-		BindAddr:                   c.Olricd.BindAddr,
-		BindPort:                   c.Olricd.BindPort,
-		Interface:                  c.Olricd.Interface,
-		ServiceDiscovery:           c.ServiceDiscovery,
-		MemberlistInterface:        c.Memberlist.Interface,
-		MemberlistConfig:           memberlistConfig,
-		Client:                     &clientConfig,
-		LogLevel:                   c.Logging.Level,
-		JoinRetryInterval:          joinRetryInterval,
-		RoutingTablePushInterval:   routingTablePushInterval,
-		TriggerBalancerInterval:    triggerBalancerInterval,
-		EnableClusterEventsChannel: c.Olricd.EnableClusterEventsChannel,
-		MaxJoinAttempts:            c.Memberlist.MaxJoinAttempts,
-		Peers:                      c.Memberlist.Peers,
-		PartitionCount:             c.Olricd.PartitionCount,
-		ReplicaCount:               c.Olricd.ReplicaCount,
-		WriteQuorum:                c.Olricd.WriteQuorum,
-		ReadQuorum:                 c.Olricd.ReadQuorum,
-		ReplicationMode:            c.Olricd.ReplicationMode,
-		ReadRepair:                 c.Olricd.ReadRepair,
-		LoadFactor:                 c.Olricd.LoadFactor,
-		MemberCountQuorum:          c.Olricd.MemberCountQuorum,
-		Logger:                     log.New(logOutput, "", log.LstdFlags),
-		LogOutput:                  logOutput,
-		LogVerbosity:               c.Logging.Verbosity,
-		Hasher:                     hasher.NewDefaultHasher(),
-		KeepAlivePeriod:            keepAlivePeriod,
-		IdleClose:                  idleClose,
-		BootstrapTimeout:           bootstrapTimeout,
-		LeaveTimeout:               leaveTimeout,
-		DMaps:                      dmapConfig,
+		BindAddr:                        c.Olricd.BindAddr,
+		BindPort:                        c.Olricd.BindPort,
+		Interface:                       c.Olricd.Interface,
+		ServiceDiscovery:                c.ServiceDiscovery,
+		MemberlistInterface:             c.Memberlist.Interface,
+		MemberlistConfig:                memberlistConfig,
+		Client:                          &clientConfig,
+		LogLevel:                        c.Logging.Level,
+		JoinRetryInterval:               joinRetryInterval,
+		RoutingTablePushInterval:        routingTablePushInterval,
+		TriggerBalancerInterval:         triggerBalancerInterval,
+		MaxConcurrentPartitionTransfers: c.Olricd.MaxConcurrentTransfers,
+		PartitionTransferBandwidth:      c.Olricd.TransferBandwidth,
+		PartitionTransferEntryRate:      c.Olricd.TransferEntryRate,
+		ImportBandwidth:                 c.Olricd.ImportBandwidth,
+		ImportEntryRate:                 c.Olricd.ImportEntryRate,
+		MaxConcurrentImports:            c.Olricd.MaxConcurrentImports,
+		BalancerWindowStart:             balancerWindowStart,
+		BalancerWindowEnd:               balancerWindowEnd,
+		DeadMemberTimeout:               deadMemberTimeout,
+		ShutdownDrainTimeout:            shutdownDrainTimeout,
+		OwnershipHistorySize:            c.Olricd.OwnershipHistorySize,
+		SlowLogThreshold:                slowLogThreshold,
+		SlowLogMaxLen:                   c.Olricd.SlowLogMaxLen,
+		WriteCoalesceDelay:              writeCoalesceDelay,
+		CommandTimeout:                  commandTimeout,
+		CommandTimeouts:                 commandTimeouts,
+		AdminAddr:                       c.Olricd.AdminAddr,
+		AdminToken:                      c.Olricd.AdminToken,
+		MaxResponseSize:                 c.Olricd.MaxResponseSize,
+		EnableClusterEventsChannel:      c.Olricd.EnableClusterEventsChannel,
+		AnalyticsReplica:                c.Olricd.AnalyticsReplica,
+		MemberTags:                      c.Olricd.MemberTags,
+		Namespaces:                      namespaces,
+		MaxJoinAttempts:                 c.Memberlist.MaxJoinAttempts,
+		Peers:                           c.Memberlist.Peers,
+		PartitionCount:                  c.Olricd.PartitionCount,
+		ReplicaCount:                    c.Olricd.ReplicaCount,
+		WriteQuorum:                     c.Olricd.WriteQuorum,
+		ReadQuorum:                      c.Olricd.ReadQuorum,
+		ReplicationMode:                 c.Olricd.ReplicationMode,
+		ReadRepair:                      c.Olricd.ReadRepair,
+		MaxClockDrift:                   maxClockDrift,
+		LoadFactor:                      c.Olricd.LoadFactor,
+		MemberCountQuorum:               c.Olricd.MemberCountQuorum,
+		QuorumLossMode:                  c.Olricd.QuorumLossMode,
+		BootstrapQuorum:                 c.Olricd.BootstrapQuorum,
+		Logger:                          log.New(logOutput, "", log.LstdFlags),
+		LogOutput:                       logOutput,
+		LogVerbosity:                    c.Logging.Verbosity,
+		Hasher:                          hasher.NewDefaultHasher(),
+		KeepAlivePeriod:                 keepAlivePeriod,
+		IdleClose:                       idleClose,
+		BootstrapTimeout:                bootstrapTimeout,
+		LeaveTimeout:                    leaveTimeout,
+		DMaps:                           dmapConfig,
 	}
 
 	if err := cfg.Sanitize(); err != nil {
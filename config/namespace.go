@@ -0,0 +1,88 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamespaceSeparator separates the namespace from the name of a DMap. The
+// DMap "cache" of the namespace "team-a" is stored as "team-a/cache".
+const NamespaceSeparator = "/"
+
+// NamespaceAccess is the permission of an access token of a namespace.
+type NamespaceAccess string
+
+const (
+	// NamespaceReadOnly allows the reads only.
+	NamespaceReadOnly NamespaceAccess = "read-only"
+
+	// NamespaceReadWrite allows the reads and the writes.
+	NamespaceReadWrite NamespaceAccess = "read-write"
+)
+
+// Namespace denotes the quotas and the access rules of a tenant. The DMaps of
+// a namespace share its quotas.
+type Namespace struct {
+	// MaxKeys is the maximum number of keys that the DMaps of the namespace
+	// can store on a member. The writes of the new keys are rejected after
+	// that. Zero means no limit.
+	MaxKeys int
+
+	// MaxInuse is the maximum amount of memory in bytes that the DMaps of the
+	// namespace can use on a member. The writes are rejected after that.
+	// Zero means no limit.
+	MaxInuse int
+
+	// ReadOnly rejects the writes to the DMaps of the namespace.
+	ReadOnly bool
+
+	// ACL maps the access tokens of the namespace to their permissions. If
+	// it's not empty, the DMaps of the namespace can only be created by a
+	// namespace that is opened with one of the tokens, see
+	// olric.WithNamespaceToken. ReadOnly overrides the permissions.
+	ACL map[string]NamespaceAccess
+}
+
+// ValidateNamespaceName checks the name of a namespace.
+func ValidateNamespaceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("namespace name cannot be empty")
+	}
+	if strings.Contains(name, NamespaceSeparator) {
+		return fmt.Errorf("namespace name cannot contain %q", NamespaceSeparator)
+	}
+	return nil
+}
+
+// Validate finds errors in the current configuration.
+func (n *Namespace) Validate() error {
+	if n.MaxKeys < 0 {
+		return fmt.Errorf("cannot specify MaxKeys less than zero")
+	}
+	if n.MaxInuse < 0 {
+		return fmt.Errorf("cannot specify MaxInuse less than zero")
+	}
+	for token, access := range n.ACL {
+		if token == "" {
+			return fmt.Errorf("ACL token cannot be empty")
+		}
+		if access != NamespaceReadOnly && access != NamespaceReadWrite {
+			return fmt.Errorf("invalid ACL permission: %q", access)
+		}
+	}
+	return nil
+}
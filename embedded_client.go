@@ -20,16 +20,21 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/dmap"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/util"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/buraksezer/olric/stats"
 	"github.com/go-redis/redis/v8"
+	"github.com/hashicorp/go-multierror"
 )
 
 func processProtocolError(err error) error {
@@ -66,9 +71,52 @@ func (l *EmbeddedLockContext) Lease(ctx context.Context, duration time.Duration)
 	return convertDMapError(err)
 }
 
+// EmbeddedMultiLockContext is returned by MultiLock method. It manages the
+// locks of all the keys at once.
+type EmbeddedMultiLockContext struct {
+	tokens map[string][]byte
+	dm     *EmbeddedDMap
+}
+
+// Unlock releases all the locks. It tries every key, even if one of them fails,
+// and returns the last error.
+func (l *EmbeddedMultiLockContext) Unlock(ctx context.Context) error {
+	var latestError error
+	for key, token := range l.tokens {
+		if err := l.dm.dm.Unlock(ctx, key, token); err != nil {
+			latestError = err
+		}
+	}
+	return convertDMapError(latestError)
+}
+
+// Lease takes the duration to update the expiry for all the locks. It tries
+// every key, even if one of them fails, and returns the errors of all the
+// failed keys.
+func (l *EmbeddedMultiLockContext) Lease(ctx context.Context, duration time.Duration) error {
+	var result error
+	for key, token := range l.tokens {
+		if err := l.dm.dm.Lease(ctx, key, token, duration); err != nil {
+			result = multierror.Append(result, fmt.Errorf("%s: %w", key, convertDMapError(err)))
+		}
+	}
+	return result
+}
+
 // EmbeddedClient is an Olric client implementation for embedded-member scenario.
+// It shares the routing table of the member, so the commands are always sent
+// directly to the partition owners, without a redirection on another member.
 type EmbeddedClient struct {
-	db *Olric
+	db           *Olric
+	codec        Codec
+	retry        *retryPolicy
+	inflightPuts chan struct{}
+	metrics      *clientMetrics
+	hedge        *hedgePolicy
+	interceptors []Interceptor
+
+	// id identifies the client in the per-client rate limits of the DMaps.
+	id string
 }
 
 // EmbeddedDMap is an DMap client implementation for embedded-member scenario.
@@ -79,6 +127,8 @@ type EmbeddedDMap struct {
 	dm     *dmap.DMap
 	client *EmbeddedClient
 	name   string
+	reads  *readSession
+	mirror *mirror
 }
 
 // RefreshMetadata fetches a list of available members and the latest routing
@@ -93,20 +143,12 @@ func (e *EmbeddedClient) RefreshMetadata(_ context.Context) error {
 // this dmap.
 //
 // It returns immediately if it acquires the lock for the given key. Otherwise,
-// it waits until deadline.
+// it waits until deadline, or until ctx is done if WithBlocking is given.
 //
 // You should know that the locks are approximate, and only to be used for
 // non-critical purposes.
-func (dm *EmbeddedDMap) Lock(ctx context.Context, key string, deadline time.Duration) (LockContext, error) {
-	token, err := dm.dm.Lock(ctx, key, 0*time.Second, deadline)
-	if err != nil {
-		return nil, convertDMapError(err)
-	}
-	return &EmbeddedLockContext{
-		key:   key,
-		token: token,
-		dm:    dm,
-	}, nil
+func (dm *EmbeddedDMap) Lock(ctx context.Context, key string, deadline time.Duration, options ...LockOption) (LockContext, error) {
+	return dm.LockWithTimeout(ctx, key, 0*time.Second, deadline, options...)
 }
 
 // LockWithTimeout sets a lock for the given key. If the lock is still unreleased
@@ -115,12 +157,23 @@ func (dm *EmbeddedDMap) Lock(ctx context.Context, key string, deadline time.Dura
 // this dmap.
 //
 // It returns immediately if it acquires the lock for the given key. Otherwise,
-// it waits until deadline.
+// it waits until deadline, or until ctx is done if WithBlocking is given.
 //
 // You should know that the locks are approximate, and only to be used for
 // non-critical purposes.
-func (dm *EmbeddedDMap) LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration) (LockContext, error) {
-	token, err := dm.dm.Lock(ctx, key, timeout, deadline)
+func (dm *EmbeddedDMap) LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration, options ...LockOption) (LockContext, error) {
+	var cfg lockConfig
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	var token []byte
+	var err error
+	if cfg.blocking {
+		token, err = dm.dm.BlockingLock(ctx, key, timeout)
+	} else {
+		token, err = dm.dm.Lock(ctx, key, timeout, deadline)
+	}
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
@@ -131,17 +184,58 @@ func (dm *EmbeddedDMap) LockWithTimeout(ctx context.Context, key string, timeout
 	}, nil
 }
 
+// LockInfo returns the hash of the holder token, the acquisition time and the
+// remaining TTL of the lock on the given key. It returns ErrNoSuchLock if the
+// key is not locked.
+func (dm *EmbeddedDMap) LockInfo(ctx context.Context, key string) (*LockInfo, error) {
+	info, err := dm.dm.LockInfo(ctx, key)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return &LockInfo{
+		TokenHash:  info.TokenHash,
+		AcquiredAt: time.Unix(0, info.AcquiredAt),
+		TTL:        info.TTL,
+	}, nil
+}
+
+// MultiLock sets locks for the given keys. The keys are locked in a
+// deterministic order, so concurrent callers cannot deadlock. If one of the
+// locks cannot be acquired until deadline, the acquired ones are released.
+func (dm *EmbeddedDMap) MultiLock(ctx context.Context, keys []string, deadline time.Duration) (LockContext, error) {
+	tokens, err := dm.dm.MultiLock(ctx, keys, 0*time.Second, deadline)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return &EmbeddedMultiLockContext{
+		tokens: tokens,
+		dm:     dm,
+	}, nil
+}
+
 // Destroy flushes the given DMap on the cluster. You should know that there
 // is no global lock on DMaps. So if you call Put/PutEx and Destroy methods
 // concurrently on the cluster, Put call may set new values to the DMap.
 func (dm *EmbeddedDMap) Destroy(ctx context.Context) error {
-	return dm.dm.Destroy(ctx)
+	err := dm.client.observe(ctx, dm.command("Destroy"), func(ctx context.Context, _ *ClientCommand) error {
+		return dm.dm.Destroy(ctx)
+	})
+	if err == nil && dm.reads != nil {
+		dm.reads.reset()
+	}
+	return err
 }
 
 // Expire updates the expiry for the given key. It returns ErrKeyNotFound if
 // the DB does not contain the key. It's thread-safe.
 func (dm *EmbeddedDMap) Expire(ctx context.Context, key string, timeout time.Duration) error {
-	return dm.dm.Expire(ctx, key, timeout)
+	err := dm.client.do(ctx, dm.command("Expire", key), func(ctx context.Context, cmd *ClientCommand) error {
+		return convertDMapError(dm.dm.Expire(ctx, cmd.Keys[0], timeout))
+	})
+	if err == nil && dm.mirror != nil {
+		dm.mirror.expire(key, timeout)
+	}
+	return err
 }
 
 // Name exposes name of the DMap.
@@ -151,27 +245,314 @@ func (dm *EmbeddedDMap) Name() string {
 
 // Function runs the given function on the owner of the given key.
 func (dm *EmbeddedDMap) Function(ctx context.Context, key string, function string, arg []byte) ([]byte, error) {
-	return dm.dm.Function(ctx, key, function, arg)
+	return dm.dm.Function(dm.client.context(ctx), key, function, arg)
+}
+
+// Execute runs the registered entry processor against the entry on the owner
+// of the key and returns its result.
+func (dm *EmbeddedDMap) Execute(ctx context.Context, key, processor string, args []byte) ([]byte, error) {
+	var result []byte
+	err := dm.client.observe(ctx, dm.command("Execute", key), func(ctx context.Context, cmd *ClientCommand) (err error) {
+		result, err = dm.dm.Execute(ctx, cmd.Keys[0], processor, args)
+		return convertDMapError(err)
+	})
+	return result, err
+}
+
+// embeddedTx encodes and decodes the values of a transaction with the codec
+// of the client.
+type embeddedTx struct {
+	tx    *dmap.Tx
+	codec Codec
+}
+
+func (t *embeddedTx) Get(key string) (*GetResponse, error) {
+	e, err := t.tx.Get(key)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return &GetResponse{entry: e, codec: t.codec}, nil
+}
+
+func (t *embeddedTx) Put(key string, value interface{}, options ...PutOption) error {
+	var pc dmap.PutConfig
+	for _, opt := range options {
+		opt(&pc)
+	}
+	if t.codec != nil {
+		encoded, err := t.codec.Encode(value)
+		if err != nil {
+			return err
+		}
+		value = encoded
+	}
+	return convertDMapError(t.tx.Put(key, value, &pc))
+}
+
+func (t *embeddedTx) Delete(key string) error {
+	return convertDMapError(t.tx.Delete(key))
+}
+
+// Tx runs fn and commits its writes atomically on the partition owner. See
+// DMap.Tx for the details.
+func (dm *EmbeddedDMap) Tx(ctx context.Context, fn func(tx Tx) error) error {
+	return dm.client.observe(ctx, dm.command("Tx"), func(ctx context.Context, _ *ClientCommand) error {
+		return convertDMapError(dm.dm.Tx(ctx, func(tx *dmap.Tx) error {
+			return fn(&embeddedTx{tx: tx, codec: dm.client.codec})
+		}))
+	})
+}
+
+// IncrMany atomically adds the deltas to the integer values of the keys and
+// returns the new values.
+func (dm *EmbeddedDMap) IncrMany(ctx context.Context, deltas map[string]int) (map[string]int, error) {
+	keys := make([]string, 0, len(deltas))
+	for key := range deltas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var values map[string]int
+	err := dm.client.observe(ctx, dm.command("IncrMany", keys...), func(ctx context.Context, cmd *ClientCommand) error {
+		rewritten := make(map[string]int, len(deltas))
+		for i, key := range keys {
+			rewritten[cmd.Keys[i]] = deltas[key]
+		}
+		latest, err := dm.dm.IncrMany(ctx, rewritten)
+		if err != nil {
+			return convertDMapError(err)
+		}
+		values = make(map[string]int, len(latest))
+		for i, key := range keys {
+			values[key] = latest[cmd.Keys[i]]
+		}
+		return nil
+	})
+	return values, err
+}
+
+// HSet sets a field of the hash that is stored at key.
+func (dm *EmbeddedDMap) HSet(ctx context.Context, key, field string, value interface{}) error {
+	return convertDMapError(dm.dm.HSet(dm.client.context(ctx), key, field, value))
+}
+
+// HGet returns the value of a field of the hash that is stored at key.
+func (dm *EmbeddedDMap) HGet(ctx context.Context, key, field string) (*HashField, error) {
+	value, err := dm.dm.HGet(dm.client.context(ctx), key, field)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return &HashField{value: value}, nil
+}
+
+// HDel deletes the fields of the hash that is stored at key. The key is deleted
+// when the hash becomes empty.
+func (dm *EmbeddedDMap) HDel(ctx context.Context, key string, fields ...string) (int, error) {
+	deleted, err := dm.dm.HDel(dm.client.context(ctx), key, fields...)
+	return deleted, convertDMapError(err)
+}
+
+// HGetAll returns all the fields of the hash that is stored at key.
+func (dm *EmbeddedDMap) HGetAll(ctx context.Context, key string) (map[string]*HashField, error) {
+	fields, err := dm.dm.HGetAll(dm.client.context(ctx), key)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	result := make(map[string]*HashField, len(fields))
+	for field, value := range fields {
+		result[field] = &HashField{value: value}
+	}
+	return result, nil
+}
+
+// HIncrBy atomically adds delta to the integer value of a field.
+func (dm *EmbeddedDMap) HIncrBy(ctx context.Context, key, field string, delta int) (int, error) {
+	latest, err := dm.dm.HIncrBy(dm.client.context(ctx), key, field, delta)
+	return latest, convertDMapError(err)
 }
 
 // Delete deletes values for the given keys. Delete will not return error
 // if key doesn't exist. It's thread-safe. It is safe to modify the contents
 // of the argument after Delete returns.
 func (dm *EmbeddedDMap) Delete(ctx context.Context, keys ...string) (int, error) {
-	return dm.dm.Delete(ctx, keys...)
+	var (
+		count   int
+		deleted []string
+	)
+	err := dm.client.do(ctx, dm.command("Delete", keys...), func(ctx context.Context, cmd *ClientCommand) (err error) {
+		deleted = cmd.Keys
+		count, err = dm.dm.Delete(ctx, cmd.Keys...)
+		return convertDMapError(err)
+	})
+	if err != nil {
+		return count, err
+	}
+	if dm.reads != nil {
+		dm.reads.forget(deleted...)
+	}
+	if dm.mirror != nil {
+		dm.mirror.delete(keys)
+	}
+	return count, nil
+}
+
+// DeleteByTag deletes all entries that are stored with the given tag. Tag
+// indexes are kept on the partition owners, so every member is visited once.
+func (dm *EmbeddedDMap) DeleteByTag(ctx context.Context, tag string) (int, error) {
+	var count int
+	err := dm.client.observe(ctx, dm.command("DeleteByTag"), func(ctx context.Context, _ *ClientCommand) (err error) {
+		count, err = dm.dm.DeleteByTag(ctx, tag)
+		return convertDMapError(err)
+	})
+	return count, err
 }
 
 // Get gets the value for the given key. It returns ErrKeyNotFound if the DB
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value. See GetResponse for the details.
-func (dm *EmbeddedDMap) Get(ctx context.Context, key string) (*GetResponse, error) {
-	result, err := dm.dm.Get(ctx, key)
+func (dm *EmbeddedDMap) Get(ctx context.Context, key string, options ...GetOption) (*GetResponse, error) {
+	e, err := dm.GetEntry(ctx, key, options...)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetResponse, nil
+}
+
+// GetEntry is like Get, but it returns the metadata of the entry too. See
+// Entry for the details.
+func (dm *EmbeddedDMap) GetEntry(ctx context.Context, key string, options ...GetOption) (*Entry, error) {
+	cfg := getConfig{readFrom: dm.config.readFrom}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	var (
+		result *dmap.Entry
+		// observed is the key after the interceptors, it's the key that is read.
+		observed string
+	)
+	err := dm.client.do(ctx, dm.command("GetEntry", key), func(ctx context.Context, cmd *ClientCommand) (err error) {
+		key := cmd.Keys[0]
+		observed = key
+		switch {
+		case dm.config.linearizableReads:
+			result, err = dm.dm.LinearizableGetEntry(ctx, key)
+		case cfg.readFrom == BackupsOnly:
+			result, err = dm.dm.GetEntryFromBackup(ctx, key)
+		case cfg.readFrom == Nearest:
+			result, err = dm.dm.GetEntryFromNearest(ctx, key)
+		case dm.client.hedge != nil && dm.reads == nil:
+			result, err = dm.hedgedGetEntry(ctx, key)
+		default:
+			result, err = dm.dm.GetEntry(ctx, key)
+		}
+		return convertDMapError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dm.reads != nil {
+		if err = dm.reads.observe(observed, result.Timestamp()); err != nil {
+			return nil, err
+		}
+	}
+
+	gr := &GetResponse{entry: result.Entry, codec: dm.client.codec}
+	if result.Member == dm.member.String() {
+		// The replies of the other members are already copied from the wire.
+		gr = newPooledResponse(result.Entry, dm.client.codec)
+	}
+	return &Entry{
+		GetResponse: gr,
+		Key:         key,
+		Member:      result.Member,
+	}, nil
+}
+
+// Query returns the entries whose values match the filter, by key. See
+// DMap.Query for the filter syntax.
+func (dm *EmbeddedDMap) Query(ctx context.Context, filter string) (map[string]*GetResponse, error) {
+	var entries []storage.Entry
+	err := dm.client.do(ctx, dm.command("Query"), func(ctx context.Context, _ *ClientCommand) (err error) {
+		entries, err = dm.dm.Query(ctx, filter)
+		return convertDMapError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dm.queryResult(entries), nil
+}
+
+// QueryPage is like Query, but it returns the result in pages. See
+// config.Config.MaxResponseSize.
+func (dm *EmbeddedDMap) QueryPage(ctx context.Context, filter string, cursor uint64) (map[string]*GetResponse, uint64, error) {
+	var entries []storage.Entry
+	var next uint64
+	err := dm.client.do(ctx, dm.command("QueryPage"), func(ctx context.Context, _ *ClientCommand) (err error) {
+		entries, next, err = dm.dm.QueryPage(ctx, filter, cursor)
+		return convertDMapError(err)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return dm.queryResult(entries), next, nil
+}
+
+func (dm *EmbeddedDMap) queryResult(entries []storage.Entry) map[string]*GetResponse {
+	result := make(map[string]*GetResponse, len(entries))
+	for _, entry := range entries {
+		result[entry.Key()] = &GetResponse{
+			entry: entry,
+			codec: dm.client.codec,
+		}
+	}
+	return result
+}
+
+// Hottest returns the n most frequently read entries of the DMap. See
+// config.DMap.AccessSampleRate to enable the access statistics.
+func (dm *EmbeddedDMap) Hottest(ctx context.Context, n int) ([]AccessStats, error) {
+	items, err := dm.dm.Hottest(ctx, n)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
+	return toAccessStats(items), nil
+}
 
-	return &GetResponse{
-		entry: result,
+// Coldest returns the n least frequently read entries of the DMap. See
+// config.DMap.AccessSampleRate to enable the access statistics.
+func (dm *EmbeddedDMap) Coldest(ctx context.Context, n int) ([]AccessStats, error) {
+	items, err := dm.dm.Coldest(ctx, n)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return toAccessStats(items), nil
+}
+
+func toAccessStats(items []dmap.AccessStats) []AccessStats {
+	result := make([]AccessStats, 0, len(items))
+	for _, item := range items {
+		result = append(result, AccessStats{
+			Key:        item.Key,
+			Hits:       item.Hits,
+			LastAccess: time.Unix(0, item.LastAccess),
+		})
+	}
+	return result
+}
+
+// GetTombstone returns the tombstone of a deleted key. See
+// config.DMap.TombstoneRetention to enable the tombstones.
+func (dm *EmbeddedDMap) GetTombstone(ctx context.Context, key string) (*Tombstone, error) {
+	ts, err := dm.dm.GetTombstone(ctx, key)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return &Tombstone{
+		Key:       ts.Key,
+		DeletedAt: time.Unix(0, ts.DeletedAt),
+		DeletedBy: ts.DeletedBy,
+		Owner:     ts.Owner,
 	}, nil
 }
 
@@ -183,15 +564,84 @@ func (dm *EmbeddedDMap) Put(ctx context.Context, key string, value interface{},
 	for _, opt := range options {
 		opt(&pc)
 	}
-	err := dm.dm.Put(ctx, key, value, &pc)
+	err := dm.put(ctx, "Put", key, value, options, func(ctx context.Context, key string, value interface{}) error {
+		return dm.dm.Put(ctx, key, value, &pc)
+	})
 	if err != nil {
-		return nil, convertDMapError(err)
+		return nil, err
 	}
 	return &pc, nil
 }
 
+// SetAndPublish sets the value for the given key and publishes the message to
+// the channel. The partition owner publishes the message after the write is
+// committed. If the message cannot be published, the write is kept and the
+// error is returned. The delivery is at-least-once: retrying the call may
+// deliver the message more than once, and the readers may see the new value
+// before the message is delivered.
+func (dm *EmbeddedDMap) SetAndPublish(ctx context.Context, key string, value interface{}, channel, message string, options ...PutOption) error {
+	var pc dmap.PutConfig
+	for _, opt := range options {
+		opt(&pc)
+	}
+	return dm.put(ctx, "SetAndPublish", key, value, options, func(ctx context.Context, key string, value interface{}) error {
+		return dm.dm.SetAndPublish(ctx, key, value, channel, message, &pc)
+	})
+}
+
+// put encodes the value with the codec of the client, calls write with it and
+// the key that is set by the interceptors, and mirrors the original value, if
+// it's configured.
+func (dm *EmbeddedDMap) put(ctx context.Context, op, key string, value interface{},
+	options []PutOption, write func(ctx context.Context, key string, value interface{}) error) error {
+	original := value
+	if dm.client.codec != nil {
+		encoded, err := dm.client.codec.Encode(value)
+		if err != nil {
+			return err
+		}
+		value = encoded
+	}
+	err := dm.client.do(ctx, dm.command(op, key), func(ctx context.Context, cmd *ClientCommand) error {
+		return convertDMapError(write(ctx, cmd.Keys[0], value))
+	})
+	if err != nil {
+		return err
+	}
+	if dm.mirror != nil {
+		// The target encodes the value with its own codec.
+		dm.mirror.put(key, original, options)
+	}
+	return nil
+}
+
+// MirrorStats returns the counters of the mirrored writes. It returns a zero
+// value if the DMap is not created with the Mirror option.
+func (dm *EmbeddedDMap) MirrorStats() MirrorStats {
+	if dm.mirror == nil {
+		return MirrorStats{}
+	}
+	return dm.mirror.stats()
+}
+
 func (e *EmbeddedClient) NewDMap(name string, options ...DMapOption) (DMap, error) {
-	dm, err := e.db.dmap.NewDMap(name)
+	return e.newDMap(name, "", options...)
+}
+
+// newDMap creates a DMap instance after checking the token against the ACL of
+// its namespace.
+func (e *EmbeddedClient) newDMap(name, token string, options ...DMapOption) (DMap, error) {
+	readOnly, err := e.db.dmap.CheckNamespaceAccess(name, token)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+
+	var dm *dmap.DMap
+	if readOnly {
+		dm, err = e.db.dmap.NewReadOnlyDMap(name)
+	} else {
+		dm, err = e.db.dmap.NewDMap(name)
+	}
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
@@ -201,13 +651,20 @@ func (e *EmbeddedClient) NewDMap(name string, options ...DMapOption) (DMap, erro
 		opt(&dc)
 	}
 
-	return &EmbeddedDMap{
+	edm := &EmbeddedDMap{
 		config: &dc,
 		dm:     dm,
 		name:   name,
 		client: e,
 		member: e.db.rt.This(),
-	}, nil
+	}
+	if dc.monotonicReads {
+		edm.reads = newReadSession(readSessionSize)
+	}
+	if dc.mirrorTarget != nil {
+		edm.mirror = newMirror(dc.mirrorTarget, dc.mirrorSampleRate, e.db.log)
+	}
+	return edm, nil
 }
 
 // DeleteDMap deletes the DMap instance from the local process.
@@ -231,13 +688,17 @@ func (e *EmbeddedClient) Stats(ctx context.Context, address string, options ...S
 	}
 
 	if address == e.db.rt.This().String() {
-		return e.db.stats(cfg), nil
+		return e.db.stats(ctx, cfg)
 	}
 
 	statsCmd := protocol.NewStats()
 	if cfg.CollectRuntime {
 		statsCmd.SetCollectRuntime()
 	}
+	if cfg.NoPartitions {
+		statsCmd.SetNoPartitions()
+	}
+	statsCmd.SetDMaps(cfg.DMaps...)
 	cmd := statsCmd.Command(ctx)
 	rc := e.db.client.Get(address)
 	err := rc.Process(ctx, cmd)
@@ -260,6 +721,105 @@ func (e *EmbeddedClient) Stats(ctx context.Context, address string, options ...S
 	return s, nil
 }
 
+// StatsDelta returns the counters of the member that are changed since the
+// delta of the given cursor, see stats.Counters. The delta is computed on the
+// member, pass delta.Cursor to the next call. The zero cursor returns all the
+// counters. Apply the deltas to the counters with stats.Delta.Apply.
+func (e *EmbeddedClient) StatsDelta(ctx context.Context, address string, cursor stats.DeltaCursor, options ...StatsOption) (stats.Delta, error) {
+	if err := e.db.isOperable(); err != nil {
+		return stats.Delta{}, err
+	}
+	var cfg statsConfig
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	if address == "" {
+		address = e.db.rt.This().String()
+	}
+
+	if address == e.db.rt.This().String() {
+		return e.db.statsDelta(ctx, cfg, cursor)
+	}
+
+	statsCmd := protocol.NewStats()
+	if cfg.CollectRuntime {
+		statsCmd.SetCollectRuntime()
+	}
+	if cfg.NoPartitions {
+		statsCmd.SetNoPartitions()
+	}
+	statsCmd.SetDMaps(cfg.DMaps...)
+	statsCmd.SetDelta(cursor.Session, cursor.Sequence)
+	cmd := statsCmd.Command(ctx)
+	rc := e.db.client.Get(address)
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return stats.Delta{}, processProtocolError(err)
+	}
+
+	if err = cmd.Err(); err != nil {
+		return stats.Delta{}, processProtocolError(err)
+	}
+	data, err := cmd.Bytes()
+	if err != nil {
+		return stats.Delta{}, processProtocolError(err)
+	}
+	var delta stats.Delta
+	err = json.Unmarshal(data, &delta)
+	if err != nil {
+		return stats.Delta{}, processProtocolError(err)
+	}
+	return delta, nil
+}
+
+// SubscribeStats polls the stats delta of the member every interval and sends
+// the deltas with changes. The first delta contains all the counters. A
+// failed poll is logged and skipped. The channel is closed when ctx is done.
+func (e *EmbeddedClient) SubscribeStats(ctx context.Context, address string, interval time.Duration, options ...StatsOption) (<-chan stats.Delta, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be greater than zero")
+	}
+
+	ch := make(chan stats.Delta, 1)
+	e.db.wg.Add(1)
+	go func() {
+		defer e.db.wg.Done()
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var cursor stats.DeltaCursor
+		for {
+			delta, err := e.StatsDelta(ctx, address, cursor, options...)
+			if err != nil {
+				e.db.log.V(3).Printf("[ERROR] Failed to get stats from %s: %v", address, err)
+			} else {
+				cursor = delta.Cursor
+				if delta.Full || len(delta.Changed) > 0 || len(delta.Removed) > 0 {
+					select {
+					case ch <- delta:
+					case <-ctx.Done():
+						return
+					case <-e.db.ctx.Done():
+						return
+					}
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-e.db.ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
 // Close stops background routines and frees allocated resources.
 func (e *EmbeddedClient) Close(_ context.Context) error {
 	return nil
@@ -281,16 +841,86 @@ func (e *EmbeddedClient) RoutingTable(ctx context.Context) (RoutingTable, error)
 	return e.db.routingTable(ctx)
 }
 
-// Members returns a thread-safe list of cluster members.
+// hashTags returns true if the given DMap co-locates the keys with the same
+// hash tag.
+func (db *Olric) hashTags(dmap string) bool {
+	if db.config.DMaps == nil {
+		return false
+	}
+	return db.config.DMaps.Custom[dmap].HashTags
+}
+
+// GroupKeysByOwner fetches the latest routing table and groups the keys of the
+// given DMap by the names of their current primary owners. See
+// RoutingTable.GroupKeysByOwner.
+func (e *EmbeddedClient) GroupKeysByOwner(ctx context.Context, dmap string, keys ...string) (map[string][]string, error) {
+	rt, err := e.db.routingTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rt.GroupKeysByOwner(dmap, e.db.hashTags(dmap), keys...), nil
+}
+
+// LocateKey fetches the latest routing table and returns the partition of the
+// key in the given DMap and the owners of the partition. See
+// RoutingTable.LocateKey.
+func (e *EmbeddedClient) LocateKey(ctx context.Context, dmap, key string) (KeyLocation, error) {
+	rt, err := e.db.routingTable(ctx)
+	if err != nil {
+		return KeyLocation{}, err
+	}
+	return rt.LocateKey(dmap, key, e.db.hashTags(dmap)), nil
+}
+
+// HotKeys returns the n most frequently accessed keys of the given DMap. See
+// config.DMap.HotKeysWindow to enable the hot key tracking.
+func (e *EmbeddedClient) HotKeys(ctx context.Context, dmap string, n int) ([]HotKey, error) {
+	dm, err := e.db.dmap.NewDMap(dmap)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	items, err := dm.HotKeys(ctx, n)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	result := make([]HotKey, 0, len(items))
+	for _, item := range items {
+		result = append(result, HotKey{
+			Key:    item.Key,
+			QPS:    item.QPS,
+			PartID: item.PartID,
+			Owner:  item.Owner,
+		})
+	}
+	return result, nil
+}
+
+// ListDMaps returns the DMaps that have a fragment on any cluster member, sorted
+// by name. The entries are counted on the partition owners, and the policies
+// are the ones configured on this member. A DMap that is created but has no
+// entry is not listed.
+func (e *EmbeddedClient) ListDMaps(ctx context.Context) ([]DMapInfo, error) {
+	result, err := e.db.dmap.ListDMaps(ctx)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return result, nil
+}
+
+// Members returns a thread-safe list of cluster members with their tags and
+// health, as seen by this member.
 func (e *EmbeddedClient) Members(_ context.Context) ([]Member, error) {
 	members := e.db.rt.Discovery().GetMembers()
 	coordinator := e.db.rt.Discovery().GetCoordinator()
+	health := e.db.rt.Discovery().MemberHealth()
 	var result []Member
 	for _, member := range members {
 		m := Member{
 			Name:      member.Name,
 			ID:        member.ID,
 			Birthdate: member.Birthdate,
+			Tags:      member.Tags,
+			Health:    health[member.Name],
 		}
 		if coordinator.ID == member.ID {
 			m.Coordinator = true
@@ -305,9 +935,190 @@ func (e *EmbeddedClient) NewPubSub(options ...PubSubOption) (*PubSub, error) {
 	return newPubSub(e.db.client, options...)
 }
 
+// Watch returns an ordered stream of the mutations applied on the given DMap.
+// Pass an empty token to read the change logs from the oldest retained
+// mutation, or the Token of a ChangeEvent to resume right after it.
+// The change log has to be enabled with config.DMaps.ChangeLogSize.
+func (e *EmbeddedClient) Watch(ctx context.Context, name, token string) (*ChangeStream, error) {
+	if err := e.db.isOperable(); err != nil {
+		return nil, err
+	}
+	return e.db.watch(ctx, name, token)
+}
+
+// SubscribeLocalEvents returns a typed stream of the events of the partitions
+// that are owned by this member. Publishing never blocks the writers: at most
+// bufferSize events are kept for a slow receiver and the rest are dropped.
+// A buffer of 1024 events is used if bufferSize is zero or negative.
+func (e *EmbeddedClient) SubscribeLocalEvents(bufferSize int) *LocalEvents {
+	return &LocalEvents{sub: e.db.eventBus.Subscribe(bufferSize)}
+}
+
+// ClusterEvents returns a stream of the membership events seen by this member,
+// the nodes that join or leave the cluster and the routing table updates. The
+// stream is closed when ctx is done. At most bufferSize events are kept for a
+// slow receiver, a buffer of 1024 events is used if it's zero or negative.
+func (e *EmbeddedClient) ClusterEvents(ctx context.Context, bufferSize int) *ClusterEvents {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &ClusterEvents{
+		sub:    e.db.rt.MembershipEvents().Subscribe(bufferSize),
+		cancel: cancel,
+	}
+	go func() {
+		<-ctx.Done()
+		c.sub.Close()
+	}()
+	return c
+}
+
+// ReloadConfig applies the reloadable subset of the given configuration to
+// this node without restarting it: log level, DMap TTL and eviction limits,
+// client timeouts, quorum sizes and rebalancing throttles. It returns ErrImmutableConfig if c modifies
+// a field like PartitionCount, and keeps the current configuration on error.
+// If ctx is done before the configuration is applied, ctx.Err() is returned.
+func (e *EmbeddedClient) ReloadConfig(ctx context.Context, c *config.Config) error {
+	return e.db.reloadConfig(ctx, c)
+}
+
+// PauseRebalancing stops moving partitions between the cluster members until
+// ResumeRebalancing is called. Transfers that are in progress are completed.
+// Members that join the cluster later are not paused.
+func (e *EmbeddedClient) PauseRebalancing(ctx context.Context) error {
+	return e.db.broadcastStatusCommand(ctx, func() *redis.StatusCmd {
+		return protocol.NewPauseRebalancing().Command(ctx)
+	})
+}
+
+// ResumeRebalancing lets the cluster members move partitions again.
+func (e *EmbeddedClient) ResumeRebalancing(ctx context.Context) error {
+	return e.db.broadcastStatusCommand(ctx, func() *redis.StatusCmd {
+		return protocol.NewResumeRebalancing().Command(ctx)
+	})
+}
+
+// MovePartition assigns the primary copy of the partition to the given member,
+// e.g. "127.0.0.1:3320". The data is moved in the background by the balancer,
+// see RebalanceStatus. The placement is kept in the routing table.
+func (e *EmbeddedClient) MovePartition(ctx context.Context, partID uint64, member string) error {
+	return convertClusterError(e.db.movePartition(ctx, partID, member))
+}
+
+// Drain migrates the primary copies and the backups of the partitions off the
+// given member, before taking it down for maintenance. It returns after the
+// data is moved, or a *ProgressError if ctx is canceled before. The drained
+// state is kept in the routing table, so it survives a coordinator change.
+func (e *EmbeddedClient) Drain(ctx context.Context, member string) error {
+	return convertClusterError(e.db.drain(ctx, member))
+}
+
+// Undo restores a destroyed DMap from the snapshots that are taken by Destroy,
+// and returns the number of restored entries. The entries that are written
+// after Destroy are kept. It returns ErrSnapshotNotFound if there is no
+// snapshot, the snapshots are kept for config.DMaps.DestroySnapshotRetention.
+func (e *EmbeddedClient) Undo(ctx context.Context, name string) (int, error) {
+	count, err := e.db.dmap.Undo(ctx, name)
+	return count, convertDMapError(err)
+}
+
+// RebalanceStatus returns the balancer status of every cluster member,
+// by member name.
+func (e *EmbeddedClient) RebalanceStatus(ctx context.Context) (map[string]RebalanceStatus, error) {
+	return e.db.clusterRebalanceStatus(ctx)
+}
+
+// SlowLog returns the latest n slow commands of the cluster members, the newest
+// one comes first. It returns all the recorded commands if n is zero. The
+// commands that the embedded clients run on their own member are not recorded,
+// the slow log only covers the commands served over the network. See
+// config.Config.SlowLogThreshold.
+func (e *EmbeddedClient) SlowLog(ctx context.Context, n int) ([]SlowLogEntry, error) {
+	return e.db.clusterSlowLog(ctx, n)
+}
+
+// OwnershipHistory returns the recent partition ownership changes, oldest
+// first. The history is kept by the cluster coordinator and it starts over
+// when the coordinator changes. See config.Config.OwnershipHistorySize.
+func (e *EmbeddedClient) OwnershipHistory(ctx context.Context, partIDs ...uint64) ([]OwnershipChange, error) {
+	return e.db.ownershipHistory(ctx, partIDs...)
+}
+
+// Compact compacts the storage engines of the DMaps on every cluster member
+// and returns the number of moved entries. The compaction worker only compacts
+// the tables with a garbage ratio above the garbageRatio option of the storage
+// engine, see config.Engine. Compact reclaims all garbage at once, and it
+// blocks the writes on a fragment while it's compacted.
+func (e *EmbeddedClient) Compact(ctx context.Context) (int, error) {
+	return e.db.compact(ctx)
+}
+
+// Migrate copies the DMaps to the cluster of the target member and returns the
+// number of the copied entries. The target cluster places the keys by its own
+// partition count, so a cluster is moved to a new PartitionCount by starting a
+// new cluster and migrating the DMaps into it. The DMaps have to be created on
+// the members of the target cluster. The entries keep their TTLs and
+// timestamps, the tags are not copied. The writes during the migration may be
+// missed, they should be stopped or Migrate should be called again.
+func (e *EmbeddedClient) Migrate(ctx context.Context, target string) (int, error) {
+	count, err := e.db.dmap.Migrate(ctx, target)
+	return count, convertDMapError(err)
+}
+
+// RegisterEntryProcessor registers an entry processor on this member. The
+// processors run on the owners of the keys, so every member of the cluster
+// has to register the same processors with the same names.
+func (e *EmbeddedClient) RegisterEntryProcessor(name string, p EntryProcessor) {
+	e.db.dmap.RegisterProcessor(name, p)
+}
+
+// RegisterKeyMigrationListener registers a listener that is notified when the
+// keys of the DMaps leave or arrive on this member while the cluster is
+// rebalanced. The embedded applications use it to keep their local state
+// derived from the keys, like indexes, consistent. A listener that was
+// registered with the same name is replaced.
+func (e *EmbeddedClient) RegisterKeyMigrationListener(name string, l KeyMigrationListener) {
+	e.db.dmap.RegisterKeyMigrationListener(name, l)
+}
+
+// UnregisterKeyMigrationListener removes the listener with the given name.
+func (e *EmbeddedClient) UnregisterKeyMigrationListener(name string) {
+	e.db.dmap.UnregisterKeyMigrationListener(name)
+}
+
+// RetentionReports returns the results of the last retention runs on this
+// member, one report per DMap with a retention policy. The janitor only
+// processes the partitions owned by this member. See config.DMap.RetentionMaxAge
+// and config.DMap.RetentionMaxEntriesPerMember.
+func (e *EmbeddedClient) RetentionReports() []RetentionReport {
+	return e.db.dmap.RetentionReports()
+}
+
 // NewEmbeddedClient creates and returns a new EmbeddedClient instance.
-func (db *Olric) NewEmbeddedClient() *EmbeddedClient {
-	return &EmbeddedClient{db: db}
+func (db *Olric) NewEmbeddedClient(options ...EmbeddedClientOption) *EmbeddedClient {
+	var cfg embeddedClientConfig
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	if cfg.maxInflightPuts <= 0 {
+		cfg.maxInflightPuts = DefaultMaxInflightPuts
+	}
+	return &EmbeddedClient{
+		db:           db,
+		codec:        cfg.codec,
+		retry:        cfg.retry,
+		inflightPuts: make(chan struct{}, cfg.maxInflightPuts),
+		metrics:      newClientMetrics(),
+		hedge:        cfg.hedge,
+		interceptors: cfg.interceptors,
+		id:           fmt.Sprintf("embedded-%d", atomic.AddUint64(&embeddedClientSeq, 1)),
+	}
+}
+
+var embeddedClientSeq uint64
+
+// context returns a copy of ctx that identifies the client to the DMaps, so
+// every EmbeddedClient has its own budget in the per-client rate limits.
+func (e *EmbeddedClient) context(ctx context.Context) context.Context {
+	return dmap.WithRateLimitID(ctx, e.id)
 }
 
 var (
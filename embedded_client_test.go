@@ -16,10 +16,15 @@ package olric
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -41,7 +46,7 @@ func TestEmbeddedClient_DMap_Put(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(context.Background(), "mykey", "myvalue")
+	_, err = dm.Put(context.Background(), "mykey", "myvalue")
 	require.NoError(t, err)
 }
 
@@ -54,7 +59,7 @@ func TestEmbeddedClient_DMap_Put_EX(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue", EX(time.Second))
+	_, err = dm.Put(ctx, "mykey", "myvalue", EX(time.Second))
 	require.NoError(t, err)
 
 	<-time.After(time.Second)
@@ -72,7 +77,7 @@ func TestEmbeddedClient_DMap_Put_PX(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue", PX(time.Millisecond))
+	_, err = dm.Put(ctx, "mykey", "myvalue", PX(time.Millisecond))
 	require.NoError(t, err)
 
 	<-time.After(time.Millisecond)
@@ -90,7 +95,7 @@ func TestEmbeddedClient_DMap_Put_EXAT(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue", EXAT(time.Duration(time.Now().Add(time.Second).UnixNano())))
+	_, err = dm.Put(ctx, "mykey", "myvalue", EXAT(time.Duration(time.Now().Add(time.Second).UnixNano())))
 	require.NoError(t, err)
 
 	<-time.After(time.Second)
@@ -108,7 +113,7 @@ func TestEmbeddedClient_DMap_Put_PXAT(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue", PXAT(time.Duration(time.Now().Add(time.Millisecond).UnixNano())))
+	_, err = dm.Put(ctx, "mykey", "myvalue", PXAT(time.Duration(time.Now().Add(time.Millisecond).UnixNano())))
 	require.NoError(t, err)
 
 	<-time.After(time.Millisecond)
@@ -117,6 +122,83 @@ func TestEmbeddedClient_DMap_Put_PXAT(t *testing.T) {
 	require.ErrorIs(t, err, ErrKeyNotFound)
 }
 
+func TestEmbeddedClient_DMap_Put_TTLJitter(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db1.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ttls := make(map[int64]struct{})
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		_, err = dm.Put(ctx, key, "myvalue", EX(time.Hour), TTLJitter(50))
+		require.NoError(t, err)
+
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		require.LessOrEqual(t, gr.TTL(), time.Now().Add(time.Hour).UnixNano()/1000000)
+		ttls[gr.TTL()] = struct{}{}
+	}
+	require.Greater(t, len(ttls), 1)
+}
+
+func TestEmbeddedClient_DMap_Put_SlidingExpiration(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db1.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), "myvalue", SlidingExpiration(time.Second))
+		require.NoError(t, err)
+	}
+
+	// The TTL is reset after a hundredth of the sliding expiration.
+	<-time.After(50 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		gr, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		// The TTL is reset by the read.
+		require.GreaterOrEqual(t, gr.TTL(), time.Now().Add(time.Second).UnixNano()/1000000-5)
+	}
+}
+
+func TestEmbeddedClient_DMap_RateLimits_PerClient(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+		RateLimits: []config.RateLimit{{WritesPerSecond: 5, PerClient: true}},
+	}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	dm1, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = dm1.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+	_, err = dm1.Put(ctx, testutil.ToKey(5), 5)
+	require.ErrorIs(t, err, ErrRateLimited)
+
+	// Every EmbeddedClient has its own budget.
+	_, err = dm2.Put(ctx, testutil.ToKey(5), 5)
+	require.NoError(t, err)
+}
+
 func TestEmbeddedClient_DMap_Put_NX(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -126,7 +208,7 @@ func TestEmbeddedClient_DMap_Put_NX(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue", NX())
+	_, err = dm.Put(ctx, "mykey", "myvalue", NX())
 	require.NoError(t, err)
 
 	<-time.After(time.Millisecond)
@@ -144,7 +226,7 @@ func TestEmbeddedClient_DMap_Put_XX(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue", XX())
+	_, err = dm.Put(ctx, "mykey", "myvalue", XX())
 	require.ErrorIs(t, err, ErrKeyNotFound)
 }
 
@@ -156,7 +238,7 @@ func TestEmbeddedClient_DMap_Get(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(context.Background(), "mykey", "myvalue")
+	_, err = dm.Put(context.Background(), "mykey", "myvalue")
 	require.NoError(t, err)
 
 	gr, err := dm.Get(context.Background(), "mykey")
@@ -167,6 +249,112 @@ func TestEmbeddedClient_DMap_Get(t *testing.T) {
 	require.Equal(t, "myvalue", value)
 }
 
+func TestEmbeddedClient_DMap_GetEntry(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "mykey", "myvalue", TS(100), EX(time.Hour))
+	require.NoError(t, err)
+
+	entry, err := dm.GetEntry(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, "mykey", entry.Key)
+	require.Equal(t, db.name, entry.Member)
+	require.Equal(t, int64(100), entry.Timestamp())
+	require.Greater(t, entry.TTL(), time.Now().UnixNano()/1e6)
+
+	value, err := entry.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+
+	// The last access time is updated by the previous read.
+	entry, err = dm.GetEntry(ctx, "mykey")
+	require.NoError(t, err)
+	require.NotZero(t, entry.LastAccess())
+
+	_, err = dm.GetEntry(ctx, "unknown")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEmbeddedClient_DMap_Get_MonotonicReads(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap", MonotonicReads())
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "mykey", "newer", TS(200))
+	require.NoError(t, err)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, int64(200), gr.Timestamp())
+
+	// Simulates a replica that has an older version of the key.
+	_, err = dm.Put(ctx, "mykey", "older", TS(100))
+	require.NoError(t, err)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrStaleRead)
+
+	// DMap instances without MonotonicReads are not affected.
+	other, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = other.Get(ctx, "mykey")
+	require.NoError(t, err)
+
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+	_, err = dm.Put(ctx, "mykey", "older", TS(100))
+	require.NoError(t, err)
+	_, err = dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+}
+
+func TestEmbeddedClient_DMap_Get_LinearizableReads(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap", LinearizableReads())
+	require.NoError(t, err)
+	dm2, err := db2.NewEmbeddedClient().NewDMap("mydmap", LinearizableReads())
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	var members = make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		entry, err := dm.GetEntry(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := entry.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+		members[entry.Member] = struct{}{}
+	}
+	// The reads are served by both members, some of them are redirected.
+	require.Len(t, members, 2)
+
+	_, err = dm.Get(ctx, "unknown")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+}
+
 func TestEmbeddedClient_DMap_Delete(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -175,7 +363,7 @@ func TestEmbeddedClient_DMap_Delete(t *testing.T) {
 	dm, err := e.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(context.Background(), "mykey", "myvalue")
+	_, err = dm.Put(context.Background(), "mykey", "myvalue")
 	require.NoError(t, err)
 
 	count, err := dm.Delete(context.Background(), "mykey")
@@ -197,7 +385,7 @@ func TestEmbeddedClient_DMap_Delete_Many_Keys(t *testing.T) {
 	var keys []string
 	for i := 0; i < 10; i++ {
 		key := testutil.ToKey(i)
-		err = dm.Put(context.Background(), key, "myvalue")
+		_, err = dm.Put(context.Background(), key, "myvalue")
 		require.NoError(t, err)
 		keys = append(keys, key)
 	}
@@ -216,7 +404,7 @@ func TestEmbeddedClient_DMap_Expire(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
-	err = dm.Put(ctx, "mykey", "myvalue")
+	_, err = dm.Put(ctx, "mykey", "myvalue")
 	require.NoError(t, err)
 
 	err = dm.Expire(ctx, "mykey", time.Millisecond)
@@ -238,7 +426,7 @@ func TestEmbeddedClient_DMap_Destroy(t *testing.T) {
 
 	ctx := context.Background()
 	for i := 0; i < 100; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
@@ -257,6 +445,39 @@ func TestEmbeddedClient_DMap_Destroy(t *testing.T) {
 	require.Greater(t, 100, total)
 }
 
+func TestEmbeddedClient_Undo(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.DestroySnapshotRetention = time.Hour
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	err = dm.Destroy(ctx)
+	require.NoError(t, err)
+
+	count, err := e.Undo(ctx, "mydmap")
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+
+	gr, err := dm.Get(ctx, testutil.ToKey(1))
+	require.NoError(t, err)
+	value, err := gr.Int()
+	require.NoError(t, err)
+	require.Equal(t, 1, value)
+
+	_, err = e.Undo(ctx, "mydmap")
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
 func TestEmbeddedClient_DMap_Lock(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -293,6 +514,66 @@ func TestEmbeddedClient_DMap_Lock_ErrLockNotAcquired(t *testing.T) {
 	require.ErrorIs(t, err, ErrLockNotAcquired)
 }
 
+func TestEmbeddedClient_DMap_Lock_WithBlocking(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := "lock.key.test"
+
+	lx, err := dm.Lock(ctx, key, time.Second)
+	require.NoError(t, err)
+
+	go func() {
+		<-time.After(100 * time.Millisecond)
+		require.NoError(t, lx.Unlock(ctx))
+	}()
+
+	// The deadline is ignored, it waits until the lock is released.
+	lx, err = dm.Lock(ctx, key, time.Millisecond, WithBlocking())
+	require.NoError(t, err)
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = dm.Lock(cctx, key, 0, WithBlocking())
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+
+	require.NoError(t, lx.Unlock(ctx))
+}
+
+func TestEmbeddedClient_DMap_LockInfo(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := "lock.key.test"
+
+	_, err = dm.LockInfo(ctx, key)
+	require.ErrorIs(t, err, ErrNoSuchLock)
+
+	lx, err := dm.LockWithTimeout(ctx, key, time.Minute, time.Second)
+	require.NoError(t, err)
+
+	info, err := dm.LockInfo(ctx, key)
+	require.NoError(t, err)
+	sum := sha256.Sum256(lx.(*EmbeddedLockContext).token)
+	require.Equal(t, hex.EncodeToString(sum[:]), info.TokenHash)
+	require.WithinDuration(t, time.Now(), info.AcquiredAt, time.Second)
+	require.Greater(t, info.TTL, 59*time.Second)
+
+	require.NoError(t, lx.Unlock(ctx))
+	_, err = dm.LockInfo(ctx, key)
+	require.ErrorIs(t, err, ErrNoSuchLock)
+}
+
 func TestEmbeddedClient_DMap_Lock_ErrNoSuchLock(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -472,6 +753,120 @@ func TestEmbeddedClient_RoutingTable_Cluster(t *testing.T) {
 	require.Len(t, owners, 3)
 }
 
+func TestEmbeddedClient_RoutingTable_RouteOfTag(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	rt, err := e.RoutingTable(context.Background())
+	require.NoError(t, err)
+
+	partID, route := rt.RouteOfTag("user:1")
+	hkey := partitions.TaggedHKey("mydmap", "{user:1}.profile", db.config.PartitionCount)
+	require.Equal(t, db.primary.PartitionIDByHKey(hkey), partID)
+	require.Equal(t, []string{db.rt.OwnerOfTag("user:1").String()}, route.PrimaryOwners)
+}
+
+func TestEmbeddedClient_GroupKeysByOwner(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	var keys []string
+	for i := 0; i < 100; i++ {
+		keys = append(keys, testutil.ToKey(i))
+	}
+	keys = append(keys, "{user:1}.profile", "{user:1}.settings")
+
+	groups, err := e.GroupKeysByOwner(context.Background(), "mydmap", keys...)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	var total int
+	for owner, ownedKeys := range groups {
+		total += len(ownedKeys)
+		for _, key := range ownedKeys {
+			hkey := partitions.HKey("mydmap", key)
+			require.Equal(t, db.primary.PartitionByHKey(hkey).Owner().String(), owner)
+		}
+	}
+	require.Equal(t, len(keys), total)
+}
+
+func TestEmbeddedClient_LocateKey_HashTags(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"tagged": {HashTags: true}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	e := db.NewEmbeddedClient()
+	ctx := context.Background()
+	partID := partitions.TagPartitionID("user:1", c.PartitionCount)
+	for _, key := range []string{"{user:1}.profile", "{user:1}.settings"} {
+		loc, err := e.LocateKey(ctx, "tagged", key)
+		require.NoError(t, err)
+		require.Equal(t, partID, loc.PartitionID)
+
+		// The hash tags are ignored by the other DMaps.
+		loc, err = e.LocateKey(ctx, "mydmap", key)
+		require.NoError(t, err)
+		require.Equal(t, db.primary.PartitionIDByHKey(partitions.HKey("mydmap", key)), loc.PartitionID)
+	}
+}
+
+func TestEmbeddedClient_LocateKey(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.ReplicaCount = 2
+	db := cluster.addMemberWithConfig(t, c, "")
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	cluster.addMemberWithConfig(t, c2, "")
+
+	e := db.NewEmbeddedClient()
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		loc, err := e.LocateKey(ctx, "mydmap", key)
+		require.NoError(t, err)
+
+		hkey := partitions.HKey("mydmap", key)
+		require.Equal(t, db.primary.PartitionIDByHKey(hkey), loc.PartitionID)
+		require.Equal(t, db.primary.PartitionByHKey(hkey).Owner().String(), loc.PrimaryOwner)
+		require.Empty(t, loc.PreviousOwners)
+		require.Len(t, loc.BackupOwners, 1)
+		require.NotEqual(t, loc.PrimaryOwner, loc.BackupOwners[0])
+	}
+}
+
+func TestEmbeddedClient_ListDMaps(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	ctx := context.Background()
+	for _, name := range []string{"foo", "bar"} {
+		_, err := db2.NewEmbeddedClient().NewDMap(name)
+		require.NoError(t, err)
+		dm, err := e.NewDMap(name)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			_, err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i))
+			require.NoError(t, err)
+		}
+	}
+
+	list, err := e.ListDMaps(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "bar", list[0].Name)
+	require.Equal(t, 10, list[0].Entries)
+	require.Equal(t, "foo", list[1].Name)
+	require.Equal(t, 10, list[1].Entries)
+}
+
 func TestEmbeddedClient_Member(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -494,6 +889,48 @@ func TestEmbeddedClient_Member(t *testing.T) {
 	}
 }
 
+func TestEmbeddedClient_Members_Tags(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c1 := testutil.NewConfig()
+	c1.MemberTags = map[string]string{"role": "cache", "zone": "a"}
+	db1 := cluster.addMemberWithConfig(t, c1, "")
+
+	c2 := testutil.NewConfig()
+	c2.MemberTags = map[string]string{"role": "analytics"}
+	db2 := cluster.addMemberWithConfig(t, c2, "")
+
+	e := db2.NewEmbeddedClient()
+	ctx := context.Background()
+	members, err := e.Members(ctx)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	for _, member := range members {
+		require.Equal(t, "alive", member.Health)
+		if member.Name == db1.rt.This().String() {
+			require.Equal(t, c1.MemberTags, member.Tags)
+		} else {
+			require.Equal(t, c2.MemberTags, member.Tags)
+		}
+	}
+
+	// The routing table is fetched from the coordinator.
+	rt, err := e.RoutingTable(ctx)
+	require.NoError(t, err)
+	for _, route := range rt {
+		for _, owner := range route.PrimaryOwners {
+			if owner == db1.rt.This().String() {
+				require.Equal(t, c1.MemberTags, route.Tags[owner])
+			} else {
+				require.Equal(t, c2.MemberTags, route.Tags[owner])
+			}
+		}
+	}
+
+	st, err := e.Stats(ctx, db1.rt.This().String())
+	require.NoError(t, err)
+	require.Equal(t, c1.MemberTags, st.Member.Tags)
+}
+
 func TestEmbeddedClient_Ping(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -516,3 +953,498 @@ func TestEmbeddedClient_Ping_WithMessage(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, message, response)
 }
+
+func TestEmbeddedClient_DMap_MultiLock(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	keys := []string{"lock.key.foo", "lock.key.bar"}
+
+	lx, err := dm.MultiLock(ctx, keys, time.Second)
+	require.NoError(t, err)
+
+	_, err = dm.Lock(ctx, "lock.key.bar", time.Millisecond)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+
+	require.NoError(t, lx.Lease(ctx, time.Minute))
+	require.NoError(t, lx.Unlock(ctx))
+
+	err = lx.Unlock(ctx)
+	require.ErrorIs(t, err, ErrNoSuchLock)
+}
+
+func TestEmbeddedClient_DMap_MultiLock_Lease(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	lx, err := dm.MultiLock(ctx, []string{"lock.key.foo", "lock.key.bar"}, time.Second)
+	require.NoError(t, err)
+
+	tokens := lx.(*EmbeddedMultiLockContext).tokens
+	require.NoError(t, dm.(*EmbeddedDMap).dm.Unlock(ctx, "lock.key.bar", tokens["lock.key.bar"]))
+
+	// The lock of lock.key.foo is extended even though lock.key.bar fails.
+	err = lx.Lease(ctx, 50*time.Millisecond)
+	require.ErrorIs(t, err, ErrNoSuchLock)
+
+	<-time.After(100 * time.Millisecond)
+	_, err = dm.Lock(ctx, "lock.key.foo", time.Millisecond)
+	require.NoError(t, err)
+}
+
+func TestEmbeddedClient_DMap_IncrMany(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "counter-1", 10)
+	require.NoError(t, err)
+
+	values, err := dm.IncrMany(ctx, map[string]int{"counter-1": 5, "counter-2": -3})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"counter-1": 15, "counter-2": -3}, values)
+
+	gr, err := dm.Get(ctx, "counter-1")
+	require.NoError(t, err)
+	value, err := gr.Int()
+	require.NoError(t, err)
+	require.Equal(t, 15, value)
+
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+	_, err = dm.IncrMany(ctx, map[string]int{"mykey": 1})
+	require.ErrorIs(t, err, ErrNotInteger)
+}
+
+func TestEmbeddedClient_DMap_Hash(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.HSet(ctx, "user-1", "name", "alice"))
+	require.NoError(t, dm2.HSet(ctx, "user-1", "age", 30))
+
+	age, err := dm2.HIncrBy(ctx, "user-1", "age", 1)
+	require.NoError(t, err)
+	require.Equal(t, 31, age)
+
+	field, err := dm.HGet(ctx, "user-1", "name")
+	require.NoError(t, err)
+	name, err := field.String()
+	require.NoError(t, err)
+	require.Equal(t, "alice", name)
+
+	fields, err := dm2.HGetAll(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, fields, 2)
+	age, err = fields["age"].Int()
+	require.NoError(t, err)
+	require.Equal(t, 31, age)
+
+	deleted, err := dm.HDel(ctx, "user-1", "name")
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+
+	_, err = dm.HGet(ctx, "user-1", "name")
+	require.ErrorIs(t, err, ErrFieldNotFound)
+
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+	_, err = dm.HGet(ctx, "mykey", "name")
+	require.ErrorIs(t, err, ErrNotHash)
+}
+
+func TestEmbeddedClient_DMap_Execute(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	e.RegisterEntryProcessor("swap", func(entry *ProcessorEntry, args []byte) ([]byte, error) {
+		previous := entry.Value
+		entry.SetValue(args)
+		return previous, nil
+	})
+
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "mykey", []byte("old"))
+	require.NoError(t, err)
+
+	previous, err := dm.Execute(ctx, "mykey", "swap", []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("old"), previous)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.Byte()
+	require.NoError(t, err)
+	require.Equal(t, []byte("new"), value)
+
+	_, err = dm.Execute(ctx, "mykey", "unknown", nil)
+	require.ErrorIs(t, err, ErrProcessorNotFound)
+}
+
+func TestEmbeddedClient_DMap_Hottest_Coldest(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {AccessSampleRate: 1}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+		for j := 0; j < i; j++ {
+			_, err = dm.Get(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+		}
+	}
+
+	hottest, err := dm.Hottest(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, hottest, 1)
+	require.Equal(t, testutil.ToKey(4), hottest[0].Key)
+	require.Equal(t, int64(4), hottest[0].Hits)
+	require.False(t, hottest[0].LastAccess.IsZero())
+
+	coldest, err := dm.Coldest(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, coldest, 1)
+	require.Equal(t, testutil.ToKey(0), coldest[0].Key)
+
+	other, err := e.NewDMap("other")
+	require.NoError(t, err)
+	_, err = other.Hottest(ctx, 1)
+	require.ErrorIs(t, err, ErrAccessStatsDisabled)
+}
+
+func TestEmbeddedClient_HotKeys(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {HotKeysWindow: time.Minute}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The key i is written once and read i times.
+	for i := 0; i < 5; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+		for j := 0; j < i; j++ {
+			_, err = dm.Get(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+		}
+	}
+
+	hotKeys, err := e.HotKeys(ctx, "mydmap", 2)
+	require.NoError(t, err)
+	require.Len(t, hotKeys, 2)
+	require.Equal(t, testutil.ToKey(4), hotKeys[0].Key)
+	require.Equal(t, testutil.ToKey(3), hotKeys[1].Key)
+	require.Greater(t, hotKeys[0].QPS, hotKeys[1].QPS)
+	require.Equal(t, db.rt.This().String(), hotKeys[0].Owner)
+
+	_, err = e.HotKeys(ctx, "other", 2)
+	require.ErrorIs(t, err, ErrHotKeysDisabled)
+}
+
+func TestEmbeddedClient_DMap_Query(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	type user struct {
+		Name string `msgpack:"name"`
+		Age  int    `msgpack:"age"`
+	}
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient(WithCodec(NewMsgpackCodec()))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), user{Name: testutil.ToKey(i), Age: i * 10})
+		require.NoError(t, err)
+	}
+
+	result, err := dm.Query(ctx, "age >= 50 AND age < 80")
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+	for i := 5; i < 8; i++ {
+		gr, ok := result[testutil.ToKey(i)]
+		require.True(t, ok)
+		var u user
+		require.NoError(t, gr.Scan(&u))
+		require.Equal(t, i*10, u.Age)
+	}
+
+	_, err = dm.Query(ctx, "age ~ 50")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestEmbeddedClient_DMap_Query_CommandTimeout(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	c := testutil.NewConfig()
+	c.CommandTimeouts = map[string]time.Duration{"DM.QUERYPAGE": time.Nanosecond}
+	db2 := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	_, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	e := db.NewEmbeddedClient(WithCodec(NewJSONCodec()))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), map[string]int{"age": i})
+		require.NoError(t, err)
+	}
+
+	// The query is aborted on the second member.
+	_, err = dm.Query(ctx, "age >= 50")
+	require.ErrorIs(t, err, ErrDeadlineExceeded)
+}
+
+func TestEmbeddedClient_DMap_QueryPage(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.MaxResponseSize = 16
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient(WithCodec(NewJSONCodec()))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), map[string]int{"id": i})
+		require.NoError(t, err)
+	}
+
+	_, err = dm.Query(ctx, "id >= 0")
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	total := make(map[string]*GetResponse)
+	var cursor uint64
+	for {
+		var page map[string]*GetResponse
+		page, cursor, err = dm.QueryPage(ctx, "id >= 0", cursor)
+		require.NoError(t, err)
+		for key, gr := range page {
+			total[key] = gr
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	require.Len(t, total, 20)
+}
+
+func TestEmbeddedClient_GetTombstone(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.TombstoneRetention = time.Hour
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.GetTombstone(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+
+	ts, err := dm.GetTombstone(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, "mykey", ts.Key)
+	require.Equal(t, db.rt.This().String(), ts.DeletedBy)
+	require.Equal(t, db.rt.This().String(), ts.Owner)
+	require.False(t, ts.DeletedAt.IsZero())
+
+	// Writing the key again forgets the tombstone.
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+	_, err = dm.GetTombstone(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEmbeddedClient_Compact(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 100; i += 2 {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	moved, err := e.Compact(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 50, moved)
+
+	for i := 1; i < 100; i += 2 {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+}
+
+func TestEmbeddedClient_DMap_Tx(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {HashTags: true}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "{account:1}.checking", 100)
+	require.NoError(t, err)
+
+	err = dm.Tx(ctx, func(tx Tx) error {
+		gr, err := tx.Get("{account:1}.checking")
+		if err != nil {
+			return err
+		}
+		balance, err := gr.Int()
+		if err != nil {
+			return err
+		}
+		if err = tx.Put("{account:1}.checking", balance-40); err != nil {
+			return err
+		}
+		return tx.Put("{account:1}.savings", 40)
+	})
+	require.NoError(t, err)
+
+	for key, expected := range map[string]int{"{account:1}.checking": 60, "{account:1}.savings": 40} {
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		balance, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, expected, balance)
+	}
+
+	err = dm.Tx(ctx, func(tx Tx) error {
+		for i := 0; i < 10; i++ {
+			if err := tx.Delete(testutil.ToKey(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, ErrCrossPartitionTx)
+
+	err = dm.Tx(ctx, func(tx Tx) error {
+		return tx.Put("{account:1}.session", "token", EX(time.Hour))
+	})
+	require.NoError(t, err)
+	gr, err := dm.Get(ctx, "{account:1}.session")
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), time.UnixMilli(gr.TTL()), time.Minute)
+
+	err = dm.Tx(ctx, func(tx Tx) error {
+		return tx.Put("{account:1}.session", "token", NX())
+	})
+	require.ErrorIs(t, err, ErrTxPutOption)
+}
+
+func TestEmbeddedClient_SlowLog(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c1 := testutil.NewConfig()
+	c1.SlowLogThreshold = time.Nanosecond
+	db1 := cluster.addMemberWithConfig(t, c1, "")
+
+	c2 := testutil.NewConfig()
+	c2.SlowLogThreshold = time.Nanosecond
+	db2 := cluster.addMemberWithConfig(t, c2, "")
+
+	e := db1.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Some of the keys are owned by the other member, they are served by its
+	// RESP server.
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	entries, err := e.SlowLog(ctx, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	var puts int
+	for i, entry := range entries {
+		if i > 0 {
+			require.LessOrEqual(t, entry.Timestamp, entries[i-1].Timestamp)
+		}
+		if entry.Command != protocol.DMap.Put {
+			continue
+		}
+		puts++
+		require.Equal(t, "mydmap", entry.DMap)
+		require.NotZero(t, entry.KeyHash)
+		require.Equal(t, db2.rt.This().String(), entry.Member)
+	}
+	require.NotZero(t, puts)
+
+	entries, err = e.SlowLog(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	s, err := e.Stats(ctx, db2.rt.This().String())
+	require.NoError(t, err)
+	require.Equal(t, int64(puts), s.CommandLatencies[protocol.DMap.Put].Count)
+}
@@ -0,0 +1,267 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// EmbeddedIterator implements Iterator for EmbeddedDMap. It scans every owner
+// of a partition, including the previous owners, so the keys that are being
+// moved by the rebalancer are not missed. The owners are resolved again for
+// every page, a new owner is scanned from the beginning and the keys that are
+// already returned are skipped.
+//
+// If the cluster has analytics replicas, it scans the copy of the DMap on one
+// of them instead. The next replica, and then the partition owners, are
+// scanned if it fails before returning the first page.
+type EmbeddedIterator struct {
+	mtx sync.Mutex
+
+	dm     *EmbeddedDMap
+	config *dmap.ScanConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// The fields below belong to the partition that is being scanned, they
+	// are keyed by member name.
+	partID   uint64
+	cursors  map[string]uint64
+	finished map[string]struct{}
+	seen     map[string]struct{}
+
+	// replicas are the analytics replicas that are not tried yet, the first
+	// one is being scanned.
+	replicas        []discovery.Member
+	analyticsCursor uint64
+	started         bool
+
+	page   []string
+	pos    int
+	key    string
+	err    error
+	closed bool
+}
+
+// Scan returns an iterator over the keys of the DMap. See DMap.Scan.
+func (dm *EmbeddedDMap) Scan(ctx context.Context, options ...ScanOption) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sc := &dmap.ScanConfig{
+		Count: DefaultScanCount,
+	}
+	for _, opt := range options {
+		opt(sc)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	i := &EmbeddedIterator{
+		dm:       dm,
+		config:   sc,
+		ctx:      ctx,
+		cancel:   cancel,
+		replicas: dm.dm.AnalyticsReplicas(),
+	}
+	i.resetPartition()
+	return i, nil
+}
+
+func (i *EmbeddedIterator) resetPartition() {
+	i.cursors = make(map[string]uint64)
+	i.finished = make(map[string]struct{})
+	i.seen = make(map[string]struct{})
+}
+
+// Next returns true if there is more key in the iterator implementation.
+// Otherwise, it returns false.
+func (i *EmbeddedIterator) Next() bool {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	for {
+		for i.pos < len(i.page) {
+			key := i.page[i.pos]
+			i.pos++
+			if _, ok := i.seen[key]; ok {
+				continue
+			}
+			i.seen[key] = struct{}{}
+			i.key = key
+			return true
+		}
+
+		if i.err != nil {
+			return false
+		}
+		if err := i.ctx.Err(); err != nil {
+			i.err = err
+			return false
+		}
+		if i.partID >= i.dm.client.db.config.PartitionCount {
+			return false
+		}
+		if err := i.fetchPage(); err != nil {
+			i.err = err
+			return false
+		}
+	}
+}
+
+// fetchPage fetches the next page of the current partition, or moves to the
+// next partition if all of its owners are scanned.
+func (i *EmbeddedIterator) fetchPage() error {
+	i.page, i.pos = nil, 0
+	if len(i.replicas) != 0 {
+		return i.fetchAnalyticsPage()
+	}
+
+	for _, owner := range i.owners() {
+		name := owner.String()
+		if _, ok := i.finished[name]; ok {
+			continue
+		}
+
+		keys, cursor, err := i.scanOnMember(owner, i.cursors[name])
+		if err != nil {
+			if errors.Is(err, dmap.ErrDMapNotFound) || !i.isOwner(owner) {
+				// The member has no fragment of the DMap, or it has left
+				// and its keys have been moved to the new owners.
+				i.finished[name] = struct{}{}
+				return nil
+			}
+			return err
+		}
+
+		if cursor == 0 {
+			i.finished[name] = struct{}{}
+		} else {
+			i.cursors[name] = cursor
+		}
+		i.page = keys
+		return nil
+	}
+
+	// All owners of the partition are scanned.
+	i.partID++
+	i.resetPartition()
+	return nil
+}
+
+// fetchAnalyticsPage fetches the next page from the copy of the DMap on an
+// analytics replica. The copy is scanned as a whole, the iterator is done
+// after the last page.
+func (i *EmbeddedIterator) fetchAnalyticsPage() error {
+	keys, cursor, err := i.scanOnMember(i.replicas[0], i.analyticsCursor)
+	if err != nil {
+		if i.started || i.ctx.Err() != nil {
+			return err
+		}
+		// Try the next one, the partition owners are scanned after the last one.
+		i.replicas = i.replicas[1:]
+		return nil
+	}
+
+	i.started = true
+	i.page = keys
+	// A key is returned once by a single scan, there is no need to keep them.
+	i.seen = make(map[string]struct{})
+	if cursor == 0 {
+		i.partID = i.dm.client.db.config.PartitionCount
+	} else {
+		i.analyticsCursor = cursor
+	}
+	return nil
+}
+
+func (i *EmbeddedIterator) owners() []discovery.Member {
+	return i.dm.client.db.primary.PartitionByID(i.partID).Owners()
+}
+
+func (i *EmbeddedIterator) isOwner(member discovery.Member) bool {
+	for _, owner := range i.owners() {
+		if owner.CompareByID(member) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *EmbeddedIterator) scanOnMember(member discovery.Member, cursor uint64) ([]string, uint64, error) {
+	db := i.dm.client.db
+	if member.CompareByID(db.rt.This()) {
+		return i.dm.dm.Scan(i.ctx, i.partID, cursor, i.config)
+	}
+
+	s := protocol.NewScan(i.partID, i.dm.name, cursor).SetCount(i.config.Count)
+	if i.config.HasMatch {
+		s.SetMatch(i.config.Match)
+	}
+	cmd := s.Command(i.ctx)
+	rc := db.client.Get(member.String())
+	err := rc.Process(i.ctx, cmd)
+	if err != nil {
+		return nil, 0, protocol.ConvertError(err)
+	}
+	keys, cursor, err := cmd.Result()
+	if err != nil {
+		return nil, 0, protocol.ConvertError(err)
+	}
+	return keys, cursor, nil
+}
+
+// Key returns a key name from the distributed map.
+func (i *EmbeddedIterator) Key() string {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	return i.key
+}
+
+// Value fetches the current value of the key. It returns ErrKeyNotFound if
+// the key is deleted after it's scanned.
+func (i *EmbeddedIterator) Value() (*GetResponse, error) {
+	return i.dm.Get(i.ctx, i.Key())
+}
+
+// Err returns the error that stopped the iteration, if there is any. It
+// returns nil if the iteration is completed or closed.
+func (i *EmbeddedIterator) Err() error {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	if i.closed {
+		return nil
+	}
+	return i.err
+}
+
+// Close stops the iteration and releases allocated resources.
+func (i *EmbeddedIterator) Close() {
+	// Cancel first, Next holds the lock while it's fetching a page.
+	i.cancel()
+
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	i.closed = true
+}
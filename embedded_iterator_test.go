@@ -0,0 +1,127 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedDMap_Scan(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db1.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	t.Run("All keys", func(t *testing.T) {
+		it, err := dm.Scan(ctx, Count(3))
+		require.NoError(t, err)
+		defer it.Close()
+
+		keys := make(map[string]int)
+		for it.Next() {
+			keys[it.Key()]++
+			gr, err := it.Value()
+			require.NoError(t, err)
+			_, err = gr.Int()
+			require.NoError(t, err)
+		}
+		require.NoError(t, it.Err())
+		require.Len(t, keys, 100)
+		for key, count := range keys {
+			require.Equal(t, 1, count, key)
+		}
+	})
+
+	t.Run("Match", func(t *testing.T) {
+		it, err := dm.Scan(ctx, Match("^00000001[0-9]$"))
+		require.NoError(t, err)
+		defer it.Close()
+
+		var keys []string
+		for it.Next() {
+			keys = append(keys, it.Key())
+		}
+		require.NoError(t, it.Err())
+		require.Len(t, keys, 10)
+		for _, key := range keys {
+			require.True(t, strings.HasPrefix(key, "00000001"), key)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		it, err := dm.Scan(ctx, Count(1))
+		require.NoError(t, err)
+		require.True(t, it.Next())
+		it.Close()
+		require.False(t, it.Next())
+		require.NoError(t, it.Err())
+	})
+}
+
+func TestEmbeddedDMap_Scan_RoutingTableChanged(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db1.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	it, err := dm.Scan(ctx, Count(2))
+	require.NoError(t, err)
+	defer it.Close()
+
+	keys := make(map[string]int)
+	for i := 0; i < 10 && it.Next(); i++ {
+		keys[it.Key()]++
+	}
+
+	db3 := cluster.addMember(t)
+	_, err = db3.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	<-time.After(250 * time.Millisecond)
+
+	for it.Next() {
+		keys[it.Key()]++
+	}
+	require.NoError(t, it.Err())
+	require.Len(t, keys, 100)
+	for key, count := range keys {
+		require.Equal(t, 1, count, key)
+	}
+}
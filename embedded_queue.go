@@ -0,0 +1,102 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/buraksezer/olric/internal/queue"
+	"github.com/buraksezer/olric/internal/resp"
+)
+
+// EmbeddedQueue is a Queue client implementation for embedded-member scenario.
+type EmbeddedQueue struct {
+	config *queueConfig
+	q      *queue.Queue
+}
+
+// NewQueue returns a new Queue client with the given options.
+func (e *EmbeddedClient) NewQueue(name string, options ...QueueOption) (Queue, error) {
+	q, err := e.db.queue.NewQueue(name)
+	if err != nil {
+		return nil, convertQueueError(err)
+	}
+
+	qc := queueConfig{visibility: DefaultVisibilityTimeout}
+	for _, opt := range options {
+		opt(&qc)
+	}
+	return &EmbeddedQueue{
+		config: &qc,
+		q:      q,
+	}, nil
+}
+
+// Name exposes name of the queue.
+func (q *EmbeddedQueue) Name() string {
+	return q.q.Name()
+}
+
+// Push appends a message to the end of the queue and returns its ID.
+func (q *EmbeddedQueue) Push(ctx context.Context, value interface{}) (uint64, error) {
+	var buf bytes.Buffer
+	if err := resp.New(&buf).Encode(value); err != nil {
+		return 0, err
+	}
+	id, err := q.q.Push(ctx, buf.Bytes())
+	return id, convertQueueError(err)
+}
+
+func toQueueMessage(m *queue.Message) *QueueMessage {
+	return &QueueMessage{
+		ID:       m.ID,
+		Delivery: m.Delivery,
+		value:    m.Value,
+	}
+}
+
+// Pop returns the oldest visible message and hides it until the visibility
+// timeout expires.
+func (q *EmbeddedQueue) Pop(ctx context.Context) (*QueueMessage, error) {
+	m, err := q.q.Pop(ctx, q.config.visibility)
+	if err != nil {
+		return nil, convertQueueError(err)
+	}
+	return toQueueMessage(m), nil
+}
+
+// BPop waits until a message is visible or timeout expires.
+func (q *EmbeddedQueue) BPop(ctx context.Context, timeout time.Duration) (*QueueMessage, error) {
+	m, err := q.q.BPop(ctx, q.config.visibility, timeout)
+	if err != nil {
+		return nil, convertQueueError(err)
+	}
+	return toQueueMessage(m), nil
+}
+
+// Ack deletes a popped message permanently.
+func (q *EmbeddedQueue) Ack(ctx context.Context, m *QueueMessage) error {
+	return convertQueueError(q.q.Ack(ctx, m.ID, m.Delivery))
+}
+
+// Len returns the number of messages in the queue.
+func (q *EmbeddedQueue) Len(ctx context.Context) (int, error) {
+	length, err := q.q.Len(ctx)
+	return length, convertQueueError(err)
+}
+
+var _ Queue = (*EmbeddedQueue)(nil)
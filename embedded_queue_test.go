@@ -0,0 +1,84 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_Queue(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	q, err := db.NewEmbeddedClient().NewQueue("jobs")
+	require.NoError(t, err)
+	require.Equal(t, "jobs", q.Name())
+
+	_, err = q.Push(ctx, "job-1")
+	require.NoError(t, err)
+	_, err = q.Push(ctx, 2)
+	require.NoError(t, err)
+
+	q2, err := db2.NewEmbeddedClient().NewQueue("jobs")
+	require.NoError(t, err)
+
+	length, err := q2.Len(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, length)
+
+	m, err := q2.Pop(ctx)
+	require.NoError(t, err)
+	value, err := m.String()
+	require.NoError(t, err)
+	require.Equal(t, "job-1", value)
+	require.NoError(t, q2.Ack(ctx, m))
+
+	m, err = q.BPop(ctx, time.Second)
+	require.NoError(t, err)
+	var number int
+	require.NoError(t, m.Scan(&number))
+	require.Equal(t, 2, number)
+	require.NoError(t, q.Ack(ctx, m))
+
+	_, err = q.Pop(ctx)
+	require.ErrorIs(t, err, ErrQueueEmpty)
+	require.ErrorIs(t, q.Ack(ctx, m), ErrNoSuchMessage)
+}
+
+func TestEmbeddedClient_Queue_VisibilityTimeout(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	q, err := db.NewEmbeddedClient().NewQueue("jobs", VisibilityTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = q.Push(ctx, "job")
+	require.NoError(t, err)
+
+	m, err := q.Pop(ctx)
+	require.NoError(t, err)
+
+	redelivered, err := q.BPop(ctx, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, m.ID, redelivered.ID)
+	require.Equal(t, uint64(2), redelivered.Delivery)
+}
@@ -0,0 +1,123 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/set"
+)
+
+// EmbeddedSet is a Set client implementation for embedded-member scenario.
+type EmbeddedSet struct {
+	st *set.Set
+}
+
+// NewSet returns a new Set client.
+func (e *EmbeddedClient) NewSet(name string) (Set, error) {
+	st, err := e.db.set.NewSet(name)
+	if err != nil {
+		return nil, convertSetError(err)
+	}
+	return &EmbeddedSet{st: st}, nil
+}
+
+// Name exposes name of the set.
+func (s *EmbeddedSet) Name() string {
+	return s.st.Name()
+}
+
+// SAdd adds the members to the set.
+func (s *EmbeddedSet) SAdd(ctx context.Context, members ...string) (int, error) {
+	added, err := s.st.SAdd(ctx, members...)
+	return added, convertSetError(err)
+}
+
+// SRem removes the members from the set.
+func (s *EmbeddedSet) SRem(ctx context.Context, members ...string) (int, error) {
+	removed, err := s.st.SRem(ctx, members...)
+	return removed, convertSetError(err)
+}
+
+// SIsMember returns true if the member is in the set.
+func (s *EmbeddedSet) SIsMember(ctx context.Context, member string) (bool, error) {
+	ok, err := s.st.SIsMember(ctx, member)
+	return ok, convertSetError(err)
+}
+
+// SMembers returns all the members of the set.
+func (s *EmbeddedSet) SMembers(ctx context.Context) ([]string, error) {
+	members, err := s.st.SMembers(ctx)
+	return members, convertSetError(err)
+}
+
+// SCard returns the number of the members in the set.
+func (s *EmbeddedSet) SCard(ctx context.Context) (int, error) {
+	length, err := s.st.SCard(ctx)
+	return length, convertSetError(err)
+}
+
+// EmbeddedSortedSet is a SortedSet client implementation for embedded-member scenario.
+type EmbeddedSortedSet struct {
+	z *set.SortedSet
+}
+
+// NewSortedSet returns a new SortedSet client.
+func (e *EmbeddedClient) NewSortedSet(name string) (SortedSet, error) {
+	z, err := e.db.set.NewSortedSet(name)
+	if err != nil {
+		return nil, convertSetError(err)
+	}
+	return &EmbeddedSortedSet{z: z}, nil
+}
+
+// Name exposes name of the sorted set.
+func (s *EmbeddedSortedSet) Name() string {
+	return s.z.Name()
+}
+
+// ZAdd adds the members to the sorted set or updates their scores.
+func (s *EmbeddedSortedSet) ZAdd(ctx context.Context, members ...ZMember) (int, error) {
+	scored := make([]set.ScoredMember, 0, len(members))
+	for _, m := range members {
+		scored = append(scored, set.ScoredMember{Member: m.Member, Score: m.Score})
+	}
+	added, err := s.z.ZAdd(ctx, scored...)
+	return added, convertSetError(err)
+}
+
+// ZRangeByScore returns the members whose scores are between min and max.
+func (s *EmbeddedSortedSet) ZRangeByScore(ctx context.Context, min, max float64) ([]ZMember, error) {
+	scored, err := s.z.ZRangeByScore(ctx, min, max)
+	if err != nil {
+		return nil, convertSetError(err)
+	}
+	members := make([]ZMember, 0, len(scored))
+	for _, sm := range scored {
+		members = append(members, ZMember{Member: sm.Member, Score: sm.Score})
+	}
+	return members, nil
+}
+
+// ZRank returns the zero-based rank of the member.
+func (s *EmbeddedSortedSet) ZRank(ctx context.Context, member string) (int, error) {
+	rank, err := s.z.ZRank(ctx, member)
+	return rank, convertSetError(err)
+}
+
+var (
+	_ Set       = (*EmbeddedSet)(nil)
+	_ SortedSet = (*EmbeddedSortedSet)(nil)
+)
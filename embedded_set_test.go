@@ -0,0 +1,85 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_Set(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	s, err := db.NewEmbeddedClient().NewSet("online-users")
+	require.NoError(t, err)
+	require.Equal(t, "online-users", s.Name())
+
+	added, err := s.SAdd(ctx, "alice", "bob")
+	require.NoError(t, err)
+	require.Equal(t, 2, added)
+
+	s2, err := db2.NewEmbeddedClient().NewSet("online-users")
+	require.NoError(t, err)
+
+	ok, err := s2.SIsMember(ctx, "alice")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	removed, err := s2.SRem(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	members, err := s.SMembers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"bob"}, members)
+
+	length, err := s2.SCard(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, length)
+}
+
+func TestEmbeddedClient_SortedSet(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	z, err := db.NewEmbeddedClient().NewSortedSet("leaderboard")
+	require.NoError(t, err)
+	require.Equal(t, "leaderboard", z.Name())
+
+	added, err := z.ZAdd(ctx, ZMember{Member: "alice", Score: 30}, ZMember{Member: "bob", Score: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, added)
+
+	z2, err := db2.NewEmbeddedClient().NewSortedSet("leaderboard")
+	require.NoError(t, err)
+
+	rank, err := z2.ZRank(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, 1, rank)
+
+	members, err := z2.ZRangeByScore(ctx, 0, 20)
+	require.NoError(t, err)
+	require.Equal(t, []ZMember{{Member: "bob", Score: 10}}, members)
+
+	_, err = z.ZRank(ctx, "carol")
+	require.ErrorIs(t, err, ErrNoSuchMember)
+}
@@ -22,7 +22,6 @@ import (
 	"strings"
 
 	"github.com/buraksezer/olric/internal/bufpool"
-	"github.com/buraksezer/olric/internal/util"
 )
 
 var pool = bufpool.New()
@@ -33,6 +32,8 @@ const (
 	KindNodeLeftEvent          = "node-left-event"
 	KindFragmentMigrationEvent = "fragment-migration-event"
 	KindFragmentReceivedEvent  = "fragment-received-event"
+
+	KindRoutingTableUpdatedEvent = "routing-table-updated-event"
 )
 
 type Event interface {
@@ -83,7 +84,8 @@ func encodeEvent(data interface{}, fields []string, valueExtractor func(r reflec
 		buf.Write(val)
 	}
 	buf.WriteString("}")
-	return util.BytesToString(buf.Bytes()), nil
+	// The buffer is returned to the pool, the event has to be copied.
+	return buf.String(), nil
 }
 
 type NodeJoinEvent struct {
@@ -213,3 +215,32 @@ func (f *FragmentReceivedEvent) Encode() (string, error) {
 		return value, nil
 	})
 }
+
+// RoutingTableUpdatedEvent is published by a member after it applies a new
+// routing table that is pushed by the cluster coordinator. Signature changes
+// with the owners of the partitions.
+type RoutingTableUpdatedEvent struct {
+	Kind        string `json:"kind"`
+	Source      string `json:"source"`
+	Coordinator string `json:"coordinator"`
+	Signature   uint64 `json:"signature"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+func (r *RoutingTableUpdatedEvent) Encode() (string, error) {
+	fields := []string{"Timestamp", "Source", "Kind", "Coordinator", "Signature"}
+	return encodeEvent(r, fields, func(r reflect.Value, field string) (interface{}, error) {
+		var value interface{}
+		switch field {
+		case "Signature":
+			value = r.FieldByName(field).Uint()
+		case "Timestamp":
+			value = r.FieldByName(field).Int()
+		case "Source", "Kind", "Coordinator":
+			value = r.FieldByName(field).String()
+		default:
+			return nil, fmt.Errorf("invalid field: %s", field)
+		}
+		return value, nil
+	})
+}
@@ -82,3 +82,18 @@ func TestClusterEvents_FragmentReceivedEvent(t *testing.T) {
 	expected := `{"timestamp":585199808000,"source":"127.0.0.1:3423","kind":"fragment-received-event","data_structure":"dmap","partition_id":123,"identifier":"mydmap","is_backup":false,"length":1234}`
 	require.Equal(t, expected, result)
 }
+
+func TestClusterEvents_RoutingTableUpdatedEvent(t *testing.T) {
+	var timestamp int64 = 585199808000 // Author's birthdate!
+	n := RoutingTableUpdatedEvent{
+		Kind:        KindRoutingTableUpdatedEvent,
+		Source:      "127.0.0.1:3423",
+		Coordinator: "127.0.0.1:3576",
+		Signature:   1234,
+		Timestamp:   timestamp,
+	}
+	result, err := n.Encode()
+	require.NoError(t, err)
+	expected := `{"timestamp":585199808000,"source":"127.0.0.1:3423","kind":"routing-table-updated-event","coordinator":"127.0.0.1:3576","signature":1234}`
+	require.Equal(t, expected, result)
+}
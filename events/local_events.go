@@ -0,0 +1,136 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The local events are delivered to the embedded clients on the same member
+// only, see EmbeddedClient.SubscribeLocalEvents. FragmentMigrationEvent and
+// FragmentReceivedEvent are delivered there too.
+const (
+	KindEntryUpdatedEvent   = "entry-updated-event"
+	KindBackupPromotedEvent = "backup-promoted-event"
+	KindSLOViolationEvent   = "slo-violation-event"
+)
+
+// EntryUpdatedEvent is published by the partition owner after an entry is
+// written, its TTL is updated or it is deleted. Operation is "put", "expire"
+// or "delete". Value is the decoded value of a put, it must not be modified.
+type EntryUpdatedEvent struct {
+	Kind          string `json:"kind"`
+	Source        string `json:"source"`
+	DataStructure string `json:"data_structure"`
+	Identifier    string `json:"identifier"`
+	PartitionID   uint64 `json:"partition_id"`
+	Operation     string `json:"operation"`
+	Key           string `json:"key"`
+	Value         []byte `json:"value"`
+	TTL           int64  `json:"ttl"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+func (e *EntryUpdatedEvent) Encode() (string, error) {
+	fields := []string{
+		"Timestamp",
+		"Source",
+		"Kind",
+		"DataStructure",
+		"PartitionID",
+		"Identifier",
+		"Operation",
+		"Key",
+		"Value",
+		"TTL",
+	}
+	return encodeEvent(e, fields, func(r reflect.Value, field string) (interface{}, error) {
+		var value interface{}
+		switch field {
+		case "PartitionID":
+			value = r.FieldByName(field).Uint()
+		case "Timestamp", "TTL":
+			value = r.FieldByName(field).Int()
+		case "Value":
+			value = r.FieldByName(field).Bytes()
+		case "Source", "Kind", "DataStructure", "Identifier", "Operation", "Key":
+			value = r.FieldByName(field).String()
+		default:
+			return nil, fmt.Errorf("invalid field: %s", field)
+		}
+		return value, nil
+	})
+}
+
+// BackupPromotedEvent is published when this member becomes the owner of a
+// partition that it was a backup owner of.
+type BackupPromotedEvent struct {
+	Kind        string `json:"kind"`
+	Source      string `json:"source"`
+	PartitionID uint64 `json:"partition_id"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+func (b *BackupPromotedEvent) Encode() (string, error) {
+	fields := []string{"Timestamp", "Source", "Kind", "PartitionID"}
+	return encodeEvent(b, fields, func(r reflect.Value, field string) (interface{}, error) {
+		var value interface{}
+		switch field {
+		case "PartitionID":
+			value = r.FieldByName(field).Uint()
+		case "Timestamp":
+			value = r.FieldByName(field).Int()
+		case "Source", "Kind":
+			value = r.FieldByName(field).String()
+		default:
+			return nil, fmt.Errorf("invalid field: %s", field)
+		}
+		return value, nil
+	})
+}
+
+// SLOViolationEvent is published when the latency SLO attainment of a DMap on
+// this member falls below its objective. It's published again only after the
+// attainment recovers. Good and Bad are the numbers of the operations in the
+// rolling window.
+type SLOViolationEvent struct {
+	Kind       string  `json:"kind"`
+	Source     string  `json:"source"`
+	DMap       string  `json:"dmap"`
+	Attainment float64 `json:"attainment"`
+	Objective  float64 `json:"objective"`
+	Good       int64   `json:"good"`
+	Bad        int64   `json:"bad"`
+	Timestamp  int64   `json:"timestamp"`
+}
+
+func (s *SLOViolationEvent) Encode() (string, error) {
+	fields := []string{"Timestamp", "Source", "Kind", "DMap", "Attainment", "Objective", "Good", "Bad"}
+	return encodeEvent(s, fields, func(r reflect.Value, field string) (interface{}, error) {
+		var value interface{}
+		switch field {
+		case "Timestamp", "Good", "Bad":
+			value = r.FieldByName(field).Int()
+		case "Attainment", "Objective":
+			value = r.FieldByName(field).Float()
+		case "Source", "Kind", "DMap":
+			value = r.FieldByName(field).String()
+		default:
+			return nil, fmt.Errorf("invalid field: %s", field)
+		}
+		return value, nil
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalEvents_EntryUpdatedEvent(t *testing.T) {
+	var timestamp int64 = 585199808000
+	e := EntryUpdatedEvent{
+		Kind:          KindEntryUpdatedEvent,
+		Source:        "127.0.0.1:3423",
+		DataStructure: "dmap",
+		Identifier:    "mydmap",
+		PartitionID:   123,
+		Operation:     "put",
+		Key:           "mykey",
+		Value:         []byte("myvalue"),
+		TTL:           0,
+		Timestamp:     timestamp,
+	}
+	result, err := e.Encode()
+	require.NoError(t, err)
+	expected := `{"timestamp":585199808000,"source":"127.0.0.1:3423","kind":"entry-updated-event","data_structure":"dmap","partition_id":123,"identifier":"mydmap","operation":"put","key":"mykey","value":"bXl2YWx1ZQ==","ttl":0}`
+	require.Equal(t, expected, result)
+}
+
+func TestLocalEvents_BackupPromotedEvent(t *testing.T) {
+	var timestamp int64 = 585199808000
+	b := BackupPromotedEvent{
+		Kind:        KindBackupPromotedEvent,
+		Source:      "127.0.0.1:3423",
+		PartitionID: 123,
+		Timestamp:   timestamp,
+	}
+	result, err := b.Encode()
+	require.NoError(t, err)
+	expected := `{"timestamp":585199808000,"source":"127.0.0.1:3423","kind":"backup-promoted-event","partition_id":123}`
+	require.Equal(t, expected, result)
+}
+
+func TestLocalEvents_SLOViolationEvent(t *testing.T) {
+	var timestamp int64 = 585199808000
+	s := SLOViolationEvent{
+		Kind:       KindSLOViolationEvent,
+		Source:     "127.0.0.1:3423",
+		DMap:       "mydmap",
+		Attainment: 0.5,
+		Objective:  0.99,
+		Good:       10,
+		Bad:        10,
+		Timestamp:  timestamp,
+	}
+	result, err := s.Encode()
+	require.NoError(t, err)
+	expected := `{"timestamp":585199808000,"source":"127.0.0.1:3423","kind":"slo-violation-event","dmap":"mydmap","attainment":0.5,"objective":0.99,"good":10,"bad":10}`
+	require.Equal(t, expected, result)
+}
@@ -0,0 +1,42 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"io"
+)
+
+// Export writes the entries of the DMap to w. See DMap.Export for the details.
+func (dm *EmbeddedDMap) Export(ctx context.Context, w io.Writer) (int, error) {
+	var count int
+	err := dm.client.observe(ctx, dm.command("Export"), func(ctx context.Context, _ *ClientCommand) (err error) {
+		count, err = dm.dm.Export(ctx, w)
+		return convertDMapError(err)
+	})
+	return count, err
+}
+
+// Import stores the entries of a stream written by Export. The values are
+// stored as they are exported, they are not encoded with the codec of the
+// client again. See DMap.Import for the details.
+func (dm *EmbeddedDMap) Import(ctx context.Context, r io.Reader) (int, error) {
+	var count int
+	err := dm.client.observe(ctx, dm.command("Import"), func(ctx context.Context, _ *ClientCommand) (err error) {
+		count, err = dm.dm.Import(ctx, r)
+		return convertDMapError(err)
+	})
+	return count, err
+}
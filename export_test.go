@@ -0,0 +1,63 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_Export_Import(t *testing.T) {
+	source := newTestOlricCluster(t)
+	db := source.addMember(t)
+	target := newTestOlricCluster(t)
+	db2 := target.addMember(t)
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i, EX(time.Hour))
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	count, err := dm.Export(ctx, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+
+	dm2, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	count, err = dm2.Import(ctx, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+
+	for i := 0; i < 100; i++ {
+		gr, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+		require.NotZero(t, gr.TTL())
+	}
+
+	_, err = dm2.Import(ctx, bytes.NewBufferString("foobar"))
+	require.ErrorIs(t, err, ErrInvalidExport)
+}
@@ -0,0 +1,120 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type getMultiConfig struct {
+	maxFailures    int
+	hasMaxFailures bool
+}
+
+// GetMultiOption is a function for defining options to control behavior of
+// DMap.GetMulti.
+type GetMultiOption func(*getMultiConfig)
+
+// MaxFailures makes GetMulti return ErrTooManyFailures if more than n keys
+// cannot be read. A missing key is not a failure. All failures are tolerated
+// by default.
+func MaxFailures(n int) GetMultiOption {
+	return func(cfg *getMultiConfig) {
+		cfg.maxFailures = n
+		cfg.hasMaxFailures = true
+	}
+}
+
+// GetMultiResult is the result of a key in DMap.GetMulti. Err is
+// ErrKeyNotFound if the key doesn't exist, or the error that prevented
+// reading the key.
+type GetMultiResult struct {
+	Response *GetResponse
+	Err      error
+}
+
+// GetMulti gets the values of the given keys. The keys of the same partition
+// owner are read one by one, the owners are read in parallel. An owner that
+// cannot be reached is not asked again for the rest of the keys, they get the
+// same error. The other errors, like ErrRateLimited, fail only their key. The result of every key is returned, even if GetMulti returns
+// ErrTooManyFailures. See MaxFailures.
+func (dm *EmbeddedDMap) GetMulti(ctx context.Context, keys []string, options ...GetMultiOption) (map[string]GetMultiResult, error) {
+	var cfg getMultiConfig
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	owners := make(map[string][]string)
+	for _, key := range keys {
+		owner := dm.client.db.primary.PartitionByHKey(dm.dm.HKey(key)).Owner()
+		owners[owner.String()] = append(owners[owner.String()], key)
+	}
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]GetMultiResult, len(keys))
+	for _, ownerKeys := range owners {
+		wg.Add(1)
+		go func(ownerKeys []string) {
+			defer wg.Done()
+
+			var ownerErr error
+			for _, key := range ownerKeys {
+				var result GetMultiResult
+				if ownerErr != nil {
+					result.Err = ownerErr
+				} else {
+					result.Response, result.Err = dm.Get(ctx, key)
+					if isTransportError(result.Err) {
+						ownerErr = result.Err
+					}
+				}
+				mtx.Lock()
+				results[key] = result
+				mtx.Unlock()
+			}
+		}(ownerKeys)
+	}
+	wg.Wait()
+
+	if cfg.hasMaxFailures {
+		var failures int
+		for _, result := range results {
+			if result.Err != nil && !errors.Is(result.Err, ErrKeyNotFound) {
+				failures++
+			}
+		}
+		if failures > cfg.maxFailures {
+			return results, ErrTooManyFailures
+		}
+	}
+	return results, nil
+}
+
+// isTransportError returns true if the error is caused by the connection to
+// the partition owner, not by the key.
+func isTransportError(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrClosed)
+}
@@ -0,0 +1,110 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedDMap_GetMulti(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db1.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+		keys = append(keys, testutil.ToKey(i))
+	}
+	keys = append(keys, "missing")
+
+	results, err := dm.GetMulti(ctx, keys, MaxFailures(0))
+	require.NoError(t, err)
+	require.Len(t, results, 11)
+	for i := 0; i < 10; i++ {
+		result := results[testutil.ToKey(i)]
+		require.NoError(t, result.Err)
+		value, err := result.Response.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+	require.ErrorIs(t, results["missing"].Err, ErrKeyNotFound)
+}
+
+func TestEmbeddedDMap_GetMulti_MaxFailures(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+		RateLimits: []config.RateLimit{{KeyPattern: "^limited:", ReadsPerSecond: 1}},
+	}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	keys := []string{"mykey", "limited:1", "limited:2"}
+	for _, key := range keys {
+		_, err = dm.Put(ctx, key, key)
+		require.NoError(t, err)
+	}
+
+	// The second read of the limited keys is rejected, the result of the
+	// other keys is returned anyway.
+	results, err := dm.GetMulti(ctx, keys, MaxFailures(0))
+	require.ErrorIs(t, err, ErrTooManyFailures)
+	require.NoError(t, results["mykey"].Err)
+	require.NoError(t, results["limited:1"].Err)
+	require.ErrorIs(t, results["limited:2"].Err, ErrRateLimited)
+
+	// All failures are tolerated by default.
+	results, err = dm.GetMulti(ctx, keys)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	// A rejected key doesn't fail the other keys of its owner.
+	results, err = dm.GetMulti(ctx, []string{"limited:1", "mykey"})
+	require.NoError(t, err)
+	require.ErrorIs(t, results["limited:1"].Err, ErrRateLimited)
+	require.NoError(t, results["mykey"].Err)
+}
+
+func TestGetMulti_isTransportError(t *testing.T) {
+	require.True(t, isTransportError(&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}))
+	require.True(t, isTransportError(fmt.Errorf("read: %w", io.EOF)))
+	require.True(t, isTransportError(redis.ErrClosed))
+	require.False(t, isTransportError(nil))
+	require.False(t, isTransportError(ErrRateLimited))
+	require.False(t, isTransportError(ErrKeyNotFound))
+}
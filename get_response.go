@@ -15,27 +15,77 @@
 package olric
 
 import (
+	"bytes"
 	"errors"
+	"sync/atomic"
 	"time"
 
+	"github.com/buraksezer/olric/internal/bufpool"
 	"github.com/buraksezer/olric/internal/resp"
 	"github.com/buraksezer/olric/pkg/storage"
 )
 
 var ErrNilResponse = errors.New("storage entry is nil")
 
+var responsePool = bufpool.New()
+
 func NewResponse(entry storage.Entry) *GetResponse {
 	return &GetResponse{entry: entry}
 }
 
+// GetResponse is the result of a Get call. The values that are read from the
+// local storage are kept in a pooled buffer, the slices returned by Byte and
+// Bytes reference that buffer. The caller owns the response: Retain adds a
+// reference to it and Release drops one. When the last reference is released,
+// the buffer goes back to the pool and the response must not be used anymore.
+// Calling Release is optional, the buffer is garbage collected otherwise.
 type GetResponse struct {
 	entry storage.Entry
+	codec Codec
+	buf   *bytes.Buffer
+	refs  int32
+}
+
+// newPooledResponse copies the value of entry to a pooled buffer. A value that
+// is read from the local storage references the memory of the fragment, that
+// memory is reused after the fragment lock is released. This is the only
+// copy on the Get path.
+func newPooledResponse(entry storage.Entry, c Codec) *GetResponse {
+	buf := responsePool.Get()
+	buf.Write(entry.Value())
+	entry.SetValue(buf.Bytes())
+	return &GetResponse{entry: entry, codec: c, buf: buf}
 }
 
+// Retain adds a reference to the response. Every Retain call must be paired
+// with a Release call.
+func (g *GetResponse) Retain() {
+	atomic.AddInt32(&g.refs, 1)
+}
+
+// Release drops a reference to the response. The value is returned to the
+// pool when the last reference is released, the slices returned by Byte and
+// Bytes are invalid after that.
+func (g *GetResponse) Release() {
+	if atomic.AddInt32(&g.refs, -1) >= 0 {
+		return
+	}
+	if g.buf != nil {
+		responsePool.Put(g.buf)
+		g.buf = nil
+	}
+	g.entry = nil
+}
+
+// Scan decodes the value into v. If the client has a Codec, the value is
+// decoded by the codec. See WithCodec.
 func (g *GetResponse) Scan(v interface{}) error {
 	if g.entry == nil {
 		return ErrNilResponse
 	}
+	if g.codec != nil {
+		return g.codec.Decode(g.entry.Value(), v)
+	}
 	return resp.Scan(g.entry.Value(), v)
 }
 
@@ -54,6 +104,10 @@ func (g *GetResponse) String() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if g.buf != nil && g.codec == nil {
+		// The string references the pooled buffer, it must outlive Release.
+		return string([]byte(*v)), nil
+	}
 	return *v, nil
 }
 
@@ -183,6 +237,8 @@ func (g *GetResponse) Duration() (time.Duration, error) {
 	return *v, nil
 }
 
+// Byte decodes the value into a byte slice. The value is not copied, the
+// result is valid until the response is released. Copy it to keep it longer.
 func (g *GetResponse) Byte() ([]byte, error) {
 	v := new([]byte)
 	err := g.Scan(v)
@@ -192,6 +248,16 @@ func (g *GetResponse) Byte() ([]byte, error) {
 	return *v, nil
 }
 
+// Bytes returns the raw value as it's stored in the DMap, the Codec of the
+// client is not applied. Like Byte, the result is valid until the response is
+// released and it must not be modified.
+func (g *GetResponse) Bytes() ([]byte, error) {
+	if g.entry == nil {
+		return nil, ErrNilResponse
+	}
+	return g.entry.Value(), nil
+}
+
 func (g *GetResponse) TTL() int64 {
 	return g.entry.TTL()
 }
@@ -199,3 +265,22 @@ func (g *GetResponse) TTL() int64 {
 func (g *GetResponse) Timestamp() int64 {
 	return g.entry.Timestamp()
 }
+
+// LastAccess returns the time of the previous access to the entry on its
+// partition owner, in nanoseconds.
+func (g *GetResponse) LastAccess() int64 {
+	return g.entry.LastAccess()
+}
+
+// Entry is a DMap entry with its metadata, see DMap.GetEntry. The value and
+// TTL, Timestamp and LastAccess of the entry are accessed via GetResponse.
+// Timestamp is the version of the entry, the last write wins.
+type Entry struct {
+	*GetResponse
+
+	// Key is the key of the entry.
+	Key string
+
+	// Member is the partition owner that served the read.
+	Member string
+}
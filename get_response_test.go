@@ -63,6 +63,57 @@ func TestDMap_Get_GetResponse(t *testing.T) {
 		scannedValue, err := gr.Byte()
 		require.NoError(t, err)
 		require.Equal(t, value, scannedValue)
+
+	})
+
+	t.Run("Retain/Release", func(t *testing.T) {
+		var value = []byte("olric")
+		err = dm.Put(ctx, "mykey-pooled", value, nil)
+		require.NoError(t, err)
+
+		e, err := dm.Get(ctx, "mykey-pooled")
+		require.NoError(t, err)
+
+		gr := newPooledResponse(e, nil)
+		scannedValue, err := gr.Byte()
+		require.NoError(t, err)
+		require.Equal(t, value, scannedValue)
+		// Byte doesn't copy the pooled value.
+		require.Equal(t, &gr.buf.Bytes()[0], &scannedValue[0])
+
+		str, err := gr.String()
+		require.NoError(t, err)
+
+		gr.Retain()
+		gr.Release()
+		_, err = gr.Byte()
+		require.NoError(t, err)
+
+		// The last reference is released, the buffer is back in the pool.
+		gr.Release()
+		require.Nil(t, gr.buf)
+		_, err = gr.Byte()
+		require.ErrorIs(t, err, ErrNilResponse)
+		require.Equal(t, "olric", str)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		var value = []byte("olric")
+		err = dm.Put(ctx, "mykey-bytes", value, nil)
+		require.NoError(t, err)
+
+		e, err := dm.Get(ctx, "mykey-bytes")
+		require.NoError(t, err)
+
+		gr := &GetResponse{entry: e, codec: jsonCodec{}}
+		raw, err := gr.Bytes()
+		require.NoError(t, err)
+		// The codec is not applied.
+		require.Equal(t, value, raw)
+		require.Equal(t, &e.Value()[0], &raw[0])
+
+		_, err = (&GetResponse{}).Bytes()
+		require.ErrorIs(t, err, ErrNilResponse)
 	})
 
 	t.Run("TTL", func(t *testing.T) {
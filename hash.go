@@ -0,0 +1,57 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import "github.com/buraksezer/olric/internal/resp"
+
+// HashField is the value of a field in a hash entry. See DMap.HSet.
+type HashField struct {
+	value []byte
+}
+
+// Scan decodes the field value into v.
+func (h *HashField) Scan(v interface{}) error {
+	return resp.Scan(h.value, v)
+}
+
+// String returns the field value as string.
+func (h *HashField) String() (string, error) {
+	v := new(string)
+	err := h.Scan(v)
+	if err != nil {
+		return "", err
+	}
+	return *v, nil
+}
+
+// Int returns the field value as int.
+func (h *HashField) Int() (int, error) {
+	v := new(int)
+	err := h.Scan(v)
+	if err != nil {
+		return 0, err
+	}
+	return *v, nil
+}
+
+// Byte returns the field value as byte slice.
+func (h *HashField) Byte() ([]byte, error) {
+	v := new([]byte)
+	err := h.Scan(v)
+	if err != nil {
+		return nil, err
+	}
+	return *v, nil
+}
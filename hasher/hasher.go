@@ -35,3 +35,29 @@ func (x xxhasher) Sum64(key []byte) uint64 {
 type Hasher interface {
 	Sum64([]byte) uint64
 }
+
+// fingerprintProbes is a fixed set of keys hashed by Fingerprint. Two hashers
+// are considered compatible if they generate the same hashes for all of them.
+var fingerprintProbes = [][]byte{
+	[]byte(""),
+	[]byte("olric"),
+	[]byte("partition-table"),
+	[]byte("0123456789abcdefghijklmnopqrstuvwxyz"),
+	{0x00, 0xff, 0x7f, 0x80},
+}
+
+// Fingerprint returns a 64-bit fingerprint of the given Hasher. Members of a
+// cluster exchange it while joining, the cluster rejects a member that
+// distributes the keys with a different hash function.
+func Fingerprint(h Hasher) uint64 {
+	var fp uint64 = 14695981039346656037
+	for _, probe := range fingerprintProbes {
+		fp ^= h.Sum64(probe)
+		fp *= 1099511628211
+	}
+	if fp == 0 {
+		// Zero denotes a member that doesn't send a fingerprint.
+		fp = 1
+	}
+	return fp
+}
@@ -0,0 +1,175 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/dmap"
+)
+
+const (
+	// hedgeSamples is the number of the latest Get latencies that the hedging
+	// delay is computed from.
+	hedgeSamples = 1000
+
+	// hedgeRecompute is the number of the samples after which the hedging
+	// delay is computed again.
+	hedgeRecompute = 100
+)
+
+// hedgePolicy keeps the latencies of the latest Get calls and computes the
+// delay after which a hedged request is sent.
+type hedgePolicy struct {
+	percentile float64
+	minDelay   time.Duration
+
+	mtx      sync.Mutex
+	samples  []time.Duration
+	next     int
+	observed int
+	delay    time.Duration
+}
+
+// WithHedgedReads makes Get and GetEntry send a second request to a backup
+// owner of the key if the partition owner doesn't respond in time, and return
+// the first successful response. The delay is the given percentile of the
+// latencies of the latest Get calls, e.g. 95, and it's never shorter than
+// minDelay. minDelay is used until enough calls are observed.
+//
+// It trades some extra load for lower tail latencies, e.g. when the partition
+// owner is paused by the garbage collector. The backups may miss the latest
+// writes, so a hedged read can return a stale value. The reads are not hedged
+// if the replica count is 1, or if MonotonicReads or LinearizableReads is set.
+func WithHedgedReads(percentile float64, minDelay time.Duration) EmbeddedClientOption {
+	return func(cfg *embeddedClientConfig) {
+		cfg.hedge = &hedgePolicy{
+			percentile: percentile,
+			minDelay:   minDelay,
+			samples:    make([]time.Duration, 0, hedgeSamples),
+			delay:      minDelay,
+		}
+	}
+}
+
+func (h *hedgePolicy) observe(latency time.Duration) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if len(h.samples) < hedgeSamples {
+		h.samples = append(h.samples, latency)
+	} else {
+		h.samples[h.next] = latency
+		h.next = (h.next + 1) % hedgeSamples
+	}
+
+	h.observed++
+	if h.observed%hedgeRecompute != 0 {
+		return
+	}
+
+	sorted := make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	idx := int(float64(len(sorted)) * h.percentile / 100)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	h.delay = sorted[idx]
+	if h.delay < h.minDelay {
+		h.delay = h.minDelay
+	}
+}
+
+func (h *hedgePolicy) hedgeDelay() time.Duration {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return h.delay
+}
+
+type hedgeResult struct {
+	entry *dmap.Entry
+	err   error
+}
+
+// hedgedGetEntry reads the key from the partition owner. If it doesn't
+// respond in the hedging delay, the key is read from a backup owner too and
+// the first successful response is returned. The returned response is
+// counted once in the read statistics.
+func (dm *EmbeddedDMap) hedgedGetEntry(ctx context.Context, key string) (*dmap.Entry, error) {
+	entry, err := dm.hedgeGetEntry(dmap.WithoutReadStats(ctx), key)
+	dmap.CountRead(err)
+	return entry, err
+}
+
+func (dm *EmbeddedDMap) hedgeGetEntry(ctx context.Context, key string) (*dmap.Entry, error) {
+	h := dm.client.hedge
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	primary := make(chan hedgeResult, 1)
+	start := time.Now()
+	go func() {
+		e, err := dm.dm.GetEntry(ctx, key)
+		// A read that is canceled after the backup owner responds doesn't
+		// tell the latency of the partition owner.
+		if !errors.Is(err, context.Canceled) {
+			h.observe(time.Since(start))
+		}
+		primary <- hedgeResult{entry: e, err: err}
+	}()
+
+	timer := time.NewTimer(h.hedgeDelay())
+	defer timer.Stop()
+
+	select {
+	case r := <-primary:
+		return r.entry, r.err
+	case <-timer.C:
+	}
+
+	dm.client.metrics.recordHedge("GetEntry")
+	backup := make(chan hedgeResult, 1)
+	go func() {
+		e, err := dm.dm.GetEntryFromBackup(ctx, key)
+		backup <- hedgeResult{entry: e, err: err}
+	}()
+
+	select {
+	case r := <-primary:
+		return r.entry, r.err
+	case r := <-backup:
+		if r.err == nil {
+			return r.entry, nil
+		}
+		if !errors.Is(r.err, dmap.ErrNoBackup) && !errors.Is(r.err, dmap.ErrKeyNotFound) {
+			dm.client.db.log.V(6).Printf("[DEBUG] Hedged read of key: %s on DMap: %s failed: %v", key, dm.name, r.err)
+		}
+	}
+
+	// The backup owner failed, wait for the partition owner.
+	r := <-primary
+	return r.entry, r.err
+}
@@ -0,0 +1,128 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgePolicy_Delay(t *testing.T) {
+	cfg := &embeddedClientConfig{}
+	WithHedgedReads(95, 5*time.Millisecond)(cfg)
+	h := cfg.hedge
+
+	// minDelay is used until enough calls are observed.
+	require.Equal(t, 5*time.Millisecond, h.hedgeDelay())
+
+	for i := 1; i <= hedgeRecompute; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, 96*time.Millisecond, h.hedgeDelay())
+
+	// The delay is never shorter than minDelay.
+	for i := 0; i < hedgeSamples; i++ {
+		h.observe(time.Microsecond)
+	}
+	require.Equal(t, 5*time.Millisecond, h.hedgeDelay())
+}
+
+func TestEmbeddedClient_HedgedReads(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	db1 := cluster.addMemberWithConfig(t, c1, "")
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	db2 := cluster.addMemberWithConfig(t, c2, "")
+
+	ctx := context.Background()
+	_, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Hedge every read.
+	e := db1.NewEmbeddedClient(WithHedgedReads(50, time.Nanosecond))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		gr, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+
+	_, err = dm.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.Greater(t, e.Metrics().Hedges, int64(0))
+}
+
+func TestEmbeddedClient_HedgedReads_ReadStats(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	db1 := cluster.addMemberWithConfig(t, c1, "")
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	db2 := cluster.addMemberWithConfig(t, c2, "")
+
+	ctx := context.Background()
+	_, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Hedge every read.
+	e := db1.NewEmbeddedClient(WithHedgedReads(50, time.Nanosecond))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Read the keys owned by db1, the backup owner doesn't count the reads.
+	var keys []string
+	for i := 0; len(keys) < 11; i++ {
+		key := testutil.ToKey(i)
+		hkey := partitions.HKey("mydmap", key)
+		if db1.primary.PartitionByHKey(hkey).Owner().CompareByID(db1.rt.This()) {
+			keys = append(keys, key)
+		}
+	}
+	missing, keys := keys[10], keys[:10]
+	for i, key := range keys {
+		_, err = dm.Put(ctx, key, i)
+		require.NoError(t, err)
+	}
+
+	hits, misses := dmap.GetHits.Read(), dmap.GetMisses.Read()
+	for _, key := range keys {
+		_, err = dm.Get(ctx, key)
+		require.NoError(t, err)
+	}
+	_, err = dm.Get(ctx, missing)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.Equal(t, int64(len(keys)), dmap.GetHits.Read()-hits)
+	require.Equal(t, int64(1), dmap.GetMisses.Read()-misses)
+}
@@ -58,7 +58,7 @@ func TestIntegration_NodesJoinOrLeftDuringQuery(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := 0; i < 100000; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 		if i == 5999 {
 			cluster.addMemberWithConfig(t, newConfig(), "mydmap")
@@ -125,13 +125,13 @@ func TestIntegration_DMap_Cache_Eviction_LRU_MaxKeys(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := 0; i < maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
 	var total int
 	for i := 0; i < maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue", NX())
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue", NX())
 		if err == ErrKeyFound {
 			err = nil
 		} else {
@@ -172,13 +172,13 @@ func TestIntegration_DMap_Cache_Eviction_MaxKeys(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := 0; i < maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
 	var total int
 	for i := maxKeys; i < 2*maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue", NX())
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue", NX())
 		if err == ErrKeyFound {
 			err = nil
 		} else {
@@ -227,7 +227,7 @@ func TestIntegration_DMap_Cache_Eviction_MaxIdleDuration(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := 0; i < maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
@@ -275,7 +275,7 @@ func TestIntegration_DMap_Cache_Eviction_TTLDuration(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := 0; i < maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
@@ -324,7 +324,7 @@ func TestIntegration_DMap_Cache_Eviction_LRU_MaxInuse(t *testing.T) {
 	require.NoError(t, err)
 
 	for i := 0; i < maxKeys; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
@@ -381,7 +381,7 @@ func TestIntegration_Kill_Nodes_During_Operation(t *testing.T) {
 	t.Log("Insert keys")
 
 	for i := 0; i < 100000; i++ {
-		err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
+		_, err = dm.Put(ctx, fmt.Sprintf("mykey-%d", i), "myvalue")
 		require.NoError(t, err)
 	}
 
@@ -0,0 +1,107 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"fmt"
+)
+
+// ClientCommand describes a command of EmbeddedClient, see Interceptor.
+type ClientCommand struct {
+	// Name is the name of the command, as it's recorded in ClientMetrics,
+	// e.g. "Put" or "GetEntry".
+	Name string
+
+	// DMap is the name of the DMap.
+	DMap string
+
+	// Keys is the keys of the command. It's empty for the commands that don't
+	// take a key, like Query. An interceptor can replace the keys before
+	// calling invoke, e.g. to add a prefix to them, but it cannot change their
+	// number. The rewritten keys are not visible to the caller: Entry.Key and
+	// the mirrored writes keep the original ones, while Query and Scan return
+	// the keys as they are stored.
+	Keys []string
+}
+
+// Invoker runs a command with the given context.
+type Invoker func(ctx context.Context) error
+
+// commandFunc runs a command with the keys that are set by the interceptors.
+type commandFunc func(ctx context.Context, cmd *ClientCommand) error
+
+// Interceptor is called with the commands of EmbeddedClient that are recorded
+// in ClientMetrics. It runs the command by calling invoke, with ctx or a
+// context derived from it, and returns its error. It can also fail the
+// command without calling invoke, e.g. to reject it or to inject faults. The
+// retried commands go through the interceptors on every attempt.
+type Interceptor func(ctx context.Context, cmd *ClientCommand, invoke Invoker) error
+
+// WithInterceptor adds an interceptor to the client, to observe the commands
+// for metrics or auditing, or to rewrite their keys. The interceptors are
+// chained in the order they are added, the first one is the outermost.
+func WithInterceptor(i Interceptor) EmbeddedClientOption {
+	return func(cfg *embeddedClientConfig) {
+		cfg.interceptors = append(cfg.interceptors, i)
+	}
+}
+
+func (dm *EmbeddedDMap) command(name string, keys ...string) *ClientCommand {
+	return &ClientCommand{
+		Name: name,
+		DMap: dm.name,
+		Keys: keys,
+	}
+}
+
+// intercept runs the command through the interceptors of the client. Every
+// attempt works on a copy of the command, so the keys are not rewritten twice
+// when the command is retried.
+func (e *EmbeddedClient) intercept(ctx context.Context, cmd *ClientCommand, f commandFunc) error {
+	c := *cmd
+	c.Keys = append([]string(nil), cmd.Keys...)
+	invoke := func(ctx context.Context) error {
+		ctx = e.context(ctx)
+		if len(c.Keys) != len(cmd.Keys) {
+			return fmt.Errorf("%w: interceptor changed the number of keys", ErrInvalidKey)
+		}
+		return f(ctx, &c)
+	}
+	for i := len(e.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := e.interceptors[i], invoke
+		invoke = func(ctx context.Context) error {
+			return interceptor(ctx, &c, next)
+		}
+	}
+	return invoke(ctx)
+}
+
+// RegisterCommandHook registers a hook that is called with every command that
+// is received by this member, before it's served. The hooks can observe the
+// commands, replace their arguments or reject them with an error. They are
+// only called with the client commands: the commands that are redirected by
+// the other members have already gone through the hooks of the member that
+// received them, and the commands of EmbeddedClient go through its
+// interceptors instead. The hooks run in the order of their names, a hook that
+// was registered with the same name is replaced.
+func (e *EmbeddedClient) RegisterCommandHook(name string, h CommandHook) {
+	e.db.server.RegisterCommandHook(name, h)
+}
+
+// UnregisterCommandHook removes the hook with the given name.
+func (e *EmbeddedClient) UnregisterCommandHook(name string) {
+	e.db.server.UnregisterCommandHook(name)
+}
@@ -0,0 +1,200 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_WithInterceptor(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	var order []string
+	var audit []ClientCommand
+	errChaos := errors.New("chaos")
+	e := db.NewEmbeddedClient(
+		WithInterceptor(func(ctx context.Context, cmd *ClientCommand, invoke Invoker) error {
+			order = append(order, "audit")
+			audit = append(audit, *cmd)
+			return invoke(ctx)
+		}),
+		WithInterceptor(func(ctx context.Context, cmd *ClientCommand, invoke Invoker) error {
+			order = append(order, "chaos")
+			for _, key := range cmd.Keys {
+				if key == "unlucky" {
+					return errChaos
+				}
+			}
+			return invoke(ctx)
+		}),
+	)
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+	require.Equal(t, []string{"audit", "chaos"}, order)
+	require.Equal(t, []ClientCommand{{Name: "Put", DMap: "mydmap", Keys: []string{"mykey"}}}, audit)
+
+	_, err = dm.Put(ctx, "unlucky", "myvalue")
+	require.ErrorIs(t, err, errChaos)
+	// The command is not run.
+	plain, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = plain.Get(ctx, "unlucky")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = dm.Delete(ctx, "mykey", "unlucky")
+	require.ErrorIs(t, err, errChaos)
+
+	m := e.Metrics()
+	require.Equal(t, int64(2), m.Commands["Put"].Calls)
+	require.Equal(t, int64(1), m.Commands["Put"].Errors)
+}
+
+func TestEmbeddedClient_RegisterCommandHook(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMemberWithConfig(t, nil, "mydmap")
+
+	e := db.NewEmbeddedClient()
+	e.RegisterCommandHook("readonly", func(cmd *ServerCommand) error {
+		if cmd.Name == protocol.DMap.Put {
+			return errors.New("read only")
+		}
+		return nil
+	})
+
+	rc := redis.NewClient(&redis.Options{Addr: db.rt.This().String()})
+	ctx := context.Background()
+
+	cmd := protocol.NewPut("mydmap", "mykey", []byte("myvalue")).Command(ctx)
+	err := rc.Process(ctx, cmd)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "read only")
+
+	e.UnregisterCommandHook("readonly")
+	cmd = protocol.NewPut("mydmap", "mykey", []byte("myvalue")).Command(ctx)
+	require.NoError(t, rc.Process(ctx, cmd))
+}
+
+func prefixKeys(ctx context.Context, cmd *ClientCommand, invoke Invoker) error {
+	for i, key := range cmd.Keys {
+		cmd.Keys[i] = "tenant:" + key
+	}
+	return invoke(ctx)
+}
+
+func TestEmbeddedClient_WithInterceptor_Rewrite_Keys(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient(WithInterceptor(prefixKeys))
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	plain, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	gr, err := plain.Get(ctx, "tenant:mykey")
+	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+
+	entry, err := dm.GetEntry(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, "mykey", entry.Key)
+
+	values, err := dm.IncrMany(ctx, map[string]int{"counter": 2})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"counter": 2}, values)
+	_, err = plain.Get(ctx, "tenant:counter")
+	require.NoError(t, err)
+
+	count, err := dm.Delete(ctx, "mykey", "counter")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+type tenantKey struct{}
+
+func TestEmbeddedClient_WithInterceptor_Rewrite_Keys_MonotonicReads(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	tenants := func(ctx context.Context, cmd *ClientCommand, invoke Invoker) error {
+		for i, key := range cmd.Keys {
+			cmd.Keys[i] = ctx.Value(tenantKey{}).(string) + ":" + key
+		}
+		return invoke(ctx)
+	}
+	dm, err := db.NewEmbeddedClient(WithInterceptor(tenants)).NewDMap("mydmap", MonotonicReads())
+	require.NoError(t, err)
+
+	ctxA := context.WithValue(context.Background(), tenantKey{}, "a")
+	ctxB := context.WithValue(context.Background(), tenantKey{}, "b")
+	_, err = dm.Put(ctxA, "mykey", "myvalue", TS(200))
+	require.NoError(t, err)
+	_, err = dm.Put(ctxB, "mykey", "myvalue", TS(100))
+	require.NoError(t, err)
+
+	// The timestamps are kept for the rewritten keys, they don't mix.
+	_, err = dm.Get(ctxA, "mykey")
+	require.NoError(t, err)
+	_, err = dm.Get(ctxB, "mykey")
+	require.NoError(t, err)
+}
+
+func TestEmbeddedClient_WithInterceptor_Rewrite_Keys_Retry(t *testing.T) {
+	e := &EmbeddedClient{
+		retry:        testRetryPolicy(3),
+		metrics:      newClientMetrics(),
+		interceptors: []Interceptor{prefixKeys},
+	}
+
+	var keys []string
+	cmd := &ClientCommand{Name: "Put", Keys: []string{"mykey"}}
+	err := e.do(context.Background(), cmd, func(_ context.Context, cmd *ClientCommand) error {
+		keys = append(keys, cmd.Keys[0])
+		if len(keys) < 3 {
+			return ErrWriteQuorum
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	// Every attempt rewrites the original keys.
+	require.Equal(t, []string{"tenant:mykey", "tenant:mykey", "tenant:mykey"}, keys)
+	require.Equal(t, []string{"mykey"}, cmd.Keys)
+
+	e.interceptors = []Interceptor{func(ctx context.Context, cmd *ClientCommand, invoke Invoker) error {
+		cmd.Keys = nil
+		return invoke(ctx)
+	}}
+	err = e.do(context.Background(), cmd, func(context.Context, *ClientCommand) error {
+		return nil
+	})
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
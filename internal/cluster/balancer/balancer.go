@@ -18,6 +18,7 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/buraksezer/olric/config"
@@ -25,8 +26,12 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/ratelimit"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/service"
 	"github.com/buraksezer/olric/pkg/flog"
+	"golang.org/x/sync/semaphore"
 )
 
 type Balancer struct {
@@ -34,27 +39,50 @@ type Balancer struct {
 
 	log     *flog.Logger
 	config  *config.Config
+	runtime atomic.Value // *config.Config
 	primary *partitions.Partitions
 	backup  *partitions.Partitions
 	rt      *routingtable.RoutingTable
+	server  *server.Server
+	paused  int32
+	running int32
 	wg      sync.WaitGroup
 	ctx     context.Context
 	cancel  context.CancelFunc
+
+	bandwidth ratelimit.Limiter
+	entryRate ratelimit.Limiter
 }
 
 func New(e *environment.Environment) *Balancer {
 	c := e.Get("config").(*config.Config)
 	log := e.Get("logger").(*flog.Logger)
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Balancer{
+	b := &Balancer{
 		config:  c,
 		primary: e.Get("primary").(*partitions.Partitions),
 		backup:  e.Get("backup").(*partitions.Partitions),
 		rt:      e.Get("routingtable").(*routingtable.RoutingTable),
+		server:  e.Get("server").(*server.Server),
 		log:     log,
 		ctx:     ctx,
 		cancel:  cancel,
 	}
+	b.runtime.Store(c)
+	b.RegisterHandlers()
+	return b
+}
+
+// runtimeConfig returns the latest configuration applied by ReloadConfig.
+func (b *Balancer) runtimeConfig() *config.Config {
+	return b.runtime.Load().(*config.Config)
+}
+
+// ReloadConfig applies the partition transfer throttles and the balancer
+// window in the given configuration. The given configuration must not be
+// modified by the caller after the call.
+func (b *Balancer) ReloadConfig(c *config.Config) {
+	b.runtime.Store(c)
 }
 
 func (b *Balancer) isAlive() bool {
@@ -67,6 +95,16 @@ func (b *Balancer) isAlive() bool {
 	return true
 }
 
+// throttle blocks until the fragment can be sent without exceeding
+// PartitionTransferBandwidth and PartitionTransferEntryRate.
+func (b *Balancer) throttle(f partitions.Fragment) error {
+	st := f.Stats()
+	if err := b.bandwidth.Wait(b.ctx, b.runtimeConfig().PartitionTransferBandwidth, st.Inuse); err != nil {
+		return err
+	}
+	return b.entryRate.Wait(b.ctx, b.runtimeConfig().PartitionTransferEntryRate, st.Length)
+}
+
 func (b *Balancer) scanPartition(sign uint64, part *partitions.Partition, owners ...discovery.Member) {
 	ownersStr := func() string {
 		var names []string
@@ -83,10 +121,16 @@ func (b *Balancer) scanPartition(sign uint64, part *partitions.Partition, owners
 		}
 		name := strings.TrimPrefix(rawName.(string), "dmap.")
 
+		err := b.throttle(f)
+		if err != nil {
+			// The node is gone.
+			return false
+		}
+
 		b.log.V(2).Printf("[INFO] Moving %s fragment: %s (kind: %s) on PartID: %d to %s",
 			f.Name(), name, part.Kind(), part.ID(), ownersStr)
 
-		err := f.Move(part, name, owners)
+		err = f.Move(part, name, owners)
 		if err != nil {
 			b.log.V(2).Printf("[ERROR] Failed to move %s fragment: %s on PartID: %d to %s: %v",
 				f.Name(), name, part.ID(), ownersStr, err)
@@ -97,7 +141,26 @@ func (b *Balancer) scanPartition(sign uint64, part *partitions.Partition, owners
 	})
 }
 
+// transfer runs fn for a partition without exceeding MaxConcurrentPartitionTransfers.
+func (b *Balancer) transfer(sem *semaphore.Weighted, wg *sync.WaitGroup, fn func()) bool {
+	if err := sem.Acquire(b.ctx, 1); err != nil {
+		// The node is gone.
+		return false
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer sem.Release(1)
+		fn()
+	}()
+	return true
+}
+
 func (b *Balancer) primaryCopies() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := semaphore.NewWeighted(int64(b.runtimeConfig().MaxConcurrentPartitionTransfers))
 	sign := b.rt.Signature()
 	for partID := uint64(0); partID < b.config.PartitionCount; partID++ {
 		if b.breakLoop(sign) {
@@ -121,7 +184,9 @@ func (b *Balancer) primaryCopies() {
 		}
 
 		// This is a previous owner. Move the keys.
-		b.scanPartition(sign, part, owner)
+		if !b.transfer(sem, &wg, func() { b.scanPartition(sign, part, owner) }) {
+			break
+		}
 	}
 }
 
@@ -140,48 +205,59 @@ func (b *Balancer) breakLoop(sign uint64) bool {
 }
 
 func (b *Balancer) backupCopies() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := semaphore.NewWeighted(int64(b.runtimeConfig().MaxConcurrentPartitionTransfers))
 	sign := b.rt.Signature()
-LOOP:
 	for partID := uint64(0); partID < b.config.PartitionCount; partID++ {
 		if b.breakLoop(sign) {
 			break
 		}
 
 		part := b.backup.PartitionByID(partID)
-		if part.Length() == 0 || part.OwnerCount() == 0 {
+		currentOwners := b.backupTargets(part)
+		if len(currentOwners) == 0 {
 			continue
 		}
 
-		var (
-			counter       = 1
-			currentOwners []discovery.Member
-		)
+		if !b.transfer(sem, &wg, func() { b.scanPartition(sign, part, currentOwners...) }) {
+			break
+		}
+	}
+}
 
-		owners := part.Owners()
-		for i := len(owners) - 1; i >= 0; i-- {
-			if counter > b.config.ReplicaCount-1 {
-				break
-			}
+// backupTargets returns the current owners of a backup partition, if this node
+// hosts data that belongs to them.
+func (b *Balancer) backupTargets(part *partitions.Partition) []discovery.Member {
+	if part.Length() == 0 || part.OwnerCount() == 0 {
+		return nil
+	}
 
-			counter++
-			owner := owners[i]
-			// Here we don't use CompareById function because the routing table
-			// is an eventually consistent data structure and a node can try to
-			// move data to previous instance(the same name but a different birthdate)
-			// of itself. So just check the name.
-			if b.rt.This().CompareByName(owner) {
-				// Already belongs to me.
-				continue LOOP
-			}
-			currentOwners = append(currentOwners, owner)
-		}
+	var (
+		counter       = 1
+		currentOwners []discovery.Member
+	)
 
-		if len(currentOwners) == 0 {
-			continue LOOP
+	owners := part.Owners()
+	for i := len(owners) - 1; i >= 0; i-- {
+		if counter > b.config.ReplicaCount-1 {
+			break
 		}
 
-		b.scanPartition(sign, part, currentOwners...)
+		counter++
+		owner := owners[i]
+		// Here we don't use CompareById function because the routing table
+		// is an eventually consistent data structure and a node can try to
+		// move data to previous instance(the same name but a different birthdate)
+		// of itself. So just check the name.
+		if b.rt.This().CompareByName(owner) {
+			// Already belongs to me.
+			return nil
+		}
+		currentOwners = append(currentOwners, owner)
 	}
+	return currentOwners
 }
 
 func (b *Balancer) triggerBalancer() {
@@ -193,6 +269,19 @@ func (b *Balancer) triggerBalancer() {
 		return
 	}
 
+	if b.IsPaused() {
+		b.log.V(6).Printf("[DEBUG] Balancer is paused")
+		return
+	}
+
+	if !b.inWindow(time.Now()) {
+		b.log.V(6).Printf("[DEBUG] Balancer is out of the scheduling window")
+		return
+	}
+
+	atomic.StoreInt32(&b.running, 1)
+	defer atomic.StoreInt32(&b.running, 0)
+
 	b.primaryCopies()
 
 	if b.config.ReplicaCount > config.MinimumReplicaCount {
@@ -200,6 +289,74 @@ func (b *Balancer) triggerBalancer() {
 	}
 }
 
+// Status describes the state of the balancer on this node.
+type Status struct {
+	Paused   bool
+	InWindow bool
+	Running  bool
+
+	// PendingPrimary and PendingBackup are the number of partitions hosted
+	// on this node that have to be moved to their new owners.
+	PendingPrimary int
+	PendingBackup  int
+}
+
+// Status returns the current state of the balancer. It doesn't block
+// while the balancer is running.
+func (b *Balancer) Status() Status {
+	s := Status{
+		Paused:   b.IsPaused(),
+		InWindow: b.inWindow(time.Now()),
+		Running:  atomic.LoadInt32(&b.running) == 1,
+	}
+	for partID := uint64(0); partID < b.config.PartitionCount; partID++ {
+		part := b.primary.PartitionByID(partID)
+		if part.Length() != 0 && part.OwnerCount() != 0 && !part.Owner().CompareByName(b.rt.This()) {
+			s.PendingPrimary++
+		}
+		if b.config.ReplicaCount > config.MinimumReplicaCount {
+			if len(b.backupTargets(b.backup.PartitionByID(partID))) != 0 {
+				s.PendingBackup++
+			}
+		}
+	}
+	return s
+}
+
+// Pause stops moving partitions until Resume is called. A transfer that is
+// in progress is not interrupted.
+func (b *Balancer) Pause() {
+	atomic.StoreInt32(&b.paused, 1)
+}
+
+// Resume lets the balancer move partitions again.
+func (b *Balancer) Resume() {
+	atomic.StoreInt32(&b.paused, 0)
+}
+
+// IsPaused returns true if the balancer is paused.
+func (b *Balancer) IsPaused() bool {
+	return atomic.LoadInt32(&b.paused) == 1
+}
+
+// inWindow checks the given time against BalancerWindowStart and BalancerWindowEnd.
+func (b *Balancer) inWindow(now time.Time) bool {
+	c := b.runtimeConfig()
+	start, end := c.BalancerWindowStart, c.BalancerWindowEnd
+	if start == end {
+		// No window is defined.
+		return true
+	}
+
+	year, month, day := now.Date()
+	offset := now.Sub(time.Date(year, month, day, 0, 0, 0, 0, now.Location()))
+	if start < end {
+		return offset >= start && offset < end
+	}
+	// The window wraps around midnight.
+	return offset >= start || offset < end
+}
+
 func (b *Balancer) BalanceEagerly() {
 	b.triggerBalancer()
 }
@@ -227,7 +384,9 @@ func (b *Balancer) Start() error {
 	return nil
 }
 
-func (b *Balancer) RegisterHandlers() {}
+func (b *Balancer) RegisterHandlers() {
+	b.server.ServeMux().HandleFunc(protocol.Internal.RecreateBackups, b.recreateBackupsCommandHandler)
+}
 
 func (b *Balancer) Shutdown(ctx context.Context) error {
 	select {
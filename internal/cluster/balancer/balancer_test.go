@@ -29,6 +29,7 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/eventbus"
 	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/buraksezer/olric/internal/testutil/mockfragment"
@@ -47,6 +48,7 @@ func newTestEnvironment(c *config.Config) *environment.Environment {
 	e.Set("primary", partitions.New(c.PartitionCount, partitions.PRIMARY))
 	e.Set("backup", partitions.New(c.PartitionCount, partitions.BACKUP))
 	e.Set("client", server.NewClient(c.Client))
+	e.Set("eventbus", eventbus.New())
 	return e
 }
 
@@ -292,3 +294,39 @@ func TestBalance_Backup_Move(t *testing.T) {
 		require.Equal(t, []discovery.Member{b2.rt.This()}, r[partID].Owners)
 	}
 }
+
+func TestBalance_InWindow(t *testing.T) {
+	c := testutil.NewConfig()
+	b := &Balancer{config: c}
+	b.runtime.Store(c)
+	at := func(hour int) time.Time {
+		return time.Date(2022, 1, 1, hour, 30, 0, 0, time.Local)
+	}
+
+	// No window
+	require.True(t, b.inWindow(at(12)))
+
+	c.BalancerWindowStart = 2 * time.Hour
+	c.BalancerWindowEnd = 6 * time.Hour
+	require.True(t, b.inWindow(at(3)))
+	require.False(t, b.inWindow(at(6)))
+	require.False(t, b.inWindow(at(1)))
+
+	// Wraps around midnight
+	c.BalancerWindowStart = 22 * time.Hour
+	c.BalancerWindowEnd = 4 * time.Hour
+	require.True(t, b.inWindow(at(23)))
+	require.True(t, b.inWindow(at(1)))
+	require.False(t, b.inWindow(at(12)))
+}
+
+func TestBalance_Pause_Resume(t *testing.T) {
+	b := &Balancer{}
+	require.False(t, b.IsPaused())
+
+	b.Pause()
+	require.True(t, b.IsPaused())
+
+	b.Resume()
+	require.False(t, b.IsPaused())
+}
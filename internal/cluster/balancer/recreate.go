@@ -0,0 +1,93 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"strings"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+// backupOwners returns the current owners of a backup partition except this node.
+func (b *Balancer) backupOwners(part *partitions.Partition) []discovery.Member {
+	var result []discovery.Member
+	owners := part.Owners()
+	for i := len(owners) - 1; i >= 0 && len(result) < b.config.ReplicaCount-1; i-- {
+		if owners[i].CompareByName(b.rt.This()) {
+			continue
+		}
+		result = append(result, owners[i])
+	}
+	return result
+}
+
+// recreateBackups copies the primary partitions owned by this node to the
+// backup owners. The receivers merge the copies with the data they already
+// have, so it's safe to copy a partition to a member that already hosts it.
+func (b *Balancer) recreateBackups() {
+	for partID := uint64(0); partID < b.config.PartitionCount; partID++ {
+		if !b.isAlive() {
+			return
+		}
+
+		part := b.primary.PartitionByID(partID)
+		if part.Length() == 0 || part.OwnerCount() == 0 || !part.Owner().CompareByName(b.rt.This()) {
+			continue
+		}
+
+		backup := b.backup.PartitionByID(partID)
+		owners := b.backupOwners(backup)
+		if len(owners) == 0 {
+			continue
+		}
+
+		part.Map().Range(func(rawName, rawFragment interface{}) bool {
+			f := rawFragment.(partitions.Fragment)
+			if f.Stats().Length == 0 {
+				return true
+			}
+			name := strings.TrimPrefix(rawName.(string), "dmap.")
+			if err := b.throttle(f); err != nil {
+				// The node is gone.
+				return false
+			}
+			if err := f.Copy(backup, name, owners); err != nil {
+				b.log.V(2).Printf("[ERROR] Failed to re-create backups of %s fragment: %s on PartID: %d: %v",
+					f.Name(), name, partID, err)
+			}
+			return true
+		})
+	}
+}
+
+func (b *Balancer) recreateBackupsCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParseRecreateBackupsCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		b.log.V(2).Printf("[INFO] Re-creating the backups of the primary partitions on %s", b.rt.This())
+		b.recreateBackups()
+	}()
+	conn.WriteString(protocol.StatusOK)
+}
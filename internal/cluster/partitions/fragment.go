@@ -23,6 +23,7 @@ type Fragment interface {
 	Name() string
 	Stats() storage.Stats
 	Move(*Partition, string, []discovery.Member) error
+	Copy(*Partition, string, []discovery.Member) error
 	Compaction() (bool, error)
 	Destroy() error
 	Close() error
@@ -15,6 +15,8 @@
 package partitions
 
 import (
+	"math"
+	"strings"
 	"sync"
 	"unsafe"
 
@@ -32,7 +34,64 @@ func SetHashFunc(h hasher.Hasher) {
 	})
 }
 
+// HashTag returns the hash tag of the key, the non-empty substring between the
+// first "{" and the first "}" after it, as in Redis Cluster.
+func HashTag(key string) (string, bool) {
+	start := strings.IndexByte(key, '{')
+	if start == -1 {
+		return "", false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return "", false
+	}
+	return key[start+1 : start+1+end], true
+}
+
+func sum64(data string) uint64 {
+	return hashFunc.Sum64(*(*[]byte)(unsafe.Pointer(&data)))
+}
+
+// TagPartitionID returns the ID of the partition that stores the keys with the
+// given hash tag, among count partitions.
+func TagPartitionID(tag string, count uint64) uint64 {
+	return sum64(tag) % count
+}
+
+// KeyPartitionID returns the ID of the partition that stores the key in the
+// given data structure, among count partitions. hashTags has to be true if
+// the data structure co-locates the keys with the same hash tag, see TaggedHKey.
+func KeyPartitionID(name, key string, count uint64, hashTags bool) uint64 {
+	if hashTags {
+		return TaggedHKey(name, key, count) % count
+	}
+	return HKey(name, key) % count
+}
+
+// HKey returns the hash of the key in the given data structure. The partition
+// of a key is HKey modulo the partition count.
 func HKey(name, key string) uint64 {
-	tmp := name + key
-	return hashFunc.Sum64(*(*[]byte)(unsafe.Pointer(&tmp)))
+	return sum64(name + key)
+}
+
+// TaggedHKey returns the hash of the key in the given data structure, among
+// count partitions. If the key has a hash tag, the partition is derived from
+// the tag only, so the keys with the same tag are stored on the same
+// partition, even in different data structures. Otherwise, it's equal to HKey.
+func TaggedHKey(name, key string, count uint64) uint64 {
+	hkey := HKey(name, key)
+
+	tag, ok := HashTag(key)
+	if !ok || count == 0 {
+		return hkey
+	}
+
+	// Replace the remainder of the hash, the rest of it still identifies the
+	// key in the storage engine.
+	partID := TagPartitionID(tag, count)
+	base := hkey - hkey%count
+	if base > math.MaxUint64-count {
+		base -= count
+	}
+	return base + partID
 }
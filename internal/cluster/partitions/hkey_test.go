@@ -26,3 +26,51 @@ func TestPartitions_HKey(t *testing.T) {
 	hkey := HKey("storage-unit-name", "some-key")
 	require.NotEqualf(t, 0, hkey, "HKey is zero. This shouldn't be normal")
 }
+
+func TestPartitions_HashTag(t *testing.T) {
+	tag, ok := HashTag("{user:1}.profile")
+	require.True(t, ok)
+	require.Equal(t, "user:1", tag)
+
+	tag, ok = HashTag("orders.{user:1}.{x}")
+	require.True(t, ok)
+	require.Equal(t, "user:1", tag)
+
+	for _, key := range []string{"user:1", "{}.profile", "{user:1", "}{"} {
+		_, ok = HashTag(key)
+		require.Falsef(t, ok, "%s has a hash tag", key)
+	}
+}
+
+func TestPartitions_TaggedHKey(t *testing.T) {
+	SetHashFunc(hasher.NewDefaultHasher())
+
+	profile := TaggedHKey("users", "{user:1}.profile", 271)
+	orders := TaggedHKey("orders", "{user:1}.orders", 271)
+	require.NotEqual(t, profile, orders)
+	require.Equal(t, profile%271, orders%271)
+
+	// The keys without a hash tag are not affected.
+	require.Equal(t, HKey("users", "user:1"), TaggedHKey("users", "user:1", 271))
+
+	// HKey doesn't co-locate the keys with the same hash tag.
+	require.Equal(t, sum64("users{user:1}.profile"), HKey("users", "{user:1}.profile"))
+}
+
+func TestPartitions_TagPartitionID(t *testing.T) {
+	SetHashFunc(hasher.NewDefaultHasher())
+
+	partID := TagPartitionID("user:1", 271)
+	require.Less(t, partID, uint64(271))
+	require.Equal(t, partID, TaggedHKey("users", "{user:1}.profile", 271)%271)
+	require.Equal(t, partID, TaggedHKey("orders", "orders.{user:1}", 271)%271)
+}
+
+func TestPartitions_KeyPartitionID(t *testing.T) {
+	SetHashFunc(hasher.NewDefaultHasher())
+
+	for _, key := range []string{"foo", "bar", "{user:1}.profile"} {
+		require.Equal(t, HKey("mydmap", key)%271, KeyPartitionID("mydmap", key, 271, false))
+		require.Equal(t, TaggedHKey("mydmap", key, 271)%271, KeyPartitionID("mydmap", key, 271, true))
+	}
+}
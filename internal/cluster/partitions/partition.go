@@ -29,6 +29,8 @@ type Partition struct {
 	kind   Kind
 	m      *sync.Map
 	owners atomic.Value
+	epoch  uint64
+	fenced uint64
 }
 
 func (p *Partition) Kind() Kind {
@@ -78,6 +80,27 @@ func (p *Partition) SetOwners(owners []discovery.Member) {
 	p.owners.Store(owners)
 }
 
+// Epoch returns the ownership epoch of the partition. It's increased by the
+// cluster coordinator when the owners of the partition change.
+func (p *Partition) Epoch() uint64 {
+	return atomic.LoadUint64(&p.epoch)
+}
+
+func (p *Partition) SetEpoch(epoch uint64) {
+	atomic.StoreUint64(&p.epoch, epoch)
+}
+
+// Fence marks the current epoch of the partition as stale. The partition stays
+// fenced until a routing table with another epoch is applied.
+func (p *Partition) Fence() {
+	atomic.StoreUint64(&p.fenced, p.Epoch()+1)
+}
+
+// Fenced returns true if the current epoch of the partition is fenced.
+func (p *Partition) Fenced() bool {
+	return atomic.LoadUint64(&p.fenced) == p.Epoch()+1
+}
+
 func (p *Partition) Length() int {
 	var length int
 	p.Map().Range(func(_, tmp interface{}) bool {
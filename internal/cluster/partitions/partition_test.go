@@ -41,6 +41,10 @@ func (tf *testFragment) Move(_ *Partition, _ string, _ []discovery.Member) error
 	return nil
 }
 
+func (tf *testFragment) Copy(_ *Partition, _ string, _ []discovery.Member) error {
+	return nil
+}
+
 func (tf *testFragment) Close() error {
 	return nil
 }
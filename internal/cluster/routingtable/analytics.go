@@ -0,0 +1,54 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"sort"
+
+	"github.com/buraksezer/olric/internal/discovery"
+)
+
+// addToHashRing adds the member to the consistent hash ring, unless it's an
+// analytics replica. The analytics replicas never own a partition.
+func (r *RoutingTable) addToHashRing(member discovery.Member) {
+	if member.Analytics {
+		r.log.V(2).Printf("[INFO] Analytics replica: %s is not added to the hash ring", member)
+		return
+	}
+	r.consistent.Add(member)
+}
+
+func (r *RoutingTable) hasPartitionOwners() bool {
+	return len(r.consistent.GetMembers()) != 0
+}
+
+// AnalyticsMembers returns the analytics replicas in the cluster, sorted by
+// their names.
+func (r *RoutingTable) AnalyticsMembers() []discovery.Member {
+	var result []discovery.Member
+	r.Members().RLock()
+	r.Members().Range(func(_ uint64, member discovery.Member) bool {
+		if member.Analytics {
+			result = append(result, member)
+		}
+		return true
+	})
+	r.Members().RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,83 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+// ErrStaleRoutingTable means that the routing table of this member is not the
+// latest one that is pushed by the cluster coordinator.
+var ErrStaleRoutingTable = errors.New("routing table is stale")
+
+// coordinatorSignature returns the signature of the latest routing table that
+// is pushed by this member. A new coordinator doesn't push a routing table
+// until the cluster changes, so the current one is the latest one.
+func (r *RoutingTable) coordinatorSignature() uint64 {
+	signature := atomic.LoadUint64(&r.pushedSignature)
+	if signature == 0 {
+		return r.Signature()
+	}
+	return signature
+}
+
+func (r *RoutingTable) routingSignatureCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParseRoutingSignatureCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	if !r.discovery.IsCoordinator() {
+		protocol.WriteError(conn, ErrNotCoordinator)
+		return
+	}
+	// The signature is sent as int64, it's converted back on the caller.
+	conn.WriteInt64(int64(r.coordinatorSignature()))
+}
+
+// ReadBarrier checks the routing table of this member against the latest one
+// that is pushed by the cluster coordinator. It returns ErrStaleRoutingTable if
+// they are different, so a partition owner can confirm that it still owns the
+// partition before serving a read.
+func (r *RoutingTable) ReadBarrier(ctx context.Context) error {
+	var signature uint64
+	if r.discovery.IsCoordinator() {
+		signature = r.coordinatorSignature()
+	} else {
+		coordinator := r.discovery.GetCoordinator()
+		cmd := protocol.NewRoutingSignature().Command(ctx)
+		rc := r.client.Get(coordinator.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return protocol.ConvertError(err)
+		}
+		result, err := cmd.Result()
+		if err != nil {
+			return protocol.ConvertError(err)
+		}
+		signature = uint64(result)
+	}
+
+	if signature != r.Signature() {
+		return ErrStaleRoutingTable
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRoutingTable_ReadBarrier(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	rt1, err := cluster.addNode(testutil.NewConfig())
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	rt2, err := cluster.addNode(testutil.NewConfig())
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		if !rt2.IsBootstrapped() || rt1.Signature() != rt2.Signature() {
+			return errors.New("the routing table is not pushed to the second node")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, rt := range []*RoutingTable{rt1, rt2} {
+		if err = rt.ReadBarrier(ctx); err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+	}
+
+	// Simulate a routing table that is not pushed to the second node.
+	rt2.setSignature(rt2.Signature() + 1)
+	if err = rt2.ReadBarrier(ctx); !errors.Is(err, ErrStaleRoutingTable) {
+		t.Fatalf("Expected ErrStaleRoutingTable. Got: %v", err)
+	}
+
+	if err = rt1.ReadBarrier(ctx); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+}
+
+func TestRoutingTable_SignatureOf(t *testing.T) {
+	m1 := discovery.Member{Name: "localhost:3320", ID: 1}
+	m2 := discovery.Member{Name: "localhost:3321", ID: 2}
+
+	table := make(map[uint64]*route)
+	for partID := uint64(0); partID < 271; partID++ {
+		table[partID] = &route{
+			Owners:  []discovery.Member{m1},
+			Backups: []discovery.Member{m2},
+			Epoch:   partID,
+		}
+	}
+	signature := signatureOf(table)
+
+	// The signature doesn't depend on the order of the keys in the payload.
+	for i := 0; i < 10; i++ {
+		data, err := msgpack.Marshal(table)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		decoded := make(map[uint64]*route)
+		if err = msgpack.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if s := signatureOf(decoded); s != signature {
+			t.Fatalf("Expected signature: %d. Got: %d", signature, s)
+		}
+	}
+
+	table[42].Owners = []discovery.Member{m2}
+	if signatureOf(table) == signature {
+		t.Fatalf("Expected a different signature")
+	}
+}
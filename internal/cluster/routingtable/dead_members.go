@@ -0,0 +1,150 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// deadMemberCheckInterval is the interval between two sequential checks of the
+// departed members.
+const deadMemberCheckInterval = time.Second
+
+type departure struct {
+	member discovery.Member
+	since  time.Time
+}
+
+// departures keeps the members that left the cluster. A member is forgotten
+// if it joins again with the same name.
+type departures struct {
+	mtx     sync.Mutex
+	members map[string]departure
+}
+
+func newDepartures() *departures {
+	return &departures{
+		members: make(map[string]departure),
+	}
+}
+
+func (d *departures) add(member discovery.Member, now time.Time) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.members[member.Name] = departure{member: member, since: now}
+}
+
+func (d *departures) remove(name string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	delete(d.members, name)
+}
+
+// expired removes and returns the members that have been gone longer than timeout.
+func (d *departures) expired(now time.Time, timeout time.Duration) []discovery.Member {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	var result []discovery.Member
+	for name, dp := range d.members {
+		if now.Sub(dp.since) >= timeout {
+			result = append(result, dp.member)
+			delete(d.members, name)
+		}
+	}
+	return result
+}
+
+func (p *placement) forget(member discovery.Member) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	delete(p.drained, member.ID)
+	for partID, pinned := range p.pinned {
+		if pinned.CompareByID(member) {
+			delete(p.pinned, partID)
+		}
+	}
+}
+
+// cleanupDeadMembers permanently removes the members that have been dead for
+// longer than DeadMemberTimeout and asks the cluster to re-create the backups
+// hosted by them. It's only run by the cluster coordinator.
+func (r *RoutingTable) cleanupDeadMembers(now time.Time) {
+	timeout := r.runtimeConfig().DeadMemberTimeout
+	if timeout == 0 {
+		return
+	}
+
+	dead := r.departures.expired(now, timeout)
+	if len(dead) == 0 || !r.discovery.IsCoordinator() {
+		return
+	}
+
+	for _, member := range dead {
+		r.placement.forget(member)
+		r.log.V(2).Printf("[INFO] %s has been dead for longer than %v. It's removed permanently", member, timeout)
+	}
+
+	// Prune the dead members from the partition owners.
+	r.updateRouting("dead member cleanup")
+	if r.config.ReplicaCount > config.MinimumReplicaCount {
+		r.recreateBackupsOnCluster()
+	}
+}
+
+func (r *RoutingTable) recreateBackupsOnCluster() {
+	var members []discovery.Member
+	r.Members().RLock()
+	r.Members().Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	r.Members().RUnlock()
+
+	for _, member := range members {
+		cmd := protocol.NewRecreateBackups().Command(r.ctx)
+		rc := r.client.Get(member.String())
+		err := rc.Process(r.ctx, cmd)
+		if err == nil {
+			err = cmd.Err()
+		}
+		if err != nil {
+			r.log.V(2).Printf("[ERROR] Failed to re-create backups on %s: %v", member, err)
+		}
+	}
+}
+
+func (r *RoutingTable) cleanupDeadMembersPeriodically() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(deadMemberCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.cleanupDeadMembers(now)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+)
+
+func TestDepartures_Expired(t *testing.T) {
+	d := newDepartures()
+	now := time.Now()
+	member := discovery.Member{
+		Name: "localhost:3320",
+		ID:   6054057,
+	}
+	d.add(member, now)
+
+	if dead := d.expired(now.Add(time.Second), time.Minute); len(dead) != 0 {
+		t.Fatalf("Expected no dead member. Got: %v", dead)
+	}
+
+	dead := d.expired(now.Add(time.Minute), time.Minute)
+	if len(dead) != 1 || !dead[0].CompareByID(member) {
+		t.Fatalf("Expected %s. Got: %v", member, dead)
+	}
+
+	if dead := d.expired(now.Add(time.Hour), time.Minute); len(dead) != 0 {
+		t.Fatalf("Expected no dead member. Got: %v", dead)
+	}
+}
+
+func TestDepartures_Rejoin(t *testing.T) {
+	d := newDepartures()
+	now := time.Now()
+	member := discovery.Member{
+		Name: "localhost:3320",
+		ID:   6054057,
+	}
+	d.add(member, now)
+	d.remove(member.Name)
+
+	if dead := d.expired(now.Add(time.Hour), time.Minute); len(dead) != 0 {
+		t.Fatalf("Expected no dead member. Got: %v", dead)
+	}
+}
+
+func TestPlacement_Forget(t *testing.T) {
+	p := newPlacement()
+	member := discovery.Member{
+		Name: "localhost:3320",
+		ID:   6054057,
+	}
+	p.drained[member.ID] = member
+	p.pinned[1] = member
+
+	p.forget(member)
+	if p.isDrained(member) {
+		t.Fatalf("Expected the member to be forgotten")
+	}
+	if _, ok := p.pinned[1]; ok {
+		t.Fatalf("Expected PartID: 1 to be unpinned")
+	}
+}
@@ -32,11 +32,19 @@ func (r *RoutingTable) bootstrapCoordinator() error {
 	r.Lock()
 	defer r.Unlock()
 
+	if !r.hasPartitionOwners() {
+		// An analytics replica cannot bootstrap the cluster alone, the routing
+		// table is calculated after a member that can own the partitions joins.
+		r.log.V(2).Printf("[WARN] Analytics replica waits for a member to own the partitions")
+		return nil
+	}
+
 	r.fillRoutingTable()
 	_, err := r.updateRoutingTableOnCluster()
 	if err != nil {
 		return err
 	}
+	r.recordOwnershipChanges(nil, r.table, "bootstrap")
 	// The coordinator bootstraps itself.
 	r.markBootstrapped()
 	r.log.V(2).Printf("[INFO] The cluster coordinator has been bootstrapped")
@@ -28,11 +28,11 @@ func (r *RoutingTable) distributePrimaryCopies(partID uint64) []discovery.Member
 	copy(owners, part.Owners())
 
 	// Find the new partition owner.
-	newOwner := r.consistent.GetPartitionOwner(int(partID))
+	newOwner := r.primaryOwnerOf(partID)
 
 	// First run.
 	if len(owners) == 0 {
-		owners = append(owners, newOwner.(discovery.Member))
+		owners = append(owners, newOwner)
 		return owners
 	}
 
@@ -85,14 +85,14 @@ func (r *RoutingTable) distributePrimaryCopies(partID uint64) []discovery.Member
 
 	// Here add the new partition newOwner.
 	for i, owner := range owners {
-		if owner.CompareByID(newOwner.(discovery.Member)) {
+		if owner.CompareByID(newOwner) {
 			// Remove it from the current position
 			owners = append(owners[:i], owners[i+1:]...)
 			// Append it again to head
-			return append(owners, newOwner.(discovery.Member))
+			return append(owners, newOwner)
 		}
 	}
-	return append(owners, newOwner.(discovery.Member))
+	return append(owners, newOwner)
 }
 
 func (r *RoutingTable) getReplicaOwners(partID uint64) ([]consistent.Member, error) {
@@ -131,8 +131,26 @@ func (r *RoutingTable) distributeBackups(partID uint64) []discovery.Member {
 		return nil
 	}
 
-	// Remove the primary owner
-	newOwners = newOwners[1:]
+	// Remove the primary owner. It's the first one, unless the placement
+	// assigns the partition to another member. The drained members don't
+	// host backups, the next members on the hash ring take their place.
+	want := len(newOwners) - 1
+	primary := r.primaryOwnerOf(partID)
+	candidates := newOwners
+	if all, err := r.consistent.GetClosestNForPartition(int(partID), len(r.consistent.GetMembers())); err == nil {
+		candidates = all
+	}
+	backups := make([]consistent.Member, 0, want)
+	for _, owner := range candidates {
+		if len(backups) == want {
+			break
+		}
+		if owner.String() == primary.String() || r.placement.isDrained(owner.(discovery.Member)) {
+			continue
+		}
+		backups = append(backups, owner)
+	}
+	newOwners = backups
 
 	// First run
 	if len(owners) == 0 {
@@ -0,0 +1,42 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import "github.com/buraksezer/olric/internal/discovery"
+
+func primaryOwner(owners []discovery.Member) []discovery.Member {
+	if len(owners) == 0 {
+		return nil
+	}
+	return owners[len(owners)-1:]
+}
+
+// nextEpoch returns the ownership epoch of a partition in the new route. The
+// epoch is increased if the primary owner or the backups are different from
+// the ones in the latest routing table. The partitions on this member keep the
+// latest routing table, so the epochs survive a coordinator change.
+func (r *RoutingTable) nextEpoch(partID uint64, rt *route) uint64 {
+	part := r.primary.PartitionByID(partID)
+	epoch := part.Epoch()
+
+	current := primaryOwner(part.Owners())
+	if !sameMembers(current, primaryOwner(rt.Owners)) {
+		return epoch + 1
+	}
+	if !sameMembers(r.backup.PartitionByID(partID).Owners(), rt.Backups) {
+		return epoch + 1
+	}
+	return epoch
+}
@@ -0,0 +1,81 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+)
+
+func TestRoutingTable_PartitionEpoch(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	c := testutil.NewConfig()
+	rt1, err := cluster.addNode(c)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	for partID := uint64(0); partID < c.PartitionCount; partID++ {
+		if epoch := rt1.primary.PartitionByID(partID).Epoch(); epoch != 1 {
+			t.Fatalf("Expected epoch: 1 for PartID: %d. Got: %d", partID, epoch)
+		}
+	}
+
+	// The epochs don't change if the owners are the same.
+	rt1.UpdateEagerly()
+	for partID := uint64(0); partID < c.PartitionCount; partID++ {
+		if epoch := rt1.primary.PartitionByID(partID).Epoch(); epoch != 1 {
+			t.Fatalf("Expected epoch: 1 for PartID: %d. Got: %d", partID, epoch)
+		}
+	}
+
+	rt2, err := cluster.addNode(testutil.NewConfig())
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		if !rt2.IsBootstrapped() || rt1.Signature() != rt2.Signature() {
+			return errors.New("the routing table is not pushed to the second node")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	var moved int
+	for partID := uint64(0); partID < c.PartitionCount; partID++ {
+		part := rt1.primary.PartitionByID(partID)
+		if part.Epoch() != rt2.primary.PartitionByID(partID).Epoch() {
+			t.Fatalf("Different epochs for PartID: %d", partID)
+		}
+		if part.Owner().CompareByID(rt2.This()) {
+			moved++
+			if part.Epoch() != 2 {
+				t.Fatalf("Expected epoch: 2 for PartID: %d. Got: %d", partID, part.Epoch())
+			}
+		} else if part.Epoch() != 1 {
+			t.Fatalf("Expected epoch: 1 for PartID: %d. Got: %d", partID, part.Epoch())
+		}
+	}
+	if moved == 0 {
+		t.Fatalf("No partitions are moved to the second node")
+	}
+}
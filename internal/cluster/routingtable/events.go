@@ -15,49 +15,117 @@
 package routingtable
 
 import (
+	"time"
+
 	"github.com/buraksezer/olric/events"
 	"github.com/buraksezer/olric/internal/discovery"
-	"time"
+	"github.com/buraksezer/olric/internal/eventbus"
 )
 
-func (r *RoutingTable) publishNodeJoinEvent(m *discovery.Member) {
+// MembershipEvents returns the bus that delivers the membership events seen by
+// this member: *events.NodeJoinEvent, *events.NodeLeftEvent and
+// *events.RoutingTableUpdatedEvent.
+func (r *RoutingTable) MembershipEvents() *eventbus.Bus {
+	return r.membershipBus
+}
+
+// publishMembershipEvent delivers the event to the local subscribers and
+// publishes it to the cluster.events channel if it's enabled.
+func (r *RoutingTable) publishMembershipEvent(e events.Event) {
+	r.membershipBus.Publish(e)
+	if r.config.EnableClusterEventsChannel {
+		r.wg.Add(1)
+		go r.publishClusterEvent(e)
+	}
+}
+
+func (r *RoutingTable) hasMembershipSubscribers() bool {
+	return r.config.EnableClusterEventsChannel || r.membershipBus.HasSubscribers()
+}
+
+func (r *RoutingTable) publishClusterEvent(e events.Event) {
 	defer r.wg.Done()
 
 	rc := r.client.Get(r.this.String())
-	message := events.NodeJoinEvent{
-		Kind:      events.KindNodeJoinEvent,
-		Source:    r.this.String(),
-		NodeJoin:  m.String(),
-		Timestamp: time.Now().UnixNano(),
-	}
-	data, err := message.Encode()
+	data, err := e.Encode()
 	if err != nil {
-		r.log.V(3).Printf("[ERROR] Failed to encode NodeJoinEvent: %v", err)
+		r.log.V(3).Printf("[ERROR] Failed to encode %T: %v", e, err)
 		return
 	}
 	err = rc.Publish(r.ctx, events.ClusterEventsChannel, data).Err()
 	if err != nil {
-		r.log.V(3).Printf("[ERROR] Failed to publish NodeJoinEvent to %s: %v", events.ClusterEventsChannel, err)
+		r.log.V(3).Printf("[ERROR] Failed to publish %T to %s: %v", e, events.ClusterEventsChannel, err)
 	}
 }
 
-func (r *RoutingTable) publishNodeLeftEvent(m *discovery.Member) {
-	defer r.wg.Done()
+func (r *RoutingTable) publishNodeJoinEvent(m discovery.Member) {
+	if !r.hasMembershipSubscribers() {
+		return
+	}
+	r.publishMembershipEvent(&events.NodeJoinEvent{
+		Kind:      events.KindNodeJoinEvent,
+		Source:    r.this.String(),
+		NodeJoin:  m.String(),
+		Timestamp: time.Now().UnixNano(),
+	})
+}
 
-	rc := r.client.Get(r.this.String())
-	message := events.NodeLeftEvent{
+func (r *RoutingTable) publishNodeLeftEvent(m discovery.Member) {
+	if !r.hasMembershipSubscribers() {
+		return
+	}
+	r.publishMembershipEvent(&events.NodeLeftEvent{
 		Kind:      events.KindNodeLeftEvent,
 		Source:    r.this.String(),
 		NodeLeft:  m.String(),
 		Timestamp: time.Now().UnixNano(),
-	}
-	data, err := message.Encode()
-	if err != nil {
-		r.log.V(3).Printf("[ERROR] Failed to encode NodeLeftEvent: %v", err)
+	})
+}
+
+func (r *RoutingTable) publishRoutingTableUpdatedEvent(coordinator discovery.Member, signature uint64) {
+	if !r.hasMembershipSubscribers() {
 		return
 	}
-	err = rc.Publish(r.ctx, events.ClusterEventsChannel, data).Err()
-	if err != nil {
-		r.log.V(3).Printf("[ERROR] Failed to publish NodeLeftEvent to %s: %v", events.ClusterEventsChannel, err)
+	r.publishMembershipEvent(&events.RoutingTableUpdatedEvent{
+		Kind:        events.KindRoutingTableUpdatedEvent,
+		Source:      r.this.String(),
+		Coordinator: coordinator.String(),
+		Signature:   signature,
+		Timestamp:   time.Now().UnixNano(),
+	})
+}
+
+func containsMember(members []discovery.Member, m discovery.Member) bool {
+	for _, member := range members {
+		if member.CompareByID(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBackupPromoted returns true if this member becomes the owner of a partition
+// that it was a backup owner of. It has to be called before the new owners
+// are set.
+func (r *RoutingTable) isBackupPromoted(partID uint64, rt *route) bool {
+	if !r.eventBus.HasSubscribers() {
+		return false
+	}
+	if !containsMember(primaryOwner(rt.Owners), r.this) {
+		return false
+	}
+	if containsMember(primaryOwner(r.primary.PartitionByID(partID).Owners()), r.this) {
+		// It's already the owner.
+		return false
 	}
+	return containsMember(r.backup.PartitionByID(partID).Owners(), r.this)
+}
+
+func (r *RoutingTable) publishBackupPromotedEvent(partID uint64) {
+	r.eventBus.Publish(&events.BackupPromotedEvent{
+		Kind:        events.KindBackupPromotedEvent,
+		Source:      r.this.String(),
+		PartitionID: partID,
+		Timestamp:   time.Now().UnixNano(),
+	})
 }
@@ -17,6 +17,7 @@ package routingtable
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -54,9 +55,8 @@ func TestRoutingTable_publishNodeJoinEvent(t *testing.T) {
 		conn.WriteInt(1)
 	})
 
-	m := discovery.NewMember(c)
-	rt.wg.Add(1)
-	go rt.publishNodeJoinEvent(&m)
+	c.EnableClusterEventsChannel = true
+	rt.publishNodeJoinEvent(discovery.NewMember(c))
 	<-ctx.Done()
 	require.ErrorIs(t, context.Canceled, ctx.Err())
 }
@@ -86,9 +86,107 @@ func TestRoutingTable_publishNodeLeftEvent(t *testing.T) {
 		conn.WriteInt(1)
 	})
 
-	m := discovery.NewMember(c)
-	rt.wg.Add(1)
-	go rt.publishNodeLeftEvent(&m)
+	c.EnableClusterEventsChannel = true
+	rt.publishNodeLeftEvent(discovery.NewMember(c))
 	<-ctx.Done()
 	require.ErrorIs(t, context.Canceled, ctx.Err())
 }
+
+func TestRoutingTable_publishBackupPromotedEvent(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	c := testutil.NewConfig()
+	rt, err := cluster.addNode(c)
+	require.NoError(t, err)
+
+	other := discovery.NewMember(testutil.NewConfig())
+	rt.primary.PartitionByID(0).SetOwners([]discovery.Member{other})
+	rt.backup.PartitionByID(0).SetOwners([]discovery.Member{rt.this})
+
+	promoted := &route{Owners: []discovery.Member{other, rt.this}}
+	// Nobody listens to the bus.
+	require.False(t, rt.isBackupPromoted(0, promoted))
+
+	sub := rt.eventBus.Subscribe(0)
+	defer sub.Close()
+
+	require.True(t, rt.isBackupPromoted(0, promoted))
+	require.False(t, rt.isBackupPromoted(0, &route{Owners: []discovery.Member{rt.this, other}}))
+
+	rt.publishBackupPromotedEvent(0)
+	e := (<-sub.Events()).(*events.BackupPromotedEvent)
+	require.Equal(t, events.KindBackupPromotedEvent, e.Kind)
+	require.Equal(t, rt.this.String(), e.Source)
+	require.Equal(t, uint64(0), e.PartitionID)
+}
+
+func TestRoutingTable_MembershipEvents(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	rt1, err := cluster.addNode(nil)
+	require.NoError(t, err)
+
+	sub := rt1.MembershipEvents().Subscribe(0)
+	defer sub.Close()
+
+	rt2, err := cluster.addNode(nil)
+	require.NoError(t, err)
+	rt1.UpdateEagerly()
+
+	var joined, updated bool
+	timeout := time.After(5 * time.Second)
+	for !joined || !updated {
+		select {
+		case e := <-sub.Events():
+			switch v := e.(type) {
+			case *events.NodeJoinEvent:
+				require.Equal(t, rt2.This().String(), v.NodeJoin)
+				joined = true
+			case *events.RoutingTableUpdatedEvent:
+				require.Equal(t, rt1.This().String(), v.Coordinator)
+				require.NotZero(t, v.Signature)
+				updated = true
+			}
+		case <-timeout:
+			t.Fatalf("Membership events have not been received. Joined: %v, Updated: %v", joined, updated)
+		}
+	}
+}
+
+func TestRoutingTable_RoutingTableUpdatedEvent_Unchanged_Table(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	rt1, err := cluster.addNode(nil)
+	require.NoError(t, err)
+
+	rt2, err := cluster.addNode(nil)
+	require.NoError(t, err)
+	rt1.UpdateEagerly()
+
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		if !rt2.IsBootstrapped() || rt1.Signature() != rt2.Signature() {
+			return errors.New("the routing table is not pushed to the second node")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	sub := rt2.MembershipEvents().Subscribe(0)
+	defer sub.Close()
+
+	// The coordinator pushes the same routing table again.
+	for i := 0; i < 5; i++ {
+		rt1.UpdateEagerly()
+	}
+
+	select {
+	case e := <-sub.Events():
+		if _, ok := e.(*events.RoutingTableUpdatedEvent); ok {
+			t.Fatalf("RoutingTableUpdatedEvent is published for an unchanged routing table")
+		}
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -21,4 +21,5 @@ import (
 func (r *RoutingTable) RegisterHandlers() {
 	r.server.ServeMux().HandleFunc(protocol.Internal.UpdateRouting, r.updateRoutingCommandHandler)
 	r.server.ServeMux().HandleFunc(protocol.Internal.LengthOfPart, r.lengthOfPartCommandHandler)
+	r.server.ServeMux().HandleFunc(protocol.Internal.RoutingSignature, r.routingSignatureCommandHandler)
 }
@@ -0,0 +1,38 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import "time"
+
+// healthCheckPeriodically pings the cluster members, see
+// config.Client.HealthCheckInterval.
+func (r *RoutingTable) healthCheckPeriodically() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.config.Client.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			// The pings are bounded by the read and write timeouts of the client.
+			for _, addr := range r.client.HealthCheck(r.ctx) {
+				r.log.V(3).Printf("[ERROR] Health check failed repeatedly, the pool of %s has been closed", addr)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/hashicorp/memberlist"
+)
+
+// OwnershipChange is a change in the owners of a partition that is decided by
+// the cluster coordinator.
+type OwnershipChange struct {
+	PartID    uint64   `json:"part_id"`
+	Kind      string   `json:"kind"`
+	Previous  []string `json:"previous"`
+	Current   []string `json:"current"`
+	Reason    string   `json:"reason"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// history is a bounded list of ownership changes. The oldest changes are
+// dropped first.
+type history struct {
+	mtx     sync.RWMutex
+	size    int
+	changes []OwnershipChange
+}
+
+func newHistory(size int) *history {
+	return &history{size: size}
+}
+
+func (h *history) add(changes ...OwnershipChange) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.changes = append(h.changes, changes...)
+	if overflow := len(h.changes) - h.size; overflow > 0 {
+		h.changes = append([]OwnershipChange(nil), h.changes[overflow:]...)
+	}
+}
+
+// list returns the changes of the given partitions from the oldest to the
+// newest. It returns all the changes if no partition is given.
+func (h *history) list(partIDs ...uint64) []OwnershipChange {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	filter := make(map[uint64]struct{})
+	for _, partID := range partIDs {
+		filter[partID] = struct{}{}
+	}
+
+	result := []OwnershipChange{}
+	for _, change := range h.changes {
+		if _, ok := filter[change.PartID]; len(filter) == 0 || ok {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+func memberNames(members []discovery.Member) []string {
+	names := make([]string, 0, len(members))
+	for _, member := range members {
+		names = append(names, member.String())
+	}
+	return names
+}
+
+func sameMembers(a, b []discovery.Member) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].CompareByID(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordOwnershipChanges compares the previous and the current routing
+// tables, and adds the changed partitions to the history.
+func (r *RoutingTable) recordOwnershipChanges(previous, current map[uint64]*route, reason string) {
+	var changes []OwnershipChange
+	now := time.Now().UnixNano()
+	for partID := uint64(0); partID < r.config.PartitionCount; partID++ {
+		cur, ok := current[partID]
+		if !ok {
+			continue
+		}
+		prev, ok := previous[partID]
+		if !ok {
+			prev = &route{}
+		}
+
+		var prevOwner, curOwner []discovery.Member
+		if len(prev.Owners) > 0 {
+			prevOwner = prev.Owners[len(prev.Owners)-1:]
+		}
+		if len(cur.Owners) > 0 {
+			curOwner = cur.Owners[len(cur.Owners)-1:]
+		}
+		if !sameMembers(prevOwner, curOwner) {
+			changes = append(changes, OwnershipChange{
+				PartID:    partID,
+				Kind:      partitions.PRIMARY.String(),
+				Previous:  memberNames(prevOwner),
+				Current:   memberNames(curOwner),
+				Reason:    reason,
+				Timestamp: now,
+			})
+		}
+		if !sameMembers(prev.Backups, cur.Backups) {
+			changes = append(changes, OwnershipChange{
+				PartID:    partID,
+				Kind:      partitions.BACKUP.String(),
+				Previous:  memberNames(prev.Backups),
+				Current:   memberNames(cur.Backups),
+				Reason:    reason,
+				Timestamp: now,
+			})
+		}
+	}
+	if len(changes) != 0 {
+		r.history.add(changes...)
+	}
+}
+
+// OwnershipHistory returns the ownership changes decided by this member while
+// it was the cluster coordinator. If partition IDs are given, only the changes
+// of these partitions are returned.
+func (r *RoutingTable) OwnershipHistory(partIDs ...uint64) ([]OwnershipChange, error) {
+	if !r.discovery.IsCoordinator() {
+		return nil, ErrNotCoordinator
+	}
+	return r.history.list(partIDs...), nil
+}
+
+func eventReason(event *discovery.ClusterEvent) string {
+	switch event.Event {
+	case memberlist.NodeJoin:
+		return fmt.Sprintf("member joined: %s", event.NodeName)
+	case memberlist.NodeLeave:
+		return fmt.Sprintf("member left: %s", event.NodeName)
+	case memberlist.NodeUpdate:
+		return fmt.Sprintf("member updated: %s", event.NodeName)
+	default:
+		return fmt.Sprintf("cluster event: %s", event.NodeName)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/discovery"
+)
+
+func TestHistory_Bounded(t *testing.T) {
+	h := newHistory(3)
+	for i := uint64(0); i < 5; i++ {
+		h.add(OwnershipChange{PartID: i})
+	}
+
+	changes := h.list()
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes. Got: %d", len(changes))
+	}
+	if changes[0].PartID != 2 || changes[2].PartID != 4 {
+		t.Fatalf("Expected the oldest changes to be dropped. Got: %v", changes)
+	}
+
+	changes = h.list(3, 100)
+	if len(changes) != 1 || changes[0].PartID != 3 {
+		t.Fatalf("Expected the changes of PartID: 3. Got: %v", changes)
+	}
+}
+
+func TestRoutingTable_RecordOwnershipChanges(t *testing.T) {
+	c := &config.Config{PartitionCount: 2}
+	r := &RoutingTable{
+		config:  c,
+		history: newHistory(10),
+	}
+	m1 := discovery.Member{Name: "localhost:3320", ID: 1}
+	m2 := discovery.Member{Name: "localhost:3321", ID: 2}
+
+	previous := map[uint64]*route{
+		0: {Owners: []discovery.Member{m1}, Backups: []discovery.Member{m2}},
+		1: {Owners: []discovery.Member{m1}, Backups: []discovery.Member{m2}},
+	}
+	current := map[uint64]*route{
+		0: {Owners: []discovery.Member{m1}, Backups: []discovery.Member{m2}},
+		1: {Owners: []discovery.Member{m1, m2}, Backups: []discovery.Member{m1}},
+	}
+	r.recordOwnershipChanges(previous, current, "test")
+
+	changes := r.history.list()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes. Got: %v", changes)
+	}
+	for _, change := range changes {
+		if change.PartID != 1 || change.Reason != "test" {
+			t.Fatalf("Unexpected change: %v", change)
+		}
+	}
+	if changes[0].Previous[0] != m1.String() || changes[0].Current[0] != m2.String() {
+		t.Fatalf("Unexpected primary owner change: %v", changes[0])
+	}
+}
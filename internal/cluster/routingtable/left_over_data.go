@@ -19,7 +19,7 @@ import (
 	"github.com/buraksezer/olric/internal/discovery"
 )
 
-func (r *RoutingTable) processLeftOverDataReports(reports map[discovery.Member]*leftOverDataReport) {
+func (r *RoutingTable) processLeftOverDataReports(reports []memberReport) {
 	check := func(member discovery.Member, owners []discovery.Member) bool {
 		for _, owner := range owners {
 			if member.CompareByID(owner) {
@@ -46,15 +46,15 @@ func (r *RoutingTable) processLeftOverDataReports(reports map[discovery.Member]*
 	}
 
 	// data structures in this function is guarded by routingMtx
-	for member, report := range reports {
-		for _, partID := range report.Partitions {
+	for _, item := range reports {
+		for _, partID := range item.report.Partitions {
 			part := r.primary.PartitionByID(partID)
-			ensureOwnership(member, partID, part)
+			ensureOwnership(item.member, partID, part)
 		}
 
-		for _, partID := range report.Backups {
+		for _, partID := range item.report.Backups {
 			part := r.backup.PartitionByID(partID)
-			ensureOwnership(member, partID, part)
+			ensureOwnership(item.member, partID, part)
 		}
 	}
 }
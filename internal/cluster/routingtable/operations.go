@@ -19,7 +19,6 @@ import (
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
-	"github.com/cespare/xxhash/v2"
 	"github.com/tidwall/redcon"
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -92,15 +91,30 @@ func (r *RoutingTable) updateRoutingCommandHandler(conn redcon.Conn, cmd redcon.
 
 	// owners(atomic.value) is guarded by routingUpdateMtx against parallel writers.
 	// Calculate routing signature. This is useful to control balancing tasks.
-	r.setSignature(xxhash.Sum64(updateRoutingCmd.Payload))
+	previous := r.Signature()
+	r.setSignature(signatureOf(table))
 	for partID, data := range table {
+		promoted := r.isBackupPromoted(partID, data)
+
 		// Set partition(primary copies) owners
 		part := r.primary.PartitionByID(partID)
 		part.SetOwners(data.Owners)
+		part.SetEpoch(data.Epoch)
 
 		// Set backup owners
 		bpart := r.backup.PartitionByID(partID)
 		bpart.SetOwners(data.Backups)
+		bpart.SetEpoch(data.Epoch)
+
+		if promoted {
+			r.publishBackupPromotedEvent(partID)
+		}
+	}
+
+	// Keep the placement decisions of the coordinator. This member takes them
+	// over if it becomes the coordinator.
+	if !r.discovery.IsCoordinator() {
+		r.placement.restore(table)
 	}
 
 	// Used by the LRU implementation.
@@ -116,6 +130,12 @@ func (r *RoutingTable) updateRoutingCommandHandler(conn redcon.Conn, cmd redcon.
 		return
 	}
 
+	// The coordinator pushes the table periodically, even if it's not changed.
+	// The signature doesn't depend on the encoding of the table, see signatureOf.
+	if signature := r.Signature(); signature != previous {
+		r.publishRoutingTableUpdatedEvent(coordinator, signature)
+	}
+
 	// Call balancer to distribute load evenly
 	r.wg.Add(1)
 	go r.runCallbacks()
@@ -0,0 +1,180 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/discovery"
+)
+
+var (
+	// ErrNotCoordinator is returned when a placement request is sent to a member
+	// other than the cluster coordinator.
+	ErrNotCoordinator = errors.New("not the cluster coordinator")
+
+	// ErrMemberDrained is returned when a partition is moved to a drained member.
+	ErrMemberDrained = errors.New("member is drained")
+)
+
+// placement keeps the decisions of the operators that override the consistent
+// hashing. The decisions are taken by the cluster coordinator and pushed to the
+// members with the routing table, so a new coordinator keeps them.
+type placement struct {
+	mtx     sync.RWMutex
+	pinned  map[uint64]discovery.Member
+	drained map[uint64]discovery.Member
+}
+
+func newPlacement() *placement {
+	return &placement{
+		pinned:  make(map[uint64]discovery.Member),
+		drained: make(map[uint64]discovery.Member),
+	}
+}
+
+func (p *placement) isDrained(member discovery.Member) bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	_, ok := p.drained[member.ID]
+	return ok
+}
+
+// snapshot returns a copy of the pinned partitions and the drained members.
+// The drained members are sorted by ID, so the signature of the routing table
+// doesn't depend on the iteration order.
+func (p *placement) snapshot() (map[uint64]discovery.Member, []discovery.Member) {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	pinned := make(map[uint64]discovery.Member, len(p.pinned))
+	for partID, member := range p.pinned {
+		pinned[partID] = member
+	}
+	var drained []discovery.Member
+	for _, member := range p.drained {
+		drained = append(drained, member)
+	}
+	sort.Slice(drained, func(i, j int) bool { return drained[i].ID < drained[j].ID })
+	return pinned, drained
+}
+
+// restore replaces the placement with the one in the routing table that is
+// pushed by the coordinator.
+func (p *placement) restore(table map[uint64]*route) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.pinned = make(map[uint64]discovery.Member)
+	p.drained = make(map[uint64]discovery.Member)
+	for partID, rt := range table {
+		if rt.Pinned != nil {
+			p.pinned[partID] = *rt.Pinned
+		}
+		for _, member := range rt.Drained {
+			p.drained[member.ID] = member
+		}
+	}
+}
+
+// primaryOwnerOf returns the member that should own the primary copy of
+// the partition, after applying the placement decisions.
+func (r *RoutingTable) primaryOwnerOf(partID uint64) discovery.Member {
+	r.placement.mtx.RLock()
+	pinned, ok := r.placement.pinned[partID]
+	r.placement.mtx.RUnlock()
+	if ok {
+		current, err := r.discovery.FindMemberByName(pinned.Name)
+		if err == nil && current.CompareByID(pinned) && !r.placement.isDrained(current) {
+			return current
+		}
+	}
+
+	owner := r.consistent.GetPartitionOwner(int(partID)).(discovery.Member)
+	if !r.placement.isDrained(owner) {
+		return owner
+	}
+
+	// Find the closest member that is not drained.
+	candidates, err := r.consistent.GetClosestNForPartition(int(partID), len(r.consistent.GetMembers()))
+	if err != nil {
+		return owner
+	}
+	for _, candidate := range candidates {
+		if !r.placement.isDrained(candidate.(discovery.Member)) {
+			return candidate.(discovery.Member)
+		}
+	}
+	// All members are drained, there is nothing to do.
+	return owner
+}
+
+// MovePartition pins the primary copy of the partition to the given member and
+// updates the routing table. The balancer moves the data in the background.
+func (r *RoutingTable) MovePartition(partID uint64, name string) error {
+	if !r.discovery.IsCoordinator() {
+		return ErrNotCoordinator
+	}
+	if partID >= r.config.PartitionCount {
+		return fmt.Errorf("invalid partition id: %d", partID)
+	}
+	member, err := r.discovery.FindMemberByName(name)
+	if err != nil {
+		return err
+	}
+	if r.placement.isDrained(member) {
+		return fmt.Errorf("%w: %s", ErrMemberDrained, name)
+	}
+
+	r.placement.mtx.Lock()
+	r.placement.pinned[partID] = member
+	r.placement.mtx.Unlock()
+
+	r.log.V(2).Printf("[INFO] PartID: %d has been pinned to %s", partID, member)
+	r.updateRouting(fmt.Sprintf("partition moved to: %s", member))
+	return nil
+}
+
+// Drain moves the primary copies and the backups off the given member, the
+// partitions are assigned to the closest members on the hash ring. It returns
+// after the routing table is pushed, the balancer moves the data in the
+// background. The member is drained until it leaves the cluster, a re-joined
+// instance of the same member is a new member.
+func (r *RoutingTable) Drain(name string) error {
+	if !r.discovery.IsCoordinator() {
+		return ErrNotCoordinator
+	}
+	member, err := r.discovery.FindMemberByName(name)
+	if err != nil {
+		return err
+	}
+
+	r.placement.mtx.Lock()
+	r.placement.drained[member.ID] = member
+	for partID, pinned := range r.placement.pinned {
+		if pinned.CompareByID(member) {
+			delete(r.placement.pinned, partID)
+		}
+	}
+	r.placement.mtx.Unlock()
+
+	r.log.V(2).Printf("[INFO] Draining %s", member)
+	r.updateRouting(fmt.Sprintf("member drained: %s", member))
+	return nil
+}
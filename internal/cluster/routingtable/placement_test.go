@@ -0,0 +1,81 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+)
+
+func TestRoutingTable_Placement_Pushed(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	var nodes []*RoutingTable
+	for i := 0; i < 3; i++ {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		rt, err := cluster.addNode(c)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		nodes = append(nodes, rt)
+	}
+	rt1, rt2, rt3 := nodes[0], nodes[1], nodes[2]
+
+	err := testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		if !rt3.IsBootstrapped() {
+			return errors.New("the third node cannot be bootstrapped")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	if err = rt1.MovePartition(0, rt2.This().String()); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	if err = rt1.Drain(rt3.This().String()); err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	// The placement is pushed with the routing table.
+	for _, rt := range []*RoutingTable{rt2, rt3} {
+		pinned, drained := rt.placement.snapshot()
+		if !pinned[0].CompareByID(rt2.This()) {
+			t.Fatalf("Expected PartID: 0 to be pinned to %s. Got: %s", rt2.This(), pinned[0])
+		}
+		if len(drained) != 1 || !drained[0].CompareByID(rt3.This()) {
+			t.Fatalf("Expected %s to be drained. Got: %v", rt3.This(), drained)
+		}
+	}
+
+	for partID := uint64(0); partID < rt1.config.PartitionCount; partID++ {
+		for _, owner := range rt2.backup.PartitionByID(partID).Owners() {
+			if owner.CompareByID(rt3.This()) {
+				t.Fatalf("Drained member is a backup owner of PartID: %d", partID)
+			}
+		}
+	}
+
+	err = cluster.shutdown()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/discovery"
+)
+
+// preDial opens the connections to the given member in advance, see
+// config.Client.PreDial.
+func (r *RoutingTable) preDial(member discovery.Member) {
+	defer r.wg.Done()
+
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.Client.DialTimeout)
+	defer cancel()
+
+	if err := r.client.Dial(ctx, member.String()); err != nil {
+		r.log.V(3).Printf("[ERROR] Failed to pre-dial %s: %v", member, err)
+		return
+	}
+	r.log.V(6).Printf("[DEBUG] Connections to %s have been established", member)
+}
+
+// preDialMembers opens the connections to all known members except this one.
+func (r *RoutingTable) preDialMembers() {
+	if !r.config.Client.PreDial {
+		return
+	}
+
+	for _, member := range r.discovery.GetMembers() {
+		if member.CompareByID(r.this) {
+			continue
+		}
+		r.wg.Add(1)
+		go r.preDial(member)
+	}
+}
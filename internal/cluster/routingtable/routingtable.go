@@ -29,6 +29,7 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/eventbus"
 	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/service"
 	"github.com/buraksezer/olric/pkg/flog"
@@ -38,9 +39,19 @@ import (
 // ErrClusterQuorum means that the cluster could not reach a healthy numbers of members to operate.
 var ErrClusterQuorum = errors.New("cannot be reached cluster quorum to operate")
 
+// ErrBootstrapQuorum means that the member has not seen enough members to serve writes yet.
+var ErrBootstrapQuorum = errors.New("bootstrap quorum has not been reached")
+
 type route struct {
 	Owners  []discovery.Member
 	Backups []discovery.Member
+	// Epoch is increased when the primary owner or the backups change.
+	Epoch uint64
+	// Pinned is the member that the partition is assigned to by MovePartition.
+	Pinned *discovery.Member
+	// Drained is the list of the drained members. It's the same for all
+	// partitions, so the placement survives a coordinator change.
+	Drained []discovery.Member
 }
 
 type RoutingTable struct {
@@ -50,11 +61,16 @@ type RoutingTable struct {
 	// uses that.
 	ownedPartitionCount uint64
 	signature           uint64
+	// pushedSignature is the signature of the latest routing table that
+	// is pushed by this member as the coordinator.
+	pushedSignature uint64
 	// numMembers is used to check cluster quorum.
 	numMembers int32
 
 	// These values is useful to control operation status.
 	bootstrapped int32
+	// fenced is 1 until the bootstrap quorum is reached.
+	fenced int32
 
 	updateRoutingMtx sync.Mutex
 	table            map[uint64]*route
@@ -62,12 +78,18 @@ type RoutingTable struct {
 	this             discovery.Member
 	members          *Members
 	config           *config.Config
+	runtime          atomic.Value // *config.Config
 	log              *flog.Logger
 	primary          *partitions.Partitions
 	backup           *partitions.Partitions
 	client           *server.Client
 	server           *server.Server
+	eventBus         *eventbus.Bus
+	membershipBus    *eventbus.Bus
 	discovery        *discovery.Discovery
+	placement        *placement
+	departures       *departures
+	history          *history
 	callbacks        []func()
 	callbackMtx      sync.Mutex
 	pushPeriod       time.Duration
@@ -78,9 +100,14 @@ type RoutingTable struct {
 
 func registerErrors() {
 	protocol.SetError("CLUSTERQUORUM", ErrClusterQuorum)
+	protocol.SetError("BOOTSTRAPQUORUM", ErrBootstrapQuorum)
 	protocol.SetError("CLUSTERJOIN", ErrClusterJoin)
 	protocol.SetError("SERVERGONE", ErrServerGone)
 	protocol.SetError("OPERATIONTIMEOUT", ErrOperationTimeout)
+	protocol.SetError("NOTCOORDINATOR", ErrNotCoordinator)
+	protocol.SetError("MEMBERDRAINED", ErrMemberDrained)
+	protocol.SetError("MEMBERNOTFOUND", discovery.ErrMemberNotFound)
+	protocol.SetError("STALEROUTINGTABLE", ErrStaleRoutingTable)
 }
 
 func New(e *environment.Environment) *RoutingTable {
@@ -98,18 +125,27 @@ func New(e *environment.Environment) *RoutingTable {
 	}
 
 	rt := &RoutingTable{
-		members:    newMembers(),
-		discovery:  discovery.New(log, c),
-		config:     c,
-		log:        log,
-		consistent: consistent.New(nil, cc),
-		primary:    e.Get("primary").(*partitions.Partitions),
-		backup:     e.Get("backup").(*partitions.Partitions),
-		client:     e.Get("client").(*server.Client),
-		server:     e.Get("server").(*server.Server),
-		pushPeriod: c.RoutingTablePushInterval,
-		ctx:        ctx,
-		cancel:     cancel,
+		members:       newMembers(),
+		placement:     newPlacement(),
+		departures:    newDepartures(),
+		history:       newHistory(c.OwnershipHistorySize),
+		discovery:     discovery.New(log, c),
+		config:        c,
+		log:           log,
+		consistent:    consistent.New(nil, cc),
+		primary:       e.Get("primary").(*partitions.Partitions),
+		backup:        e.Get("backup").(*partitions.Partitions),
+		client:        e.Get("client").(*server.Client),
+		server:        e.Get("server").(*server.Server),
+		eventBus:      e.Get("eventbus").(*eventbus.Bus),
+		membershipBus: eventbus.New(),
+		pushPeriod:    c.RoutingTablePushInterval,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	rt.runtime.Store(c)
+	if c.BootstrapQuorum > 1 {
+		rt.fenced = 1
 	}
 	registerErrors()
 	rt.RegisterHandlers()
@@ -129,7 +165,19 @@ func (r *RoutingTable) setNumMembers() {
 	// Calling NumMembers in every request is quite expensive.
 	// It's rarely updated. Just call this when the membership info changed.
 	nr := int32(r.discovery.NumMembers())
-	atomic.StoreInt32(&r.numMembers, nr)
+	previous := atomic.SwapInt32(&r.numMembers, nr)
+	r.logMemberCountQuorumChange(previous, nr)
+}
+
+func (r *RoutingTable) logMemberCountQuorumChange(previous, current int32) {
+	quorum := r.runtimeConfig().MemberCountQuorum
+	switch {
+	case previous >= quorum && current < quorum:
+		r.log.V(1).Printf("[WARN] Member count quorum has been lost: %d/%d members. "+
+			"The writes are rejected until it's restored", current, quorum)
+	case previous != 0 && previous < quorum && current >= quorum:
+		r.log.V(2).Printf("[INFO] Member count quorum has been restored: %d/%d members", current, quorum)
+	}
 }
 
 func (r *RoutingTable) SetNumMembersEagerly(nr int32) {
@@ -144,6 +192,18 @@ func (r *RoutingTable) Members() *Members {
 	return r.members
 }
 
+// runtimeConfig returns the latest configuration applied by ReloadConfig.
+func (r *RoutingTable) runtimeConfig() *config.Config {
+	return r.runtime.Load().(*config.Config)
+}
+
+// ReloadConfig applies the member count quorum, quorum loss mode and dead
+// member timeout in the given configuration. The given configuration must not
+// be modified by the caller after the call.
+func (r *RoutingTable) ReloadConfig(c *config.Config) {
+	r.runtime.Store(c)
+}
+
 func (r *RoutingTable) setSignature(s uint64) {
 	atomic.StoreUint64(&r.signature, s)
 }
@@ -170,12 +230,39 @@ func (r *RoutingTable) OwnedPartitionCount() uint64 {
 func (r *RoutingTable) CheckMemberCountQuorum() error {
 	// This type of quorum function determines the presence of quorum based on the count of members in the cluster,
 	// as observed by the local member’s cluster membership manager
-	if r.config.MemberCountQuorum > r.NumMembers() {
+	if r.runtimeConfig().MemberCountQuorum > r.NumMembers() {
 		return ErrClusterQuorum
 	}
 	return nil
 }
 
+// CheckMemberCountQuorumForReads returns ErrClusterQuorum if this member
+// cannot see config.MemberCountQuorum members and config.QuorumLossMode is
+// UnavailableQuorumLossMode. The writes are checked by CheckMemberCountQuorum
+// in all modes.
+func (r *RoutingTable) CheckMemberCountQuorumForReads() error {
+	if r.runtimeConfig().QuorumLossMode != config.UnavailableQuorumLossMode {
+		return nil
+	}
+	return r.CheckMemberCountQuorum()
+}
+
+// CheckBootstrapQuorum returns ErrBootstrapQuorum until this member has seen
+// config.BootstrapQuorum members. The writes are fenced until then. It's
+// lifted once and for all, the departures are handled by MemberCountQuorum.
+func (r *RoutingTable) CheckBootstrapQuorum() error {
+	if atomic.LoadInt32(&r.fenced) == 0 {
+		return nil
+	}
+	if r.NumMembers() < r.config.BootstrapQuorum {
+		return ErrBootstrapQuorum
+	}
+	if atomic.CompareAndSwapInt32(&r.fenced, 1, 0) {
+		r.log.V(2).Printf("[INFO] Bootstrap quorum has been reached: %d members", r.NumMembers())
+	}
+	return nil
+}
+
 func (r *RoutingTable) markBootstrapped() {
 	// Bootstrapped by the coordinator.
 	atomic.StoreInt32(&r.bootstrapped, 1)
@@ -212,24 +299,32 @@ func (r *RoutingTable) fillRoutingTable() {
 			"the cluster has %d members currently",
 			r.config.ReplicaCount, r.NumMembers())
 	}
+	pinned, drained := r.placement.snapshot()
 	table := make(map[uint64]*route)
 	for partID := uint64(0); partID < r.config.PartitionCount; partID++ {
 		rt := &route{
-			Owners: r.distributePrimaryCopies(partID),
+			Owners:  r.distributePrimaryCopies(partID),
+			Drained: drained,
+		}
+		if member, ok := pinned[partID]; ok {
+			rt.Pinned = &member
 		}
 		if r.config.ReplicaCount > config.MinimumReplicaCount {
 			rt.Backups = r.distributeBackups(partID)
 		}
+		rt.Epoch = r.nextEpoch(partID, rt)
 		table[partID] = rt
 	}
 	r.table = table
 }
 
 func (r *RoutingTable) UpdateEagerly() {
-	r.updateRouting()
+	r.updateRouting("eager update")
 }
 
-func (r *RoutingTable) updateRouting() {
+// updateRouting calculates a new routing table and pushes it to the cluster.
+// reason is recorded in the ownership history.
+func (r *RoutingTable) updateRouting(reason string) {
 	// This function is called by listenMemberlistEvents and updateRoutingPeriodically
 	// So this lock prevents parallel execution.
 	r.Lock()
@@ -247,12 +342,19 @@ func (r *RoutingTable) updateRouting() {
 		return
 	}
 
+	if !r.hasPartitionOwners() {
+		r.log.V(2).Printf("[WARN] There is no member to own the partitions, only the analytics replicas")
+		return
+	}
+
+	previous := r.table
 	r.fillRoutingTable()
 	reports, err := r.updateRoutingTableOnCluster()
 	if err != nil {
 		r.log.V(2).Printf("[ERROR] Failed to update routing table on cluster: %v", err)
 		return
 	}
+	r.recordOwnershipChanges(previous, r.table, reason)
 	r.processLeftOverDataReports(reports)
 }
 
@@ -265,13 +367,15 @@ func (r *RoutingTable) processClusterEvent(event *discovery.ClusterEvent) {
 	switch event.Event {
 	case memberlist.NodeJoin:
 		r.Members().Add(member)
-		r.consistent.Add(member)
+		r.addToHashRing(member)
+		r.departures.remove(member.Name)
 		r.log.V(2).Printf("[INFO] Node joined: %s", member)
-
-		if r.config.EnableClusterEventsChannel {
+		if r.config.Client.PreDial {
 			r.wg.Add(1)
-			go r.publishNodeJoinEvent(&member)
+			go r.preDial(member)
 		}
+
+		r.publishNodeJoinEvent(member)
 	case memberlist.NodeLeave:
 		if _, err := r.Members().Get(member.ID); err != nil {
 			r.log.V(2).Printf("[ERROR] Unknown node left: %s: %d", event.NodeName, member.ID)
@@ -279,16 +383,14 @@ func (r *RoutingTable) processClusterEvent(event *discovery.ClusterEvent) {
 		}
 		r.Members().Delete(member.ID)
 		r.consistent.Remove(event.NodeName)
+		r.departures.add(member, time.Now())
 		// Don't try to used closed sockets again.
 		r.log.V(2).Printf("[INFO] Node left: %s", event.NodeName)
 		if err := r.client.Close(event.NodeName); err != nil {
 			r.log.V(2).Printf("[ERROR] Failed to remove the node from pool %s: %v", event.NodeName, err)
 		}
 
-		if r.config.EnableClusterEventsChannel {
-			r.wg.Add(1)
-			go r.publishNodeLeftEvent(&member)
-		}
+		r.publishNodeLeftEvent(member)
 	case memberlist.NodeUpdate:
 		// Node's birthdate may be changed. Close the pool and re-add to the hash ring.
 		// This takes linear time, but member count should be too small for a decent computer!
@@ -303,8 +405,12 @@ func (r *RoutingTable) processClusterEvent(event *discovery.ClusterEvent) {
 			return true
 		})
 		r.Members().Add(member)
-		r.consistent.Add(member)
+		r.addToHashRing(member)
 		r.log.V(2).Printf("[INFO] Node updated: %s", member)
+		if r.config.Client.PreDial {
+			r.wg.Add(1)
+			go r.preDial(member)
+		}
 	default:
 		r.log.V(2).Printf("[ERROR] Unknown event received: %v", event)
 		return
@@ -323,7 +429,7 @@ func (r *RoutingTable) listenClusterEvents(eventCh chan *discovery.ClusterEvent)
 			return
 		case e := <-eventCh:
 			r.processClusterEvent(e)
-			r.updateRouting()
+			r.updateRouting(eventReason(e))
 		}
 	}
 }
@@ -338,7 +444,7 @@ func (r *RoutingTable) pushPeriodically() {
 		case <-r.ctx.Done():
 			return
 		case <-ticker.C:
-			r.updateRouting()
+			r.updateRouting("periodic push")
 		}
 	}
 }
@@ -395,7 +501,7 @@ func (r *RoutingTable) Start() error {
 	r.Members().Add(r.this)
 	r.Members().Unlock()
 
-	r.consistent.Add(r.this)
+	r.addToHashRing(r.this)
 
 	if r.discovery.IsCoordinator() {
 		err = r.bootstrapCoordinator()
@@ -404,9 +510,19 @@ func (r *RoutingTable) Start() error {
 		}
 	}
 
+	r.preDialMembers()
+
 	r.wg.Add(1)
 	go r.pushPeriodically()
 
+	r.wg.Add(1)
+	go r.cleanupDeadMembersPeriodically()
+
+	if r.config.Client.HealthCheckInterval > 0 {
+		r.wg.Add(1)
+		go r.healthCheckPeriodically()
+	}
+
 	if r.config.MemberlistInterface != "" {
 		r.log.V(2).Printf("[INFO] Memberlist uses interface: %s", r.config.MemberlistInterface)
 	}
@@ -442,6 +558,7 @@ func (r *RoutingTable) Shutdown(ctx context.Context) error {
 		}
 	case <-done:
 	}
+	r.membershipBus.Close()
 	return nil
 }
 
@@ -27,6 +27,7 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/eventbus"
 	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/hashicorp/memberlist"
@@ -41,6 +42,7 @@ func newRoutingTableForTest(c *config.Config, srv *server.Server) *RoutingTable
 	e.Set("backup", partitions.New(c.PartitionCount, partitions.BACKUP))
 	e.Set("client", server.NewClient(c.Client))
 	e.Set("server", srv)
+	e.Set("eventbus", eventbus.New())
 
 	rt := New(e)
 	go func() {
@@ -204,6 +206,83 @@ func TestRoutingTable_Cluster(t *testing.T) {
 	}
 }
 
+func TestRoutingTable_CheckMemberCountQuorumForReads(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	c1 := testutil.NewConfig()
+	c1.MemberCountQuorum = 1
+	rt1, err := cluster.addNode(c1)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	rt1.SetNumMembersEagerly(0)
+	err = rt1.CheckMemberCountQuorum()
+	if !errors.Is(err, ErrClusterQuorum) {
+		t.Fatalf("Expected ErrClusterQuorum. Got: %v", err)
+	}
+
+	// The reads are served in read-only mode.
+	err = rt1.CheckMemberCountQuorumForReads()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	rt1.config.QuorumLossMode = config.UnavailableQuorumLossMode
+	err = rt1.CheckMemberCountQuorumForReads()
+	if !errors.Is(err, ErrClusterQuorum) {
+		t.Fatalf("Expected ErrClusterQuorum. Got: %v", err)
+	}
+
+	rt1.SetNumMembersEagerly(1)
+	err = rt1.CheckMemberCountQuorumForReads()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+}
+
+func TestRoutingTable_CheckBootstrapQuorum(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	c1 := testutil.NewConfig()
+	c1.BootstrapQuorum = 2
+	rt1, err := cluster.addNode(c1)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	err = rt1.CheckBootstrapQuorum()
+	if !errors.Is(err, ErrBootstrapQuorum) {
+		t.Fatalf("Expected ErrBootstrapQuorum. Got: %v", err)
+	}
+
+	_, err = cluster.addNode(nil)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		return rt1.CheckBootstrapQuorum()
+	})
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	// The fence is lifted once and for all.
+	rt1.SetNumMembersEagerly(1)
+	err = rt1.CheckBootstrapQuorum()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+
+	err = cluster.shutdown()
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+}
+
 func TestRoutingTable_CheckPartitionOwnership(t *testing.T) {
 	cluster := newTestCluster()
 	defer cluster.cancel()
@@ -0,0 +1,36 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+)
+
+// PartitionIDOfTag returns the ID of the partition that stores the keys with
+// the given hash tag, e.g. "user:1" for "{user:1}.profile".
+func (r *RoutingTable) PartitionIDOfTag(tag string) uint64 {
+	return partitions.TagPartitionID(tag, r.config.PartitionCount)
+}
+
+// OwnerOfTag returns the primary owner of the keys with the given hash tag.
+func (r *RoutingTable) OwnerOfTag(tag string) discovery.Member {
+	return r.primary.PartitionByID(r.PartitionIDOfTag(tag)).Owner()
+}
+
+// BackupOwnersOfTag returns the backup owners of the keys with the given hash tag.
+func (r *RoutingTable) BackupOwnersOfTag(tag string) []discovery.Member {
+	return r.backup.PartitionOwnersByID(r.PartitionIDOfTag(tag))
+}
@@ -0,0 +1,59 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routingtable
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testutil"
+)
+
+func TestRoutingTable_OwnerOfTag(t *testing.T) {
+	cluster := newTestCluster()
+	defer cluster.cancel()
+
+	c := testutil.NewConfig()
+	partitions.SetHashFunc(c.Hasher)
+
+	rt1, err := cluster.addNode(c)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	rt2, err := cluster.addNode(nil)
+	if err != nil {
+		t.Fatalf("Expected nil. Got: %v", err)
+	}
+	rt1.UpdateEagerly()
+
+	partID := rt1.PartitionIDOfTag("user:1")
+	hkey := partitions.TaggedHKey("mydmap", "{user:1}.profile", c.PartitionCount)
+	if rt1.primary.PartitionIDByHKey(hkey) != partID {
+		t.Fatalf("Expected partition id: %d. Got: %d", partID, rt1.primary.PartitionIDByHKey(hkey))
+	}
+
+	for _, rt := range []*RoutingTable{rt1, rt2} {
+		owner := rt.OwnerOfTag("user:1")
+		expected := rt.primary.PartitionByHKey(hkey).Owner()
+		if !owner.CompareByID(expected) {
+			t.Fatalf("Expected owner: %s. Got: %s", expected, owner)
+		}
+		for _, backup := range rt.BackupOwnersOfTag("user:1") {
+			if backup.CompareByID(owner) {
+				t.Fatalf("%s is both the owner and a backup of the tag", owner)
+			}
+		}
+	}
+}
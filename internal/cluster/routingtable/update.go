@@ -15,11 +15,16 @@
 package routingtable
 
 import (
-	"github.com/buraksezer/olric/internal/protocol"
+	"encoding/binary"
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/internal/protocol"
 
 	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/cespare/xxhash/v2"
 	"github.com/vmihailenco/msgpack/v5"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
@@ -68,15 +73,63 @@ func (r *RoutingTable) updateRoutingTableOnMember(data []byte, member discovery.
 	return &report, nil
 }
 
-func (r *RoutingTable) updateRoutingTableOnCluster() (map[discovery.Member]*leftOverDataReport, error) {
+// memberReport is the left-over data report of a member.
+type memberReport struct {
+	member discovery.Member
+	report *leftOverDataReport
+}
+
+// signatureOf returns the signature of a routing table. The partitions are
+// hashed in the order of their IDs, so it doesn't depend on the order of the
+// keys in the encoded table.
+func signatureOf(table map[uint64]*route) uint64 {
+	partIDs := make([]uint64, 0, len(table))
+	for partID := range table {
+		partIDs = append(partIDs, partID)
+	}
+	sort.Slice(partIDs, func(i, j int) bool { return partIDs[i] < partIDs[j] })
+
+	var buf [8]byte
+	write := func(d *xxhash.Digest, v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		_, _ = d.Write(buf[:])
+	}
+	d := xxhash.New()
+	for _, partID := range partIDs {
+		rt := table[partID]
+		write(d, partID)
+		write(d, rt.Epoch)
+		write(d, uint64(len(rt.Owners)))
+		for _, owner := range rt.Owners {
+			write(d, owner.ID)
+		}
+		write(d, uint64(len(rt.Backups)))
+		for _, backup := range rt.Backups {
+			write(d, backup.ID)
+		}
+		if rt.Pinned != nil {
+			write(d, rt.Pinned.ID)
+		} else {
+			write(d, 0)
+		}
+		write(d, uint64(len(rt.Drained)))
+		for _, member := range rt.Drained {
+			write(d, member.ID)
+		}
+	}
+	return d.Sum64()
+}
+
+func (r *RoutingTable) updateRoutingTableOnCluster() ([]memberReport, error) {
 	data, err := msgpack.Marshal(r.table)
 	if err != nil {
 		return nil, err
 	}
+	atomic.StoreUint64(&r.pushedSignature, signatureOf(r.table))
 
 	var mtx sync.Mutex
 	var g errgroup.Group
-	reports := make(map[discovery.Member]*leftOverDataReport)
+	var reports []memberReport
 	num := int64(runtime.NumCPU())
 	sem := semaphore.NewWeighted(num)
 
@@ -97,7 +150,7 @@ func (r *RoutingTable) updateRoutingTableOnCluster() (map[discovery.Member]*left
 
 			mtx.Lock()
 			defer mtx.Unlock()
-			reports[member] = report
+			reports = append(reports, memberReport{member: member, report: report})
 			return nil
 		})
 		return true
@@ -14,6 +14,13 @@
 
 package discovery
 
+import (
+	"fmt"
+
+	"github.com/buraksezer/olric/pkg/flog"
+	"github.com/hashicorp/memberlist"
+)
+
 // delegate is a struct which implements memberlist.Delegate interface.
 type delegate struct {
 	meta []byte
@@ -48,3 +55,52 @@ func (d delegate) LocalState(join bool) []byte { return nil }
 
 // MergeRemoteState is invoked after a TCP Push/Pull.
 func (d delegate) MergeRemoteState(buf []byte, join bool) {}
+
+// handshakeDelegate implements memberlist.MergeDelegate and
+// memberlist.AliveDelegate interfaces. It rejects the members which distribute
+// the keys with a different hash function.
+type handshakeDelegate struct {
+	log         *flog.Logger
+	fingerprint uint64
+}
+
+func (d *Discovery) newHandshakeDelegate() handshakeDelegate {
+	return handshakeDelegate{
+		log:         d.log,
+		fingerprint: d.member.HasherFingerprint,
+	}
+}
+
+func (h handshakeDelegate) validate(peer *memberlist.Node) error {
+	if len(peer.Meta) == 0 {
+		return nil
+	}
+	member, err := NewMemberFromMetadata(peer.Meta)
+	if err != nil {
+		return err
+	}
+	// Zero denotes a member that doesn't send a fingerprint.
+	if member.HasherFingerprint == 0 || member.HasherFingerprint == h.fingerprint {
+		return nil
+	}
+	h.log.V(2).Printf("[ERROR] Member: %s uses a different hasher, fingerprint: %d != %d",
+		member, member.HasherFingerprint, h.fingerprint)
+	return fmt.Errorf("%w: %s", ErrHasherMismatch, member)
+}
+
+// NotifyMerge is invoked when a merge could take place. It cancels the merge,
+// so the join attempt, if one of the peers uses a different hasher.
+func (h handshakeDelegate) NotifyMerge(peers []*memberlist.Node) error {
+	for _, peer := range peers {
+		if err := h.validate(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyAlive is invoked when a message about a live node is received. The
+// message is ignored if the node uses a different hasher.
+func (h handshakeDelegate) NotifyAlive(peer *memberlist.Node) error {
+	return h.validate(peer)
+}
@@ -23,10 +23,12 @@ import (
 	"plugin"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/discovery/kubernetes"
 	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/buraksezer/olric/pkg/service_discovery"
@@ -35,12 +37,19 @@ import (
 
 const eventChanCapacity = 256
 
+// dnsSRVScheme is the prefix of the peers that are resolved with DNS SRV lookups.
+const dnsSRVScheme = "dns+srv://"
+
 // UptimeSeconds is number of seconds since the server started.
 var UptimeSeconds = stats.NewInt64Counter()
 
 // ErrMemberNotFound indicates that the requested member could not be found in the member list.
 var ErrMemberNotFound = errors.New("member not found")
 
+// ErrHasherMismatch indicates that a member uses a different hash function to
+// distribute the keys. See config.Config.Hasher.
+var ErrHasherMismatch = errors.New("hasher mismatch")
+
 // ClusterEvent is a single event related to node activity in the memberlist.
 // The Node member of this struct must not be directly modified.
 type ClusterEvent struct {
@@ -71,6 +80,7 @@ type Discovery struct {
 	// Try to reconnect dead members
 	eventSubscribers []chan *ClusterEvent
 	serviceDiscovery service_discovery.ServiceDiscovery
+	lookupSRV        func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
 
 	// Flow control
 	wg     sync.WaitGroup
@@ -83,11 +93,12 @@ func New(log *flog.Logger, c *config.Config) *Discovery {
 	member := NewMember(c)
 	ctx, cancel := context.WithCancel(context.Background())
 	d := &Discovery{
-		member: &member,
-		config: c,
-		log:    log,
-		ctx:    ctx,
-		cancel: cancel,
+		member:    &member,
+		config:    c,
+		log:       log,
+		lookupSRV: net.DefaultResolver.LookupSRV,
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 	return d
 }
@@ -99,11 +110,7 @@ func (d *Discovery) loadServiceDiscoveryPlugin() error {
 		if sd, ok = val.(service_discovery.ServiceDiscovery); !ok {
 			return fmt.Errorf("plugin type %T is not a ServiceDiscovery interface", val)
 		}
-	} else {
-		pluginPath, ok := d.config.ServiceDiscovery["path"]
-		if !ok {
-			return fmt.Errorf("plugin path could not be found")
-		}
+	} else if pluginPath, ok := d.config.ServiceDiscovery["path"]; ok {
 		plug, err := plugin.Open(pluginPath.(string))
 		if err != nil {
 			return fmt.Errorf("failed to open plugin: %w", err)
@@ -117,6 +124,11 @@ func (d *Discovery) loadServiceDiscoveryPlugin() error {
 		if sd, ok = symDiscovery.(service_discovery.ServiceDiscovery); !ok {
 			return fmt.Errorf("unable to assert type to serviceDiscovery")
 		}
+	} else if d.config.ServiceDiscovery["provider"] == kubernetes.ProviderName {
+		// Built-in provider, it doesn't require a plugin.
+		sd = kubernetes.New()
+	} else {
+		return fmt.Errorf("plugin path could not be found")
 	}
 
 	if err := sd.SetConfig(d.config.ServiceDiscovery); err != nil {
@@ -164,10 +176,13 @@ func (d *Discovery) Start() error {
 	}
 	eventsCh := make(chan memberlist.NodeEvent, eventChanCapacity)
 	d.config.MemberlistConfig.Delegate = dl
-	d.config.MemberlistConfig.Logger = d.config.Logger
+	d.config.MemberlistConfig.Merge = d.newHandshakeDelegate()
+	d.config.MemberlistConfig.Alive = d.newHandshakeDelegate()
+	d.config.MemberlistConfig.Logger = newMemberlistLogger(d.config.Logger)
 	d.config.MemberlistConfig.Events = &memberlist.ChannelEventDelegate{
 		Ch: eventsCh,
 	}
+	installMetricsSink(d.config.MetricsSink, d.config.MemberlistConfig.ProbeInterval)
 	list, err := memberlist.Create(d.config.MemberlistConfig)
 	if err != nil {
 		return err
@@ -201,7 +216,53 @@ func (d *Discovery) Join() (int, error) {
 		}
 		return d.memberlist.Join(peers)
 	}
-	return d.memberlist.Join(d.config.Peers)
+	peers, err := d.resolvePeers()
+	if err != nil {
+		return 0, err
+	}
+	return d.memberlist.Join(peers)
+}
+
+// resolvePeers returns config.Peers after resolving the dns+srv:// entries,
+// and the addresses of config.PeerResolver. It's called for every join
+// attempt, so the members can join when the seeds are replaced behind a DNS
+// name. A failed lookup is skipped if there are other peers.
+func (d *Discovery) resolvePeers() ([]string, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.config.MemberlistConfig.TCPTimeout)
+	defer cancel()
+
+	var peers []string
+	var latestError error
+	for _, peer := range d.config.Peers {
+		if !strings.HasPrefix(peer, dnsSRVScheme) {
+			peers = append(peers, peer)
+			continue
+		}
+		_, records, err := d.lookupSRV(ctx, "", "", strings.TrimPrefix(peer, dnsSRVScheme))
+		if err != nil {
+			d.log.V(2).Printf("[ERROR] Failed to resolve %s: %v", peer, err)
+			latestError = err
+			continue
+		}
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			peers = append(peers, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+		}
+	}
+
+	if d.config.PeerResolver != nil {
+		addrs, err := d.config.PeerResolver.Resolve(ctx)
+		if err != nil {
+			d.log.V(2).Printf("[ERROR] PeerResolver returned an error: %v", err)
+			latestError = err
+		}
+		peers = append(peers, addrs...)
+	}
+
+	if len(peers) == 0 && latestError != nil {
+		return nil, latestError
+	}
+	return peers, nil
 }
 
 func (d *Discovery) Rejoin(peers []string) (int, error) {
@@ -228,6 +289,21 @@ func (d *Discovery) NumMembers() int {
 	return d.memberlist.NumMembers()
 }
 
+// MemberHealth returns the state of the known members by their names, as seen
+// by the failure detector of this member: "alive" or "suspect".
+func (d *Discovery) MemberHealth() map[string]string {
+	result := make(map[string]string)
+	for _, node := range d.memberlist.Members() {
+		switch node.State {
+		case memberlist.StateAlive:
+			result[node.Name] = "alive"
+		case memberlist.StateSuspect:
+			result[node.Name] = "suspect"
+		}
+	}
+	return result
+}
+
 // FindMemberByName finds and returns an alive member.
 func (d *Discovery) FindMemberByName(name string) (Member, error) {
 	members := d.GetMembers()
@@ -15,6 +15,8 @@
 package discovery
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/buraksezer/olric/pkg/service_discovery"
 	"github.com/hashicorp/memberlist"
@@ -75,6 +77,12 @@ func (tc *testCluster) addNewMember(t *testing.T) *Discovery {
 	return d
 }
 
+type testHasher struct{}
+
+func (testHasher) Sum64(key []byte) uint64 {
+	return uint64(len(key))
+}
+
 func TestDiscovery_GetCoordinator(t *testing.T) {
 	c := newTestCluster(t)
 	d1 := c.addNewMember(t)
@@ -149,6 +157,25 @@ func TestDiscovery_FindMemberByName(t *testing.T) {
 	}
 }
 
+func TestDiscovery_Join_HasherMismatch(t *testing.T) {
+	tc := newTestCluster(t)
+	d1 := tc.addNewMember(t)
+
+	cfg := testutil.NewConfig()
+	cfg.Peers = append(cfg.Peers, tc.members...)
+	cfg.Hasher = testHasher{}
+	d2 := New(testutil.NewFlogger(cfg), cfg)
+	require.NoError(t, d2.Start())
+	defer func() {
+		require.NoError(t, d2.Shutdown())
+	}()
+
+	_, err := d2.Join()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrHasherMismatch.Error())
+	require.Equal(t, 1, d1.NumMembers())
+}
+
 func TestDiscovery_increaseUptimeSeconds(t *testing.T) {
 	c := newTestCluster(t)
 	c.addNewMember(t)
@@ -254,6 +281,51 @@ func TestDiscovery_loadServiceDiscoveryPlugin(t *testing.T) {
 	require.True(t, sd.discoverPeers)
 }
 
+type testAddressResolver struct {
+	addrs []string
+	err   error
+}
+
+func (r *testAddressResolver) Resolve(_ context.Context) ([]string, error) {
+	return r.addrs, r.err
+}
+
+func TestDiscovery_resolvePeers(t *testing.T) {
+	c := testutil.NewConfig()
+	c.Peers = []string{"127.0.0.1:3322", "dns+srv://_olric._tcp.example.com"}
+	resolver := &testAddressResolver{addrs: []string{"127.0.0.1:3324"}}
+	c.PeerResolver = resolver
+
+	d := New(testutil.NewFlogger(c), c)
+	var srvErr error
+	d.lookupSRV = func(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+		require.Equal(t, "_olric._tcp.example.com", name)
+		if srvErr != nil {
+			return "", nil, srvErr
+		}
+		return "", []*net.SRV{{Target: "olric-0.example.com.", Port: 3323}}, nil
+	}
+
+	peers, err := d.resolvePeers()
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1:3322", "olric-0.example.com:3323", "127.0.0.1:3324"}, peers)
+
+	t.Run("Failed lookup is skipped", func(t *testing.T) {
+		srvErr = errors.New("no such host")
+		resolver.err = errors.New("resolver error")
+		resolver.addrs = nil
+		peers, err := d.resolvePeers()
+		require.NoError(t, err)
+		require.Equal(t, []string{"127.0.0.1:3322"}, peers)
+	})
+
+	t.Run("No peers", func(t *testing.T) {
+		d.config.Peers = d.config.Peers[1:]
+		_, err := d.resolvePeers()
+		require.Error(t, err)
+	})
+}
+
 func TestDiscovery_ClusterEvents(t *testing.T) {
 	c := newTestCluster(t)
 	d1 := c.addNewMember(t)
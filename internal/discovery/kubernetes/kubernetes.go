@@ -0,0 +1,353 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*Package kubernetes implements the built-in Kubernetes service discovery provider.*/
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ProviderName is the value of the provider key that selects this provider
+	// in config.Config.ServiceDiscovery.
+	ProviderName = "k8s"
+
+	// APIMode lists the pods by a label selector on the Kubernetes API server.
+	APIMode = "api"
+
+	// DNSMode resolves the pod IPs from the DNS name of a headless service.
+	DNSMode = "dns"
+
+	// DefaultPort is the default memberlist port of the pods.
+	DefaultPort = 3322
+
+	// DefaultTimeout is the default timeout of a discovery request.
+	DefaultTimeout = 10 * time.Second
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// ErrNotEnoughPeers is returned by DiscoverPeers until minPeers pods are discovered.
+var ErrNotEnoughPeers = errors.New("not enough peers")
+
+// Kubernetes discovers the memberlist addresses of the Olric pods. The pods
+// that are terminating are skipped, the pods that are not ready yet are not,
+// so the members can join each other before they pass their readiness probes.
+// Configuration keys:
+//
+//	provider:      "k8s"
+//	mode:          "api" (default) or "dns"
+//	labelSelector: label selector of the pods, required in api mode
+//	namespace:     namespace of the pods, the namespace of the pod by default
+//	service:       DNS name of the headless service, required in dns mode
+//	port:          memberlist port of the pods, 3322 by default
+//	minPeers:      minimum number of the discovered pods, including this one
+//	timeout:       timeout of a discovery request, "10s" by default
+//	apiServer:     address of the API server, in-cluster address by default
+//	tokenFile:     service account token, in-cluster token by default
+//	caFile:        CA certificate of the API server, in-cluster CA by default
+//
+// A headless service should set publishNotReadyAddresses to true in dns mode.
+type Kubernetes struct {
+	log           *log.Logger
+	mode          string
+	labelSelector string
+	namespace     string
+	service       string
+	port          int
+	minPeers      int
+	timeout       time.Duration
+	apiServer     string
+	tokenFile     string
+	caFile        string
+
+	client     *http.Client
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+// New returns a new Kubernetes provider.
+func New() *Kubernetes {
+	return &Kubernetes{
+		log:        log.New(os.Stderr, "", log.LstdFlags),
+		lookupHost: net.DefaultResolver.LookupHost,
+	}
+}
+
+func stringValue(c map[string]interface{}, key, def string) (string, error) {
+	raw, ok := c[key]
+	if !ok {
+		return def, nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string, got %T", key, raw)
+	}
+	return value, nil
+}
+
+func intValue(c map[string]interface{}, key string, def int) (int, error) {
+	raw, ok := c[key]
+	if !ok {
+		return def, nil
+	}
+	switch value := raw.(type) {
+	case int:
+		return value, nil
+	case int64:
+		return int(value), nil
+	case uint64:
+		return int(value), nil
+	case float64:
+		return int(value), nil
+	case string:
+		return strconv.Atoi(value)
+	default:
+		return 0, fmt.Errorf("%s must be an integer, got %T", key, raw)
+	}
+}
+
+// SetConfig registers the provider configuration.
+func (k *Kubernetes) SetConfig(c map[string]interface{}) error {
+	var err error
+	if k.mode, err = stringValue(c, "mode", APIMode); err != nil {
+		return err
+	}
+	if k.labelSelector, err = stringValue(c, "labelSelector", ""); err != nil {
+		return err
+	}
+	if k.namespace, err = stringValue(c, "namespace", ""); err != nil {
+		return err
+	}
+	if k.service, err = stringValue(c, "service", ""); err != nil {
+		return err
+	}
+	if k.port, err = intValue(c, "port", DefaultPort); err != nil {
+		return err
+	}
+	if k.minPeers, err = intValue(c, "minPeers", 0); err != nil {
+		return err
+	}
+	timeout, err := stringValue(c, "timeout", "")
+	if err != nil {
+		return err
+	}
+	k.timeout = DefaultTimeout
+	if timeout != "" {
+		if k.timeout, err = time.ParseDuration(timeout); err != nil {
+			return fmt.Errorf("failed to parse timeout: %w", err)
+		}
+	}
+	if k.apiServer, err = stringValue(c, "apiServer", ""); err != nil {
+		return err
+	}
+	if k.tokenFile, err = stringValue(c, "tokenFile", serviceAccountDir+"/token"); err != nil {
+		return err
+	}
+	if k.caFile, err = stringValue(c, "caFile", serviceAccountDir+"/ca.crt"); err != nil {
+		return err
+	}
+
+	switch k.mode {
+	case APIMode:
+		if k.labelSelector == "" {
+			return fmt.Errorf("labelSelector is required in %s mode", APIMode)
+		}
+	case DNSMode:
+		if k.service == "" {
+			return fmt.Errorf("service is required in %s mode", DNSMode)
+		}
+	default:
+		return fmt.Errorf("unknown mode: %s", k.mode)
+	}
+	if k.port <= 0 || k.port > 65535 {
+		return fmt.Errorf("invalid port: %d", k.port)
+	}
+	if k.minPeers < 0 {
+		return fmt.Errorf("cannot specify minPeers less than zero")
+	}
+	return nil
+}
+
+// SetLogger sets the logger of the provider.
+func (k *Kubernetes) SetLogger(l *log.Logger) {
+	if l != nil {
+		k.log = l
+	}
+}
+
+// Initialize prepares the API client in api mode.
+func (k *Kubernetes) Initialize() error {
+	if k.mode != APIMode {
+		return nil
+	}
+
+	if k.apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return fmt.Errorf("apiServer is not set and this process is not running in a Kubernetes cluster")
+		}
+		k.apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	if k.namespace == "" {
+		data, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return fmt.Errorf("failed to read the namespace of the pod: %w", err)
+		}
+		k.namespace = strings.TrimSpace(string(data))
+	}
+
+	if k.client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if ca, err := ioutil.ReadFile(k.caFile); err == nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return fmt.Errorf("failed to parse the CA certificate: %s", k.caFile)
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read the CA certificate: %w", err)
+		}
+		k.client = &http.Client{Transport: transport}
+	}
+	return nil
+}
+
+// Register does nothing, the pods are registered by Kubernetes.
+func (k *Kubernetes) Register() error {
+	return nil
+}
+
+// Deregister does nothing, the pods are deregistered by Kubernetes.
+func (k *Kubernetes) Deregister() error {
+	return nil
+}
+
+// DiscoverPeers returns the memberlist addresses of the Olric pods. It's
+// called for every join attempt, so the addresses are fresh after the pods
+// are rescheduled. It returns ErrNotEnoughPeers if less than minPeers pods
+// are discovered.
+func (k *Kubernetes) DiscoverPeers() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), k.timeout)
+	defer cancel()
+
+	var ips []string
+	var err error
+	if k.mode == DNSMode {
+		ips, err = k.lookupHost(ctx, k.service)
+	} else {
+		ips, err = k.listPods(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var peers []string
+	for _, ip := range ips {
+		if _, ok := seen[ip]; ok {
+			continue
+		}
+		seen[ip] = struct{}{}
+		peers = append(peers, net.JoinHostPort(ip, strconv.Itoa(k.port)))
+	}
+	sort.Strings(peers)
+
+	if len(peers) == 0 || len(peers) < k.minPeers {
+		return nil, fmt.Errorf("%w: discovered %d, required %d", ErrNotEnoughPeers, len(peers), k.minPeers)
+	}
+	k.log.Printf("[INFO] Discovered %d Olric pods", len(peers))
+	return peers, nil
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			DeletionTimestamp *string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Phase string `json:"phase"`
+			PodIP string `json:"podIP"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func (k *Kubernetes) listPods(ctx context.Context) ([]string, error) {
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/pods?labelSelector=%s",
+		strings.TrimSuffix(k.apiServer, "/"), url.PathEscape(k.namespace), url.QueryEscape(k.labelSelector))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The token is rotated by the kubelet, read it for every request.
+	if token, err := ioutil.ReadFile(k.tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read the service account token: %w", err)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list the pods: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var pods podList
+	if err = json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, pod := range pods.Items {
+		if pod.Metadata.DeletionTimestamp != nil {
+			// Terminating
+			continue
+		}
+		if pod.Status.PodIP == "" {
+			// Not scheduled yet
+			continue
+		}
+		if pod.Status.Phase != "Running" && pod.Status.Phase != "Pending" {
+			continue
+		}
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips, nil
+}
+
+// Close does nothing.
+func (k *Kubernetes) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPodList = `{
+  "items": [
+    {"metadata": {}, "status": {"phase": "Running", "podIP": "10.0.0.2"}},
+    {"metadata": {}, "status": {"phase": "Pending", "podIP": "10.0.0.1"}},
+    {"metadata": {"deletionTimestamp": "2022-05-15T10:00:00Z"}, "status": {"phase": "Running", "podIP": "10.0.0.3"}},
+    {"metadata": {}, "status": {"phase": "Pending"}},
+    {"metadata": {}, "status": {"phase": "Failed", "podIP": "10.0.0.4"}}
+  ]
+}`
+
+func newTestAPIServer(t *testing.T) (*httptest.Server, string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/namespaces/olric/pods" || r.URL.Query().Get("labelSelector") != "app=olricd" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(testPodList))
+	}))
+	t.Cleanup(srv.Close)
+
+	f, err := ioutil.TempFile("/tmp/", "olric-k8s-token")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, os.Remove(f.Name()))
+	})
+	require.NoError(t, f.Close())
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("token\n"), 0600))
+	return srv, f.Name()
+}
+
+func TestKubernetes_DiscoverPeers_API(t *testing.T) {
+	srv, tokenFile := newTestAPIServer(t)
+
+	k := New()
+	err := k.SetConfig(map[string]interface{}{
+		"provider":      "k8s",
+		"labelSelector": "app=olricd",
+		"namespace":     "olric",
+		"port":          3322,
+		"apiServer":     srv.URL,
+		"tokenFile":     tokenFile,
+		"caFile":        "/nonexistent/ca.crt",
+	})
+	require.NoError(t, err)
+	require.NoError(t, k.Initialize())
+
+	peers, err := k.DiscoverPeers()
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:3322", "10.0.0.2:3322"}, peers)
+
+	t.Run("minPeers", func(t *testing.T) {
+		k.minPeers = 3
+		defer func() {
+			k.minPeers = 0
+		}()
+		_, err = k.DiscoverPeers()
+		require.ErrorIs(t, err, ErrNotEnoughPeers)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte("foobar"), 0600))
+		_, err = k.DiscoverPeers()
+		require.Error(t, err)
+	})
+}
+
+func TestKubernetes_DiscoverPeers_DNS(t *testing.T) {
+	k := New()
+	err := k.SetConfig(map[string]interface{}{
+		"provider": "k8s",
+		"mode":     "dns",
+		"service":  "olricd.olric.svc.cluster.local",
+		"port":     "3000",
+		"minPeers": 2,
+	})
+	require.NoError(t, err)
+	require.NoError(t, k.Initialize())
+
+	var addrs []string
+	k.lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host != "olricd.olric.svc.cluster.local" {
+			return nil, errors.New("no such host")
+		}
+		return addrs, nil
+	}
+
+	addrs = []string{"10.0.0.1"}
+	_, err = k.DiscoverPeers()
+	require.ErrorIs(t, err, ErrNotEnoughPeers)
+
+	addrs = []string{"10.0.0.2", "10.0.0.1", "10.0.0.2"}
+	peers, err := k.DiscoverPeers()
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:3000", "10.0.0.2:3000"}, peers)
+}
+
+func TestKubernetes_SetConfig(t *testing.T) {
+	configs := []map[string]interface{}{
+		{"mode": "foobar"},
+		{"mode": "api"},
+		{"mode": "dns"},
+		{"mode": "dns", "service": "olricd", "port": 0},
+		{"mode": "dns", "service": "olricd", "minPeers": -1},
+		{"mode": "dns", "service": "olricd", "timeout": "foobar"},
+		{"mode": "dns", "service": 1},
+	}
+	for _, c := range configs {
+		require.Error(t, New().SetConfig(c), "%v", c)
+	}
+}
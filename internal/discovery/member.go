@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/hasher"
 	"github.com/cespare/xxhash/v2"
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -29,6 +30,18 @@ type Member struct {
 	NameHash  uint64
 	ID        uint64
 	Birthdate int64
+
+	// Analytics is true if the member is an analytics replica, it doesn't own
+	// any partition. See config.Config.AnalyticsReplica.
+	Analytics bool
+
+	// Tags is the arbitrary key/value metadata of the member, see
+	// config.Config.MemberTags.
+	Tags map[string]string
+
+	// HasherFingerprint identifies the hash function used by the member to
+	// distribute keys, see hasher.Fingerprint. Zero means unknown.
+	HasherFingerprint uint64
 }
 
 // CompareByID returns true if two members denote the same member in the cluster.
@@ -68,10 +81,18 @@ func MemberID(name string, birthdate int64) uint64 {
 func NewMember(c *config.Config) Member {
 	birthdate := time.Now().UnixNano()
 	nameHash := xxhash.Sum64([]byte(c.MemberlistConfig.Name))
+	h := c.Hasher
+	if h == nil {
+		h = hasher.NewDefaultHasher()
+	}
 	return Member{
 		Name:      c.MemberlistConfig.Name,
 		NameHash:  nameHash,
 		ID:        MemberID(c.MemberlistConfig.Name, birthdate),
 		Birthdate: birthdate,
+		Analytics: c.AnalyticsReplica,
+		Tags:      c.MemberTags,
+
+		HasherFingerprint: hasher.Fingerprint(h),
 	}
 }
@@ -0,0 +1,209 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/buraksezer/olric/internal/stats"
+)
+
+var (
+	// ProbeFailuresTotal is the number of failure detector probes that got no ack.
+	ProbeFailuresTotal = stats.NewInt64Counter()
+
+	// SuspectTimeoutsTotal is the number of suspected members that are marked as
+	// failed because the suspicion timed out.
+	SuspectTimeoutsTotal = stats.NewInt64Counter()
+
+	// RefutedSuspicionsTotal is the number of times this member refuted a
+	// suspicion about itself.
+	RefutedSuspicionsTotal = stats.NewInt64Counter()
+
+	// DroppedMessagesTotal is the number of gossip messages dropped because
+	// the handler queue was full.
+	DroppedMessagesTotal = stats.NewInt64Counter()
+
+	// SuspectMessagesTotal is the number of suspect messages processed.
+	SuspectMessagesTotal = stats.NewInt64Counter()
+
+	// DeadMessagesTotal is the number of dead messages processed.
+	DeadMessagesTotal = stats.NewInt64Counter()
+
+	// DegradedProbesTotal is the number of probes run with a scaled interval,
+	// because the local health score was degraded.
+	DegradedProbesTotal = stats.NewInt64Counter()
+
+	// BroadcastQueueDepth is the number of broadcasts waiting in the queue,
+	// sampled by memberlist.
+	BroadcastQueueDepth = stats.NewInt64Gauge()
+)
+
+// memberlist reports its internals to the global go-metrics instance. The
+// events without a metric are only logged, so the logger output is inspected
+// too.
+var (
+	installMetricsSinkOnce sync.Once
+	metricsSink            = &gossipSink{}
+)
+
+// installMetricsSink installs the gossip sink as the global go-metrics
+// instance, once per process, and adds the sink of a member to it. The probes
+// that take the whole probe interval are counted as failures.
+func installMetricsSink(sink metrics.MetricSink, probeInterval time.Duration) {
+	installMetricsSinkOnce.Do(func() {
+		cfg := metrics.DefaultConfig("")
+		cfg.EnableHostname = false
+		cfg.EnableRuntimeMetrics = false
+		_, _ = metrics.NewGlobal(cfg, metricsSink)
+	})
+	metricsSink.setProbeInterval(probeInterval)
+	if sink != nil {
+		metricsSink.addSink(sink)
+	}
+}
+
+// gossipSink collects the gossip statistics from the memberlist metrics and
+// passes the metrics to the sinks of the members.
+type gossipSink struct {
+	probeInterval int64 // in nanoseconds, accessed atomically
+
+	mtx   sync.RWMutex
+	sinks metrics.FanoutSink
+}
+
+func (g *gossipSink) setProbeInterval(interval time.Duration) {
+	atomic.StoreInt64(&g.probeInterval, int64(interval))
+}
+
+// addSink adds a sink unless it's already added by another member of the
+// process.
+func (g *gossipSink) addSink(sink metrics.MetricSink) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if reflect.TypeOf(sink).Comparable() {
+		for _, s := range g.sinks {
+			if s == sink {
+				return
+			}
+		}
+	}
+	g.sinks = append(g.sinks, sink)
+}
+
+func metricName(key []string) string {
+	return strings.Join(key, ".")
+}
+
+func (g *gossipSink) SetGauge(key []string, val float32) {
+	g.SetGaugeWithLabels(key, val, nil)
+}
+
+func (g *gossipSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	g.sinks.SetGaugeWithLabels(key, val, labels)
+}
+
+func (g *gossipSink) EmitKey(key []string, val float32) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	g.sinks.EmitKey(key, val)
+}
+
+func (g *gossipSink) IncrCounter(key []string, val float32) {
+	g.IncrCounterWithLabels(key, val, nil)
+}
+
+func (g *gossipSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	switch metricName(key) {
+	case "memberlist.msg.suspect":
+		SuspectMessagesTotal.Increase(int64(val))
+	case "memberlist.msg.dead":
+		DeadMessagesTotal.Increase(int64(val))
+	case "memberlist.degraded.probe":
+		DegradedProbesTotal.Increase(int64(val))
+	}
+
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	g.sinks.IncrCounterWithLabels(key, val, labels)
+}
+
+func (g *gossipSink) AddSample(key []string, val float32) {
+	g.AddSampleWithLabels(key, val, nil)
+}
+
+func (g *gossipSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	switch metricName(key) {
+	case "memberlist.queue.broadcasts":
+		BroadcastQueueDepth.Set(int64(val))
+	case "memberlist.probeNode":
+		// The sample is in milliseconds. A probe without any ack waits until
+		// the end of the probe interval, the acknowledged ones return earlier.
+		probeInterval := atomic.LoadInt64(&g.probeInterval)
+		if probeInterval > 0 && float64(val)*float64(time.Millisecond) >= float64(probeInterval) {
+			ProbeFailuresTotal.Increase(1)
+		}
+	}
+
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	g.sinks.AddSampleWithLabels(key, val, labels)
+}
+
+// gossipLogWriter counts the memberlist log lines that have no metric
+// counterpart, and passes them to the underlying writer.
+type gossipLogWriter struct {
+	w io.Writer
+}
+
+var gossipLogCounters = []struct {
+	line    []byte
+	counter *stats.Int64Counter
+}{
+	{[]byte("suspect timeout reached"), SuspectTimeoutsTotal},
+	{[]byte("Refuting a suspect message"), RefutedSuspicionsTotal},
+	{[]byte("dropping message"), DroppedMessagesTotal},
+}
+
+func (g *gossipLogWriter) Write(p []byte) (int, error) {
+	for _, c := range gossipLogCounters {
+		if bytes.Contains(p, c.line) {
+			c.counter.Increase(1)
+			break
+		}
+	}
+	return g.w.Write(p)
+}
+
+func newMemberlistLogger(l *log.Logger) *log.Logger {
+	return log.New(&gossipLogWriter{w: l.Writer()}, l.Prefix(), l.Flags())
+}
+
+// HealthScore returns the health score of the local member, see
+// memberlist.GetHealthScore. Lower values are healthier, zero is the best.
+func (d *Discovery) HealthScore() int {
+	return d.memberlist.GetHealthScore()
+}
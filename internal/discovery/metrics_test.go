@@ -0,0 +1,62 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscovery_MemberlistLogger(t *testing.T) {
+	DroppedMessagesTotal.Reset()
+
+	buf := bytes.NewBuffer(nil)
+	l := newMemberlistLogger(log.New(buf, "", 0))
+	l.Printf("[WARN] memberlist: handler queue full, dropping message (%d) %s", 1, "127.0.0.1:3322")
+	l.Printf("[DEBUG] memberlist: Stream connection from=127.0.0.1:3322")
+
+	require.Equal(t, int64(1), DroppedMessagesTotal.Read())
+	require.Contains(t, buf.String(), "Stream connection")
+}
+
+func TestDiscovery_MetricsSink(t *testing.T) {
+	SuspectMessagesTotal.Reset()
+	BroadcastQueueDepth.Reset()
+	ProbeFailuresTotal.Reset()
+
+	// The sink of the application receives the metrics too.
+	inmem := metrics.NewInmemSink(time.Minute, time.Minute)
+	installMetricsSink(inmem, time.Second)
+	installMetricsSink(inmem, time.Second)
+
+	metrics.IncrCounterWithLabels([]string{"memberlist", "msg", "suspect"}, 1, nil)
+	metrics.AddSampleWithLabels([]string{"memberlist", "queue", "broadcasts"}, 7, nil)
+	metrics.AddSampleWithLabels([]string{"memberlist", "probeNode"}, 5, nil)
+	metrics.AddSampleWithLabels([]string{"memberlist", "probeNode"}, 1001, nil)
+
+	require.Equal(t, int64(1), SuspectMessagesTotal.Read())
+	require.Equal(t, int64(7), BroadcastQueueDepth.Read())
+	require.Equal(t, int64(1), ProbeFailuresTotal.Read())
+
+	data := inmem.Data()
+	require.NotEmpty(t, data)
+	require.Equal(t, 1, data[0].Counters["memberlist.msg.suspect"].Count)
+	require.Equal(t, 2, data[0].Samples["memberlist.probeNode"].Count)
+}
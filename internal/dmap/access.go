@@ -0,0 +1,228 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrAccessStatsDisabled is returned when the access statistics are requested
+// from a DMap without AccessSampleRate.
+var ErrAccessStatsDisabled = errors.New("access statistics are disabled")
+
+// AccessStats is the access statistics of an entry.
+type AccessStats struct {
+	Key string
+
+	// Hits is the estimated number of reads. It's extrapolated from the
+	// sampled reads, so it's not exact.
+	Hits int64
+
+	// LastAccess is the time of the last read or write access in nanoseconds.
+	LastAccess int64
+}
+
+// accessLog keeps the number of the sampled reads of the keys in a fragment.
+// It's updated under the fragment's read lock, so it has its own lock.
+type accessLog struct {
+	mtx    sync.Mutex
+	counts map[uint64]int64
+}
+
+func newAccessLog() *accessLog {
+	return &accessLog{
+		counts: make(map[uint64]int64),
+	}
+}
+
+func (a *accessLog) increase(hkey uint64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.counts[hkey]++
+}
+
+func (a *accessLog) get(hkey uint64) int64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.counts[hkey]
+}
+
+func (a *accessLog) delete(hkey uint64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	delete(a.counts, hkey)
+}
+
+// recordAccess samples a read of the given key on the partition owner.
+func (dm *DMap) recordAccess(hkey uint64) {
+	rate := dm.config().accessSampleRate
+	if rate <= 0 {
+		return
+	}
+	if rate < 1 && rand.Float64() >= rate {
+		return
+	}
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if err != nil {
+		return
+	}
+	f.access.increase(hkey)
+}
+
+func sortAccessStats(items []AccessStats, hottest bool) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Hits != items[j].Hits {
+			if hottest {
+				return items[i].Hits > items[j].Hits
+			}
+			return items[i].Hits < items[j].Hits
+		}
+		if hottest {
+			return items[i].LastAccess > items[j].LastAccess
+		}
+		return items[i].LastAccess < items[j].LastAccess
+	})
+}
+
+func (dm *DMap) accessStatsOnFragment(ctx context.Context, f *fragment) ([]AccessStats, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	var result []AccessStats
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if isKeyExpired(e.TTL()) {
+			return true
+		}
+		hits := f.access.get(hkey)
+		if hits > 0 {
+			hits = int64(float64(hits) / dm.config().accessSampleRate)
+		}
+		result = append(result, AccessStats{
+			Key:        e.Key(),
+			Hits:       hits,
+			LastAccess: e.LastAccess(),
+		})
+		return true
+	})
+	if err := ctx.Err(); err != nil {
+		CanceledOperationsTotal.Increase(1)
+		return nil, err
+	}
+	return result, nil
+}
+
+// accessStatsLocal returns the n hottest or coldest entries of the partitions
+// owned by this member.
+func (dm *DMap) accessStatsLocal(ctx context.Context, n int, hottest bool) ([]AccessStats, error) {
+	var result []AccessStats
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		part := dm.s.primary.PartitionByID(partID)
+		if !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items, err := dm.accessStatsOnFragment(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+		sortAccessStats(result, hottest)
+		if len(result) > n {
+			result = result[:n]
+		}
+	}
+	return result, nil
+}
+
+func (dm *DMap) accessStats(ctx context.Context, n int, hottest bool) ([]AccessStats, error) {
+	if dm.config().accessSampleRate <= 0 {
+		return nil, ErrAccessStatsDisabled
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	var result []AccessStats
+	for _, member := range dm.s.rt.Discovery().GetMembers() {
+		if member.CompareByID(dm.s.rt.This()) {
+			items, err := dm.accessStatsLocal(ctx, n, hottest)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, items...)
+			continue
+		}
+
+		cmd := protocol.NewAccessStats(dm.name, n, hottest).SetLocal().Command(ctx)
+		rc := dm.s.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return nil, protocol.ConvertError(err)
+		}
+		raw, err := cmd.Result()
+		if err != nil {
+			return nil, protocol.ConvertError(err)
+		}
+		for _, item := range raw {
+			var s AccessStats
+			if err = msgpack.Unmarshal([]byte(item), &s); err != nil {
+				return nil, err
+			}
+			result = append(result, s)
+		}
+	}
+
+	sortAccessStats(result, hottest)
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
+
+// Hottest returns the n most frequently read entries of the DMap. It returns
+// ErrAccessStatsDisabled if AccessSampleRate is not set for the DMap.
+func (dm *DMap) Hottest(ctx context.Context, n int) ([]AccessStats, error) {
+	return dm.accessStats(ctx, n, true)
+}
+
+// Coldest returns the n least frequently read entries of the DMap. The entries
+// with the same number of reads are ordered by their last access time. It
+// returns ErrAccessStatsDisabled if AccessSampleRate is not set for the DMap.
+func (dm *DMap) Coldest(ctx context.Context, n int) ([]AccessStats, error) {
+	return dm.accessStats(ctx, n, false)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) accessStatsCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	accessCmd, err := protocol.ParseAccessStatsCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getDMap(accessCmd.DMap)
+	if errors.Is(err, ErrDMapNotFound) && accessCmd.Local {
+		// This member has no entry of the DMap.
+		conn.WriteArray(0)
+		return
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	var items []AccessStats
+	if accessCmd.Local {
+		items, err = dm.accessStatsLocal(ctx, accessCmd.Count, accessCmd.Hottest)
+	} else {
+		items, err = dm.accessStats(ctx, accessCmd.Count, accessCmd.Hottest)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	encoded := make([][]byte, 0, len(items))
+	for _, item := range items {
+		data, err := msgpack.Marshal(item)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		encoded = append(encoded, data)
+	}
+
+	conn.WriteArray(len(encoded))
+	for _, data := range encoded {
+		conn.WriteBulk(data)
+	}
+}
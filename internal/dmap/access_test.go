@@ -0,0 +1,94 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_AccessStats_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{AccessSampleRate: 1})
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{AccessSampleRate: 1})
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	// The key i is read i times.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < i; j++ {
+			_, err = dm2.Get(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+		}
+	}
+
+	hottest, err := dm1.Hottest(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, hottest, 3)
+	for i, item := range hottest {
+		require.Equal(t, testutil.ToKey(9-i), item.Key)
+		require.Equal(t, int64(9-i), item.Hits)
+		require.NotZero(t, item.LastAccess)
+	}
+
+	coldest, err := dm2.Coldest(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, coldest, 2)
+	require.Equal(t, testutil.ToKey(0), coldest[0].Key)
+	require.Equal(t, int64(0), coldest[0].Hits)
+	require.Equal(t, testutil.ToKey(1), coldest[1].Key)
+	require.Equal(t, int64(1), coldest[1].Hits)
+
+	// The statistics of a deleted key are dropped.
+	_, err = dm1.Delete(ctx, testutil.ToKey(9))
+	require.NoError(t, err)
+	err = dm1.Put(ctx, testutil.ToKey(9), testutil.ToVal(9), nil)
+	require.NoError(t, err)
+
+	hottest, err = dm2.Hottest(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, testutil.ToKey(8), hottest[0].Key)
+}
+
+func TestDMap_AccessStats_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Hottest(context.Background(), 10)
+	require.ErrorIs(t, err, ErrAccessStatsDisabled)
+
+	_, err = dm.Coldest(context.Background(), 10)
+	require.ErrorIs(t, err, ErrAccessStatsDisabled)
+}
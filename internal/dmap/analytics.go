@@ -0,0 +1,440 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// maxPendingAnalyticsRecords is the maximum number of the writes that are
+	// waiting to be sent to an analytics replica. If it's exceeded, the writes
+	// are dropped and the DMaps are copied to the replica again.
+	maxPendingAnalyticsRecords = 1 << 16
+
+	// analyticsBatchSize is the maximum number of the writes in a dm.replicate command.
+	analyticsBatchSize = 512
+)
+
+var (
+	// AnalyticsReplicatedTotal is the number of the writes sent to the analytics replicas.
+	AnalyticsReplicatedTotal = stats.NewInt64Counter()
+
+	// AnalyticsDroppedTotal is the number of the writes that are dropped because
+	// an analytics replica fell behind or failed.
+	AnalyticsDroppedTotal = stats.NewInt64Counter()
+)
+
+// analyticsRecord is a write that is replicated to the analytics replicas.
+// Entry is the encoded entry, it's nil for a delete. Timestamp is the time of
+// a delete, the timestamp of a write is in its entry.
+type analyticsRecord struct {
+	DMap      string `msgpack:"dmap"`
+	HKey      uint64 `msgpack:"hkey"`
+	Entry     []byte `msgpack:"entry"`
+	Timestamp int64  `msgpack:"timestamp"`
+}
+
+// analyticsTarget sends the writes to an analytics replica in order, by a
+// single goroutine. The DMaps are copied to the replica before the first
+// write, and again after the writes are dropped.
+type analyticsTarget struct {
+	member discovery.Member
+
+	mtx     sync.Mutex
+	pending []analyticsRecord
+	running bool
+	synced  bool
+	closed  bool
+}
+
+// analyticsReplicator replicates the writes of the primary partitions owned by
+// this member to the analytics replicas asynchronously.
+type analyticsReplicator struct {
+	s       *Service
+	mtx     sync.RWMutex
+	targets map[uint64]*analyticsTarget
+	count   int32
+}
+
+func newAnalyticsReplicator(s *Service) *analyticsReplicator {
+	return &analyticsReplicator{
+		s:       s,
+		targets: make(map[uint64]*analyticsTarget),
+	}
+}
+
+func (a *analyticsReplicator) enabled() bool {
+	return atomic.LoadInt32(&a.count) > 0
+}
+
+// refresh updates the targets with the analytics replicas in the routing
+// table. It's called after the routing table is updated.
+func (a *analyticsReplicator) refresh() {
+	if a.s.rt.This().Analytics {
+		return
+	}
+
+	current := make(map[uint64]discovery.Member)
+	for _, member := range a.s.rt.AnalyticsMembers() {
+		current[member.ID] = member
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for id, t := range a.targets {
+		if _, ok := current[id]; !ok {
+			t.mtx.Lock()
+			t.closed = true
+			t.pending = nil
+			t.mtx.Unlock()
+			delete(a.targets, id)
+		}
+	}
+	for id, member := range current {
+		if _, ok := a.targets[id]; ok {
+			continue
+		}
+		t := &analyticsTarget{member: member}
+		a.targets[id] = t
+		// Copy the DMaps even if there is no write.
+		a.s.wg.Add(1)
+		t.running = true
+		go a.drain(t)
+	}
+	atomic.StoreInt32(&a.count, int32(len(a.targets)))
+}
+
+// send queues the record for all analytics replicas. Callers hold the
+// fragment lock, so the writes of a key are queued in the order they are
+// applied.
+func (a *analyticsReplicator) send(record analyticsRecord) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	for _, t := range a.targets {
+		t.mtx.Lock()
+		if t.closed {
+			t.mtx.Unlock()
+			continue
+		}
+		if len(t.pending) >= maxPendingAnalyticsRecords {
+			// The replica fell behind, copy the DMaps again.
+			AnalyticsDroppedTotal.Increase(int64(len(t.pending)) + 1)
+			t.pending = nil
+			t.synced = false
+		} else {
+			t.pending = append(t.pending, record)
+		}
+		if !t.running {
+			t.running = true
+			a.s.wg.Add(1)
+			go a.drain(t)
+		}
+		t.mtx.Unlock()
+	}
+}
+
+func (a *analyticsReplicator) drain(t *analyticsTarget) {
+	defer a.s.wg.Done()
+
+	for {
+		t.mtx.Lock()
+		if t.closed || !a.s.isAlive() {
+			t.running = false
+			t.mtx.Unlock()
+			return
+		}
+		synced := t.synced
+		t.synced = true
+		if synced && len(t.pending) == 0 {
+			t.running = false
+			t.mtx.Unlock()
+			return
+		}
+		var batch []analyticsRecord
+		if synced {
+			n := len(t.pending)
+			if n > analyticsBatchSize {
+				n = analyticsBatchSize
+			}
+			batch = t.pending[:n]
+			t.pending = t.pending[n:]
+		}
+		t.mtx.Unlock()
+
+		var err error
+		if synced {
+			err = a.replicate(t.member, batch)
+		} else {
+			err = a.copyDMaps(t.member)
+		}
+		if err != nil {
+			a.s.log.V(3).Printf("[ERROR] Failed to replicate DMaps to analytics replica: %s: %v", t.member, err)
+			t.mtx.Lock()
+			AnalyticsDroppedTotal.Increase(int64(len(t.pending) + len(batch)))
+			t.pending = nil
+			t.synced = false
+			t.running = false
+			t.mtx.Unlock()
+			return
+		}
+	}
+}
+
+func (a *analyticsReplicator) replicate(member discovery.Member, batch []analyticsRecord) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	payload, err := msgpack.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	cmd := protocol.NewReplicate(payload).Command(a.s.ctx)
+	rc := a.s.client.Get(member.String())
+	if err = rc.Process(a.s.ctx, cmd); err != nil {
+		return protocol.ConvertError(err)
+	}
+	if err = protocol.ConvertError(cmd.Err()); err != nil {
+		return err
+	}
+	AnalyticsReplicatedTotal.Increase(int64(len(batch)))
+	return nil
+}
+
+// copyDMaps sends all entries of the primary partitions owned by this member
+// to the analytics replica.
+func (a *analyticsReplicator) copyDMaps(member discovery.Member) error {
+	for partID := uint64(0); partID < a.s.config.PartitionCount; partID++ {
+		part := a.s.primary.PartitionByID(partID)
+		if part.OwnerCount() == 0 || !part.Owner().CompareByID(a.s.rt.This()) {
+			continue
+		}
+
+		var err error
+		part.Map().Range(func(rawName, rawFragment interface{}) bool {
+			name := rawName.(string)
+			if !strings.HasPrefix(name, "dmap.") {
+				return true
+			}
+			f := rawFragment.(*fragment)
+
+			var batch []analyticsRecord
+			f.RLock()
+			f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+				batch = append(batch, analyticsRecord{
+					DMap:  strings.TrimPrefix(name, "dmap."),
+					HKey:  hkey,
+					Entry: e.Encode(),
+				})
+				return true
+			})
+			f.RUnlock()
+
+			for len(batch) > 0 && err == nil {
+				n := len(batch)
+				if n > analyticsBatchSize {
+					n = analyticsBatchSize
+				}
+				err = a.replicate(member, batch[:n])
+				batch = batch[n:]
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicateToAnalytics sends the entry to the analytics replicas. entry is
+// nil for a delete. It's called by the partition owner after a write.
+func (dm *DMap) replicateToAnalytics(hkey uint64, entry storage.Entry) {
+	if !dm.s.analytics.enabled() {
+		return
+	}
+	record := analyticsRecord{
+		DMap: dm.name,
+		HKey: hkey,
+	}
+	if entry != nil {
+		record.Entry = entry.Encode()
+	} else {
+		record.Timestamp = dm.s.clock.Now()
+	}
+	dm.s.analytics.send(record)
+}
+
+// analyticsStore keeps the copies of the DMaps on an analytics replica. They
+// are not in the partitions, so they are never moved by the balancer.
+type analyticsStore struct {
+	mtx       sync.RWMutex
+	fragments map[string]*fragment
+}
+
+func newAnalyticsStore() *analyticsStore {
+	return &analyticsStore{
+		fragments: make(map[string]*fragment),
+	}
+}
+
+func (a *analyticsStore) load(name string) (*fragment, bool) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	f, ok := a.fragments[name]
+	return f, ok
+}
+
+func (a *analyticsStore) loadOrCreate(dm *DMap) (*fragment, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	f, ok := a.fragments[dm.name]
+	if ok {
+		return f, nil
+	}
+	f, err := dm.newFragment()
+	if err != nil {
+		return nil, err
+	}
+	a.fragments[dm.name] = f
+	return f, nil
+}
+
+// destroy wipes out the copy of the DMap.
+func (a *analyticsStore) destroy(name string) error {
+	a.mtx.Lock()
+	f, ok := a.fragments[name]
+	delete(a.fragments, name)
+	a.mtx.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return f.Destroy()
+}
+
+// applyAnalyticsRecords applies the replicated writes on an analytics replica.
+// The newer delete wins if a key is written by different members while its
+// partition is moved, the writes are merged by the ConflictResolver of the
+// DMap.
+func (s *Service) applyAnalyticsRecords(records []analyticsRecord) error {
+	for _, record := range records {
+		dm, err := s.NewDMap(record.DMap)
+		if err != nil {
+			return err
+		}
+		f, err := s.analyticsStore.loadOrCreate(dm)
+		if err != nil {
+			return err
+		}
+
+		f.Lock()
+		var entry storage.Entry
+		timestamp := record.Timestamp
+		if record.Entry != nil {
+			entry = dm.engine.NewEntry()
+			entry.Decode(record.Entry)
+			timestamp = entry.Timestamp()
+		}
+		current, gerr := f.storage.Get(record.HKey)
+		switch {
+		case errors.Is(gerr, storage.ErrKeyNotFound):
+			if entry != nil {
+				err = f.storage.Put(record.HKey, entry)
+			}
+		case gerr != nil:
+			err = gerr
+		case entry == nil:
+			if current.Timestamp() <= timestamp {
+				err = f.storage.Delete(record.HKey)
+			}
+		default:
+			if winner := dm.resolveConflict(current, entry); winner != current {
+				err = f.storage.Put(record.HKey, winner)
+			}
+		}
+		f.Unlock()
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryAnalytics runs the query on the copy of the DMap on an analytics replica.
+func (dm *DMap) queryAnalytics(ctx context.Context, filter *Filter) ([]storage.Entry, error) {
+	f, ok := dm.s.analyticsStore.load(dm.name)
+	if !ok {
+		return nil, nil
+	}
+	return dm.queryOnFragment(ctx, f, filter)
+}
+
+// AnalyticsReplicas returns the analytics replicas in the cluster. The list
+// is rotated on every call, so the heavy reads are spread over the replicas.
+// The next ones are tried in order if the first one is not available.
+func (dm *DMap) AnalyticsReplicas() []discovery.Member {
+	replicas := dm.s.rt.AnalyticsMembers()
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	start := int(atomic.AddUint64(&dm.s.analyticsQueries, 1) % uint64(len(replicas)))
+	result := make([]discovery.Member, 0, len(replicas))
+	result = append(result, replicas[start:]...)
+	return append(result, replicas[:start]...)
+}
+
+// queryOnAnalyticsReplicas runs the query on one of the analytics replicas.
+// It returns false if there is no analytics replica or none of them is
+// available, the query runs on the partition owners then.
+func (dm *DMap) queryOnAnalyticsReplicas(ctx context.Context, expr string, filter *Filter, cursor uint64) ([]storage.Entry, uint64, bool, error) {
+	for _, member := range dm.AnalyticsReplicas() {
+		if member.CompareByID(dm.s.rt.This()) {
+			entries, next, err := dm.queryLocal(ctx, filter, cursor)
+			return entries, next, true, err
+		}
+
+		entries, next, err := dm.queryOnMember(ctx, member, expr, cursor)
+		if err == nil {
+			return entries, next, true, nil
+		}
+		if ctx.Err() != nil {
+			return nil, 0, true, err
+		}
+		dm.s.log.V(3).Printf("[ERROR] Failed to run the query on analytics replica: %s: %v", member, err)
+	}
+	return nil, 0, false, nil
+}
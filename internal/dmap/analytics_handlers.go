@@ -0,0 +1,42 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) replicateCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	replicateCmd, err := protocol.ParseReplicateCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	var records []analyticsRecord
+	if err = msgpack.Unmarshal(replicateCmd.Payload, &records); err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = s.applyAnalyticsRecords(records)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
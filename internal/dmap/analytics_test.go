@@ -0,0 +1,149 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_AnalyticsReplica(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := cluster.AddMember(nil).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := cluster.AddMember(nil).(*Service)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	put := func(i int) {
+		value, err := json.Marshal(map[string]interface{}{"id": i})
+		require.NoError(t, err)
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), value, nil))
+	}
+	// Written before the analytics replica joins, they are copied to the replica.
+	for i := 0; i < 10; i++ {
+		put(i)
+	}
+
+	c := testutil.NewConfig()
+	c.AnalyticsReplica = true
+	s3 := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	require.True(t, s3.rt.This().Analytics)
+
+	for i := 10; i < 20; i++ {
+		put(i)
+	}
+	_, err = dm1.Delete(ctx, testutil.ToKey(19))
+	require.NoError(t, err)
+
+	var expected []string
+	for i := 15; i < 19; i++ {
+		expected = append(expected, testutil.ToKey(i))
+	}
+	sort.Strings(expected)
+
+	err = testutil.TryWithInterval(50, 100*time.Millisecond, func() error {
+		dm3, err := s3.NewDMap("mydmap")
+		if err != nil {
+			return err
+		}
+		entries, err := dm3.queryAnalytics(ctx, mustParseFilter(t, "id >= 15"))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		for _, e := range entries {
+			keys = append(keys, e.Key())
+		}
+		sort.Strings(keys)
+		if fmt.Sprint(keys) != fmt.Sprint(expected) {
+			return fmt.Errorf("replica is not in sync: %v", keys)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The query runs on the analytics replica.
+	entries, err := dm1.Query(ctx, "id >= 15")
+	require.NoError(t, err)
+	require.Len(t, entries, len(expected))
+
+	// The copy of the DMap is scanned as a whole on the analytics replica.
+	dm3, err := s3.NewDMap("mydmap")
+	require.NoError(t, err)
+	keys, cursor, err := dm3.Scan(ctx, 0, 0, &ScanConfig{Count: 100})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), cursor)
+	require.Len(t, keys, 19)
+	require.Len(t, dm1.AnalyticsReplicas(), 1)
+
+	// The analytics replica never owns a partition.
+	for partID := uint64(0); partID < s3.config.PartitionCount; partID++ {
+		require.False(t, s3.primary.PartitionByID(partID).Owner().CompareByID(s3.rt.This()))
+		require.Equal(t, 0, s3.primary.PartitionByID(partID).Length())
+	}
+	require.Greater(t, AnalyticsReplicatedTotal.Read(), int64(0))
+}
+
+func mustParseFilter(t *testing.T, expr string) *Filter {
+	filter, err := ParseFilter(expr)
+	require.NoError(t, err)
+	return filter
+}
+
+func TestDMap_applyAnalyticsRecords_Delete(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	key := "mykey"
+	hkey := dm.HKey(key)
+	newRecord := func(timestamp int64) analyticsRecord {
+		e := dm.engine.NewEntry()
+		e.SetKey(key)
+		e.SetValue([]byte("value"))
+		e.SetTimestamp(timestamp)
+		return analyticsRecord{DMap: dm.name, HKey: hkey, Entry: e.Encode()}
+	}
+
+	require.NoError(t, s.applyAnalyticsRecords([]analyticsRecord{newRecord(20)}))
+
+	// An older delete, e.g. from the previous owner of the partition, is ignored.
+	err = s.applyAnalyticsRecords([]analyticsRecord{{DMap: dm.name, HKey: hkey, Timestamp: 10}})
+	require.NoError(t, err)
+	f, ok := s.analyticsStore.load(dm.name)
+	require.True(t, ok)
+	require.True(t, f.storage.Check(hkey))
+
+	err = s.applyAnalyticsRecords([]analyticsRecord{{DMap: dm.name, HKey: hkey, Timestamp: 30}})
+	require.NoError(t, err)
+	require.False(t, f.storage.Check(hkey))
+}
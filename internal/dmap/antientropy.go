@@ -0,0 +1,351 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/cespare/xxhash/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// AntiEntropyMismatchesTotal is the number of the backup fragments whose
+	// checksums don't match with the primary fragments.
+	AntiEntropyMismatchesTotal = stats.NewInt64Counter()
+
+	// AntiEntropyRepairsTotal is the number of the backup entries that are
+	// repaired by the anti-entropy worker.
+	AntiEntropyRepairsTotal = stats.NewInt64Counter()
+)
+
+// digestEntry denotes an entry in the digest of a backup fragment.
+type digestEntry struct {
+	Key       string
+	Timestamp int64
+}
+
+func entryChecksum(hkey uint64, timestamp int64) uint64 {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], hkey)
+	binary.BigEndian.PutUint64(buf[8:], uint64(timestamp))
+	return xxhash.Sum64(buf[:])
+}
+
+// fragmentChecksum returns the checksum of the entries in a fragment. The
+// checksums of the entries are summed, so it doesn't depend on the order of
+// the entries in the storage engine.
+func fragmentChecksum(f *fragment) uint64 {
+	f.Lock()
+	defer f.Unlock()
+
+	var checksum uint64
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		checksum += entryChecksum(hkey, e.Timestamp())
+		return true
+	})
+	return checksum
+}
+
+func fragmentDigest(f *fragment) map[uint64]digestEntry {
+	f.Lock()
+	defer f.Unlock()
+
+	digest := make(map[uint64]digestEntry)
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		digest[hkey] = digestEntry{
+			Key:       e.Key(),
+			Timestamp: e.Timestamp(),
+		}
+		return true
+	})
+	return digest
+}
+
+type backupRepair struct {
+	hkey      uint64
+	key       string
+	timestamp int64
+	entry     []byte
+	tags      []string
+}
+
+// compareWithDigest returns the entries that are missing on the backup owner,
+// the keys whose versions differ on the owners and the keys that are not in the
+// primary fragment anymore. The deletes carry the timestamps in the digest, the
+// backup owner keeps an entry if it has been updated after the digest is taken.
+func compareWithDigest(f *fragment, digest map[uint64]digestEntry) ([]backupRepair, []backupRepair, []backupRepair) {
+	f.Lock()
+	defer f.Unlock()
+
+	var puts, conflicts []backupRepair
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		d, ok := digest[hkey]
+		delete(digest, hkey)
+		if ok {
+			if d.Timestamp != e.Timestamp() {
+				conflicts = append(conflicts, backupRepair{hkey: hkey, key: e.Key()})
+			}
+			return true
+		}
+		puts = append(puts, backupRepair{
+			hkey:      hkey,
+			key:       e.Key(),
+			timestamp: e.Timestamp(),
+			entry:     e.Encode(),
+			tags:      f.tags.tags[hkey],
+		})
+		return true
+	})
+
+	var deletes []backupRepair
+	for hkey, d := range digest {
+		deletes = append(deletes, backupRepair{
+			hkey:      hkey,
+			key:       d.Key,
+			timestamp: d.Timestamp,
+		})
+	}
+	return puts, conflicts, deletes
+}
+
+// mergeWithBackup merges the version of the key on the backup owner into the
+// primary fragment with the ConflictResolver of the DMap. It returns the
+// merged entry and its tags if the backup owner needs it, nil otherwise.
+func (dm *DMap) mergeWithBackup(f *fragment, hkey uint64, received storage.Entry) (storage.Entry, []string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	current, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		// Deleted after the digest is compared, the next run repairs it.
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = dm.s.clock.Update(received.Timestamp()); err != nil {
+		return nil, nil, err
+	}
+	winner := dm.resolveConflict(current, received)
+	if winner != current {
+		if err = f.storage.Put(hkey, winner); err != nil {
+			return nil, nil, err
+		}
+	}
+	if winner == received {
+		return nil, nil, nil
+	}
+	return winner, f.tags.tags[hkey], nil
+}
+
+// repairConflict fetches the version of the key on the backup owner, merges
+// it with the primary one and sends the result to the backup owner. It
+// returns true if the key is repaired.
+func (dm *DMap) repairConflict(f *fragment, owner discovery.Member, r backupRepair) (bool, error) {
+	rc := dm.s.client.Get(owner.String())
+	getCmd := protocol.NewGetEntry(dm.name, r.key).SetReplica().Command(dm.s.ctx)
+	err := protocol.ConvertError(rc.Process(dm.s.ctx, getCmd))
+	if errors.Is(err, ErrKeyNotFound) {
+		// Deleted after the digest is taken, the next run repairs it.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	value, err := getCmd.Bytes()
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	received := dm.engine.NewEntry()
+	received.Decode(value)
+
+	winner, tags, err := dm.mergeWithBackup(f, r.hkey, received)
+	if err != nil || winner == nil {
+		return false, err
+	}
+	putCmd := protocol.NewPutEntry(dm.name, r.key, winner.Encode()).
+		SetEpoch(dm.epochOf(r.hkey)).
+		SetTimestamp(winner.Timestamp()).
+		SetTags(tags...).
+		Command(dm.s.ctx)
+	if err = rc.Process(dm.s.ctx, putCmd); err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	return true, protocol.ConvertError(putCmd.Err())
+}
+
+// repairBackup compares the primary fragment with the fragment on a backup
+// owner and repairs the drifted entries on the backup.
+func (dm *DMap) repairBackup(partID uint64, f *fragment, owner discovery.Member) (int, error) {
+	rc := dm.s.client.Get(owner.String())
+
+	checksumCmd := protocol.NewChecksum(partID, dm.name).Command(dm.s.ctx)
+	err := rc.Process(dm.s.ctx, checksumCmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	checksum, err := checksumCmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	if uint64(checksum) == fragmentChecksum(f) {
+		return 0, nil
+	}
+	AntiEntropyMismatchesTotal.Increase(1)
+
+	digestCmd := protocol.NewDigest(partID, dm.name).Command(dm.s.ctx)
+	err = rc.Process(dm.s.ctx, digestCmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	data, err := digestCmd.Bytes()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	digest := make(map[uint64]digestEntry)
+	if err = msgpack.Unmarshal(data, &digest); err != nil {
+		return 0, err
+	}
+
+	var repaired int
+	puts, conflicts, deletes := compareWithDigest(f, digest)
+	for _, r := range puts {
+		cmd := protocol.NewPutEntry(dm.name, r.key, r.entry).
+			SetEpoch(dm.epochOf(r.hkey)).
+			SetTimestamp(r.timestamp).
+			SetTags(r.tags...).
+			Command(dm.s.ctx)
+		err = rc.Process(dm.s.ctx, cmd)
+		if err != nil {
+			return repaired, protocol.ConvertError(err)
+		}
+		if err = protocol.ConvertError(cmd.Err()); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+	for _, r := range conflicts {
+		ok, err := dm.repairConflict(f, owner, r)
+		if err != nil {
+			return repaired, err
+		}
+		if ok {
+			repaired++
+		}
+	}
+	for _, r := range deletes {
+		cmd := protocol.NewDelEntry(dm.name, r.key).
+			SetReplica().
+			SetEpoch(dm.epochOf(r.hkey)).
+			SetTimestamp(r.timestamp).
+			Command(dm.s.ctx)
+		err = rc.Process(dm.s.ctx, cmd)
+		if err != nil {
+			return repaired, protocol.ConvertError(err)
+		}
+		if err = protocol.ConvertError(cmd.Err()); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+	return repaired, nil
+}
+
+// runAntiEntropy compares the primary fragments owned by this node with their
+// backups. A missing backup entry is copied from the primary fragment, and it's
+// deleted if the key is not in the primary fragment anymore. The versions that
+// differ on the owners are merged by the ConflictResolver of the DMap, both
+// owners get the merged one.
+//
+// The partitions in transfer are skipped, the previous owners may still hold
+// entries that are not moved to this node yet.
+func (s *Service) runAntiEntropy() {
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		if !s.isAlive() {
+			return
+		}
+
+		part := s.primary.PartitionByID(partID)
+		if part.OwnerCount() != 1 || !part.Owner().CompareByID(s.rt.This()) {
+			// Not owned by this node or the ownership is being transferred.
+			continue
+		}
+		owners := s.backup.PartitionByID(partID).Owners()
+		if len(owners) == 0 {
+			continue
+		}
+		if len(owners) > s.config.ReplicaCount-1 {
+			// A backup migration is pending.
+			continue
+		}
+
+		part.Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+			dmapName := strings.TrimPrefix(name.(string), "dmap.")
+			dm, err := s.getDMap(dmapName)
+			if err != nil {
+				dm, err = s.NewTempDMap(dmapName)
+				if err != nil {
+					s.log.V(3).Printf("[ERROR] Failed to create DMap: %s: %v", dmapName, err)
+					return true
+				}
+			}
+
+			f := tmp.(*fragment)
+			for _, owner := range owners {
+				repaired, err := dm.repairBackup(partID, f, owner)
+				AntiEntropyRepairsTotal.Increase(int64(repaired))
+				if err != nil {
+					s.log.V(3).Printf("[ERROR] Failed to repair backup of DMap: %s on %s, PartID: %d: %v",
+						dmapName, owner, partID, err)
+					continue
+				}
+				if repaired > 0 {
+					s.log.V(4).Printf("[INFO] Repaired %d entries of DMap: %s on %s, PartID: %d",
+						repaired, dmapName, owner, partID)
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (s *Service) antiEntropyWorker() {
+	defer s.wg.Done()
+	timer := time.NewTimer(s.config.DMaps.AntiEntropyInterval)
+	defer timer.Stop()
+
+	for {
+		timer.Reset(s.config.DMaps.AntiEntropyInterval)
+		select {
+		case <-timer.C:
+			s.runAntiEntropy()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) loadBackupFragment(partID uint64, name string) (*fragment, error) {
+	if partID >= s.config.PartitionCount {
+		return nil, fmt.Errorf("%w: invalid partition id: %d", protocol.ErrInvalidArgument, partID)
+	}
+	part := s.backup.PartitionByID(partID)
+	f, ok := part.Map().Load(s.fragmentName(name))
+	if !ok {
+		return nil, errFragmentNotFound
+	}
+	return f.(*fragment), nil
+}
+
+func (s *Service) checksumCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	checksumCmd, err := protocol.ParseChecksumCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	f, err := s.loadBackupFragment(checksumCmd.PartID, checksumCmd.DMap)
+	if errors.Is(err, errFragmentNotFound) {
+		conn.WriteInt64(0)
+		return
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt64(int64(fragmentChecksum(f)))
+}
+
+func (s *Service) digestCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	digestCmd, err := protocol.ParseDigestCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	digest := make(map[uint64]digestEntry)
+	f, err := s.loadBackupFragment(digestCmd.PartID, digestCmd.DMap)
+	if err == nil {
+		digest = fragmentDigest(f)
+	} else if !errors.Is(err, errFragmentNotFound) {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	data, err := msgpack.Marshal(digest)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteBulk(data)
+}
@@ -0,0 +1,243 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// backupEntryOf returns the backup entry of a key from the backup owner.
+func backupEntryOf(dms []*DMap, key string) (storage.Entry, *fragment, error) {
+	hkey := partitions.HKey("mydmap", key)
+	for _, dm := range dms {
+		part := dm.getPartitionByHKey(hkey, partitions.BACKUP)
+		if !dm.s.checkOwnership(part) {
+			continue
+		}
+		f, err := dm.loadOrCreateFragment(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.RLock()
+		e, err := f.storage.Get(hkey)
+		f.RUnlock()
+		return e, f, err
+	}
+	return nil, nil, errors.New("backup owner not found")
+}
+
+func TestDMap_AntiEntropy(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	dms := []*DMap{dm1, dm2}
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		for i := 0; i < 10; i++ {
+			if _, _, err := backupEntryOf(dms, testutil.ToKey(i)); err != nil {
+				return fmt.Errorf("backup of %s: %w", testutil.ToKey(i), err)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Simulate missed async backups.
+	for i := 0; i < 5; i++ {
+		_, f, err := backupEntryOf(dms, testutil.ToKey(i))
+		require.NoError(t, err)
+		f.Lock()
+		err = f.storage.Delete(partitions.HKey("mydmap", testutil.ToKey(i)))
+		f.Unlock()
+		require.NoError(t, err)
+	}
+
+	// Simulate a missed delete.
+	_, f, err := backupEntryOf(dms, "deleted-key")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+	f.Lock()
+	e := f.storage.NewEntry()
+	e.SetKey("deleted-key")
+	e.SetValue([]byte("value"))
+	e.SetTimestamp(time.Now().UnixNano())
+	err = f.storage.Put(partitions.HKey("mydmap", "deleted-key"), e)
+	f.Unlock()
+	require.NoError(t, err)
+
+	mismatches := AntiEntropyMismatchesTotal.Read()
+	repairs := AntiEntropyRepairsTotal.Read()
+
+	s1.runAntiEntropy()
+	s2.runAntiEntropy()
+
+	for i := 0; i < 10; i++ {
+		backup, _, err := backupEntryOf(dms, testutil.ToKey(i))
+		require.NoError(t, err)
+		primary, err := dm1.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, primary.Timestamp(), backup.Timestamp())
+	}
+	_, _, err = backupEntryOf(dms, "deleted-key")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+
+	require.Greater(t, AntiEntropyMismatchesTotal.Read(), mismatches)
+	require.Equal(t, int64(6), AntiEntropyRepairsTotal.Read()-repairs)
+
+	// The fragments are in sync, nothing to repair.
+	repairs = AntiEntropyRepairsTotal.Read()
+	s1.runAntiEntropy()
+	s2.runAntiEntropy()
+	require.Equal(t, repairs, AntiEntropyRepairsTotal.Read())
+}
+
+func TestDMap_AntiEntropy_Keep_Newer_Backup_Entry(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	dms := []*DMap{dm1, dm2}
+	_, f, err := backupEntryOf(dms, "mykey")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+
+	hkey := partitions.HKey("mydmap", "mykey")
+	digestTimestamp := time.Now().UnixNano()
+	f.Lock()
+	e := f.storage.NewEntry()
+	e.SetKey("mykey")
+	e.SetValue([]byte("value"))
+	// Updated after the digest is taken.
+	e.SetTimestamp(digestTimestamp + 1)
+	err = f.storage.Put(hkey, e)
+	f.Unlock()
+	require.NoError(t, err)
+
+	for _, dm := range dms {
+		err = dm.deleteFromFragmentIfNotNewer("mykey", partitions.BACKUP, digestTimestamp)
+		require.NoError(t, err)
+	}
+	_, _, err = backupEntryOf(dms, "mykey")
+	require.NoError(t, err)
+
+	for _, dm := range dms {
+		err = dm.deleteFromFragmentIfNotNewer("mykey", partitions.BACKUP, digestTimestamp+1)
+		require.NoError(t, err)
+	}
+	_, _, err = backupEntryOf(dms, "mykey")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+}
+
+func TestDMap_AntiEntropy_Skip_Partition_In_Transfer(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	dms := []*DMap{dm1, dm2}
+	_, f, err := backupEntryOf(dms, "mykey")
+	require.ErrorIs(t, err, storage.ErrKeyNotFound)
+
+	// An entry on the backup owner that is not moved to the primary owner yet.
+	hkey := partitions.HKey("mydmap", "mykey")
+	f.Lock()
+	e := f.storage.NewEntry()
+	e.SetKey("mykey")
+	e.SetValue([]byte("value"))
+	e.SetTimestamp(time.Now().UnixNano())
+	err = f.storage.Put(hkey, e)
+	f.Unlock()
+	require.NoError(t, err)
+
+	// Simulate a pending primary migration.
+	var owner *Service
+	for _, s := range []*Service{s1, s2} {
+		part := s.primary.PartitionByHKey(hkey)
+		if part.Owner().CompareByID(s.rt.This()) {
+			owner = s
+		}
+	}
+	require.NotNil(t, owner)
+	part := owner.primary.PartitionByHKey(hkey)
+	dm, err := owner.getDMap("mydmap")
+	require.NoError(t, err)
+	_, err = dm.loadOrCreateFragment(part)
+	require.NoError(t, err)
+	owners := part.Owners()
+	part.SetOwners(append([]discovery.Member{owner.rt.Discovery().GetMembers()[0]}, owners...))
+	defer part.SetOwners(owners)
+
+	s1.runAntiEntropy()
+	s2.runAntiEntropy()
+
+	_, _, err = backupEntryOf(dms, "mykey")
+	require.NoError(t, err)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/buraksezer/olric/events"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/pkg/neterrors"
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/tidwall/redcon"
@@ -33,9 +34,19 @@ type fragmentPack struct {
 	Kind    partitions.Kind
 	Name    string
 	Payload []byte
+	// Tags is the tags of the entries in Payload, by hkey.
+	Tags map[uint64][]string
+	// ChangeLog is the change log of a primary partition. It's sent with the
+	// first table of the partition.
+	ChangeLog *changelogPack
 }
 
 func (dm *DMap) fragmentMergeFunction(f *fragment, hkey uint64, entry storage.Entry) error {
+	if err := dm.s.clock.Update(entry.Timestamp()); err != nil {
+		// The entry is merged anyway, rejecting it would lose the key.
+		dm.s.log.V(3).Printf("[ERROR] Failed to update the clock with key: %s on DMap: %s: %v", entry.Key(), dm.name, err)
+	}
+
 	current, err := f.storage.Get(hkey)
 	if errors.Is(err, storage.ErrKeyNotFound) {
 		return f.storage.Put(hkey, entry)
@@ -44,9 +55,7 @@ func (dm *DMap) fragmentMergeFunction(f *fragment, hkey uint64, entry storage.En
 		return err
 	}
 
-	versions := []*version{{entry: current}, {entry: entry}}
-	versions = dm.sortVersions(versions)
-	winner := versions[0].entry
+	winner := dm.resolveConflict(current, entry)
 	if winner == current {
 		// No need to insert the winner
 		return nil
@@ -54,19 +63,45 @@ func (dm *DMap) fragmentMergeFunction(f *fragment, hkey uint64, entry storage.En
 	return f.storage.Put(hkey, winner)
 }
 
-func (dm *DMap) mergeFragments(part *partitions.Partition, fp *fragmentPack) error {
+// mergeFragments merges the received fragment into the local one and returns
+// the number of the received entries.
+func (dm *DMap) mergeFragments(part *partitions.Partition, fp *fragmentPack) (int, error) {
 	f, err := dm.loadOrCreateFragment(part)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Acquire fragment's lock. No one should work on it.
 	f.Lock()
 	defer f.Unlock()
 
-	return f.storage.Import(fp.Payload, func(hkey uint64, entry storage.Entry) error {
-		return dm.fragmentMergeFunction(f, hkey, entry)
+	var count int
+	err = f.storage.Import(fp.Payload, func(hkey uint64, entry storage.Entry) error {
+		count++
+		if err := dm.fragmentMergeFunction(f, hkey, entry); err != nil {
+			return err
+		}
+		if len(fp.Tags) == 0 && len(f.tags.tags) == 0 {
+			return nil
+		}
+		// The tags go with the version that is kept.
+		current, err := f.storage.Get(hkey)
+		if err != nil {
+			return err
+		}
+		if current.Timestamp() == entry.Timestamp() {
+			f.tags.set(hkey, entry.Key(), fp.Tags[hkey])
+		}
+		return nil
 	})
+	if err != nil {
+		return count, err
+	}
+
+	if fp.ChangeLog != nil && part.Kind() == partitions.PRIMARY && dm.config().changeLogSize != 0 {
+		dm.s.changelogOf(dm.name, part.ID()).adopt(fp.ChangeLog, dm.config().changeLogSize)
+	}
+	return count, nil
 }
 
 func (s *Service) checkOwnership(part *partitions.Partition) bool {
@@ -133,7 +168,16 @@ func (s *Service) moveFragmentCommandHandler(conn redcon.Conn, cmd redcon.Comman
 		return
 	}
 
-	err = dm.mergeFragments(part, fp)
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	release, err := s.admitImport(ctx, len(fp.Payload), false)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	count, err := dm.mergeFragments(part, fp)
+	release(count)
 	if err != nil {
 		s.log.V(2).Printf("[ERROR] Failed to merge Received DMap (kind: %s): %s on PartID: %d: %v",
 			fp.Kind, fp.Name, fp.PartID, err)
@@ -141,7 +185,17 @@ func (s *Service) moveFragmentCommandHandler(conn redcon.Conn, cmd redcon.Comman
 		return
 	}
 
-	if s.config.EnableClusterEventsChannel {
+	if s.hasKeyMigrationListeners() {
+		keys, err := exportedKeys(dm.engine, fp.Payload)
+		if err != nil {
+			s.log.V(3).Printf("[ERROR] Failed to read the keys of received DMap: %s on PartID: %d: %v",
+				fp.Name, fp.PartID, err)
+		} else {
+			s.notifyKeysArrived(part, fp.Name, keys)
+		}
+	}
+
+	if s.config.EnableClusterEventsChannel || s.eventBus.HasSubscribers() {
 		e := &events.FragmentReceivedEvent{
 			Kind:          events.KindFragmentReceivedEvent,
 			Source:        s.rt.This().String(),
@@ -152,8 +206,11 @@ func (s *Service) moveFragmentCommandHandler(conn redcon.Conn, cmd redcon.Comman
 			IsBackup:      part.Kind() == partitions.BACKUP,
 			Timestamp:     time.Now().UnixNano(),
 		}
-		s.wg.Add(1)
-		go s.publishEvent(e)
+		s.eventBus.Publish(e)
+		if s.config.EnableClusterEventsChannel {
+			s.wg.Add(1)
+			go s.publishEvent(e)
+		}
 	}
 
 	conn.WriteString(protocol.StatusOK)
@@ -17,6 +17,7 @@ package dmap
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"testing"
 	"time"
@@ -216,3 +217,71 @@ L:
 		require.NotEqual(t, part.Owner().ID, db1.rt.This().ID)
 	}
 }
+
+func TestDMap_Balancer_RecreateBackups(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	db1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	db2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := db1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	_, err = db2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var totalKeys = 100
+	for i := 0; i < totalKeys; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	countKeys := func(kind partitions.Kind) int {
+		var total int
+		for _, s := range []*Service{db1, db2} {
+			for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+				if kind == partitions.PRIMARY {
+					total += s.primary.PartitionByID(partID).Length()
+				} else {
+					total += s.backup.PartitionByID(partID).Length()
+				}
+			}
+		}
+		return total
+	}
+	require.Equal(t, totalKeys, countKeys(partitions.BACKUP))
+
+	// Lose all the backups.
+	for _, s := range []*Service{db1, db2} {
+		for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+			part := s.backup.PartitionByID(partID)
+			part.Map().Range(func(name, _ interface{}) bool {
+				part.Map().Delete(name)
+				return true
+			})
+		}
+	}
+	require.Equal(t, 0, countKeys(partitions.BACKUP))
+
+	for _, s := range []*Service{db1, db2} {
+		cmd := protocol.NewRecreateBackups().Command(ctx)
+		rc := db1.client.Get(s.rt.This().String())
+		require.NoError(t, rc.Process(ctx, cmd))
+		require.NoError(t, cmd.Err())
+	}
+
+	err = testutil.TryWithInterval(50, 100*time.Millisecond, func() error {
+		if count := countKeys(partitions.BACKUP); count != totalKeys {
+			return fmt.Errorf("expected %d backups, got: %d", totalKeys, count)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, totalKeys, countKeys(partitions.PRIMARY))
+}
@@ -0,0 +1,292 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/events"
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// ErrChangeLogDisabled is returned when a DMap has no change log configured.
+	ErrChangeLogDisabled = errors.New("change log is disabled")
+
+	// ErrSequenceTooOld is returned when the requested sequence has already
+	// been dropped from the change log.
+	ErrSequenceTooOld = errors.New("sequence is no longer in the change log")
+)
+
+// MutationKind denotes the type of the change recorded in the change log.
+type MutationKind string
+
+const (
+	MutationPut    MutationKind = "put"
+	MutationExpire MutationKind = "expire"
+	MutationDelete MutationKind = "delete"
+)
+
+// Mutation is a write applied on the partition owner. Sequence numbers are
+// assigned per partition, they are strictly increasing per partition of a
+// DMap and the change log of a partition is moved with it, so a sequence stays
+// valid after the partition changes its owner.
+type Mutation struct {
+	PartID    uint64       `msgpack:"part_id"`
+	Sequence  uint64       `msgpack:"sequence"`
+	Kind      MutationKind `msgpack:"kind"`
+	Key       string       `msgpack:"key"`
+	Value     []byte       `msgpack:"value"`
+	TTL       int64        `msgpack:"ttl"`
+	Timestamp int64        `msgpack:"timestamp"`
+
+	// codec is the ID of the codec that Value is encoded with. The change log
+	// keeps the decoded values.
+	codec uint8
+}
+
+// Encode encodes the mutation with msgpack.
+func (m *Mutation) Encode() ([]byte, error) {
+	return msgpack.Marshal(m)
+}
+
+// Decode decodes a mutation that is encoded by Encode.
+func (m *Mutation) Decode(data []byte) error {
+	return msgpack.Unmarshal(data, m)
+}
+
+// changelog keeps the latest mutations of a partition, the oldest one first.
+// Mutations are consecutive, so the position of a sequence can be calculated
+// directly.
+type changelog struct {
+	mtx       sync.RWMutex
+	sequence  uint64
+	mutations []Mutation
+}
+
+// append records the mutation and drops the oldest ones beyond size. The size
+// is given by every call, so a reloaded configuration is applied.
+func (c *changelog) append(m Mutation, size int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.sequence++
+	m.Sequence = c.sequence
+	if m.Timestamp == 0 {
+		m.Timestamp = time.Now().UnixNano()
+	}
+	c.mutations = append(c.mutations, m)
+	if len(c.mutations) > size {
+		c.mutations = c.mutations[len(c.mutations)-size:]
+	}
+}
+
+// since returns at most count mutations that come after the given sequence.
+// It returns ErrSequenceTooOld if the mutations after the sequence have been
+// dropped, or the sequence is not known, e.g. the change log is lost with a
+// dead partition owner.
+func (c *changelog) since(sequence uint64, count int) ([]Mutation, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	oldest := c.sequence - uint64(len(c.mutations)) + 1
+	if sequence+1 < oldest || sequence > c.sequence {
+		return nil, ErrSequenceTooOld
+	}
+
+	var result []Mutation
+	for seq := sequence + 1; seq <= c.sequence && len(result) < count; seq++ {
+		result = append(result, c.mutations[seq-oldest])
+	}
+	return result, nil
+}
+
+// snapshot returns a copy of the change log to move it with the partition.
+func (c *changelog) snapshot() *changelogPack {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return &changelogPack{
+		Sequence:  c.sequence,
+		Mutations: append([]Mutation(nil), c.mutations...),
+	}
+}
+
+// adopt takes over the change log of the previous partition owner. The
+// mutations that are recorded on this member before the change log arrives
+// are numbered after the received ones. A change log that is not newer than
+// this one is ignored, it may be sent again.
+func (c *changelog) adopt(cp *changelogPack, size int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if cp.Sequence <= c.sequence {
+		return
+	}
+	local := c.mutations
+	c.sequence = cp.Sequence
+	c.mutations = append([]Mutation(nil), cp.Mutations...)
+	for _, m := range local {
+		c.sequence++
+		m.Sequence = c.sequence
+		c.mutations = append(c.mutations, m)
+	}
+	if len(c.mutations) > size {
+		c.mutations = c.mutations[len(c.mutations)-size:]
+	}
+}
+
+// changelogPack is the change log of a partition that is sent with its
+// primary copy.
+type changelogPack struct {
+	Sequence  uint64
+	Mutations []Mutation
+}
+
+type changelogKey struct {
+	dmap   string
+	partID uint64
+}
+
+// changelogOf returns the change log of a partition of the DMap and creates
+// it if required.
+func (s *Service) changelogOf(name string, partID uint64) *changelog {
+	s.changelogMtx.Lock()
+	defer s.changelogMtx.Unlock()
+
+	key := changelogKey{dmap: name, partID: partID}
+	cl, ok := s.changelogs[key]
+	if !ok {
+		cl = &changelog{}
+		s.changelogs[key] = cl
+	}
+	return cl
+}
+
+// changelogSnapshot returns a copy of the change log of a partition of the
+// DMap to move it with the partition. It returns nil if there is no change log.
+func (s *Service) changelogSnapshot(name string, partID uint64) *changelogPack {
+	s.changelogMtx.Lock()
+	cl, ok := s.changelogs[changelogKey{dmap: name, partID: partID}]
+	s.changelogMtx.Unlock()
+	if !ok {
+		return nil
+	}
+	return cl.snapshot()
+}
+
+// dropChangelog forgets the change log of a partition of the DMap after it is
+// moved to the new partition owner.
+func (s *Service) dropChangelog(name string, partID uint64) {
+	s.changelogMtx.Lock()
+	defer s.changelogMtx.Unlock()
+
+	delete(s.changelogs, changelogKey{dmap: name, partID: partID})
+}
+
+// recordMutation appends the mutation to the change log, if change data
+// capture is enabled for this DMap, and publishes it on the local event bus.
+// Callers hold the fragment lock, so the mutations of a key are recorded in
+// the order they are applied.
+func (dm *DMap) recordMutation(m Mutation) {
+	changelogEnabled := dm.config() != nil && dm.config().changeLogSize != 0
+	if !changelogEnabled && !dm.s.eventBus.HasSubscribers() {
+		return
+	}
+	if m.codec != codec.None {
+		value, err := codec.Decode(m.codec, m.Value)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to decode the value of key: %s on DMap: %s: %v", m.Key, dm.name, err)
+			return
+		}
+		m.Value, m.codec = value, codec.None
+	}
+	if m.Value != nil {
+		value, err := dm.reverseValue(m.Key, m.Value)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to reverse the value of key: %s on DMap: %s: %v", m.Key, dm.name, err)
+			return
+		}
+		m.Value = value
+	}
+	if m.Timestamp == 0 {
+		m.Timestamp = dm.s.clock.Now()
+	}
+	m.PartID = dm.s.primary.PartitionByHKey(dm.HKey(m.Key)).ID()
+	dm.publishEntryUpdatedEvent(m)
+	if changelogEnabled {
+		dm.s.changelogOf(dm.name, m.PartID).append(m, dm.config().changeLogSize)
+	}
+}
+
+func (dm *DMap) publishEntryUpdatedEvent(m Mutation) {
+	if !dm.s.eventBus.HasSubscribers() {
+		return
+	}
+	dm.s.eventBus.Publish(&events.EntryUpdatedEvent{
+		Kind:          events.KindEntryUpdatedEvent,
+		Source:        dm.s.rt.This().String(),
+		DataStructure: "dmap",
+		Identifier:    dm.name,
+		PartitionID:   m.PartID,
+		Operation:     string(m.Kind),
+		Key:           m.Key,
+		Value:         m.Value,
+		TTL:           m.TTL,
+		Timestamp:     m.Timestamp,
+	})
+}
+
+// Changes returns at most count mutations of the partitions that are owned by
+// this member, after the given sequences by partition ID. The other partitions
+// are skipped, their owners return their mutations. A partition that has a
+// previous owner in the routing table is skipped too, until its change log
+// arrives with its entries and the routing table drops the previous owner.
+func (dm *DMap) Changes(sequences map[uint64]uint64, count int) ([]Mutation, error) {
+	if dm.config() == nil || dm.config().changeLogSize == 0 {
+		return nil, ErrChangeLogDisabled
+	}
+
+	partIDs := make([]uint64, 0, len(sequences))
+	for partID := range sequences {
+		partIDs = append(partIDs, partID)
+	}
+	sort.Slice(partIDs, func(i, j int) bool { return partIDs[i] < partIDs[j] })
+
+	var result []Mutation
+	for _, partID := range partIDs {
+		if len(result) >= count {
+			break
+		}
+		if partID >= dm.s.config.PartitionCount {
+			continue
+		}
+		part := dm.s.primary.PartitionByID(partID)
+		if part.OwnerCount() != 1 || !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		mutations, err := dm.s.changelogOf(dm.name, partID).since(sequences[partID], count-len(result))
+		if err != nil {
+			return nil, fmt.Errorf("%w: partition: %d", err, partID)
+		}
+		result = append(result, mutations...)
+	}
+	return result, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) changesCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	changesCmd, err := protocol.ParseChangesCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getDMap(changesCmd.DMap)
+	if errors.Is(err, ErrDMapNotFound) {
+		// Nothing has been written to this DMap on this node yet, but the
+		// configuration still tells whether the change log is enabled.
+		dm, err = s.NewTempDMap(changesCmd.DMap)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	mutations, err := dm.Changes(changesCmd.Sequences, changesCmd.Count)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	var items [][]byte
+	for _, m := range mutations {
+		data, err := m.Encode()
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		items = append(items, data)
+	}
+
+	conn.WriteArray(len(items))
+	for _, data := range items {
+		conn.WriteBulk(data)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Changelog_Since(t *testing.T) {
+	cl := &changelog{}
+	for i := 0; i < 6; i++ {
+		cl.append(Mutation{Kind: MutationPut, Key: "key"}, 4)
+	}
+
+	mutations, err := cl.since(2, 10)
+	require.NoError(t, err)
+	require.Len(t, mutations, 4)
+	for i, m := range mutations {
+		require.Equal(t, uint64(i+3), m.Sequence)
+	}
+
+	mutations, err = cl.since(4, 1)
+	require.NoError(t, err)
+	require.Len(t, mutations, 1)
+	require.Equal(t, uint64(5), mutations[0].Sequence)
+
+	mutations, err = cl.since(6, 10)
+	require.NoError(t, err)
+	require.Len(t, mutations, 0)
+
+	_, err = cl.since(1, 10)
+	require.ErrorIs(t, err, ErrSequenceTooOld)
+
+	// Unknown sequence, e.g. the change log is lost with its owner.
+	_, err = cl.since(7, 10)
+	require.ErrorIs(t, err, ErrSequenceTooOld)
+}
+
+func TestDMap_Changelog_Resize(t *testing.T) {
+	cl := &changelog{}
+	for i := 0; i < 4; i++ {
+		cl.append(Mutation{Kind: MutationPut, Key: "key"}, 4)
+	}
+	cl.append(Mutation{Kind: MutationPut, Key: "key"}, 2)
+
+	_, err := cl.since(2, 10)
+	require.ErrorIs(t, err, ErrSequenceTooOld)
+
+	mutations, err := cl.since(3, 10)
+	require.NoError(t, err)
+	require.Len(t, mutations, 2)
+	require.Equal(t, uint64(4), mutations[0].Sequence)
+	require.Equal(t, uint64(5), mutations[1].Sequence)
+}
+
+func TestDMap_Changelog_Adopt(t *testing.T) {
+	previous := &changelog{}
+	for i := 0; i < 10; i++ {
+		previous.append(Mutation{Kind: MutationPut, Key: "old"}, 3)
+	}
+
+	cl := &changelog{}
+	cl.append(Mutation{Kind: MutationPut, Key: "new"}, 16)
+	cl.adopt(previous.snapshot(), 16)
+
+	mutations, err := cl.since(7, 10)
+	require.NoError(t, err)
+	require.Len(t, mutations, 4)
+	for i, m := range mutations {
+		require.Equal(t, uint64(i+8), m.Sequence)
+	}
+	require.Equal(t, "old", mutations[2].Key)
+	require.Equal(t, "new", mutations[3].Key)
+
+	// The same change log is ignored if it's sent again.
+	cl.adopt(previous.snapshot(), 16)
+	mutations, err = cl.since(7, 10)
+	require.NoError(t, err)
+	require.Len(t, mutations, 4)
+}
+
+func TestDMap_Changelog_MovedWithPartition(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c1 := testutil.NewConfig()
+	c1.DMaps.ChangeLogSize = 1024
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+
+	cursors := make(map[uint64]uint64)
+	for partID := uint64(0); partID < s1.config.PartitionCount; partID++ {
+		cursors[partID] = 0
+	}
+	before, err := dm1.Changes(cursors, 1000)
+	require.NoError(t, err)
+	require.Len(t, before, 100)
+
+	c2 := testutil.NewConfig()
+	c2.DMaps.ChangeLogSize = 1024
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Every mutation is read from the current owner of its partition with
+	// the sequence that is assigned by the previous owner.
+	require.Eventually(t, func() bool {
+		// Drops the previous owners of the moved partitions.
+		s1.rt.UpdateEagerly()
+
+		var after []Mutation
+		for _, dm := range []*DMap{dm1, dm2} {
+			mutations, err := dm.Changes(cursors, 1000)
+			if err != nil {
+				return false
+			}
+			after = append(after, mutations...)
+		}
+		if len(after) != len(before) {
+			return false
+		}
+		sequences := make(map[string]uint64)
+		for _, m := range before {
+			sequences[m.Key] = m.Sequence
+		}
+		for _, m := range after {
+			if sequences[m.Key] != m.Sequence {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestDMap_Changelog_ReadRepair(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.ReadRepair = true
+	c.DMaps.ChangeLogSize = 1024
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue", nil))
+
+	hkey := dm.HKey("mykey")
+	e := s.newEnv(ctx, 0)
+	e.hkey = hkey
+	e.fragment, err = dm.loadOrCreateFragment(dm.getPartitionByHKey(hkey, partitions.PRIMARY))
+	require.NoError(t, err)
+	entry, err := e.fragment.storage.Get(hkey)
+	require.NoError(t, err)
+	e.repair = true
+	require.NoError(t, dm.putEntryOnFragment(e, entry))
+
+	partID := dm.getPartitionByHKey(hkey, partitions.PRIMARY).ID()
+	mutations, err := dm.Changes(map[uint64]uint64{partID: 0}, 10)
+	require.NoError(t, err)
+	require.Len(t, mutations, 1)
+}
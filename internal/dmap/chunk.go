@@ -0,0 +1,325 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// ErrValueTooLarge is returned if a value exceeds MaxValueSize of the DMap.
+	ErrValueTooLarge = errors.New("value too large")
+
+	// ErrChunkedValueOption is returned if a value that has to be split into
+	// chunks is written with an option that cannot be applied to its chunks.
+	ErrChunkedValueOption = errors.New("NX, XX and SLIDING are not supported for chunked values")
+)
+
+// chunkKeySeparator separates the key of a chunked value from the index of
+// its chunks.
+const chunkKeySeparator = "\x00chunk\x00"
+
+// chunkManifest is stored in place of a value that is split into chunks. The
+// entry of the manifest is marked with codec.Chunked.
+type chunkManifest struct {
+	Size       int    `msgpack:"size"`
+	Chunks     int    `msgpack:"chunks"`
+	Generation uint64 `msgpack:"generation"`
+}
+
+// chunkKey returns the key of the i-th chunk of a value. Every write of a
+// chunked value has a new generation, so it never overwrites the chunks of the
+// previous value that are still referenced by its manifest. The manifests
+// without a generation use the keys without it.
+func chunkKey(key string, generation uint64, i int) string {
+	if generation == 0 {
+		return key + chunkKeySeparator + strconv.Itoa(i)
+	}
+	return key + chunkKeySeparator + strconv.FormatUint(generation, 16) + "." + strconv.Itoa(i)
+}
+
+// newChunkGeneration returns a random, non-zero generation for the chunks of
+// a write.
+func newChunkGeneration() (uint64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	generation := binary.BigEndian.Uint64(buf[:])
+	if generation == 0 {
+		generation = 1
+	}
+	return generation, nil
+}
+
+// parentKey returns the key of the chunked value that the chunk belongs to.
+// The chunk keys are validated against the key of their value.
+func parentKey(key string) string {
+	if i := strings.Index(key, chunkKeySeparator); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+func isChunkKey(key string) bool {
+	return strings.Contains(key, chunkKeySeparator)
+}
+
+// checkValueSize rejects the value if it exceeds MaxValueSize of the DMap.
+func (dm *DMap) checkValueSize(e *env) error {
+	if dm.config() == nil || dm.config().maxValueSize == 0 {
+		return nil
+	}
+	if e.putConfig.OnlyUpdateTTL || e.putConfig.Chunked {
+		return nil
+	}
+	if len(e.value) > dm.config().maxValueSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrValueTooLarge, len(e.value), dm.config().maxValueSize)
+	}
+	return nil
+}
+
+func (dm *DMap) chunkingEnabled(e *env) bool {
+	return dm.config() != nil && dm.config().chunkSize > 0 &&
+		!e.putConfig.OnlyUpdateTTL && !e.putConfig.Chunked && !isChunkKey(e.key)
+}
+
+// chunkManifestOf returns the manifest of the current value of the key, nil
+// if it's not chunked.
+func (dm *DMap) chunkManifestOf(ctx context.Context, key string) (*chunkManifest, error) {
+	e, err := dm.getEntry(ctx, key, false)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Codec() != codec.Chunked {
+		return nil, nil
+	}
+	var m chunkManifest
+	if err = msgpack.Unmarshal(e.Value(), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// chunkCount returns the number of the chunks of the current value of the
+// key, zero if it's not chunked.
+func (dm *DMap) chunkCount(ctx context.Context, key string) (int, error) {
+	m, err := dm.chunkManifestOf(ctx, key)
+	if err != nil || m == nil {
+		return 0, err
+	}
+	return m.Chunks, nil
+}
+
+// chunkExpiry returns the absolute expiry of a chunked value as PXAT. The
+// chunks and the manifest are written at different times, a relative TTL
+// would make the chunks expire before the manifest.
+func (dm *DMap) chunkExpiry(pc *PutConfig) (time.Duration, bool) {
+	var ttl time.Duration
+	switch {
+	case pc.HasEXAT:
+		return pc.EXAT, true
+	case pc.HasPXAT:
+		return pc.PXAT, true
+	case pc.HasEX:
+		ttl = pc.EX
+	case pc.HasPX:
+		ttl = pc.PX
+	case dm.config().ttlDuration > 0:
+		ttl = dm.config().ttlDuration
+	default:
+		return 0, false
+	}
+	return time.Duration(time.Now().Add(ttl).UnixNano()), true
+}
+
+// putChunked splits the value into chunks if it's larger than ChunkSize. The
+// chunks are written under a new generation before the manifest, so the
+// readers don't see an incomplete value, and the readers of the previous value
+// can still read its chunks. The chunks of the previous value are deleted
+// after the manifest is written.
+func (dm *DMap) putChunked(e *env) error {
+	if err := dm.checkWrite(e.key); err != nil {
+		return err
+	}
+	if err := dm.checkValueSize(e); err != nil {
+		return err
+	}
+
+	previous, err := dm.chunkManifestOf(e.ctx, e.key)
+	if err != nil {
+		return err
+	}
+
+	size := dm.config().chunkSize
+	var chunks int
+	var generation uint64
+	if len(e.value) > size {
+		pc := e.putConfig
+		if pc.HasNX || pc.HasXX || pc.HasSliding {
+			return ErrChunkedValueOption
+		}
+		if generation, err = newChunkGeneration(); err != nil {
+			return err
+		}
+
+		// The chunks don't have a jitter, they never expire before the
+		// manifest.
+		pxat, hasPXAT := dm.chunkExpiry(pc)
+		chunks = (len(e.value) + size - 1) / size
+		for i := 0; i < chunks; i++ {
+			end := (i + 1) * size
+			if end > len(e.value) {
+				end = len(e.value)
+			}
+			ce := dm.s.newEnv(e.ctx, e.timestamp)
+			ce.putConfig = &PutConfig{
+				HasPXAT:      hasPXAT,
+				PXAT:         pxat,
+				HasTimestamp: pc.HasTimestamp,
+				Timestamp:    pc.Timestamp,
+			}
+			ce.dmap = e.dmap
+			ce.key = chunkKey(e.key, generation, i)
+			ce.value = e.value[i*size : end]
+			if err = dm.put(ce); err != nil {
+				dm.deleteChunks(e.ctx, e.key, generation, 0, i)
+				return err
+			}
+		}
+
+		manifest, err := msgpack.Marshal(chunkManifest{
+			Size:       len(e.value),
+			Chunks:     chunks,
+			Generation: generation,
+		})
+		if err != nil {
+			dm.deleteChunks(e.ctx, e.key, generation, 0, chunks)
+			return err
+		}
+		mc := *pc
+		mc.Chunked = true
+		mc.HasEX, mc.HasPX, mc.HasEXAT = false, false, false
+		mc.HasPXAT, mc.PXAT = hasPXAT, pxat
+		e.putConfig = &mc
+		e.value = manifest
+	}
+
+	if err = dm.put(e); err != nil {
+		dm.deleteChunks(e.ctx, e.key, generation, 0, chunks)
+		return err
+	}
+	if previous != nil {
+		dm.deleteChunks(e.ctx, e.key, previous.Generation, 0, previous.Chunks)
+	}
+	return nil
+}
+
+// deleteChunks deletes the chunks of the key between from and to. The
+// failures are logged, the chunks expire with their TTL anyway.
+func (dm *DMap) deleteChunks(ctx context.Context, key string, generation uint64, from, to int) {
+	for i := from; i < to; i++ {
+		if _, err := dm.deleteKeys(ctx, chunkKey(key, generation, i)); err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to delete chunk: %d of key: %s on DMap: %s: %v", i, key, dm.name, err)
+		}
+	}
+}
+
+// expireChunks updates the expiry of the chunks of the key, if its value is
+// chunked. It's called after the expiry of the manifest is updated, so the
+// chunks never expire before the manifest.
+func (dm *DMap) expireChunks(ctx context.Context, key string, timeout time.Duration) error {
+	if dm.config() == nil || dm.config().chunkSize == 0 || isChunkKey(key) {
+		return nil
+	}
+	m, err := dm.chunkManifestOf(ctx, key)
+	if err != nil || m == nil {
+		return err
+	}
+	for i := 0; i < m.Chunks; i++ {
+		if err = dm.expireKey(ctx, chunkKey(key, m.Generation, i), timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assembleChunks reads the chunks of a chunked value and replaces the
+// manifest with the value. It returns ErrKeyNotFound if a chunk is missing.
+func (dm *DMap) assembleChunks(ctx context.Context, e *Entry, err error) (*Entry, error) {
+	if err != nil || e.Codec() != codec.Chunked {
+		return e, err
+	}
+
+	var m chunkManifest
+	if err = msgpack.Unmarshal(e.Value(), &m); err != nil {
+		return nil, err
+	}
+	value := make([]byte, 0, m.Size)
+	for i := 0; i < m.Chunks; i++ {
+		chunk, err := dm.getEntry(ctx, chunkKey(e.Key(), m.Generation, i), false)
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, chunk.Value()...)
+	}
+	e.SetValue(value)
+	e.SetCodec(codec.None)
+	return e, nil
+}
+
+// deleteKeysWithChunks deletes the keys and the chunks of their values, if
+// the DMap splits the large values into chunks.
+func (dm *DMap) deleteKeysWithChunks(ctx context.Context, keys ...string) (int, error) {
+	if dm.config() == nil || dm.config().chunkSize == 0 {
+		return dm.deleteKeys(ctx, keys...)
+	}
+
+	manifests := make(map[string]*chunkManifest)
+	for _, key := range keys {
+		if isChunkKey(key) {
+			continue
+		}
+		m, err := dm.chunkManifestOf(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if m != nil {
+			manifests[key] = m
+		}
+	}
+
+	count, err := dm.deleteKeys(ctx, keys...)
+	if err != nil {
+		return 0, err
+	}
+	for key, m := range manifests {
+		dm.deleteChunks(ctx, key, m.Generation, 0, m.Chunks)
+	}
+	return count, nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_MaxValueSize(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mydmap": {MaxValueSize: 128},
+	}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "small", bytes.Repeat([]byte("a"), 64), nil))
+
+	err = dm.Put(ctx, "large", bytes.Repeat([]byte("a"), 256), nil)
+	require.ErrorIs(t, err, ErrValueTooLarge)
+
+	_, err = dm.Get(ctx, "large")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Chunked_Put_Get(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.DMaps.ChunkSize = 100
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	value := bytes.Repeat([]byte("olric"), 250)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), value, nil))
+	}
+
+	for i := 0; i < 10; i++ {
+		entry, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, value, entry.Value())
+	}
+
+	// The values under ChunkSize are not split.
+	require.NoError(t, dm1.Put(ctx, "small", testutil.ToVal(1), nil))
+	n, err := dm1.chunkCount(ctx, "small")
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	err = dm1.Put(ctx, "nx", value, &PutConfig{HasNX: true})
+	require.ErrorIs(t, err, ErrChunkedValueOption)
+}
+
+func TestDMap_Chunked_Overwrite(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.ChunkSize = 100
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "mykey", bytes.Repeat([]byte("a"), 1000), nil))
+	previous, err := dm.chunkManifestOf(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, 10, previous.Chunks)
+	require.NotZero(t, previous.Generation)
+
+	value := bytes.Repeat([]byte("b"), 250)
+	require.NoError(t, dm.Put(ctx, "mykey", value, nil))
+	m, err := dm.chunkManifestOf(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, 3, m.Chunks)
+	require.NotEqual(t, previous.Generation, m.Generation)
+
+	entry, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, value, entry.Value())
+
+	// The chunks of the previous value are deleted.
+	for i := 0; i < previous.Chunks; i++ {
+		_, err = dm.getEntry(ctx, chunkKey("mykey", previous.Generation, i), false)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_Chunked_Delete(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.ChunkSize = 100
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "mykey", bytes.Repeat([]byte("a"), 1000), nil))
+	m, err := dm.chunkManifestOf(ctx, "mykey")
+	require.NoError(t, err)
+
+	count, err := dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	for i := 0; i < m.Chunks; i++ {
+		_, err = dm.getEntry(ctx, chunkKey("mykey", m.Generation, i), false)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_Chunked_TTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.DMaps.ChunkSize = 100
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, "mykey", bytes.Repeat([]byte("a"), 1000), &PutConfig{HasEX: true, EX: time.Minute})
+	require.NoError(t, err)
+
+	checkChunks := func() {
+		manifest, err := dm1.getEntry(ctx, "mykey", false)
+		require.NoError(t, err)
+		m, err := dm1.chunkManifestOf(ctx, "mykey")
+		require.NoError(t, err)
+		for i := 0; i < m.Chunks; i++ {
+			chunk, err := dm1.getEntry(ctx, chunkKey("mykey", m.Generation, i), false)
+			require.NoError(t, err)
+			// The chunks never expire before the manifest.
+			require.GreaterOrEqual(t, chunk.TTL(), manifest.TTL())
+		}
+	}
+	checkChunks()
+
+	// Expire updates the expiry of the chunks.
+	require.NoError(t, dm1.Expire(ctx, "mykey", time.Hour))
+	manifest, err := dm1.getEntry(ctx, "mykey", false)
+	require.NoError(t, err)
+	require.Greater(t, manifest.TTL(), time.Now().Add(30*time.Minute).UnixNano()/1000000)
+	checkChunks()
+}
+
+func TestDMap_Chunked_Hidden_Chunk_Keys(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.ChunkSize = 100
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	value := []byte(`{"id": 1, "data": "` + strings.Repeat("a", 1000) + `"}`)
+	require.NoError(t, dm.Put(ctx, "mykey", value, nil))
+
+	var keys []string
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		result, _, err := dm.Scan(ctx, partID, 0, &ScanConfig{Count: 100})
+		require.NoError(t, err)
+		keys = append(keys, result...)
+	}
+	require.Equal(t, []string{"mykey"}, keys)
+
+	entries, _, err := dm.queryLocal(ctx, mustParseFilter(t, "id = 1"), 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "mykey", entries[0].Key())
+	require.Equal(t, value, entries[0].Value())
+
+	infos, err := s.ListDMaps(ctx)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, 1, infos[0].Entries)
+}
@@ -0,0 +1,124 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/hlc"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Put_HybridLogicalClock(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.WriteQuorum = 1
+		c.MaxClockDrift = 2 * time.Hour
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Find a key that is owned by the second member.
+	var key string
+	for i := 0; i < 100; i++ {
+		hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+		if s1.primary.PartitionByHKey(hkey).Owner().CompareByID(s2.rt.This()) {
+			key = testutil.ToKey(i)
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+
+	// The wall clock of the first member is an hour ahead.
+	skewed := time.Now().Add(time.Hour).UnixNano()
+	require.NoError(t, s1.clock.Update(skewed))
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, key, []byte("first"), nil)
+	require.NoError(t, err)
+
+	// The owner stamps the entry after the clock of the sender.
+	e, err := dm2.Get(ctx, key)
+	require.NoError(t, err)
+	require.Greater(t, e.Timestamp(), skewed)
+
+	// The next write on the owner wins, even though its wall clock is behind.
+	err = dm2.Put(ctx, key, []byte("second"), nil)
+	require.NoError(t, err)
+
+	next, err := dm2.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), next.Value())
+	require.Greater(t, next.Timestamp(), e.Timestamp())
+
+	// The backup owner has seen the timestamp of the replica.
+	require.GreaterOrEqual(t, s1.clock.Last(), next.Timestamp())
+}
+
+func TestDMap_Put_HybridLogicalClock_MaxClockDrift(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func(maxClockDrift time.Duration) *Service {
+		c := testutil.NewConfig()
+		c.MaxClockDrift = maxClockDrift
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService(-1)
+	s2 := newService(time.Minute)
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Find a key that is owned by the second member.
+	var key string
+	for i := 0; i < 100; i++ {
+		hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+		if s1.primary.PartitionByHKey(hkey).Owner().CompareByID(s2.rt.This()) {
+			key = testutil.ToKey(i)
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+
+	// The wall clock of the first member is an hour ahead.
+	skewed := time.Now().Add(time.Hour).UnixNano()
+	require.NoError(t, s1.clock.Update(skewed))
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, key, []byte("value"), nil)
+	require.ErrorIs(t, err, hlc.ErrClockDrift)
+	require.Less(t, s2.clock.Last(), skewed)
+
+	_, err = dm2.Get(ctx, key)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
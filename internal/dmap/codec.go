@@ -0,0 +1,133 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+var (
+	// CodecRawBytesTotal is the total size of the values that are stored
+	// encoded, before they are encoded.
+	CodecRawBytesTotal = stats.NewInt64Counter()
+
+	// CodecEncodedBytesTotal is the total size of the values that are stored
+	// encoded, after they are encoded.
+	CodecEncodedBytesTotal = stats.NewInt64Counter()
+)
+
+// ErrTransformFailed is returned when a stage of the value transformation
+// pipeline of a DMap returns an error.
+var ErrTransformFailed = errors.New("value transformation failed")
+
+// transformValue applies the transformation pipeline of the DMap in order.
+func (dm *DMap) transformValue(key string, value []byte) ([]byte, error) {
+	if dm.config() == nil {
+		return value, nil
+	}
+	var err error
+	for _, t := range dm.config().transformers {
+		value, err = t.Transform(dm.name, key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTransformFailed, err)
+		}
+	}
+	return value, nil
+}
+
+// reverseValue reverts the transformation pipeline of the DMap in the
+// opposite order.
+func (dm *DMap) reverseValue(key string, value []byte) ([]byte, error) {
+	if dm.config() == nil {
+		return value, nil
+	}
+	var err error
+	for i := len(dm.config().transformers) - 1; i >= 0; i-- {
+		value, err = dm.config().transformers[i].Reverse(dm.name, key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTransformFailed, err)
+		}
+	}
+	return value, nil
+}
+
+// encodeValue applies the transformation pipeline, encodes the value with
+// the codec of the DMap and records the codec ID on the entry. The key of
+// the entry has to be set before. The values that are smaller than the codec
+// threshold, or that don't get smaller by encoding, are stored as they are.
+func (dm *DMap) encodeValue(nt storage.Entry, value []byte) error {
+	value, err := dm.transformValue(nt.Key(), value)
+	if err != nil {
+		return err
+	}
+	if dm.config() == nil || dm.config().codec == nil || len(value) < dm.config().codecThreshold {
+		nt.SetValue(value)
+		return nil
+	}
+	encoded, err := dm.config().codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	if len(encoded) >= len(value) {
+		nt.SetValue(value)
+		return nil
+	}
+	CodecRawBytesTotal.Increase(int64(len(value)))
+	CodecEncodedBytesTotal.Increase(int64(len(encoded)))
+	nt.SetCodec(dm.config().codec.ID())
+	nt.SetValue(encoded)
+	return nil
+}
+
+// decodeEntry decodes the value of an entry with the codec it was stored
+// with. Entries are kept encoded on the owners and travel encoded between
+// the nodes, they are decoded before leaving the DMap.
+func decodeEntry(e storage.Entry) (storage.Entry, error) {
+	if e == nil || e.Codec() == codec.None || e.Codec() == codec.Chunked {
+		// The manifests of the chunked values are assembled by the caller.
+		return e, nil
+	}
+	value, err := codec.Decode(e.Codec(), e.Value())
+	if err != nil {
+		return nil, err
+	}
+	e.SetValue(value)
+	e.SetCodec(codec.None)
+	return e, nil
+}
+
+// readEntry decodes an entry that is read from a fragment and reverts the
+// transformation pipeline. It's called on the partition owner, the entries
+// that are returned by the owners are decoded by decodeEntry only.
+func (dm *DMap) readEntry(e storage.Entry) (storage.Entry, error) {
+	e, err := decodeEntry(e)
+	if err != nil || e == nil || dm.config() == nil || len(dm.config().transformers) == 0 {
+		return e, err
+	}
+	if e.Codec() == codec.Chunked {
+		return e, nil
+	}
+	value, err := dm.reverseValue(e.Key(), e.Value())
+	if err != nil {
+		return nil, err
+	}
+	e.SetValue(value)
+	return e, nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Codec(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.Codec = "gzip"
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The values that don't get smaller by encoding are stored as they are.
+	value := func(i int) []byte {
+		return bytes.Repeat(testutil.ToVal(i), 100)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), value(i), nil)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		res, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, value(i), res.Value())
+	}
+
+	// The codec ID is stored with the entries, on both primary and backup owners.
+	for _, dm := range []*DMap{dm1, dm2} {
+		for i := 0; i < 10; i++ {
+			hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+			for _, kind := range []partitions.Kind{partitions.PRIMARY, partitions.BACKUP} {
+				f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, kind))
+				if err == errFragmentNotFound {
+					continue
+				}
+				require.NoError(t, err)
+				f.RLock()
+				e, err := f.storage.Get(hkey)
+				f.RUnlock()
+				require.NoError(t, err)
+				require.Equal(t, codec.Gzip, e.Codec())
+			}
+		}
+	}
+}
+
+func TestDMap_Codec_Threshold(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.DMaps.Codec = "flate"
+	c.DMaps.CodecThreshold = 1024
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	raw, encoded := CodecRawBytesTotal.Read(), CodecEncodedBytesTotal.Read()
+
+	ctx := context.Background()
+	small := bytes.Repeat([]byte("a"), 1023)
+	err = dm.Put(ctx, "small", small, nil)
+	require.NoError(t, err)
+	large := bytes.Repeat([]byte("a"), 1024)
+	err = dm.Put(ctx, "large", large, nil)
+	require.NoError(t, err)
+	incompressible := make([]byte, 2048)
+	_, err = rand.Read(incompressible)
+	require.NoError(t, err)
+	err = dm.Put(ctx, "incompressible", incompressible, nil)
+	require.NoError(t, err)
+
+	for key, expected := range map[string]uint8{"small": codec.None, "large": codec.Flate, "incompressible": codec.None} {
+		hkey := partitions.HKey("mydmap", key)
+		f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.PRIMARY))
+		require.NoError(t, err)
+		f.RLock()
+		e, err := f.storage.Get(hkey)
+		f.RUnlock()
+		require.NoError(t, err)
+		require.Equal(t, expected, e.Codec(), key)
+	}
+
+	for key, value := range map[string][]byte{"small": small, "large": large, "incompressible": incompressible} {
+		res, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, value, res.Value())
+	}
+
+	require.Equal(t, int64(1024), CodecRawBytesTotal.Read()-raw)
+	require.Less(t, CodecEncodedBytesTotal.Read()-encoded, int64(1024))
+}
+
+func TestDMap_Codec_Unknown(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.DMaps.Codec = "foobar"
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+
+	_, err := s.NewDMap("mydmap")
+	require.ErrorIs(t, err, codec.ErrUnknownCodec)
+}
+
+type suffixTransformer struct {
+	suffix []byte
+}
+
+func (s suffixTransformer) Transform(_, _ string, value []byte) ([]byte, error) {
+	return append(append([]byte(nil), value...), s.suffix...), nil
+}
+
+func (s suffixTransformer) Reverse(_, key string, value []byte) ([]byte, error) {
+	if !bytes.HasSuffix(value, s.suffix) {
+		return nil, fmt.Errorf("value of %s doesn't have the suffix: %s", key, s.suffix)
+	}
+	return value[:len(value)-len(s.suffix)], nil
+}
+
+type rejectTransformer struct{}
+
+func (rejectTransformer) Transform(_, _ string, value []byte) ([]byte, error) {
+	if bytes.Equal(value, []byte("invalid")) {
+		return nil, errors.New("invalid value")
+	}
+	return value, nil
+}
+
+func (rejectTransformer) Reverse(_, _ string, value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func TestDMap_Transformers(t *testing.T) {
+	dc := config.DMap{
+		Codec: "gzip",
+		Transformers: []config.Transformer{
+			rejectTransformer{},
+			suffixTransformer{suffix: []byte("-a")},
+			suffixTransformer{suffix: []byte("-b")},
+		},
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	// The values are read on both the partition owners and the other members.
+	for _, dm := range []*DMap{dm1, dm2} {
+		for i := 0; i < 10; i++ {
+			res, err := dm.Get(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+			require.Equal(t, testutil.ToVal(i), res.Value())
+		}
+	}
+
+	// The transformed values are stored.
+	for _, dm := range []*DMap{dm1, dm2} {
+		for i := 0; i < 10; i++ {
+			hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+			f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.PRIMARY))
+			if err == errFragmentNotFound {
+				continue
+			}
+			require.NoError(t, err)
+			f.RLock()
+			e, err := f.storage.Get(hkey)
+			f.RUnlock()
+			if err != nil {
+				continue
+			}
+			value, err := codec.Decode(e.Codec(), e.Value())
+			require.NoError(t, err)
+			require.Equal(t, append(testutil.ToVal(i), []byte("-a-b")...), value)
+		}
+	}
+
+	err = dm2.Put(ctx, "mykey", []byte("invalid"), nil)
+	require.ErrorIs(t, err, ErrTransformFailed)
+	_, err = dm1.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -16,6 +16,7 @@ package dmap
 
 import (
 	"context"
+	"fmt"
 	"runtime"
 	"strings"
 	"sync"
@@ -69,6 +70,48 @@ func (s *Service) doCompaction(partID uint64) {
 	compaction(backup)
 }
 
+// Compact compacts the storage engines of all DMap fragments on this member,
+// including the backups, and returns the number of moved entries. Unlike the
+// compaction worker, it ignores the garbage ratio of the tables.
+func (s *Service) Compact(ctx context.Context) (int, error) {
+	var total int
+	var compactErr error
+	compact := func(part *partitions.Partition) {
+		part.Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// Continue. This fragment belongs to a different data structure.
+				return true
+			}
+			if compactErr = ctx.Err(); compactErr != nil {
+				return false
+			}
+
+			f := tmp.(*fragment)
+			f.Lock()
+			moved, err := f.storage.Compact()
+			f.Unlock()
+			total += moved
+			if err != nil {
+				compactErr = fmt.Errorf("compaction failed on %s: %w", name, err)
+				return false
+			}
+			return true
+		})
+	}
+
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		compact(s.primary.PartitionByID(partID))
+		if compactErr != nil {
+			return total, compactErr
+		}
+		compact(s.backup.PartitionByID(partID))
+		if compactErr != nil {
+			return total, compactErr
+		}
+	}
+	return total, nil
+}
+
 func (s *Service) triggerCompaction() {
 	var wg sync.WaitGroup
 
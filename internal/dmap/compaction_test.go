@@ -92,3 +92,41 @@ func TestDMap_Compaction(t *testing.T) {
 	})
 	require.NoError(t, err)
 }
+
+func TestDMap_Compact(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 100; i += 2 {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	moved, err := s.Compact(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 50, moved)
+
+	for i := 1; i < 100; i += 2 {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	// Nothing to compact.
+	moved, err = s.Compact(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, moved)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = s.Compact(cctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -15,10 +15,14 @@
 package dmap
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/schema"
+	"github.com/buraksezer/olric/pkg/codec"
 )
 
 // dmapConfig keeps DMap config control parameters and access-log for keys in a dmap.
@@ -30,7 +34,45 @@ type dmapConfig struct {
 	maxInuse        int
 	lruSamples      int
 	evictionPolicy  config.EvictionPolicy
+	quotaPolicy     config.QuotaPolicy
 	functions       map[string]config.Function
+	changeLogSize   int
+	keyPattern      *regexp.Regexp
+	keyValidator    func(key string) error
+	rateLimits      []rateLimitRule
+
+	latencySLO          time.Duration
+	latencySLOObjective float64
+	latencySLOWindow    time.Duration
+	codec               codec.Codec
+	codecThreshold      int
+	maxValueSize        int
+	chunkSize           int
+
+	retentionMaxAge              time.Duration
+	retentionMaxEntriesPerMember int
+	retentionDryRun              bool
+	tombstoneRetention           time.Duration
+	strictExpiry                 bool
+	hashTags                     bool
+
+	accessSampleRate float64
+	hotKeysWindow    time.Duration
+	valueSchema      *schema.Schema
+
+	loader       config.Loader
+	refreshAhead time.Duration
+
+	writer               config.Writer
+	writeMode            config.WriteMode
+	writeBehindDelay     time.Duration
+	writeBehindBatchSize int
+
+	transformers     []config.Transformer
+	conflictResolver config.ConflictResolver
+
+	onEntryExpired func(dmap, key string, value []byte)
+	onEntryEvicted func(dmap, key string, value []byte)
 }
 
 func (c *dmapConfig) load(dc *config.DMaps, name string) error {
@@ -41,8 +83,21 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 	c.maxInuse = dc.MaxInuse
 	c.lruSamples = dc.LRUSamples
 	c.evictionPolicy = dc.EvictionPolicy
+	c.quotaPolicy = dc.QuotaPolicy
 	c.engine = dc.Engine
+	c.changeLogSize = dc.ChangeLogSize
+	c.tombstoneRetention = dc.TombstoneRetention
+	c.strictExpiry = dc.StrictExpiry
 	c.functions = make(map[string]config.Function)
+	c.onEntryExpired = dc.OnEntryExpired
+	c.onEntryEvicted = dc.OnEntryEvicted
+	codecName := dc.Codec
+	c.codecThreshold = dc.CodecThreshold
+	c.maxValueSize = dc.MaxValueSize
+	c.chunkSize = dc.ChunkSize
+	c.retentionMaxAge = dc.RetentionMaxAge
+	c.retentionMaxEntriesPerMember = dc.RetentionMaxEntriesPerMember
+	c.retentionDryRun = dc.RetentionDryRun
 
 	if dc.Custom != nil {
 		// config.DMap struct can be used for fine-grained control.
@@ -69,12 +124,109 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 			if c.evictionPolicy != cs.EvictionPolicy {
 				c.evictionPolicy = cs.EvictionPolicy
 			}
+			if cs.QuotaPolicy != "" {
+				c.quotaPolicy = cs.QuotaPolicy
+			}
 			if c.engine == nil {
 				c.engine = cs.Engine
 			}
 			if cs.Functions != nil {
 				c.functions = cs.Functions
 			}
+			if cs.ChangeLogSize != 0 {
+				c.changeLogSize = cs.ChangeLogSize
+			}
+			if cs.TombstoneRetention != 0 {
+				c.tombstoneRetention = cs.TombstoneRetention
+			}
+			if cs.StrictExpiry {
+				c.strictExpiry = true
+			}
+			c.hashTags = cs.HashTags
+			if cs.KeyPattern != "" {
+				r, err := regexp.Compile(cs.KeyPattern)
+				if err != nil {
+					return fmt.Errorf("invalid key pattern: %w", err)
+				}
+				c.keyPattern = r
+			}
+			c.keyValidator = cs.KeyValidator
+			c.loadLatencySLO(cs)
+			for _, rl := range cs.RateLimits {
+				rule := rateLimitRule{
+					reads:     rl.ReadsPerSecond,
+					writes:    rl.WritesPerSecond,
+					perClient: rl.PerClient,
+				}
+				if rl.KeyPattern != "" {
+					r, err := regexp.Compile(rl.KeyPattern)
+					if err != nil {
+						return fmt.Errorf("invalid key pattern in rate limits: %w", err)
+					}
+					rule.keyPattern = r
+				}
+				c.rateLimits = append(c.rateLimits, rule)
+			}
+			if cs.Codec != "" {
+				codecName = cs.Codec
+			}
+			if cs.CodecThreshold != 0 {
+				c.codecThreshold = cs.CodecThreshold
+			}
+			if cs.MaxValueSize != 0 {
+				c.maxValueSize = cs.MaxValueSize
+			}
+			if cs.ChunkSize != 0 {
+				c.chunkSize = cs.ChunkSize
+			}
+			if cs.RetentionMaxAge != 0 {
+				c.retentionMaxAge = cs.RetentionMaxAge
+			}
+			if cs.RetentionMaxEntriesPerMember != 0 {
+				c.retentionMaxEntriesPerMember = cs.RetentionMaxEntriesPerMember
+			}
+			if cs.RetentionDryRun {
+				c.retentionDryRun = true
+			}
+			c.accessSampleRate = cs.AccessSampleRate
+			c.hotKeysWindow = cs.HotKeysWindow
+			c.loader = cs.Loader
+			c.refreshAhead = cs.RefreshAhead
+			c.writer = cs.Writer
+			c.writeMode = cs.WriteMode
+			c.writeBehindDelay = cs.WriteBehindDelay
+			c.writeBehindBatchSize = cs.WriteBehindBatchSize
+			c.transformers = cs.Transformers
+			c.conflictResolver = cs.ConflictResolver
+			if cs.ValueSchema != "" {
+				s, err := schema.Compile(cs.ValueSchema)
+				if err != nil {
+					return err
+				}
+				c.valueSchema = s
+			}
+		}
+	}
+
+	if codecName != "" {
+		cd, err := codec.GetByName(codecName)
+		if err != nil {
+			return err
+		}
+		if cd.ID() != codec.None {
+			c.codec = cd
+		}
+	}
+
+	if c.writer != nil {
+		if c.writeMode == "" {
+			c.writeMode = config.WriteThrough
+		}
+		if c.writeBehindDelay == 0 {
+			c.writeBehindDelay = config.DefaultWriteBehindDelay
+		}
+		if c.writeBehindBatchSize == 0 {
+			c.writeBehindBatchSize = config.DefaultWriteBehindBatchSize
 		}
 	}
 
@@ -90,3 +242,41 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 	}
 	return nil
 }
+
+// runtimeConfig returns the latest configuration applied by ReloadConfig.
+func (s *Service) runtimeConfig() *config.Config {
+	return s.runtime.Load().(*config.Config)
+}
+
+// ReloadConfig applies the DMaps configuration, quorum sizes and import
+// throttles in the given configuration to this service. Storage engines and
+// hash tags cannot be changed at runtime, the DMaps keep them. Nothing is
+// changed if the configuration is invalid for any of the DMaps.
+//
+// The given configuration is owned by the service after the call, it must not
+// be modified by the caller.
+func (s *Service) ReloadConfig(ctx context.Context, c *config.Config) error {
+	s.Lock()
+	defer s.Unlock()
+
+	configs := make(map[string]*dmapConfig)
+	for name, dm := range s.dmaps {
+		dc := &dmapConfig{}
+		if err := dc.load(c.DMaps, name); err != nil {
+			return fmt.Errorf("failed to reload configuration of DMap: %s: %w", name, err)
+		}
+		dc.engine = dm.config().engine
+		// The keys would be routed to different partitions.
+		dc.hashTags = dm.config().hashTags
+		configs[name] = dc
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.runtime.Store(c)
+	for name, dc := range configs {
+		s.dmaps[name].cfg.Store(dc)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ConflictsResolvedTotal is the number of the conflicting versions that are
+// passed to a ConflictResolver.
+var ConflictsResolvedTotal = stats.NewInt64Counter()
+
+// lastWriteWins returns the version with the later timestamp, the current one
+// if the timestamps are equal.
+func (dm *DMap) lastWriteWins(current, received storage.Entry) storage.Entry {
+	versions := []*version{{entry: current}, {entry: received}}
+	versions = dm.sortVersions(versions)
+	return versions[0].entry
+}
+
+// toConflictEntry returns a decoded copy of the entry, the caller keeps the
+// original one.
+func (dm *DMap) toConflictEntry(e storage.Entry) (config.ConflictEntry, error) {
+	c := dm.engine.NewEntry()
+	c.Decode(e.Encode())
+	c, err := dm.readEntry(c)
+	if err != nil {
+		return config.ConflictEntry{}, err
+	}
+	return config.ConflictEntry{
+		Key:       c.Key(),
+		Value:     c.Value(),
+		TTL:       c.TTL(),
+		Timestamp: c.Timestamp(),
+	}, nil
+}
+
+func equalConflictEntries(a, b config.ConflictEntry) bool {
+	return a.Key == b.Key && a.TTL == b.TTL && a.Timestamp == b.Timestamp && bytes.Equal(a.Value, b.Value)
+}
+
+// resolveConflict returns the version of the key that is kept when the
+// received entry conflicts with the current one. It's called by every path
+// that merges the versions of a key: the fragment merges, the read repair,
+// anti-entropy and the analytics replicas. The configured ConflictResolver is
+// called with the decoded values, it falls back to the last write wins if the
+// resolver fails.
+func (dm *DMap) resolveConflict(current, received storage.Entry) storage.Entry {
+	if dm.config() == nil || dm.config().conflictResolver == nil {
+		return dm.lastWriteWins(current, received)
+	}
+
+	local, err := dm.toConflictEntry(current)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to decode the local version of a conflicting key on DMap: %s: %v", dm.name, err)
+		return dm.lastWriteWins(current, received)
+	}
+	remote, err := dm.toConflictEntry(received)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to decode the received version of key: %s on DMap: %s: %v", local.Key, dm.name, err)
+		return dm.lastWriteWins(current, received)
+	}
+
+	ConflictsResolvedTotal.Increase(1)
+	resolved, err := dm.config().conflictResolver.Resolve(dm.name, local, remote)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to resolve the conflict of key: %s on DMap: %s: %v", local.Key, dm.name, err)
+		return dm.lastWriteWins(current, received)
+	}
+	switch {
+	case equalConflictEntries(resolved, local):
+		return current
+	case equalConflictEntries(resolved, remote):
+		return received
+	}
+
+	nt := dm.engine.NewEntry()
+	nt.SetKey(local.Key)
+	if err = dm.encodeValue(nt, resolved.Value); err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to encode the resolved value of key: %s on DMap: %s: %v", local.Key, dm.name, err)
+		return dm.lastWriteWins(current, received)
+	}
+	nt.SetTTL(resolved.TTL)
+	nt.SetTimestamp(resolved.Timestamp)
+	return nt
+}
+
+// resolveVersions merges the versions of a key that are collected from its
+// owners, the latest one is the local version of the merge. A version is
+// passed to the resolver once, even if several owners have it.
+func (dm *DMap) resolveVersions(sorted []*version) storage.Entry {
+	winner := sorted[0].entry
+	merged := []storage.Entry{winner}
+	for _, v := range sorted[1:] {
+		if containsVersion(merged, v.entry) {
+			continue
+		}
+		merged = append(merged, v.entry)
+		winner = dm.resolveConflict(winner, v.entry)
+	}
+	return winner
+}
+
+func containsVersion(entries []storage.Entry, e storage.Entry) bool {
+	for _, item := range entries {
+		if sameVersion(item, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameVersion(a, b storage.Entry) bool {
+	return a.Timestamp() == b.Timestamp() && a.TTL() == b.TTL() && bytes.Equal(a.Value(), b.Value())
+}
@@ -0,0 +1,187 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newConflictTestDMap(t *testing.T, resolver config.ConflictResolver) (*DMap, *fragment, uint64) {
+	cluster := testcluster.New(NewService)
+	t.Cleanup(cluster.Shutdown)
+
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {ConflictResolver: resolver}}
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(context.Background(), "mykey", []byte("local"), nil)
+	require.NoError(t, err)
+
+	hkey := partitions.HKey("mydmap", "mykey")
+	f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.PRIMARY))
+	require.NoError(t, err)
+	return dm, f, hkey
+}
+
+func TestDMap_ConflictResolver_Merge(t *testing.T) {
+	var calls int
+	resolver := config.ConflictResolverFunc(func(dmap string, local, remote config.ConflictEntry) (config.ConflictEntry, error) {
+		calls++
+		require.Equal(t, "mydmap", dmap)
+		require.Equal(t, "mykey", local.Key)
+
+		merged := local
+		merged.Value = append(append([]byte{}, local.Value...), remote.Value...)
+		if remote.Timestamp > merged.Timestamp {
+			merged.Timestamp = remote.Timestamp
+		}
+		return merged, nil
+	})
+	dm, f, hkey := newConflictTestDMap(t, resolver)
+
+	timestamp := time.Now().Add(time.Hour).UnixNano()
+	e := dm.engine.NewEntry()
+	e.SetKey("mykey")
+	e.SetTimestamp(timestamp)
+	e.SetValue([]byte("remote"))
+
+	err := dm.fragmentMergeFunction(f, hkey, e)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	merged, err := f.storage.Get(hkey)
+	require.NoError(t, err)
+	require.Equal(t, timestamp, merged.Timestamp())
+
+	value, err := dm.Get(context.Background(), "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("localremote"), value.Value())
+}
+
+func TestDMap_ConflictResolver_Error(t *testing.T) {
+	resolver := config.ConflictResolverFunc(func(_ string, local, _ config.ConflictEntry) (config.ConflictEntry, error) {
+		return local, errors.New("cannot merge")
+	})
+	dm, f, hkey := newConflictTestDMap(t, resolver)
+
+	// The last write wins.
+	e := dm.engine.NewEntry()
+	e.SetKey("mykey")
+	e.SetTimestamp(time.Now().Add(time.Hour).UnixNano())
+	e.SetValue([]byte("remote"))
+
+	err := dm.fragmentMergeFunction(f, hkey, e)
+	require.NoError(t, err)
+
+	value, err := dm.Get(context.Background(), "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("remote"), value.Value())
+}
+
+func TestDMap_ConflictResolver_AntiEntropy(t *testing.T) {
+	resolver := config.ConflictResolverFunc(func(_ string, local, remote config.ConflictEntry) (config.ConflictEntry, error) {
+		merged := local
+		merged.Value = append(append([]byte{}, local.Value...), remote.Value...)
+		if remote.Timestamp > merged.Timestamp {
+			merged.Timestamp = remote.Timestamp
+		}
+		return merged, nil
+	})
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.Custom = map[string]config.DMap{"mydmap": {ConflictResolver: resolver}}
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	dms := []*DMap{dm1, dm2}
+	ctx := context.Background()
+	require.NoError(t, dm1.Put(ctx, "mykey", []byte("local"), nil))
+
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		_, _, err := backupEntryOf(dms, "mykey")
+		return err
+	})
+	require.NoError(t, err)
+
+	// The backup owner has a different version of the key.
+	timestamp := time.Now().Add(time.Second).UnixNano()
+	_, f, err := backupEntryOf(dms, "mykey")
+	require.NoError(t, err)
+	f.Lock()
+	e := f.storage.NewEntry()
+	e.SetKey("mykey")
+	e.SetValue([]byte("remote"))
+	e.SetTimestamp(timestamp)
+	err = f.storage.Put(partitions.HKey("mydmap", "mykey"), e)
+	f.Unlock()
+	require.NoError(t, err)
+
+	s1.runAntiEntropy()
+	s2.runAntiEntropy()
+
+	value, err := dm1.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("localremote"), value.Value())
+	require.Equal(t, timestamp, value.Timestamp())
+
+	backup, _, err := backupEntryOf(dms, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("localremote"), backup.Value())
+}
+
+func TestDMap_ConflictResolver_ReadRepair(t *testing.T) {
+	var calls int
+	resolver := config.ConflictResolverFunc(func(_ string, local, remote config.ConflictEntry) (config.ConflictEntry, error) {
+		calls++
+		merged := local
+		merged.Value = append(append([]byte{}, local.Value...), remote.Value...)
+		return merged, nil
+	})
+	dm, _, _ := newConflictTestDMap(t, resolver)
+
+	older := dm.engine.NewEntry()
+	older.SetKey("mykey")
+	older.SetValue([]byte("remote"))
+	older.SetTimestamp(1)
+
+	current, err := dm.Get(context.Background(), "mykey")
+	require.NoError(t, err)
+	winner := dm.resolveVersions([]*version{{entry: current}, {entry: older}, {entry: current}})
+	require.Equal(t, 1, calls)
+	require.Equal(t, []byte("localremote"), winner.Value())
+}
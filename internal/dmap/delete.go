@@ -17,11 +17,14 @@ package dmap
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -34,7 +37,14 @@ var (
 )
 
 func (dm *DMap) deleteFromFragment(key string, kind partitions.Kind) error {
-	hkey := partitions.HKey(dm.name, key)
+	return dm.deleteFromFragmentIfNotNewer(key, kind, 0)
+}
+
+// deleteFromFragmentIfNotNewer deletes the key from the fragment unless its
+// timestamp is newer than the given one. A zero timestamp deletes the key
+// unconditionally.
+func (dm *DMap) deleteFromFragmentIfNotNewer(key string, kind partitions.Kind, timestamp int64) error {
+	hkey := dm.HKey(key)
 	part := dm.getPartitionByHKey(hkey, kind)
 	f, err := dm.loadFragment(part)
 	if errors.Is(err, errFragmentNotFound) {
@@ -48,14 +58,31 @@ func (dm *DMap) deleteFromFragment(key string, kind partitions.Kind) error {
 	f.Lock()
 	defer f.Unlock()
 
+	if timestamp != 0 {
+		e, err := f.storage.Get(hkey)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if e.Timestamp() > timestamp {
+			// The entry has been updated after the decision to delete it.
+			return nil
+		}
+	}
+
+	f.tags.delete(hkey)
+	f.access.delete(hkey)
 	return f.storage.Delete(hkey)
 }
 
 func (dm *DMap) deleteFromPreviousOwners(key string, owners []discovery.Member) error {
+	epoch := dm.epochOf(dm.HKey(key))
 	// Traverse in reverse order. Except from the latest host, this one.
 	for i := len(owners) - 2; i >= 0; i-- {
 		owner := owners[i]
-		cmd := protocol.NewDelEntry(dm.name, key).Command(dm.s.ctx)
+		cmd := protocol.NewDelEntry(dm.name, key).SetEpoch(epoch).Command(dm.s.ctx)
 		rc := dm.s.client.Get(owner.String())
 		err := rc.Process(dm.s.ctx, cmd)
 		if err != nil {
@@ -71,11 +98,12 @@ func (dm *DMap) deleteFromPreviousOwners(key string, owners []discovery.Member)
 
 func (dm *DMap) deleteBackupOnCluster(hkey uint64, key string) error {
 	owners := dm.s.backup.PartitionOwnersByHKey(hkey)
+	epoch := dm.epochOf(hkey)
 	var g errgroup.Group
 	for _, owner := range owners {
 		mem := owner
 		g.Go(func() error {
-			cmd := protocol.NewDelEntry(dm.name, key).SetReplica().Command(dm.s.ctx)
+			cmd := protocol.NewDelEntry(dm.name, key).SetReplica().SetEpoch(epoch).Command(dm.s.ctx)
 			rc := dm.s.client.Get(mem.String())
 			err := rc.Process(dm.s.ctx, cmd)
 			if err != nil {
@@ -97,12 +125,14 @@ func (dm *DMap) deleteOnCluster(hkey uint64, key string, f *fragment) error {
 
 	err := dm.deleteFromPreviousOwners(key, owners)
 	if err != nil {
+		dm.fenceOnStaleEpoch(hkey, err)
 		return err
 	}
 
 	if dm.s.config.ReplicaCount != 0 {
 		err := dm.deleteBackupOnCluster(hkey, key)
 		if err != nil {
+			dm.fenceOnStaleEpoch(hkey, err)
 			return err
 		}
 	}
@@ -111,58 +141,115 @@ func (dm *DMap) deleteOnCluster(hkey uint64, key string, f *fragment) error {
 	if err != nil {
 		return err
 	}
+	f.tags.delete(hkey)
+	f.access.delete(hkey)
 
 	// DeleteHits is the number of deletion reqs resulting in an item being removed.
 	DeleteHits.Increase(1)
 
+	dm.recordMutation(Mutation{
+		Kind: MutationDelete,
+		Key:  key,
+	})
+	dm.replicateToAnalytics(hkey, nil)
+
 	return nil
 }
 
-func (dm *DMap) deleteKey(key string) error {
-	hkey := partitions.HKey(dm.name, key)
+func (dm *DMap) deleteKey(ctx context.Context, key string) error {
+	defer dm.observeSLO(time.Now())
+
+	return dm.deleteOnOwner(ctx, key)
+}
+
+// deleteOnOwner deletes the key on the cluster. It has to be called on the
+// partition owner.
+func (dm *DMap) deleteOnOwner(ctx context.Context, key string) error {
+	hkey := dm.HKey(key)
 	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
 	f, err := dm.loadOrCreateFragment(part)
 	if err != nil {
 		return err
 	}
 
+	// The key may be in the external store even if it's not here.
+	op := config.WriteOp{Key: key, Delete: true}
+	if dm.writerEnabled(config.WriteThrough) {
+		unlock := dm.lockKeyForWriter(key)
+		defer unlock()
+
+		if err = dm.checkOwnerEpoch(hkey); err != nil {
+			return err
+		}
+		if err = dm.writeThrough(ctx, op); err != nil {
+			return err
+		}
+	}
+
 	f.Lock()
 	defer f.Unlock()
 
+	if err = dm.checkOwnerEpoch(hkey); err != nil {
+		return err
+	}
+
 	// Check the HKey before trying to delete it.
 	if !f.storage.Check(hkey) {
 		// DeleteMisses is the number of deletions reqs for missing keys
 		DeleteMisses.Increase(1)
+		dm.writeBehind(op)
 		return nil
 	}
 
-	return dm.deleteOnCluster(hkey, key, f)
+	if err = dm.deleteOnCluster(hkey, key, f); err != nil {
+		return err
+	}
+	dm.recordTombstone(ctx, key)
+	dm.writeBehind(op)
+	return nil
 }
 
 func (dm *DMap) deleteKeys(ctx context.Context, keys ...string) (int, error) {
-	members := make(map[discovery.Member][]string)
+	if err := dm.s.rt.CheckBootstrapQuorum(); err != nil {
+		return 0, err
+	}
+	if err := dm.s.rt.CheckMemberCountQuorum(); err != nil {
+		return 0, err
+	}
+	if err := dm.checkNamespace(); err != nil {
+		return 0, err
+	}
+
+	members := make(map[uint64]discovery.Member)
+	distribution := make(map[uint64][]string)
 	for _, key := range keys {
-		hkey := partitions.HKey(dm.name, key)
+		if err := dm.checkRateLimit(ctx, key, true); err != nil {
+			return 0, err
+		}
+		hkey := dm.HKey(key)
 		member := dm.s.primary.PartitionByHKey(hkey).Owner()
-		members[member] = append(members[member], key)
+		members[member.ID] = member
+		distribution[member.ID] = append(distribution[member.ID], key)
 	}
 
-	for member, distributedKeys := range members {
+	for id, distributedKeys := range distribution {
+		member := members[id]
 		if member.CompareByName(dm.s.rt.This()) {
 			for _, key := range distributedKeys {
-				if err := dm.deleteKey(key); err != nil {
+				if err := dm.deleteKey(ctx, key); err != nil {
 					return 0, err
 				}
 			}
 		} else {
-			cmd := protocol.NewDel(dm.name, distributedKeys...).Command(dm.s.ctx)
+			cmd := protocol.NewDelFrom(dm.name, dm.clientOf(ctx), distributedKeys...).Command(dm.s.ctx)
 			rc := dm.s.client.Get(member.String())
 			err := rc.Process(ctx, cmd)
 			if err != nil {
 				return 0, protocol.ConvertError(err)
 			}
-
-			return 0, protocol.ConvertError(cmd.Err())
+			if err = protocol.ConvertError(cmd.Err()); err != nil {
+				return 0, err
+			}
 		}
 	}
 
@@ -172,5 +259,5 @@ func (dm *DMap) deleteKeys(ctx context.Context, keys ...string) (int, error) {
 // Delete deletes the value for the given key. Delete will not return error if key doesn't exist. It's thread-safe.
 // It is safe to modify the contents of the argument after Delete returns.
 func (dm *DMap) Delete(ctx context.Context, keys ...string) (int, error) {
-	return dm.deleteKeys(ctx, keys...)
+	return dm.deleteKeysWithChunks(ctx, keys...)
 }
@@ -15,8 +15,11 @@
 package dmap
 
 import (
+	"errors"
+
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
 )
 
@@ -32,7 +35,33 @@ func (s *Service) delCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	count, err := dm.deleteKeys(s.ctx, delCmd.Keys...)
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	ctx = clientContext(ctx, conn)
+	count, err := dm.deleteKeysWithChunks(ctx, delCmd.Keys...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteInt(count)
+}
+
+func (s *Service) delFromCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	delFromCmd, err := protocol.ParseDelFromCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getDMap(delFromCmd.Del.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx := withForwarded(WithClient(s.ctx, delFromCmd.Client))
+	count, err := dm.deleteKeys(ctx, delFromCmd.Del.Keys...)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -58,7 +87,12 @@ func (s *Service) delEntryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		kind = partitions.BACKUP
 	}
 	for _, key := range delCmd.Del.Keys {
-		err = dm.deleteFromFragment(key, kind)
+		part := dm.getPartitionByHKey(dm.HKey(key), kind)
+		if err = checkEpoch(part, delCmd.Epoch); err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		err = dm.deleteFromFragmentIfNotNewer(key, kind, delCmd.Timestamp)
 		if err != nil {
 			protocol.WriteError(conn, err)
 			return
@@ -67,3 +101,36 @@ func (s *Service) delEntryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 
 	conn.WriteInt(len(delCmd.Del.Keys))
 }
+
+func (s *Service) delByTagCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	delByTagCmd, err := protocol.ParseDelByTagCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getDMap(delByTagCmd.DMap)
+	if errors.Is(err, ErrDMapNotFound) && delByTagCmd.Local {
+		// This member has no entry of the DMap.
+		conn.WriteInt(0)
+		return
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	var count int
+	if delByTagCmd.Local {
+		count, err = dm.deleteByTagLocal(ctx, delByTagCmd.Tag)
+	} else {
+		count, err = dm.DeleteByTag(ctx, delByTagCmd.Tag)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(count)
+}
@@ -98,6 +98,40 @@ func TestDMap_Delete_Cluster(t *testing.T) {
 	}
 }
 
+func TestDMap_Delete_Cluster_ManyKeys(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	s3 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	dm3, err := s3.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var keys []string
+	for i := 0; i < 100; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+		keys = append(keys, testutil.ToKey(i))
+	}
+
+	// The keys are distributed among all members, every group must be deleted.
+	_, err = dm2.Delete(ctx, keys...)
+	require.NoError(t, err)
+
+	for _, key := range keys {
+		_, err = dm3.Get(ctx, key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
 func TestDMap_Delete_Lookup(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	defer cluster.Shutdown()
@@ -350,3 +384,169 @@ func TestDMap_Delete_Compaction(t *testing.T) {
 	}
 	checkEmptyStorageEngine(t, s)
 }
+
+func TestDMap_DeleteByTag_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := cluster.AddMember(nil).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := cluster.AddMember(nil).(*Service)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		pc := &PutConfig{}
+		if i%2 == 0 {
+			pc.Tags = []string{"even"}
+		}
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc)
+		require.NoError(t, err)
+	}
+
+	// A write without tags clears the tags of the key.
+	err = dm1.Put(ctx, testutil.ToKey(0), testutil.ToVal(0), nil)
+	require.NoError(t, err)
+
+	count, err := dm2.DeleteByTag(ctx, "even")
+	require.NoError(t, err)
+	require.Equal(t, 9, count)
+
+	for i := 0; i < 20; i++ {
+		_, err = dm1.Get(ctx, testutil.ToKey(i))
+		if i%2 == 0 && i != 0 {
+			require.ErrorIs(t, err, ErrKeyNotFound)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestDMap_DeleteByTag_Backup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.ReplicationMode = config.SyncReplicationMode
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, "mykey", "myvalue", &PutConfig{Tags: []string{"mytag"}})
+	require.NoError(t, err)
+
+	_, f, err := backupEntryOf([]*DMap{dm1, dm2}, "mykey")
+	require.NoError(t, err)
+	hkey := partitions.HKey("mydmap", "mykey")
+	f.RLock()
+	require.Equal(t, []string{"mytag"}, f.tags.tags[hkey])
+	f.RUnlock()
+
+	// A TTL update keeps the tags.
+	err = dm1.Expire(ctx, "mykey", time.Hour)
+	require.NoError(t, err)
+	f.RLock()
+	require.Equal(t, []string{"mytag"}, f.tags.tags[hkey])
+	f.RUnlock()
+
+	_, err = dm1.Delete(ctx, "mykey")
+	require.NoError(t, err)
+	f.RLock()
+	require.Empty(t, f.tags.tags)
+	f.RUnlock()
+}
+
+func TestDMap_DeleteByTag_Balancer(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := cluster.AddMember(nil).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		pc := &PutConfig{}
+		if i%2 == 0 {
+			pc.Tags = []string{"even"}
+		}
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc)
+		require.NoError(t, err)
+	}
+
+	// The fragments are moved to the new member with their tags.
+	s2 := cluster.AddMember(nil).(*Service)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	count, err := dm2.DeleteByTag(ctx, "even")
+	require.NoError(t, err)
+	require.Equal(t, 50, count)
+}
+
+func TestDMap_DeleteByTag_Expired(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	pc := &PutConfig{HasPX: true, PX: time.Millisecond, Tags: []string{"mytag"}}
+	err = dm.Put(ctx, "expired", "myvalue", pc)
+	require.NoError(t, err)
+	err = dm.Put(ctx, "mykey", "myvalue", &PutConfig{Tags: []string{"mytag"}})
+	require.NoError(t, err)
+
+	<-time.After(5 * time.Millisecond)
+
+	count, err := dm.DeleteByTag(ctx, "mytag")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	part := dm.getPartitionByHKey(partitions.HKey("mydmap", "expired"), partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	require.NoError(t, err)
+	f.RLock()
+	require.Empty(t, f.tags.tags)
+	f.RUnlock()
+}
+
+func TestDMap_DeleteByTag_ContextCanceled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(context.Background(), "mykey", "myvalue", &PutConfig{Tags: []string{"mytag"}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	before := CanceledOperationsTotal.Read()
+	_, err = dm.DeleteByTag(ctx, "mytag")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, before+1, CanceledOperationsTotal.Read())
+
+	// Nothing is deleted.
+	_, err = dm.Get(context.Background(), "mykey")
+	require.NoError(t, err)
+}
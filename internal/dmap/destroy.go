@@ -17,6 +17,7 @@ package dmap
 import (
 	"context"
 	"runtime"
+	"sync/atomic"
 
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
@@ -41,10 +42,11 @@ func (dm *DMap) destroyOnCluster(ctx context.Context) error {
 	})
 	m.RUnlock()
 
+	var done int32
 	for _, item := range members {
 		addr := item.String()
 		g.Go(func() error {
-			if err := sem.Acquire(dm.s.ctx, 1); err != nil {
+			if err := sem.Acquire(ctx, 1); err != nil {
 				dm.s.log.V(3).
 					Printf("[ERROR] Failed to acquire semaphore to call Destroy command on %s for %s: %v",
 						addr, dm.name, err)
@@ -60,15 +62,36 @@ func (dm *DMap) destroyOnCluster(ctx context.Context) error {
 				dm.s.log.V(3).Printf("[ERROR] DM.DESTROY returned an error: %v", err)
 				return err
 			}
-			return cmd.Err()
+			if err = cmd.Err(); err != nil {
+				return err
+			}
+			atomic.AddInt32(&done, 1)
+			return nil
 		})
 	}
-	return g.Wait()
+	err := g.Wait()
+	if ctx.Err() != nil && int(done) < len(members) {
+		// The DMap is destroyed on some members, report the progress.
+		return &ProgressError{
+			Operation: "destroy",
+			Done:      int(done),
+			Total:     len(members),
+			Err:       ctx.Err(),
+		}
+	}
+	return err
 }
 
 // Destroy flushes the given DMap on the cluster. You should know that there
 // is no global lock on DMaps. So if you call Put, Put with EX and Destroy methods
 // concurrently on the cluster, Put and Put with EX calls may set new values to the DMap.
+//
+// It stops calling the remaining members when the context is canceled and
+// returns a *ProgressError with the number of the members that destroyed
+// the DMap.
 func (dm *DMap) Destroy(ctx context.Context) error {
+	if err := dm.checkNamespace(); err != nil {
+		return err
+	}
 	return dm.destroyOnCluster(ctx)
 }
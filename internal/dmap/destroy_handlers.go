@@ -15,15 +15,19 @@
 package dmap
 
 import (
+	"context"
 	"errors"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
 )
 
-func (dm *DMap) destroyFragmentOnPartition(part *partitions.Partition) error {
+// destroyFragmentOnPartition wipes out the fragment of the DMap on the given
+// partition. The fragment is set aside instead if a snapshot is given.
+func (dm *DMap) destroyFragmentOnPartition(part *partitions.Partition, snapshot *destroySnapshot) error {
 	f, err := dm.loadFragment(part)
 	if errors.Is(err, errFragmentNotFound) {
 		// not exists
@@ -32,12 +36,41 @@ func (dm *DMap) destroyFragmentOnPartition(part *partitions.Partition) error {
 	if err != nil {
 		return err
 	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if snapshot != nil {
+		f.detachUsage()
+		part.Map().Delete(dm.fragmentName)
+		snapshot.add(part, f)
+		return nil
+	}
 	return wipeOutFragment(part, dm.fragmentName, f)
 }
 
-func (s *Service) destroyLocalDMap(name string) error {
+// destroyLocalDMap destroys the fragments of the DMap on this member. It
+// checks the context between the partitions and returns a *ProgressError if
+// it's canceled. The destroyed fragments are not restored.
+func (s *Service) destroyLocalDMap(ctx context.Context, name string) error {
+	var snapshot *destroySnapshot
+	if s.config.DMaps.DestroySnapshotRetention > 0 {
+		snapshot = newDestroySnapshot(s.config.DMaps.DestroySnapshotRetention)
+	}
+
 	// This is very similar with rm -rf. Destroys given dmap on the cluster
 	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		if err := ctx.Err(); err != nil {
+			if snapshot != nil {
+				s.keepSnapshot(name, snapshot)
+			}
+			return &ProgressError{
+				Operation: "destroy",
+				Done:      int(partID),
+				Total:     int(s.config.PartitionCount),
+				Err:       err,
+			}
+		}
 		dm, err := s.getDMap(name)
 		if errors.Is(err, ErrDMapNotFound) {
 			continue
@@ -47,7 +80,7 @@ func (s *Service) destroyLocalDMap(name string) error {
 		}
 
 		part := dm.s.primary.PartitionByID(partID)
-		err = dm.destroyFragmentOnPartition(part)
+		err = dm.destroyFragmentOnPartition(part, snapshot)
 		if err != nil {
 			return err
 		}
@@ -55,13 +88,21 @@ func (s *Service) destroyLocalDMap(name string) error {
 		// Destroy on replicas
 		if s.config.ReplicaCount > config.MinimumReplicaCount {
 			backup := dm.s.backup.PartitionByID(partID)
-			err = dm.destroyFragmentOnPartition(backup)
+			err = dm.destroyFragmentOnPartition(backup, snapshot)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	if snapshot != nil {
+		s.keepSnapshot(name, snapshot)
+	}
+
+	if err := s.analyticsStore.destroy(name); err != nil {
+		return err
+	}
+
 	s.Lock()
 	delete(s.dmaps, name)
 	s.Unlock()
@@ -82,10 +123,13 @@ func (s *Service) destroyCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
 	if destroyCmd.Local {
-		err = s.destroyLocalDMap(destroyCmd.DMap)
+		err = s.destroyLocalDMap(ctx, destroyCmd.DMap)
 	} else {
-		err = dm.destroyOnCluster(s.ctx)
+		err = dm.destroyOnCluster(ctx)
 	}
 
 	if err != nil {
@@ -95,3 +139,28 @@ func (s *Service) destroyCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 
 	conn.WriteString(protocol.StatusOK)
 }
+
+func (s *Service) undoCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	undoCmd, err := protocol.ParseUndoCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	var count int
+	if undoCmd.Local {
+		count, err = s.undoLocal(ctx, undoCmd.DMap)
+	} else {
+		count, err = s.Undo(ctx, undoCmd.DMap)
+	}
+
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteInt(count)
+}
@@ -140,3 +140,38 @@ func TestDMap_Destroy_destroyOperation(t *testing.T) {
 		require.ErrorIs(t, err, ErrKeyNotFound)
 	}
 }
+
+func TestDMap_Destroy_Canceled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(context.Background(), testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = dm.Destroy(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	var pe *ProgressError
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, "destroy", pe.Operation)
+	require.Equal(t, 0, pe.Done)
+	require.Equal(t, 1, pe.Total)
+
+	err = s.destroyLocalDMap(ctx, "mydmap")
+	require.ErrorAs(t, err, &pe)
+	require.Equal(t, int(s.config.PartitionCount), pe.Total)
+
+	// Nothing is destroyed.
+	for i := 0; i < 10; i++ {
+		_, err = dm.Get(context.Background(), testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+}
@@ -17,6 +17,7 @@ package dmap
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -30,15 +31,30 @@ var (
 	ErrKeyNotFound  = errors.New("key not found")
 	ErrDMapNotFound = errors.New("dmap not found")
 	ErrServerGone   = errors.New("server is gone")
+
+	// ErrInvalidKey is returned when a key is rejected by the key validation
+	// rules of a DMap.
+	ErrInvalidKey = errors.New("invalid key")
 )
 
 // DMap implements a single-hop distributed hash table.
 type DMap struct {
 	name         string
+	namespace    string
 	fragmentName string
 	s            *Service
 	engine       storage.Engine
-	config       *dmapConfig
+	cfg          atomic.Value // *dmapConfig
+
+	// readOnly rejects the writes of this instance, see NewReadOnlyDMap.
+	readOnly bool
+}
+
+// config returns the current configuration of the DMap. It's replaced by
+// Service.ReloadConfig at runtime.
+func (dm *DMap) config() *dmapConfig {
+	c, _ := dm.cfg.Load().(*dmapConfig)
+	return c
 }
 
 // Name exposes name of the DMap.
@@ -93,17 +109,19 @@ func (s *Service) NewDMap(name string) (*DMap, error) {
 // It's used for temporary DMaps.
 func (s *Service) NewTempDMap(name string) (*DMap, error) {
 	dm := &DMap{
-		config:       &dmapConfig{},
 		name:         name,
+		namespace:    namespaceOf(name),
 		fragmentName: s.fragmentName(name),
 		s:            s,
 	}
-	if err := dm.config.load(s.config.DMaps, name); err != nil {
+	c := &dmapConfig{}
+	if err := c.load(s.runtimeConfig().DMaps, name); err != nil {
 		return nil, err
 	}
+	dm.cfg.Store(c)
 
 	// It's a shortcut.
-	dm.engine = dm.config.engine.Implementation
+	dm.engine = c.engine.Implementation
 	return dm, nil
 }
 
@@ -134,6 +152,55 @@ func (dm *DMap) getPartitionByHKey(hkey uint64, kind partitions.Kind) *partition
 	return part
 }
 
+// HKey returns the hash of the key in this DMap. The keys with the same hash
+// tag are stored on the same partition if HashTags is enabled for the DMap.
+func (dm *DMap) HKey(key string) uint64 {
+	if dm.config().hashTags {
+		return partitions.TaggedHKey(dm.name, key, dm.s.config.PartitionCount)
+	}
+	return partitions.HKey(dm.name, key)
+}
+
+// checkWrite returns an error if the key cannot be written to this DMap.
+func (dm *DMap) checkWrite(key string) error {
+	if err := dm.s.rt.CheckBootstrapQuorum(); err != nil {
+		return err
+	}
+	// A member on the minority side of a network partition doesn't accept
+	// writes, they would diverge from the majority.
+	if err := dm.s.rt.CheckMemberCountQuorum(); err != nil {
+		return err
+	}
+	if err := dm.checkNamespace(); err != nil {
+		return err
+	}
+	return dm.validateKey(key)
+}
+
+// validateKey checks the key against KeyPattern and KeyValidator of this DMap.
+func (dm *DMap) validateKey(key string) error {
+	if dm.config() == nil {
+		return nil
+	}
+	if dm.config().keyPattern != nil && !dm.config().keyPattern.MatchString(key) {
+		return fmt.Errorf("%w: does not match %s", ErrInvalidKey, dm.config().keyPattern)
+	}
+	if dm.config().keyValidator != nil {
+		if err := dm.config().keyValidator(key); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+	}
+	return nil
+}
+
+// strictlyExpired returns true if the strict expiry mode is enabled for the
+// DMap and the entry with the given TTL is expired. The reads that may
+// observe an entry before the eviction workers remove it check it, see
+// config.DMaps.StrictExpiry.
+func (dm *DMap) strictlyExpired(ttl int64) bool {
+	return dm.config().strictExpiry && isKeyExpired(ttl)
+}
+
 func isKeyExpired(ttl int64) bool {
 	if ttl == 0 {
 		return false
@@ -15,10 +15,14 @@
 package dmap
 
 import (
-	"github.com/stretchr/testify/require"
+	"context"
 	"testing"
 
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDMap_Name(t *testing.T) {
@@ -30,3 +34,39 @@ func TestDMap_Name(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "mydmap", dm.Name())
 }
+
+func TestDMap_MemberCountQuorum(t *testing.T) {
+	for _, mode := range []int{config.ReadOnlyQuorumLossMode, config.UnavailableQuorumLossMode} {
+		c := testutil.NewConfig()
+		c.MemberCountQuorum = 1
+		c.QuorumLossMode = mode
+
+		cluster := testcluster.New(NewService)
+		s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		err = dm.Put(ctx, "mykey", []byte("myvalue"), nil)
+		require.NoError(t, err)
+
+		// Simulate a network partition.
+		s.rt.SetNumMembersEagerly(0)
+
+		err = dm.Put(ctx, "mykey", []byte("myvalue"), nil)
+		require.ErrorIs(t, err, routingtable.ErrClusterQuorum)
+
+		_, err = dm.Delete(ctx, "mykey")
+		require.ErrorIs(t, err, routingtable.ErrClusterQuorum)
+
+		_, err = dm.Get(ctx, "mykey")
+		if mode == config.UnavailableQuorumLossMode {
+			require.ErrorIs(t, err, routingtable.ErrClusterQuorum)
+		} else {
+			require.NoError(t, err)
+		}
+
+		cluster.Shutdown()
+	}
+}
@@ -33,15 +33,20 @@ type env struct {
 	kind      partitions.Kind
 	fragment  *fragment
 	function  string
+	// repair is set for the writes of read-repair. They restore a version
+	// that is already written, so they are not recorded as mutations.
+	repair bool
 }
 
-func newEnv(ctx context.Context, timestamp int64) *env {
+// newEnv returns a new env. If timestamp is zero, the env is stamped with the
+// hybrid logical clock of this member.
+func (s *Service) newEnv(ctx context.Context, timestamp int64) *env {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
 	if timestamp == 0 {
-		timestamp = time.Now().UnixNano()
+		timestamp = s.clock.Now()
 	}
 
 	return &env{
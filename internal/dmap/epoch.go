@@ -0,0 +1,66 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/stats"
+)
+
+// ErrStaleEpoch is returned when a replicated write carries an older partition
+// epoch than the one on the receiver. It means that the sender is not the
+// partition owner anymore, but it has not received the new routing table yet.
+var ErrStaleEpoch = errors.New("partition epoch is stale")
+
+// StaleEpochRejectionsTotal is the number of the replicated writes that are
+// rejected because of a stale partition epoch.
+var StaleEpochRejectionsTotal = stats.NewInt64Counter()
+
+// epochOf returns the ownership epoch of the partition that the key belongs to.
+func (dm *DMap) epochOf(hkey uint64) uint64 {
+	return dm.s.primary.PartitionByHKey(hkey).Epoch()
+}
+
+// checkEpoch returns ErrStaleEpoch if the epoch of a replicated write is older
+// than the epoch of the partition on this member. Zero means that the sender
+// doesn't know the epoch, the writes of read repair don't carry an epoch.
+func checkEpoch(part *partitions.Partition, epoch uint64) error {
+	if epoch != 0 && epoch < part.Epoch() {
+		StaleEpochRejectionsTotal.Increase(1)
+		return ErrStaleEpoch
+	}
+	return nil
+}
+
+// checkOwnerEpoch returns ErrStaleEpoch if the partition of the key is fenced
+// on this member. The partition owner fences a partition when a backup owner
+// rejects a replicated write with ErrStaleEpoch, so a deposed owner refuses the
+// writes before applying them, until it receives the new routing table.
+func (dm *DMap) checkOwnerEpoch(hkey uint64) error {
+	if dm.s.primary.PartitionByHKey(hkey).Fenced() {
+		StaleEpochRejectionsTotal.Increase(1)
+		return ErrStaleEpoch
+	}
+	return nil
+}
+
+// fenceOnStaleEpoch fences the partition of the key if err is ErrStaleEpoch.
+func (dm *DMap) fenceOnStaleEpoch(hkey uint64, err error) {
+	if errors.Is(err, ErrStaleEpoch) {
+		dm.s.primary.PartitionByHKey(hkey).Fence()
+	}
+}
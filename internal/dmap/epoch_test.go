@@ -0,0 +1,134 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Put_StaleEpoch(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.WriteQuorum = 1
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Find a key that is owned by the first member.
+	var key string
+	for i := 0; i < 100; i++ {
+		hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+		if s1.primary.PartitionByHKey(hkey).Owner().CompareByID(s1.rt.This()) {
+			key = testutil.ToKey(i)
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, key, []byte("value"), nil)
+	require.NoError(t, err)
+
+	// Simulate a new routing table that is received by the backup owner but
+	// not by the partition owner.
+	hkey := partitions.HKey("mydmap", key)
+	part := s2.backup.PartitionByHKey(hkey)
+	part.SetEpoch(dm1.epochOf(hkey) + 1)
+
+	err = dm1.Put(ctx, key, []byte("new-value"), nil)
+	require.ErrorIs(t, err, ErrStaleEpoch)
+
+	_, err = dm1.Delete(ctx, key)
+	require.ErrorIs(t, err, ErrStaleEpoch)
+
+	// The write is not applied by the deposed owner.
+	e, err := dm2.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), e.Value())
+}
+
+func TestDMap_Put_StaleEpoch_AsyncReplication(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.ReplicationMode = config.AsyncReplicationMode
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var key string
+	for i := 0; i < 100; i++ {
+		hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+		if s1.primary.PartitionByHKey(hkey).Owner().CompareByID(s1.rt.This()) {
+			key = testutil.ToKey(i)
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+	hkey := partitions.HKey("mydmap", key)
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, key, []byte("value"), nil)
+	require.NoError(t, err)
+
+	// The backup owner has a newer routing table.
+	s2.backup.PartitionByHKey(hkey).SetEpoch(dm1.epochOf(hkey) + 1)
+
+	// The write is applied locally, the backup rejects it in the background.
+	err = dm1.Put(ctx, key, []byte("new-value"), nil)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return s1.primary.PartitionByHKey(hkey).Fenced()
+	}, time.Second, 10*time.Millisecond)
+
+	// The deposed owner refuses the next writes before applying them.
+	err = dm1.Put(ctx, key, []byte("another-value"), nil)
+	require.ErrorIs(t, err, ErrStaleEpoch)
+	e, err := dm1.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("new-value"), e.Value())
+
+	// The fence is lifted by the next routing table.
+	part := s1.primary.PartitionByHKey(hkey)
+	part.SetEpoch(part.Epoch() + 1)
+	require.False(t, part.Fenced())
+}
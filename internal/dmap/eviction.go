@@ -23,18 +23,40 @@ import (
 	"strings"
 	"time"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/storage"
 	"golang.org/x/sync/semaphore"
 )
 
+var (
+	// EvictionSweepsTotal is the number of sweeps run to evict the expired and idle keys.
+	EvictionSweepsTotal = stats.NewInt64Counter()
+
+	// ActivelyExpiredTotal is the number of expired and idle keys removed by the eviction workers.
+	ActivelyExpiredTotal = stats.NewInt64Counter()
+
+	// LazilyExpiredTotal is the number of expired and idle keys removed by the read
+	// requests on the partition owner before the eviction workers removed them.
+	LazilyExpiredTotal = stats.NewInt64Counter()
+)
+
+// observer returns the storage.Observer in the engine configuration of the DMap.
+func (dm *DMap) observer() storage.Observer {
+	if dm.config() == nil || dm.config().engine == nil {
+		return nil
+	}
+	return storage.LoadObserver(storage.NewConfig(dm.config().engine.Config))
+}
+
 // isKeyIdleOnFragment is not a thread-safe function. It accesses underlying fragment for the given hkey.
 func (dm *DMap) isKeyIdleOnFragment(hkey uint64, f *fragment) bool {
-	if dm.config == nil {
+	if dm.config() == nil {
 		return false
 	}
 
-	if dm.config.maxIdleDuration.Nanoseconds() == 0 {
+	if dm.config().maxIdleDuration.Nanoseconds() == 0 {
 		return false
 	}
 	// Maximum time in seconds for each entry to stay idle in the map.
@@ -46,10 +68,35 @@ func (dm *DMap) isKeyIdleOnFragment(hkey uint64, f *fragment) bool {
 		return false
 	}
 	// TODO: Handle other errors.
-	ttl := (dm.config.maxIdleDuration.Nanoseconds() + lastAccess) / 1000000
+	ttl := (dm.config().maxIdleDuration.Nanoseconds() + lastAccess) / 1000000
 	return isKeyExpired(ttl)
 }
 
+// removalNotifier returns a function that calls the callback with the value of
+// the entry in a new goroutine. The value is read before the entry is deleted,
+// the returned function has to be called after. It's not a thread-safe function.
+func (dm *DMap) removalNotifier(callback func(dmap, key string, value []byte), f *fragment, hkey uint64, key string) func() {
+	if callback == nil {
+		return func() {}
+	}
+
+	entry, err := f.storage.Get(hkey)
+	if err == nil {
+		entry, err = dm.readEntry(entry)
+	}
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to read the removed key: %s on DMap: %s: %v", key, dm.name, err)
+		return func() {}
+	}
+	// The value points to the memory of the storage engine, it may be reused
+	// after the entry is deleted.
+	value := make([]byte, len(entry.Value()))
+	copy(value, entry.Value())
+	return func() {
+		go callback(dm.name, key, value)
+	}
+}
+
 func (dm *DMap) isKeyIdle(hkey uint64) bool {
 	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
 	f, err := dm.loadFragment(part)
@@ -66,6 +113,48 @@ func (dm *DMap) isKeyIdle(hkey uint64) bool {
 	return dm.isKeyIdleOnFragment(hkey, f)
 }
 
+// expireLazily removes an expired or idle key that is found by a read request
+// on the partition owner. The key is removed from the backups and the previous
+// owners too, like the eviction workers do.
+func (dm *DMap) expireLazily(hkey uint64, key string) {
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		// The key is found on the backups or the previous owners.
+		return
+	}
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to get DMap fragment: %v", err)
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	ttl, err := f.storage.GetTTL(hkey)
+	if err != nil {
+		// Already removed.
+		return
+	}
+	if !isKeyExpired(ttl) && !dm.isKeyIdleOnFragment(hkey, f) {
+		// The key is written again after it's read.
+		return
+	}
+
+	notify := dm.removalNotifier(dm.config().onEntryExpired, f, hkey, key)
+	err = dm.deleteOnCluster(hkey, key, f)
+	if err != nil {
+		// The eviction workers will try again.
+		dm.s.log.V(3).Printf("[ERROR] Failed to delete expired key: %s on DMap: %s: %v",
+			key, dm.name, err)
+		return
+	}
+	notify()
+
+	EvictedTotal.Increase(1)
+	LazilyExpiredTotal.Increase(1)
+}
+
 func (s *Service) evictKeysAtBackground() {
 	defer s.wg.Done()
 
@@ -88,10 +177,10 @@ func (s *Service) evictKeysAtBackground() {
 		go func() {
 			defer s.wg.Done()
 			defer sem.Release(1)
-			// Good for developing tests.
+			start := time.Now()
 			s.evictKeys()
 			select {
-			case <-time.After(100 * time.Millisecond):
+			case <-time.After(s.expirationScanDelay(time.Since(start))):
 			case <-s.ctx.Done():
 				return
 			}
@@ -99,10 +188,37 @@ func (s *Service) evictKeysAtBackground() {
 	}
 }
 
+// expirationScanDelay returns the time to wait before the next scan of an
+// eviction worker. It's ExpirationScanInterval unless the worker has to wait
+// longer to keep the time spent on scanning under ExpirationMaxCPUFraction.
+func (s *Service) expirationScanDelay(elapsed time.Duration) time.Duration {
+	delay := config.DefaultExpirationScanInterval
+	if s.config.DMaps == nil {
+		return delay
+	}
+	if s.config.DMaps.ExpirationScanInterval > 0 {
+		delay = s.config.DMaps.ExpirationScanInterval
+	}
+	fraction := s.config.DMaps.ExpirationMaxCPUFraction
+	if fraction <= 0 || fraction >= 1 {
+		return delay
+	}
+	idle := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+	if idle > delay {
+		return idle
+	}
+	return delay
+}
+
 func (s *Service) evictKeys() {
 	partID := uint64(rand.Intn(int(s.config.PartitionCount)))
 	part := s.primary.PartitionByID(partID)
 	part.Map().Range(func(name, tmp interface{}) bool {
+		if !strings.HasPrefix(name.(string), "dmap.") {
+			// This fragment belongs to a different data structure.
+			return true
+		}
+
 		f := tmp.(*fragment)
 		s.scanFragmentForEviction(partID, strings.TrimPrefix(name.(string), "dmap."), f)
 		// this breaks the loop, we only scan one dmap instance per call
@@ -120,9 +236,14 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 
 	// We need limits to prevent CPU starvation. deleteOnCluster does some network operation
 	// to delete keys from the backup nodes and the previous owners.
-	maxKeyCount := 20
-	maxTotalCount := 100
+	maxKeyCount := config.DefaultExpirationSampleSize
+	if s.config.DMaps != nil && s.config.DMaps.ExpirationSampleSize > 0 {
+		maxKeyCount = s.config.DMaps.ExpirationSampleSize
+	}
+	maxTotalCount := 5 * maxKeyCount
 	totalCount := 0
+	evicted := 0
+	start := time.Now()
 
 	createdDMap := false
 
@@ -163,7 +284,13 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 				return true // continue
 			}
 
-			if isKeyExpired(ttl) || dm.isKeyIdleOnFragment(hkey, f) || createdDMap {
+			expired := isKeyExpired(ttl) || dm.isKeyIdleOnFragment(hkey, f)
+			if expired || createdDMap {
+				callback := dm.config().onEntryEvicted
+				if expired {
+					callback = dm.config().onEntryExpired
+				}
+				notify := dm.removalNotifier(callback, f, hkey, key)
 				err = dm.deleteOnCluster(hkey, key, f)
 				if err != nil {
 					// It will be tried again.
@@ -172,8 +299,15 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 					return true
 				}
 
+				notify()
+
 				// number of valid items removed from cache to free memory for new items.
 				EvictedTotal.Increase(1)
+				evicted++
+				if expired {
+					ActivelyExpiredTotal.Increase(1)
+					count++
+				}
 			}
 			return true
 		})
@@ -183,6 +317,15 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 	}
 
 	defer func() {
+		EvictionSweepsTotal.Increase(1)
+		if observer := dm.observer(); observer != nil {
+			observer(storage.Event{
+				Kind:     storage.EvictionSweep,
+				Time:     time.Now(),
+				Duration: time.Since(start),
+				Entries:  evicted,
+			})
+		}
 		if totalCount > 0 {
 			if s.log.V(6).Ok() {
 				s.log.V(6).Printf("[DEBUG] Evicted key count is %d on PartID: %d", totalCount, partID)
@@ -219,7 +362,7 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 
 	// Pick random items from the distributed map and sort them by accessedAt.
 	e.fragment.storage.Range(func(hkey uint64, e storage.Entry) bool {
-		if idx >= dm.config.lruSamples {
+		if idx >= dm.config().lruSamples {
 			return false
 		}
 		idx++
@@ -250,10 +393,12 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 	if dm.s.log.V(6).Ok() {
 		dm.s.log.V(6).Printf("[DEBUG] Evicted item on DMap: %s, key: %s with LRU", e.dmap, key)
 	}
+	notify := dm.removalNotifier(dm.config().onEntryEvicted, e.fragment, item.HKey, key)
 	err = dm.deleteOnCluster(item.HKey, key, e.fragment)
 	if err != nil {
 		return err
 	}
+	notify()
 
 	// number of valid items removed from cache to free memory for new items.
 	EvictedTotal.Increase(1)
@@ -20,8 +20,10 @@ import (
 	"time"
 
 	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/stretchr/testify/require"
 )
 
@@ -214,3 +216,153 @@ func TestDMap_Eviction_LRU_Config_MaxInuse(t *testing.T) {
 
 	require.NotEqual(t, 100, length)
 }
+
+type removedEntry struct {
+	dmap  string
+	key   string
+	value []byte
+}
+
+func TestDMap_Eviction_OnEntryExpired(t *testing.T) {
+	removed := make(chan removedEntry, 100)
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps = &config.DMaps{
+		Engine: config.NewEngine(),
+		OnEntryExpired: func(dmap, key string, value []byte) {
+			removed <- removedEntry{dmap: dmap, key: key, value: value}
+		},
+	}
+	require.NoError(t, c.DMaps.Engine.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	pc := &PutConfig{
+		HasPX: true,
+		PX:    time.Millisecond,
+	}
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc)
+		require.NoError(t, err)
+	}
+
+	<-time.After(5 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.evictKeys()
+	}
+
+	values := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		select {
+		case r := <-removed:
+			require.Equal(t, "mydmap", r.dmap)
+			values[r.key] = r.value
+		case <-time.After(time.Second):
+			require.Fail(t, "OnEntryExpired is not called")
+		}
+	}
+	for i := 0; i < 10; i++ {
+		require.Equal(t, testutil.ToVal(i), values[testutil.ToKey(i)])
+	}
+}
+
+func TestDMap_Eviction_OnEntryEvicted(t *testing.T) {
+	removed := make(chan removedEntry, 100)
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps = &config.DMaps{
+		MaxKeys:        70,
+		EvictionPolicy: config.LRUEviction,
+		Engine:         config.NewEngine(),
+		OnEntryEvicted: func(dmap, key string, value []byte) {
+			removed <- removedEntry{dmap: dmap, key: key, value: value}
+		},
+	}
+	require.NoError(t, c.DMaps.Engine.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	select {
+	case r := <-removed:
+		require.Equal(t, "mydmap", r.dmap)
+		_, err = dm.Get(ctx, r.key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		require.NotEmpty(t, r.value)
+	case <-time.After(time.Second):
+		require.Fail(t, "OnEntryEvicted is not called")
+	}
+}
+
+func TestDMap_Eviction_ExpirationStats(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	// Run the eviction workers by hand.
+	c.DMaps.ExpirationScanInterval = time.Hour
+	c.DMaps.ExpirationSampleSize = 50
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	pc := &PutConfig{
+		HasPX: true,
+		PX:    10 * time.Millisecond,
+	}
+	for i := 0; i < 100; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc)
+		require.NoError(t, err)
+	}
+	<-time.After(20 * time.Millisecond)
+
+	lazily := LazilyExpiredTotal.Read()
+	for i := 0; i < 50; i++ {
+		key := testutil.ToKey(i)
+		_, err = dm.Get(ctx, key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+
+		// The read removes the key.
+		part := dm.getPartitionByHKey(dm.HKey(key), partitions.PRIMARY)
+		f, err := dm.loadFragment(part)
+		require.NoError(t, err)
+		_, err = f.storage.Get(dm.HKey(key))
+		require.ErrorIs(t, err, storage.ErrKeyNotFound)
+	}
+	require.Equal(t, int64(50), LazilyExpiredTotal.Read()-lazily)
+
+	actively := ActivelyExpiredTotal.Read()
+	for i := 0; i < 100; i++ {
+		s.evictKeys()
+	}
+	require.Greater(t, ActivelyExpiredTotal.Read(), actively)
+}
+
+func TestDMap_Eviction_ExpirationScanDelay(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.ExpirationScanInterval = 100 * time.Millisecond
+	s := &Service{config: c}
+	require.Equal(t, 100*time.Millisecond, s.expirationScanDelay(time.Second))
+
+	c.DMaps.ExpirationMaxCPUFraction = 0.5
+	require.Equal(t, 100*time.Millisecond, s.expirationScanDelay(10*time.Millisecond))
+	require.Equal(t, time.Second, s.expirationScanDelay(time.Second))
+}
@@ -0,0 +1,150 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// ErrProcessorNotFound is returned when the entry processor is not registered
+// on the partition owner.
+var ErrProcessorNotFound = errors.New("entry processor not found")
+
+// ProcessorEntry is the entry that an EntryProcessor runs against. Value is
+// nil if the key doesn't exist.
+type ProcessorEntry struct {
+	Key    string
+	Value  []byte
+	Exists bool
+
+	// TTL is the expiry time of the entry in milliseconds, zero if the entry
+	// has no TTL. It's kept if the value is updated.
+	TTL int64
+
+	modified bool
+	deleted  bool
+}
+
+// SetValue updates the value of the entry. The new value is stored after the
+// processor returns.
+func (e *ProcessorEntry) SetValue(value []byte) {
+	e.Value = value
+	e.modified = true
+	e.deleted = false
+}
+
+// Delete deletes the entry after the processor returns.
+func (e *ProcessorEntry) Delete() {
+	e.Value = nil
+	e.deleted = true
+	e.modified = false
+}
+
+// EntryProcessor runs against an entry on the partition owner while holding
+// the lock of the key. It returns a result to the caller. The entry is
+// not touched unless the processor calls SetValue or Delete.
+type EntryProcessor func(entry *ProcessorEntry, args []byte) (result []byte, err error)
+
+// RegisterProcessor registers an entry processor on this node. It has to
+// be registered on every member, since it runs on the owner of the key.
+func (s *Service) RegisterProcessor(name string, p EntryProcessor) {
+	s.processorMtx.Lock()
+	defer s.processorMtx.Unlock()
+
+	s.processors[name] = p
+}
+
+func (s *Service) processor(name string) (EntryProcessor, error) {
+	s.processorMtx.RLock()
+	defer s.processorMtx.RUnlock()
+
+	p, ok := s.processors[name]
+	if !ok {
+		return nil, ErrProcessorNotFound
+	}
+	return p, nil
+}
+
+// Execute runs the registered entry processor against the entry on its owner
+// and returns the result.
+func (dm *DMap) Execute(ctx context.Context, key, processor string, args []byte) ([]byte, error) {
+	if err := dm.checkRateLimit(ctx, key, true); err != nil {
+		return nil, err
+	}
+
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.executeOnCluster(ctx, hkey, key, processor, args)
+	}
+
+	cmd := protocol.NewExecute(dm.name, key, processor, args).Command(dm.s.ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	result, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return result, nil
+}
+
+func (dm *DMap) executeOnCluster(ctx context.Context, hkey uint64, key, processor string, args []byte) ([]byte, error) {
+	if err := dm.checkWrite(key); err != nil {
+		return nil, err
+	}
+
+	p, err := dm.s.processor(processor)
+	if err != nil {
+		return nil, err
+	}
+
+	atomicKey := dm.name + key
+	dm.s.locker.Lock(atomicKey)
+	defer dm.releaseAtomicKey(atomicKey, key, dm.name)
+
+	current, err := dm.currentEntry(hkey, key)
+	if err != nil {
+		return nil, err
+	}
+	entry := &ProcessorEntry{Key: key}
+	if current != nil {
+		entry.Value = current.Value()
+		entry.TTL = current.TTL()
+		entry.Exists = true
+	}
+
+	result, err := p(entry, args)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to run entry processor: %s on DMap: %s: %v", processor, dm.name, err)
+		return nil, err
+	}
+
+	switch {
+	case entry.modified:
+		err = dm.storeState(ctx, processor, hkey, key, entry.Value, entry.TTL)
+	case entry.deleted && entry.Exists:
+		err = dm.deleteKey(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
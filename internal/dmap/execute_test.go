@@ -0,0 +1,109 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// appendProcessor appends args to the value and returns the previous value.
+func appendProcessor(entry *ProcessorEntry, args []byte) ([]byte, error) {
+	previous := entry.Value
+	entry.SetValue(append(append([]byte{}, entry.Value...), args...))
+	return previous, nil
+}
+
+func TestDMap_Execute_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	var dmaps []*DMap
+	for _, s := range []*Service{s1, s2} {
+		s.RegisterProcessor("append", appendProcessor)
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+		dmaps = append(dmaps, dm)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		for _, dm := range dmaps {
+			_, err := dm.Execute(ctx, testutil.ToKey(i), "append", []byte(strconv.Itoa(i)))
+			require.NoError(t, err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		gr, err := dmaps[0].Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, []byte(strconv.Itoa(i)+strconv.Itoa(i)), gr.Value())
+	}
+
+	previous, err := dmaps[1].Execute(ctx, testutil.ToKey(1), "append", []byte("x"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("11"), previous)
+}
+
+func TestDMap_Execute_Delete(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	s.RegisterProcessor("pop", func(entry *ProcessorEntry, _ []byte) ([]byte, error) {
+		if !entry.Exists {
+			return nil, nil
+		}
+		value := entry.Value
+		entry.Delete()
+		return value, nil
+	})
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+	err = dm.Put(ctx, "mykey", "myvalue", nil)
+	require.NoError(t, err)
+
+	value, err := dm.Execute(ctx, "mykey", "pop", nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("myvalue"), value)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	value, err = dm.Execute(ctx, "mykey", "pop", nil)
+	require.NoError(t, err)
+	require.Nil(t, value)
+}
+
+func TestDMap_Execute_ProcessorNotFound(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Execute(context.Background(), "mykey", "unknown", nil)
+	require.ErrorIs(t, err, ErrProcessorNotFound)
+}
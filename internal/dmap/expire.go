@@ -17,15 +17,42 @@ package dmap
 import (
 	"context"
 	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
 )
 
 // Expire updates the expiry for the given key. It returns ErrKeyNotFound if the
 // DB does not contain the key. It's thread-safe.
 func (dm *DMap) Expire(ctx context.Context, key string, timeout time.Duration) error {
+	if err := dm.checkNamespace(); err != nil {
+		return err
+	}
+	if err := dm.checkRateLimit(ctx, key, true); err != nil {
+		return err
+	}
+	member := dm.s.primary.PartitionByHKey(dm.HKey(key)).Owner()
+	if !member.CompareByName(dm.s.rt.This()) {
+		// The partition owner updates the expiry of the chunks too.
+		cmd := protocol.NewPExpire(dm.name, key, timeout).Command(ctx)
+		rc := dm.s.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return protocol.ConvertError(err)
+		}
+		return protocol.ConvertError(cmd.Err())
+	}
+
+	if err := dm.expireKey(ctx, key, timeout); err != nil {
+		return err
+	}
+	return dm.expireChunks(ctx, key, timeout)
+}
+
+func (dm *DMap) expireKey(ctx context.Context, key string, timeout time.Duration) error {
 	pc := &PutConfig{
 		OnlyUpdateTTL: true,
 	}
-	e := newEnv(ctx, 0)
+	e := dm.s.newEnv(ctx, 0)
 	e.putConfig = pc
 	e.dmap = dm.name
 	e.key = key
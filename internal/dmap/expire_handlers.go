@@ -16,6 +16,7 @@ package dmap
 
 import (
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
 )
 
@@ -32,16 +33,10 @@ func (s *Service) expireCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	pc := &PutConfig{
-		OnlyUpdateTTL: true,
-	}
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
 
-	e := newEnv(s.ctx, 0)
-	e.putConfig = pc
-	e.dmap = expireCmd.DMap
-	e.key = expireCmd.Key
-	e.timeout = expireCmd.Seconds
-	err = dm.put(e)
+	err = dm.Expire(clientContext(ctx, conn), expireCmd.Key, expireCmd.Seconds)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -62,16 +57,10 @@ func (s *Service) pexpireCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	pc := &PutConfig{
-		OnlyUpdateTTL: true,
-	}
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
 
-	e := newEnv(s.ctx, 0)
-	e.putConfig = pc
-	e.dmap = pexpireCmd.DMap
-	e.key = pexpireCmd.Key
-	e.timeout = pexpireCmd.Milliseconds
-	err = dm.put(e)
+	err = dm.Expire(clientContext(ctx, conn), pexpireCmd.Key, pexpireCmd.Milliseconds)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -19,8 +19,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -111,3 +113,76 @@ func TestDMap_Expire_pexpireCommandHandler(t *testing.T) {
 	_, err = dm.Get(ctx, key)
 	require.ErrorIs(t, err, ErrKeyNotFound)
 }
+
+func TestDMap_StrictExpiry(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.StrictExpiry = true
+		s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+		s.RegisterProcessor("exists", func(entry *ProcessorEntry, _ []byte) ([]byte, error) {
+			if entry.Exists {
+				return []byte("true"), nil
+			}
+			return []byte("false"), nil
+		})
+		return s
+	}
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	allKeys := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+		allKeys[testutil.ToKey(i)] = false
+	}
+
+	// Simulate the expired entries that are not removed by the eviction
+	// workers yet, on the owners and the backups.
+	expired := time.Now().Add(-time.Second).UnixMilli()
+	for key := range allKeys {
+		hkey := partitions.HKey("mydmap", key)
+		for _, dm := range []*DMap{dm1, dm2} {
+			for _, kind := range []partitions.Kind{partitions.PRIMARY, partitions.BACKUP} {
+				part := dm.getPartitionByHKey(hkey, kind)
+				if !dm.s.checkOwnership(part) {
+					continue
+				}
+				f, err := dm.loadOrCreateFragment(part)
+				require.NoError(t, err)
+				f.Lock()
+				e, err := f.storage.Get(hkey)
+				require.NoError(t, err)
+				e.SetTTL(expired)
+				err = f.storage.UpdateTTL(hkey, e)
+				f.Unlock()
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	for key := range allKeys {
+		for _, dm := range []*DMap{dm1, dm2} {
+			_, err = dm.Get(ctx, key)
+			require.ErrorIs(t, err, ErrKeyNotFound)
+
+			result, err := dm.Execute(ctx, key, "exists", nil)
+			require.NoError(t, err)
+			require.Equal(t, "false", string(result))
+		}
+	}
+
+	for _, sc := range []*ScanConfig{nil, {Replica: true}} {
+		require.Equal(t, 0, testScanIterator(t, s1, allKeys, sc))
+		require.Equal(t, 0, testScanIterator(t, s2, allKeys, sc))
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/routingtable"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// exportMagic is the first field of the header of an export stream.
+	exportMagic = "OLRIC.DMAP"
+
+	// ExportVersion is the version of the export format written by Export.
+	ExportVersion = 1
+
+	// exportPageSize is the size of a page of dm.export if
+	// config.Config.MaxResponseSize is not set.
+	exportPageSize = 1 << 20
+
+	// exportScanCount is the number of the entries that are read from the
+	// storage engine at once.
+	exportScanCount = 100
+)
+
+// ErrInvalidExport is returned by Import if the stream is not written by
+// Export or its version is not supported.
+var ErrInvalidExport = errors.New("invalid export")
+
+// exportHeader starts an export stream, it's followed by the ExportEntry items.
+type exportHeader struct {
+	Magic   string
+	Version int
+	DMap    string
+}
+
+// ExportEntry is an entry of an export stream. TTL and Timestamp are Unix
+// times, in milliseconds and nanoseconds respectively, the TTL is zero if the
+// entry doesn't expire.
+type ExportEntry struct {
+	Key       string
+	Value     []byte
+	TTL       int64
+	Timestamp int64
+	Tags      []string
+}
+
+// exportOnFragment returns the entries of the fragment, starting from the
+// storage cursor. It stops before the size of the entries exceeds maxSize and
+// returns the cursor of the next entry, zero if the fragment is completed. The
+// chunked values are read from the cluster after the fragment is released.
+func (dm *DMap) exportOnFragment(ctx context.Context, f *fragment, cursor uint64, maxSize int) ([]ExportEntry, uint64, error) {
+	var result []ExportEntry
+	var chunked []string
+	var size int
+	var err error
+	collect := func(e storage.Entry) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if isKeyExpired(e.TTL()) || isChunkKey(e.Key()) {
+			return true
+		}
+		if e.Codec() == codec.Chunked {
+			chunked = append(chunked, e.Key())
+			return true
+		}
+		// Cut the page before it exceeds the size limit, the cursor points
+		// to this entry then.
+		if len(result) > 0 && size+len(e.Key())+len(e.Value()) > maxSize {
+			return false
+		}
+		// Copy the entry, the storage engine may reuse the underlying memory.
+		entry := dm.engine.NewEntry()
+		entry.Decode(e.Encode())
+		entry, err = dm.readEntry(entry)
+		if err != nil {
+			return false
+		}
+		size += len(entry.Key()) + len(entry.Value())
+		result = append(result, ExportEntry{
+			Key:       entry.Key(),
+			Value:     entry.Value(),
+			TTL:       entry.TTL(),
+			Timestamp: entry.Timestamp(),
+			Tags:      append([]string(nil), f.tags.tags[dm.HKey(entry.Key())]...),
+		})
+		return true
+	}
+
+	f.RLock()
+	cursor, scanErr := f.storage.Scan(cursor, exportScanCount, collect)
+	f.RUnlock()
+	if scanErr != nil {
+		return nil, 0, scanErr
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = ctx.Err(); err != nil {
+		CanceledOperationsTotal.Increase(1)
+		return nil, 0, err
+	}
+
+	for _, key := range chunked {
+		entry, err := dm.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, ExportEntry{
+			Key:       key,
+			Value:     entry.Value(),
+			TTL:       entry.TTL(),
+			Timestamp: entry.Timestamp(),
+		})
+	}
+	return result, cursor, nil
+}
+
+// exportLocal returns the entries of the partitions owned by this member. It
+// starts from the storage cursor in the fragment of the given partition, and
+// stops after the page reaches config.Config.MaxResponseSize, possibly in the
+// middle of a fragment. It returns the partition ID and the storage cursor of
+// the next page, the partition ID is config.Config.PartitionCount after the
+// last page.
+//
+// It returns routingtable.ErrStaleRoutingTable if the routing table isn't the
+// one with the given signature, the partitions may have moved since the
+// export is started.
+func (dm *DMap) exportLocal(ctx context.Context, partID, cursor, signature uint64) ([]ExportEntry, uint64, uint64, error) {
+	if dm.s.rt.Signature() != signature {
+		return nil, 0, 0, routingtable.ErrStaleRoutingTable
+	}
+
+	pageSize := dm.s.config.MaxResponseSize
+	if pageSize <= 0 {
+		pageSize = exportPageSize
+	}
+
+	var result []ExportEntry
+	var size int
+	for ; partID < dm.s.config.PartitionCount; partID, cursor = partID+1, 0 {
+		part := dm.s.primary.PartitionByID(partID)
+		if part.OwnerCount() == 0 || !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		for {
+			entries, next, err := dm.exportOnFragment(ctx, f, cursor, pageSize-size)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			for _, e := range entries {
+				size += len(e.Key) + len(e.Value)
+			}
+			result = append(result, entries...)
+			if next == 0 {
+				break
+			}
+			if size >= pageSize {
+				return result, partID, next, nil
+			}
+			cursor = next
+		}
+	}
+	return result, partID, 0, nil
+}
+
+// exportOnMember returns a page of the entries owned by the given member.
+func (dm *DMap) exportOnMember(ctx context.Context, member discovery.Member, partID, cursor, signature uint64) ([]ExportEntry, uint64, uint64, error) {
+	if member.CompareByID(dm.s.rt.This()) {
+		return dm.exportLocal(ctx, partID, cursor, signature)
+	}
+
+	cmd := protocol.NewExport(dm.name, partID, cursor, signature).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, 0, 0, protocol.ConvertError(err)
+	}
+	res, err := cmd.Result()
+	if err != nil {
+		return nil, 0, 0, protocol.ConvertError(err)
+	}
+	if len(res) != 3 {
+		return nil, 0, 0, fmt.Errorf("invalid export response: %v", res)
+	}
+
+	var next [2]uint64
+	for i := range next {
+		raw, ok := res[i].(string)
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("invalid export cursor: %v", res[i])
+		}
+		if next[i], err = strconv.ParseUint(raw, 10, 64); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	items, ok := res[2].([]interface{})
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("invalid export entries: %v", res[2])
+	}
+	result := make([]ExportEntry, 0, len(items))
+	for _, item := range items {
+		raw, ok := item.(string)
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("invalid export entry: %v", item)
+		}
+		var e ExportEntry
+		if err = msgpack.Unmarshal([]byte(raw), &e); err != nil {
+			return nil, 0, 0, err
+		}
+		result = append(result, e)
+	}
+	return result, next[0], next[1], nil
+}
+
+// Export writes the entries of the DMap to w, and returns the number of the
+// written entries. The stream starts with a header that contains the format
+// version, and every entry keeps its TTL, timestamp and tags. The partition
+// owners send their entries in pages, the writes during the export may be
+// missed.
+//
+// The export is pinned to the routing table that it's started with. It
+// returns routingtable.ErrStaleRoutingTable if the routing table changes
+// before it's completed, the partitions may have moved and some entries may
+// be missed or written twice.
+func (dm *DMap) Export(ctx context.Context, w io.Writer) (int, error) {
+	enc := msgpack.NewEncoder(w)
+	err := enc.Encode(&exportHeader{
+		Magic:   exportMagic,
+		Version: ExportVersion,
+		DMap:    dm.name,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	signature := dm.s.rt.Signature()
+	var total int
+	for _, member := range dm.s.rt.Discovery().GetMembers() {
+		if member.Analytics {
+			// Analytics replicas don't own any partition.
+			continue
+		}
+		var partID, cursor uint64
+		for partID < dm.s.config.PartitionCount {
+			var entries []ExportEntry
+			entries, partID, cursor, err = dm.exportOnMember(ctx, member, partID, cursor, signature)
+			if err != nil {
+				return total, err
+			}
+			for i := range entries {
+				if err = enc.Encode(&entries[i]); err != nil {
+					return total, err
+				}
+				total++
+			}
+		}
+	}
+	if dm.s.rt.Signature() != signature {
+		return total, routingtable.ErrStaleRoutingTable
+	}
+	return total, nil
+}
+
+// Import stores the entries of a stream written by Export, and returns the
+// number of the stored entries. The entries overwrite the existing keys and
+// keep their TTLs, timestamps and tags, the expired ones are skipped. The
+// stream may be exported from a DMap with a different name.
+//
+// The entries are sent to their partition owners in batches, like the entries
+// of Warmup. They are not propagated to the Writer of the DMap, and the LRU
+// eviction and the quotas are not applied.
+func (dm *DMap) Import(ctx context.Context, r io.Reader) (int, error) {
+	dec := msgpack.NewDecoder(r)
+	var header exportHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if header.Magic != exportMagic {
+		return 0, fmt.Errorf("%w: unknown format", ErrInvalidExport)
+	}
+	if header.Version != ExportVersion {
+		return 0, fmt.Errorf("%w: unsupported version: %d", ErrInvalidExport, header.Version)
+	}
+
+	newBatch := func() *protocol.Warmup {
+		return protocol.NewImport(dm.name)
+	}
+	return dm.sendInBatches(ctx, newBatch, func(ctx context.Context, add batchAdder) (int, error) {
+		return dm.readExport(ctx, dec, add)
+	})
+}
+
+// readExport reads the entries of an export stream and adds them to the
+// batches. The chunked values are stored one by one, it returns their count.
+func (dm *DMap) readExport(ctx context.Context, dec *msgpack.Decoder, add batchAdder) (int, error) {
+	var count int
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		var entry ExportEntry
+		err := dec.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		if isKeyExpired(entry.TTL) {
+			continue
+		}
+
+		e := dm.s.newEnv(ctx, entry.Timestamp)
+		e.dmap = dm.name
+		e.key = entry.Key
+		e.value = entry.Value
+		if err = dm.checkWrite(e.key); err != nil {
+			return count, err
+		}
+		if err = dm.checkValueSize(e); err != nil {
+			return count, err
+		}
+		if dm.chunkingEnabled(e) && len(e.value) > dm.config().chunkSize {
+			pc := &PutConfig{
+				HasTimestamp: true,
+				Timestamp:    entry.Timestamp,
+				Tags:         entry.Tags,
+			}
+			if entry.TTL != 0 {
+				pc.HasPXAT = true
+				pc.PXAT = time.Duration(entry.TTL) * time.Millisecond
+			}
+			if err = dm.Put(ctx, entry.Key, entry.Value, pc); err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
+		add(entry.Key, func(batch *protocol.Warmup) {
+			batch.AddImported(entry.Key, entry.Value, entry.TTL, entry.Timestamp, entry.Tags)
+		})
+	}
+}
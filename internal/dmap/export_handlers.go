@@ -0,0 +1,69 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) exportCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	exportCmd, err := protocol.ParseExportCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getDMap(exportCmd.DMap)
+	if errors.Is(err, ErrDMapNotFound) {
+		// The DMap is not used on this member, but it may own some entries.
+		dm, err = s.NewTempDMap(exportCmd.DMap)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	entries, partID, cursor, err := dm.exportLocal(ctx, exportCmd.PartID, exportCmd.Cursor, exportCmd.Signature)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	encoded := make([][]byte, 0, len(entries))
+	for i := range entries {
+		data, err := msgpack.Marshal(&entries[i])
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		encoded = append(encoded, data)
+	}
+
+	conn.WriteArray(3)
+	conn.WriteBulkString(strconv.FormatUint(partID, 10))
+	conn.WriteBulkString(strconv.FormatUint(cursor, 10))
+	conn.WriteArray(len(encoded))
+	for _, data := range encoded {
+		conn.WriteBulk(data)
+	}
+}
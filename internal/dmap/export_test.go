@@ -0,0 +1,173 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/routingtable"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Export_Import(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		// Export the entries in many pages.
+		c.MaxResponseSize = 100
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	newDMap := func(name string) *DMap {
+		dm, err := s1.NewDMap(name)
+		require.NoError(t, err)
+		_, err = s2.NewDMap(name)
+		require.NoError(t, err)
+		return dm
+	}
+	source := newDMap("mydmap")
+	target := newDMap("mydmap-copy")
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		pc := &PutConfig{}
+		if i%2 == 0 {
+			pc.HasEX = true
+			pc.EX = time.Hour
+			pc.Tags = []string{"even"}
+		}
+		err := source.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc)
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	count, err := source.Export(ctx, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+
+	// The imported entries overwrite the existing keys.
+	err = target.Put(ctx, testutil.ToKey(1), "stale", nil)
+	require.NoError(t, err)
+
+	count, err = target.Import(ctx, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+
+	for i := 0; i < 100; i++ {
+		expected, err := source.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		e, err := target.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), e.Value())
+		require.Equal(t, expected.TTL(), e.TTL())
+		require.Equal(t, expected.Timestamp(), e.Timestamp())
+	}
+
+	deleted, err := target.DeleteByTag(ctx, "even")
+	require.NoError(t, err)
+	require.Equal(t, 50, deleted)
+}
+
+func TestDMap_Export_Pages(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.PartitionCount = 1
+	c.MaxResponseSize = 100
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	// The single fragment is exported in many pages.
+	signature := s.rt.Signature()
+	keys := make(map[string]struct{})
+	var partID, cursor uint64
+	var pages int
+	for partID < c.PartitionCount {
+		var entries []ExportEntry
+		entries, partID, cursor, err = dm.exportLocal(ctx, partID, cursor, signature)
+		require.NoError(t, err)
+		for _, e := range entries {
+			require.NotContains(t, keys, e.Key)
+			keys[e.Key] = struct{}{}
+		}
+		pages++
+	}
+	require.Len(t, keys, 100)
+	require.Greater(t, pages, 1)
+
+	_, _, _, err = dm.exportLocal(ctx, 0, 0, signature+1)
+	require.ErrorIs(t, err, routingtable.ErrStaleRoutingTable)
+}
+
+func TestDMap_Import_Invalid(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Import(context.Background(), bytes.NewBufferString("foobar"))
+	require.ErrorIs(t, err, ErrInvalidExport)
+}
+
+func TestDMap_Export_Chunked(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.DMaps.ChunkSize = 100
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	value := bytes.Repeat([]byte("a"), 1000)
+	err = dm.Put(ctx, "mykey", value, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	count, err := dm.Export(ctx, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+
+	count, err = dm.Import(ctx, &buf)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	e, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, value, e.Value())
+}
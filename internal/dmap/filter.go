@@ -0,0 +1,293 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrInvalidFilter is returned when a query filter cannot be parsed.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type condition struct {
+	path  []string
+	op    string
+	value interface{}
+}
+
+// Filter is a parsed query filter. A filter is a list of conditions that are
+// joined with AND:
+//
+//	age >= 30 AND address.city = "Berlin" AND active = true
+//
+// A condition compares a field of the value with a literal. Fields of the
+// nested objects are separated by dots. The operators are =, !=, <, <=, >
+// and >=. The literals are numbers, double-quoted strings, true, false and
+// null. Booleans and null can only be compared with = and !=.
+//
+// The values have to be JSON or MessagePack encoded objects. An entry doesn't
+// match if its value is not an object, the field is missing or the types of
+// the field and the literal are different.
+type Filter struct {
+	conditions []condition
+}
+
+var filterOperators = []string{"<=", ">=", "!=", "=", "<", ">"}
+
+// ParseFilter parses the given filter expression.
+func ParseFilter(expr string) (*Filter, error) {
+	f := &Filter{}
+	rest := strings.TrimSpace(expr)
+	if rest == "" {
+		return nil, fmt.Errorf("%w: empty expression", ErrInvalidFilter)
+	}
+
+	for {
+		c, tail, err := parseCondition(rest)
+		if err != nil {
+			return nil, err
+		}
+		f.conditions = append(f.conditions, c)
+
+		tail = strings.TrimSpace(tail)
+		if tail == "" {
+			return f, nil
+		}
+		if len(tail) < 4 || !strings.EqualFold(tail[:4], "and ") {
+			return nil, fmt.Errorf("%w: expected AND: %s", ErrInvalidFilter, tail)
+		}
+		rest = strings.TrimSpace(tail[4:])
+	}
+}
+
+func parseCondition(s string) (condition, string, error) {
+	var c condition
+
+	end := strings.IndexAny(s, " \t=!<>")
+	if end <= 0 {
+		return c, "", fmt.Errorf("%w: expected a field: %s", ErrInvalidFilter, s)
+	}
+	field := s[:end]
+	c.path = strings.Split(field, ".")
+	for _, name := range c.path {
+		if name == "" {
+			return c, "", fmt.Errorf("%w: invalid field: %s", ErrInvalidFilter, field)
+		}
+	}
+
+	s = strings.TrimSpace(s[end:])
+	for _, op := range filterOperators {
+		if strings.HasPrefix(s, op) {
+			c.op = op
+			break
+		}
+	}
+	if c.op == "" {
+		return c, "", fmt.Errorf("%w: expected an operator: %s", ErrInvalidFilter, s)
+	}
+
+	s = strings.TrimSpace(s[len(c.op):])
+	value, tail, err := parseLiteral(s)
+	if err != nil {
+		return c, "", err
+	}
+	switch value.(type) {
+	case float64, string:
+	default:
+		if c.op != "=" && c.op != "!=" {
+			return c, "", fmt.Errorf("%w: %s cannot be used with %v", ErrInvalidFilter, c.op, value)
+		}
+	}
+	c.value = value
+	return c, tail, nil
+}
+
+func parseLiteral(s string) (interface{}, string, error) {
+	if strings.HasPrefix(s, "\"") {
+		// Find the closing quote, skipping the escaped characters.
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				var value string
+				if err := json.Unmarshal([]byte(s[:i+1]), &value); err != nil {
+					return nil, "", fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return nil, "", fmt.Errorf("%w: unterminated string: %s", ErrInvalidFilter, s)
+	}
+
+	end := strings.IndexAny(s, " \t")
+	if end == -1 {
+		end = len(s)
+	}
+	raw, tail := s[:end], s[end:]
+	switch raw {
+	case "":
+		return nil, "", fmt.Errorf("%w: expected a value", ErrInvalidFilter)
+	case "true":
+		return true, tail, nil
+	case "false":
+		return false, tail, nil
+	case "null":
+		return nil, tail, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: invalid value: %s", ErrInvalidFilter, raw)
+	}
+	return value, tail, nil
+}
+
+// decodeDocument decodes a JSON or MessagePack encoded object.
+func decodeDocument(value []byte) (map[string]interface{}, bool) {
+	var doc map[string]interface{}
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, false
+		}
+		return doc, true
+	}
+	if err := msgpack.Unmarshal(value, &doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+func lookupField(doc map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, name := range path {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func compare(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func (c *condition) match(doc map[string]interface{}) bool {
+	field, ok := lookupField(doc, c.path)
+	if !ok {
+		return false
+	}
+
+	switch expected := c.value.(type) {
+	case float64:
+		actual, ok := toFloat64(field)
+		if !ok {
+			return false
+		}
+		switch {
+		case actual < expected:
+			return compare(c.op, -1)
+		case actual > expected:
+			return compare(c.op, 1)
+		default:
+			return compare(c.op, 0)
+		}
+	case string:
+		actual, ok := field.(string)
+		if !ok {
+			return false
+		}
+		return compare(c.op, strings.Compare(actual, expected))
+	case bool:
+		actual, ok := field.(bool)
+		if !ok {
+			return false
+		}
+		return (actual == expected) == (c.op == "=")
+	default:
+		return (field == nil) == (c.op == "=")
+	}
+}
+
+// Match returns true if the value satisfies all conditions of the filter.
+func (f *Filter) Match(value []byte) bool {
+	doc, ok := decodeDocument(value)
+	if !ok {
+		return false
+	}
+	for i := range f.conditions {
+		if !f.conditions[i].match(doc) {
+			return false
+		}
+	}
+	return true
+}
@@ -34,8 +34,66 @@ type fragment struct {
 
 	service *Service
 	storage storage.Engine
+	tags    *tagIndex
+	access  *accessLog
+	hotKeys *hotKeyTracker
 	ctx     context.Context
 	cancel  context.CancelFunc
+
+	// usage and nsUsage are the usage counters of the DMap and its namespace
+	// if it's a primary fragment. length and inuse are the stats of the
+	// storage counted in them.
+	usage   *usageCounter
+	nsUsage *usageCounter
+	length  int
+	inuse   int
+}
+
+// Unlock updates the usage counters with the changes made under the write
+// lock and unlocks the fragment.
+func (f *fragment) Unlock() {
+	f.updateUsage()
+	f.RWMutex.Unlock()
+}
+
+// updateUsage adds the changes of the storage since the last update to the
+// usage counters. The fragment lock has to be held by the caller.
+func (f *fragment) updateUsage() {
+	if f.usage == nil {
+		return
+	}
+	st := f.storage.Stats()
+	if st.Length == f.length && st.Inuse == f.inuse {
+		return
+	}
+	f.addUsage(st.Length-f.length, st.Inuse-f.inuse)
+	f.length, f.inuse = st.Length, st.Inuse
+}
+
+func (f *fragment) addUsage(keys, inuse int) {
+	f.usage.add(keys, inuse)
+	if f.nsUsage != nil {
+		f.nsUsage.add(keys, inuse)
+	}
+}
+
+// attachUsage starts counting the fragment in the given usage counters. The
+// namespace counter is nil if the DMap doesn't belong to a namespace. The
+// fragment lock has to be held by the caller.
+func (f *fragment) attachUsage(usage, nsUsage *usageCounter) {
+	st := f.storage.Stats()
+	f.usage, f.nsUsage, f.length, f.inuse = usage, nsUsage, st.Length, st.Inuse
+	f.addUsage(st.Length, st.Inuse)
+}
+
+// detachUsage removes the fragment from its usage counters before it's taken
+// out of its partition. The fragment lock has to be held by the caller.
+func (f *fragment) detachUsage() {
+	if f.usage == nil {
+		return
+	}
+	f.addUsage(-f.length, -f.inuse)
+	f.usage, f.nsUsage, f.length, f.inuse = nil, nil, 0, 0
 }
 
 func (f *fragment) Stats() storage.Stats {
@@ -74,23 +132,124 @@ func (f *fragment) Name() string {
 }
 
 func (f *fragment) Move(part *partitions.Partition, name string, owners []discovery.Member) error {
+	keys, err := f.move(part, name, owners)
+	if err != nil {
+		return err
+	}
+	f.service.notifyKeysLeft(part, name, owners, keys)
+	return nil
+}
+
+// move sends a table of the fragment to the owners and drops it. It returns
+// the moved keys if there is a KeyMigrationListener.
+func (f *fragment) move(part *partitions.Partition, name string, owners []discovery.Member) ([]string, error) {
 	f.Lock()
 	defer f.Unlock()
 
 	i := f.storage.TransferIterator()
 	if !i.Next() {
-		return nil
+		return nil, nil
 	}
 
 	payload, index, err := i.Export()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if f.service.hasKeyMigrationListeners() {
+		keys, err = exportedKeys(f.storage, payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var cl *changelogPack
+	if part.Kind() == partitions.PRIMARY {
+		cl = f.service.changelogSnapshot(strings.TrimPrefix(name, "dmap."), part.ID())
+	}
+	if err := f.transfer(part, name, payload, f.tags.tags, cl, owners); err != nil {
+		return nil, err
+	}
+	if cl != nil {
+		f.service.dropChangelog(strings.TrimPrefix(name, "dmap."), part.ID())
+	}
+	if err := i.Drop(index); err != nil {
+		return nil, err
+	}
+	for hkey := range f.tags.tags {
+		if !f.storage.Check(hkey) {
+			// The entry has been moved with the dropped table.
+			f.tags.delete(hkey)
+		}
+	}
+	return keys, nil
+}
+
+// Copy sends the fragment to the owners as a fragment of the given partition.
+// The local data is left untouched. It's used to re-create the backups that
+// are lost with a dead member.
+func (f *fragment) Copy(part *partitions.Partition, name string, owners []discovery.Member) error {
+	f.RLock()
+	snapshot, err := f.storage.Fork(nil)
+	if err != nil {
+		f.RUnlock()
+		return err
+	}
+	tags := make(map[uint64][]string, len(f.tags.tags))
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		if t, ok := f.tags.tags[hkey]; ok {
+			tags[hkey] = t
+		}
+		err = snapshot.Put(hkey, e)
+		return err == nil
+	})
+	f.RUnlock()
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err := snapshot.Close(); err != nil {
+			f.service.log.V(3).Printf("[ERROR] Failed to close the snapshot of %s: %v", name, err)
+		}
+	}()
+
+	i := snapshot.TransferIterator()
+	for i.Next() {
+		payload, index, err := i.Export()
+		if err != nil {
+			return err
+		}
+		if err := f.transfer(part, name, payload, tags, nil, owners); err != nil {
+			return err
+		}
+		if err := i.Drop(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transfer sends an exported table to the owners with the moveFragment command,
+// along with the tags of its entries.
+func (f *fragment) transfer(part *partitions.Partition, name string, payload []byte,
+	tags map[uint64][]string, cl *changelogPack, owners []discovery.Member) error {
 	fp := &fragmentPack{
-		PartID:  part.ID(),
-		Kind:    part.Kind(),
-		Name:    strings.TrimPrefix(name, "dmap."),
-		Payload: payload,
+		PartID:    part.ID(),
+		Kind:      part.Kind(),
+		Name:      strings.TrimPrefix(name, "dmap."),
+		Payload:   payload,
+		ChangeLog: cl,
+	}
+	if len(tags) != 0 {
+		fp.Tags = make(map[uint64][]string)
+		err := f.storage.Import(payload, func(hkey uint64, _ storage.Entry) error {
+			if t, ok := tags[hkey]; ok {
+				fp.Tags[hkey] = t
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 	}
 	value, err := msgpack.Marshal(fp)
 	if err != nil {
@@ -98,7 +257,7 @@ func (f *fragment) Move(part *partitions.Partition, name string, owners []discov
 	}
 
 	for _, owner := range owners {
-		if f.service.config.EnableClusterEventsChannel {
+		if f.service.config.EnableClusterEventsChannel || f.service.eventBus.HasSubscribers() {
 			e := &events.FragmentMigrationEvent{
 				Kind:          events.KindFragmentMigrationEvent,
 				Source:        f.service.rt.This().String(),
@@ -110,8 +269,11 @@ func (f *fragment) Move(part *partitions.Partition, name string, owners []discov
 				IsBackup:      part.Kind() == partitions.BACKUP,
 				Timestamp:     time.Now().UnixNano(),
 			}
-			f.service.wg.Add(1)
-			go f.service.publishEvent(e)
+			f.service.eventBus.Publish(e)
+			if f.service.config.EnableClusterEventsChannel {
+				f.service.wg.Add(1)
+				go f.service.publishEvent(e)
+			}
 		}
 
 		cmd := protocol.NewMoveFragment(value).Command(f.service.ctx)
@@ -124,13 +286,11 @@ func (f *fragment) Move(part *partitions.Partition, name string, owners []discov
 			return err
 		}
 	}
-
-	return i.Drop(index)
+	return nil
 }
 
 func (dm *DMap) newFragment() (*fragment, error) {
-	c := storage.NewConfig(dm.config.engine.Config)
-	engine, err := dm.engine.Fork(c)
+	engine, err := dm.engine.Fork(dm.engineConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -142,12 +302,18 @@ func (dm *DMap) newFragment() (*fragment, error) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	return &fragment{
+	f := &fragment{
 		service: dm.s,
 		storage: engine,
+		tags:    newTagIndex(),
+		access:  newAccessLog(),
 		ctx:     ctx,
 		cancel:  cancel,
-	}, nil
+	}
+	if dm.config() != nil && dm.config().hotKeysWindow > 0 {
+		f.hotKeys = newHotKeyTracker(dm.config().hotKeysWindow)
+	}
+	return f, nil
 }
 
 func (dm *DMap) loadOrCreateFragment(part *partitions.Partition) (*fragment, error) {
@@ -166,6 +332,9 @@ func (dm *DMap) loadOrCreateFragment(part *partitions.Partition) (*fragment, err
 		return nil, err
 	}
 
+	if part.Kind() == partitions.PRIMARY {
+		dm.s.attachUsage(f, dm.name)
+	}
 	part.Map().Store(dm.fragmentName, f)
 	return f, nil
 }
@@ -8,10 +8,15 @@ import (
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
 )
 
 func (dm *DMap) Function(ctx context.Context, key string, function string, arg []byte) ([]byte, error) {
-	hkey := partitions.HKey(dm.name, key)
+	if err := dm.checkRateLimit(ctx, key, true); err != nil {
+		return nil, err
+	}
+
+	hkey := dm.HKey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 
 	// We are on the partition owner. So we can call the function directly.
@@ -36,58 +41,115 @@ func (dm *DMap) Function(ctx context.Context, key string, function string, arg [
 }
 
 func (dm *DMap) functionOnCluster(ctx context.Context, dmap string, hkey uint64, key string, function string, arg []byte) ([]byte, error) {
-	f, ok := dm.config.functions[function]
+	if err := dm.checkWrite(key); err != nil {
+		return nil, err
+	}
+	defer dm.observeSLO(time.Now())
+
+	f, ok := dm.config().functions[function]
 	if !ok {
 		return nil, fmt.Errorf("function: %s is not registered", function)
 	}
 
+	return dm.atomicUpdate(ctx, dmap, hkey, key, function, func(currentState []byte) ([]byte, []byte, error) {
+		return f(key, currentState, arg)
+	})
+}
+
+var (
+	// errSkipUpdate is returned by the update function of atomicUpdate to
+	// leave the key as it is.
+	errSkipUpdate = errors.New("skip update")
+
+	// errDeleteKey is returned by the update function of atomicUpdate to
+	// delete the key.
+	errDeleteKey = errors.New("delete key")
+)
+
+// atomicUpdate calls update with the current value of the key and stores the
+// new value while holding the fine-grained lock of the key. update can return
+// errSkipUpdate or errDeleteKey instead of a new value. It has to be called
+// on the partition owner. label is used to identify the operation in the logs.
+func (dm *DMap) atomicUpdate(ctx context.Context, dmap string, hkey uint64, key, label string,
+	update func(currentState []byte) ([]byte, []byte, error)) ([]byte, error) {
 	var currentState []byte
 	var ttl int64
-	var err error
 
 	atomicKey := dmap + key
 	dm.s.locker.Lock(atomicKey)
-	defer func() {
-		err = dm.s.locker.Unlock(atomicKey)
-		if err != nil {
-			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dmap, err)
+	defer dm.releaseAtomicKey(atomicKey, key, dmap)
+
+	entry, err := dm.currentEntry(hkey, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		currentState = entry.Value()
+		ttl = entry.TTL()
+	}
+
+	newState, result, err := update(currentState)
+	if errors.Is(err, errSkipUpdate) {
+		return result, nil
+	}
+	if errors.Is(err, errDeleteKey) {
+		if entry == nil {
+			return result, nil
 		}
-	}()
+		return result, dm.deleteOnOwner(ctx, key)
+	}
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to call function: %s on DMap: %s: %v", label, dmap, err)
+		return nil, err
+	}
 
-	// first lookup on this node
-	// if not found, the get on the cluster
+	if err = dm.storeState(ctx, label, hkey, key, newState, ttl); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (dm *DMap) releaseAtomicKey(atomicKey, key, dmap string) {
+	err := dm.s.locker.Unlock(atomicKey)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dmap, err)
+	}
+}
+
+// currentEntry returns the decoded entry of the key, or nil if the key
+// doesn't exist. It looks up this node first, then the cluster.
+func (dm *DMap) currentEntry(hkey uint64, key string) (storage.Entry, error) {
+	var err error
 	localVersion := dm.lookupOnThisNode(hkey, key)
 	entry := localVersion.entry
+	if entry != nil && dm.strictlyExpired(entry.TTL()) {
+		// Don't resurrect the expired entry with the new value.
+		return nil, nil
+	}
 	if entry == nil {
 		entry, err = dm.getOnCluster(hkey, key)
 		if err != nil {
 			if !errors.Is(err, ErrKeyNotFound) {
-				dm.s.log.V(3).Printf("[ERROR] Failed to get key: %s on DMap: %s: %v", key, dmap, err)
+				dm.s.log.V(3).Printf("[ERROR] Failed to get key: %s on DMap: %s: %v", key, dm.name, err)
 				return nil, err
 			}
+			return nil, nil
 		}
 	}
+	return dm.readEntry(entry)
+}
 
-	if entry != nil {
-		currentState = entry.Value()
-		ttl = entry.TTL()
-	}
-
-	newState, result, err := f(key, currentState, arg)
-	if err != nil {
-		dm.s.log.V(3).Printf("[ERROR] Failed to call function: %s on DMap: %s: %v", function, dmap, err)
-		return nil, err
-	}
-
+// storeState puts the new value of the key on the cluster and keeps its TTL.
+func (dm *DMap) storeState(ctx context.Context, label string, hkey uint64, key string, value []byte, ttl int64) error {
 	p := &env{
 		ctx:       ctx,
-		function:  function,
+		function:  label,
 		dmap:      dm.name,
 		key:       key,
 		hkey:      hkey,
-		timestamp: time.Now().UnixNano(),
+		timestamp: dm.s.clock.Now(),
 		kind:      partitions.PRIMARY,
-		value:     newState,
+		value:     value,
 		putConfig: &PutConfig{},
 	}
 	if ttl != 0 {
@@ -96,11 +158,10 @@ func (dm *DMap) functionOnCluster(ctx context.Context, dmap string, hkey uint64,
 		p.putConfig.HasPX = true
 		p.putConfig.PX = timeout
 	}
-	err = dm.putOnCluster(p)
+	err := dm.putOnCluster(p)
 	if err != nil {
-		dm.s.log.V(3).Printf("[ERROR] Failed to put the entry after function call: %v", err)
-		return nil, err
+		dm.s.log.V(3).Printf("[ERROR] Failed to put the entry after %s: %v", label, err)
+		return err
 	}
-
-	return result, nil
+	return nil
 }
@@ -2,6 +2,7 @@ package dmap
 
 import (
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
 )
 
@@ -18,7 +19,10 @@ func (s *Service) functionCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	latest, err := dm.Function(s.ctx, functionCmd.Key, functionCmd.Function, functionCmd.Arg)
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	latest, err := dm.Function(clientContext(ctx, conn), functionCmd.Key, functionCmd.Function, functionCmd.Arg)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -18,9 +18,11 @@ import (
 	"context"
 	"errors"
 	"sort"
+	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/stats"
@@ -29,10 +31,10 @@ import (
 
 // Entry is a DMap entry with its metadata.
 type Entry struct {
-	Key       string
-	Value     interface{}
-	TTL       int64
-	Timestamp int64
+	storage.Entry
+
+	// Member is the partition owner that served the read.
+	Member string
 }
 
 var (
@@ -46,6 +48,30 @@ var (
 	EvictedTotal = stats.NewInt64Counter()
 )
 
+type readStatsKey struct{}
+
+// WithoutReadStats returns a copy of ctx whose reads are not counted in
+// GetHits and GetMisses. The caller counts the result with CountRead, e.g.
+// a hedged read sends two requests for a single Get call.
+func WithoutReadStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readStatsKey{}, true)
+}
+
+// CountRead counts the result of a read in GetHits or GetMisses.
+func CountRead(err error) {
+	switch {
+	case err == nil:
+		GetHits.Increase(1)
+	case errors.Is(err, ErrKeyNotFound):
+		GetMisses.Increase(1)
+	}
+}
+
+func countsReads(ctx context.Context) bool {
+	skip, _ := ctx.Value(readStatsKey{}).(bool)
+	return !skip
+}
+
 // ErrReadQuorum means that read quorum cannot be reached to operate.
 var ErrReadQuorum = errors.New("read quorum cannot be reached")
 
@@ -76,6 +102,7 @@ func (dm *DMap) getOnFragment(e *env) (storage.Entry, error) {
 	}
 
 	if isKeyExpired(entry.TTL()) {
+		// The partition owner removes the key, see expireLazily.
 		return nil, ErrKeyNotFound
 	}
 	return entry, nil
@@ -231,7 +258,7 @@ func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
 
 func (dm *DMap) readRepair(winner *version, versions []*version) {
 	for _, version := range versions {
-		if version.entry != nil && winner.entry.Timestamp() == version.entry.Timestamp() {
+		if version.entry != nil && sameVersion(winner.entry, version.entry) {
 			continue
 		}
 
@@ -239,7 +266,7 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 		tmp := *version.host
 		if tmp.CompareByID(dm.s.rt.This()) {
 			for _, kind := range []partitions.Kind{partitions.PRIMARY, partitions.BACKUP} {
-				hkey := partitions.HKey(dm.name, winner.entry.Key())
+				hkey := dm.HKey(winner.entry.Key())
 				part := dm.getPartitionByHKey(hkey, kind)
 				f, err := dm.loadOrCreateFragment(part)
 				if err != nil {
@@ -249,9 +276,10 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 				}
 
 				f.Lock()
-				e := newEnv(context.Background(), 0)
+				e := dm.s.newEnv(context.Background(), 0)
 				e.hkey = hkey
 				e.fragment = f
+				e.repair = true
 				err = dm.putEntryOnFragment(e, winner.entry)
 				if err != nil {
 					dm.s.log.V(3).Printf("[ERROR] Failed to synchronize with replica: %v", err)
@@ -260,7 +288,11 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 			}
 		} else {
 			// If readRepair is enabled, this function is called by every GET request.
-			cmd := protocol.NewPutEntry(dm.name, winner.entry.Key(), winner.entry.Encode()).Command(dm.s.ctx)
+			// The tags of the version are not known here.
+			cmd := protocol.NewPutEntry(dm.name, winner.entry.Key(), winner.entry.Encode()).
+				SetKeepTags().
+				SetTimestamp(winner.entry.Timestamp()).
+				Command(dm.s.ctx)
 			rc := dm.s.client.Get(version.host.String())
 			err := rc.Process(dm.s.ctx, cmd)
 			if err != nil {
@@ -279,13 +311,14 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 	// RUnlock should not be called with defer statement here because
 	// readRepair function may call putOnFragment function which needs a write
 	// lock. Please don't forget calling RUnlock before returning here.
+	readQuorum := dm.s.runtimeConfig().ReadQuorum
 	versions := dm.lookupOnOwners(hkey, key)
-	if dm.s.config.ReadQuorum >= config.MinimumReplicaCount {
+	if readQuorum >= config.MinimumReplicaCount {
 		v := dm.lookupOnReplicas(hkey, key)
 		versions = append(versions, v...)
 	}
 
-	if len(versions) < dm.s.config.ReadQuorum {
+	if len(versions) < readQuorum {
 		return nil, ErrReadQuorum
 	}
 
@@ -295,19 +328,21 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 		return nil, ErrKeyNotFound
 	}
 
-	if len(sorted) < dm.s.config.ReadQuorum {
+	if len(sorted) < readQuorum {
 		return nil, ErrReadQuorum
 	}
 
-	// The most up-to-date version of the values.
-	winner := sorted[0]
+	// The most up-to-date version of the values, the versions are merged by
+	// the ConflictResolver of the DMap.
+	winner := &version{entry: dm.resolveVersions(sorted)}
 	if isKeyExpired(winner.entry.TTL()) || dm.isKeyIdle(hkey) {
+		dm.expireLazily(hkey, key)
 		return nil, ErrKeyNotFound
 	}
 
 	if dm.s.config.ReadRepair {
 		// Parallel read operations may propagate different versions of
-		// the same key/value pair. The rule is simple: last write wins.
+		// the same key/value pair, every owner gets the merged one.
 		dm.readRepair(winner, versions)
 	}
 	return winner.entry, nil
@@ -317,27 +352,90 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value.
 func (dm *DMap) Get(ctx context.Context, key string) (storage.Entry, error) {
-	hkey := partitions.HKey(dm.name, key)
+	e, err := dm.GetEntry(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return e.Entry, nil
+}
+
+// GetEntry is like Get, but it also returns the partition owner that served
+// the read.
+func (dm *DMap) GetEntry(ctx context.Context, key string) (*Entry, error) {
+	e, err := dm.getEntry(ctx, key, false)
+	return dm.assembleChunks(ctx, e, err)
+}
+
+// LinearizableGetEntry is like GetEntry, but the partition owner checks its
+// routing table against the cluster coordinator before serving the read. It
+// returns routingtable.ErrStaleRoutingTable if the ownership of the partition
+// is being transferred, so the caller doesn't read from a former owner.
+func (dm *DMap) LinearizableGetEntry(ctx context.Context, key string) (*Entry, error) {
+	e, err := dm.getEntry(ctx, key, true)
+	return dm.assembleChunks(ctx, e, err)
+}
+
+func (dm *DMap) getEntry(ctx context.Context, key string, linearizable bool) (*Entry, error) {
+	if err := dm.s.rt.CheckMemberCountQuorumForReads(); err != nil {
+		return nil, err
+	}
+
+	if err := dm.checkRateLimit(ctx, key, false); err != nil {
+		return nil, err
+	}
+
+	hkey := dm.HKey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 
 	// We are on the partition owner
 	if member.CompareByName(dm.s.rt.This()) {
+		defer dm.observeSLO(time.Now())
+		if linearizable {
+			if err := dm.s.rt.ReadBarrier(ctx); err != nil {
+				return nil, err
+			}
+			// The routing table may have been updated before the barrier.
+			if !dm.s.primary.PartitionByHKey(hkey).Owner().CompareByName(dm.s.rt.This()) {
+				return nil, routingtable.ErrStaleRoutingTable
+			}
+		}
+
+		dm.recordHotKey(hkey, key)
 		entry, err := dm.getOnCluster(hkey, key)
 		if errors.Is(err, ErrKeyNotFound) {
-			GetMisses.Increase(1)
+			if countsReads(ctx) {
+				GetMisses.Increase(1)
+			}
+			if dm.config().loader != nil {
+				entry, err = dm.loadOnMiss(ctx, hkey, key)
+			}
+		} else if err == nil {
+			dm.refreshAhead(entry, key)
+			dm.slideTTL(hkey, entry)
 		}
 		if err != nil {
 			return nil, err
 		}
 
 		// number of keys that have been requested and found present
-		GetHits.Increase(1)
+		if countsReads(ctx) {
+			GetHits.Increase(1)
+		}
+		dm.recordAccess(hkey)
 
-		return entry, nil
+		entry, err = dm.readEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		return dm.toEntry(entry, member)
 	}
 
 	// Redirect to the partition owner
-	cmd := protocol.NewGet(dm.name, key).SetRaw().Command(dm.s.ctx)
+	getCmd := protocol.NewGet(dm.name, key).SetRaw()
+	if linearizable {
+		getCmd.SetLinearizable()
+	}
+	cmd := getCmd.Command(dm.s.ctx)
 	rc := dm.s.client.Get(member.String())
 	err := rc.Process(ctx, cmd)
 	if err != nil {
@@ -353,10 +451,30 @@ func (dm *DMap) Get(ctx context.Context, key string) (storage.Entry, error) {
 		return nil, protocol.ConvertError(err)
 	}
 
-	// number of keys that have been requested and found present
-	GetHits.Increase(1)
-
 	entry := dm.engine.NewEntry()
 	entry.Decode(value)
-	return entry, nil
+	if dm.strictlyExpired(entry.TTL()) {
+		// The entry is expired while it's sent by the partition owner.
+		if countsReads(ctx) {
+			GetMisses.Increase(1)
+		}
+		return nil, ErrKeyNotFound
+	}
+
+	// number of keys that have been requested and found present
+	if countsReads(ctx) {
+		GetHits.Increase(1)
+	}
+	return dm.toEntry(entry, member)
+}
+
+func (dm *DMap) toEntry(entry storage.Entry, member discovery.Member) (*Entry, error) {
+	entry, err := decodeEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{
+		Entry:  entry,
+		Member: member.String(),
+	}, nil
 }
@@ -0,0 +1,159 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrNoBackup means that the partition of the key has no backup owner.
+var ErrNoBackup = errors.New("no backup owner")
+
+// backupOwner returns the backup owner to read the key from. It prefers this
+// member if it's one of the backup owners, otherwise it picks one of them
+// randomly to spread the reads.
+func (dm *DMap) backupOwner(hkey uint64) (discovery.Member, error) {
+	owners := dm.s.backup.PartitionOwnersByHKey(hkey)
+	if len(owners) == 0 {
+		return discovery.Member{}, ErrNoBackup
+	}
+	for _, owner := range owners {
+		if owner.CompareByName(dm.s.rt.This()) {
+			return owner, nil
+		}
+	}
+	return owners[rand.Intn(len(owners))], nil
+}
+
+// GetEntryFromBackup reads the key from a backup owner of its partition,
+// instead of the partition owner. The backups may miss the latest writes, so
+// the entry can be stale. It returns ErrNoBackup if the partition has no
+// backup owner.
+func (dm *DMap) GetEntryFromBackup(ctx context.Context, key string) (*Entry, error) {
+	if err := dm.s.rt.CheckMemberCountQuorumForReads(); err != nil {
+		return nil, err
+	}
+	if err := dm.checkRateLimit(ctx, key, false); err != nil {
+		return nil, err
+	}
+
+	hkey := dm.HKey(key)
+	member, err := dm.backupOwner(hkey)
+	if err != nil {
+		return nil, err
+	}
+	e, err := dm.getEntryOnBackup(ctx, hkey, key, member)
+	return dm.assembleChunks(ctx, e, err)
+}
+
+// GetEntryFromNearest reads the key from the closest owner of its partition:
+// this member if it owns the partition or one of its backups, otherwise the
+// owner with the lowest round-trip time. The partition owner is preferred
+// until the round-trip times are measured. The entry can be stale if it's read
+// from a backup owner.
+func (dm *DMap) GetEntryFromNearest(ctx context.Context, key string) (*Entry, error) {
+	hkey := dm.HKey(key)
+	primary := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if primary.CompareByName(dm.s.rt.This()) {
+		return dm.GetEntry(ctx, key)
+	}
+
+	rtts := dm.s.client.RTTs()
+	nearest := primary
+	rtt, measured := rtts[primary.String()]
+	measured = measured && rtt.Samples > 0
+	for _, owner := range dm.s.backup.PartitionOwnersByHKey(hkey) {
+		if owner.CompareByName(dm.s.rt.This()) {
+			nearest = owner
+			break
+		}
+		r, ok := rtts[owner.String()]
+		if ok && r.Samples > 0 && (!measured || r.SRTT < rtt.SRTT) {
+			nearest, rtt, measured = owner, r, true
+		}
+	}
+	if nearest.CompareByName(primary) {
+		return dm.GetEntry(ctx, key)
+	}
+
+	if err := dm.s.rt.CheckMemberCountQuorumForReads(); err != nil {
+		return nil, err
+	}
+	if err := dm.checkRateLimit(ctx, key, false); err != nil {
+		return nil, err
+	}
+	e, err := dm.getEntryOnBackup(ctx, hkey, key, nearest)
+	return dm.assembleChunks(ctx, e, err)
+}
+
+func (dm *DMap) getEntryOnBackup(ctx context.Context, hkey uint64, key string, member discovery.Member) (*Entry, error) {
+	var err error
+	var entry storage.Entry
+	if member.CompareByName(dm.s.rt.This()) {
+		e := dm.s.newEnv(ctx, 0)
+		e.dmap = dm.name
+		e.key = key
+		e.hkey = hkey
+		e.kind = partitions.BACKUP
+		entry, err = dm.getOnFragment(e)
+		if errors.Is(err, errFragmentNotFound) {
+			err = ErrKeyNotFound
+		}
+	} else {
+		cmd := protocol.NewGetEntry(dm.name, key).SetReplica().Command(dm.s.ctx)
+		rc := dm.s.client.Get(member.String())
+		err = protocol.ConvertError(rc.Process(ctx, cmd))
+		if errors.Is(err, ErrDMapNotFound) {
+			err = ErrKeyNotFound
+		}
+		if err == nil {
+			var value []byte
+			value, err = cmd.Bytes()
+			if err != nil {
+				return nil, protocol.ConvertError(err)
+			}
+			entry = dm.engine.NewEntry()
+			entry.Decode(value)
+			if dm.strictlyExpired(entry.TTL()) {
+				// The entry is expired while it's sent by the backup owner.
+				err = ErrKeyNotFound
+			}
+		}
+	}
+	if errors.Is(err, ErrKeyNotFound) && countsReads(ctx) {
+		GetMisses.Increase(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// number of keys that have been requested and found present
+	if countsReads(ctx) {
+		GetHits.Increase(1)
+	}
+
+	entry, err = dm.readEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	return dm.toEntry(entry, member)
+}
@@ -0,0 +1,77 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_GetEntryFromBackup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		for i := 0; i < 10; i++ {
+			e, err := dm.GetEntryFromBackup(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+			require.Equal(t, testutil.ToVal(i), e.Value())
+
+			// The backup owner served the read, not the partition owner.
+			hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+			owner := dm.s.primary.PartitionByHKey(hkey).Owner()
+			require.NotEqual(t, owner.String(), e.Member)
+		}
+
+		_, err = dm.GetEntryFromBackup(ctx, "missing")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_GetEntryFromBackup_ErrNoBackup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.GetEntryFromBackup(context.Background(), "mykey")
+	require.ErrorIs(t, err, ErrNoBackup)
+}
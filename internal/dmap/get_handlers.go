@@ -17,6 +17,7 @@ package dmap
 import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
 )
 
@@ -32,7 +33,10 @@ func (s *Service) getCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	raw, err := dm.Get(s.ctx, getCmd.Key)
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	raw, err := dm.getEntry(clientContext(ctx, conn), getCmd.Key, getCmd.Linearizable)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -42,6 +46,11 @@ func (s *Service) getCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		conn.WriteBulk(raw.Encode())
 		return
 	}
+	raw, err = dm.assembleChunks(ctx, raw, nil)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
 	conn.WriteBulk(raw.Value())
 }
 
@@ -62,10 +71,10 @@ func (s *Service) getEntryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		kind = partitions.BACKUP
 	}
 
-	e := newEnv(s.ctx, 0)
+	e := s.newEnv(s.ctx, 0)
 	e.dmap = getEntryCmd.DMap
 	e.key = getEntryCmd.Key
-	e.hkey = partitions.HKey(getEntryCmd.DMap, getEntryCmd.Key)
+	e.hkey = dm.HKey(getEntryCmd.Key)
 	e.kind = kind
 	nt, err := dm.getOnFragment(e)
 	if err == errFragmentNotFound {
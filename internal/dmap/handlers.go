@@ -23,16 +23,41 @@ func (s *Service) RegisterHandlers() {
 	s.server.ServeMux().HandleFunc(protocol.DMap.Get, s.getCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Del, s.delCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.DelEntry, s.delEntryCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.DelByTag, s.delByTagCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.GetEntry, s.getEntryCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.PutEntry, s.putEntryCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Expire, s.expireCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.PExpire, s.pexpireCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Destroy, s.destroyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Undo, s.undoCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Scan, s.scanCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Function, s.functionCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.IncrMany, s.incrManyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HSet, s.hsetCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HDel, s.hdelCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HIncrBy, s.hincrByCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Execute, s.executeCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Tx, s.txCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Replicate, s.replicateCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Query, s.queryCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.QueryPage, s.queryPageCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Migrate, s.migrateCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Warmup, s.warmupCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Access, s.accessStatsCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HotKeys, s.hotKeysCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ListDMaps, s.listDMapsCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Export, s.exportCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Import, s.importCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Lock, s.lockCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Unlock, s.unlockCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.LockLease, s.lockLeaseCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.PLockLease, s.plockLeaseCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.LockInfo, s.lockInfoCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ForceUnlock, s.forceUnlockCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Changes, s.changesCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Checksum, s.checksumCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Digest, s.digestCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.DelFrom, s.delFromCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Tombstone, s.tombstoneCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.Internal.MoveFragment, s.moveFragmentCommandHandler)
 }
@@ -0,0 +1,233 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/resp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// ErrFieldNotFound is returned when the field is not in the hash.
+	ErrFieldNotFound = errors.New("field not found")
+
+	// ErrNotHash is returned when a hash operation is applied to a value that
+	// is not a hash.
+	ErrNotHash = errors.New("value is not a hash")
+)
+
+// hashMagic is prepended to the encoded hashes to tell them apart from the
+// other values. 0xc1 is never used by msgpack.
+var hashMagic = []byte{0xc1, 'H'}
+
+func encodeHash(fields map[string][]byte) ([]byte, error) {
+	data, err := msgpack.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, hashMagic...), data...), nil
+}
+
+func decodeHash(value []byte) (map[string][]byte, error) {
+	if !bytes.HasPrefix(value, hashMagic) {
+		return nil, ErrNotHash
+	}
+	fields := make(map[string][]byte)
+	if err := msgpack.Unmarshal(value[len(hashMagic):], &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// updateHash modifies the fields of the hash atomically. A missing key is
+// treated as an empty hash. It has to be called on the partition owner.
+func (dm *DMap) updateHash(ctx context.Context, hkey uint64, key, label string, update func(fields map[string][]byte) error) error {
+	if err := dm.checkWrite(key); err != nil {
+		return err
+	}
+
+	_, err := dm.atomicUpdate(ctx, dm.name, hkey, key, label, func(currentState []byte) ([]byte, []byte, error) {
+		fields := make(map[string][]byte)
+		if len(currentState) != 0 {
+			var err error
+			fields, err = decodeHash(currentState)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := update(fields); err != nil {
+			return nil, nil, err
+		}
+		newState, err := encodeHash(fields)
+		return newState, nil, err
+	})
+	return err
+}
+
+func (dm *DMap) hsetOnCluster(ctx context.Context, hkey uint64, key, field string, value []byte) error {
+	return dm.updateHash(ctx, hkey, key, "hset", func(fields map[string][]byte) error {
+		fields[field] = value
+		return nil
+	})
+}
+
+// hdelOnCluster deletes the fields. The hash is not written if none of the
+// fields exists, and the key is deleted if the hash becomes empty.
+func (dm *DMap) hdelOnCluster(ctx context.Context, hkey uint64, key string, fields ...string) (int, error) {
+	var deleted int
+	err := dm.updateHash(ctx, hkey, key, "hdel", func(current map[string][]byte) error {
+		for _, field := range fields {
+			if _, ok := current[field]; ok {
+				delete(current, field)
+				deleted++
+			}
+		}
+		if deleted == 0 {
+			return errSkipUpdate
+		}
+		if len(current) == 0 {
+			return errDeleteKey
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (dm *DMap) hincrByOnCluster(ctx context.Context, hkey uint64, key, field string, delta int) (int, error) {
+	var latest int
+	err := dm.updateHash(ctx, hkey, key, "hincrby", func(fields map[string][]byte) error {
+		var current int
+		if value, ok := fields[field]; ok {
+			var err error
+			current, err = strconv.Atoi(string(value))
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrNotInteger, field)
+			}
+		}
+		latest = current + delta
+		fields[field] = []byte(strconv.Itoa(latest))
+		return nil
+	})
+	return latest, err
+}
+
+// HSet sets the field of the hash that is stored at key. value type is
+// arbitrary, it's encoded like the values of Put.
+func (dm *DMap) HSet(ctx context.Context, key, field string, value interface{}) error {
+	if err := dm.checkRateLimit(ctx, key, true); err != nil {
+		return err
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	err := resp.New(valueBuf).Encode(value)
+	if err != nil {
+		return err
+	}
+	encoded := make([]byte, valueBuf.Len())
+	copy(encoded, valueBuf.Bytes())
+
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.hsetOnCluster(ctx, hkey, key, field, encoded)
+	}
+
+	cmd := protocol.NewHSet(dm.name, key, field, encoded).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// HDel deletes the fields of the hash that is stored at key and returns the
+// number of the deleted fields. The key is deleted with its last field.
+func (dm *DMap) HDel(ctx context.Context, key string, fields ...string) (int, error) {
+	if err := dm.checkRateLimit(ctx, key, true); err != nil {
+		return 0, err
+	}
+
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.hdelOnCluster(ctx, hkey, key, fields...)
+	}
+
+	cmd := protocol.NewHDel(dm.name, key, fields...).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	deleted, err := cmd.Result()
+	return int(deleted), protocol.ConvertError(err)
+}
+
+// HIncrBy adds delta to the integer value of the field and returns the new
+// value. A missing field is treated as zero.
+func (dm *DMap) HIncrBy(ctx context.Context, key, field string, delta int) (int, error) {
+	if err := dm.checkRateLimit(ctx, key, true); err != nil {
+		return 0, err
+	}
+
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.hincrByOnCluster(ctx, hkey, key, field, delta)
+	}
+
+	cmd := protocol.NewHIncrBy(dm.name, key, field, delta).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	latest, err := cmd.Result()
+	return int(latest), protocol.ConvertError(err)
+}
+
+// HGetAll returns all the fields of the hash that is stored at key. It
+// returns ErrKeyNotFound if the key doesn't exist.
+func (dm *DMap) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	entry, err := dm.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHash(entry.Value())
+}
+
+// HGet returns the value of the field. It returns ErrKeyNotFound if the key
+// doesn't exist, and ErrFieldNotFound if the field is not in the hash.
+func (dm *DMap) HGet(ctx context.Context, key, field string) ([]byte, error) {
+	fields, err := dm.HGetAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := fields[field]
+	if !ok {
+		return nil, ErrFieldNotFound
+	}
+	return value, nil
+}
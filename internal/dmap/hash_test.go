@@ -0,0 +1,124 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Hash_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		require.NoError(t, dm1.HSet(ctx, key, "name", "olric"))
+		require.NoError(t, dm2.HSet(ctx, key, "version", "0.5"))
+
+		count, err := dm2.HIncrBy(ctx, key, "count", 2)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+		count, err = dm1.HIncrBy(ctx, key, "count", -3)
+		require.NoError(t, err)
+		require.Equal(t, -1, count)
+	}
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		value, err := dm2.HGet(ctx, key, "name")
+		require.NoError(t, err)
+		require.Equal(t, []byte("olric"), value)
+
+		deleted, err := dm2.HDel(ctx, key, "version", "unknown")
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+
+		_, err = dm1.HGet(ctx, key, "version")
+		require.ErrorIs(t, err, ErrFieldNotFound)
+
+		fields, err := dm1.HGetAll(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{
+			"name":  []byte("olric"),
+			"count": []byte("-1"),
+		}, fields)
+	}
+}
+
+func TestDMap_Hash_NotHash(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(ctx, "mykey", "myvalue", nil)
+	require.NoError(t, err)
+
+	err = dm.HSet(ctx, "mykey", "myfield", "myvalue")
+	require.ErrorIs(t, err, ErrNotHash)
+
+	_, err = dm.HGet(ctx, "mykey", "myfield")
+	require.ErrorIs(t, err, ErrNotHash)
+
+	_, err = dm.HGetAll(ctx, "unknown")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, dm.HSet(ctx, "myhash", "counter", "foo"))
+	_, err = dm.HIncrBy(ctx, "myhash", "counter", 1)
+	require.ErrorIs(t, err, ErrNotInteger)
+}
+
+func TestDMap_Hash_HDel(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Doesn't create the key.
+	deleted, err := dm.HDel(ctx, "myhash", "name")
+	require.NoError(t, err)
+	require.Equal(t, 0, deleted)
+	_, err = dm.Get(ctx, "myhash")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, dm.HSet(ctx, "myhash", "name", "olric"))
+	require.NoError(t, dm.HSet(ctx, "myhash", "version", "0.5"))
+	deleted, err = dm.HDel(ctx, "myhash", "name", "version")
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+
+	// The hash is empty, the key is deleted.
+	_, err = dm.Get(ctx, "myhash")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -0,0 +1,316 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// The sketch of a fragment takes 8KB per window.
+	hotKeysSketchDepth = 4
+	hotKeysSketchWidth = 512
+
+	// hotKeysCandidates is the maximum number of keys that are tracked by
+	// a fragment.
+	hotKeysCandidates = 32
+)
+
+// hotKeysSketchSeeds are used to derive the independent hash functions of
+// the sketch rows from the hkey.
+var hotKeysSketchSeeds = [hotKeysSketchDepth]uint64{
+	0x9e3779b97f4a7c15,
+	0xc2b2ae3d27d4eb4f,
+	0x165667b19e3779f9,
+	0xd6e8feb86659fd93,
+}
+
+// ErrHotKeysDisabled is returned when the hot keys are requested from a DMap
+// without HotKeysWindow.
+var ErrHotKeysDisabled = errors.New("hot key tracking is disabled")
+
+// HotKey is a frequently accessed key of a DMap.
+type HotKey struct {
+	Key string
+
+	// QPS is the estimated number of reads and writes per second. It's
+	// computed from a count-min sketch, so it may overestimate the rate.
+	QPS float64
+
+	PartID uint64
+
+	// Owner is the name of the member that owns the partition.
+	Owner string
+}
+
+// countMinSketch is a count-min sketch of the hkeys.
+type countMinSketch [hotKeysSketchDepth][hotKeysSketchWidth]uint32
+
+func sketchIndex(hkey uint64, row int) uint64 {
+	h := (hkey ^ hotKeysSketchSeeds[row]) * 0xff51afd7ed558ccd
+	return (h >> 32) % hotKeysSketchWidth
+}
+
+// add increases the counters of the hkey and returns its new estimate.
+func (c *countMinSketch) add(hkey uint64) uint32 {
+	var min uint32
+	for row := 0; row < hotKeysSketchDepth; row++ {
+		i := sketchIndex(hkey, row)
+		c[row][i]++
+		if row == 0 || c[row][i] < min {
+			min = c[row][i]
+		}
+	}
+	return min
+}
+
+func (c *countMinSketch) estimate(hkey uint64) uint32 {
+	var min uint32
+	for row := 0; row < hotKeysSketchDepth; row++ {
+		count := c[row][sketchIndex(hkey, row)]
+		if row == 0 || count < min {
+			min = count
+		}
+	}
+	return min
+}
+
+// hotKeyTracker estimates the access rate of the keys in a fragment. The
+// accesses are counted in a sketch per window. The rate is computed from the
+// current and the previous windows, so it covers at least one full window.
+// Only a bounded set of candidate keys is kept, the candidate with the lowest
+// estimate is replaced by a hotter key. It's updated under the fragment's
+// read lock, so it has its own lock.
+type hotKeyTracker struct {
+	mtx         sync.Mutex
+	window      time.Duration
+	start       time.Time
+	current     *countMinSketch
+	previous    *countMinSketch
+	hasPrevious bool
+	candidates  map[string]uint64
+}
+
+func newHotKeyTracker(window time.Duration) *hotKeyTracker {
+	return &hotKeyTracker{
+		window:     window,
+		start:      time.Now(),
+		current:    &countMinSketch{},
+		previous:   &countMinSketch{},
+		candidates: make(map[string]uint64),
+	}
+}
+
+// rotate starts a new window if the current one is over.
+func (h *hotKeyTracker) rotate(now time.Time) {
+	elapsed := now.Sub(h.start)
+	if elapsed < h.window {
+		return
+	}
+	if elapsed < 2*h.window {
+		h.previous, h.current = h.current, h.previous
+	} else {
+		// Nothing is recorded in the last window.
+		*h.previous = countMinSketch{}
+	}
+	*h.current = countMinSketch{}
+	h.hasPrevious = true
+	h.start = h.start.Add(elapsed / h.window * h.window)
+
+	for key, hkey := range h.candidates {
+		if h.previous.estimate(hkey) == 0 {
+			delete(h.candidates, key)
+		}
+	}
+}
+
+func (h *hotKeyTracker) record(hkey uint64, key string, now time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.rotate(now)
+	count := h.current.add(hkey)
+	if _, ok := h.candidates[key]; ok {
+		return
+	}
+	if len(h.candidates) < hotKeysCandidates {
+		h.candidates[key] = hkey
+		return
+	}
+
+	var coldest string
+	var min uint32
+	for candidate, chkey := range h.candidates {
+		estimate := h.current.estimate(chkey)
+		if coldest == "" || estimate < min {
+			coldest, min = candidate, estimate
+		}
+	}
+	if count > min {
+		delete(h.candidates, coldest)
+		h.candidates[key] = hkey
+	}
+}
+
+func (h *hotKeyTracker) top(n int, now time.Time) []HotKey {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.rotate(now)
+	span := now.Sub(h.start)
+	if h.hasPrevious {
+		span += h.window
+	}
+	if span < time.Second {
+		span = time.Second
+	}
+
+	var result []HotKey
+	for key, hkey := range h.candidates {
+		count := h.current.estimate(hkey) + h.previous.estimate(hkey)
+		if count == 0 {
+			continue
+		}
+		result = append(result, HotKey{
+			Key: key,
+			QPS: float64(count) / span.Seconds(),
+		})
+	}
+	sortHotKeys(result)
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+func sortHotKeys(items []HotKey) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].QPS != items[j].QPS {
+			return items[i].QPS > items[j].QPS
+		}
+		return items[i].Key < items[j].Key
+	})
+}
+
+// recordHotKey counts an access to the given key on the partition owner.
+func (f *fragment) recordHotKey(hkey uint64, key string) {
+	if f.hotKeys == nil {
+		return
+	}
+	f.hotKeys.record(hkey, key, time.Now())
+}
+
+// recordHotKey counts a read of the given key on the partition owner.
+func (dm *DMap) recordHotKey(hkey uint64, key string) {
+	if dm.config().hotKeysWindow <= 0 {
+		return
+	}
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if err != nil {
+		return
+	}
+	f.recordHotKey(hkey, key)
+}
+
+// hotKeysLocal returns the n hottest keys of the partitions owned by this
+// member.
+func (dm *DMap) hotKeysLocal(n int) ([]HotKey, error) {
+	now := time.Now()
+	var result []HotKey
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		part := dm.s.primary.PartitionByID(partID)
+		if !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.hotKeys == nil {
+			continue
+		}
+		for _, item := range f.hotKeys.top(n, now) {
+			item.PartID = partID
+			item.Owner = dm.s.rt.This().String()
+			result = append(result, item)
+		}
+		sortHotKeys(result)
+		if len(result) > n {
+			result = result[:n]
+		}
+	}
+	return result, nil
+}
+
+// HotKeys returns the n most frequently accessed keys of the DMap with their
+// approximate access rates. It returns ErrHotKeysDisabled if HotKeysWindow is
+// not set for the DMap.
+func (dm *DMap) HotKeys(ctx context.Context, n int) ([]HotKey, error) {
+	if dm.config().hotKeysWindow <= 0 {
+		return nil, ErrHotKeysDisabled
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	var result []HotKey
+	for _, member := range dm.s.rt.Discovery().GetMembers() {
+		if member.CompareByID(dm.s.rt.This()) {
+			items, err := dm.hotKeysLocal(n)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, items...)
+			continue
+		}
+
+		cmd := protocol.NewHotKeys(dm.name, n).SetLocal().Command(ctx)
+		rc := dm.s.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return nil, protocol.ConvertError(err)
+		}
+		raw, err := cmd.Result()
+		if err != nil {
+			return nil, protocol.ConvertError(err)
+		}
+		for _, item := range raw {
+			var h HotKey
+			if err = msgpack.Unmarshal([]byte(item), &h); err != nil {
+				return nil, err
+			}
+			result = append(result, h)
+		}
+	}
+
+	sortHotKeys(result)
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
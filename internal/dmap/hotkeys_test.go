@@ -0,0 +1,124 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_hotKeyTracker(t *testing.T) {
+	h := newHotKeyTracker(10 * time.Second)
+	now := h.start
+
+	for i := 0; i < 100; i++ {
+		h.record(1, "hot", now)
+	}
+	h.record(2, "cold", now)
+
+	top := h.top(1, now.Add(5*time.Second))
+	require.Len(t, top, 1)
+	require.Equal(t, "hot", top[0].Key)
+	require.Equal(t, float64(20), top[0].QPS)
+
+	// The previous window is still counted after the rotation.
+	top = h.top(2, now.Add(15*time.Second))
+	require.Len(t, top, 2)
+	require.Equal(t, "hot", top[0].Key)
+	require.Equal(t, float64(100)/15, top[0].QPS)
+
+	// Nothing is recorded in the last two windows.
+	top = h.top(2, now.Add(25*time.Second))
+	require.Len(t, top, 0)
+}
+
+func TestDMap_hotKeyTracker_Candidates(t *testing.T) {
+	h := newHotKeyTracker(time.Minute)
+	now := h.start
+
+	for i := 0; i < hotKeysCandidates; i++ {
+		h.record(uint64(i), testutil.ToKey(i), now)
+	}
+	require.Len(t, h.candidates, hotKeysCandidates)
+
+	// A hotter key replaces the coldest candidate.
+	hkey := uint64(hotKeysCandidates)
+	for i := 0; i < 10; i++ {
+		h.record(hkey, "hot", now)
+	}
+	require.Len(t, h.candidates, hotKeysCandidates)
+	require.Contains(t, h.candidates, "hot")
+
+	top := h.top(1, now)
+	require.Equal(t, "hot", top[0].Key)
+}
+
+func TestDMap_HotKeys_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HotKeysWindow: time.Minute})
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HotKeysWindow: time.Minute})
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	// The key i is accessed i+1 times.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < i; j++ {
+			_, err = dm2.Get(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+		}
+	}
+
+	hotKeys, err := dm1.HotKeys(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, hotKeys, 3)
+	for i, item := range hotKeys {
+		require.Equal(t, testutil.ToKey(9-i), item.Key)
+		require.Equal(t, float64(10-i), item.QPS)
+		require.NotEmpty(t, item.Owner)
+	}
+
+	hotKeys, err = dm2.HotKeys(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, hotKeys, 3)
+}
+
+func TestDMap_HotKeys_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.HotKeys(context.Background(), 3)
+	require.ErrorIs(t, err, ErrHotKeysDisabled)
+}
@@ -0,0 +1,102 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/ratelimit"
+	"github.com/buraksezer/olric/internal/stats"
+)
+
+// ErrImportThrottled is returned when a fragment is sent to a member that
+// imports config.Config.MaxConcurrentImports fragments already. The balancer
+// sends the fragment again in the next run.
+var ErrImportThrottled = errors.New("import throttled")
+
+// ImportsTotal is the number of the fragments imported by this member, see
+// admitImport.
+var ImportsTotal = stats.NewInt64Counter()
+
+// importThrottle keeps the imports of a member under config.Config.ImportBandwidth,
+// ImportEntryRate and MaxConcurrentImports. The limits are read from the
+// runtime configuration, so they can be changed by ReloadConfig.
+type importThrottle struct {
+	mtx       sync.Mutex
+	inflight  int
+	bandwidth ratelimit.Limiter
+	entryRate ratelimit.Limiter
+}
+
+// tryAcquire takes an import slot if there are less than max imports, zero
+// means no limit.
+func (t *importThrottle) tryAcquire(max int) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if max > 0 && t.inflight >= max {
+		return false
+	}
+	t.inflight++
+	return true
+}
+
+func (t *importThrottle) release() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.inflight--
+}
+
+// admitImport waits until a fragment of the given size can be imported under
+// the byte and entry rates, and returns a function that has to be called
+// after the import. The number of entries is only known after the import, the
+// release function reserves them and the next import waits for them.
+//
+// An import slot is taken after the rates are respected. If there is no free
+// slot, it returns ErrImportThrottled without waiting unless wait is true.
+// The fragments sent by the other members are not waited for, the handler
+// would block the sender otherwise.
+func (s *Service) admitImport(ctx context.Context, size int, wait bool) (func(count int), error) {
+	t := s.imports
+	if s.runtimeConfig().ImportEntryRate > 0 {
+		if err := t.entryRate.WaitIdle(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.bandwidth.Wait(ctx, s.runtimeConfig().ImportBandwidth, size); err != nil {
+		return nil, err
+	}
+
+	for !t.tryAcquire(s.runtimeConfig().MaxConcurrentImports) {
+		if !wait {
+			return nil, ErrImportThrottled
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	return func(count int) {
+		defer t.release()
+		ImportsTotal.Increase(1)
+		t.entryRate.Reserve(s.runtimeConfig().ImportEntryRate, count)
+	}, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_AdmitImport(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.MaxConcurrentImports = 1
+	c.ImportEntryRate = 100
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+	ctx := context.Background()
+	imports := ImportsTotal.Read()
+	release, err := s.admitImport(ctx, 1024, false)
+	require.NoError(t, err)
+
+	// The fragments of the other members are rejected without waiting.
+	_, err = s.admitImport(ctx, 1024, false)
+	require.ErrorIs(t, err, ErrImportThrottled)
+
+	admitted := make(chan func(int), 1)
+	go func() {
+		r, err := s.admitImport(ctx, 1024, true)
+		if err == nil {
+			admitted <- r
+		}
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatalf("The second import has been admitted before the first one is done")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 10 entries reserve 100ms, the release doesn't wait for them but the
+	// next import does.
+	start := time.Now()
+	release(10)
+	require.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+	second := <-admitted
+	second(0)
+
+	third, err := s.admitImport(ctx, 1024, false)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+	third(0)
+	require.Equal(t, int64(3), ImportsTotal.Read()-imports)
+}
+
+func TestDMap_AdmitImport_ReloadConfig(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.MaxConcurrentImports = 1
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+	ctx := context.Background()
+	release, err := s.admitImport(ctx, 1024, false)
+	require.NoError(t, err)
+	defer release(0)
+
+	_, err = s.admitImport(ctx, 1024, false)
+	require.ErrorIs(t, err, ErrImportThrottled)
+
+	rc := *c
+	rc.MaxConcurrentImports = 2
+	require.NoError(t, s.ReloadConfig(ctx, &rc))
+
+	second, err := s.admitImport(ctx, 1024, false)
+	require.NoError(t, err)
+	second(0)
+}
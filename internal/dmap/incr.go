@@ -0,0 +1,138 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrNotInteger is returned when an increment is applied to a value that is
+// not an integer.
+var ErrNotInteger = errors.New("value is not an integer")
+
+// incrOnCluster adds delta to the integer value of the key and returns the
+// new value. A missing key is treated as zero. It has to be called on the
+// partition owner.
+func (dm *DMap) incrOnCluster(ctx context.Context, hkey uint64, key string, delta int) (int, error) {
+	if err := dm.checkWrite(key); err != nil {
+		return 0, err
+	}
+
+	var latest int
+	_, err := dm.atomicUpdate(ctx, dm.name, hkey, key, "incr", func(currentState []byte) ([]byte, []byte, error) {
+		var current int
+		if len(currentState) != 0 {
+			var err error
+			current, err = strconv.Atoi(string(currentState))
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %s", ErrNotInteger, key)
+			}
+		}
+		latest = current + delta
+		return []byte(strconv.Itoa(latest)), nil, nil
+	})
+	return latest, err
+}
+
+// IncrMany atomically adds the deltas to the integer values of the keys and
+// returns the new values. The keys are grouped by their partition owners, and
+// a single request is sent to every owner. A missing key is treated as zero.
+// Every key is updated atomically, but the batch is not: if an error is
+// returned, some of the keys may have been incremented.
+func (dm *DMap) IncrMany(ctx context.Context, deltas map[string]int) (map[string]int, error) {
+	keys := make([]string, 0, len(deltas))
+	for key := range deltas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	owners := make(map[string]discovery.Member)
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		owner := dm.s.primary.PartitionByHKey(dm.HKey(key)).Owner()
+		owners[owner.String()] = owner
+		groups[owner.String()] = append(groups[owner.String()], key)
+	}
+
+	var mtx sync.Mutex
+	result := make(map[string]int, len(deltas))
+	var g errgroup.Group
+	for name, group := range groups {
+		owner, group := owners[name], group
+		g.Go(func() error {
+			values, err := dm.incrManyOn(ctx, owner, group, deltas)
+			if err != nil {
+				return err
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			for i, key := range group {
+				result[key] = values[i]
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// incrManyOn applies the increments of the keys on the given partition owner.
+// It returns the new values in the order of the keys.
+func (dm *DMap) incrManyOn(ctx context.Context, owner discovery.Member, keys []string, deltas map[string]int) ([]int, error) {
+	values := make([]int, 0, len(keys))
+	if owner.CompareByName(dm.s.rt.This()) {
+		for _, key := range keys {
+			value, err := dm.incrOnCluster(ctx, dm.HKey(key), key, deltas[key])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	incrManyCmd := protocol.NewIncrMany(dm.name)
+	for _, key := range keys {
+		incrManyCmd.Add(key, deltas[key])
+	}
+	cmd := incrManyCmd.Command(ctx)
+	rc := dm.s.client.Get(owner.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	if len(result) != len(keys) {
+		return nil, fmt.Errorf("expected %d values, got: %d", len(keys), len(result))
+	}
+	for _, value := range result {
+		values = append(values, int(value))
+	}
+	return values, nil
+}
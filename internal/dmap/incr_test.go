@@ -0,0 +1,80 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_IncrMany_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	deltas := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		deltas[testutil.ToKey(i)] = i
+	}
+	for _, dm := range []*DMap{dm1, dm2} {
+		_, err = dm.IncrMany(ctx, deltas)
+		require.NoError(t, err)
+	}
+
+	values, err := dm1.IncrMany(ctx, map[string]int{
+		testutil.ToKey(10): -1,
+		"new-key":          5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{testutil.ToKey(10): 19, "new-key": 5}, values)
+
+	for i := 0; i < 100; i++ {
+		gr, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		expected := 2 * i
+		if i == 10 {
+			expected--
+		}
+		require.Equal(t, []byte(strconv.Itoa(expected)), gr.Value())
+	}
+}
+
+func TestDMap_IncrMany_NotInteger(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(ctx, "mykey", "myvalue", nil)
+	require.NoError(t, err)
+
+	_, err = dm.IncrMany(ctx, map[string]int{"mykey": 1})
+	require.ErrorIs(t, err, ErrNotInteger)
+}
@@ -21,7 +21,13 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 )
 
+// pruneInterval is the interval between two sequential runs of pruneWorker.
+const pruneInterval = time.Minute
+
+// wipeOutFragment closes and destroys the fragment and deletes it from the
+// partition. The fragment lock has to be held by the caller.
 func wipeOutFragment(part *partitions.Partition, name string, f *fragment) error {
+	f.detachUsage()
 	// Stop background services if there is any.
 	err := f.Close()
 	if err != nil {
@@ -89,6 +95,26 @@ func (s *Service) janitorWorker() {
 		select {
 		case <-timer.C:
 			s.deleteEmptyFragments()
+			s.deleteExpiredSnapshots()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneWorker removes the tombstones, the rate limiters and the lock queues
+// that are no longer needed.
+func (s *Service) pruneWorker() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.pruneTombstones(time.Now())
+			s.pruneRateLimiters()
+			s.pruneLockQueues()
 		case <-s.ctx.Done():
 			return
 		}
@@ -0,0 +1,146 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"strings"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// KeyMigration describes a set of keys of a DMap that moved between the
+// members while the cluster is rebalanced.
+type KeyMigration struct {
+	DMap        string
+	PartitionID uint64
+	IsBackup    bool
+
+	// Owners is the list of the members that the keys are moved to. It's
+	// empty for the keys that arrived on this member.
+	Owners []string
+
+	Keys []string
+}
+
+// KeyMigrationListener is notified when the keys of the DMaps leave or arrive
+// on this member, so the applications can keep the state derived from them
+// consistent. The methods are called after the keys are moved, without
+// holding any lock, but they block the rebalancing. They may be called
+// concurrently.
+type KeyMigrationListener interface {
+	// KeysLeft is called after the keys are moved to the new owners and
+	// removed from this member.
+	KeysLeft(m *KeyMigration)
+
+	// KeysArrived is called after the keys are merged into a partition that
+	// is owned by this member.
+	KeysArrived(m *KeyMigration)
+}
+
+// RegisterKeyMigrationListener registers a listener on this node. A listener
+// that was registered with the same name is replaced.
+func (s *Service) RegisterKeyMigrationListener(name string, l KeyMigrationListener) {
+	s.keyMigrationMtx.Lock()
+	defer s.keyMigrationMtx.Unlock()
+
+	s.keyMigrationListeners[name] = l
+}
+
+// UnregisterKeyMigrationListener removes the listener with the given name.
+func (s *Service) UnregisterKeyMigrationListener(name string) {
+	s.keyMigrationMtx.Lock()
+	defer s.keyMigrationMtx.Unlock()
+
+	delete(s.keyMigrationListeners, name)
+}
+
+func (s *Service) keyMigrationListenersSnapshot() []KeyMigrationListener {
+	s.keyMigrationMtx.RLock()
+	defer s.keyMigrationMtx.RUnlock()
+
+	if len(s.keyMigrationListeners) == 0 {
+		return nil
+	}
+	listeners := make([]KeyMigrationListener, 0, len(s.keyMigrationListeners))
+	for _, l := range s.keyMigrationListeners {
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+func (s *Service) hasKeyMigrationListeners() bool {
+	s.keyMigrationMtx.RLock()
+	defer s.keyMigrationMtx.RUnlock()
+
+	return len(s.keyMigrationListeners) > 0
+}
+
+// exportedKeys returns the keys in an exported table of the storage engine.
+func exportedKeys(engine storage.Engine, payload []byte) ([]string, error) {
+	var keys []string
+	err := engine.Import(payload, func(_ uint64, e storage.Entry) error {
+		keys = append(keys, e.Key())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *Service) notifyKeysLeft(part *partitions.Partition, name string, owners []discovery.Member, keys []string) {
+	if len(keys) == 0 || !strings.HasPrefix(name, "dmap.") {
+		return
+	}
+	listeners := s.keyMigrationListenersSnapshot()
+	if len(listeners) == 0 {
+		return
+	}
+
+	m := &KeyMigration{
+		DMap:        strings.TrimPrefix(name, "dmap."),
+		PartitionID: part.ID(),
+		IsBackup:    part.Kind() == partitions.BACKUP,
+		Keys:        keys,
+	}
+	for _, owner := range owners {
+		m.Owners = append(m.Owners, owner.String())
+	}
+	for _, l := range listeners {
+		l.KeysLeft(m)
+	}
+}
+
+func (s *Service) notifyKeysArrived(part *partitions.Partition, name string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	listeners := s.keyMigrationListenersSnapshot()
+	if len(listeners) == 0 {
+		return
+	}
+
+	m := &KeyMigration{
+		DMap:        name,
+		PartitionID: part.ID(),
+		IsBackup:    part.Kind() == partitions.BACKUP,
+		Keys:        keys,
+	}
+	for _, l := range listeners {
+		l.KeysArrived(m)
+	}
+}
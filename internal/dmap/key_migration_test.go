@@ -0,0 +1,115 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type testKeyMigrationListener struct {
+	mtx     sync.Mutex
+	left    []*KeyMigration
+	arrived []*KeyMigration
+}
+
+func (l *testKeyMigrationListener) KeysLeft(m *KeyMigration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.left = append(l.left, m)
+}
+
+func (l *testKeyMigrationListener) KeysArrived(m *KeyMigration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.arrived = append(l.arrived, m)
+}
+
+func TestDMap_KeyMigrationListener(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	// Find a partition that is owned by the second member.
+	var part *partitions.Partition
+	for partID := uint64(0); partID < s1.config.PartitionCount; partID++ {
+		p := s1.primary.PartitionByID(partID)
+		if p.Owner().CompareByID(s2.rt.This()) {
+			part = p
+			break
+		}
+	}
+	require.NotNil(t, part)
+
+	// Simulate the left-over data of a previous owner.
+	f, err := dm1.loadOrCreateFragment(part)
+	require.NoError(t, err)
+	var keys []string
+	for i := 0; len(keys) < 10; i++ {
+		key := testutil.ToKey(i)
+		hkey := partitions.HKey("mymap", key)
+		if hkey%s1.config.PartitionCount != part.ID() {
+			continue
+		}
+		e := f.storage.NewEntry()
+		e.SetKey(key)
+		e.SetValue(testutil.ToVal(i))
+		e.SetTimestamp(time.Now().UnixNano())
+		require.NoError(t, f.storage.Put(hkey, e))
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	l1 := &testKeyMigrationListener{}
+	s1.RegisterKeyMigrationListener("test", l1)
+	l2 := &testKeyMigrationListener{}
+	s2.RegisterKeyMigrationListener("test", l2)
+
+	err = f.Move(part, "dmap.mymap", []discovery.Member{s2.rt.This()})
+	require.NoError(t, err)
+
+	check := func(migrations []*KeyMigration) {
+		require.Len(t, migrations, 1)
+		m := migrations[0]
+		require.Equal(t, "mymap", m.DMap)
+		require.Equal(t, part.ID(), m.PartitionID)
+		require.False(t, m.IsBackup)
+		sort.Strings(m.Keys)
+		require.Equal(t, keys, m.Keys)
+	}
+	check(l1.left)
+	require.Equal(t, []string{s2.rt.This().String()}, l1.left[0].Owners)
+	require.Empty(t, l1.arrived)
+
+	check(l2.arrived)
+	require.Empty(t, l2.arrived[0].Owners)
+	require.Empty(t, l2.left)
+
+	// No more notifications after the listener is removed.
+	s1.UnregisterKeyMigrationListener("test")
+	require.False(t, s1.hasKeyMigrationListeners())
+}
@@ -0,0 +1,186 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// DMapInfo describes a DMap that has entries on the cluster.
+type DMapInfo struct {
+	Name string
+
+	// Entries is the number of entries on the partition owners, the backups
+	// are not counted.
+	Entries int
+
+	EvictionPolicy  config.EvictionPolicy
+	QuotaPolicy     config.QuotaPolicy
+	TTLDuration     time.Duration
+	MaxIdleDuration time.Duration
+	MaxKeys         int
+	MaxInuse        int
+}
+
+// listDMapsLocal returns the DMaps that have a fragment on this member, sorted
+// by name. It counts the entries of the partitions owned by this member.
+func (s *Service) listDMapsLocal() ([]DMapInfo, error) {
+	entries := make(map[string]int)
+	chunked := make(map[string]bool)
+	chunking := func(name string) bool {
+		enabled, ok := chunked[name]
+		if !ok {
+			c := &dmapConfig{}
+			enabled = c.load(s.runtimeConfig().DMaps, name) == nil && c.chunkSize > 0
+			chunked[name] = enabled
+		}
+		return enabled
+	}
+	scan := func(part *partitions.Partition, owned bool) {
+		part.Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+			dmapName := strings.TrimPrefix(name.(string), "dmap.")
+			var length int
+			if owned {
+				f := tmp.(*fragment)
+				length = f.Stats().Length
+				if chunking(dmapName) {
+					// The chunks of the large values are not entries.
+					length -= countChunkKeys(f)
+				}
+			}
+			entries[dmapName] += length
+			return true
+		})
+	}
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		scan(part, part.Owner().CompareByID(s.rt.This()))
+		scan(s.backup.PartitionByID(partID), false)
+	}
+
+	result := make([]DMapInfo, 0, len(entries))
+	for name, count := range entries {
+		info, err := s.dmapInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		info.Entries = count
+		result = append(result, info)
+	}
+	sortDMapInfos(result)
+	return result, nil
+}
+
+// countChunkKeys returns the number of the chunks of the chunked values in
+// the fragment.
+func countChunkKeys(f *fragment) int {
+	f.RLock()
+	defer f.RUnlock()
+
+	var count int
+	f.storage.Range(func(_ uint64, e storage.Entry) bool {
+		if isChunkKey(e.Key()) {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// dmapInfo returns the configured policies of the DMap on this member.
+func (s *Service) dmapInfo(name string) (DMapInfo, error) {
+	c := &dmapConfig{}
+	if err := c.load(s.runtimeConfig().DMaps, name); err != nil {
+		return DMapInfo{}, err
+	}
+	return DMapInfo{
+		Name:            name,
+		EvictionPolicy:  c.evictionPolicy,
+		QuotaPolicy:     c.quotaPolicy,
+		TTLDuration:     c.ttlDuration,
+		MaxIdleDuration: c.maxIdleDuration,
+		MaxKeys:         c.maxKeys,
+		MaxInuse:        c.maxInuse,
+	}, nil
+}
+
+func sortDMapInfos(items []DMapInfo) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+}
+
+// ListDMaps returns the DMaps that have a fragment on any cluster member, sorted
+// by name, with their number of entries. The policies are the ones configured
+// on this member.
+func (s *Service) ListDMaps(ctx context.Context) ([]DMapInfo, error) {
+	entries := make(map[string]int)
+	for _, member := range s.rt.Discovery().GetMembers() {
+		var items []DMapInfo
+		if member.CompareByID(s.rt.This()) {
+			local, err := s.listDMapsLocal()
+			if err != nil {
+				return nil, err
+			}
+			items = local
+		} else {
+			cmd := protocol.NewListDMaps().SetLocal().Command(ctx)
+			rc := s.client.Get(member.String())
+			err := rc.Process(ctx, cmd)
+			if err != nil {
+				return nil, protocol.ConvertError(err)
+			}
+			raw, err := cmd.Result()
+			if err != nil {
+				return nil, protocol.ConvertError(err)
+			}
+			for _, item := range raw {
+				var info DMapInfo
+				if err = msgpack.Unmarshal([]byte(item), &info); err != nil {
+					return nil, err
+				}
+				items = append(items, info)
+			}
+		}
+		for _, item := range items {
+			entries[item.Name] += item.Entries
+		}
+	}
+
+	result := make([]DMapInfo, 0, len(entries))
+	for name, count := range entries {
+		info, err := s.dmapInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		info.Entries = count
+		result = append(result, info)
+	}
+	sortDMapInfos(result)
+	return result, nil
+}
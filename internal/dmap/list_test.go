@@ -0,0 +1,76 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_ListDMaps(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.Custom = map[string]config.DMap{
+			"users": {TTLDuration: time.Hour, EvictionPolicy: config.LRUEviction, MaxKeys: 1000},
+		}
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	s2 := newService()
+
+	ctx := context.Background()
+	for _, name := range []string{"users", "orders", "sessions"} {
+		dm, err := s1.NewDMap(name)
+		require.NoError(t, err)
+		_, err = s2.NewDMap(name)
+		require.NoError(t, err)
+		for i := 0; i < 20; i++ {
+			err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+			require.NoError(t, err)
+		}
+	}
+	// It has no entry.
+	_, err := s1.NewDMap("empty")
+	require.NoError(t, err)
+
+	for _, s := range []*Service{s1, s2} {
+		list, err := s.ListDMaps(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+
+		require.Equal(t, "orders", list[0].Name)
+		require.Equal(t, "sessions", list[1].Name)
+		require.Equal(t, "users", list[2].Name)
+		for _, info := range list {
+			require.Equal(t, 20, info.Entries)
+		}
+		require.Equal(t, time.Hour, list[2].TTLDuration)
+		require.Equal(t, config.LRUEviction, list[2].EvictionPolicy)
+		require.Equal(t, 1000, list[2].MaxKeys)
+		require.Equal(t, config.QuotaEvict, list[2].QuotaPolicy)
+	}
+}
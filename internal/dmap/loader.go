@@ -0,0 +1,108 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrLoaderFailed is returned when the Loader of a DMap returns an error.
+var ErrLoaderFailed = errors.New("loader failed")
+
+var (
+	// LoadsTotal is the number of the Loader calls.
+	LoadsTotal = stats.NewInt64Counter()
+
+	// LoadErrorsTotal is the number of the Loader calls that returned an error.
+	LoadErrorsTotal = stats.NewInt64Counter()
+
+	// RefreshesTotal is the number of the entries refreshed in the background.
+	RefreshesTotal = stats.NewInt64Counter()
+)
+
+func (dm *DMap) loaderKey(key string) string {
+	return dm.name + "\x00" + key
+}
+
+// load calls the Loader and stores the loaded value on the cluster.
+func (dm *DMap) load(ctx context.Context, key string) error {
+	LoadsTotal.Increase(1)
+	value, ttl, err := dm.config().loader(ctx, dm.name, key)
+	if err != nil {
+		LoadErrorsTotal.Increase(1)
+		return fmt.Errorf("%w: %v", ErrLoaderFailed, err)
+	}
+	if value == nil {
+		return ErrKeyNotFound
+	}
+
+	pc := &PutConfig{}
+	if ttl > 0 {
+		pc.HasPX = true
+		pc.PX = ttl
+	}
+	return dm.Put(ctx, key, value, pc)
+}
+
+// loadOnMiss loads the key with the Loader on a cache miss. The concurrent
+// misses of a key are collapsed into a single call.
+func (dm *DMap) loadOnMiss(ctx context.Context, hkey uint64, key string) (storage.Entry, error) {
+	_, err, _ := dm.s.loads.Do(dm.loaderKey(key), func() (interface{}, error) {
+		return nil, dm.load(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dm.getOnCluster(hkey, key)
+}
+
+// refreshAhead reloads the entry in the background if it's about to expire.
+func (dm *DMap) refreshAhead(entry storage.Entry, key string) {
+	if dm.config().loader == nil || dm.config().refreshAhead <= 0 || entry.TTL() == 0 {
+		return
+	}
+	// TTL is in milliseconds.
+	remaining := time.Duration(entry.TTL()-time.Now().UnixNano()/1000000) * time.Millisecond
+	if remaining > dm.config().refreshAhead {
+		return
+	}
+
+	lk := dm.loaderKey(key)
+	if _, ok := dm.s.refreshing.LoadOrStore(lk, struct{}{}); ok {
+		// It's already being refreshed.
+		return
+	}
+
+	dm.s.wg.Add(1)
+	go func() {
+		defer dm.s.wg.Done()
+		defer dm.s.refreshing.Delete(lk)
+
+		_, err, _ := dm.s.loads.Do(lk, func() (interface{}, error) {
+			return nil, dm.load(dm.s.ctx, key)
+		})
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to refresh key: %s on DMap: %s: %v", key, dm.name, err)
+			return
+		}
+		RefreshesTotal.Increase(1)
+	}()
+}
@@ -0,0 +1,151 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Loader(t *testing.T) {
+	var calls int64
+	dc := config.DMap{
+		Loader: func(ctx context.Context, dmap, key string) ([]byte, time.Duration, error) {
+			atomic.AddInt64(&calls, 1)
+			switch key {
+			case "missing":
+				return nil, 0, nil
+			case "failing":
+				return nil, 0, errors.New("database is down")
+			}
+			return []byte(fmt.Sprintf("%s-%s", dmap, key)), 0, nil
+		},
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		e, err := dm1.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, []byte("mydmap-"+testutil.ToKey(i)), e.Value())
+	}
+	require.Equal(t, int64(10), atomic.LoadInt64(&calls))
+
+	// The loaded values are stored in the DMap.
+	for i := 0; i < 10; i++ {
+		_, err := dm1.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(10), atomic.LoadInt64(&calls))
+
+	_, err = dm1.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = dm1.Get(ctx, "failing")
+	require.ErrorIs(t, err, ErrLoaderFailed)
+}
+
+func TestDMap_Loader_Singleflight(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	dc := config.DMap{
+		Loader: func(ctx context.Context, dmap, key string) ([]byte, time.Duration, error) {
+			atomic.AddInt64(&calls, 1)
+			<-release
+			return []byte("value"), 0, nil
+		},
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := dm.Get(context.Background(), "mykey")
+			require.NoError(t, err)
+			require.Equal(t, []byte("value"), e.Value())
+		}()
+	}
+
+	<-time.After(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, int64(1), atomic.LoadInt64(&calls))
+}
+
+func TestDMap_Loader_RefreshAhead(t *testing.T) {
+	var calls int64
+	dc := config.DMap{
+		Loader: func(ctx context.Context, dmap, key string) ([]byte, time.Duration, error) {
+			n := atomic.AddInt64(&calls, 1)
+			return []byte(fmt.Sprintf("value-%d", n)), 300 * time.Millisecond, nil
+		},
+		RefreshAhead: 250 * time.Millisecond,
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	e, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value-1"), e.Value())
+
+	// The entry is not about to expire yet.
+	_, err = dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), atomic.LoadInt64(&calls))
+
+	<-time.After(100 * time.Millisecond)
+	e, err = dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value-1"), e.Value())
+
+	require.Eventually(t, func() bool {
+		e, err := dm.Get(ctx, "mykey")
+		return err == nil && string(e.Value()) == "value-2"
+	}, time.Second, 10*time.Millisecond)
+}
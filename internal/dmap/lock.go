@@ -18,13 +18,17 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
-	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 var (
@@ -33,9 +37,22 @@ var (
 
 	// ErrNoSuchLock is returned when the requested lock does not exist
 	ErrNoSuchLock = errors.New("no such lock")
+
+	// ErrInvalidAdminToken is returned when ForceUnlock is called without the
+	// admin token of the cluster, or the cluster has no admin token.
+	ErrInvalidAdminToken = errors.New("invalid admin token")
 )
 
-// unlockKey tries to unlock the lock by verifying the lock with token.
+// isLockOwner reports whether this member is the partition owner of the key.
+// The waiters of a lock are queued on the partition owner, so the lock is
+// released there to notify them.
+func (dm *DMap) isLockOwner(key string) bool {
+	return dm.s.primary.PartitionByHKey(dm.HKey(key)).Owner().CompareByName(dm.s.rt.This())
+}
+
+// unlockKey tries to unlock the lock by verifying the lock with token. It has
+// to be called on the partition owner, the request is sent to the new owner
+// if the partition has moved.
 func (dm *DMap) unlockKey(ctx context.Context, key string, token []byte) error {
 	lkey := dm.name + key
 	// Only one unlockKey should work for a given key.
@@ -47,6 +64,10 @@ func (dm *DMap) unlockKey(ctx context.Context, key string, token []byte) error {
 		}
 	}()
 
+	if !dm.isLockOwner(key) {
+		return dm.Unlock(ctx, key, token)
+	}
+
 	// get the key to check its value
 	entry, err := dm.Get(ctx, key)
 	if errors.Is(err, ErrKeyNotFound) {
@@ -66,13 +87,153 @@ func (dm *DMap) unlockKey(ctx context.Context, key string, token []byte) error {
 	if err != nil {
 		return fmt.Errorf("unlock failed because of delete: %w", err)
 	}
+	dm.s.notifyLockWaiter(lkey)
 	return nil
 }
 
+// LockInfo describes an acquired lock.
+type LockInfo struct {
+	// TokenHash is the hex encoded SHA-256 hash of the holder's token. The
+	// token itself is never returned, it's the credential of the holder.
+	TokenHash string `msgpack:"token_hash"`
+
+	// AcquiredAt is the time of the acquisition in nanoseconds.
+	AcquiredAt int64 `msgpack:"acquired_at"`
+
+	// TTL is the remaining time until the lock is released automatically.
+	// It's zero if the lock has no timeout.
+	TTL time.Duration `msgpack:"ttl"`
+}
+
+func (dm *DMap) localLockInfo(ctx context.Context, key string) (*LockInfo, error) {
+	entry, err := dm.Get(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, ErrNoSuchLock
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &LockInfo{
+		TokenHash:  hashLockToken(entry.Value()),
+		AcquiredAt: entry.Timestamp(),
+	}
+	if entry.TTL() > 0 {
+		info.TTL = time.Until(time.UnixMilli(entry.TTL()))
+		if info.TTL <= 0 {
+			// already expired
+			return nil, ErrNoSuchLock
+		}
+	}
+	return info, nil
+}
+
+// hashLockToken returns the hex encoded SHA-256 hash of a lock token.
+func hashLockToken(token []byte) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:])
+}
+
+// LockInfo returns the token hash, the acquisition time and the remaining TTL of
+// the lock on the key. It returns ErrNoSuchLock if the key is not locked.
+// It redirects the request to the partition owner, if required.
+func (dm *DMap) LockInfo(ctx context.Context, key string) (*LockInfo, error) {
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.localLockInfo(ctx, key)
+	}
+
+	cmd := protocol.NewLockInfo(dm.name, key).Command(dm.s.ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	data, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	info := &LockInfo{}
+	if err = msgpack.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// forceUnlockKey releases the lock without verifying its token. Like
+// unlockKey, it has to be called on the partition owner.
+func (dm *DMap) forceUnlockKey(ctx context.Context, key, adminToken string) error {
+	lkey := dm.name + key
+	dm.s.locker.Lock(lkey)
+	defer func() {
+		err := dm.s.locker.Unlock(lkey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dm.name, err)
+		}
+	}()
+
+	if !dm.isLockOwner(key) {
+		return dm.ForceUnlock(ctx, key, adminToken)
+	}
+
+	_, err := dm.Get(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return ErrNoSuchLock
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = dm.deleteKeys(ctx, key)
+	if err != nil {
+		return fmt.Errorf("force unlock failed because of delete: %w", err)
+	}
+	dm.s.log.V(2).Printf("[WARN] Lock on key: %s on DMap: %s has been released by force", key, dm.name)
+	dm.s.notifyLockWaiter(lkey)
+	return nil
+}
+
+// checkAdminToken verifies the admin token of the cluster. It's refused if
+// the cluster has no admin token.
+func (s *Service) checkAdminToken(token string) error {
+	if s.config.AdminToken == "" {
+		return ErrInvalidAdminToken
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+		return ErrInvalidAdminToken
+	}
+	return nil
+}
+
+// ForceUnlock releases the lock on the key without its token. It's meant for
+// the operators to break a lock whose holder is gone, so it requires the admin
+// token of the cluster. It returns ErrNoSuchLock if the key is not locked.
+// It redirects the request to the partition owner, if required.
+func (dm *DMap) ForceUnlock(ctx context.Context, key, adminToken string) error {
+	if err := dm.s.checkAdminToken(adminToken); err != nil {
+		return err
+	}
+
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.forceUnlockKey(ctx, key, adminToken)
+	}
+
+	cmd := protocol.NewForceUnlock(dm.name, key, adminToken).Command(dm.s.ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
 // Unlock takes key and token and tries to unlock the key.
 // It redirects the request to the partition owner, if required.
 func (dm *DMap) Unlock(ctx context.Context, key string, token []byte) error {
-	hkey := partitions.HKey(dm.name, key)
+	hkey := dm.HKey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
 		return dm.unlockKey(ctx, key, token)
@@ -87,74 +248,161 @@ func (dm *DMap) Unlock(ctx context.Context, key string, token []byte) error {
 	return protocol.ConvertError(cmd.Err())
 }
 
-// tryLock takes a deadline and env and sets a key-value pair by using
-// Put with NX and PX commands. It tries to acquire the lock 100 times per second
-// if the lock is already acquired. It returns ErrLockNotAcquired if the deadline exceeds.
-func (dm *DMap) tryLock(e *env, deadline time.Duration) error {
-	err := dm.put(e)
-	if err == nil {
-		return nil
-	}
-	// If it returns ErrKeyFound, the lock is already acquired.
-	if !errors.Is(err, ErrKeyFound) {
-		// something went wrong
-		return err
-	}
-
-	ctx, cancel := context.WithTimeout(e.ctx, deadline)
-	defer cancel()
+// waitLock sets a key-value pair by using Put with NX and PX commands. It has
+// to be called on the partition owner. If the lock is already acquired, the
+// caller waits in the FIFO queue of the lock and only the first waiter retries.
+// It returns ErrLockNotAcquired if ctx is done before the lock is acquired.
+// The waiter keeps its place in the queue for a while if detach is true, so
+// a member that waits for the lock with the same token can come back.
+func (dm *DMap) waitLock(ctx context.Context, e *env, token string, detach bool) error {
+	lkey := dm.name + e.key
+	w := dm.s.enqueueLockWaiter(lkey, token)
 
-	timer := time.NewTimer(10 * time.Millisecond)
+	timer := time.NewTimer(lockPollInterval)
 	defer timer.Stop()
 
-	// Try to acquire lock.
-LOOP:
 	for {
-		timer.Reset(10 * time.Millisecond)
-		select {
-		case <-timer.C:
-			err = dm.put(e)
-			if errors.Is(err, ErrKeyFound) {
-				// not released by the other process/goroutine. try again.
-				continue
+		if dm.s.isFirstLockWaiter(lkey, w) {
+			err := dm.put(e)
+			if err == nil {
+				// Acquired! The next waiter is notified when it's released.
+				dm.s.removeLockWaiter(lkey, w)
+				return nil
 			}
-			if err != nil {
-				// something went wrong.
+			// If it returns ErrKeyFound, the lock is already acquired.
+			if !errors.Is(err, ErrKeyFound) {
+				// something went wrong
+				dm.s.removeLockWaiter(lkey, w)
 				return err
 			}
-			// Acquired! Quit without error.
-			break LOOP
+		}
+
+		interval := lockPollInterval
+		if !dm.s.isFirstLockWaiter(lkey, w) {
+			// Wake up to drop the waiters that are gone, if nobody notifies.
+			interval = lockWaiterGrace
+		}
+		timer.Reset(interval)
+
+		select {
+		case <-w.notify:
+		case <-timer.C:
 		case <-ctx.Done():
 			// Deadline exceeded. Quit with an error.
+			if detach {
+				dm.s.detachLockWaiter(w)
+			} else {
+				dm.s.removeLockWaiter(lkey, w)
+			}
 			return ErrLockNotAcquired
 		case <-dm.s.ctx.Done():
+			dm.s.removeLockWaiter(lkey, w)
 			return fmt.Errorf("server is gone")
 		}
 	}
-	return nil
 }
 
-// Lock prepares a token and env, then calls tryLock
-func (dm *DMap) Lock(ctx context.Context, key string, timeout, deadline time.Duration) ([]byte, error) {
+// lockWaitSlice returns the longest time that a member waits for a lock on
+// another member in a single command. It is kept under the read timeout of
+// the internal client.
+func (dm *DMap) lockWaitSlice() time.Duration {
+	slice := time.Second
+	if rt := dm.s.config.Client.ReadTimeout; rt > 0 && rt/2 < slice {
+		slice = rt / 2
+	}
+	return slice
+}
+
+// lockConfig controls how a lock is acquired.
+type lockConfig struct {
+	// timeout is the time after which the lock is released automatically.
+	timeout time.Duration
+
+	// deadline is how long to wait for the lock. It's ignored if block is true.
+	deadline time.Duration
+
+	// block makes the caller wait until the lock is acquired or ctx is done.
+	block bool
+
+	// detach keeps the place of the caller in the wait queue after the wait
+	// ends, because the caller is another member that waits in slices.
+	detach bool
+}
+
+// lock acquires the lock with the given token. The waiters are queued on the
+// partition owner. It redirects the request to the partition owner, if
+// required.
+func (dm *DMap) lock(ctx context.Context, key string, token []byte, cfg lockConfig) error {
+	if !cfg.block {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.deadline)
+		defer cancel()
+	}
+	timeout := cfg.timeout
+
+	hexToken := hex.EncodeToString(token)
+	for {
+		hkey := dm.HKey(key)
+		member := dm.s.primary.PartitionByHKey(hkey).Owner()
+		if member.CompareByName(dm.s.rt.This()) {
+			var pc PutConfig
+			pc.HasNX = true
+			if timeout.Milliseconds() != 0 {
+				pc.HasPX = true
+				pc.PX = timeout
+			}
+
+			e := dm.s.newEnv(ctx, 0)
+			e.putConfig = &pc
+			e.dmap = dm.name
+			e.key = key
+			e.hkey = hkey
+			e.value = token
+			return dm.waitLock(ctx, e, hexToken, cfg.detach)
+		}
+
+		// Wait on the partition owner in slices, the owner keeps the place of
+		// this member in the queue between the commands.
+		wait := dm.lockWaitSlice()
+		if d, ok := ctx.Deadline(); ok && time.Until(d) < wait {
+			wait = time.Until(d)
+		}
+		cmd := protocol.NewLock(dm.name, key, wait.Seconds()).SetToken(hexToken)
+		if timeout.Milliseconds() != 0 {
+			cmd.SetPX(timeout.Milliseconds())
+		}
+		rc := dm.s.client.Get(member.String())
+		redisCmd := cmd.Command(dm.s.ctx)
+		err := protocol.ConvertError(rc.Process(ctx, redisCmd))
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || errors.Is(err, server.ErrDeadlineExceeded) {
+			return ErrLockNotAcquired
+		}
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return err
+		}
+	}
+}
+
+func newLockToken() ([]byte, error) {
 	token := make([]byte, 16)
 	_, err := rand.Read(token)
 	if err != nil {
 		return nil, err
 	}
+	return token, nil
+}
 
-	var pc PutConfig
-	pc.HasNX = true
-	if timeout.Milliseconds() != 0 {
-		pc.HasPX = true
-		pc.PX = timeout
+// Lock prepares a token and waits for the lock until deadline.
+func (dm *DMap) Lock(ctx context.Context, key string, timeout, deadline time.Duration) ([]byte, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
 	}
 
-	e := newEnv(ctx, 0)
-	e.putConfig = &pc
-	e.dmap = dm.name
-	e.key = key
-	e.value = token
-	err = dm.tryLock(e, deadline)
+	err = dm.lock(ctx, key, token, lockConfig{timeout: timeout, deadline: deadline})
 	if err != nil {
 		return nil, err
 	}
@@ -162,6 +410,62 @@ func (dm *DMap) Lock(ctx context.Context, key string, timeout, deadline time.Dur
 	return token, nil
 }
 
+// BlockingLock prepares a token and waits for the lock until it's acquired or
+// ctx is done.
+func (dm *DMap) BlockingLock(ctx context.Context, key string, timeout time.Duration) ([]byte, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.lock(ctx, key, token, lockConfig{timeout: timeout, block: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MultiLock acquires locks on the given keys in lexicographical order, so two
+// callers that lock overlapping keys cannot deadlock. deadline is shared by all
+// the keys. If one of the locks cannot be acquired before the deadline, the
+// acquired ones are released and ErrLockNotAcquired is returned. It returns
+// the tokens by key.
+func (dm *DMap) MultiLock(ctx context.Context, keys []string, timeout, deadline time.Duration) (map[string][]byte, error) {
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Strings(sorted)
+
+	end := time.Now().Add(deadline)
+	tokens := make(map[string][]byte)
+	for i, key := range sorted {
+		if i > 0 && sorted[i-1] == key {
+			// Already acquired.
+			continue
+		}
+		remaining := time.Until(end)
+		if remaining <= 0 {
+			dm.releaseLocks(ctx, tokens)
+			return nil, ErrLockNotAcquired
+		}
+		token, err := dm.Lock(ctx, key, timeout, remaining)
+		if err != nil {
+			dm.releaseLocks(ctx, tokens)
+			return nil, err
+		}
+		tokens[key] = token
+	}
+	return tokens, nil
+}
+
+func (dm *DMap) releaseLocks(ctx context.Context, tokens map[string][]byte) {
+	for key, token := range tokens {
+		if err := dm.Unlock(ctx, key, token); err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the lock for key: %s on DMap: %s: %v", key, dm.name, err)
+		}
+	}
+}
+
 // leaseKey tries to update the expiry of the key by verifying token.
 func (dm *DMap) leaseKey(ctx context.Context, key string, token []byte, timeout time.Duration) error {
 	lkey := dm.name + key
@@ -205,7 +509,7 @@ func (dm *DMap) leaseKey(ctx context.Context, key string, token []byte, timeout
 // Lease takes key and token and tries to update the expiry with duration.
 // It redirects the request to the partition owner, if required.
 func (dm *DMap) Lease(ctx context.Context, key string, token []byte, timeout time.Duration) error {
-	hkey := partitions.HKey(dm.name, key)
+	hkey := dm.HKey(key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
 		return dm.leaseKey(ctx, key, token, timeout)
@@ -19,7 +19,9 @@ import (
 	"time"
 
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func (s *Service) unlockCommandHandler(conn redcon.Conn, cmd redcon.Command) {
@@ -71,7 +73,26 @@ func (s *Service) lockCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	}
 
 	deadline := time.Duration(lockCmd.Deadline * float64(time.Second))
-	token, err := dm.Lock(s.ctx, lockCmd.Key, timeout, deadline)
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	var token []byte
+	if lockCmd.Token != "" {
+		token, err = hex.DecodeString(lockCmd.Token)
+	} else {
+		token, err = newLockToken()
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = dm.lock(ctx, lockCmd.Key, token, lockConfig{
+		timeout:  timeout,
+		deadline: deadline,
+		block:    lockCmd.Block,
+		detach:   lockCmd.Token != "",
+	})
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -133,3 +154,56 @@ func (s *Service) plockLeaseCommandHandler(conn redcon.Conn, cmd redcon.Command)
 	}
 	conn.WriteString(protocol.StatusOK)
 }
+
+func (s *Service) lockInfoCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	lockInfoCmd, err := protocol.ParseLockInfoCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getDMap(lockInfoCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	info, err := dm.LockInfo(ctx, lockInfoCmd.Key)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	data, err := msgpack.Marshal(info)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteBulk(data)
+}
+
+func (s *Service) forceUnlockCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	forceUnlockCmd, err := protocol.ParseForceUnlockCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getDMap(forceUnlockCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	err = dm.ForceUnlock(ctx, forceUnlockCmd.Key, forceUnlockCmd.AdminToken)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
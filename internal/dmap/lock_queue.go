@@ -0,0 +1,164 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+)
+
+const (
+	// lockPollInterval is the interval of the retries of the first waiter. The
+	// lock can be released without a notification when its timeout expires.
+	lockPollInterval = 10 * time.Millisecond
+
+	// lockWaiterGrace is how long a waiter keeps its place in the queue after
+	// its command returns. The members that wait for a lock on another member
+	// send a new command with the same token in this period.
+	lockWaiterGrace = 500 * time.Millisecond
+)
+
+// lockWaiter is a caller that waits for a lock on the partition owner.
+type lockWaiter struct {
+	token string
+
+	// notify is signaled when the waiter becomes the first one in the queue.
+	notify chan struct{}
+
+	// detachedAt is the time when the last command of the waiter returned
+	// without acquiring the lock. It's zero while a command waits.
+	detachedAt time.Time
+}
+
+func (w *lockWaiter) signal() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// lockQueue is the FIFO queue of the waiters of a lock. Only the first waiter
+// tries to acquire the lock, so the waiters acquire it in the order of arrival.
+type lockQueue struct {
+	waiters []*lockWaiter
+}
+
+// prune drops the detached waiters that didn't come back in lockWaiterGrace.
+func (q *lockQueue) prune(now time.Time) {
+	waiters := q.waiters[:0]
+	for _, w := range q.waiters {
+		if !w.detachedAt.IsZero() && now.Sub(w.detachedAt) > lockWaiterGrace {
+			continue
+		}
+		waiters = append(waiters, w)
+	}
+	q.waiters = waiters
+}
+
+// enqueueLockWaiter adds a waiter to the end of the queue of the lock. If a
+// detached waiter with the same token is still in the queue, it is returned
+// and keeps its place.
+func (s *Service) enqueueLockWaiter(lkey, token string) *lockWaiter {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	q, ok := s.lockQueues[lkey]
+	if !ok {
+		q = &lockQueue{}
+		s.lockQueues[lkey] = q
+	}
+	for _, w := range q.waiters {
+		if w.token == token {
+			w.detachedAt = time.Time{}
+			return w
+		}
+	}
+	w := &lockWaiter{
+		token:  token,
+		notify: make(chan struct{}, 1),
+	}
+	q.waiters = append(q.waiters, w)
+	return w
+}
+
+// isFirstLockWaiter reports whether w is the first waiter of the lock. It
+// drops the detached waiters that didn't come back in lockWaiterGrace.
+func (s *Service) isFirstLockWaiter(lkey string, w *lockWaiter) bool {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	q, ok := s.lockQueues[lkey]
+	if !ok {
+		return false
+	}
+	q.prune(time.Now())
+	return len(q.waiters) > 0 && q.waiters[0] == w
+}
+
+// removeLockWaiter removes the waiter from the queue and notifies the next one.
+func (s *Service) removeLockWaiter(lkey string, w *lockWaiter) {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	q, ok := s.lockQueues[lkey]
+	if !ok {
+		return
+	}
+	for i, waiter := range q.waiters {
+		if waiter == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(q.waiters) == 0 {
+		delete(s.lockQueues, lkey)
+		return
+	}
+	q.waiters[0].signal()
+}
+
+// detachLockWaiter keeps the place of the waiter for lockWaiterGrace after its
+// command returns.
+func (s *Service) detachLockWaiter(w *lockWaiter) {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	w.detachedAt = time.Now()
+}
+
+// notifyLockWaiter wakes up the first waiter of the lock after it's released.
+func (s *Service) notifyLockWaiter(lkey string) {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	q, ok := s.lockQueues[lkey]
+	if !ok || len(q.waiters) == 0 {
+		return
+	}
+	q.waiters[0].signal()
+}
+
+// pruneLockQueues removes the queues that have only the detached waiters.
+func (s *Service) pruneLockQueues() {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	now := time.Now()
+	for lkey, q := range s.lockQueues {
+		q.prune(now)
+		if len(q.waiters) == 0 {
+			delete(s.lockQueues, lkey)
+		}
+	}
+}
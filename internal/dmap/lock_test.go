@@ -21,9 +21,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -420,3 +422,286 @@ func TestDMap_plockLeaseCommandHandler(t *testing.T) {
 	err = dm.Unlock(ctx, key, token)
 	require.NoError(t, err)
 }
+
+func TestDMap_MultiLock_Standalone(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	keys := []string{"lock.test.bar", "lock.test.foo", "lock.test.bar"}
+	tokens, err := dm.MultiLock(ctx, keys, nilTimeout, time.Second)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+
+	_, err = dm.Lock(ctx, "lock.test.foo", nilTimeout, time.Millisecond)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+
+	for key, token := range tokens {
+		require.NoError(t, dm.Unlock(ctx, key, token))
+	}
+}
+
+func TestDMap_MultiLock_ErrLockNotAcquired_Standalone(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	token, err := dm.Lock(ctx, "lock.test.foo", nilTimeout, time.Second)
+	require.NoError(t, err)
+
+	_, err = dm.MultiLock(ctx, []string{"lock.test.foo", "lock.test.bar"}, nilTimeout, 10*time.Millisecond)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+
+	// lock.test.bar has been released.
+	barToken, err := dm.Lock(ctx, "lock.test.bar", nilTimeout, time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, dm.Unlock(ctx, "lock.test.bar", barToken))
+	require.NoError(t, dm.Unlock(ctx, "lock.test.foo", token))
+}
+
+func TestDMap_MultiLock_Deadline_Exceeded_Standalone(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	keys := []string{"lock.test.foo", "lock.test.bar"}
+	_, err = dm.MultiLock(ctx, keys, nilTimeout, time.Nanosecond)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+
+	// None of the keys is left locked.
+	for _, key := range keys {
+		token, err := dm.Lock(ctx, key, nilTimeout, time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, dm.Unlock(ctx, key, token))
+	}
+}
+
+func lockWaiters(s *Service, lkey string) int {
+	s.lockQueueMtx.Lock()
+	defer s.lockQueueMtx.Unlock()
+
+	q, ok := s.lockQueues[lkey]
+	if !ok {
+		return 0
+	}
+	return len(q.waiters)
+}
+
+func TestDMap_Lock_FIFO_Standalone(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	key := "lock.test.foo"
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	token, err := dm.Lock(ctx, key, nilTimeout, time.Second)
+	require.NoError(t, err)
+
+	acquired := make(chan int, 3)
+	errCh := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			token, err := dm.Lock(ctx, key, nilTimeout, 5*time.Second)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			acquired <- i
+			errCh <- dm.Unlock(ctx, key, token)
+		}(i)
+		// Wait until the caller is queued to keep the order.
+		require.Eventually(t, func() bool {
+			return lockWaiters(s, "lock.test"+key) == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	require.NoError(t, dm.Unlock(ctx, key, token))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-errCh)
+		require.Equal(t, i, <-acquired)
+	}
+	require.Equal(t, 0, lockWaiters(s, "lock.test"+key))
+}
+
+func TestDMap_BlockingLock_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	// Find a key that is owned by the first member.
+	var key string
+	for i := 0; i < 100; i++ {
+		k := "lock.test.foo." + strconv.Itoa(i)
+		if s1.primary.PartitionByHKey(partitions.HKey("lock.test", k)).Owner().CompareByID(s1.rt.This()) {
+			key = k
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+
+	ctx := context.Background()
+	token, err := dm1.Lock(ctx, key, nilTimeout, time.Second)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		// The second member waits on the owner longer than a single command.
+		_, err := dm2.BlockingLock(ctx, key, nilTimeout)
+		errCh <- err
+	}()
+
+	<-time.After(dm2.lockWaitSlice() + 500*time.Millisecond)
+	select {
+	case err = <-errCh:
+		t.Fatalf("BlockingLock returned before the lock is released: %v", err)
+	default:
+	}
+
+	require.NoError(t, dm1.Unlock(ctx, key, token))
+	require.NoError(t, <-errCh)
+
+	t.Run("Canceled", func(t *testing.T) {
+		cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err := dm1.BlockingLock(cctx, key, nilTimeout)
+		require.ErrorIs(t, err, ErrLockNotAcquired)
+	})
+}
+
+func TestDMap_LockInfo_ForceUnlock_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.AdminToken = "secret"
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.AdminToken = "secret"
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		key := "lock.test.foo." + strconv.Itoa(i)
+		before := time.Now()
+		token, err := dm1.Lock(ctx, key, time.Hour, time.Second)
+		require.NoError(t, err)
+
+		info, err := dm2.LockInfo(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, hashLockToken(token), info.TokenHash)
+		require.GreaterOrEqual(t, info.AcquiredAt, before.UnixNano())
+		require.Greater(t, info.TTL, 59*time.Minute)
+		require.LessOrEqual(t, info.TTL, time.Hour)
+
+		// The holder is gone, break the lock. It requires the admin token.
+		require.ErrorIs(t, dm2.ForceUnlock(ctx, key, ""), ErrInvalidAdminToken)
+		require.ErrorIs(t, dm2.ForceUnlock(ctx, key, "wrong"), ErrInvalidAdminToken)
+		require.NoError(t, dm2.ForceUnlock(ctx, key, "secret"))
+
+		_, err = dm1.LockInfo(ctx, key)
+		require.ErrorIs(t, err, ErrNoSuchLock)
+		require.ErrorIs(t, dm1.ForceUnlock(ctx, key, "secret"), ErrNoSuchLock)
+		require.ErrorIs(t, dm1.Unlock(ctx, key, token), ErrNoSuchLock)
+
+		_, err = dm2.Lock(ctx, key, nilTimeout, time.Second)
+		require.NoError(t, err)
+
+		info, err = dm1.LockInfo(ctx, key)
+		require.NoError(t, err)
+		require.Zero(t, info.TTL)
+	}
+}
+
+func TestDMap_ForceUnlock_Without_Admin_Token(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = dm.Lock(ctx, "lock.test.foo", nilTimeout, time.Second)
+	require.NoError(t, err)
+
+	// The cluster has no admin token, ForceUnlock is always refused.
+	require.ErrorIs(t, dm.ForceUnlock(ctx, "lock.test.foo", ""), ErrInvalidAdminToken)
+	_, err = dm.LockInfo(ctx, "lock.test.foo")
+	require.NoError(t, err)
+}
+
+func TestDMap_Unlock_Notifies_On_Partition_Owner(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.AdminToken = "secret"
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.AdminToken = "secret"
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	// Find a key that is owned by the first member.
+	var key string
+	for i := 0; i < 100; i++ {
+		k := "lock.test.foo." + strconv.Itoa(i)
+		if s1.primary.PartitionByHKey(partitions.HKey("lock.test", k)).Owner().CompareByID(s1.rt.This()) {
+			key = k
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+
+	requireNotified := func(w *lockWaiter) {
+		select {
+		case <-w.notify:
+		case <-time.After(time.Second):
+			require.Fail(t, "the waiter on the partition owner is not notified")
+		}
+	}
+
+	// The second member handles the release as if the partition has moved
+	// after the request is routed to it. The waiter on the owner is notified.
+	ctx := context.Background()
+	token, err := dm1.Lock(ctx, key, nilTimeout, time.Second)
+	require.NoError(t, err)
+	w := s1.enqueueLockWaiter(dm1.name+key, "waiter")
+	require.NoError(t, dm2.unlockKey(ctx, key, token))
+	requireNotified(w)
+	s1.removeLockWaiter(dm1.name+key, w)
+
+	_, err = dm1.Lock(ctx, key, nilTimeout, time.Second)
+	require.NoError(t, err)
+	w = s1.enqueueLockWaiter(dm1.name+key, "waiter")
+	require.NoError(t, dm2.forceUnlockKey(ctx, key, "secret"))
+	requireNotified(w)
+}
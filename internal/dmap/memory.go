@@ -0,0 +1,111 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+
+	"github.com/buraksezer/olric/internal/kvstore"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// maxReclaimRuns is the maximum number of the compaction runs to free some
+// space on a fragment before rejecting a write.
+const maxReclaimRuns = 16
+
+var (
+	// ErrOutOfMemory is returned if a write exceeds the memory budget of the
+	// storage engine on the partition owner.
+	ErrOutOfMemory = errors.New("out of memory")
+
+	// OutOfMemoryErrorsTotal is the number of the writes rejected because the
+	// memory budget is exhausted.
+	OutOfMemoryErrorsTotal = stats.NewInt64Counter()
+)
+
+// newMemoryBudget creates the memory budget of this member from the
+// configuration of the default storage engine. It returns nil, if there is
+// no limit.
+func (s *Service) newMemoryBudget() (*kvstore.MemoryBudget, error) {
+	if s.config.DMaps.Engine == nil {
+		return nil, nil
+	}
+	maxInuse, clusterMaxInuse, err := kvstore.MemoryLimits(storage.NewConfig(s.config.DMaps.Engine.Config))
+	if err != nil {
+		return nil, err
+	}
+	if maxInuse == 0 && clusterMaxInuse == 0 {
+		return nil, nil
+	}
+	s.maxInuse, s.clusterMaxInuse = maxInuse, clusterMaxInuse
+	return kvstore.NewMemoryBudget(kvstore.NodeMemoryLimit(maxInuse, clusterMaxInuse, 1)), nil
+}
+
+// updateMemoryBudget divides the cluster-wide memory limit between the
+// members again. It's called after every routing table update.
+func (s *Service) updateMemoryBudget() {
+	numMembers := int(s.rt.NumMembers())
+	if numMembers == 0 {
+		numMembers = 1
+	}
+	s.memoryBudget.SetLimit(kvstore.NodeMemoryLimit(s.maxInuse, s.clusterMaxInuse, numMembers))
+}
+
+// MemoryBudget returns the memory budget of this member. It's nil, if there
+// is no limit.
+func (s *Service) MemoryBudget() *kvstore.MemoryBudget {
+	return s.memoryBudget
+}
+
+// engineConfig returns the storage engine configuration of a new fragment.
+func (dm *DMap) engineConfig() *storage.Config {
+	c := storage.NewConfig(dm.config().engine.Config)
+	if dm.s.memoryBudget == nil {
+		return c
+	}
+	c = c.Copy()
+	c.Add(kvstore.MemoryBudgetKey, dm.s.memoryBudget)
+	return c
+}
+
+// reclaimMemory compacts the fragment to reuse the space of the deleted
+// entries. The fragment has to be locked by the caller.
+func (dm *DMap) reclaimMemory(f *fragment) {
+	for i := 0; i < maxReclaimRuns; i++ {
+		done, err := f.storage.Compaction()
+		if err != nil || done {
+			return
+		}
+	}
+}
+
+// storeWithinBudget calls store, and calls it once more after reclaiming some
+// space on the fragment if the memory budget is exhausted. The fragment has to
+// be locked by the caller.
+func (dm *DMap) storeWithinBudget(f *fragment, store func() error) error {
+	err := store()
+	if !errors.Is(err, storage.ErrOutOfMemory) {
+		return err
+	}
+
+	dm.reclaimMemory(f)
+	err = store()
+	if errors.Is(err, storage.ErrOutOfMemory) {
+		OutOfMemoryErrorsTotal.Increase(1)
+		return ErrOutOfMemory
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/kvstore"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_MemoryBudget(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.DMaps.Engine.Config[kvstore.MaxInuseKey] = 4 << 20
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	require.Equal(t, int64(4<<20), s.MemoryBudget().Limit())
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	oom := OutOfMemoryErrorsTotal.Read()
+	value := make([]byte, 10<<10)
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), value, nil)
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorIs(t, err, ErrOutOfMemory)
+	require.Equal(t, oom+1, OutOfMemoryErrorsTotal.Read())
+}
+
+func TestDMap_MemoryBudget_Reclaim(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.PartitionCount = 1
+	c.DMaps.Engine.Config[kvstore.MaxInuseKey] = 3 << 20
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	value := make([]byte, 10<<10)
+	ctx := context.Background()
+	var count int
+	for ; count < 1000; count++ {
+		err = dm.Put(ctx, testutil.ToKey(count), value, nil)
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorIs(t, err, ErrOutOfMemory)
+
+	for i := 0; i < count; i++ {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	// The space of the deleted entries is reclaimed instead of allocating
+	// new tables.
+	for i := 0; i < count; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), value, nil))
+	}
+	require.LessOrEqual(t, s.MemoryBudget().Allocated(), s.MemoryBudget().Limit())
+}
+
+func TestDMap_MemoryBudget_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	require.Nil(t, s.MemoryBudget())
+}
@@ -0,0 +1,195 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"strings"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/go-redis/redis/v8"
+)
+
+// MigratedEntriesTotal is the number of the entries that are copied to another
+// cluster by this member, see Migrate.
+var MigratedEntriesTotal = stats.NewInt64Counter()
+
+// migrateBatchSize is the number of the entries that are read from a fragment
+// and sent to the target cluster in a single pipeline.
+const migrateBatchSize = 100
+
+// Migrate copies the DMaps to the cluster of the target member, and returns
+// the number of the copied entries. Every member sends the primary copies that
+// it owns, and the target cluster places the keys by its own partition count.
+// So PartitionCount of a cluster is changed by starting a new cluster with the
+// new partition count and migrating the DMaps into it.
+//
+// The DMaps have to be created on the members of the target cluster. The
+// entries keep their TTLs and timestamps, the expired ones are skipped. The
+// tags are not copied. The writes during the migration may be missed,
+// they should be stopped or Migrate should be called again.
+func (s *Service) Migrate(ctx context.Context, target string) (int, error) {
+	var total int
+	for _, member := range s.rt.Discovery().GetMembers() {
+		if member.Analytics {
+			// Analytics replicas don't own any partition.
+			continue
+		}
+		if member.CompareByID(s.rt.This()) {
+			count, err := s.migrateLocal(ctx, target)
+			total += count
+			if err != nil {
+				return total, err
+			}
+			continue
+		}
+
+		cmd := protocol.NewMigrate(target).SetLocal().Command(s.ctx)
+		rc := s.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return total, protocol.ConvertError(err)
+		}
+		count, err := cmd.Result()
+		if err != nil {
+			return total, protocol.ConvertError(err)
+		}
+		total += int(count)
+	}
+	return total, nil
+}
+
+// migrateLocal copies the primary copies of the DMaps owned by this member to
+// the cluster of the target member.
+func (s *Service) migrateLocal(ctx context.Context, target string) (int, error) {
+	var total int
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		if part.OwnerCount() == 0 || !part.Owner().CompareByID(s.rt.This()) {
+			continue
+		}
+
+		var err error
+		part.Map().Range(func(rawName, rawFragment interface{}) bool {
+			name := rawName.(string)
+			if !strings.HasPrefix(name, "dmap.") {
+				// This fragment belongs to a different data structure.
+				return true
+			}
+			dmapName := strings.TrimPrefix(name, "dmap.")
+			var dm *DMap
+			dm, err = s.getDMap(dmapName)
+			if err != nil {
+				dm, err = s.NewTempDMap(dmapName)
+				if err != nil {
+					return false
+				}
+			}
+
+			var count int
+			count, err = dm.migrateFragment(ctx, rawFragment.(*fragment), target)
+			total += count
+			return err == nil
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// migrateFragment sends the entries of the fragment to the target member with
+// DM.PUT, so the target cluster redirects them to the partition owners. The
+// fragment is scanned in batches, the lock is held only while a batch is
+// collected.
+func (dm *DMap) migrateFragment(ctx context.Context, f *fragment, target string) (int, error) {
+	var count int
+	var cursor uint64
+	rc := dm.s.client.Get(target)
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		batch := make([]*protocol.Put, 0, migrateBatchSize)
+		var readErr error
+		f.RLock()
+		next, err := f.storage.Scan(cursor, migrateBatchSize, func(e storage.Entry) bool {
+			if isKeyExpired(e.TTL()) {
+				return true
+			}
+			var entry storage.Entry
+			entry, readErr = dm.readEntry(e)
+			if readErr != nil {
+				return false
+			}
+			// The value may point to the memory of the storage engine.
+			value := make([]byte, len(entry.Value()))
+			copy(value, entry.Value())
+
+			cmd := protocol.NewPut(dm.name, entry.Key(), value).SetTimestamp(entry.Timestamp())
+			switch {
+			case entry.Sliding() != 0:
+				cmd.SetSliding(entry.Sliding())
+			case entry.TTL() != 0:
+				cmd.SetPXAT(entry.TTL())
+			}
+			batch = append(batch, cmd)
+			return true
+		})
+		f.RUnlock()
+		if err == nil {
+			err = readErr
+		}
+		if err != nil {
+			return count, err
+		}
+
+		if len(batch) > 0 {
+			if err = dm.sendMigrateBatch(ctx, rc, batch); err != nil {
+				return count, err
+			}
+			count += len(batch)
+			MigratedEntriesTotal.Increase(int64(len(batch)))
+		}
+		if next == 0 {
+			return count, nil
+		}
+		cursor = next
+	}
+}
+
+func (dm *DMap) sendMigrateBatch(ctx context.Context, rc *redis.Client, batch []*protocol.Put) error {
+	pipe := rc.Pipeline()
+	cmds := make([]*redis.StatusCmd, 0, len(batch))
+	for _, put := range batch {
+		cmd := put.Command(ctx)
+		cmds = append(cmds, cmd)
+		if err := pipe.Process(ctx, cmd); err != nil {
+			return protocol.ConvertError(err)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return protocol.ConvertError(err)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return protocol.ConvertError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/buraksezer/olric/config"
+)
+
+var (
+	// ErrNamespaceReadOnly is returned by the writes to the DMaps of a
+	// read-only namespace.
+	ErrNamespaceReadOnly = errors.New("namespace is read-only")
+
+	// ErrNamespaceQuotaExceeded is returned if a write exceeds the quotas of
+	// the namespace of the DMap.
+	ErrNamespaceQuotaExceeded = errors.New("namespace quota exceeded")
+
+	// ErrNamespaceAccessDenied is returned if a DMap of a namespace with an
+	// ACL is opened without one of its tokens.
+	ErrNamespaceAccessDenied = errors.New("namespace access denied")
+)
+
+// NamespaceUsage is the number of the keys and the in-use memory of the DMaps
+// of a namespace on a member.
+type NamespaceUsage struct {
+	Keys  int
+	Inuse int
+}
+
+// namespaceOf returns the namespace of a DMap, or an empty string if the DMap
+// doesn't belong to a namespace.
+func namespaceOf(name string) string {
+	i := strings.Index(name, config.NamespaceSeparator)
+	if i <= 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// namespaceConfig returns the configuration of the namespace of this DMap.
+func (dm *DMap) namespaceConfig() (config.Namespace, bool) {
+	if dm.namespace == "" {
+		return config.Namespace{}, false
+	}
+	ns, ok := dm.s.config.Namespaces[dm.namespace]
+	return ns, ok
+}
+
+// checkNamespace rejects the writes to the DMaps of a read-only namespace and
+// the writes of the instances opened with a read-only token.
+func (dm *DMap) checkNamespace() error {
+	if dm.readOnly {
+		return ErrNamespaceReadOnly
+	}
+	ns, ok := dm.namespaceConfig()
+	if ok && ns.ReadOnly {
+		return ErrNamespaceReadOnly
+	}
+	return nil
+}
+
+// CheckNamespaceAccess checks the token against the ACL of the namespace of
+// the given DMap and returns true if the token is read-only. The DMaps of a
+// namespace with an ACL cannot be opened without a token.
+func (s *Service) CheckNamespaceAccess(name, token string) (bool, error) {
+	ns, ok := s.config.Namespaces[namespaceOf(name)]
+	if !ok || len(ns.ACL) == 0 {
+		return false, nil
+	}
+	if token == "" {
+		return false, ErrNamespaceAccessDenied
+	}
+	for t, access := range ns.ACL {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return access == config.NamespaceReadOnly, nil
+		}
+	}
+	return false, ErrNamespaceAccessDenied
+}
+
+// NewReadOnlyDMap creates and returns a DMap instance that rejects the writes.
+// It's not registered with the service, the commands of the other members
+// are served by the registered instance.
+func (s *Service) NewReadOnlyDMap(name string) (*DMap, error) {
+	if _, err := s.NewDMap(name); err != nil {
+		return nil, err
+	}
+	dm, err := s.NewTempDMap(name)
+	if err != nil {
+		return nil, err
+	}
+	dm.readOnly = true
+	return dm, nil
+}
+
+// namespaceUsageKey returns the key of the usage counter of a namespace. It
+// cannot collide with the fragment name of a DMap.
+func namespaceUsageKey(namespace string) string {
+	return "namespace." + namespace
+}
+
+// NamespaceUsage returns the usage of the given namespace on this member. It
+// counts the primary copies of the keys.
+func (s *Service) NamespaceUsage(namespace string) NamespaceUsage {
+	return s.usageOf(namespaceUsageKey(namespace)).load()
+}
@@ -0,0 +1,132 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Namespace_MaxKeys(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.Namespaces = map[string]config.Namespace{"team-a": {MaxKeys: 10}}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	// The DMaps of a namespace share its quota.
+	dm1, err := s.NewDMap("team-a/cache")
+	require.NoError(t, err)
+	dm2, err := s.NewDMap("team-a/sessions")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), i, nil))
+		require.NoError(t, dm2.Put(ctx, testutil.ToKey(i), i, nil))
+	}
+	require.Equal(t, NamespaceUsage{Keys: 10, Inuse: s.NamespaceUsage("team-a").Inuse}, s.NamespaceUsage("team-a"))
+
+	err = dm1.Put(ctx, testutil.ToKey(5), 5, nil)
+	require.ErrorIs(t, err, ErrNamespaceQuotaExceeded)
+
+	// Overwriting an existing key is allowed.
+	require.NoError(t, dm1.Put(ctx, testutil.ToKey(0), 42, nil))
+
+	// The deleted keys are released.
+	_, err = dm2.Delete(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+	require.Equal(t, 9, s.NamespaceUsage("team-a").Keys)
+	require.NoError(t, dm1.Put(ctx, testutil.ToKey(5), 5, nil))
+
+	// The other namespaces are not affected.
+	dm3, err := s.NewDMap("team-b/cache")
+	require.NoError(t, err)
+	require.NoError(t, dm3.Put(ctx, testutil.ToKey(5), 5, nil))
+}
+
+func TestDMap_Namespace_ReadOnly(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.Namespaces = map[string]config.Namespace{"reporting": {ReadOnly: true}}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("reporting/cache")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.ErrorIs(t, dm.Put(ctx, "mykey", "myvalue", nil), ErrNamespaceReadOnly)
+	_, err = dm.Delete(ctx, "mykey")
+	require.ErrorIs(t, err, ErrNamespaceReadOnly)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Namespace_ACL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.Namespaces = map[string]config.Namespace{
+		"team-a": {
+			ACL: map[string]config.NamespaceAccess{
+				"writer": config.NamespaceReadWrite,
+				"reader": config.NamespaceReadOnly,
+			},
+		},
+	}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	_, err := s.CheckNamespaceAccess("team-a/cache", "")
+	require.ErrorIs(t, err, ErrNamespaceAccessDenied)
+	_, err = s.CheckNamespaceAccess("team-a/cache", "unknown")
+	require.ErrorIs(t, err, ErrNamespaceAccessDenied)
+
+	readOnly, err := s.CheckNamespaceAccess("team-a/cache", "writer")
+	require.NoError(t, err)
+	require.False(t, readOnly)
+
+	readOnly, err = s.CheckNamespaceAccess("team-a/cache", "reader")
+	require.NoError(t, err)
+	require.True(t, readOnly)
+
+	// The namespaces without an ACL can be accessed without a token.
+	readOnly, err = s.CheckNamespaceAccess("team-b/cache", "")
+	require.NoError(t, err)
+	require.False(t, readOnly)
+
+	ctx := context.Background()
+	dm, err := s.NewReadOnlyDMap("team-a/cache")
+	require.NoError(t, err)
+	require.ErrorIs(t, dm.Put(ctx, "mykey", "myvalue", nil), ErrNamespaceReadOnly)
+	require.ErrorIs(t, dm.Destroy(ctx), ErrNamespaceReadOnly)
+
+	// The registered instance serves the other members.
+	registered, err := s.getDMap("team-a/cache")
+	require.NoError(t, err)
+	require.NoError(t, registered.Put(ctx, "mykey", "myvalue", nil))
+
+	_, err = dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+}
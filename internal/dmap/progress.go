@@ -0,0 +1,41 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import "fmt"
+
+// ProgressError is returned by a long operation that is interrupted by the
+// cancellation of its context. Done of Total steps are completed before the
+// interruption, the operation is not rolled back.
+type ProgressError struct {
+	// Operation is the name of the interrupted operation, like "destroy".
+	Operation string
+
+	// Done and Total are the number of the completed and all steps, the
+	// steps depend on the operation.
+	Done  int
+	Total int
+
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *ProgressError) Error() string {
+	return fmt.Sprintf("%s interrupted after %d of %d steps: %v", e.Operation, e.Done, e.Total, e.Err)
+}
+
+func (e *ProgressError) Unwrap() error {
+	return e.Err
+}
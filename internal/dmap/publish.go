@@ -0,0 +1,62 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrPublisherUnavailable is returned by SetAndPublish if the Pub/Sub service
+// is not running on the partition owner.
+var ErrPublisherUnavailable = errors.New("pub/sub is not available")
+
+// publisher delivers the messages of SetAndPublish to the subscribers. It's
+// implemented by the Pub/Sub service.
+type publisher interface {
+	Publish(ctx context.Context, channel, message string) (int, error)
+}
+
+// SetAndPublish sets the value for the given key and publishes the message to
+// the channel. The partition owner publishes the message after the write is
+// committed, without holding the fragment lock. If the message cannot be
+// published, the write is kept and the error is returned. The caller can retry
+// the call, so the delivery is at-least-once: a subscriber may receive the same
+// message more than once, and the readers may see the new value before the
+// message is delivered.
+func (dm *DMap) SetAndPublish(ctx context.Context, key string, value interface{}, channel, message string, cfg *PutConfig) error {
+	if cfg == nil {
+		cfg = &PutConfig{}
+	}
+	cfg.HasPublish = true
+	cfg.PublishChannel = channel
+	cfg.PublishMessage = message
+	return dm.Put(ctx, key, value, cfg)
+}
+
+// publish publishes the message of a committed write, if there is any. The
+// fragment lock must not be held by the caller.
+func (dm *DMap) publish(e *env) error {
+	if !e.putConfig.HasPublish {
+		return nil
+	}
+	_, err := dm.s.publisher.Publish(e.ctx, e.putConfig.PublishChannel, e.putConfig.PublishMessage)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to publish to channel: %s for key: %s on DMap: %s: %v",
+			e.putConfig.PublishChannel, e.key, dm.name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type testPublisher struct {
+	mtx      sync.Mutex
+	err      error
+	messages map[string][]string
+}
+
+func newTestPublisher() *testPublisher {
+	return &testPublisher{messages: make(map[string][]string)}
+}
+
+func (p *testPublisher) Publish(_ context.Context, channel, message string) (int, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.err != nil {
+		return 0, p.err
+	}
+	p.messages[channel] = append(p.messages[channel], message)
+	return 1, nil
+}
+
+func (p *testPublisher) count(channel string) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return len(p.messages[channel])
+}
+
+func TestDMap_SetAndPublish(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+
+	p := newTestPublisher()
+	s1.publisher = p
+	s2.publisher = p
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.SetAndPublish(ctx, testutil.ToKey(i), testutil.ToVal(i), "updates", testutil.ToKey(i), nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 10, p.count("updates"))
+
+	for i := 0; i < 10; i++ {
+		gr, err := dm1.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), gr.Value())
+	}
+}
+
+func TestDMap_SetAndPublish_Publish_Failed(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	p := newTestPublisher()
+	p.err = errors.New("publish failed")
+	s.publisher = p
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "existing", "old", nil))
+
+	// The write is committed before the message is published.
+	err = dm.SetAndPublish(ctx, "existing", "new", "updates", "existing", nil)
+	require.ErrorIs(t, err, p.err)
+	gr, err := dm.Get(ctx, "existing")
+	require.NoError(t, err)
+	require.Equal(t, "new", string(gr.Value()))
+
+	// Retrying delivers the message.
+	p.mtx.Lock()
+	p.err = nil
+	p.mtx.Unlock()
+	err = dm.SetAndPublish(ctx, "existing", "new", "updates", "existing", nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, p.count("updates"))
+}
+
+func TestDMap_SetAndPublish_PublisherUnavailable(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.SetAndPublish(context.Background(), "mykey", "myvalue", "updates", "mykey", nil)
+	require.ErrorIs(t, err, ErrPublisherUnavailable)
+	_, err = dm.Get(context.Background(), "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/resp"
 	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/codec"
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/go-redis/redis/v8"
 )
@@ -47,6 +48,8 @@ var (
 func prepareTTL(e *env) int64 {
 	var ttl int64
 	switch {
+	case e.putConfig.HasSliding:
+		ttl = (e.putConfig.Sliding.Nanoseconds() + time.Now().UnixNano()) / 1000000
 	case e.putConfig.HasEX:
 		ttl = (e.putConfig.EX.Nanoseconds() + time.Now().UnixNano()) / 1000000
 	case e.putConfig.HasPX:
@@ -74,9 +77,23 @@ func (dm *DMap) putEntryOnFragment(e *env, nt storage.Entry) error {
 			}
 			return err
 		}
+		if !e.repair {
+			dm.recordMutation(Mutation{
+				Kind: MutationExpire,
+				Key:  e.key,
+				TTL:  nt.TTL(),
+			})
+		}
+		if dm.s.analytics.enabled() {
+			if updated, err := e.fragment.storage.Get(e.hkey); err == nil {
+				dm.replicateToAnalytics(e.hkey, updated)
+			}
+		}
 		return nil
 	}
-	err := e.fragment.storage.Put(e.hkey, nt)
+	err := dm.storeWithinBudget(e.fragment, func() error {
+		return e.fragment.storage.Put(e.hkey, nt)
+	})
 	if errors.Is(err, storage.ErrKeyTooLarge) {
 		err = ErrKeyTooLarge
 	}
@@ -90,19 +107,41 @@ func (dm *DMap) putEntryOnFragment(e *env, nt storage.Entry) error {
 	// total number of entries stored during the life of this instance.
 	EntriesTotal.Increase(1)
 
+	dm.forgetTombstone(nt.Key())
+	if !e.repair {
+		dm.recordMutation(Mutation{
+			Kind:      MutationPut,
+			Key:       nt.Key(),
+			Value:     nt.Value(),
+			TTL:       nt.TTL(),
+			Timestamp: nt.Timestamp(),
+			codec:     nt.Codec(),
+		})
+	}
+	dm.replicateToAnalytics(e.hkey, nt)
+
 	return nil
 }
 
-func (dm *DMap) prepareEntry(e *env) storage.Entry {
+func (dm *DMap) prepareEntry(e *env) (storage.Entry, error) {
 	nt := e.fragment.storage.NewEntry()
 	nt.SetKey(e.key)
-	nt.SetValue(e.value)
-	nt.SetTTL(prepareTTL(e))
+	if e.putConfig.Chunked {
+		// The manifest of a chunked value is stored as it is.
+		nt.SetValue(e.value)
+		nt.SetCodec(codec.Chunked)
+	} else if err := dm.encodeValue(nt, e.value); err != nil {
+		return nil, err
+	}
+	nt.SetTTL(jitterTTL(prepareTTL(e), e.putConfig))
+	if e.putConfig.HasSliding {
+		nt.SetSliding(toMilliseconds(e.putConfig.Sliding))
+	}
 	nt.SetTimestamp(e.timestamp)
-	return nt
+	return nt, nil
 }
 
-func (dm *DMap) putOnReplicaFragment(e *env) error {
+func (dm *DMap) putOnReplicaFragment(e *env, keepTags bool) error {
 	part := dm.getPartitionByHKey(e.hkey, partitions.BACKUP)
 	f, err := dm.loadOrCreateFragment(part)
 	if err != nil {
@@ -113,7 +152,9 @@ func (dm *DMap) putOnReplicaFragment(e *env) error {
 	f.Lock()
 	defer f.Unlock()
 
-	err = f.storage.PutRaw(e.hkey, e.value)
+	err = dm.storeWithinBudget(f, func() error {
+		return f.storage.PutRaw(e.hkey, e.value)
+	})
 	if errors.Is(err, storage.ErrKeyTooLarge) {
 		err = ErrKeyTooLarge
 	}
@@ -124,26 +165,43 @@ func (dm *DMap) putOnReplicaFragment(e *env) error {
 		return err
 	}
 
+	if !keepTags {
+		// The tags are kept on the backups too, they are needed after a
+		// failover.
+		f.tags.set(e.hkey, e.key, e.putConfig.Tags)
+	}
+
 	// total number of entries stored during the life of this instance.
 	EntriesTotal.Increase(1)
 
 	return nil
 }
 
+// putEntryCommand returns the command that replicates the entry of the write
+// to a backup owner, along with its tags.
+func (dm *DMap) putEntryCommand(e *env, data []byte) *protocol.PutEntry {
+	cmd := protocol.NewPutEntry(dm.name, e.key, data).
+		SetEpoch(dm.epochOf(e.hkey)).
+		SetTimestamp(e.timestamp)
+	if e.putConfig.OnlyUpdateTTL {
+		return cmd.SetKeepTags()
+	}
+	return cmd.SetTags(e.putConfig.Tags...)
+}
+
 func (dm *DMap) asyncPutOnBackup(e *env, data []byte, owner discovery.Member) {
 	defer dm.s.wg.Done()
 
 	rc := dm.s.client.Get(owner.String())
-	cmd := protocol.NewPutEntry(e.dmap, e.key, data).Command(dm.s.ctx)
+	cmd := dm.putEntryCommand(e, data).Command(dm.s.ctx)
 	err := rc.Process(dm.s.ctx, cmd)
-	if err != nil {
-		if dm.s.log.V(3).Ok() {
-			dm.s.log.V(3).Printf("[ERROR] Failed to create replica in async mode: %v", err)
-		}
-		return
+	if err == nil {
+		err = cmd.Err()
 	}
-	err = cmd.Err()
 	if err != nil {
+		err = protocol.ConvertError(err)
+		// The next writes are refused if this member is not the owner anymore.
+		dm.fenceOnStaleEpoch(e.hkey, err)
 		if dm.s.log.V(3).Ok() {
 			dm.s.log.V(3).Printf("[ERROR] Failed to create replica in async mode: %v", err)
 		}
@@ -180,12 +238,19 @@ func (dm *DMap) syncPutOnCluster(e *env, nt storage.Entry) error {
 	owners := dm.s.backup.PartitionOwnersByHKey(e.hkey)
 	for _, owner := range owners {
 		rc := dm.s.client.Get(owner.String())
-		cmd := protocol.NewPutEntry(dm.name, e.key, encodedEntry).Command(dm.s.ctx)
+		cmd := dm.putEntryCommand(e, encodedEntry).Command(dm.s.ctx)
 		err := rc.Process(dm.s.ctx, cmd)
 		if err != nil {
-			return protocol.ConvertError(err)
+			err = protocol.ConvertError(err)
+			dm.fenceOnStaleEpoch(e.hkey, err)
+			return err
 		}
 		err = protocol.ConvertError(cmd.Err())
+		if errors.Is(err, ErrStaleEpoch) {
+			// This member is not the partition owner anymore, don't apply the write.
+			dm.fenceOnStaleEpoch(e.hkey, err)
+			return err
+		}
 		if err != nil {
 			if dm.s.log.V(3).Ok() {
 				dm.s.log.V(3).Printf("[ERROR] Failed to call put command on %s for DMap: %s: %v", owner, e.dmap, err)
@@ -202,7 +267,7 @@ func (dm *DMap) syncPutOnCluster(e *env, nt storage.Entry) error {
 	} else {
 		successful++
 	}
-	if successful >= dm.s.config.WriteQuorum {
+	if successful >= dm.s.runtimeConfig().WriteQuorum {
 		return nil
 	}
 	return ErrWriteQuorum
@@ -225,12 +290,12 @@ func (dm *DMap) setLRUEvictionStats(e *env) error {
 		return nil
 	}
 
-	if dm.config.maxKeys > 0 {
+	if dm.config().maxKeys > 0 {
 		// MaxKeys controls maximum key count owned by this node.
 		// We need ownedPartitionCount property because every partition
 		// manages itself independently. So if you set MaxKeys=70 and
 		// your partition count is 7, every partition 10 keys at maximum.
-		if st.Length > 0 && st.Length >= dm.config.maxKeys/int(ownedPartitionCount) {
+		if st.Length > 0 && st.Length >= dm.config().maxKeys/int(ownedPartitionCount) {
 			err := dm.evictKeyWithLRU(e)
 			if err != nil {
 				return err
@@ -238,13 +303,13 @@ func (dm *DMap) setLRUEvictionStats(e *env) error {
 		}
 	}
 
-	if dm.config.maxInuse > 0 {
+	if dm.config().maxInuse > 0 {
 		// MaxInuse controls maximum in-use memory of partitions on this node.
 		// We need ownedPartitionCount property because every partition
 		// manages itself independently. So if you set MaxInuse=70M(in bytes) and
 		// your partition count is 7, every partition consumes 10M in-use space at maximum.
 		// WARNING: Actual allocated memory can be different.
-		if st.Inuse > 0 && st.Inuse >= dm.config.maxInuse/int(ownedPartitionCount) {
+		if st.Inuse > 0 && st.Inuse >= dm.config().maxInuse/int(ownedPartitionCount) {
 			err := dm.evictKeyWithLRU(e)
 			if err != nil {
 				return err
@@ -289,6 +354,26 @@ func (dm *DMap) checkPutConditions(e *env) error {
 	return nil
 }
 
+// prepareWriteOp checks the conditions of the write and creates its WriteOp
+// before the Writer is called in write-through mode. The write is checked
+// again before it's applied.
+func (dm *DMap) prepareWriteOp(e *env) (config.WriteOp, error) {
+	e.fragment.RLock()
+	defer e.fragment.RUnlock()
+
+	if err := dm.checkOwnerEpoch(e.hkey); err != nil {
+		return config.WriteOp{}, err
+	}
+	if err := dm.checkPutConditions(e); err != nil {
+		return config.WriteOp{}, err
+	}
+	nt, err := dm.prepareEntry(e)
+	if err != nil {
+		return config.WriteOp{}, err
+	}
+	return dm.newWriteOp(nt)
+}
+
 func (dm *DMap) putOnCluster(e *env) error {
 	part := dm.getPartitionByHKey(e.hkey, partitions.PRIMARY)
 	f, err := dm.loadOrCreateFragment(part)
@@ -296,26 +381,93 @@ func (dm *DMap) putOnCluster(e *env) error {
 		return err
 	}
 
+	if dm.config() != nil && dm.config().valueSchema != nil && !e.putConfig.OnlyUpdateTTL {
+		if err = dm.config().valueSchema.ValidateBytes(e.value); err != nil {
+			return err
+		}
+	}
+
+	if e.putConfig.HasPublish && dm.s.publisher == nil {
+		return ErrPublisherUnavailable
+	}
+
 	e.fragment = f
+	var op config.WriteOp
+	writeThrough := dm.writerEnabled(config.WriteThrough) && !e.putConfig.OnlyUpdateTTL
+	if writeThrough {
+		unlock := dm.lockKeyForWriter(e.key)
+		defer unlock()
+
+		if op, err = dm.prepareWriteOp(e); err != nil {
+			return err
+		}
+		if err = dm.writeThrough(e.ctx, op); err != nil {
+			return err
+		}
+	}
+
 	f.Lock()
 	defer f.Unlock()
 
+	if err = dm.checkOwnerEpoch(e.hkey); err != nil {
+		return err
+	}
+
+	f.recordHotKey(e.hkey, e.key)
+
 	if err = dm.checkPutConditions(e); err != nil {
 		return err
 	}
 
-	if dm.config != nil {
-		if dm.config.ttlDuration.Seconds() != 0 && e.timeout.Seconds() == 0 {
-			e.timeout = dm.config.ttlDuration
+	if err = dm.checkQuotas(e); err != nil {
+		return err
+	}
+
+	if dm.config() != nil {
+		if dm.config().ttlDuration.Seconds() != 0 && e.timeout.Seconds() == 0 {
+			e.timeout = dm.config().ttlDuration
 		}
-		if dm.config.evictionPolicy == config.LRUEviction {
-			if err = dm.setLRUEvictionStats(e); err != nil {
-				return err
+		if dm.config().evictionPolicy == config.LRUEviction {
+			if dm.config().quotaPolicy != config.QuotaReject {
+				if err = dm.setLRUEvictionStats(e); err != nil {
+					return err
+				}
+			}
+			// Evict the keys before the memory budget is exhausted.
+			if dm.s.memoryBudget.UnderPressure() && e.fragment.storage.Stats().Length > 0 {
+				if err = dm.evictKeyWithLRU(e); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
-	nt := dm.prepareEntry(e)
+	nt, err := dm.prepareEntry(e)
+	if err != nil {
+		return err
+	}
+
+	if dm.writerEnabled(config.WriteBehind) && !e.putConfig.OnlyUpdateTTL {
+		if op, err = dm.newWriteOp(nt); err != nil {
+			return err
+		}
+	}
+
+	if err = dm.writeEntry(e, nt); err != nil {
+		return err
+	}
+
+	if !e.putConfig.OnlyUpdateTTL {
+		// A write without tags clears the previous tags of the key.
+		f.tags.set(e.hkey, e.key, e.putConfig.Tags)
+		dm.writeBehind(op)
+	}
+	return nil
+}
+
+// writeEntry stores the entry on this member and replicates it to the backup
+// owners. The fragment lock has to be held by the caller.
+func (dm *DMap) writeEntry(e *env, nt storage.Entry) error {
 	if dm.s.config.ReplicaCount > config.MinimumReplicaCount {
 		switch dm.s.config.ReplicationMode {
 		case config.AsyncReplicationMode:
@@ -329,12 +481,16 @@ func (dm *DMap) putOnCluster(e *env) error {
 			return fmt.Errorf("invalid replication mode: %v", dm.s.config.ReplicationMode)
 		}
 	}
-
 	// single replica
 	return dm.putEntryOnFragment(e, nt)
 }
 
 func (dm *DMap) writePutCommand(e *env) (*redis.StatusCmd, error) {
+	if e.putConfig.OnlyUpdateTTL {
+		// Expire must not overwrite the value on the partition owner.
+		return protocol.NewPExpire(e.dmap, e.key, e.timeout).Command(dm.s.ctx), nil
+	}
+
 	cmd := protocol.NewPut(e.dmap, e.key, e.value)
 	switch {
 	case e.putConfig.HasEX:
@@ -354,17 +510,58 @@ func (dm *DMap) writePutCommand(e *env) (*redis.StatusCmd, error) {
 		cmd.SetXX()
 	}
 
+	if len(e.putConfig.Tags) > 0 {
+		cmd.SetTags(e.putConfig.Tags...)
+	}
+
+	if e.putConfig.HasTimestamp {
+		cmd.SetTimestamp(e.timestamp)
+	}
+	cmd.SetClock(dm.s.clock.Now())
+
+	if e.putConfig.HasTTLJitter {
+		cmd.SetJitter(e.putConfig.TTLJitter)
+	}
+
+	if e.putConfig.HasSliding {
+		cmd.SetSliding(toMilliseconds(e.putConfig.Sliding))
+	}
+
+	if e.putConfig.HasPublish {
+		cmd.SetPublish(e.putConfig.PublishChannel, e.putConfig.PublishMessage)
+	}
+
+	if e.putConfig.Chunked {
+		cmd.SetChunked()
+	}
+
 	return cmd.Command(dm.s.ctx), nil
 }
 
 // put controls every write operation in Olric. It redirects the requests to its owner,
 // if the key belongs to another host.
 func (dm *DMap) put(e *env) error {
-	e.hkey = partitions.HKey(e.dmap, e.key)
+	if err := dm.checkWrite(parentKey(e.key)); err != nil {
+		return err
+	}
+	if err := dm.checkValueSize(e); err != nil {
+		return err
+	}
+
+	if err := dm.checkRateLimit(e.ctx, e.key, true); err != nil {
+		return err
+	}
+
+	e.hkey = dm.HKey(e.key)
 	member := dm.s.primary.PartitionByHKey(e.hkey).Owner()
 	if member.CompareByName(dm.s.rt.This()) {
 		// We are on the partition owner.
-		return dm.putOnCluster(e)
+		defer dm.observeSLO(time.Now())
+		if err := dm.putOnCluster(e); err != nil {
+			return err
+		}
+		// The message is published after the write is committed.
+		return dm.publish(e)
 	}
 
 	// Redirect to the partition owner.
@@ -394,6 +591,27 @@ type PutConfig struct {
 	OnlyUpdateTTL bool
 	HasTimestamp  bool
 	Timestamp     int64
+	Tags          []string
+
+	// HasTTLJitter cuts a random part of the TTL, up to TTLJitter percent of
+	// it, so the keys that are written together don't expire together.
+	HasTTLJitter bool
+	TTLJitter    float64
+
+	// HasSliding sets the TTL to Sliding and resets it on every read on the
+	// partition owner. It replaces EX, PX, EXAT and PXAT.
+	HasSliding bool
+	Sliding    time.Duration
+
+	// HasPublish makes the partition owner publish PublishMessage to
+	// PublishChannel after storing the value, see SetAndPublish.
+	HasPublish     bool
+	PublishChannel string
+	PublishMessage string
+
+	// Chunked marks the value as the manifest of a value that is split into
+	// chunks. It's set internally, see ChunkSize.
+	Chunked bool
 }
 
 // Put sets the value for the given key. It overwrites any previous value
@@ -414,7 +632,7 @@ func (dm *DMap) Put(ctx context.Context, key string, value interface{}, cfg *Put
 		cfg = &PutConfig{}
 	}
 
-	e := newEnv(ctx, cfg.Timestamp)
+	e := dm.s.newEnv(ctx, cfg.Timestamp)
 	e.putConfig = cfg
 	e.dmap = dm.name
 	e.key = key
@@ -426,5 +644,8 @@ func (dm *DMap) Put(ctx context.Context, key string, value interface{}, cfg *Put
 	}
 
 	copy(e.value[:], valueBuf.Bytes())
+	if dm.chunkingEnabled(e) {
+		return dm.putChunked(e)
+	}
 	return dm.put(e)
 }
@@ -15,13 +15,17 @@
 package dmap
 
 import (
+	"errors"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
 	"github.com/tidwall/redcon"
 )
 
+var errTimestampNotAllowed = errors.New("TS is reserved for the cluster members")
+
 func (s *Service) putCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	putCmd, err := protocol.ParsePutCommand(cmd)
 	if err != nil {
@@ -54,12 +58,52 @@ func (s *Service) putCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		pc.PXAT = time.Duration(putCmd.PXAT * int64(time.Millisecond))
 	}
 
-	e := newEnv(s.ctx, 0)
+	pc.Tags = putCmd.Tags
+	if putCmd.Jitter != 0 {
+		pc.HasTTLJitter = true
+		pc.TTLJitter = putCmd.Jitter
+	}
+	if putCmd.Sliding != 0 {
+		pc.HasSliding = true
+		pc.Sliding = time.Duration(putCmd.Sliding * int64(time.Millisecond))
+	}
+	if putCmd.Timestamp != 0 {
+		if !server.IsMemberConnection(conn) {
+			// The entries are versioned by the cluster, TS is only sent by
+			// the members, see Migrate.
+			protocol.WriteError(conn, errTimestampNotAllowed)
+			return
+		}
+		pc.HasTimestamp = true
+		pc.Timestamp = putCmd.Timestamp
+	}
+	pc.Chunked = putCmd.Chunked
+	if putCmd.Publish {
+		pc.HasPublish = true
+		pc.PublishChannel = putCmd.PublishChannel
+		pc.PublishMessage = putCmd.PublishMessage
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	if putCmd.Clock != 0 {
+		if err = s.clock.Update(putCmd.Clock); err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+	}
+	e := s.newEnv(clientContext(ctx, conn), putCmd.Timestamp)
 	e.putConfig = &pc
 	e.dmap = putCmd.DMap
 	e.key = putCmd.Key
 	e.value = putCmd.Value
-	err = dm.put(e)
+	if dm.chunkingEnabled(e) && len(e.value) > dm.config().chunkSize {
+		// The value is written by a client that doesn't split it.
+		err = dm.putChunked(e)
+	} else {
+		err = dm.put(e)
+	}
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -80,12 +124,23 @@ func (s *Service) putEntryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	e := newEnv(s.ctx, 0)
-	e.hkey = partitions.HKey(putEntryCmd.DMap, putEntryCmd.Key)
+	e := s.newEnv(s.ctx, 0)
+	e.hkey = dm.HKey(putEntryCmd.Key)
 	e.dmap = putEntryCmd.DMap
 	e.key = putEntryCmd.Key
 	e.value = putEntryCmd.Value
-	err = dm.putOnReplicaFragment(e)
+	e.putConfig.Tags = putEntryCmd.Tags
+	if err = checkEpoch(dm.getPartitionByHKey(e.hkey, partitions.BACKUP), putEntryCmd.Epoch); err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	// Keep the clock of this member ahead of the entries of the primary owner,
+	// so the writes after a failover win against them.
+	if err = s.clock.Update(putEntryCmd.Timestamp); err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	err = dm.putOnReplicaFragment(e, putEntryCmd.KeepTags)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -18,12 +18,14 @@ import (
 	"context"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/schema"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
@@ -76,6 +78,47 @@ func TestDMap_Put_Cluster(t *testing.T) {
 	}
 }
 
+func TestDMap_Put_OnlyUpdateTTL_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := s1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	dm2, err := s2.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		// Some of the keys are forwarded to the partition owner.
+		e := &env{
+			ctx:       ctx,
+			putConfig: &PutConfig{OnlyUpdateTTL: true},
+			timestamp: time.Now().UnixNano(),
+			kind:      partitions.PRIMARY,
+			dmap:      dm2.name,
+			key:       testutil.ToKey(i),
+			timeout:   time.Hour,
+		}
+		err = dm2.put(e)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		gr, err := dm1.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), gr.Value())
+		require.NotEqual(t, int64(0), gr.TTL())
+	}
+}
+
 func TestDMap_Put_AsyncReplicationMode(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	defer cluster.Shutdown()
@@ -372,3 +415,83 @@ func TestDMap_Put_ErrEntryTooLarge(t *testing.T) {
 	err = dm.Put(ctx, "key", data, nil)
 	require.ErrorIs(t, err, ErrEntryTooLarge)
 }
+
+func TestDMap_Put_KeyValidation(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+		KeyPattern: "^user:",
+		KeyValidator: func(key string) error {
+			if key == "user:admin" {
+				return errors.New("reserved key")
+			}
+			return nil
+		},
+	}}
+	require.NoError(t, c.DMaps.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm.Put(ctx, "user:1", "value", nil)
+	require.NoError(t, err)
+
+	err = dm.Put(ctx, "garbage", "value", nil)
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	err = dm.Put(ctx, "user:admin", "value", nil)
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestDMap_Put_ValueSchema(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+			ValueSchema: `{
+				"type": "object",
+				"required": ["id"],
+				"properties": {
+					"id": {"type": "integer", "minimum": 1},
+					"name": {"type": "string"}
+				}
+			}`,
+		}}
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		value := []byte(fmt.Sprintf(`{"id": %d, "name": "foobar"}`, i+1))
+		err = dm1.Put(ctx, testutil.ToKey(i), value, nil)
+		require.NoError(t, err)
+	}
+
+	// Some of the keys are owned by the other member.
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), []byte(`{"name": "foobar"}`), nil)
+		require.ErrorIs(t, err, schema.ErrMismatch)
+
+		err = dm1.Put(ctx, testutil.ToKey(i), []byte(`{"id": 0}`), nil)
+		require.ErrorIs(t, err, schema.ErrMismatch)
+	}
+
+	err = dm1.Put(ctx, "mykey", "garbage", nil)
+	require.ErrorIs(t, err, schema.ErrMismatch)
+}
@@ -0,0 +1,248 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrResponseTooLarge is returned by Query if the result doesn't fit in a
+// single response. See config.Config.MaxResponseSize.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// queryOnFragment returns the entries in the fragment that match the filter.
+// The chunked values are read from the cluster after the fragment is released.
+func (dm *DMap) queryOnFragment(ctx context.Context, f *fragment, filter *Filter) ([]storage.Entry, error) {
+	var result []storage.Entry
+	var chunked []string
+	var err error
+	f.RLock()
+	f.storage.Range(func(_ uint64, e storage.Entry) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if isKeyExpired(e.TTL()) || isChunkKey(e.Key()) {
+			return true
+		}
+		if e.Codec() == codec.Chunked {
+			chunked = append(chunked, e.Key())
+			return true
+		}
+		// Copy the entry, the storage engine may reuse the underlying memory.
+		entry := dm.engine.NewEntry()
+		entry.Decode(e.Encode())
+		entry, err = dm.readEntry(entry)
+		if err != nil {
+			return false
+		}
+		if filter.Match(entry.Value()) {
+			result = append(result, entry)
+		}
+		return true
+	})
+	f.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		CanceledOperationsTotal.Increase(1)
+		return nil, err
+	}
+
+	for _, key := range chunked {
+		entry, err := dm.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(entry.Value()) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// queryLocal runs the query on the partitions owned by this member, or on the
+// copy of the DMap if this member is an analytics replica. It starts from the
+// partition cursor and stops at a partition boundary after the result
+// reaches config.Config.MaxResponseSize. It returns the cursor of the next
+// page, zero if the result is complete.
+func (dm *DMap) queryLocal(ctx context.Context, filter *Filter, cursor uint64) ([]storage.Entry, uint64, error) {
+	if dm.s.rt.This().Analytics {
+		entries, err := dm.queryAnalytics(ctx, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		page, next := dm.paginate(entries, cursor, dm.s.config.PartitionCount)
+		return page, next, nil
+	}
+
+	var result []storage.Entry
+	var size int
+	for partID := cursor; partID < dm.s.config.PartitionCount; partID++ {
+		if dm.s.config.MaxResponseSize > 0 && size >= dm.s.config.MaxResponseSize {
+			return result, partID, nil
+		}
+		part := dm.s.primary.PartitionByID(partID)
+		if !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		entries, err := dm.queryOnFragment(ctx, f, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, e := range entries {
+			size += entrySize(e)
+		}
+		result = append(result, entries...)
+	}
+	return result, 0, nil
+}
+
+// queryOnMember runs the query on the partitions owned by the given member.
+func (dm *DMap) queryOnMember(ctx context.Context, member discovery.Member, expr string, cursor uint64) ([]storage.Entry, uint64, error) {
+	cmd := protocol.NewQueryPage(dm.name, expr, cursor).SetLocal().Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, 0, protocol.ConvertError(err)
+	}
+	raw, next, err := cmd.Result()
+	if err != nil {
+		return nil, 0, protocol.ConvertError(err)
+	}
+	var result []storage.Entry
+	for _, item := range raw {
+		entry := dm.engine.NewEntry()
+		entry.Decode([]byte(item))
+		result = append(result, entry)
+	}
+	return result, next, nil
+}
+
+// entrySize returns the approximate size of the entry in a response.
+func entrySize(e storage.Entry) int {
+	return len(e.Key()) + len(e.Value())
+}
+
+// paginate returns the entries of the partitions in [from, limit) that fit in
+// a response, sorted by partition. The page is cut at a partition boundary
+// after config.Config.MaxResponseSize, it contains one partition at least. It
+// returns the cursor of the next page, zero if the page is the last one.
+func (dm *DMap) paginate(entries []storage.Entry, from, limit uint64) ([]storage.Entry, uint64) {
+	type item struct {
+		partID uint64
+		entry  storage.Entry
+	}
+	items := make([]item, 0, len(entries))
+	for _, e := range entries {
+		partID := dm.s.primary.PartitionIDByHKey(dm.HKey(e.Key()))
+		if partID < from || partID >= limit {
+			continue
+		}
+		items = append(items, item{partID: partID, entry: e})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].partID < items[j].partID
+	})
+
+	next := limit
+	var size int
+	page := make([]storage.Entry, 0, len(items))
+	for i, it := range items {
+		if dm.s.config.MaxResponseSize > 0 && size >= dm.s.config.MaxResponseSize && it.partID != items[i-1].partID {
+			next = it.partID
+			break
+		}
+		size += entrySize(it.entry)
+		page = append(page, it.entry)
+	}
+	if next >= dm.s.config.PartitionCount {
+		next = 0
+	}
+	return page, next
+}
+
+// Query returns the entries whose values match the filter expression. The
+// filter runs on the partition owners, so only the matching entries are sent
+// over the network. If the cluster has analytics replicas, the query runs on
+// one of them instead. See Filter for the syntax. It returns
+// ErrResponseTooLarge if the result exceeds config.Config.MaxResponseSize,
+// use QueryPage then.
+func (dm *DMap) Query(ctx context.Context, expr string) ([]storage.Entry, error) {
+	result, next, err := dm.QueryPage(ctx, expr, 0)
+	if err != nil {
+		return nil, err
+	}
+	if next != 0 {
+		return nil, ErrResponseTooLarge
+	}
+	return result, nil
+}
+
+// QueryPage is like Query, but it returns the result in pages of
+// config.Config.MaxResponseSize. The cursor of the first page is zero, it
+// returns the cursor of the next page, or zero if the page is the last one.
+func (dm *DMap) QueryPage(ctx context.Context, expr string, cursor uint64) ([]storage.Entry, uint64, error) {
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, next, ok, err := dm.queryOnAnalyticsReplicas(ctx, expr, filter, cursor)
+	if ok {
+		return result, next, err
+	}
+
+	// The members stop at different partitions, the page ends where the
+	// first one stopped.
+	limit := dm.s.config.PartitionCount
+	for _, member := range dm.s.rt.Discovery().GetMembers() {
+		if member.Analytics {
+			continue
+		}
+		var entries []storage.Entry
+		if member.CompareByID(dm.s.rt.This()) {
+			entries, next, err = dm.queryLocal(ctx, filter, cursor)
+		} else {
+			entries, next, err = dm.queryOnMember(ctx, member, expr, cursor)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if next != 0 && next < limit {
+			limit = next
+		}
+		result = append(result, entries...)
+	}
+	page, next := dm.paginate(result, cursor, limit)
+	return page, next, nil
+}
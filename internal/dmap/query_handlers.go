@@ -0,0 +1,116 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) queryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	queryCmd, err := protocol.ParseQueryCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getDMap(queryCmd.DMap)
+	if errors.Is(err, ErrDMapNotFound) && queryCmd.Local {
+		// This member has no entry of the DMap.
+		conn.WriteArray(0)
+		return
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	var entries []storage.Entry
+	if queryCmd.Local {
+		var filter *Filter
+		var next uint64
+		filter, err = ParseFilter(queryCmd.Filter)
+		if err == nil {
+			entries, next, err = dm.queryLocal(ctx, filter, 0)
+		}
+		if err == nil && next != 0 {
+			err = ErrResponseTooLarge
+		}
+	} else {
+		entries, err = dm.Query(ctx, queryCmd.Filter)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(entries))
+	for _, e := range entries {
+		conn.WriteBulk(e.Encode())
+	}
+}
+
+func (s *Service) queryPageCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	queryPageCmd, err := protocol.ParseQueryPageCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getDMap(queryPageCmd.DMap)
+	if errors.Is(err, ErrDMapNotFound) && queryPageCmd.Local {
+		// This member has no entry of the DMap.
+		conn.WriteArray(2)
+		conn.WriteBulkString("0")
+		conn.WriteArray(0)
+		return
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	var entries []storage.Entry
+	var next uint64
+	if queryPageCmd.Local {
+		var filter *Filter
+		filter, err = ParseFilter(queryPageCmd.Filter)
+		if err == nil {
+			entries, next, err = dm.queryLocal(ctx, filter, queryPageCmd.Cursor)
+		}
+	} else {
+		entries, next, err = dm.QueryPage(ctx, queryPageCmd.Filter, queryPageCmd.Cursor)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(2)
+	conn.WriteBulkString(strconv.FormatUint(next, 10))
+	conn.WriteArray(len(entries))
+	for _, e := range entries {
+		conn.WriteBulk(e.Encode())
+	}
+}
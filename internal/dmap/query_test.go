@@ -0,0 +1,174 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestDMap_Filter(t *testing.T) {
+	doc := map[string]interface{}{
+		"name":    "foo",
+		"age":     42,
+		"active":  true,
+		"comment": nil,
+		"address": map[string]interface{}{
+			"city": "Berlin",
+		},
+	}
+	jsonValue, err := json.Marshal(doc)
+	require.NoError(t, err)
+	msgpackValue, err := msgpack.Marshal(doc)
+	require.NoError(t, err)
+
+	tests := map[string]bool{
+		`age = 42`:                           true,
+		`age>=42`:                            true,
+		`age < 42`:                           false,
+		`age != 42.5`:                        true,
+		`name = "foo"`:                       true,
+		`name > "bar"`:                       true,
+		`address.city = "Berlin"`:            true,
+		`address.city = "Berlin" AND age<40`: false,
+		`active = true and age > 40`:         true,
+		`comment = null`:                     true,
+		`missing = null`:                     false,
+		`name = 42`:                          false,
+	}
+	for expr, expected := range tests {
+		f, err := ParseFilter(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, expected, f.Match(jsonValue), expr)
+		require.Equal(t, expected, f.Match(msgpackValue), expr)
+	}
+
+	t.Run("Not an object", func(t *testing.T) {
+		f, err := ParseFilter(`age = 42`)
+		require.NoError(t, err)
+		require.False(t, f.Match([]byte("42")))
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		for _, expr := range []string{"", "age", "age ~ 1", "age = ", `name = "foo`, "active > true", "age = 1 OR age = 2"} {
+			_, err := ParseFilter(expr)
+			require.ErrorIs(t, err, ErrInvalidFilter, expr)
+		}
+	})
+}
+
+func TestDMap_Query_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := cluster.AddMember(nil).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := cluster.AddMember(nil).(*Service)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		value, err := json.Marshal(map[string]interface{}{"id": i})
+		require.NoError(t, err)
+		err = dm1.Put(ctx, testutil.ToKey(i), value, nil)
+		require.NoError(t, err)
+	}
+
+	entries, err := dm2.Query(ctx, "id >= 15")
+	require.NoError(t, err)
+
+	var keys []string
+	for _, e := range entries {
+		keys = append(keys, e.Key())
+	}
+	sort.Strings(keys)
+
+	var expected []string
+	for i := 15; i < 20; i++ {
+		expected = append(expected, testutil.ToKey(i))
+	}
+	sort.Strings(expected)
+	require.Equal(t, expected, keys)
+
+	for _, e := range entries {
+		id, err := strconv.Atoi(e.Key())
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf(`{"id":%d}`, id), string(e.Value()))
+	}
+
+	_, err = dm2.Query(ctx, "id >")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestDMap_QueryPage(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c1 := testutil.NewConfig()
+	c1.MaxResponseSize = 64
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	c2 := testutil.NewConfig()
+	c2.MaxResponseSize = 64
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		value, err := json.Marshal(map[string]interface{}{"id": i})
+		require.NoError(t, err)
+		err = dm1.Put(ctx, testutil.ToKey(i), value, nil)
+		require.NoError(t, err)
+	}
+
+	_, err = dm1.Query(ctx, "id >= 0")
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	keys := make(map[string]struct{})
+	var pages int
+	var cursor uint64
+	for {
+		var entries []storage.Entry
+		entries, cursor, err = dm1.QueryPage(ctx, "id >= 0", cursor)
+		require.NoError(t, err)
+		pages++
+		for _, e := range entries {
+			_, ok := keys[e.Key()]
+			require.False(t, ok, "duplicate key: %s", e.Key())
+			keys[e.Key()] = struct{}{}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	require.Len(t, keys, 100)
+	require.Greater(t, pages, 1)
+}
@@ -0,0 +1,155 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/config"
+)
+
+var (
+	// ErrMaxKeysExceeded is returned if a write of a new key exceeds MaxKeys
+	// of a DMap whose QuotaPolicy is QuotaReject.
+	ErrMaxKeysExceeded = errors.New("max keys exceeded")
+
+	// ErrMaxInuseExceeded is returned if a write exceeds MaxInuse of a DMap
+	// whose QuotaPolicy is QuotaReject.
+	ErrMaxInuseExceeded = errors.New("max inuse exceeded")
+)
+
+// rejectsOverQuota returns true if the writes are rejected instead of
+// evicting the keys when MaxKeys or MaxInuse is exceeded.
+func (dm *DMap) rejectsOverQuota() bool {
+	if dm.config() == nil || dm.config().quotaPolicy != config.QuotaReject {
+		return false
+	}
+	return dm.config().maxKeys > 0 || dm.config().maxInuse > 0
+}
+
+// usageCounter is the number of the keys and the in-use memory of the primary
+// fragments of a DMap or a namespace on this member. The fragments update it when their write
+// lock is released, so it can be checked under a fragment lock without
+// scanning the other fragments.
+type usageCounter struct {
+	keys  int64
+	inuse int64
+}
+
+func (c *usageCounter) add(keys, inuse int) {
+	atomic.AddInt64(&c.keys, int64(keys))
+	atomic.AddInt64(&c.inuse, int64(inuse))
+}
+
+func (c *usageCounter) load() NamespaceUsage {
+	return NamespaceUsage{
+		Keys:  int(atomic.LoadInt64(&c.keys)),
+		Inuse: int(atomic.LoadInt64(&c.inuse)),
+	}
+}
+
+// reserveKey increases the number of the keys if it's below max, or max is
+// zero. The writes to the different fragments cannot exceed the quota
+// together.
+func (c *usageCounter) reserveKey(max int) bool {
+	if max == 0 {
+		c.add(1, 0)
+		return true
+	}
+	for {
+		keys := atomic.LoadInt64(&c.keys)
+		if keys >= int64(max) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&c.keys, keys, keys+1) {
+			return true
+		}
+	}
+}
+
+// usageOf returns the usage counter with the given key. The key of a DMap is
+// its fragment name.
+func (s *Service) usageOf(key string) *usageCounter {
+	s.usageMtx.Lock()
+	defer s.usageMtx.Unlock()
+
+	c, ok := s.usage[key]
+	if !ok {
+		c = &usageCounter{}
+		s.usage[key] = c
+	}
+	return c
+}
+
+// attachUsage starts counting a primary fragment of the DMap with the given
+// name in the usage counters of the DMap and its namespace. The fragment lock
+// has to be held by the caller.
+func (s *Service) attachUsage(f *fragment, name string) {
+	var nsUsage *usageCounter
+	if namespace := namespaceOf(name); namespace != "" {
+		nsUsage = s.usageOf(namespaceUsageKey(namespace))
+	}
+	f.attachUsage(s.usageOf(s.fragmentName(name)), nsUsage)
+}
+
+// checkQuotas rejects the write if this DMap or its namespace is out of its
+// quotas. Overwriting an existing key is allowed, unless the in-use memory
+// quota is exceeded. A new key is reserved in the usage counters, the fragment
+// lock has to be held by the caller.
+func (dm *DMap) checkQuotas(e *env) error {
+	f := e.fragment
+	if e.putConfig.OnlyUpdateTTL || f.usage == nil {
+		return nil
+	}
+
+	var maxKeys, nsMaxKeys int
+	if f.nsUsage != nil {
+		ns, _ := dm.namespaceConfig()
+		if ns.MaxInuse > 0 && f.nsUsage.load().Inuse >= ns.MaxInuse {
+			return ErrNamespaceQuotaExceeded
+		}
+		nsMaxKeys = ns.MaxKeys
+	}
+	if dm.rejectsOverQuota() {
+		if dm.config().maxInuse > 0 && f.usage.load().Inuse >= dm.config().maxInuse {
+			return ErrMaxInuseExceeded
+		}
+		maxKeys = dm.config().maxKeys
+	}
+	if (maxKeys == 0 && nsMaxKeys == 0) || f.storage.Check(e.hkey) {
+		return nil
+	}
+
+	if f.nsUsage != nil && !f.nsUsage.reserveKey(nsMaxKeys) {
+		return ErrNamespaceQuotaExceeded
+	}
+	if !f.usage.reserveKey(maxKeys) {
+		if f.nsUsage != nil {
+			f.nsUsage.add(-1, 0)
+		}
+		return ErrMaxKeysExceeded
+	}
+	// The reserved key is counted, it's released by updateUsage if the write
+	// fails.
+	f.length++
+	return nil
+}
+
+// DMapUsage returns the usage of the given DMap on this member. It counts the
+// primary copies of the keys.
+func (s *Service) DMapUsage(name string) NamespaceUsage {
+	return s.usageOf(s.fragmentName(name)).load()
+}
@@ -0,0 +1,128 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Quota_MaxKeys_Reject(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mydmap": {
+			MaxKeys:        10,
+			EvictionPolicy: config.LRUEviction,
+			QuotaPolicy:    config.QuotaReject,
+		},
+	}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i, nil))
+	}
+	require.Equal(t, 10, s.DMapUsage("mydmap").Keys)
+
+	err = dm.Put(ctx, testutil.ToKey(10), 10, nil)
+	require.ErrorIs(t, err, ErrMaxKeysExceeded)
+
+	// Overwriting an existing key is allowed and nothing is evicted.
+	require.NoError(t, dm.Put(ctx, testutil.ToKey(0), 42, nil))
+	for i := 0; i < 10; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	// The other DMaps are not affected.
+	other, err := s.NewDMap("other")
+	require.NoError(t, err)
+	require.NoError(t, other.Put(ctx, testutil.ToKey(10), 10, nil))
+}
+
+func TestDMap_Quota_MaxInuse_Reject(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.QuotaPolicy = config.QuotaReject
+	c.DMaps.Custom = map[string]config.DMap{
+		"mydmap": {MaxInuse: 1},
+	}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue", nil))
+
+	err = dm.Put(ctx, "mykey", "othervalue", nil)
+	require.ErrorIs(t, err, ErrMaxInuseExceeded)
+}
+
+func TestDMap_Quota_MaxKeys_Concurrent_Writes(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mydmap": {
+			MaxKeys:     10,
+			QuotaPolicy: config.QuotaReject,
+		},
+	}
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := dm.Put(ctx, testutil.ToKey(i), i, nil)
+			if err != nil {
+				require.ErrorIs(t, err, ErrMaxKeysExceeded)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 10, s.DMapUsage("mydmap").Keys)
+
+	// The deleted keys are released.
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = testutil.ToKey(i)
+	}
+	_, err = dm.Delete(ctx, keys...)
+	require.NoError(t, err)
+	require.Equal(t, NamespaceUsage{}, s.DMapUsage("mydmap"))
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue", nil))
+}
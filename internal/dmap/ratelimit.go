@@ -0,0 +1,165 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/ratelimit"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/tidwall/redcon"
+)
+
+// ErrRateLimited is returned when an operation exceeds the rate limits of the
+// DMap, see config.DMap.RateLimits.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitedTotal is the total number of the operations rejected by the rate
+// limits of the DMaps.
+var RateLimitedTotal = stats.NewInt64Counter()
+
+// rateLimitRule is the compiled form of config.RateLimit.
+type rateLimitRule struct {
+	keyPattern *regexp.Regexp
+	reads      int64
+	writes     int64
+	perClient  bool
+}
+
+type rateLimitKey struct {
+	rule   int
+	write  bool
+	client string
+}
+
+// rateLimiters keeps the limiters of a DMap on a member.
+type rateLimiters struct {
+	mtx sync.Mutex
+	m   map[rateLimitKey]*ratelimit.Limiter
+}
+
+func (r *rateLimiters) get(key rateLimitKey) *ratelimit.Limiter {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	l, ok := r.m[key]
+	if !ok {
+		l = &ratelimit.Limiter{}
+		r.m[key] = l
+	}
+	return l
+}
+
+// prune removes the idle limiters, so the limiters of the clients that are
+// gone don't pile up.
+func (r *rateLimiters) prune() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for key, l := range r.m {
+		if l.Idle() {
+			delete(r.m, key)
+		}
+	}
+}
+
+// rateLimitersOf returns the limiters of a DMap and creates them if required.
+func (s *Service) rateLimitersOf(name string) *rateLimiters {
+	s.rateLimitMtx.Lock()
+	defer s.rateLimitMtx.Unlock()
+
+	r, ok := s.rateLimiters[name]
+	if !ok {
+		r = &rateLimiters{m: make(map[rateLimitKey]*ratelimit.Limiter)}
+		s.rateLimiters[name] = r
+	}
+	return r
+}
+
+func (s *Service) pruneRateLimiters() {
+	s.rateLimitMtx.Lock()
+	defer s.rateLimitMtx.Unlock()
+
+	for _, r := range s.rateLimiters {
+		r.prune()
+	}
+}
+
+type (
+	forwardedKey   struct{}
+	rateLimitIDKey struct{}
+)
+
+// WithRateLimitID returns a copy of ctx that identifies the client in the
+// per-client rate limits, instead of its address. The clients on the same
+// member, like the instances of EmbeddedClient, share an address.
+func WithRateLimitID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, rateLimitIDKey{}, id)
+}
+
+// clientContext carries the address of the client that sent the command. The
+// commands that are forwarded by the other members are marked, they have been
+// rate limited by the member that received them.
+func clientContext(ctx context.Context, conn redcon.Conn) context.Context {
+	ctx = WithClient(ctx, conn.RemoteAddr())
+	if server.IsMemberConnection(conn) {
+		ctx = withForwarded(ctx)
+	}
+	return ctx
+}
+
+func withForwarded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forwardedKey{}, true)
+}
+
+// checkRateLimit returns ErrRateLimited if the operation on the key exceeds
+// the rate limits of the DMap. It's called on the member that receives the
+// operation, before it's forwarded to the partition owner.
+func (dm *DMap) checkRateLimit(ctx context.Context, key string, write bool) error {
+	if forwarded, _ := ctx.Value(forwardedKey{}).(bool); forwarded {
+		return nil
+	}
+	for i, rule := range dm.config().rateLimits {
+		if rule.keyPattern != nil && !rule.keyPattern.MatchString(key) {
+			continue
+		}
+
+		rate := rule.reads
+		if write {
+			rate = rule.writes
+		}
+		if rate == 0 {
+			return nil
+		}
+
+		k := rateLimitKey{rule: i, write: write}
+		if rule.perClient {
+			k.client, _ = ctx.Value(rateLimitIDKey{}).(string)
+			if k.client == "" {
+				k.client = dm.clientOf(ctx)
+			}
+		}
+		if !dm.s.rateLimitersOf(dm.name).get(k).Allow(rate, 1) {
+			RateLimitedTotal.Increase(1)
+			return ErrRateLimited
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_RateLimits(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+		RateLimits: []config.RateLimit{
+			{KeyPattern: "^hot:", WritesPerSecond: 5, PerClient: true},
+			{ReadsPerSecond: 5},
+		},
+	}}
+	require.NoError(t, c.DMaps.Sanitize())
+	require.NoError(t, c.DMaps.Validate())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client1 := WithClient(ctx, "127.0.0.1:40001")
+	for i := 0; i < 5; i++ {
+		require.NoError(t, dm.Put(client1, "hot:1", i, nil))
+	}
+	require.ErrorIs(t, dm.Put(client1, "hot:1", 5, nil), ErrRateLimited)
+	require.NotZero(t, RateLimitedTotal.Read())
+
+	// Every client has its own budget.
+	client2 := WithClient(ctx, "127.0.0.1:40002")
+	require.NoError(t, dm.Put(client2, "hot:1", 5, nil))
+
+	// The first matching rule applies, the writes of the other keys are not
+	// limited.
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i, nil))
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	_, err = dm.Get(ctx, testutil.ToKey(5))
+	require.ErrorIs(t, err, ErrRateLimited)
+
+	_, err = dm.Get(ctx, "hot:1")
+	require.NoError(t, err)
+}
+
+func TestDMap_RateLimits_ReceivingMember(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+			RateLimits: []config.RateLimit{{WritesPerSecond: 5}},
+		}}
+		require.NoError(t, c.DMaps.Sanitize())
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Find a key that is owned by the second member.
+	var key string
+	for i := 0; i < 100; i++ {
+		hkey := partitions.HKey("mydmap", testutil.ToKey(i))
+		if s1.primary.PartitionByHKey(hkey).Owner().CompareByID(s2.rt.This()) {
+			key = testutil.ToKey(i)
+			break
+		}
+	}
+	require.NotEmpty(t, key)
+
+	// The writes are limited by the member that receives them, the forwarded
+	// ones are not counted again on the partition owner.
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, dm1.Put(ctx, key, i, nil))
+	}
+	require.ErrorIs(t, dm1.Put(ctx, key, 5, nil), ErrRateLimited)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, dm2.Put(ctx, key, i, nil))
+	}
+	require.ErrorIs(t, dm2.Put(ctx, key, 5, nil), ErrRateLimited)
+	require.NoError(t, dm2.Put(withForwarded(ctx), key, 5, nil))
+}
@@ -0,0 +1,262 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// maxReportedKeys is the maximum number of keys kept in a RetentionReport.
+const maxReportedKeys = 100
+
+// retentionScanCount is the number of entries read from a fragment under its
+// lock by the retention janitor.
+const retentionScanCount = 1000
+
+// RetentionReport is the result of the last retention run of a DMap on this node.
+type RetentionReport struct {
+	DMap      string
+	Expired   int
+	Trimmed   int
+	DryRun    bool
+	Timestamp int64
+
+	// Keys is a sample of the deleted keys, or the keys that would be deleted
+	// if DryRun is true.
+	Keys []string
+}
+
+type retentionCandidate struct {
+	hkey      uint64
+	key       string
+	timestamp int64
+	f         *fragment
+}
+
+func (dm *DMap) retentionEnabled() bool {
+	return dm.config().retentionMaxAge > 0 || dm.config().retentionMaxEntriesPerMember > 0
+}
+
+// collectRetentionCandidates scans the primary fragments owned by this node.
+// The fragments are read in pages, the writers are blocked only while a page
+// is read.
+func (dm *DMap) collectRetentionCandidates() ([]retentionCandidate, error) {
+	var candidates []retentionCandidate
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		part := dm.s.primary.PartitionByID(partID)
+		if !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var cursor uint64
+		for {
+			f.RLock()
+			cursor, err = f.storage.Scan(cursor, retentionScanCount, func(e storage.Entry) bool {
+				candidates = append(candidates, retentionCandidate{
+					hkey:      dm.HKey(e.Key()),
+					key:       e.Key(),
+					timestamp: e.Timestamp(),
+					f:         f,
+				})
+				return true
+			})
+			f.RUnlock()
+			if err != nil {
+				return nil, err
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// applyRetention enforces the retention policies of the DMap on the partitions
+// owned by this node. Entries older than retentionMaxAge are deleted first, then
+// the oldest entries are deleted until there are at most
+// retentionMaxEntriesPerMember.
+func (dm *DMap) applyRetention(now time.Time) (RetentionReport, error) {
+	report := RetentionReport{
+		DMap:      dm.name,
+		DryRun:    dm.config().retentionDryRun,
+		Timestamp: now.UnixNano(),
+	}
+
+	candidates, err := dm.collectRetentionCandidates()
+	if err != nil {
+		return report, err
+	}
+
+	var deleted []retentionCandidate
+	if dm.config().retentionMaxAge > 0 {
+		threshold := now.Add(-dm.config().retentionMaxAge).UnixNano()
+		alive := candidates[:0]
+		for _, c := range candidates {
+			if c.timestamp < threshold {
+				deleted = append(deleted, c)
+				report.Expired++
+				continue
+			}
+			alive = append(alive, c)
+		}
+		candidates = alive
+	}
+
+	maxEntries := dm.config().retentionMaxEntriesPerMember
+	if maxEntries > 0 && len(candidates) > maxEntries {
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].timestamp < candidates[j].timestamp
+		})
+		extra := len(candidates) - maxEntries
+		deleted = append(deleted, candidates[:extra]...)
+		report.Trimmed = extra
+	}
+
+	for _, c := range deleted {
+		if len(report.Keys) >= maxReportedKeys {
+			break
+		}
+		report.Keys = append(report.Keys, c.key)
+	}
+
+	if report.DryRun {
+		return report, nil
+	}
+
+	for _, c := range deleted {
+		if err := dm.deleteRetentionCandidate(c); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (dm *DMap) deleteRetentionCandidate(c retentionCandidate) error {
+	c.f.Lock()
+	defer c.f.Unlock()
+
+	e, err := c.f.storage.Get(c.hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if e.Timestamp() != c.timestamp {
+		// The key has been updated after the scan.
+		return nil
+	}
+	return dm.deleteOnCluster(c.hkey, c.key, c.f)
+}
+
+// retentionDMaps returns the names of the DMaps that are created on this
+// member, and the ones that have a custom configuration.
+func (s *Service) retentionDMaps() []string {
+	names := make(map[string]struct{})
+	s.RLock()
+	for name := range s.dmaps {
+		names[name] = struct{}{}
+	}
+	s.RUnlock()
+	for name := range s.runtimeConfig().DMaps.Custom {
+		names[name] = struct{}{}
+	}
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s *Service) applyRetentionPolicies() {
+	for _, name := range s.retentionDMaps() {
+		dm, err := s.getDMap(name)
+		if err != nil {
+			dm, err = s.NewTempDMap(name)
+			if err != nil {
+				s.log.V(3).Printf("[ERROR] Failed to create DMap: %s: %v", name, err)
+				continue
+			}
+		}
+		if !dm.retentionEnabled() {
+			continue
+		}
+
+		report, err := dm.applyRetention(time.Now())
+		if err != nil {
+			s.log.V(3).Printf("[ERROR] Failed to apply retention policies on DMap: %s: %v", name, err)
+		}
+
+		s.retentionMtx.Lock()
+		s.retentionReports[name] = report
+		s.retentionMtx.Unlock()
+
+		if report.Expired+report.Trimmed == 0 {
+			continue
+		}
+		if report.DryRun {
+			s.log.V(2).Printf("[INFO] Retention policies would delete %d expired and %d trimmed entries on DMap: %s (dry-run): %v",
+				report.Expired, report.Trimmed, name, report.Keys)
+		} else {
+			s.log.V(4).Printf("[INFO] Retention policies deleted %d expired and %d trimmed entries on DMap: %s",
+				report.Expired, report.Trimmed, name)
+		}
+	}
+}
+
+func (s *Service) retentionWorker() {
+	defer s.wg.Done()
+	timer := time.NewTimer(s.config.DMaps.RetentionInterval)
+	defer timer.Stop()
+
+	for {
+		timer.Reset(s.config.DMaps.RetentionInterval)
+		select {
+		case <-timer.C:
+			s.applyRetentionPolicies()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// RetentionReports returns the reports of the last retention runs on this node.
+func (s *Service) RetentionReports() []RetentionReport {
+	s.retentionMtx.RLock()
+	defer s.retentionMtx.RUnlock()
+
+	reports := make([]RetentionReport, 0, len(s.retentionReports))
+	for _, report := range s.retentionReports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].DMap < reports[j].DMap
+	})
+	return reports
+}
@@ -0,0 +1,122 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Retention_MaxEntries(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{RetentionMaxEntriesPerMember: 10})
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	s.applyRetentionPolicies()
+
+	for i := 0; i < 20; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		if i < 10 {
+			require.ErrorIs(t, err, ErrKeyNotFound)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+
+	reports := s.RetentionReports()
+	require.Len(t, reports, 1)
+	require.Equal(t, "mydmap", reports[0].DMap)
+	require.Equal(t, 10, reports[0].Trimmed)
+	require.Equal(t, 0, reports[0].Expired)
+	require.False(t, reports[0].DryRun)
+	require.Len(t, reports[0].Keys, 10)
+}
+
+func TestDMap_Retention_MaxAge_DryRun(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{
+		RetentionMaxAge: 10 * time.Millisecond,
+		RetentionDryRun: true,
+	})
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	<-time.After(20 * time.Millisecond)
+	s.applyRetentionPolicies()
+
+	reports := s.RetentionReports()
+	require.Len(t, reports, 1)
+	require.True(t, reports[0].DryRun)
+	require.Equal(t, 10, reports[0].Expired)
+
+	// Nothing is deleted in dry-run mode.
+	for i := 0; i < 10; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+}
+
+func TestDMap_Retention_Global(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.DMaps.RetentionMaxEntriesPerMember = retentionScanCount
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+
+	// The fragments are read in more than one page.
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+	total := 2*retentionScanCount + 500
+	for i := 0; i < total; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	s.applyRetentionPolicies()
+
+	reports := s.RetentionReports()
+	require.Len(t, reports, 1)
+	require.Equal(t, "mydmap", reports[0].DMap)
+	require.Equal(t, total-retentionScanCount, reports[0].Trimmed)
+
+	var length int
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		length += s.primary.PartitionByID(partID).Length()
+	}
+	require.Equal(t, retentionScanCount, length)
+}
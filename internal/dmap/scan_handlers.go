@@ -15,43 +15,82 @@
 package dmap
 
 import (
+	"context"
 	"strconv"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/tidwall/redcon"
 )
 
-func (dm *DMap) scanOnFragment(f *fragment, cursor uint64, sc *ScanConfig) ([]string, uint64, error) {
+// CanceledOperationsTotal is the number of scans stopped before completion
+// because their context was canceled.
+var CanceledOperationsTotal = stats.NewInt64Counter()
+
+func (dm *DMap) scanOnFragment(ctx context.Context, f *fragment, cursor uint64, sc *ScanConfig) ([]string, uint64, error) {
 	f.Lock()
 	defer f.Unlock()
 
 	var items []string
+	var size int
 	var err error
 
-	if sc.HasMatch {
-		cursor, err = f.storage.ScanRegexMatch(cursor, sc.Match, sc.Count, func(e storage.Entry) bool {
-			items = append(items, e.Key())
+	// The storage engine calls this for every entry. Checking the context here
+	// releases the fragment lock as soon as the caller gives up.
+	collect := func(e storage.Entry) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if dm.strictlyExpired(e.TTL()) || isChunkKey(e.Key()) {
 			return true
-		})
-		if err != nil {
-			return nil, 0, err
 		}
-		return items, cursor, nil
-	}
-
-	cursor, err = f.storage.Scan(cursor, sc.Count, func(e storage.Entry) bool {
+		// Cut the batch before it exceeds the response size limit, the
+		// cursor points to this entry then.
+		maxSize := dm.s.config.MaxResponseSize
+		if maxSize > 0 && len(items) > 0 && size+len(e.Key()) > maxSize {
+			return false
+		}
+		size += len(e.Key())
 		items = append(items, e.Key())
 		return true
-	})
+	}
+
+	if sc.HasMatch {
+		cursor, err = f.storage.ScanRegexMatch(cursor, sc.Match, sc.Count, collect)
+	} else {
+		cursor, err = f.storage.Scan(cursor, sc.Count, collect)
+	}
 	if err != nil {
 		return nil, 0, err
 	}
+	if err = ctx.Err(); err != nil {
+		CanceledOperationsTotal.Increase(1)
+		return nil, 0, err
+	}
 	return items, cursor, nil
 }
 
-func (dm *DMap) Scan(partID, cursor uint64, sc *ScanConfig) ([]string, uint64, error) {
+// Scan iterates over the fragment on the given partition. An analytics replica
+// has no partitions, it iterates over its copy of the DMap as a whole and
+// partID is ignored. It stops and returns the context's error if ctx is
+// canceled before the scan is completed.
+func (dm *DMap) Scan(ctx context.Context, partID, cursor uint64, sc *ScanConfig) ([]string, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		CanceledOperationsTotal.Increase(1)
+		return nil, 0, err
+	}
+
+	if dm.s.rt.This().Analytics {
+		f, ok := dm.s.analyticsStore.load(dm.name)
+		if !ok {
+			return nil, 0, nil
+		}
+		return dm.scanOnFragment(ctx, f, cursor, sc)
+	}
+
 	var part *partitions.Partition
 	if sc.Replica {
 		part = dm.s.backup.PartitionByID(partID)
@@ -65,7 +104,7 @@ func (dm *DMap) Scan(partID, cursor uint64, sc *ScanConfig) ([]string, uint64, e
 	if err != nil {
 		return nil, 0, err
 	}
-	return dm.scanOnFragment(f, cursor, sc)
+	return dm.scanOnFragment(ctx, f, cursor, sc)
 }
 
 type ScanConfig struct {
@@ -118,9 +157,12 @@ func (s *Service) scanCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	}
 	sc.Replica = scanCmd.Replica
 
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
 	var result []string
 	var cursor uint64
-	result, cursor, err = dm.Scan(scanCmd.PartID, scanCmd.Cursor, &sc)
+	result, cursor, err = dm.Scan(ctx, scanCmd.PartID, scanCmd.Cursor, &sc)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -208,3 +208,60 @@ func TestDMap_scanCommandHandler_count(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, keys, 5)
 }
+
+func TestDMap_Scan_ContextCanceled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = dm.Put(context.Background(), testutil.ToKey(i), i, nil)
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	before := CanceledOperationsTotal.Read()
+	sc := &ScanConfig{Count: 10}
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		_, _, err = dm.Scan(ctx, partID, 0, sc)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	require.Equal(t, before+int64(s.config.PartitionCount), CanceledOperationsTotal.Read())
+}
+
+func TestDMap_Scan_MaxResponseSize(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	// Only one key fits in a batch.
+	c.MaxResponseSize = 1
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	allKeys := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), i, nil)
+		require.NoError(t, err)
+		allKeys[testutil.ToKey(i)] = false
+	}
+
+	keys, cursor, err := dm.Scan(ctx, 0, 0, &ScanConfig{Count: 10})
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotZero(t, cursor)
+
+	totalKeys := testScanIterator(t, s, allKeys, nil)
+	require.Equal(t, 100, totalKeys)
+	for _, value := range allKeys {
+		require.True(t, value)
+	}
+}
@@ -19,18 +19,25 @@ import (
 	"errors"
 	"reflect"
 	"sync"
+	"sync/atomic"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/events"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/eventbus"
+	"github.com/buraksezer/olric/internal/hlc"
+	"github.com/buraksezer/olric/internal/kvstore"
 	"github.com/buraksezer/olric/internal/locker"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/schema"
 	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/service"
+	"github.com/buraksezer/olric/pkg/codec"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/buraksezer/olric/pkg/storage"
+	"golang.org/x/sync/singleflight"
 )
 
 var errFragmentNotFound = errors.New("fragment not found")
@@ -43,23 +50,84 @@ type storageMap struct {
 type Service struct {
 	sync.RWMutex // protects dmaps map
 
-	log     *flog.Logger
-	config  *config.Config
+	log    *flog.Logger
+	config *config.Config
+	// runtime keeps the latest configuration applied by ReloadConfig, the
+	// fields that can be modified at runtime are read from it.
+	runtime atomic.Value // *config.Config
 	client  *server.Client
 	server  *server.Server
 	rt      *routingtable.RoutingTable
 	primary *partitions.Partitions
 	backup  *partitions.Partitions
 	locker  *locker.Locker
-	dmaps   map[string]*DMap
-	storage *storageMap
-	wg      sync.WaitGroup
-	ctx     context.Context
-	cancel  context.CancelFunc
+	// writerLocker serializes the writes of a key in write-through mode,
+	// see lockKeyForWriter.
+	writerLocker *locker.Locker
+	eventBus     *eventbus.Bus
+	dmaps        map[string]*DMap
+	storage      *storageMap
+
+	// clock stamps the entries written on this member. It is updated with the
+	// timestamps received from the other members.
+	clock *hlc.Clock
+
+	changelogMtx sync.Mutex
+	changelogs   map[changelogKey]*changelog
+
+	tombstoneMtx sync.Mutex
+	tombstones   map[string]*tombstones
+
+	lockQueueMtx sync.Mutex
+	lockQueues   map[string]*lockQueue
+
+	usageMtx sync.Mutex
+	usage    map[string]*usageCounter
+
+	rateLimitMtx sync.Mutex
+	rateLimiters map[string]*rateLimiters
+
+	sloMtx      sync.Mutex
+	sloTrackers map[string]*sloTracker
+
+	publisher publisher
+
+	processorMtx sync.RWMutex
+	processors   map[string]EntryProcessor
+
+	keyMigrationMtx       sync.RWMutex
+	keyMigrationListeners map[string]KeyMigrationListener
+
+	retentionMtx     sync.RWMutex
+	retentionReports map[string]RetentionReport
+
+	snapshotMtx sync.Mutex
+	snapshots   map[string]*destroySnapshot
+
+	loads      singleflight.Group
+	refreshing sync.Map
+
+	writeBehindMtx    sync.Mutex
+	writeBehindQueues map[string]*writeBehindQueue
+
+	imports *importThrottle
+
+	analytics        *analyticsReplicator
+	analyticsStore   *analyticsStore
+	analyticsQueries uint64
+
+	memoryBudget    *kvstore.MemoryBudget
+	maxInuse        uint64
+	clusterMaxInuse uint64
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func registerErrors() {
 	protocol.SetError("NOSUCHLOCK", ErrNoSuchLock)
+	protocol.SetError("INVALIDADMINTOKEN", ErrInvalidAdminToken)
 	protocol.SetError("LOCKNOTACQUIRED", ErrLockNotAcquired)
 	protocol.SetError("READQUORUM", ErrReadQuorum)
 	protocol.SetError("WRITEQUORUM", ErrWriteQuorum)
@@ -68,27 +136,92 @@ func registerErrors() {
 	protocol.SetError("ENTRYTOOLARGE", ErrEntryTooLarge)
 	protocol.SetError("KEYNOTFOUND", ErrKeyNotFound)
 	protocol.SetError("KEYFOUND", ErrKeyFound)
+	protocol.SetError("CHANGELOGDISABLED", ErrChangeLogDisabled)
+	protocol.SetError("SEQUENCETOOOLD", ErrSequenceTooOld)
+	protocol.SetError("INVALIDKEY", ErrInvalidKey)
+	protocol.SetError("NOTINTEGER", ErrNotInteger)
+	protocol.SetError("FIELDNOTFOUND", ErrFieldNotFound)
+	protocol.SetError("NOTHASH", ErrNotHash)
+	protocol.SetError("PROCESSORNOTFOUND", ErrProcessorNotFound)
+	protocol.SetError("INVALIDFILTER", ErrInvalidFilter)
+	protocol.SetError("ACCESSSTATSDISABLED", ErrAccessStatsDisabled)
+	protocol.SetError("HOTKEYSDISABLED", ErrHotKeysDisabled)
+	protocol.SetError("UNKNOWNCODEC", codec.ErrUnknownCodec)
+	protocol.SetError("SCHEMAMISMATCH", schema.ErrMismatch)
+	protocol.SetError("SNAPSHOTNOTFOUND", ErrSnapshotNotFound)
+	protocol.SetError("LOADERFAILED", ErrLoaderFailed)
+	protocol.SetError("WRITERFAILED", ErrWriterFailed)
+	protocol.SetError("TRANSFORMFAILED", ErrTransformFailed)
+	protocol.SetError("STALEEPOCH", ErrStaleEpoch)
+	protocol.SetError("OUTOFMEMORY", ErrOutOfMemory)
+	protocol.SetError("TOMBSTONESDISABLED", ErrTombstonesDisabled)
+	protocol.SetError("TXCONFLICT", ErrTxConflict)
+	protocol.SetError("CROSSPARTITIONTX", ErrCrossPartitionTx)
+	protocol.SetError("RATELIMITED", ErrRateLimited)
+	protocol.SetError("PUBLISHERUNAVAILABLE", ErrPublisherUnavailable)
+	protocol.SetError("NAMESPACEREADONLY", ErrNamespaceReadOnly)
+	protocol.SetError("NAMESPACEQUOTAEXCEEDED", ErrNamespaceQuotaExceeded)
+	protocol.SetError("NAMESPACEACCESSDENIED", ErrNamespaceAccessDenied)
+	protocol.SetError("MAXKEYSEXCEEDED", ErrMaxKeysExceeded)
+	protocol.SetError("VALUETOOLARGE", ErrValueTooLarge)
+	protocol.SetError("CHUNKEDVALUEOPTION", ErrChunkedValueOption)
+	protocol.SetError("MAXINUSEEXCEEDED", ErrMaxInuseExceeded)
+	protocol.SetError("RESPONSETOOLARGE", ErrResponseTooLarge)
+	protocol.SetError("IMPORTTHROTTLED", ErrImportThrottled)
+	protocol.SetError("CLOCKDRIFT", hlc.ErrClockDrift)
 }
 
 func NewService(e *environment.Environment) (service.Service, error) {
 	ctx, cancel := context.WithCancel(context.Background())
+	c := e.Get("config").(*config.Config)
 	s := &Service{
-		config:  e.Get("config").(*config.Config),
-		client:  e.Get("client").(*server.Client),
-		server:  e.Get("server").(*server.Server),
-		log:     e.Get("logger").(*flog.Logger),
-		rt:      e.Get("routingtable").(*routingtable.RoutingTable),
-		primary: e.Get("primary").(*partitions.Partitions),
-		backup:  e.Get("backup").(*partitions.Partitions),
-		locker:  e.Get("locker").(*locker.Locker),
+		config:   c,
+		client:   e.Get("client").(*server.Client),
+		server:   e.Get("server").(*server.Server),
+		log:      e.Get("logger").(*flog.Logger),
+		rt:       e.Get("routingtable").(*routingtable.RoutingTable),
+		primary:  e.Get("primary").(*partitions.Partitions),
+		backup:   e.Get("backup").(*partitions.Partitions),
+		locker:   e.Get("locker").(*locker.Locker),
+		eventBus: e.Get("eventbus").(*eventbus.Bus),
+
+		writerLocker: locker.New(),
 		storage: &storageMap{
 			engines: make(map[string]storage.Engine),
 			configs: make(map[string]map[string]interface{}),
 		},
-		dmaps:  make(map[string]*DMap),
-		ctx:    ctx,
-		cancel: cancel,
+		clock:      hlc.New(c.MaxClockDrift),
+		dmaps:      make(map[string]*DMap),
+		changelogs: make(map[changelogKey]*changelog),
+		tombstones: make(map[string]*tombstones),
+
+		lockQueues:   make(map[string]*lockQueue),
+		usage:        make(map[string]*usageCounter),
+		rateLimiters: make(map[string]*rateLimiters),
+		sloTrackers:  make(map[string]*sloTracker),
+
+		processors:            make(map[string]EntryProcessor),
+		keyMigrationListeners: make(map[string]KeyMigrationListener),
+		retentionReports:      make(map[string]RetentionReport),
+		snapshots:             make(map[string]*destroySnapshot),
+		writeBehindQueues:     make(map[string]*writeBehindQueue),
+		imports:               &importThrottle{},
+		analyticsStore:        newAnalyticsStore(),
+		ctx:                   ctx,
+		cancel:                cancel,
 	}
+	s.runtime.Store(c)
+	budget, err := s.newMemoryBudget()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.memoryBudget = budget
+	s.analytics = newAnalyticsReplicator(s)
+	if p, ok := e.Get("pubsub").(publisher); ok {
+		s.publisher = p
+	}
+
 	registerErrors()
 	s.RegisterHandlers()
 	return s, nil
@@ -139,6 +272,24 @@ func (s *Service) Start() error {
 	s.wg.Add(1)
 	go s.evictKeysAtBackground()
 
+	s.wg.Add(1)
+	go s.retentionWorker()
+
+	s.wg.Add(1)
+	go s.pruneWorker()
+
+	if s.memoryBudget != nil {
+		s.updateMemoryBudget()
+		s.rt.AddCallback(s.updateMemoryBudget)
+	}
+
+	s.rt.AddCallback(s.analytics.refresh)
+
+	if s.config.DMaps.AntiEntropyInterval > 0 {
+		s.wg.Add(1)
+		go s.antiEntropyWorker()
+	}
+
 	return nil
 }
 
@@ -18,9 +18,19 @@ import (
 	"context"
 	"testing"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
 )
 
+// newTestServiceWithDMapConfig adds a member to the cluster with the given
+// custom configuration of a DMap.
+func newTestServiceWithDMapConfig(cluster *testcluster.TestCluster, name string, dc config.DMap) *Service {
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{name: dc}
+	return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+}
+
 func TestDMapService(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	defer cluster.Shutdown()
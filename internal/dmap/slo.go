@@ -0,0 +1,216 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/events"
+)
+
+const (
+	// sloSlots is the number of the slots that the rolling window of a latency
+	// SLO is divided into.
+	sloSlots = 10
+
+	// sloMinSamples is the minimum number of the operations in the window to
+	// evaluate the attainment of a latency SLO. A few slow operations right
+	// after a quiet period don't trigger a violation.
+	sloMinSamples = 20
+)
+
+// SLOStatus is the latency SLO attainment of a DMap on a member, in the
+// rolling window. See config.DMap.LatencySLO.
+type SLOStatus struct {
+	Target     time.Duration
+	Objective  float64
+	Window     time.Duration
+	Good       int64
+	Bad        int64
+	Attainment float64
+	Violated   bool
+}
+
+type sloSlot struct {
+	start int64
+	good  int64
+	bad   int64
+}
+
+// sloTracker counts the good and the bad operations of a DMap in a rolling
+// window.
+type sloTracker struct {
+	mtx       sync.Mutex
+	target    time.Duration
+	objective float64
+	window    time.Duration
+	slots     [sloSlots]sloSlot
+	violated  bool
+}
+
+// count returns the number of the good and the bad operations in the window.
+// It has to be called with the lock held.
+func (t *sloTracker) count(now time.Time) (good, bad int64) {
+	oldest := now.Add(-t.window).UnixNano()
+	for _, slot := range t.slots {
+		if slot.start > oldest {
+			good += slot.good
+			bad += slot.bad
+		}
+	}
+	return good, bad
+}
+
+// status returns the status of the SLO. It has to be called with the lock held.
+func (t *sloTracker) status(now time.Time) SLOStatus {
+	good, bad := t.count(now)
+	attainment := 1.0
+	if good+bad > 0 {
+		attainment = float64(good) / float64(good+bad)
+	}
+	return SLOStatus{
+		Target:     t.target,
+		Objective:  t.objective,
+		Window:     t.window,
+		Good:       good,
+		Bad:        bad,
+		Attainment: attainment,
+		Violated:   t.violated,
+	}
+}
+
+// record counts an operation and returns true if the attainment falls below
+// the objective with it.
+func (t *sloTracker) record(now time.Time, latency time.Duration) (SLOStatus, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	slotLength := t.window / sloSlots
+	if slotLength <= 0 {
+		// The window is validated, it's a guard against dividing by zero.
+		slotLength = 1
+	}
+	start := now.Truncate(slotLength).UnixNano()
+	slot := &t.slots[(start/int64(slotLength))%sloSlots]
+	if slot.start != start {
+		*slot = sloSlot{start: start}
+	}
+	if latency <= t.target {
+		slot.good++
+	} else {
+		slot.bad++
+	}
+
+	st := t.status(now)
+	if st.Good+st.Bad < sloMinSamples {
+		return st, false
+	}
+	if st.Attainment >= t.objective {
+		t.violated = false
+		return st, false
+	}
+	if t.violated {
+		// It's already reported.
+		return st, false
+	}
+	t.violated = true
+	st.Violated = true
+	return st, true
+}
+
+// sloTrackerOf returns the SLO tracker of a DMap and creates it if required.
+func (s *Service) sloTrackerOf(name string, c *dmapConfig) *sloTracker {
+	s.sloMtx.Lock()
+	defer s.sloMtx.Unlock()
+
+	t, ok := s.sloTrackers[name]
+	if !ok || t.target != c.latencySLO || t.objective != c.latencySLOObjective || t.window != c.latencySLOWindow {
+		// The configuration is reloaded, start over.
+		t = &sloTracker{
+			target:    c.latencySLO,
+			objective: c.latencySLOObjective,
+			window:    c.latencySLOWindow,
+		}
+		s.sloTrackers[name] = t
+	}
+	return t
+}
+
+// observeSLO counts an operation that is started at the given time in the
+// latency SLO of the DMap, and publishes an SLOViolationEvent if the
+// attainment falls below the objective. It has to be called on the partition
+// owner.
+func (dm *DMap) observeSLO(start time.Time) {
+	c := dm.config()
+	if c.latencySLO == 0 {
+		return
+	}
+
+	now := time.Now()
+	st, violated := dm.s.sloTrackerOf(dm.name, c).record(now, now.Sub(start))
+	if !violated {
+		return
+	}
+	dm.s.log.V(3).Printf("[WARN] Latency SLO attainment of DMap: %s is %.4f, below the objective: %.4f",
+		dm.name, st.Attainment, st.Objective)
+	if !dm.s.eventBus.HasSubscribers() {
+		return
+	}
+	dm.s.eventBus.Publish(&events.SLOViolationEvent{
+		Kind:       events.KindSLOViolationEvent,
+		Source:     dm.s.rt.This().String(),
+		DMap:       dm.name,
+		Attainment: st.Attainment,
+		Objective:  st.Objective,
+		Good:       st.Good,
+		Bad:        st.Bad,
+		Timestamp:  now.UnixNano(),
+	})
+}
+
+// SLOs returns the latency SLO status of the DMaps on this member, by DMap
+// name. Only the DMaps with a latency SLO that served an operation are
+// returned.
+func (s *Service) SLOs() map[string]SLOStatus {
+	s.sloMtx.Lock()
+	defer s.sloMtx.Unlock()
+
+	now := time.Now()
+	result := make(map[string]SLOStatus)
+	for name, t := range s.sloTrackers {
+		t.mtx.Lock()
+		result[name] = t.status(now)
+		t.mtx.Unlock()
+	}
+	return result
+}
+
+// loadLatencySLO sets the latency SLO of the DMap and its defaults.
+func (c *dmapConfig) loadLatencySLO(cs config.DMap) {
+	c.latencySLO = cs.LatencySLO
+	if c.latencySLO == 0 {
+		return
+	}
+	c.latencySLOObjective = cs.LatencySLOObjective
+	if c.latencySLOObjective == 0 {
+		c.latencySLOObjective = config.DefaultLatencySLOObjective
+	}
+	c.latencySLOWindow = cs.LatencySLOWindow
+	if c.latencySLOWindow == 0 {
+		c.latencySLOWindow = config.DefaultLatencySLOWindow
+	}
+}
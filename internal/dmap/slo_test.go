@@ -0,0 +1,114 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/events"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_SLOTracker(t *testing.T) {
+	tr := &sloTracker{
+		target:    10 * time.Millisecond,
+		objective: 0.99,
+		window:    10 * time.Second,
+	}
+
+	now := time.Now()
+	for i := 0; i < sloMinSamples; i++ {
+		_, violated := tr.record(now, time.Millisecond)
+		require.False(t, violated)
+	}
+
+	st, violated := tr.record(now, time.Second)
+	require.True(t, violated)
+	require.Equal(t, int64(sloMinSamples), st.Good)
+	require.Equal(t, int64(1), st.Bad)
+
+	// It's reported once.
+	_, violated = tr.record(now, time.Second)
+	require.False(t, violated)
+
+	// The old operations fall out of the window.
+	st, _ = tr.record(now.Add(11*time.Second), time.Millisecond)
+	require.Equal(t, int64(1), st.Good)
+	require.Equal(t, int64(0), st.Bad)
+}
+
+func TestDMap_SLOTracker_Short_Window(t *testing.T) {
+	tr := &sloTracker{
+		target:    10 * time.Millisecond,
+		objective: 0.99,
+		window:    5 * time.Nanosecond,
+	}
+
+	st, violated := tr.record(time.Now(), time.Millisecond)
+	require.False(t, violated)
+	require.Equal(t, int64(1), st.Good)
+}
+
+func TestDMap_LatencySLO(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{"mydmap": {
+		// Every operation exceeds the target.
+		LatencySLO: time.Nanosecond,
+	}}
+	require.NoError(t, c.DMaps.Sanitize())
+	require.NoError(t, c.DMaps.Validate())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	sub := s.eventBus.Subscribe(0)
+	defer sub.Close()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < sloMinSamples; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i, nil))
+	}
+
+	slo := s.SLOs()["mydmap"]
+	require.Equal(t, int64(sloMinSamples), slo.Bad)
+	require.Equal(t, float64(0), slo.Attainment)
+	require.Equal(t, config.DefaultLatencySLOObjective, slo.Objective)
+	require.True(t, slo.Violated)
+
+	var violations int
+	for {
+		select {
+		case ev := <-sub.Events():
+			if v, ok := ev.(*events.SLOViolationEvent); ok {
+				require.Equal(t, "mydmap", v.DMap)
+				violations++
+			}
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	require.Equal(t, 1, violations)
+}
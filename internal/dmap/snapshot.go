@@ -0,0 +1,227 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrSnapshotNotFound is returned by Undo when there is no snapshot of the DMap
+// on the cluster. It's never taken or it's already expired.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+type snapshotFragment struct {
+	part *partitions.Partition
+	f    *fragment
+}
+
+// destroySnapshot keeps the fragments of a destroyed DMap on this member until
+// it's expired or restored. See config.DMaps.DestroySnapshotRetention.
+type destroySnapshot struct {
+	fragments []snapshotFragment
+	expiresAt time.Time
+}
+
+func newDestroySnapshot(retention time.Duration) *destroySnapshot {
+	return &destroySnapshot{
+		expiresAt: time.Now().Add(retention),
+	}
+}
+
+func (d *destroySnapshot) add(part *partitions.Partition, f *fragment) {
+	d.fragments = append(d.fragments, snapshotFragment{part: part, f: f})
+}
+
+// wipeOut destroys the fragments of the snapshot. They are not in the
+// partitions anymore, so wipeOutFragment cannot be used.
+func (d *destroySnapshot) wipeOut() error {
+	for _, sf := range d.fragments {
+		if err := sf.f.Close(); err != nil {
+			return err
+		}
+		if err := sf.f.Destroy(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepSnapshot stores the snapshot of a destroyed DMap. The previous snapshot
+// of the DMap is wiped out, if there is any.
+func (s *Service) keepSnapshot(name string, snapshot *destroySnapshot) {
+	if len(snapshot.fragments) == 0 {
+		return
+	}
+
+	s.snapshotMtx.Lock()
+	previous, ok := s.snapshots[name]
+	s.snapshots[name] = snapshot
+	s.snapshotMtx.Unlock()
+
+	if ok {
+		if err := previous.wipeOut(); err != nil {
+			s.log.V(3).Printf("[ERROR] Failed to wipe out the previous snapshot of DMap: %s: %v", name, err)
+		}
+	}
+	s.log.V(2).Printf("[INFO] Snapshot of the destroyed DMap: %s is kept until %s",
+		name, snapshot.expiresAt.Format(time.RFC3339))
+}
+
+// deleteExpiredSnapshots wipes out the snapshots whose retention period is over.
+func (s *Service) deleteExpiredSnapshots() {
+	now := time.Now()
+	expired := make(map[string]*destroySnapshot)
+
+	s.snapshotMtx.Lock()
+	for name, snapshot := range s.snapshots {
+		if now.After(snapshot.expiresAt) {
+			expired[name] = snapshot
+			delete(s.snapshots, name)
+		}
+	}
+	s.snapshotMtx.Unlock()
+
+	for name, snapshot := range expired {
+		if err := snapshot.wipeOut(); err != nil {
+			s.log.V(3).Printf("[ERROR] Failed to wipe out the snapshot of DMap: %s: %v", name, err)
+			continue
+		}
+		s.log.V(2).Printf("[INFO] Snapshot of the destroyed DMap: %s has been expired", name)
+	}
+}
+
+// restoreFragment puts a fragment of the snapshot back into its partition and
+// returns the number of restored entries. If the DMap has been written since
+// it's destroyed, the entries are merged into the new fragment. The entries
+// written after Destroy are kept.
+func (s *Service) restoreFragment(name string, sf snapshotFragment) (int, error) {
+	tmp, loaded := sf.part.Map().LoadOrStore(s.fragmentName(name), sf.f)
+	if !loaded {
+		sf.f.Lock()
+		defer sf.f.Unlock()
+		if sf.part.Kind() == partitions.PRIMARY {
+			s.attachUsage(sf.f, name)
+		}
+		return sf.f.storage.Stats().Length, nil
+	}
+
+	current := tmp.(*fragment)
+	current.Lock()
+	var count int
+	var err error
+	sf.f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		if current.storage.Check(hkey) {
+			return true
+		}
+		if err = current.storage.Put(hkey, e); err != nil {
+			return false
+		}
+		if tags, ok := sf.f.tags.tags[hkey]; ok {
+			current.tags.set(hkey, e.Key(), tags)
+		}
+		count++
+		return true
+	})
+	current.Unlock()
+	if err != nil {
+		return count, err
+	}
+
+	if err = sf.f.Close(); err != nil {
+		return count, err
+	}
+	return count, sf.f.Destroy()
+}
+
+// undoLocal restores the snapshot of the DMap on this member and returns the
+// number of restored entries.
+func (s *Service) undoLocal(ctx context.Context, name string) (int, error) {
+	s.snapshotMtx.Lock()
+	snapshot, ok := s.snapshots[name]
+	delete(s.snapshots, name)
+	s.snapshotMtx.Unlock()
+	if !ok {
+		return 0, ErrSnapshotNotFound
+	}
+
+	// The fragments of a DMap that is not known by this member are evicted,
+	// see scanFragmentForEviction.
+	if _, err := s.getDMap(name); errors.Is(err, ErrDMapNotFound) {
+		if _, err = s.NewDMap(name); err != nil {
+			return 0, err
+		}
+	}
+
+	var total int
+	for _, sf := range snapshot.fragments {
+		release, err := s.admitImport(ctx, sf.f.Stats().Inuse, true)
+		if err != nil {
+			return total, err
+		}
+		count, err := s.restoreFragment(name, sf)
+		release(count)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	s.log.V(2).Printf("[INFO] Snapshot of the destroyed DMap: %s has been restored: %d entries", name, total)
+	return total, nil
+}
+
+// Undo restores the snapshots of a destroyed DMap on the cluster and returns
+// the number of restored entries. The entries that are written after Destroy
+// are kept. It returns ErrSnapshotNotFound if there is no snapshot on any
+// member. See config.DMaps.DestroySnapshotRetention.
+func (s *Service) Undo(ctx context.Context, name string) (int, error) {
+	var total int
+	var found bool
+	for _, member := range s.rt.Discovery().GetMembers() {
+		var count int
+		var err error
+		if member.CompareByID(s.rt.This()) {
+			count, err = s.undoLocal(ctx, name)
+		} else {
+			cmd := protocol.NewUndo(name).SetLocal().Command(ctx)
+			rc := s.client.Get(member.String())
+			err = rc.Process(ctx, cmd)
+			if err == nil {
+				var n int64
+				n, err = cmd.Result()
+				count = int(n)
+			}
+			err = protocol.ConvertError(err)
+		}
+		if errors.Is(err, ErrSnapshotNotFound) {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		found = true
+		total += count
+	}
+
+	if !found {
+		return 0, ErrSnapshotNotFound
+	}
+	return total, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Undo(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c1 := testutil.NewConfig()
+	c1.DMaps.DestroySnapshotRetention = time.Hour
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	c2 := testutil.NewConfig()
+	c2.DMaps.DestroySnapshotRetention = time.Hour
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	err = dm1.Destroy(ctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm1.Get(ctx, testutil.ToKey(i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+
+	// The writes after Destroy are kept.
+	dm1, err = s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+	err = dm1.Put(ctx, testutil.ToKey(0), "new-value", nil)
+	require.NoError(t, err)
+
+	count, err := s1.Undo(ctx, "mydmap")
+	require.NoError(t, err)
+	require.Equal(t, 99, count)
+
+	e, err := dm1.Get(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+	require.Equal(t, []byte("new-value"), e.Value())
+	for i := 1; i < 100; i++ {
+		e, err = dm1.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), e.Value())
+	}
+
+	_, err = s1.Undo(ctx, "mydmap")
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestDMap_Undo_Expired(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	c := testutil.NewConfig()
+	c.DMaps.DestroySnapshotRetention = time.Millisecond
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	err = dm.Destroy(ctx)
+	require.NoError(t, err)
+
+	<-time.After(5 * time.Millisecond)
+	s.deleteExpiredSnapshots()
+
+	_, err = s.Undo(ctx, "mydmap")
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestDMap_Undo_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm.Put(ctx, "mykey", "myvalue", nil)
+	require.NoError(t, err)
+
+	err = dm.Destroy(ctx)
+	require.NoError(t, err)
+
+	_, err = s.Undo(ctx, "mydmap")
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+}
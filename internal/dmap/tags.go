@@ -0,0 +1,172 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// tagIndex maps tags to the keys that carry them in a fragment. It's
+// maintained on the partition owner and the backup owners, the tags are
+// replicated with PutEntry and moved with the fragments. It's not
+// thread-safe, callers have to hold the fragment lock.
+type tagIndex struct {
+	keys map[string]map[uint64]string
+	tags map[uint64][]string
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		keys: make(map[string]map[uint64]string),
+		tags: make(map[uint64][]string),
+	}
+}
+
+// set replaces the tags of the given key. Passing no tags removes the key
+// from the index.
+func (t *tagIndex) set(hkey uint64, key string, tags []string) {
+	t.delete(hkey)
+	if len(tags) == 0 {
+		return
+	}
+	for _, tag := range tags {
+		keys, ok := t.keys[tag]
+		if !ok {
+			keys = make(map[uint64]string)
+			t.keys[tag] = keys
+		}
+		keys[hkey] = key
+	}
+	t.tags[hkey] = tags
+}
+
+func (t *tagIndex) delete(hkey uint64) {
+	for _, tag := range t.tags[hkey] {
+		keys := t.keys[tag]
+		delete(keys, hkey)
+		if len(keys) == 0 {
+			delete(t.keys, tag)
+		}
+	}
+	delete(t.tags, hkey)
+}
+
+func (t *tagIndex) keysByTag(tag string) map[uint64]string {
+	result := make(map[uint64]string)
+	for hkey, key := range t.keys[tag] {
+		result[hkey] = key
+	}
+	return result
+}
+
+// deleteByTagOnFragment deletes the entries that carry the tag in the fragment.
+func (dm *DMap) deleteByTagOnFragment(f *fragment, tag string) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	var count int
+	for hkey, key := range f.tags.keysByTag(tag) {
+		ttl, err := f.storage.GetTTL(hkey)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			f.tags.delete(hkey)
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		expired := isKeyExpired(ttl) || dm.isKeyIdleOnFragment(hkey, f)
+		if err = dm.deleteOnCluster(hkey, key, f); err != nil {
+			return count, err
+		}
+		if expired {
+			// The entry is removed as the eviction would do, it's not counted.
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// deleteByTagLocal deletes the entries carrying the tag on the partitions
+// owned by this member. It stops between the partitions if ctx is canceled.
+func (dm *DMap) deleteByTagLocal(ctx context.Context, tag string) (int, error) {
+	var total int
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		if err := ctx.Err(); err != nil {
+			CanceledOperationsTotal.Increase(1)
+			return total, err
+		}
+		part := dm.s.primary.PartitionByID(partID)
+		if !part.Owner().CompareByID(dm.s.rt.This()) {
+			continue
+		}
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		count, err := dm.deleteByTagOnFragment(f, tag)
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// DeleteByTag deletes all entries that carry the given tag in the cluster.
+// It returns the number of deleted entries.
+func (dm *DMap) DeleteByTag(ctx context.Context, tag string) (int, error) {
+	if err := dm.s.rt.CheckBootstrapQuorum(); err != nil {
+		return 0, err
+	}
+	if err := dm.s.rt.CheckMemberCountQuorum(); err != nil {
+		return 0, err
+	}
+	if err := dm.checkNamespace(); err != nil {
+		return 0, err
+	}
+
+	var total int
+	for _, member := range dm.s.rt.Discovery().GetMembers() {
+		if member.CompareByID(dm.s.rt.This()) {
+			count, err := dm.deleteByTagLocal(ctx, tag)
+			total += count
+			if err != nil {
+				return total, err
+			}
+			continue
+		}
+
+		cmd := protocol.NewDelByTag(dm.name, tag).SetLocal().Command(ctx)
+		rc := dm.s.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return total, protocol.ConvertError(err)
+		}
+		count, err := cmd.Result()
+		if err != nil {
+			return total, protocol.ConvertError(err)
+		}
+		total += int(count)
+	}
+	return total, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrTombstonesDisabled is returned when a DMap has no tombstone retention
+// configured.
+var ErrTombstonesDisabled = errors.New("tombstones are disabled")
+
+// Tombstone is kept by the partition owner after a key is deleted.
+type Tombstone struct {
+	Key string `msgpack:"key"`
+
+	// DeletedAt is the time of the deletion in nanoseconds.
+	DeletedAt int64 `msgpack:"deleted_at"`
+
+	// DeletedBy is the address of the client that deleted the key. It's the
+	// address of the member for the embedded clients.
+	DeletedBy string `msgpack:"deleted_by"`
+
+	// Owner is the partition owner that applied the deletion.
+	Owner string `msgpack:"owner"`
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx that carries the address of the client
+// that issued the request. It's recorded in the tombstones, and it identifies
+// the client for the rate limits, unless WithRateLimitID is set.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientOf returns the client address that is carried by ctx, or the address
+// of this member if there is none.
+func (dm *DMap) clientOf(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	if client == "" {
+		return dm.s.rt.This().String()
+	}
+	return client
+}
+
+// tombstones keeps the tombstones of a DMap on a node.
+type tombstones struct {
+	mtx       sync.Mutex
+	retention time.Duration
+	m         map[string]Tombstone
+}
+
+func (t *tombstones) add(ts Tombstone) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.m[ts.Key] = ts
+}
+
+func (t *tombstones) forget(key string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	delete(t.m, key)
+}
+
+func (t *tombstones) get(key string, now time.Time) (Tombstone, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	ts, ok := t.m[key]
+	if !ok {
+		return Tombstone{}, false
+	}
+	if now.UnixNano()-ts.DeletedAt > t.retention.Nanoseconds() {
+		delete(t.m, key)
+		return Tombstone{}, false
+	}
+	return ts, true
+}
+
+func (t *tombstones) prune(now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for key, ts := range t.m {
+		if now.UnixNano()-ts.DeletedAt > t.retention.Nanoseconds() {
+			delete(t.m, key)
+		}
+	}
+}
+
+// tombstonesOf returns the tombstones of a DMap and creates them if required.
+func (s *Service) tombstonesOf(name string, retention time.Duration) *tombstones {
+	s.tombstoneMtx.Lock()
+	defer s.tombstoneMtx.Unlock()
+
+	t, ok := s.tombstones[name]
+	if !ok {
+		t = &tombstones{m: make(map[string]Tombstone)}
+		s.tombstones[name] = t
+	}
+	t.retention = retention
+	return t
+}
+
+// pruneTombstones removes the tombstones that are older than their retention.
+func (s *Service) pruneTombstones(now time.Time) {
+	s.tombstoneMtx.Lock()
+	defer s.tombstoneMtx.Unlock()
+
+	for _, t := range s.tombstones {
+		t.prune(now)
+	}
+}
+
+func (dm *DMap) tombstonesEnabled() bool {
+	return dm.config() != nil && dm.config().tombstoneRetention > 0
+}
+
+// recordTombstone keeps a tombstone for the deleted key, if tombstones are
+// enabled for this DMap.
+func (dm *DMap) recordTombstone(ctx context.Context, key string) {
+	if !dm.tombstonesEnabled() {
+		return
+	}
+	dm.s.tombstonesOf(dm.name, dm.config().tombstoneRetention).add(Tombstone{
+		Key:       key,
+		DeletedAt: dm.s.clock.Now(),
+		DeletedBy: dm.clientOf(ctx),
+		Owner:     dm.s.rt.This().String(),
+	})
+}
+
+// forgetTombstone removes the tombstone of a key that is written again.
+func (dm *DMap) forgetTombstone(key string) {
+	if !dm.tombstonesEnabled() {
+		return
+	}
+	dm.s.tombstonesOf(dm.name, dm.config().tombstoneRetention).forget(key)
+}
+
+func (dm *DMap) localTombstone(key string) (*Tombstone, error) {
+	if !dm.tombstonesEnabled() {
+		return nil, ErrTombstonesDisabled
+	}
+	ts, ok := dm.s.tombstonesOf(dm.name, dm.config().tombstoneRetention).get(key, time.Now())
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return &ts, nil
+}
+
+// GetTombstone returns the tombstone of a deleted key from its partition
+// owner. It returns ErrKeyNotFound if there is no tombstone for the key.
+func (dm *DMap) GetTombstone(ctx context.Context, key string) (*Tombstone, error) {
+	hkey := dm.HKey(key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.localTombstone(key)
+	}
+
+	cmd := protocol.NewTombstone(dm.name, key).Command(dm.s.ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	data, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	ts := &Tombstone{}
+	if err = msgpack.Unmarshal(data, ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
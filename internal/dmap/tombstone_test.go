@@ -0,0 +1,108 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Tombstone(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	dc := config.DMap{TombstoneRetention: time.Hour}
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	// Some of the keys are forwarded to the other member.
+	clientCtx := WithClient(ctx, "10.0.0.1:54321")
+	for i := 0; i < 10; i++ {
+		_, err = dm1.Delete(clientCtx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		for _, dm := range []*DMap{dm1, dm2} {
+			ts, err := dm.GetTombstone(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+			require.Equal(t, testutil.ToKey(i), ts.Key)
+			require.Equal(t, "10.0.0.1:54321", ts.DeletedBy)
+			require.NotZero(t, ts.DeletedAt)
+			require.Equal(t, dm.s.primary.PartitionByHKey(partitions.HKey("mydmap", testutil.ToKey(i))).Owner().String(), ts.Owner)
+		}
+	}
+
+	// A write removes the tombstone.
+	err = dm2.Put(ctx, testutil.ToKey(0), testutil.ToVal(0), nil)
+	require.NoError(t, err)
+	_, err = dm1.GetTombstone(ctx, testutil.ToKey(0))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// The keys that are never deleted have no tombstone.
+	_, err = dm1.GetTombstone(ctx, "never-deleted")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Tombstone_Expired(t *testing.T) {
+	ts := &tombstones{retention: time.Minute, m: make(map[string]Tombstone)}
+	now := time.Now()
+	ts.add(Tombstone{Key: "old", DeletedAt: now.Add(-2 * time.Minute).UnixNano()})
+	ts.add(Tombstone{Key: "new", DeletedAt: now.UnixNano()})
+
+	_, ok := ts.get("old", now)
+	require.False(t, ok)
+	_, ok = ts.get("new", now)
+	require.True(t, ok)
+
+	ts.prune(now.Add(2 * time.Minute))
+	require.Len(t, ts.m, 0)
+}
+
+func TestDMap_Tombstone_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := cluster.AddMember(nil).(*Service)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm.Put(ctx, "mykey", "myvalue", nil)
+	require.NoError(t, err)
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+
+	_, err = dm.GetTombstone(ctx, "mykey")
+	require.ErrorIs(t, err, ErrTombstonesDisabled)
+}
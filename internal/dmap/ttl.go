@@ -0,0 +1,88 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// jitterTTL cuts a random part of the remaining time to live, up to
+// PutConfig.TTLJitter percent of it. The entry never outlives the given TTL.
+func jitterTTL(ttl int64, cfg *PutConfig) int64 {
+	if ttl == 0 || !cfg.HasTTLJitter || cfg.TTLJitter <= 0 {
+		return ttl
+	}
+	percent := cfg.TTLJitter
+	if percent > 100 {
+		percent = 100
+	}
+	remaining := ttl - time.Now().UnixNano()/1000000
+	if remaining <= 0 {
+		return ttl
+	}
+	return ttl - int64(rand.Float64()*percent/100*float64(remaining))
+}
+
+// toMilliseconds converts d to milliseconds. It rounds up, a duration under a
+// millisecond doesn't become zero.
+func toMilliseconds(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// slideTTL resets the TTL of an entry that is written with a sliding
+// expiration, and updates the TTL of the given entry. It's called on the
+// partition owner after a read. The backups keep the TTL of the last write.
+// The TTL isn't reset until a hundredth of the sliding expiration has passed
+// since the last reset, so the reads of a hot key don't take the fragment lock
+// every time.
+func (dm *DMap) slideTTL(hkey uint64, entry storage.Entry) {
+	sliding := entry.Sliding()
+	if sliding == 0 {
+		return
+	}
+	ttl := sliding + time.Now().UnixNano()/1000000
+	step := sliding / 100
+	if step == 0 {
+		step = 1
+	}
+	if ttl-entry.TTL() < step {
+		return
+	}
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if err != nil {
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	// The key may have been overwritten since it was read.
+	current, err := f.storage.Get(hkey)
+	if err != nil || current.Timestamp() != entry.Timestamp() {
+		return
+	}
+	entry.SetTTL(ttl)
+	err = f.storage.UpdateTTL(hkey, entry)
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		dm.s.log.V(3).Printf("[ERROR] Failed to reset the TTL of the key on DMap: %s: %v", dm.name, err)
+	}
+}
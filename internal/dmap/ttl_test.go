@@ -0,0 +1,135 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_jitterTTL(t *testing.T) {
+	now := time.Now().UnixNano() / 1000000
+	ttl := now + 10000
+
+	require.Equal(t, ttl, jitterTTL(ttl, &PutConfig{}))
+	require.Equal(t, int64(0), jitterTTL(0, &PutConfig{HasTTLJitter: true, TTLJitter: 50}))
+
+	for i := 0; i < 100; i++ {
+		jittered := jitterTTL(ttl, &PutConfig{HasTTLJitter: true, TTLJitter: 50})
+		require.LessOrEqual(t, jittered, ttl)
+		require.GreaterOrEqual(t, jittered, now+5000-10)
+
+		// Capped at 100 percent.
+		jittered = jitterTTL(ttl, &PutConfig{HasTTLJitter: true, TTLJitter: 500})
+		require.GreaterOrEqual(t, jittered, now)
+	}
+}
+
+func TestDMap_Put_SlidingExpiration(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(ctx, "mykey", "myvalue", &PutConfig{
+		HasSliding: true,
+		Sliding:    200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// Every read resets the TTL, the key outlives the sliding duration.
+	for i := 0; i < 3; i++ {
+		<-time.After(100 * time.Millisecond)
+		_, err = dm.Get(ctx, "mykey")
+		require.NoError(t, err)
+	}
+
+	<-time.After(300 * time.Millisecond)
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	t.Run("A write without sliding expiration clears it", func(t *testing.T) {
+		err = dm.Put(ctx, "mykey", "myvalue", &PutConfig{
+			HasSliding: true,
+			Sliding:    time.Hour,
+		})
+		require.NoError(t, err)
+		err = dm.Put(ctx, "mykey", "myvalue", nil)
+		require.NoError(t, err)
+
+		e, err := dm.Get(ctx, "mykey")
+		require.NoError(t, err)
+		require.Equal(t, int64(0), e.TTL())
+	})
+}
+
+func TestDMap_Put_SlidingExpiration_Backup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		e := testcluster.NewEnvironment(c)
+		return cluster.AddMember(e).(*Service)
+	}
+
+	s1 := newService()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newService()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm1.Put(ctx, "mykey", "myvalue", &PutConfig{
+		HasSliding: true,
+		Sliding:    time.Hour,
+	})
+	require.NoError(t, err)
+
+	// The sliding expiration is stored in the entry, so the backup owner
+	// keeps sliding the TTL after a failover.
+	var found bool
+	for _, dm := range []*DMap{dm1, dm2} {
+		hkey := dm.HKey("mykey")
+		f, err := dm.loadFragment(dm.getPartitionByHKey(hkey, partitions.BACKUP))
+		if err != nil {
+			continue
+		}
+		entry, err := f.storage.Get(hkey)
+		require.NoError(t, err)
+		require.Equal(t, time.Hour.Milliseconds(), entry.Sliding())
+		found = true
+	}
+	require.True(t, found)
+}
+
+func TestDMap_toMilliseconds(t *testing.T) {
+	require.Equal(t, int64(0), toMilliseconds(0))
+	require.Equal(t, int64(1), toMilliseconds(500*time.Microsecond))
+	require.Equal(t, int64(1), toMilliseconds(time.Millisecond))
+	require.Equal(t, int64(2), toMilliseconds(1500*time.Microsecond))
+}
@@ -0,0 +1,675 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/resp"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	// ErrTxConflict is returned if a key that is read by a transaction is
+	// modified by someone else before the transaction is committed.
+	ErrTxConflict = errors.New("transaction conflict")
+
+	// ErrCrossPartitionTx is returned if a transaction accesses the keys of
+	// different partitions. The keys with the same hash tag, e.g. {user:1},
+	// are stored on the same partition if HashTags is enabled for the DMap.
+	ErrCrossPartitionTx = errors.New("keys of a transaction must be on the same partition")
+
+	// TxCommitsTotal is the number of the committed transactions.
+	TxCommitsTotal = stats.NewInt64Counter()
+
+	// TxConflictsTotal is the number of the transactions that are rejected
+	// with ErrTxConflict.
+	TxConflictsTotal = stats.NewInt64Counter()
+
+	// ErrTxPutOption is returned if Tx.Put is called with an option other
+	// than EX, PX, EXAT and PXAT.
+	ErrTxPutOption = errors.New("only EX, PX, EXAT and PXAT are supported in transactions")
+
+	// TxRollbacksTotal is the number of the transactions that are rolled back
+	// after a failed write.
+	TxRollbacksTotal = stats.NewInt64Counter()
+)
+
+// TxWrite is a write that is buffered by a transaction.
+type TxWrite struct {
+	Key    string `msgpack:"key"`
+	Value  []byte `msgpack:"value"`
+	Delete bool   `msgpack:"delete"`
+
+	// TTL is the timeout of the key after the commit and ExpireAt is its
+	// expiry time in milliseconds. The key doesn't expire if both are zero.
+	TTL      time.Duration `msgpack:"ttl"`
+	ExpireAt int64         `msgpack:"expire_at"`
+}
+
+// txReplica carries the writes of a committed transaction to the backup
+// owners. Entries are the encoded entries of the stored keys, Keys are their
+// keys and Clock is the latest timestamp of them, so the backup owners don't
+// decode the entries.
+type txReplica struct {
+	Entries [][]byte `msgpack:"entries"`
+	Keys    []string `msgpack:"keys"`
+	Clock   int64    `msgpack:"clock"`
+	Deletes []string `msgpack:"deletes"`
+}
+
+// txUndo is the previous version of a key that is written by a transaction,
+// it's nil if the key didn't exist.
+type txUndo struct {
+	hkey     uint64
+	previous []byte
+}
+
+// txRequest is sent to the partition owner to commit a transaction. Reads
+// holds the timestamps of the entries that are read by the transaction, zero
+// if the key doesn't exist.
+type txRequest struct {
+	Reads  map[string]int64 `msgpack:"reads"`
+	Writes []TxWrite        `msgpack:"writes"`
+}
+
+// Tx reads the keys from the cluster and buffers the writes until the
+// transaction is committed. It's not safe for concurrent use.
+type Tx struct {
+	dm      *DMap
+	ctx     context.Context
+	partID  uint64
+	hasPart bool
+	writes  map[string]int
+	request txRequest
+}
+
+func (dm *DMap) newTx(ctx context.Context) *Tx {
+	return &Tx{
+		dm:     dm,
+		ctx:    ctx,
+		writes: make(map[string]int),
+		request: txRequest{
+			Reads: make(map[string]int64),
+		},
+	}
+}
+
+func (tx *Tx) checkPartition(key string) error {
+	partID := tx.dm.s.primary.PartitionIDByHKey(tx.dm.HKey(key))
+	if !tx.hasPart {
+		tx.partID, tx.hasPart = partID, true
+		return nil
+	}
+	if partID != tx.partID {
+		return fmt.Errorf("%w: %s", ErrCrossPartitionTx, key)
+	}
+	return nil
+}
+
+// Get returns the entry of the key. The value that is written by the
+// transaction is returned, if there is any.
+func (tx *Tx) Get(key string) (storage.Entry, error) {
+	if err := tx.checkPartition(key); err != nil {
+		return nil, err
+	}
+
+	if i, ok := tx.writes[key]; ok {
+		w := tx.request.Writes[i]
+		if w.Delete {
+			return nil, ErrKeyNotFound
+		}
+		e := tx.dm.engine.NewEntry()
+		e.SetKey(key)
+		e.SetValue(w.Value)
+		return e, nil
+	}
+
+	e, err := tx.dm.Get(tx.ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	if _, ok := tx.request.Reads[key]; !ok {
+		var timestamp int64
+		if e != nil {
+			timestamp = e.Timestamp()
+		}
+		tx.request.Reads[key] = timestamp
+	}
+	return e, err
+}
+
+func (tx *Tx) write(w TxWrite) error {
+	if err := tx.checkPartition(w.Key); err != nil {
+		return err
+	}
+	if i, ok := tx.writes[w.Key]; ok {
+		tx.request.Writes[i] = w
+		return nil
+	}
+	tx.writes[w.Key] = len(tx.request.Writes)
+	tx.request.Writes = append(tx.request.Writes, w)
+	return nil
+}
+
+// Put sets the value of the key when the transaction is committed. The TTL
+// options of cfg are supported, cfg may be nil. The default TTL of the DMap
+// is used if there is no TTL option.
+func (tx *Tx) Put(key string, value interface{}, cfg *PutConfig) error {
+	w := TxWrite{Key: key}
+	if cfg != nil {
+		if cfg.HasNX || cfg.HasXX || cfg.HasSliding || cfg.HasPublish || cfg.HasTimestamp ||
+			cfg.HasTTLJitter || cfg.OnlyUpdateTTL || len(cfg.Tags) > 0 {
+			return ErrTxPutOption
+		}
+		switch {
+		case cfg.HasEX:
+			w.TTL = cfg.EX
+		case cfg.HasPX:
+			w.TTL = cfg.PX
+		case cfg.HasEXAT:
+			w.ExpireAt = cfg.EXAT.Milliseconds()
+		case cfg.HasPXAT:
+			w.ExpireAt = cfg.PXAT.Milliseconds()
+		}
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	enc := resp.New(valueBuf)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	w.Value = make([]byte, valueBuf.Len())
+	copy(w.Value, valueBuf.Bytes())
+	return tx.write(w)
+}
+
+// Delete deletes the key when the transaction is committed.
+func (tx *Tx) Delete(key string) error {
+	return tx.write(TxWrite{Key: key, Delete: true})
+}
+
+// Tx runs fn and commits its writes atomically on the partition owner. The
+// keys have to be on the same partition. Nothing is written if fn returns an
+// error. It returns ErrTxConflict if a key that fn reads is modified before
+// the commit, the caller may run the transaction again.
+//
+// The partition owner applies the writes and replicates them to the backup
+// owners while it holds the lock of the partition's fragment, so the other
+// reads and writes on the partition see all of the writes or none of them.
+// The writes are rolled back on the owner if one of them fails or the write
+// quorum cannot be reached.
+func (dm *DMap) Tx(ctx context.Context, fn func(tx *Tx) error) error {
+	tx := dm.newTx(ctx)
+	if err := fn(tx); err != nil {
+		// Rollback, the writes are discarded.
+		return err
+	}
+	// A transaction that reads a single key is consistent anyway.
+	if len(tx.request.Writes) == 0 && len(tx.request.Reads) <= 1 {
+		return nil
+	}
+	return dm.commitTx(ctx, &tx.request)
+}
+
+func (dm *DMap) commitTx(ctx context.Context, req *txRequest) error {
+	var key string
+	for key = range req.Reads {
+		break
+	}
+	if len(req.Writes) > 0 {
+		key = req.Writes[0].Key
+	}
+
+	member := dm.s.primary.PartitionByHKey(dm.HKey(key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.commitTxOnCluster(ctx, req)
+	}
+
+	data, err := msgpack.Marshal(req)
+	if err != nil {
+		return err
+	}
+	cmd := protocol.NewTx(dm.name, data).Command(dm.s.ctx)
+	rc := dm.s.client.Get(member.String())
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// txKeys returns the keys of the transaction in a deterministic order, the
+// locks are acquired in this order.
+func txKeys(req *txRequest) []string {
+	unique := make(map[string]struct{})
+	for key := range req.Reads {
+		unique[key] = struct{}{}
+	}
+	for _, w := range req.Writes {
+		unique[w.Key] = struct{}{}
+	}
+	keys := make([]string, 0, len(unique))
+	for key := range unique {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// txTimestamp returns the timestamp of the key on the fragment, zero if the
+// key doesn't exist or it's expired. The fragment lock has to be held.
+func txTimestamp(f *fragment, hkey uint64) (int64, error) {
+	e, err := f.storage.Get(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if isKeyExpired(e.TTL()) {
+		return 0, nil
+	}
+	return e.Timestamp(), nil
+}
+
+// txTTL returns the expiry time of a write in milliseconds.
+func (dm *DMap) txTTL(w TxWrite) int64 {
+	timeout := w.TTL
+	switch {
+	case w.ExpireAt != 0:
+		return w.ExpireAt
+	case timeout == 0 && dm.config() != nil:
+		timeout = dm.config().ttlDuration
+	}
+	if timeout == 0 {
+		return 0
+	}
+	return (timeout.Nanoseconds() + time.Now().UnixNano()) / 1000000
+}
+
+func (dm *DMap) commitTxOnCluster(ctx context.Context, req *txRequest) error {
+	keys := txKeys(req)
+	hkey := dm.HKey(keys[0])
+	partID := dm.s.primary.PartitionIDByHKey(hkey)
+	for _, key := range keys {
+		if dm.s.primary.PartitionIDByHKey(dm.HKey(key)) != partID {
+			return fmt.Errorf("%w: %s", ErrCrossPartitionTx, key)
+		}
+	}
+	for _, w := range req.Writes {
+		if err := dm.checkWrite(w.Key); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range keys {
+		dm.s.locker.Lock(dm.name + key)
+	}
+	defer func() {
+		for _, key := range keys {
+			dm.releaseAtomicKey(dm.name+key, key, dm.name)
+		}
+	}()
+
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return err
+	}
+
+	// prepare checks the transaction and creates its entries. The fragment
+	// lock has to be held.
+	prepare := func() ([]storage.Entry, []config.WriteOp, error) {
+		if err := dm.checkOwnerEpoch(hkey); err != nil {
+			return nil, nil, err
+		}
+		for key, timestamp := range req.Reads {
+			current, err := txTimestamp(f, dm.HKey(key))
+			if err != nil {
+				return nil, nil, err
+			}
+			if current != timestamp {
+				TxConflictsTotal.Increase(1)
+				return nil, nil, fmt.Errorf("%w: %s", ErrTxConflict, key)
+			}
+		}
+		return dm.prepareTx(f, req.Writes)
+	}
+
+	if dm.writerEnabled(config.WriteThrough) {
+		// The Writer is called without holding the fragment lock, see
+		// lockKeyForWriter. The transaction is checked again before it's
+		// applied.
+		for _, key := range keys {
+			unlock := dm.lockKeyForWriter(key)
+			defer unlock()
+		}
+
+		f.Lock()
+		_, ops, err := prepare()
+		f.Unlock()
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if err = dm.writeThrough(ctx, op); err != nil {
+				return err
+			}
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	entries, ops, err := prepare()
+	if err != nil {
+		return err
+	}
+
+	undo, err := dm.applyTx(f, req.Writes, entries)
+	if err != nil {
+		return err
+	}
+
+	rep := &txReplica{}
+	for i, w := range req.Writes {
+		if w.Delete {
+			rep.Deletes = append(rep.Deletes, w.Key)
+			continue
+		}
+		rep.Entries = append(rep.Entries, entries[i].Encode())
+		rep.Keys = append(rep.Keys, w.Key)
+		if ts := entries[i].Timestamp(); ts > rep.Clock {
+			rep.Clock = ts
+		}
+	}
+	if err = dm.replicateTx(hkey, rep); err != nil {
+		dm.rollbackTx(f, undo)
+		return err
+	}
+
+	dm.completeTx(ctx, f, req.Writes, entries, ops)
+	TxCommitsTotal.Increase(1)
+	return nil
+}
+
+// prepareTx creates the entries of the writes and their WriteOps. The
+// fragment lock has to be held.
+func (dm *DMap) prepareTx(f *fragment, writes []TxWrite) ([]storage.Entry, []config.WriteOp, error) {
+	entries := make([]storage.Entry, len(writes))
+	ops := make([]config.WriteOp, len(writes))
+	for i, w := range writes {
+		if w.Delete {
+			ops[i] = config.WriteOp{Key: w.Key, Delete: true}
+			continue
+		}
+
+		e := &env{
+			key:       w.Key,
+			hkey:      dm.HKey(w.Key),
+			fragment:  f,
+			putConfig: &PutConfig{},
+		}
+		if err := dm.checkQuotas(e); err != nil {
+			return nil, nil, err
+		}
+
+		nt := f.storage.NewEntry()
+		nt.SetKey(w.Key)
+		if err := dm.encodeValue(nt, w.Value); err != nil {
+			return nil, nil, err
+		}
+		nt.SetTTL(dm.txTTL(w))
+		nt.SetTimestamp(dm.s.clock.Now())
+		entries[i] = nt
+
+		if dm.writerEnabled(config.WriteThrough) || dm.writerEnabled(config.WriteBehind) {
+			op, err := dm.newWriteOp(nt)
+			if err != nil {
+				return nil, nil, err
+			}
+			ops[i] = op
+		}
+	}
+	return entries, ops, nil
+}
+
+// applyTx applies the writes of a transaction to the fragment. The applied
+// writes are rolled back if one of them fails. The fragment lock has to be
+// held.
+func (dm *DMap) applyTx(f *fragment, writes []TxWrite, entries []storage.Entry) ([]txUndo, error) {
+	undo := make([]txUndo, 0, len(writes))
+	for i, w := range writes {
+		u := txUndo{hkey: dm.HKey(w.Key)}
+		previous, err := f.storage.Get(u.hkey)
+		if err == nil {
+			u.previous = previous.Encode()
+		}
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			err = nil
+		}
+		if err == nil {
+			if w.Delete {
+				err = f.storage.Delete(u.hkey)
+			} else {
+				err = dm.storeWithinBudget(f, func() error {
+					return f.storage.Put(u.hkey, entries[i])
+				})
+			}
+		}
+		if errors.Is(err, storage.ErrKeyTooLarge) {
+			err = ErrKeyTooLarge
+		}
+		if errors.Is(err, storage.ErrEntryTooLarge) {
+			err = ErrEntryTooLarge
+		}
+		if err != nil {
+			dm.rollbackTx(f, undo)
+			return nil, err
+		}
+		undo = append(undo, u)
+	}
+	return undo, nil
+}
+
+// rollbackTx restores the previous versions of the keys that are written by
+// a failed transaction. The fragment lock has to be held.
+func (dm *DMap) rollbackTx(f *fragment, undo []txUndo) {
+	TxRollbacksTotal.Increase(1)
+	for i := len(undo) - 1; i >= 0; i-- {
+		var err error
+		if undo[i].previous == nil {
+			err = f.storage.Delete(undo[i].hkey)
+		} else {
+			err = f.storage.PutRaw(undo[i].hkey, undo[i].previous)
+		}
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to roll back a transaction on DMap: %s: %v", dm.name, err)
+		}
+	}
+}
+
+// replicateTx sends the writes of a transaction to the backup owners. They
+// apply the writes at once. The fragment lock has to be held.
+func (dm *DMap) replicateTx(hkey uint64, rep *txReplica) error {
+	if dm.s.config.ReplicaCount <= config.MinimumReplicaCount {
+		return nil
+	}
+	data, err := msgpack.Marshal(rep)
+	if err != nil {
+		return err
+	}
+
+	epoch := dm.epochOf(hkey)
+	owners := dm.s.backup.PartitionOwnersByHKey(hkey)
+	if dm.s.config.ReplicationMode == config.AsyncReplicationMode {
+		for _, owner := range owners {
+			if !dm.s.isAlive() {
+				return ErrServerGone
+			}
+			dm.s.wg.Add(1)
+			go func(owner discovery.Member) {
+				defer dm.s.wg.Done()
+				if err := dm.sendTxReplica(hkey, epoch, data, owner); err != nil {
+					dm.s.log.V(3).Printf("[ERROR] Failed to replicate a transaction in async mode: %v", err)
+				}
+			}(owner)
+		}
+		return nil
+	}
+
+	// This member has already applied the writes.
+	successful := 1
+	for _, owner := range owners {
+		err := dm.sendTxReplica(hkey, epoch, data, owner)
+		if errors.Is(err, ErrStaleEpoch) {
+			return err
+		}
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to replicate a transaction to %s for DMap: %s: %v", owner, dm.name, err)
+			continue
+		}
+		successful++
+	}
+	if successful >= dm.s.runtimeConfig().WriteQuorum {
+		return nil
+	}
+	return ErrWriteQuorum
+}
+
+func (dm *DMap) sendTxReplica(hkey, epoch uint64, data []byte, owner discovery.Member) error {
+	cmd := protocol.NewTx(dm.name, data).SetReplica().SetEpoch(epoch).Command(dm.s.ctx)
+	rc := dm.s.client.Get(owner.String())
+	err := rc.Process(dm.s.ctx, cmd)
+	if err == nil {
+		err = cmd.Err()
+	}
+	if err != nil {
+		err = protocol.ConvertError(err)
+		// The next writes are refused if this member is not the owner anymore.
+		dm.fenceOnStaleEpoch(hkey, err)
+	}
+	return err
+}
+
+// applyTxReplica applies the writes of a transaction that is committed by the
+// partition owner at once.
+func (dm *DMap) applyTxReplica(epoch uint64, rep *txReplica) error {
+	var key string
+	switch {
+	case len(rep.Keys) > 0:
+		key = rep.Keys[0]
+	case len(rep.Deletes) > 0:
+		key = rep.Deletes[0]
+	default:
+		return nil
+	}
+
+	part := dm.getPartitionByHKey(dm.HKey(key), partitions.BACKUP)
+	if err := checkEpoch(part, epoch); err != nil {
+		return err
+	}
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return err
+	}
+
+	// Keep the clock of this member ahead of the entries of the primary owner.
+	if err = dm.s.clock.Update(rep.Clock); err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	for i, raw := range rep.Entries {
+		hkey := dm.HKey(rep.Keys[i])
+		err = dm.storeWithinBudget(f, func() error {
+			return f.storage.PutRaw(hkey, raw)
+		})
+		if err != nil {
+			return err
+		}
+		// The writes of a transaction clear the tags, like on the owner.
+		f.tags.delete(hkey)
+		EntriesTotal.Increase(1)
+	}
+	for _, key := range rep.Deletes {
+		hkey := dm.HKey(key)
+		f.tags.delete(hkey)
+		f.access.delete(hkey)
+		if err = f.storage.Delete(hkey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeTx records the writes of a committed transaction in the change log,
+// the tombstones and the analytics replicas, and queues them for the Writer
+// in write-behind mode. The fragment lock has to be held.
+func (dm *DMap) completeTx(ctx context.Context, f *fragment, writes []TxWrite, entries []storage.Entry, ops []config.WriteOp) {
+	owners := dm.s.primary.PartitionOwnersByHKey(dm.HKey(writes[0].Key))
+	for i, w := range writes {
+		hkey := dm.HKey(w.Key)
+		f.access.delete(hkey)
+		if w.Delete {
+			f.tags.delete(hkey)
+			if len(owners) > 1 {
+				// The previous owners may still have the key while the
+				// partition is moved.
+				if err := dm.deleteFromPreviousOwners(w.Key, owners); err != nil {
+					dm.s.log.V(3).Printf("[ERROR] Failed to delete key: %s on the previous owners of DMap: %s: %v", w.Key, dm.name, err)
+				}
+			}
+			DeleteHits.Increase(1)
+			dm.recordMutation(Mutation{
+				Kind: MutationDelete,
+				Key:  w.Key,
+			})
+			dm.replicateToAnalytics(hkey, nil)
+			dm.recordTombstone(ctx, w.Key)
+		} else {
+			nt := entries[i]
+			// A write without tags clears the previous tags of the key.
+			f.tags.set(hkey, w.Key, nil)
+			EntriesTotal.Increase(1)
+			dm.forgetTombstone(w.Key)
+			dm.recordMutation(Mutation{
+				Kind:      MutationPut,
+				Key:       w.Key,
+				Value:     nt.Value(),
+				TTL:       nt.TTL(),
+				Timestamp: nt.Timestamp(),
+				codec:     nt.Codec(),
+			})
+			dm.replicateToAnalytics(hkey, nt)
+		}
+		dm.writeBehind(ops[i])
+	}
+}
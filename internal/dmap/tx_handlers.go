@@ -0,0 +1,70 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) txCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	txCmd, err := protocol.ParseTxCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getDMap(txCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	if txCmd.Replica {
+		var rep txReplica
+		if err = msgpack.Unmarshal(txCmd.Payload, &rep); err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		if err = dm.applyTxReplica(txCmd.Epoch, &rep); err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		conn.WriteString(protocol.StatusOK)
+		return
+	}
+
+	var req txRequest
+	if err = msgpack.Unmarshal(txCmd.Payload, &req); err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	if len(req.Reads) == 0 && len(req.Writes) == 0 {
+		conn.WriteString(protocol.StatusOK)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	err = dm.commitTx(ctx, &req)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
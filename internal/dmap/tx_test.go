@@ -0,0 +1,299 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Tx(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	// The transaction runs on a member that doesn't own the keys.
+	var tag string
+	for i := 0; tag == ""; i++ {
+		hkey := partitions.TaggedHKey("mydmap", fmt.Sprintf("{account:%d}", i), s1.config.PartitionCount)
+		if !s1.primary.PartitionByHKey(hkey).Owner().CompareByName(s1.rt.This()) {
+			tag = fmt.Sprintf("{account:%d}", i)
+		}
+	}
+
+	var dmaps []*DMap
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+		dmaps = append(dmaps, dm)
+	}
+
+	ctx := context.Background()
+	for _, dm := range dmaps {
+		from := tag + ".checking"
+		to := tag + ".savings"
+		require.NoError(t, dm.Put(ctx, from, []byte("100"), nil))
+
+		err := dm.Tx(ctx, func(tx *Tx) error {
+			e, err := tx.Get(from)
+			if err != nil {
+				return err
+			}
+			require.Equal(t, []byte("100"), e.Value())
+
+			_, err = tx.Get(to)
+			require.ErrorIs(t, err, ErrKeyNotFound)
+
+			if err = tx.Put(from, []byte("40"), nil); err != nil {
+				return err
+			}
+			if err = tx.Put(to, []byte("60"), nil); err != nil {
+				return err
+			}
+
+			// The buffered writes are visible in the transaction.
+			e, err = tx.Get(to)
+			require.NoError(t, err)
+			require.Equal(t, []byte("60"), e.Value())
+			return nil
+		})
+		require.NoError(t, err)
+
+		for key, value := range map[string]string{from: "40", to: "60"} {
+			e, err := dm.Get(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, []byte(value), e.Value())
+		}
+
+		err = dm.Tx(ctx, func(tx *Tx) error {
+			return tx.Delete(to)
+		})
+		require.NoError(t, err)
+		_, err = dm.Get(ctx, to)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_Tx_Rollback(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+	err = dm.Tx(ctx, func(tx *Tx) error {
+		if err := tx.Put("{tag}.a", []byte("a"), nil); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	_, err = dm.Get(ctx, "{tag}.a")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Tx_Conflict(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "{tag}.a", []byte("a"), nil))
+
+	conflicts := TxConflictsTotal.Read()
+	err = dm.Tx(ctx, func(tx *Tx) error {
+		if _, err := tx.Get("{tag}.a"); err != nil {
+			return err
+		}
+		// Someone else modifies the key before the commit.
+		require.NoError(t, dm.Put(ctx, "{tag}.a", []byte("b"), nil))
+		return tx.Put("{tag}.b", []byte("b"), nil)
+	})
+	require.ErrorIs(t, err, ErrTxConflict)
+	require.Equal(t, conflicts+1, TxConflictsTotal.Read())
+
+	_, err = dm.Get(ctx, "{tag}.b")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Tx_CrossPartition(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Tx(context.Background(), func(tx *Tx) error {
+		for i := 0; i < 10; i++ {
+			if err := tx.Put(fmt.Sprintf("key-%d", i), []byte("value"), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, ErrCrossPartitionTx)
+}
+
+func TestDMap_HashTags_Disabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	tagged, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+	plain, err := s.NewDMap("otherdmap")
+	require.NoError(t, err)
+
+	count := s.config.PartitionCount
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("{user:1}.%d", i)
+		require.Equal(t, partitions.TagPartitionID("user:1", count), tagged.HKey(key)%count)
+		// The DMaps without HashTags don't interpret the curly braces.
+		require.Equal(t, partitions.HKey("otherdmap", key), plain.HKey(key))
+	}
+}
+
+func TestDMap_Tx_TTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = dm.Tx(ctx, func(tx *Tx) error {
+		if err := tx.Put("{tag}.a", []byte("a"), &PutConfig{HasPX: true, PX: time.Hour}); err != nil {
+			return err
+		}
+		return tx.Put("{tag}.b", []byte("b"), nil)
+	})
+	require.NoError(t, err)
+
+	e, err := dm.Get(ctx, "{tag}.a")
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), time.UnixMilli(e.TTL()), time.Minute)
+
+	e, err = dm.Get(ctx, "{tag}.b")
+	require.NoError(t, err)
+	require.Zero(t, e.TTL())
+
+	err = dm.Tx(ctx, func(tx *Tx) error {
+		return tx.Put("{tag}.a", []byte("a"), &PutConfig{HasNX: true})
+	})
+	require.ErrorIs(t, err, ErrTxPutOption)
+}
+
+func TestDMap_Tx_Rollback_Failed_Write(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", config.DMap{HashTags: true})
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "{tag}.a", []byte("a"), nil))
+
+	rollbacks := TxRollbacksTotal.Read()
+	err = dm.Tx(ctx, func(tx *Tx) error {
+		if err := tx.Put("{tag}.a", []byte("b"), nil); err != nil {
+			return err
+		}
+		if err := tx.Put("{tag}.b", []byte("b"), nil); err != nil {
+			return err
+		}
+		// The storage engine rejects the key.
+		return tx.Put("{tag}."+strings.Repeat("c", 300), []byte("c"), nil)
+	})
+	require.ErrorIs(t, err, ErrKeyTooLarge)
+	require.Equal(t, rollbacks+1, TxRollbacksTotal.Read())
+
+	e, err := dm.Get(ctx, "{tag}.a")
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), e.Value())
+
+	_, err = dm.Get(ctx, "{tag}.b")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Tx_Replication(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.WriteQuorum = 2
+		c.DMaps.Custom = map[string]config.DMap{"mydmap": {HashTags: true}}
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+	defer cluster.Shutdown()
+
+	dm, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "{tag}.c", []byte("c"), nil))
+	err = dm.Tx(ctx, func(tx *Tx) error {
+		if err := tx.Put("{tag}.a", []byte("a"), nil); err != nil {
+			return err
+		}
+		if err := tx.Put("{tag}.b", []byte("b"), nil); err != nil {
+			return err
+		}
+		return tx.Delete("{tag}.c")
+	})
+	require.NoError(t, err)
+
+	// Find the backup owner, it has applied all the writes.
+	hkey := dm.HKey("{tag}.a")
+	backup := s1
+	if s1.backup.PartitionOwnersByHKey(hkey)[0].CompareByName(s2.rt.This()) {
+		backup = s2
+	}
+	bdm, err := backup.NewDMap("mydmap")
+	require.NoError(t, err)
+	f, err := bdm.loadFragment(bdm.getPartitionByHKey(hkey, partitions.BACKUP))
+	require.NoError(t, err)
+
+	for key, value := range map[string]string{"{tag}.a": "a", "{tag}.b": "b"} {
+		e, err := f.storage.Get(bdm.HKey(key))
+		require.NoError(t, err)
+		require.Equal(t, []byte(value), e.Value())
+	}
+	require.False(t, f.storage.Check(bdm.HKey("{tag}.c")))
+}
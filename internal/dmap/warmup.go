@@ -0,0 +1,395 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/resp"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/errgroup"
+)
+
+// WarmedUpEntriesTotal is the number of the entries that are stored by Warmup
+// on this member, see Warmup.
+var WarmedUpEntriesTotal = stats.NewInt64Counter()
+
+// warmupBatchSize is the number of the entries that are sent to a partition
+// owner in a single command.
+const warmupBatchSize = 1000
+
+// WarmupEntry is an entry that is preloaded by Warmup.
+type WarmupEntry struct {
+	Key string
+
+	// Value is encoded like the values of Put.
+	Value interface{}
+
+	// TTL of the entry. Zero means the TTL of the DMap, see
+	// config.DMap.TTLDuration. It's rounded up to milliseconds.
+	TTL time.Duration
+}
+
+// WarmupSource provides the entries to Warmup.
+type WarmupSource interface {
+	// Next returns the next entry. It returns io.EOF after the last one.
+	Next(ctx context.Context) (*WarmupEntry, error)
+}
+
+// Warmup preloads the DMap with the entries of the source, and returns the
+// number of the stored entries. The entries are grouped by their partition
+// owners and sent in batches, the batches of the different owners are sent in
+// parallel. The owners store a batch on their primary copies and replicate it
+// to every backup owner in a single pipeline, instead of a replication round
+// trip per key.
+//
+// The keys that already exist are not overwritten and not counted, they may
+// have been written after the source was read. The entries are not propagated
+// to the Writer of the DMap, they are expected to come from the source of
+// truth. The LRU eviction and the quotas are not applied.
+func (dm *DMap) Warmup(ctx context.Context, source WarmupSource) (int, error) {
+	newBatch := func() *protocol.Warmup {
+		return protocol.NewWarmup(dm.name)
+	}
+	return dm.sendInBatches(ctx, newBatch, func(ctx context.Context, add batchAdder) (int, error) {
+		return dm.readWarmupSource(ctx, source, add)
+	})
+}
+
+// batchAdder adds an entry to the batch of the partition owner of the key.
+type batchAdder func(key string, addTo func(batch *protocol.Warmup))
+
+// sendInBatches calls read with a batchAdder, the entries are grouped by
+// their partition owners and sent in batches. read returns the number of the
+// entries that it stores by itself. Every owner has a single batch in flight,
+// the batches of the different owners are sent in parallel. It returns the
+// number of the stored entries.
+func (dm *DMap) sendInBatches(ctx context.Context, newBatch func() *protocol.Warmup,
+	read func(ctx context.Context, add batchAdder) (int, error)) (int, error) {
+	members := make(map[uint64]discovery.Member)
+	batches := make(map[uint64]*protocol.Warmup)
+	// Every owner has a single batch in flight, the source isn't read ahead
+	// of the slowest owner.
+	inflight := make(map[uint64]chan struct{})
+
+	var total int64
+	g, ctx := errgroup.WithContext(ctx)
+	send := func(member discovery.Member, batch *protocol.Warmup) {
+		slot, ok := inflight[member.ID]
+		if !ok {
+			slot = make(chan struct{}, 1)
+			inflight[member.ID] = slot
+		}
+		select {
+		case slot <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		g.Go(func() error {
+			defer func() { <-slot }()
+			count, err := dm.sendWarmupBatch(ctx, member, batch)
+			atomic.AddInt64(&total, int64(count))
+			return err
+		})
+	}
+
+	count, err := read(ctx, func(key string, addTo func(batch *protocol.Warmup)) {
+		member := dm.s.primary.PartitionByHKey(dm.HKey(key)).Owner()
+		batch, ok := batches[member.ID]
+		if !ok {
+			batch = newBatch()
+			batches[member.ID] = batch
+			members[member.ID] = member
+		}
+		addTo(batch)
+		if len(batch.Keys) >= warmupBatchSize {
+			send(member, batch)
+			delete(batches, member.ID)
+		}
+	})
+	if err == nil {
+		for id, batch := range batches {
+			send(members[id], batch)
+		}
+	}
+	if werr := g.Wait(); err == nil {
+		err = werr
+	}
+	return count + int(atomic.LoadInt64(&total)), err
+}
+
+// readWarmupSource reads the entries of the source and adds them to the
+// batches. The chunked values are stored one by one, it returns their count.
+func (dm *DMap) readWarmupSource(ctx context.Context, source WarmupSource, add batchAdder) (int, error) {
+	var count int
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		entry, err := source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		e, err := dm.newWarmupEnv(ctx, entry)
+		if err != nil {
+			return count, err
+		}
+		if err = dm.checkWrite(e.key); err != nil {
+			return count, err
+		}
+		if err = dm.checkValueSize(e); err != nil {
+			return count, err
+		}
+		if dm.chunkingEnabled(e) && len(e.value) > dm.config().chunkSize {
+			stored, err := dm.warmupChunked(e)
+			if err != nil {
+				return count, err
+			}
+			if stored {
+				count++
+			}
+			continue
+		}
+		ttl := toMilliseconds(entry.TTL)
+		add(e.key, func(batch *protocol.Warmup) {
+			batch.Add(e.key, e.value, ttl)
+		})
+	}
+}
+
+// warmupChunked stores a chunked value unless the key exists. The chunked
+// writes don't support NX, so the key is checked before the write.
+func (dm *DMap) warmupChunked(e *env) (bool, error) {
+	_, err := dm.Get(e.ctx, e.key)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, ErrKeyNotFound) {
+		return false, err
+	}
+	if err = dm.putChunked(e); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (dm *DMap) newWarmupEnv(ctx context.Context, entry *WarmupEntry) (*env, error) {
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	err := resp.New(valueBuf).Encode(entry.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	e := dm.s.newEnv(ctx, 0)
+	e.dmap = dm.name
+	e.key = entry.Key
+	e.value = make([]byte, valueBuf.Len())
+	copy(e.value, valueBuf.Bytes())
+	if entry.TTL > 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = entry.TTL
+	}
+	return e, nil
+}
+
+func (dm *DMap) sendWarmupBatch(ctx context.Context, member discovery.Member, batch *protocol.Warmup) (int, error) {
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.warmupOnOwner(ctx, batch)
+	}
+
+	cmd := batch.Command(dm.s.ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	count, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(count), nil
+}
+
+// warmupOnOwner stores the entries of the batch on the primary copies, and
+// replicates them to the backup owners in parallel. The entries whose
+// partitions have moved are redirected to their new owners one by one. The
+// keys that already exist are skipped, unless the batch is imported.
+func (dm *DMap) warmupOnOwner(ctx context.Context, batch *protocol.Warmup) (int, error) {
+	owners := make(map[uint64]discovery.Member)
+	replicas := make(map[uint64][]*redis.StatusCmd)
+
+	var count int
+	for idx, key := range batch.Keys {
+		e := dm.newBatchEnv(ctx, batch, idx)
+
+		if !dm.s.primary.PartitionByHKey(e.hkey).Owner().CompareByName(dm.s.rt.This()) {
+			err := dm.put(e)
+			if errors.Is(err, ErrKeyFound) {
+				continue
+			}
+			if err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
+
+		encoded, err := dm.warmupEntry(e)
+		if errors.Is(err, ErrKeyFound) {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+
+		if dm.s.config.ReplicaCount == config.MinimumReplicaCount {
+			continue
+		}
+		epoch := dm.epochOf(e.hkey)
+		for _, owner := range dm.s.backup.PartitionOwnersByHKey(e.hkey) {
+			owners[owner.ID] = owner
+			cmd := protocol.NewPutEntry(dm.name, key, encoded).
+				SetEpoch(epoch).
+				SetTimestamp(e.timestamp).
+				SetTags(e.putConfig.Tags...).
+				Command(dm.s.ctx)
+			replicas[owner.ID] = append(replicas[owner.ID], cmd)
+		}
+	}
+	if !batch.Import {
+		WarmedUpEntriesTotal.Increase(int64(count))
+	}
+
+	var g errgroup.Group
+	for id, cmds := range replicas {
+		owner, cmds := owners[id], cmds
+		g.Go(func() error {
+			err := dm.sendWarmupReplicas(ctx, owner, cmds)
+			if err == nil {
+				return nil
+			}
+			if dm.s.config.ReplicationMode == config.SyncReplicationMode {
+				return err
+			}
+			dm.s.log.V(3).Printf("[ERROR] Failed to replicate the warm-up batch to %s for DMap: %s: %v", owner, dm.name, err)
+			return nil
+		})
+	}
+	return count, g.Wait()
+}
+
+// newBatchEnv returns the env of the entry at idx in the batch. The warmed up
+// entries are written with NX, the imported ones keep their TTLs, timestamps
+// and tags.
+func (dm *DMap) newBatchEnv(ctx context.Context, batch *protocol.Warmup, idx int) *env {
+	var timestamp int64
+	if batch.Import {
+		timestamp = batch.Timestamps[idx]
+	}
+	e := dm.s.newEnv(ctx, timestamp)
+	e.dmap = dm.name
+	e.key = batch.Keys[idx]
+	e.hkey = dm.HKey(e.key)
+	e.value = batch.Values[idx]
+
+	ttl := batch.TTLs[idx]
+	if batch.Import {
+		e.putConfig.HasTimestamp = true
+		e.putConfig.Timestamp = timestamp
+		e.putConfig.Tags = batch.Tags[idx]
+		if ttl > 0 {
+			e.putConfig.HasPXAT = true
+			e.putConfig.PXAT = time.Duration(ttl) * time.Millisecond
+		}
+		return e
+	}
+
+	e.putConfig.HasNX = true
+	if ttl > 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Duration(ttl) * time.Millisecond
+	} else if dm.config() != nil {
+		e.timeout = dm.config().ttlDuration
+	}
+	return e
+}
+
+// warmupEntry stores the entry on its primary copy and returns it encoded. It
+// returns ErrKeyFound if the key exists and the entry is written with NX.
+func (dm *DMap) warmupEntry(e *env) ([]byte, error) {
+	if dm.config() != nil && dm.config().valueSchema != nil {
+		if err := dm.config().valueSchema.ValidateBytes(e.value); err != nil {
+			return nil, err
+		}
+	}
+
+	part := dm.getPartitionByHKey(e.hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return nil, err
+	}
+
+	e.fragment = f
+	f.Lock()
+	defer f.Unlock()
+
+	if err = dm.checkPutConditions(e); err != nil {
+		return nil, err
+	}
+	nt, err := dm.prepareEntry(e)
+	if err != nil {
+		return nil, err
+	}
+	if err = dm.putEntryOnFragment(e, nt); err != nil {
+		return nil, err
+	}
+	// A write without tags clears the previous tags of the key.
+	f.tags.set(e.hkey, e.key, e.putConfig.Tags)
+	return nt.Encode(), nil
+}
+
+func (dm *DMap) sendWarmupReplicas(ctx context.Context, owner discovery.Member, cmds []*redis.StatusCmd) error {
+	pipe := dm.s.client.Get(owner.String()).Pipeline()
+	for _, cmd := range cmds {
+		if err := pipe.Process(ctx, cmd); err != nil {
+			return protocol.ConvertError(err)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return protocol.ConvertError(err)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return protocol.ConvertError(err)
+		}
+	}
+	return nil
+}
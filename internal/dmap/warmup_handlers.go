@@ -0,0 +1,57 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) warmupCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	warmupCmd, err := protocol.ParseWarmupCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	s.storeWarmupBatch(conn, warmupCmd)
+}
+
+func (s *Service) importCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	importCmd, err := protocol.ParseImportCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	s.storeWarmupBatch(conn, importCmd)
+}
+
+func (s *Service) storeWarmupBatch(conn redcon.Conn, warmupCmd *protocol.Warmup) {
+	dm, err := s.getDMap(warmupCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	count, err := dm.warmupOnOwner(ctx, warmupCmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(count)
+}
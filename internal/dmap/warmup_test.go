@@ -0,0 +1,140 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type sliceWarmupSource struct {
+	entries []*WarmupEntry
+}
+
+func (s *sliceWarmupSource) Next(_ context.Context) (*WarmupEntry, error) {
+	if len(s.entries) == 0 {
+		return nil, io.EOF
+	}
+	entry := s.entries[0]
+	s.entries = s.entries[1:]
+	return entry, nil
+}
+
+func TestDMap_Warmup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	newService := func() *Service {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.WriteQuorum = 2
+		return cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	}
+	s1 := newService()
+	s2 := newService()
+
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	source := &sliceWarmupSource{}
+	for i := 0; i < 2500; i++ {
+		source.entries = append(source.entries, &WarmupEntry{
+			Key:   testutil.ToKey(i),
+			Value: testutil.ToVal(i),
+		})
+	}
+	source.entries = append(source.entries, &WarmupEntry{
+		Key:   "volatile",
+		Value: "myvalue",
+		TTL:   time.Hour,
+	})
+
+	ctx := context.Background()
+	count, err := dm1.Warmup(ctx, source)
+	require.NoError(t, err)
+	require.Equal(t, 2501, count)
+
+	for i := 0; i < 2500; i++ {
+		entry, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), entry.Value())
+
+		// The batches are replicated to the backup owners.
+		entry, err = dm2.GetEntryFromBackup(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), entry.Value())
+	}
+
+	entry, err := dm2.Get(ctx, "volatile")
+	require.NoError(t, err)
+	require.NotZero(t, entry.TTL())
+
+	total := WarmedUpEntriesTotal.Read()
+	require.GreaterOrEqual(t, total, int64(2501))
+}
+
+func TestDMap_Warmup_Canceled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	source := &sliceWarmupSource{entries: []*WarmupEntry{{Key: "mykey", Value: "myvalue"}}}
+	_, err = dm.Warmup(ctx, source)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDMap_Warmup_NX(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	// Written after the source was read.
+	require.NoError(t, dm.Put(ctx, "mykey", "newer", nil))
+
+	source := &sliceWarmupSource{entries: []*WarmupEntry{
+		{Key: "mykey", Value: "older"},
+		{Key: "volatile", Value: "myvalue", TTL: time.Microsecond},
+	}}
+	count, err := dm.Warmup(ctx, source)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	entry, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("newer"), entry.Value())
+
+	// A TTL under a millisecond is rounded up, it doesn't mean no TTL.
+	<-time.After(10 * time.Millisecond)
+	_, err = dm.Get(ctx, "volatile")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
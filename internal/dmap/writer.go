@@ -0,0 +1,288 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/pkg/codec"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrWriterFailed is returned when the Writer of a DMap returns an error in
+// write-through mode.
+var ErrWriterFailed = errors.New("writer failed")
+
+var (
+	// WritesTotal is the number of the writes that are passed to the Writers.
+	WritesTotal = stats.NewInt64Counter()
+
+	// WriteErrorsTotal is the number of the Writer calls that returned an error.
+	WriteErrorsTotal = stats.NewInt64Counter()
+
+	// WriteBehindRetriesTotal is the number of the writes that are queued
+	// again after a failed write-behind flush.
+	WriteBehindRetriesTotal = stats.NewInt64Counter()
+
+	// WriteBehindQueueLength is the number of the writes that are waiting in
+	// the write-behind queues.
+	WriteBehindQueueLength = stats.NewInt64Gauge()
+)
+
+// writeBehindQueue keeps the pending writes of a DMap in order. The writes
+// of a key are coalesced, only the last one is kept.
+type writeBehindQueue struct {
+	mtx     sync.Mutex
+	keys    []string
+	pending map[string]config.WriteOp
+
+	// flushMtx serializes the flushes. A write that is taken by a flush may
+	// be overtaken by a later write of the same key otherwise.
+	flushMtx sync.Mutex
+}
+
+func newWriteBehindQueue() *writeBehindQueue {
+	return &writeBehindQueue{
+		pending: make(map[string]config.WriteOp),
+	}
+}
+
+func (q *writeBehindQueue) push(op config.WriteOp) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if _, ok := q.pending[op.Key]; !ok {
+		q.keys = append(q.keys, op.Key)
+		WriteBehindQueueLength.Increase(1)
+	}
+	q.pending[op.Key] = op
+}
+
+// take removes at most n writes from the head of the queue.
+func (q *writeBehindQueue) take(n int) []config.WriteOp {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if n > len(q.keys) {
+		n = len(q.keys)
+	}
+	ops := make([]config.WriteOp, 0, n)
+	for _, key := range q.keys[:n] {
+		ops = append(ops, q.pending[key])
+		delete(q.pending, key)
+	}
+	q.keys = q.keys[n:]
+	WriteBehindQueueLength.Decrease(int64(n))
+	return ops
+}
+
+// requeue puts the failed writes back to the head of the queue. A write is
+// dropped if the key is written again in the meantime.
+func (q *writeBehindQueue) requeue(ops []config.WriteOp) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	var keys []string
+	for _, op := range ops {
+		if _, ok := q.pending[op.Key]; ok {
+			continue
+		}
+		keys = append(keys, op.Key)
+		q.pending[op.Key] = op
+	}
+	q.keys = append(keys, q.keys...)
+	WriteBehindQueueLength.Increase(int64(len(keys)))
+	WriteBehindRetriesTotal.Increase(int64(len(keys)))
+}
+
+func (q *writeBehindQueue) length() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return len(q.keys)
+}
+
+func (dm *DMap) writerEnabled(mode config.WriteMode) bool {
+	return dm.config() != nil && dm.config().writer != nil && dm.config().writeMode == mode
+}
+
+// newWriteOp creates a WriteOp for a stored entry. The Writer gets the
+// decoded value.
+func (dm *DMap) newWriteOp(entry storage.Entry) (config.WriteOp, error) {
+	value := entry.Value()
+	if entry.Codec() != codec.None {
+		decoded, err := codec.Decode(entry.Codec(), value)
+		if err != nil {
+			return config.WriteOp{}, err
+		}
+		value = decoded
+	} else {
+		// The value of a stored entry may point to the storage engine's memory.
+		value = append([]byte(nil), value...)
+	}
+	value, err := dm.reverseValue(entry.Key(), value)
+	if err != nil {
+		return config.WriteOp{}, err
+	}
+	return config.WriteOp{
+		Key:   entry.Key(),
+		Value: value,
+	}, nil
+}
+
+// lockKeyForWriter serializes the writes of a key in write-through mode. The
+// Writer is called without holding the fragment lock, the key lock keeps the
+// writes of the key in the same order on the external store and the DMap.
+func (dm *DMap) lockKeyForWriter(key string) func() {
+	lkey := dm.name + key
+	dm.s.writerLocker.Lock(lkey)
+	return func() {
+		if err := dm.s.writerLocker.Unlock(lkey); err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the writer lock for key: %s on DMap: %s: %v", key, dm.name, err)
+		}
+	}
+}
+
+// writeThrough passes the write to the Writer if the DMap is in
+// write-through mode. It's called before the write is applied, without
+// holding the fragment lock, see lockKeyForWriter.
+func (dm *DMap) writeThrough(ctx context.Context, op config.WriteOp) error {
+	if !dm.writerEnabled(config.WriteThrough) {
+		return nil
+	}
+
+	WritesTotal.Increase(1)
+	if err := dm.config().writer(ctx, dm.name, []config.WriteOp{op}); err != nil {
+		WriteErrorsTotal.Increase(1)
+		return fmt.Errorf("%w: %v", ErrWriterFailed, err)
+	}
+	return nil
+}
+
+// writeBehind queues the write if the DMap is in write-behind mode. It's
+// called after the write is applied.
+func (dm *DMap) writeBehind(op config.WriteOp) {
+	if !dm.writerEnabled(config.WriteBehind) {
+		return
+	}
+	dm.s.writeBehindQueueOf(dm).push(op)
+}
+
+// writeBehindQueueOf returns the write-behind queue of a DMap. It creates the
+// queue and starts its worker if required.
+func (s *Service) writeBehindQueueOf(dm *DMap) *writeBehindQueue {
+	s.writeBehindMtx.Lock()
+	defer s.writeBehindMtx.Unlock()
+
+	q, ok := s.writeBehindQueues[dm.name]
+	if !ok {
+		q = newWriteBehindQueue()
+		s.writeBehindQueues[dm.name] = q
+		s.wg.Add(1)
+		go s.writeBehindWorker(dm, q)
+	}
+	return q
+}
+
+// flushWriteBehindQueue passes the pending writes to the Writer in batches.
+// The failed batches are queued again if retry is true.
+func (s *Service) flushWriteBehindQueue(ctx context.Context, dm *DMap, q *writeBehindQueue, retry bool) {
+	cfg := dm.config()
+	if cfg == nil || cfg.writer == nil {
+		// The Writer is removed by ReloadConfig.
+		return
+	}
+
+	q.flushMtx.Lock()
+	defer q.flushMtx.Unlock()
+	for {
+		ops := q.take(cfg.writeBehindBatchSize)
+		if len(ops) == 0 {
+			return
+		}
+
+		WritesTotal.Increase(int64(len(ops)))
+		err := cfg.writer(ctx, dm.name, ops)
+		if err == nil {
+			continue
+		}
+
+		WriteErrorsTotal.Increase(1)
+		if !retry {
+			s.log.V(3).Printf("[ERROR] Failed to write %d entries of DMap: %s: %v", len(ops), dm.name, err)
+			continue
+		}
+		s.log.V(3).Printf("[ERROR] Failed to write %d entries of DMap: %s, they will be retried: %v",
+			len(ops), dm.name, err)
+		q.requeue(ops)
+		return
+	}
+}
+
+// FlushWriteBehind passes the pending writes of all write-behind queues to
+// the Writers. It's called while draining the member before shutdown, the
+// failed writes are queued again and flushed on Shutdown for the last time.
+func (s *Service) FlushWriteBehind(ctx context.Context) error {
+	s.writeBehindMtx.Lock()
+	queues := make(map[string]*writeBehindQueue, len(s.writeBehindQueues))
+	for name, q := range s.writeBehindQueues {
+		queues[name] = q
+	}
+	s.writeBehindMtx.Unlock()
+
+	for name, q := range queues {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dm, err := s.getDMap(name)
+		if err != nil {
+			// Not registered, the worker flushes the queue on Shutdown.
+			continue
+		}
+		if q.length() > 0 {
+			s.log.V(2).Printf("[INFO] Flushing %d pending writes of DMap: %s", q.length(), name)
+			s.flushWriteBehindQueue(ctx, dm, q, true)
+		}
+	}
+	return nil
+}
+
+func (s *Service) writeBehindWorker(dm *DMap, q *writeBehindQueue) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(dm.config().writeBehindDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flushWriteBehindQueue(s.ctx, dm, q, true)
+		case <-s.ctx.Done():
+			// Drain the queue before leaving the cluster, the writes are lost
+			// otherwise.
+			if q.length() > 0 {
+				s.log.V(2).Printf("[INFO] Flushing %d pending writes of DMap: %s", q.length(), dm.name)
+				s.flushWriteBehindQueue(context.Background(), dm, q, false)
+			}
+			return
+		}
+	}
+}
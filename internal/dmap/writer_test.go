@@ -0,0 +1,284 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	mtx     sync.Mutex
+	entries map[string][]byte
+	calls   int
+	fail    func(calls int, ops []config.WriteOp) error
+}
+
+func newTestStore() *testStore {
+	return &testStore{entries: make(map[string][]byte)}
+}
+
+func (s *testStore) write(_ context.Context, _ string, ops []config.WriteOp) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.calls++
+	if s.fail != nil {
+		if err := s.fail(s.calls, ops); err != nil {
+			return err
+		}
+	}
+	for _, op := range ops {
+		if op.Delete {
+			delete(s.entries, op.Key)
+			continue
+		}
+		s.entries[op.Key] = op.Value
+	}
+	return nil
+}
+
+func (s *testStore) get(key string) ([]byte, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	value, ok := s.entries[key]
+	return value, ok
+}
+
+func (s *testStore) length() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.entries)
+}
+
+func TestDMap_Writer_WriteThrough(t *testing.T) {
+	store := newTestStore()
+	store.fail = func(_ int, ops []config.WriteOp) error {
+		if ops[0].Key == "failing" {
+			return errors.New("database is down")
+		}
+		return nil
+	}
+	dc := config.DMap{
+		Writer: store.write,
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s1 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	s2 := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	_, err = s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		value, ok := store.get(testutil.ToKey(i))
+		require.True(t, ok)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+
+	_, err = dm1.Delete(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+	_, ok := store.get(testutil.ToKey(0))
+	require.False(t, ok)
+
+	// The DMap is not modified if the Writer fails.
+	err = dm1.Put(ctx, "failing", []byte("value"), nil)
+	require.ErrorIs(t, err, ErrWriterFailed)
+	_, err = dm1.Get(ctx, "failing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Writer_WriteThrough_Without_Fragment_Lock(t *testing.T) {
+	type ctxKey struct{}
+
+	var dm *DMap
+	dc := config.DMap{
+		Writer: func(ctx context.Context, _ string, ops []config.WriteOp) error {
+			if ctx.Value(ctxKey{}) == nil {
+				return errors.New("the context of the caller is not passed")
+			}
+			// The fragment of the key is not locked while the Writer is called.
+			done := make(chan error, 1)
+			go func() {
+				_, err := dm.Get(context.Background(), ops[0].Key)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err != nil && !errors.Is(err, ErrKeyNotFound) {
+					return err
+				}
+				return nil
+			case <-time.After(time.Second):
+				return errors.New("the fragment is locked")
+			}
+		},
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	var err error
+	dm, err = s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, true)
+	err = dm.Put(ctx, "mykey", []byte("value"), nil)
+	require.NoError(t, err)
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+}
+
+func TestDMap_Writer_WriteBehind(t *testing.T) {
+	store := newTestStore()
+	// The first call fails, the writes are retried.
+	store.fail = func(calls int, _ []config.WriteOp) error {
+		if calls == 1 {
+			return errors.New("database is down")
+		}
+		return nil
+	}
+	dc := config.DMap{
+		Writer:               store.write,
+		WriteMode:            config.WriteBehind,
+		WriteBehindDelay:     10 * time.Millisecond,
+		WriteBehindBatchSize: 3,
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	retries := WriteBehindRetriesTotal.Read()
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	// Overwrite the first key, only the last write is propagated.
+	err = dm.Put(ctx, testutil.ToKey(0), []byte("overwritten"), nil)
+	require.NoError(t, err)
+	_, err = dm.Delete(ctx, testutil.ToKey(1))
+	require.NoError(t, err)
+
+	err = testutil.TryWithInterval(50, 20*time.Millisecond, func() error {
+		if store.length() != 9 {
+			return errors.New("the writes are not propagated yet")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	value, ok := store.get(testutil.ToKey(0))
+	require.True(t, ok)
+	require.Equal(t, []byte("overwritten"), value)
+	_, ok = store.get(testutil.ToKey(1))
+	require.False(t, ok)
+	require.Greater(t, WriteBehindRetriesTotal.Read(), retries)
+}
+
+func TestDMap_Writer_WriteBehind_Flush_On_Shutdown(t *testing.T) {
+	store := newTestStore()
+	dc := config.DMap{
+		Writer:           store.write,
+		WriteMode:        config.WriteBehind,
+		WriteBehindDelay: time.Hour,
+	}
+
+	cluster := testcluster.New(NewService)
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(context.Background(), testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 0, store.length())
+
+	cluster.Shutdown()
+	require.Equal(t, 10, store.length())
+}
+
+func TestDMap_Writer_FlushWriteBehind(t *testing.T) {
+	store := newTestStore()
+	dc := config.DMap{
+		Writer:           store.write,
+		WriteMode:        config.WriteBehind,
+		WriteBehindDelay: time.Hour,
+	}
+
+	cluster := testcluster.New(NewService)
+	defer cluster.Shutdown()
+
+	s := newTestServiceWithDMapConfig(cluster, "mydmap", dc)
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(context.Background(), testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 0, store.length())
+
+	require.NoError(t, s.FlushWriteBehind(context.Background()))
+	require.Equal(t, 10, store.length())
+}
+
+func TestDMap_writeBehindQueue(t *testing.T) {
+	q := newWriteBehindQueue()
+	q.push(config.WriteOp{Key: "a", Value: []byte("1")})
+	q.push(config.WriteOp{Key: "b", Value: []byte("1")})
+	q.push(config.WriteOp{Key: "a", Value: []byte("2")})
+	require.Equal(t, 2, q.length())
+
+	ops := q.take(10)
+	require.Equal(t, []config.WriteOp{
+		{Key: "a", Value: []byte("2")},
+		{Key: "b", Value: []byte("1")},
+	}, ops)
+	require.Equal(t, 0, q.length())
+
+	// "a" is written again before the failed batch is queued again.
+	q.push(config.WriteOp{Key: "a", Delete: true})
+	q.requeue(ops)
+	require.Equal(t, []config.WriteOp{
+		{Key: "b", Value: []byte("1")},
+		{Key: "a", Delete: true},
+	}, q.take(10))
+}
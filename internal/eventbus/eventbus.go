@@ -0,0 +1,142 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventbus implements an in-process event bus. It delivers the events
+// of the local partitions to the subscribers in the same process, without
+// encoding them or sending them over the network.
+package eventbus
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/events"
+)
+
+// DefaultBufferSize is the default number of the undelivered events that a
+// subscription keeps.
+const DefaultBufferSize = 1024
+
+// Subscription receives the events published on a Bus.
+type Subscription struct {
+	id      uint64
+	bus     *Bus
+	ch      chan events.Event
+	dropped int64
+	once    sync.Once
+}
+
+// Events returns the channel that the events are delivered to. It's closed
+// when the subscription or the bus is closed.
+func (s *Subscription) Events() <-chan events.Event {
+	return s.ch
+}
+
+// Dropped returns the number of the events that are dropped because the
+// buffer of the subscription was full.
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close cancels the subscription.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		close(s.ch)
+	})
+}
+
+// Bus delivers the published events to all of its subscribers. Publish never
+// blocks, the events are dropped for the subscribers that fall behind.
+type Bus struct {
+	mtx           sync.RWMutex
+	nextID        uint64
+	count         int32
+	subscriptions map[uint64]*Subscription
+}
+
+// New returns a new Bus.
+func New() *Bus {
+	return &Bus{
+		subscriptions: make(map[uint64]*Subscription),
+	}
+}
+
+// Subscribe creates a new subscription that keeps at most bufferSize
+// undelivered events. DefaultBufferSize is used if it's zero or negative.
+func (b *Bus) Subscribe(bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.nextID++
+	s := &Subscription{
+		id:  b.nextID,
+		bus: b,
+		ch:  make(chan events.Event, bufferSize),
+	}
+	b.subscriptions[s.id] = s
+	atomic.AddInt32(&b.count, 1)
+	return s
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if _, ok := b.subscriptions[s.id]; !ok {
+		return
+	}
+	delete(b.subscriptions, s.id)
+	atomic.AddInt32(&b.count, -1)
+	s.close()
+}
+
+// HasSubscribers returns true if there is at least one subscription. The
+// publishers call it to avoid creating the events that nobody receives.
+func (b *Bus) HasSubscribers() bool {
+	return atomic.LoadInt32(&b.count) > 0
+}
+
+// Publish delivers the event to all subscribers.
+func (b *Bus) Publish(e events.Event) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	for _, s := range b.subscriptions {
+		select {
+		case s.ch <- e:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// Close cancels all subscriptions.
+func (b *Bus) Close() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for id, s := range b.subscriptions {
+		delete(b.subscriptions, id)
+		s.close()
+	}
+	atomic.StoreInt32(&b.count, 0)
+}
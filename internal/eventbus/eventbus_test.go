@@ -0,0 +1,57 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	b := New()
+	require.False(t, b.HasSubscribers())
+
+	s1 := b.Subscribe(2)
+	s2 := b.Subscribe(0)
+	require.True(t, b.HasSubscribers())
+
+	for i := 0; i < 3; i++ {
+		b.Publish(&events.BackupPromotedEvent{PartitionID: uint64(i)})
+	}
+
+	// The buffer of the first subscription is full.
+	require.Equal(t, int64(1), s1.Dropped())
+	require.Equal(t, int64(0), s2.Dropped())
+	for i := 0; i < 2; i++ {
+		e := <-s1.Events()
+		require.Equal(t, uint64(i), e.(*events.BackupPromotedEvent).PartitionID)
+	}
+
+	s1.Close()
+	_, ok := <-s1.Events()
+	require.False(t, ok)
+	// Calling Close again is harmless.
+	s1.Close()
+
+	b.Close()
+	require.False(t, b.HasSubscribers())
+	for i := 0; i < 3; i++ {
+		<-s2.Events()
+	}
+	_, ok = <-s2.Events()
+	require.False(t, ok)
+}
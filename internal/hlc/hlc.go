@@ -0,0 +1,101 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hlc implements a hybrid logical clock. The timestamps are in
+// nanoseconds since the Unix epoch, so they can be compared with the wall
+// clock, but they never go backwards on a member and they are always ahead of
+// the timestamps that the member has seen from the others.
+package hlc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClockDrift is returned by Update if the received timestamp is too far
+// ahead of the wall clock.
+var ErrClockDrift = errors.New("clock drift exceeds the limit")
+
+// Clock is a hybrid logical clock. The logical part is folded into the low
+// bits of the physical time: if the wall clock doesn't advance past the last
+// timestamp, the clock ticks by one nanosecond instead. The zero value is
+// ready to use.
+type Clock struct {
+	mtx  sync.Mutex
+	last int64
+
+	// maxDrift is the maximum duration that a received timestamp can be
+	// ahead of the wall clock, zero means no limit.
+	maxDrift time.Duration
+
+	// wall returns the physical time, it is replaced in tests.
+	wall func() int64
+}
+
+// New returns a new Clock. Update rejects the timestamps that are more than
+// maxDrift ahead of the wall clock, zero means no limit.
+func New(maxDrift time.Duration) *Clock {
+	return &Clock{maxDrift: maxDrift}
+}
+
+func (c *Clock) physical() int64 {
+	if c.wall != nil {
+		return c.wall()
+	}
+	return time.Now().UnixNano()
+}
+
+// Now returns a new timestamp for a local event. It is greater than all the
+// timestamps that are returned by Now or passed to Update before.
+func (c *Clock) Now() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if wall := c.physical(); wall > c.last {
+		c.last = wall
+	} else {
+		c.last++
+	}
+	return c.last
+}
+
+// Update merges a timestamp received from another member into the clock.
+// The subsequent calls to Now return greater timestamps, even if the wall
+// clock of this member is behind. It returns ErrClockDrift without moving the
+// clock if the timestamp is more than the maximum drift ahead of the wall
+// clock.
+func (c *Clock) Update(ts int64) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.maxDrift > 0 {
+		if drift := time.Duration(ts - c.physical()); drift > c.maxDrift {
+			return fmt.Errorf("%w: %s ahead of the wall clock", ErrClockDrift, drift)
+		}
+	}
+	if ts > c.last {
+		c.last = ts
+	}
+	return nil
+}
+
+// Last returns the last timestamp of the clock without advancing it.
+func (c *Clock) Last() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.last
+}
@@ -0,0 +1,80 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock_Now(t *testing.T) {
+	c := New(0)
+	var last int64
+	for i := 0; i < 1000; i++ {
+		ts := c.Now()
+		require.Greater(t, ts, last)
+		last = ts
+	}
+
+	// The timestamps are close to the wall clock.
+	require.InDelta(t, time.Now().UnixNano(), c.Now(), float64(time.Second))
+}
+
+func TestClock_Wall_Backwards(t *testing.T) {
+	wall := int64(1000)
+	c := &Clock{wall: func() int64 { return wall }}
+
+	require.Equal(t, int64(1000), c.Now())
+	require.Equal(t, int64(1001), c.Now())
+
+	// NTP steps the clock back, the timestamps keep increasing.
+	wall = 500
+	require.Equal(t, int64(1002), c.Now())
+
+	wall = 2000
+	require.Equal(t, int64(2000), c.Now())
+}
+
+func TestClock_Update(t *testing.T) {
+	wall := int64(1000)
+	c := &Clock{wall: func() int64 { return wall }}
+	require.Equal(t, int64(1000), c.Now())
+
+	// The other member is ahead of us.
+	require.NoError(t, c.Update(5000))
+	require.Equal(t, int64(5000), c.Last())
+	require.Equal(t, int64(5001), c.Now())
+
+	// Timestamps from the past don't move the clock.
+	require.NoError(t, c.Update(10))
+	require.Equal(t, int64(5002), c.Now())
+}
+
+func TestClock_Update_MaxDrift(t *testing.T) {
+	wall := int64(1000)
+	c := &Clock{wall: func() int64 { return wall }, maxDrift: 100}
+	require.NoError(t, c.Update(1100))
+	require.Equal(t, int64(1100), c.Last())
+
+	// A member with a broken clock cannot push the clock into the future.
+	require.ErrorIs(t, c.Update(1201), ErrClockDrift)
+	require.Equal(t, int64(1100), c.Last())
+
+	wall = 2000
+	require.NoError(t, c.Update(2100))
+	require.Equal(t, int64(2100), c.Last())
+}
@@ -0,0 +1,165 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"sync/atomic"
+
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+const (
+	// MemoryBudgetKey is the configuration key of a *MemoryBudget that is
+	// shared by the KVStore instances.
+	MemoryBudgetKey = "memoryBudget"
+
+	// MaxInuseKey is the configuration key of the maximum amount of memory in
+	// bytes that the tables can allocate on a member.
+	MaxInuseKey = "maxInuse"
+
+	// ClusterMaxInuseKey is the configuration key of the maximum amount of
+	// memory in bytes that the tables can allocate in the cluster. It's
+	// divided evenly between the members.
+	ClusterMaxInuseKey = "clusterMaxInuse"
+)
+
+// highWatermark is the ratio of the limit that the budget is considered
+// under pressure. The recycled tables are released and the keys are evicted
+// proactively after that point.
+const highWatermark = 0.90
+
+// MemoryBudget limits the memory allocated by the tables of all KVStore
+// instances that share it. A KVStore returns storage.ErrOutOfMemory instead of
+// allocating a new table if the budget is exhausted. The methods are safe to
+// call on a nil MemoryBudget, it means no limit.
+type MemoryBudget struct {
+	limit     int64
+	allocated int64
+}
+
+// NewMemoryBudget returns a new MemoryBudget. Zero limit disables it.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+// SetLimit updates the limit of the budget. The tables that are already
+// allocated are kept.
+func (b *MemoryBudget) SetLimit(limit int64) {
+	if b == nil {
+		return
+	}
+	atomic.StoreInt64(&b.limit, limit)
+}
+
+// Limit returns the limit of the budget in bytes.
+func (b *MemoryBudget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.limit)
+}
+
+// Allocated returns the memory allocated by the tables in bytes.
+func (b *MemoryBudget) Allocated() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.allocated)
+}
+
+// UnderPressure returns true if the allocated memory is above the high
+// watermark of the limit.
+func (b *MemoryBudget) UnderPressure() bool {
+	limit := b.Limit()
+	if limit <= 0 {
+		return false
+	}
+	return float64(b.Allocated()) >= float64(limit)*highWatermark
+}
+
+// reserve charges size bytes to the budget, if the limit allows it.
+func (b *MemoryBudget) reserve(size uint64) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		allocated := atomic.LoadInt64(&b.allocated)
+		limit := atomic.LoadInt64(&b.limit)
+		if limit > 0 && allocated+int64(size) > limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.allocated, allocated, allocated+int64(size)) {
+			return true
+		}
+	}
+}
+
+// charge charges size bytes to the budget unconditionally.
+func (b *MemoryBudget) charge(size uint64) {
+	if b == nil {
+		return
+	}
+	atomic.AddInt64(&b.allocated, int64(size))
+}
+
+// release gives size bytes back to the budget.
+func (b *MemoryBudget) release(size uint64) {
+	if b == nil {
+		return
+	}
+	atomic.AddInt64(&b.allocated, -int64(size))
+}
+
+// MemoryLimits reads MaxInuseKey and ClusterMaxInuseKey from the
+// configuration. The missing keys are returned as zero.
+func MemoryLimits(c *storage.Config) (maxInuse, clusterMaxInuse uint64, err error) {
+	if raw, gerr := c.Get(MaxInuseKey); gerr == nil {
+		if maxInuse, err = prepareSize(MaxInuseKey, raw); err != nil {
+			return 0, 0, err
+		}
+	}
+	if raw, gerr := c.Get(ClusterMaxInuseKey); gerr == nil {
+		if clusterMaxInuse, err = prepareSize(ClusterMaxInuseKey, raw); err != nil {
+			return 0, 0, err
+		}
+	}
+	return maxInuse, clusterMaxInuse, nil
+}
+
+// NodeMemoryLimit returns the memory limit of a member. The cluster-wide limit
+// is divided evenly between the members, the smaller one of the limits is
+// used if both of them are set. Zero means no limit.
+func NodeMemoryLimit(maxInuse, clusterMaxInuse uint64, numMembers int) int64 {
+	limit := maxInuse
+	if clusterMaxInuse > 0 && numMembers > 0 {
+		share := clusterMaxInuse / uint64(numMembers)
+		if limit == 0 || share < limit {
+			limit = share
+		}
+	}
+	return int64(limit)
+}
+
+func loadMemoryBudget(c *storage.Config) *MemoryBudget {
+	if c == nil {
+		return nil
+	}
+	raw, err := c.Get(MemoryBudgetKey)
+	if err != nil {
+		return nil
+	}
+	b, _ := raw.(*MemoryBudget)
+	return b
+}
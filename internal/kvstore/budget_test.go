@@ -0,0 +1,121 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/kvstore/entry"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVStore_MemoryBudget(t *testing.T) {
+	budget := NewMemoryBudget(int64(2 * defaultTableSize))
+	c := DefaultConfig()
+	c.Add(MemoryBudgetKey, budget)
+
+	s1 := testKVStore(t, c)
+	s2 := testKVStore(t, c)
+	// Every fork allocates a table.
+	require.Equal(t, int64(2*defaultTableSize), budget.Allocated())
+	require.True(t, budget.UnderPressure())
+
+	var err error
+	for i := 0; i < 1500; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%01000d", i)))
+		err = s1.Put(xxhash.Sum64([]byte(e.Key())), e)
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorIs(t, err, storage.ErrOutOfMemory)
+	require.Equal(t, int64(2*defaultTableSize), budget.Allocated())
+
+	// Destroying an engine gives its tables back.
+	require.NoError(t, s2.Destroy())
+	require.Equal(t, int64(defaultTableSize), budget.Allocated())
+
+	e := entry.New()
+	e.SetKey("new-key")
+	e.SetValue([]byte(fmt.Sprintf("%01000d", 0)))
+	require.NoError(t, s1.Put(xxhash.Sum64([]byte(e.Key())), e))
+	require.Equal(t, int64(2*defaultTableSize), budget.Allocated())
+}
+
+func TestKVStore_MemoryBudget_ReleaseRecycledTables(t *testing.T) {
+	budget := NewMemoryBudget(0)
+	c := DefaultConfig()
+	c.Add(MemoryBudgetKey, budget)
+	s := testKVStore(t, c)
+
+	for i := 0; i < 1500; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%01000d", i)))
+		require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	for i := 0; i < 1500; i++ {
+		require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+	}
+	require.Equal(t, int64(2*defaultTableSize), budget.Allocated())
+
+	// The recycled tables are kept until maxIdleTableTimeout, unless the
+	// budget is under pressure.
+	budget.SetLimit(int64(2 * defaultTableSize))
+	for {
+		done, err := s.Compaction()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+	require.Equal(t, int64(defaultTableSize), budget.Allocated())
+	require.False(t, budget.UnderPressure())
+}
+
+func TestMemoryBudget_Nil(t *testing.T) {
+	var budget *MemoryBudget
+	require.True(t, budget.reserve(defaultTableSize))
+	require.False(t, budget.UnderPressure())
+	require.Equal(t, int64(0), budget.Limit())
+	require.Equal(t, int64(0), budget.Allocated())
+}
+
+func TestNodeMemoryLimit(t *testing.T) {
+	require.Equal(t, int64(0), NodeMemoryLimit(0, 0, 3))
+	require.Equal(t, int64(100), NodeMemoryLimit(100, 0, 3))
+	require.Equal(t, int64(100), NodeMemoryLimit(0, 300, 3))
+	require.Equal(t, int64(50), NodeMemoryLimit(50, 300, 3))
+	require.Equal(t, int64(100), NodeMemoryLimit(150, 300, 3))
+}
+
+func TestMemoryLimits(t *testing.T) {
+	c := DefaultConfig()
+	c.Add(MaxInuseKey, 100)
+	c.Add(ClusterMaxInuseKey, uint64(300))
+	maxInuse, clusterMaxInuse, err := MemoryLimits(c)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), maxInuse)
+	require.Equal(t, uint64(300), clusterMaxInuse)
+
+	c.Add(MaxInuseKey, "100")
+	_, _, err = MemoryLimits(c)
+	require.Error(t, err)
+}
@@ -23,7 +23,14 @@ import (
 	"github.com/buraksezer/olric/pkg/storage"
 )
 
-func (k *KVStore) evictTable(t *table.Table) error {
+// evictTable moves the entries of the table to the latest one. It returns the
+// number of moved entries.
+func (k *KVStore) evictTable(t *table.Table) (int, error) {
+	k.compacting = true
+	defer func() {
+		k.compacting = false
+	}()
+
 	var total int
 	var evictErr error
 	t.Range(func(hkey uint64, e storage.Entry) bool {
@@ -63,7 +70,7 @@ func (k *KVStore) evictTable(t *table.Table) error {
 		t.Reset()
 	}
 
-	return evictErr
+	return total, evictErr
 }
 
 func (k *KVStore) isTableExpired(recycledAt int64) bool {
@@ -78,35 +85,106 @@ func (k *KVStore) isTableExpired(recycledAt int64) bool {
 
 func (k *KVStore) isCompactionOK(t *table.Table) bool {
 	s := t.Stats()
-	return float64(s.Garbage) >= float64(s.Allocated)*maxGarbageRatio
+	return float64(s.Garbage) >= float64(s.Allocated)*k.garbageRatio
 }
 
-func (k *KVStore) Compaction() (bool, error) {
-	for _, t := range k.tables {
-		if k.isCompactionOK(t) {
-			err := k.evictTable(t)
-			if err != nil {
-				return false, err
-			}
-			// Continue scanning
-			return false, nil
-		}
-	}
-
+// releaseRecycledTables frees the recycled tables that are idle for
+// maxIdleTableTimeout. All of them are freed if force is true.
+func (k *KVStore) releaseRecycledTables(force bool) {
 	for i := 0; i < len(k.tables); i++ {
 		t := k.tables[i]
 		s := t.Stats()
 		if t.State() == table.RecycledState {
-			if k.isTableExpired(s.RecycledAt) {
+			// Release the recycled tables immediately if the memory budget
+			// is under pressure, the other engines may need the space.
+			if force || k.isTableExpired(s.RecycledAt) || k.budget.UnderPressure() {
 				if len(k.tables) == 1 {
 					break
 				}
 				delete(k.tablesByCoefficient, t.Coefficient())
 				k.tables = append(k.tables[:i], k.tables[i+1:]...)
 				i--
+				k.budget.release(s.Allocated)
+				k.notify(storage.Event{Kind: storage.TableReleased, Size: s.Allocated})
+			}
+		}
+	}
+}
+
+func (k *KVStore) Compaction() (bool, error) {
+	for _, t := range k.tables {
+		if k.isCompactionOK(t) {
+			start := time.Now()
+			moved, err := k.evictTable(t)
+			CompactionRunsTotal.Increase(1)
+			CompactedEntriesTotal.Increase(int64(moved))
+			k.notify(storage.Event{
+				Kind:     storage.CompactionRun,
+				Duration: time.Since(start),
+				Entries:  moved,
+			})
+			if err != nil {
+				return false, err
 			}
+			// Continue scanning
+			return false, nil
 		}
 	}
 
+	k.releaseRecycledTables(false)
 	return true, nil
 }
+
+// Compact moves the live entries out of every table that has garbage and
+// frees the recycled tables, regardless of the garbage ratio and
+// maxIdleTableTimeout. It returns the number of moved entries.
+func (k *KVStore) Compact() (int, error) {
+	if len(k.tables) == 0 {
+		return 0, nil
+	}
+
+	head := k.tables[len(k.tables)-1]
+	if head.Stats().Garbage > 0 {
+		// The entries of the head table are moved to a new one.
+		k.compacting = true
+		err := k.makeTable()
+		k.compacting = false
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var total int
+	tables := append([]*table.Table(nil), k.tables[:len(k.tables)-1]...)
+	for _, t := range tables {
+		if t.State() == table.RecycledState || t.Stats().Garbage == 0 {
+			continue
+		}
+
+		start := time.Now()
+		var moved int
+		var err error
+		for t.Stats().Inuse > 0 {
+			var n int
+			n, err = k.evictTable(t)
+			moved += n
+			if err != nil {
+				break
+			}
+		}
+		total += moved
+		CompactionRunsTotal.Increase(1)
+		CompactedEntriesTotal.Increase(int64(moved))
+		k.notify(storage.Event{
+			Kind:     storage.CompactionRun,
+			Duration: time.Since(start),
+			Entries:  moved,
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+
+	k.releaseRecycledTables(true)
+	return total, nil
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/buraksezer/olric/internal/kvstore/entry"
 	"github.com/buraksezer/olric/internal/kvstore/table"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/cespare/xxhash/v2"
 	"github.com/stretchr/testify/require"
 )
@@ -121,3 +122,131 @@ func TestKVStore_Compaction_MaxIdleTableDuration(t *testing.T) {
 
 	require.Equal(t, 1, len(s.(*KVStore).tables))
 }
+
+func TestKVStore_Compaction_Observer(t *testing.T) {
+	var events []storage.Event
+	c := DefaultConfig()
+	c.Add(storage.ObserverKey, storage.Observer(func(e storage.Event) {
+		events = append(events, e)
+	}))
+	s := testKVStore(t, c)
+
+	timestamp := time.Now().UnixNano()
+	for i := 0; i < 1500; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%01000d", i)))
+		e.SetTTL(timestamp)
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		err := s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 750; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		err := s.Delete(hkey)
+		require.NoError(t, err)
+	}
+
+	for {
+		done, err := s.Compaction()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+
+	kinds := make(map[storage.EventKind]int)
+	var moved int
+	for _, e := range events {
+		kinds[e.Kind]++
+		if e.Kind == storage.CompactionRun {
+			moved += e.Entries
+		}
+	}
+	require.NotZero(t, kinds[storage.TableAllocated])
+	require.NotZero(t, kinds[storage.CompactionRun])
+	require.NotZero(t, moved)
+}
+
+func TestKVStore_Compaction_GarbageRatio(t *testing.T) {
+	c := DefaultConfig()
+	c.Add(GarbageRatioKey, 0.9)
+	s := testKVStore(t, c)
+
+	timestamp := time.Now().UnixNano()
+	for i := 0; i < 1500; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%01000d", i)))
+		e.SetTTL(timestamp)
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		err := s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 750; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		err := s.Delete(hkey)
+		require.NoError(t, err)
+	}
+
+	// The garbage ratio of the tables is below the threshold.
+	done, err := s.Compaction()
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Equal(t, 2, len(s.(*KVStore).tables))
+}
+
+func TestKVStore_Compact(t *testing.T) {
+	s := testKVStore(t, nil)
+
+	timestamp := time.Now().UnixNano()
+	for i := 0; i < 1500; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%01000d", i)))
+		e.SetTTL(timestamp)
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		err := s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 1500; i += 2 {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		err := s.Delete(hkey)
+		require.NoError(t, err)
+	}
+
+	moved, err := s.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 750, moved)
+
+	stats := s.Stats()
+	require.Equal(t, 0, stats.Garbage)
+	require.Equal(t, 750, stats.Length)
+	for _, tb := range s.(*KVStore).tables {
+		require.NotEqual(t, table.RecycledState, tb.State())
+	}
+
+	for i := 1; i < 1500; i += 2 {
+		_, err := s.Get(xxhash.Sum64([]byte(bkey(i))))
+		require.NoError(t, err)
+	}
+
+	// Nothing to compact.
+	moved, err = s.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 0, moved)
+}
+
+func TestGarbageRatio(t *testing.T) {
+	ratio, err := GarbageRatio(DefaultConfig())
+	require.NoError(t, err)
+	require.Equal(t, defaultGarbageRatio, ratio)
+
+	c := DefaultConfig()
+	c.Add(GarbageRatioKey, 0)
+	_, err = GarbageRatio(c)
+	require.Error(t, err)
+}
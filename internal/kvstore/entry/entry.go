@@ -22,7 +22,7 @@ import (
 
 // In-memory layout for an entry:
 //
-// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | | Timestamp(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
+// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | | Timestamp(uint64) | LastAccess(uint64) | CODEC(uint8) | SLIDING(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 
 // Entry represents a value with its metadata.
 type Entry struct {
@@ -30,6 +30,8 @@ type Entry struct {
 	ttl        int64
 	timestamp  int64
 	lastAccess int64
+	codec      uint8
+	sliding    int64
 	value      []byte
 }
 
@@ -79,12 +81,28 @@ func (e *Entry) LastAccess() int64 {
 	return e.lastAccess
 }
 
+func (e *Entry) SetCodec(id uint8) {
+	e.codec = id
+}
+
+func (e *Entry) Codec() uint8 {
+	return e.codec
+}
+
+func (e *Entry) SetSliding(sliding int64) {
+	e.sliding = sliding
+}
+
+func (e *Entry) Sliding() int64 {
+	return e.sliding
+}
+
 func (e *Entry) Encode() []byte {
 	var offset int
 
 	klen := uint8(len(e.Key()))
 	vlen := len(e.Value())
-	length := 29 + len(e.Key()) + vlen
+	length := 38 + len(e.Key()) + vlen
 
 	buf := make([]byte, length)
 
@@ -108,6 +126,14 @@ func (e *Entry) Encode() []byte {
 	binary.BigEndian.PutUint64(buf[offset:], uint64(e.LastAccess()))
 	offset += 8
 
+	// Set the codec ID. It's 1 byte.
+	buf[offset] = e.Codec()
+	offset++
+
+	// Set the sliding expiration. It's 8 bytes.
+	binary.BigEndian.PutUint64(buf[offset:], uint64(e.Sliding()))
+	offset += 8
+
 	// Set the value length. It's 4 bytes.
 	binary.BigEndian.PutUint32(buf[offset:], uint32(len(e.Value())))
 	offset += 4
@@ -135,6 +161,12 @@ func (e *Entry) Decode(buf []byte) {
 	e.lastAccess = int64(binary.BigEndian.Uint64(buf[offset : offset+8]))
 	offset += 8
 
+	e.codec = buf[offset]
+	offset++
+
+	e.sliding = int64(binary.BigEndian.Uint64(buf[offset : offset+8]))
+	offset += 8
+
 	vlen := binary.BigEndian.Uint32(buf[offset : offset+4])
 	offset += 4
 	e.value = buf[offset : offset+int(vlen)]
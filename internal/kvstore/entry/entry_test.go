@@ -27,6 +27,8 @@ func TestEntryEncodeDecode(t *testing.T) {
 	e.SetTTL(200)
 	e.SetTimestamp(time.Now().UnixNano())
 	e.SetLastAccess(time.Now().UnixNano())
+	e.SetCodec(1)
+	e.SetSliding(1000)
 	e.SetValue([]byte("mydata"))
 
 	t.Run("Encode", func(t *testing.T) {
@@ -29,11 +29,27 @@ import (
 
 	"github.com/buraksezer/olric/internal/kvstore/entry"
 	"github.com/buraksezer/olric/internal/kvstore/table"
+	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/storage"
 )
 
+var (
+	// TablesAllocatedTotal is the number of tables allocated by the storage engine.
+	TablesAllocatedTotal = stats.NewInt64Counter()
+
+	// CompactionRunsTotal is the number of compacted tables.
+	CompactionRunsTotal = stats.NewInt64Counter()
+
+	// CompactedEntriesTotal is the number of entries moved by the compaction runs.
+	CompactedEntriesTotal = stats.NewInt64Counter()
+)
+
 const (
-	maxGarbageRatio = 0.40
+	// GarbageRatioKey is the configuration key of the garbage ratio of a
+	// table that triggers its compaction. It must be between 0 and 1.
+	GarbageRatioKey = "garbageRatio"
+
+	defaultGarbageRatio = 0.40
 	// 1MB
 	defaultTableSize = uint64(1 << 20)
 
@@ -47,6 +63,10 @@ type KVStore struct {
 	tablesByCoefficient map[uint64]*table.Table
 	tables              []*table.Table
 	config              *storage.Config
+	observer            storage.Observer
+	budget              *MemoryBudget
+	garbageRatio        float64
+	compacting          bool
 }
 
 func DefaultConfig() *storage.Config {
@@ -66,7 +86,12 @@ func New(c *storage.Config) (*KVStore, error) {
 		return nil, err
 	}
 
-	size, err := prepareTableSize(raw)
+	size, err := prepareSize("tableSize", raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio, err := GarbageRatio(c)
 	if err != nil {
 		return nil, err
 	}
@@ -75,14 +100,78 @@ func New(c *storage.Config) (*KVStore, error) {
 		tableSize:           size,
 		tablesByCoefficient: make(map[uint64]*table.Table),
 		config:              c,
+		observer:            storage.LoadObserver(c),
+		budget:              loadMemoryBudget(c),
+		garbageRatio:        ratio,
 	}, nil
 }
 
 func (k *KVStore) SetConfig(c *storage.Config) {
 	k.config = c
+	k.observer = storage.LoadObserver(c)
+	k.budget = loadMemoryBudget(c)
+	if ratio, err := GarbageRatio(c); err == nil {
+		k.garbageRatio = ratio
+	}
+}
+
+// GarbageRatio reads GarbageRatioKey from the configuration. It returns the
+// default ratio, 0.40, if the key is missing.
+func GarbageRatio(c *storage.Config) (float64, error) {
+	raw, err := c.Get(GarbageRatioKey)
+	if err != nil {
+		return defaultGarbageRatio, nil
+	}
+
+	var ratio float64
+	switch rawType := raw.(type) {
+	case float32:
+		ratio = float64(rawType)
+	case float64:
+		ratio = rawType
+	case int:
+		ratio = float64(rawType)
+	default:
+		return 0, fmt.Errorf("invalid type for %s: %s", GarbageRatioKey, reflect.TypeOf(rawType))
+	}
+	if ratio <= 0 || ratio > 1 {
+		return 0, fmt.Errorf("%s must be between 0 and 1: %v", GarbageRatioKey, ratio)
+	}
+	return ratio, nil
+}
+
+// notify reports the event to the observer, if there is any.
+func (k *KVStore) notify(e storage.Event) {
+	if k.observer == nil {
+		return
+	}
+	e.Time = time.Now()
+	k.observer(e)
+}
+
+// recycledTable returns the index of the first recycled table, or -1.
+func (k *KVStore) recycledTable() int {
+	for i, t := range k.tables {
+		if t.State() == table.RecycledState {
+			return i
+		}
+	}
+	return -1
 }
 
 func (k *KVStore) makeTable() error {
+	if k.recycledTable() == -1 {
+		if k.compacting {
+			// The compaction may exceed the budget temporarily, the
+			// compacted tables are given back after that.
+			k.budget.charge(k.tableSize)
+		} else if !k.budget.reserve(k.tableSize) {
+			// Don't touch the head table, the caller may try again after
+			// freeing some memory.
+			return storage.ErrOutOfMemory
+		}
+	}
+
 	if len(k.tables) != 0 {
 		head := k.tables[len(k.tables)-1]
 		head.SetState(table.ReadOnlyState)
@@ -98,6 +187,7 @@ func (k *KVStore) makeTable() error {
 				k.coefficient++
 
 				t.SetState(table.ReadWriteState)
+				k.notify(storage.Event{Kind: storage.TableReused, Size: t.Stats().Allocated})
 				return nil
 			}
 		}
@@ -108,6 +198,8 @@ func (k *KVStore) makeTable() error {
 	newTable.SetCoefficient(k.coefficient)
 	k.tablesByCoefficient[k.coefficient] = newTable
 	k.coefficient++
+	TablesAllocatedTotal.Increase(1)
+	k.notify(storage.Event{Kind: storage.TableAllocated, Size: k.tableSize})
 	return nil
 }
 
@@ -124,7 +216,7 @@ func requiredSizeForAnEntry(e storage.Entry) uint64 {
 	return uint64(len(e.Key()) + len(e.Value()) + table.MetadataLength)
 }
 
-func prepareTableSize(raw interface{}) (size uint64, err error) {
+func prepareSize(name string, raw interface{}) (size uint64, err error) {
 	switch rawType := raw.(type) {
 	case uint:
 		size = uint64(rawType)
@@ -147,7 +239,7 @@ func prepareTableSize(raw interface{}) (size uint64, err error) {
 	case int64:
 		size = uint64(rawType)
 	default:
-		err = fmt.Errorf("invalid type for tableSize: %s", reflect.TypeOf(rawType))
+		err = fmt.Errorf("invalid type for %s: %s", name, reflect.TypeOf(rawType))
 		return
 	}
 	return
@@ -164,6 +256,9 @@ func (k *KVStore) Fork(c *storage.Config) (storage.Engine, error) {
 		return nil, err
 	}
 	t := table.New(k.tableSize)
+	// Every fragment needs a table, it's charged even if the budget is
+	// exhausted.
+	child.budget.charge(k.tableSize)
 	child.tables = append(child.tables, t)
 	t.SetCoefficient(child.coefficient)
 	child.tablesByCoefficient[child.coefficient] = t
@@ -405,6 +500,8 @@ func (k *KVStore) Stats() storage.Stats {
 		stats.Inuse += int(s.Inuse)
 		stats.Garbage += int(s.Garbage)
 		stats.Length += s.Length
+		stats.KeyBytes += int(s.KeyBytes)
+		stats.ValueBytes += int(s.ValueBytes)
 	}
 	return stats
 }
@@ -527,6 +624,11 @@ func (k *KVStore) Close() error {
 }
 
 func (k *KVStore) Destroy() error {
+	for _, t := range k.tables {
+		k.budget.release(t.Stats().Allocated)
+	}
+	k.tables = nil
+	k.tablesByCoefficient = make(map[uint64]*table.Table)
 	return nil
 }
 
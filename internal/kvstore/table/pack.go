@@ -75,6 +75,9 @@ func Decode(data []byte) (*Table, error) {
 	t.offsetIndex = rb
 
 	copy(t.memory[:t.offset], p.Memory)
+	for _, offset := range t.hkeys {
+		t.countBytes(offset)
+	}
 
 	return t, nil
 }
@@ -63,5 +63,5 @@ func TestTable_Pack_EncodeDecode(t *testing.T) {
 		require.Equal(t, e.TTL(), int64(i))
 		require.NotEqual(t, timestamp, e.LastAccess())
 	}
-
+	require.Equal(t, tb.Stats(), newTable.Stats())
 }
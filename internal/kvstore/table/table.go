@@ -29,7 +29,7 @@ import (
 
 const (
 	MaxKeyLength   = 256
-	MetadataLength = 29
+	MetadataLength = 38
 )
 
 type State uint8
@@ -51,6 +51,11 @@ type Stats struct {
 	Garbage    uint64
 	Length     int
 	RecycledAt int64
+
+	// KeyBytes and ValueBytes are the sizes of the keys and the values of
+	// the live entries. The rest of Inuse is the metadata of the entries.
+	KeyBytes   uint64
+	ValueBytes uint64
 }
 
 type Table struct {
@@ -60,6 +65,8 @@ type Table struct {
 	allocated     uint64
 	inuse         uint64
 	garbage       uint64
+	keyBytes      uint64
+	valueBytes    uint64
 	recycledAt    int64
 	state         State
 	hkeys         map[uint64]uint64
@@ -112,13 +119,29 @@ func (t *Table) PutRaw(hkey uint64, value []byte) error {
 	t.offsetIndex.Add(t.offset)
 	copy(t.memory[t.offset:], value)
 	t.inuse += inuse
+	t.countBytes(t.offset)
 	t.offset += inuse
 	return nil
 }
 
+// entrySizes returns the key and the value lengths of the entry at offset.
+func (t *Table) entrySizes(offset uint64) (klen, vlen uint64) {
+	klen = uint64(t.memory[offset])
+	// KEY-LENGTH + KEY + TTL + TIMESTAMP + LASTACCESS + CODEC + SLIDING
+	voffset := offset + 1 + klen + 8 + 8 + 8 + 1 + 8
+	vlen = uint64(binary.BigEndian.Uint32(t.memory[voffset : voffset+4]))
+	return klen, vlen
+}
+
+func (t *Table) countBytes(offset uint64) {
+	klen, vlen := t.entrySizes(offset)
+	t.keyBytes += klen
+	t.valueBytes += vlen
+}
+
 // In-memory layout for entry:
 //
-// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VALUE-LENGTH(uint64) | VALUE(bytes)
+// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | CODEC(uint8) | SLIDING(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	if len(value.Key()) >= MaxKeyLength {
 		return storage.ErrKeyTooLarge
@@ -126,7 +149,7 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 
 	// Check empty space on allocated memory area.
 
-	// TTL + Timestamp + LastAccess + + value-Length + key-Length
+	// TTL + Timestamp + LastAccess + Codec + Sliding + value-Length + key-Length
 	inuse := uint64(len(value.Key()) + len(value.Value()) + MetadataLength)
 	if inuse+t.offset >= t.allocated {
 		return ErrNotEnoughSpace
@@ -144,6 +167,8 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	t.hkeys[hkey] = t.offset
 	t.offsetIndex.Add(t.offset)
 	t.inuse += inuse
+	t.keyBytes += uint64(len(value.Key()))
+	t.valueBytes += uint64(len(value.Value()))
 
 	// Set key length. It's 1 byte.
 	klen := uint8(len(value.Key()))
@@ -166,6 +191,14 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	binary.BigEndian.PutUint64(t.memory[t.offset:], uint64(time.Now().UnixNano()))
 	t.offset += 8
 
+	// Set the codec ID. It's 1 byte.
+	t.memory[t.offset] = value.Codec()
+	t.offset++
+
+	// Set the sliding expiration. It's 8 bytes.
+	binary.BigEndian.PutUint64(t.memory[t.offset:], uint64(value.Sliding()))
+	t.offset += 8
+
 	// Set the value length. It's 4 bytes.
 	binary.BigEndian.PutUint32(t.memory[t.offset:], uint32(len(value.Value())))
 	t.offset += 4
@@ -184,14 +217,16 @@ func (t *Table) GetRaw(hkey uint64) ([]byte, error) {
 	start, end := offset, offset
 
 	// In-memory structure:
-	// 1                 | klen       | 8           | 8                  | 8                  | 4                    | vlen
-	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64)  | LASTACCESS(uint64) | VALUE-LENGTH(uint64) | VALUE(bytes)
+	// 1                 | klen       | 8           | 8                  | 8                  | 1            | 8               | 4                    | vlen
+	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64)  | LASTACCESS(uint64) | CODEC(uint8) | SLIDING(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 	klen := uint64(t.memory[end])
 	end++       // One byte to keep key length
 	end += klen // key length
 	end += 8    // TTL
 	end += 8    // Timestamp
 	end += 8    // LastAccess
+	end++       // Codec
+	end += 8    // Sliding
 
 	vlen := binary.BigEndian.Uint32(t.memory[end : end+4])
 	end += 4            // 4 bytes to keep value length
@@ -258,7 +293,7 @@ func (t *Table) get(offset uint64) storage.Entry {
 	e := &entry.Entry{}
 	// In-memory structure:
 	//
-	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
+	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | CODEC(uint8) | SLIDING(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 	klen := uint64(t.memory[offset])
 	offset++
 
@@ -283,6 +318,12 @@ func (t *Table) get(offset uint64) storage.Entry {
 	t.lastAccessMtx.Unlock()
 	offset += 8
 
+	e.SetCodec(t.memory[offset])
+	offset++
+
+	e.SetSliding(int64(binary.BigEndian.Uint64(t.memory[offset : offset+8])))
+	offset += 8
+
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
 	offset += 4
 	e.SetValue(t.memory[offset : offset+uint64(vlen)])
@@ -298,7 +339,7 @@ func (t *Table) Get(hkey uint64) (storage.Entry, error) {
 	e := &entry.Entry{}
 	// In-memory structure:
 	//
-	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
+	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | CODEC(uint8) | SLIDING(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 	klen := uint64(t.memory[offset])
 	offset++
 
@@ -324,6 +365,12 @@ func (t *Table) Get(hkey uint64) (storage.Entry, error) {
 
 	offset += 8
 
+	e.SetCodec(t.memory[offset])
+	offset++
+
+	e.SetSliding(int64(binary.BigEndian.Uint64(t.memory[offset : offset+8])))
+	offset += 8
+
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
 	offset += 4
 	e.SetValue(t.memory[offset : offset+uint64(vlen)])
@@ -360,6 +407,14 @@ func (t *Table) Delete(hkey uint64) error {
 	offset += 8
 	garbage += 8
 
+	// Codec, skip it.
+	offset++
+	garbage++
+
+	// Sliding, skip it.
+	offset += 8
+	garbage += 8
+
 	// value len and its header.
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
 	garbage += 4 + uint64(vlen)
@@ -369,6 +424,8 @@ func (t *Table) Delete(hkey uint64) error {
 
 	t.garbage += garbage
 	t.inuse -= garbage
+	t.keyBytes -= klen
+	t.valueBytes -= uint64(vlen)
 	return nil
 }
 
@@ -409,6 +466,8 @@ func (t *Table) Stats() Stats {
 		Garbage:    t.garbage,
 		Length:     len(t.hkeys),
 		RecycledAt: t.recycledAt,
+		KeyBytes:   t.keyBytes,
+		ValueBytes: t.valueBytes,
 	}
 }
 
@@ -440,6 +499,8 @@ func (t *Table) Reset() {
 	t.SetState(RecycledState)
 	t.inuse = 0
 	t.garbage = 0
+	t.keyBytes = 0
+	t.valueBytes = 0
 	t.offset = 0
 	t.coefficient = 0
 	t.recycledAt = time.Now().UnixNano()
@@ -451,17 +512,21 @@ func (t *Table) Scan(cursor uint64, count int, f func(e storage.Entry) bool) (ui
 		it.AdvanceIfNeeded(cursor)
 	}
 	var num int
+	// The scan is not completed if f stops it, the rejected entry is
+	// returned by the next call.
+	var stopped bool
 	for it.HasNext() && num < count {
 		offset := it.Next()
 		e := t.get(offset)
 		if !f(e) {
+			stopped = true
 			break
 		}
 		cursor = offset + 1
 		num++
 	}
 
-	if !it.HasNext() {
+	if !stopped && !it.HasNext() {
 		// end of the scan
 		cursor = 0
 	}
@@ -481,6 +546,9 @@ func (t *Table) ScanRegexMatch(cursor uint64, expr string, count int, f func(e s
 	}
 
 	var num int
+	// The scan is not completed if f stops it, the rejected entry is
+	// returned by the next call.
+	var stopped bool
 	for it.HasNext() && num < count {
 		offset := it.Next()
 
@@ -491,13 +559,14 @@ func (t *Table) ScanRegexMatch(cursor uint64, expr string, count int, f func(e s
 
 		e := t.get(offset)
 		if !f(e) {
+			stopped = true
 			break
 		}
 		cursor = offset + 1
 		num++
 	}
 
-	if !it.HasNext() {
+	if !stopped && !it.HasNext() {
 		// end of the scan
 		cursor = 0
 	}
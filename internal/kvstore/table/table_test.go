@@ -96,6 +96,30 @@ func TestTable_PutRaw(t *testing.T) {
 	require.Equal(t, e, value)
 }
 
+func TestTable_Stats_KeyAndValueBytes(t *testing.T) {
+	tb, e := setupTable()
+	require.NoError(t, tb.Put(hkey, e))
+	require.NoError(t, tb.PutRaw(hkey+1, e.Encode()))
+
+	s := tb.Stats()
+	require.Equal(t, uint64(2*len(key)), s.KeyBytes)
+	require.Equal(t, uint64(2*len("foobar-value")), s.ValueBytes)
+	require.Equal(t, s.KeyBytes+s.ValueBytes+2*MetadataLength, s.Inuse)
+
+	// Overwrite
+	e.SetValue([]byte("value"))
+	require.NoError(t, tb.Put(hkey, e))
+	s = tb.Stats()
+	require.Equal(t, uint64(len("foobar-value")+len("value")), s.ValueBytes)
+
+	require.NoError(t, tb.Delete(hkey))
+	require.NoError(t, tb.Delete(hkey+1))
+	s = tb.Stats()
+	require.Zero(t, s.KeyBytes)
+	require.Zero(t, s.ValueBytes)
+	require.Zero(t, s.Inuse)
+}
+
 func TestTable_GetRaw(t *testing.T) {
 	tb, e := setupTable()
 
@@ -258,7 +282,7 @@ func TestTable_Stats(t *testing.T) {
 	s := tb.Stats()
 	require.Equal(t, uint64(1<<20), s.Allocated)
 	require.Equal(t, 100, s.Length)
-	require.Equal(t, uint64(4280), s.Inuse)
+	require.Equal(t, uint64(5180), s.Inuse)
 	require.Equal(t, uint64(0), s.Garbage)
 
 	for i := 0; i < 100; i++ {
@@ -272,7 +296,7 @@ func TestTable_Stats(t *testing.T) {
 	require.Equal(t, uint64(1<<20), s.Allocated)
 	require.Equal(t, 0, s.Length)
 	require.Equal(t, uint64(0), s.Inuse)
-	require.Equal(t, uint64(4280), s.Garbage)
+	require.Equal(t, uint64(5180), s.Garbage)
 }
 
 func TestTable_Reset(t *testing.T) {
@@ -326,6 +350,39 @@ func TestTable_Scan(t *testing.T) {
 	}
 }
 
+func TestTable_Scan_Stopped(t *testing.T) {
+	tb := New(1 << 20)
+	for i := 0; i < 10; i++ {
+		e := entry.New()
+		key := fmt.Sprintf("key-%d", i)
+		hkey := xxhash.Sum64String(key)
+		e.SetKey(key)
+		e.SetValue([]byte(fmt.Sprintf("value-%d", i)))
+		require.NoError(t, tb.Put(hkey, e))
+	}
+
+	// Accept one entry in every call, the scan visits all of them.
+	keys := make(map[string]struct{})
+	var err error
+	var cursor uint64
+	for {
+		var accepted bool
+		cursor, err = tb.Scan(cursor, 10, func(e storage.Entry) bool {
+			if accepted {
+				return false
+			}
+			accepted = true
+			keys[e.Key()] = struct{}{}
+			return true
+		})
+		require.NoError(t, err)
+		if cursor == 0 {
+			break
+		}
+	}
+	require.Len(t, keys, 10)
+}
+
 func TestTable_ScanRegexMatch(t *testing.T) {
 	tb := New(1 << 20)
 	for i := 0; i < 100; i++ {
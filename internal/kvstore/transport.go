@@ -38,6 +38,7 @@ func (t *transferIterator) Drop(index int) error {
 	tb := t.storage.tables[index]
 	t.storage.tables = append(t.storage.tables[:index], t.storage.tables[index+1:]...)
 	delete(t.storage.tablesByCoefficient, tb.Coefficient())
+	t.storage.budget.release(tb.Stats().Allocated)
 
 	return nil
 }
@@ -16,7 +16,9 @@ package protocol
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/buraksezer/olric/internal/util"
 	"github.com/go-redis/redis/v8"
 	"github.com/tidwall/redcon"
 )
@@ -62,3 +64,188 @@ func ParseClusterMembers(cmd redcon.Command) (*ClusterMembers, error) {
 	c := NewClusterMembers()
 	return c, nil
 }
+
+type PauseRebalancing struct{}
+
+func NewPauseRebalancing() *PauseRebalancing {
+	return &PauseRebalancing{}
+}
+
+func (p *PauseRebalancing) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Cluster.PauseRebalancing)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParsePauseRebalancing(cmd redcon.Command) (*PauseRebalancing, error) {
+	if len(cmd.Args) > 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewPauseRebalancing(), nil
+}
+
+type ResumeRebalancing struct{}
+
+func NewResumeRebalancing() *ResumeRebalancing {
+	return &ResumeRebalancing{}
+}
+
+func (r *ResumeRebalancing) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Cluster.ResumeRebalancing)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseResumeRebalancing(cmd redcon.Command) (*ResumeRebalancing, error) {
+	if len(cmd.Args) > 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewResumeRebalancing(), nil
+}
+
+type MovePartition struct {
+	PartID uint64
+	Member string
+}
+
+func NewMovePartition(partID uint64, member string) *MovePartition {
+	return &MovePartition{
+		PartID: partID,
+		Member: member,
+	}
+}
+
+func (m *MovePartition) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Cluster.MovePartition)
+	args = append(args, m.PartID)
+	args = append(args, m.Member)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseMovePartition(cmd redcon.Command) (*MovePartition, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	partID, err := strconv.ParseUint(util.BytesToString(cmd.Args[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return NewMovePartition(partID, util.BytesToString(cmd.Args[2])), nil
+}
+
+type Drain struct {
+	Member string
+}
+
+func NewDrain(member string) *Drain {
+	return &Drain{
+		Member: member,
+	}
+}
+
+func (d *Drain) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Cluster.Drain)
+	args = append(args, d.Member)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseDrain(cmd redcon.Command) (*Drain, error) {
+	if len(cmd.Args) != 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewDrain(util.BytesToString(cmd.Args[1])), nil
+}
+
+type Rebalance struct{}
+
+func NewRebalance() *Rebalance {
+	return &Rebalance{}
+}
+
+func (r *Rebalance) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Cluster.Rebalance)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseRebalance(cmd redcon.Command) (*Rebalance, error) {
+	if len(cmd.Args) > 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewRebalance(), nil
+}
+
+type RebalanceStatus struct{}
+
+func NewRebalanceStatus() *RebalanceStatus {
+	return &RebalanceStatus{}
+}
+
+func (r *RebalanceStatus) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, Cluster.RebalanceStatus)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseRebalanceStatus(cmd redcon.Command) (*RebalanceStatus, error) {
+	if len(cmd.Args) > 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewRebalanceStatus(), nil
+}
+
+type OwnershipHistory struct {
+	PartIDs []uint64
+}
+
+func NewOwnershipHistory(partIDs ...uint64) *OwnershipHistory {
+	return &OwnershipHistory{
+		PartIDs: partIDs,
+	}
+}
+
+func (o *OwnershipHistory) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, Cluster.OwnershipHistory)
+	for _, partID := range o.PartIDs {
+		args = append(args, partID)
+	}
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseOwnershipHistory(cmd redcon.Command) (*OwnershipHistory, error) {
+	if len(cmd.Args) < 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	o := NewOwnershipHistory()
+	for _, arg := range cmd.Args[1:] {
+		partID, err := strconv.ParseUint(util.BytesToString(arg), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		o.PartIDs = append(o.PartIDs, partID)
+	}
+	return o, nil
+}
+
+type Compact struct{}
+
+func NewCompact() *Compact {
+	return &Compact{}
+}
+
+func (c *Compact) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Cluster.Compact)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseCompact(cmd redcon.Command) (*Compact, error) {
+	if len(cmd.Args) > 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewCompact(), nil
+}
@@ -48,3 +48,119 @@ func TestProtocol_ClusterMembers(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestProtocol_PauseRebalancing(t *testing.T) {
+	pauseCmd := NewPauseRebalancing()
+
+	cmd := stringToCommand(pauseCmd.Command(context.Background()).String())
+	_, err := ParsePauseRebalancing(cmd)
+	require.NoError(t, err)
+
+	t.Run("CLUSTER.PAUSEREBALANCING invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.pauserebalancing foobar")
+		_, err = ParsePauseRebalancing(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Rebalance(t *testing.T) {
+	rebalanceCmd := NewRebalance()
+
+	cmd := stringToCommand(rebalanceCmd.Command(context.Background()).String())
+	_, err := ParseRebalance(cmd)
+	require.NoError(t, err)
+
+	t.Run("CLUSTER.REBALANCE invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.rebalance foobar")
+		_, err = ParseRebalance(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_ResumeRebalancing(t *testing.T) {
+	resumeCmd := NewResumeRebalancing()
+
+	cmd := stringToCommand(resumeCmd.Command(context.Background()).String())
+	_, err := ParseResumeRebalancing(cmd)
+	require.NoError(t, err)
+
+	t.Run("CLUSTER.RESUMEREBALANCING invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.resumerebalancing foobar")
+		_, err = ParseResumeRebalancing(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_MovePartition(t *testing.T) {
+	moveCmd := NewMovePartition(12, "127.0.0.1:3320")
+
+	cmd := stringToCommand(moveCmd.Command(context.Background()).String())
+	parsed, err := ParseMovePartition(cmd)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), parsed.PartID)
+	require.Equal(t, "127.0.0.1:3320", parsed.Member)
+
+	t.Run("CLUSTER.MOVEPARTITION invalid partition id", func(t *testing.T) {
+		cmd := stringToCommand("cluster.movepartition foobar 127.0.0.1:3320")
+		_, err = ParseMovePartition(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Drain(t *testing.T) {
+	drainCmd := NewDrain("127.0.0.1:3320")
+
+	cmd := stringToCommand(drainCmd.Command(context.Background()).String())
+	parsed, err := ParseDrain(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:3320", parsed.Member)
+
+	t.Run("CLUSTER.DRAIN invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.drain")
+		_, err = ParseDrain(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_RebalanceStatus(t *testing.T) {
+	statusCmd := NewRebalanceStatus()
+
+	cmd := stringToCommand(statusCmd.Command(context.Background()).String())
+	_, err := ParseRebalanceStatus(cmd)
+	require.NoError(t, err)
+
+	t.Run("CLUSTER.REBALANCESTATUS invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.rebalancestatus foobar")
+		_, err = ParseRebalanceStatus(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_OwnershipHistory(t *testing.T) {
+	historyCmd := NewOwnershipHistory(1, 42)
+
+	cmd := stringToCommand(historyCmd.Command(context.Background()).String())
+	parsed, err := ParseOwnershipHistory(cmd)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 42}, parsed.PartIDs)
+
+	t.Run("CLUSTER.OWNERSHIPHISTORY invalid partition id", func(t *testing.T) {
+		cmd := stringToCommand("cluster.ownershiphistory foobar")
+		_, err = ParseOwnershipHistory(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Compact(t *testing.T) {
+	compactCmd := NewCompact()
+
+	cmd := stringToCommand(compactCmd.Command(context.Background()).String())
+	_, err := ParseCompact(cmd)
+	require.NoError(t, err)
+
+	t.Run("CLUSTER.COMPACT invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.compact foobar")
+		_, err = ParseCompact(cmd)
+		require.Error(t, err)
+	})
+}
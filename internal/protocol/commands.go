@@ -17,73 +17,156 @@ package protocol
 const StatusOK = "OK"
 
 type ClusterCommands struct {
-	RoutingTable string
-	Members      string
+	RoutingTable      string
+	Members           string
+	PauseRebalancing  string
+	ResumeRebalancing string
+	MovePartition     string
+	Drain             string
+	RebalanceStatus   string
+	OwnershipHistory  string
+	Compact           string
+	Rebalance         string
 }
 
 var Cluster = &ClusterCommands{
-	RoutingTable: "cluster.routingtable",
-	Members:      "cluster.members",
+	RoutingTable:      "cluster.routingtable",
+	Members:           "cluster.members",
+	PauseRebalancing:  "cluster.pauserebalancing",
+	ResumeRebalancing: "cluster.resumerebalancing",
+	MovePartition:     "cluster.movepartition",
+	Drain:             "cluster.drain",
+	RebalanceStatus:   "cluster.rebalancestatus",
+	OwnershipHistory:  "cluster.ownershiphistory",
+	Compact:           "cluster.compact",
+	Rebalance:         "cluster.rebalance",
 }
 
 type InternalCommands struct {
 	MoveFragment        string
 	UpdateRouting       string
 	LengthOfPart        string
+	RecreateBackups     string
+	MoveQueue           string
+	ReplicateQueue      string
+	MoveSet             string
+	ReplicateSet        string
 	ClusterRoutingTable string
+	RoutingSignature    string
+	Member              string
 }
 
 var Internal = &InternalCommands{
-	MoveFragment:  "internal.node.movefragment",
-	UpdateRouting: "internal.node.updaterouting",
-	LengthOfPart:  "internal.node.lengthofpart",
+	MoveFragment:     "internal.node.movefragment",
+	UpdateRouting:    "internal.node.updaterouting",
+	LengthOfPart:     "internal.node.lengthofpart",
+	RecreateBackups:  "internal.node.recreatebackups",
+	MoveQueue:        "internal.node.movequeue",
+	ReplicateQueue:   "internal.node.replicatequeue",
+	MoveSet:          "internal.node.moveset",
+	ReplicateSet:     "internal.node.replicateset",
+	RoutingSignature: "internal.node.routingsignature",
+	Member:           "internal.node.member",
 }
 
 type GenericCommands struct {
-	Ping  string
-	Stats string
+	Ping     string
+	Stats    string
+	SlowLog  string
+	Deadline string
 }
 
 var Generic = &GenericCommands{
-	Ping:  "ping",
-	Stats: "stats",
+	Ping:     "ping",
+	Stats:    "stats",
+	SlowLog:  "slowlog",
+	Deadline: "deadline",
 }
 
 type DMapCommands struct {
-	Get        string
-	GetEntry   string
-	Put        string
-	PutEntry   string
-	Del        string
-	DelEntry   string
-	Expire     string
-	PExpire    string
-	Destroy    string
-	Query      string
-	Lock       string
-	Unlock     string
-	LockLease  string
-	PLockLease string
-	Scan       string
-	Function   string
+	Get         string
+	GetEntry    string
+	Put         string
+	PutEntry    string
+	Del         string
+	DelEntry    string
+	DelByTag    string
+	Expire      string
+	PExpire     string
+	Destroy     string
+	Undo        string
+	Query       string
+	QueryPage   string
+	Access      string
+	HotKeys     string
+	ListDMaps   string
+	Lock        string
+	Unlock      string
+	LockLease   string
+	PLockLease  string
+	LockInfo    string
+	ForceUnlock string
+	Scan        string
+	Function    string
+	IncrMany    string
+	HSet        string
+	HDel        string
+	HIncrBy     string
+	Execute     string
+	Changes     string
+	Checksum    string
+	Digest      string
+	DelFrom     string
+	Tombstone   string
+	Tx          string
+	Replicate   string
+	Migrate     string
+	Warmup      string
+	Export      string
+	Import      string
 }
 
 var DMap = &DMapCommands{
-	Get:        "dm.get",
-	GetEntry:   "dm.getentry",
-	Put:        "dm.put",
-	PutEntry:   "dm.putentry",
-	Del:        "dm.del",
-	DelEntry:   "dm.delentry",
-	Expire:     "dm.expire",
-	PExpire:    "dm.pexpire",
-	Destroy:    "dm.destroy",
-	Lock:       "dm.lock",
-	Unlock:     "dm.unlock",
-	LockLease:  "dm.locklease",
-	PLockLease: "dm.plocklease",
-	Scan:       "dm.scan",
-	Function:   "dm.function",
+	Get:         "dm.get",
+	GetEntry:    "dm.getentry",
+	Put:         "dm.put",
+	PutEntry:    "dm.putentry",
+	Del:         "dm.del",
+	DelEntry:    "dm.delentry",
+	DelByTag:    "dm.delbytag",
+	Expire:      "dm.expire",
+	PExpire:     "dm.pexpire",
+	Destroy:     "dm.destroy",
+	Undo:        "dm.undo",
+	Query:       "dm.query",
+	QueryPage:   "dm.querypage",
+	Access:      "dm.access",
+	HotKeys:     "dm.hotkeys",
+	ListDMaps:   "dm.listdmaps",
+	Lock:        "dm.lock",
+	Unlock:      "dm.unlock",
+	LockLease:   "dm.locklease",
+	PLockLease:  "dm.plocklease",
+	LockInfo:    "dm.lockinfo",
+	ForceUnlock: "dm.forceunlock",
+	Scan:        "dm.scan",
+	Function:    "dm.function",
+	IncrMany:    "dm.incrmany",
+	HSet:        "dm.hset",
+	HDel:        "dm.hdel",
+	HIncrBy:     "dm.hincrby",
+	Execute:     "dm.execute",
+	Changes:     "dm.changes",
+	Checksum:    "dm.checksum",
+	Digest:      "dm.digest",
+	DelFrom:     "dm.delfrom",
+	Tombstone:   "dm.tombstone",
+	Tx:          "dm.tx",
+	Replicate:   "dm.replicate",
+	Migrate:     "dm.migrate",
+	Warmup:      "dm.warmup",
+	Export:      "dm.export",
+	Import:      "dm.import",
 }
 
 type PubSubCommands struct {
@@ -105,3 +188,76 @@ var PubSub = &PubSubCommands{
 	PubSubNumpat:    "pubsub numpat",
 	PubSubNumsub:    "pubsub numsub",
 }
+
+type QueueCommands struct {
+	Push string
+	Pop  string
+	BPop string
+	Ack  string
+	Len  string
+}
+
+var Queue = &QueueCommands{
+	Push: "queue.push",
+	Pop:  "queue.pop",
+	BPop: "queue.bpop",
+	Ack:  "queue.ack",
+	Len:  "queue.len",
+}
+
+type SetCommands struct {
+	SAdd      string
+	SRem      string
+	SIsMember string
+	SMembers  string
+	SCard     string
+}
+
+var Set = &SetCommands{
+	SAdd:      "set.sadd",
+	SRem:      "set.srem",
+	SIsMember: "set.sismember",
+	SMembers:  "set.smembers",
+	SCard:     "set.scard",
+}
+
+type SortedSetCommands struct {
+	ZAdd          string
+	ZRangeByScore string
+	ZRank         string
+}
+
+var SortedSet = &SortedSetCommands{
+	ZAdd:          "zset.zadd",
+	ZRangeByScore: "zset.zrangebyscore",
+	ZRank:         "zset.zrank",
+}
+
+// memberCommands are sent only by the cluster members to each other, the
+// clients never send them.
+var memberCommands = map[string]struct{}{
+	Cluster.RoutingTable:      {},
+	Internal.MoveFragment:     {},
+	Internal.UpdateRouting:    {},
+	Internal.LengthOfPart:     {},
+	Internal.RecreateBackups:  {},
+	Internal.MoveQueue:        {},
+	Internal.ReplicateQueue:   {},
+	Internal.MoveSet:          {},
+	Internal.ReplicateSet:     {},
+	Internal.RoutingSignature: {},
+	Internal.Member:           {},
+	DMap.GetEntry:             {},
+	DMap.PutEntry:             {},
+	DMap.DelEntry:             {},
+	DMap.Tombstone:            {},
+	DMap.Replicate:            {},
+	PubSub.PublishInternal:    {},
+}
+
+// IsMemberCommand returns true if the command is sent only by the cluster
+// members to each other. The name has to be lowercase.
+func IsMemberCommand(name string) bool {
+	_, ok := memberCommands[name]
+	return ok
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -37,6 +38,29 @@ type Put struct {
 	PXAT  int64
 	NX    bool
 	XX    bool
+	Tags  []string
+
+	// Timestamp is the version of the entry in nanoseconds. Zero means the
+	// time of the write.
+	Timestamp int64
+
+	// Clock is the hybrid logical clock of the sender. The receiver merges it
+	// into its own clock before stamping the entry.
+	Clock int64
+
+	// Jitter is the maximum percentage of the TTL that is randomly cut.
+	Jitter float64
+
+	// Sliding is the TTL in milliseconds that is reset on every read.
+	Sliding int64
+
+	Publish        bool
+	PublishChannel string
+	PublishMessage string
+
+	// Chunked marks the value as the manifest of a value that is split into
+	// chunks. It's set by the DMaps with a ChunkSize.
+	Chunked bool
 }
 
 func NewPut(dmap, key string, value []byte) *Put {
@@ -77,6 +101,46 @@ func (p *Put) SetXX() *Put {
 	return p
 }
 
+func (p *Put) SetTags(tags ...string) *Put {
+	p.Tags = tags
+	return p
+}
+
+func (p *Put) SetTimestamp(ts int64) *Put {
+	p.Timestamp = ts
+	return p
+}
+
+func (p *Put) SetClock(clock int64) *Put {
+	p.Clock = clock
+	return p
+}
+
+func (p *Put) SetJitter(percent float64) *Put {
+	p.Jitter = percent
+	return p
+}
+
+func (p *Put) SetSliding(sliding int64) *Put {
+	p.Sliding = sliding
+	return p
+}
+
+// SetPublish makes the partition owner publish the message to the channel
+// after storing the value.
+func (p *Put) SetPublish(channel, message string) *Put {
+	p.Publish = true
+	p.PublishChannel = channel
+	p.PublishMessage = message
+	return p
+}
+
+// SetChunked marks the value as the manifest of a chunked value.
+func (p *Put) SetChunked() *Put {
+	p.Chunked = true
+	return p
+}
+
 func (p *Put) Command(ctx context.Context) *redis.StatusCmd {
 	var args []interface{}
 	args = append(args, DMap.Put)
@@ -112,6 +176,41 @@ func (p *Put) Command(ctx context.Context) *redis.StatusCmd {
 		args = append(args, "XX")
 	}
 
+	for _, tag := range p.Tags {
+		args = append(args, "TAG")
+		args = append(args, tag)
+	}
+
+	if p.Timestamp != 0 {
+		args = append(args, "TS")
+		args = append(args, p.Timestamp)
+	}
+
+	if p.Clock != 0 {
+		args = append(args, "CLOCK")
+		args = append(args, p.Clock)
+	}
+
+	if p.Jitter != 0 {
+		args = append(args, "JITTER")
+		args = append(args, p.Jitter)
+	}
+
+	if p.Sliding != 0 {
+		args = append(args, "SLIDING")
+		args = append(args, p.Sliding)
+	}
+
+	if p.Publish {
+		args = append(args, "PUBLISH")
+		args = append(args, p.PublishChannel)
+		args = append(args, p.PublishMessage)
+	}
+
+	if p.Chunked {
+		args = append(args, "CHUNKED")
+	}
+
 	return redis.NewStatusCmd(ctx, args...)
 }
 
@@ -169,6 +268,68 @@ func ParsePutCommand(cmd redcon.Command) (*Put, error) {
 			p.SetPXAT(pxat)
 			args = args[2:]
 			continue
+		case "TAG":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			p.Tags = append(p.Tags, util.BytesToString(args[1]))
+			args = args[2:]
+			continue
+		case "TS":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			ts, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetTimestamp(ts)
+			args = args[2:]
+			continue
+		case "CLOCK":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			clock, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetClock(clock)
+			args = args[2:]
+			continue
+		case "JITTER":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			jitter, err := strconv.ParseFloat(util.BytesToString(args[1]), 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetJitter(jitter)
+			args = args[2:]
+			continue
+		case "SLIDING":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			sliding, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetSliding(sliding)
+			args = args[2:]
+			continue
+		case "PUBLISH":
+			if len(args) < 3 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			p.SetPublish(util.BytesToString(args[1]), util.BytesToString(args[2]))
+			args = args[3:]
+			continue
+		case "CHUNKED":
+			p.SetChunked()
+			args = args[1:]
+			continue
 		default:
 			return nil, errors.New("syntax error")
 		}
@@ -178,9 +339,15 @@ func ParsePutCommand(cmd redcon.Command) (*Put, error) {
 }
 
 type PutEntry struct {
-	DMap  string
-	Key   string
-	Value []byte
+	DMap     string
+	Key      string
+	Value    []byte
+	Epoch    uint64
+	Tags     []string
+	KeepTags bool
+	// Timestamp is the timestamp of the encoded entry, the receiver merges it
+	// into its clock without decoding the entry.
+	Timestamp int64
 }
 
 func NewPutEntry(dmap, key string, value []byte) *PutEntry {
@@ -191,12 +358,50 @@ func NewPutEntry(dmap, key string, value []byte) *PutEntry {
 	}
 }
 
+func (p *PutEntry) SetEpoch(epoch uint64) *PutEntry {
+	p.Epoch = epoch
+	return p
+}
+
+// SetTags sets the tags of the entry. An entry without tags clears the
+// previous tags of the key.
+func (p *PutEntry) SetTags(tags ...string) *PutEntry {
+	p.Tags = tags
+	return p
+}
+
+// SetKeepTags keeps the current tags of the key, e.g. for the TTL updates.
+func (p *PutEntry) SetKeepTags() *PutEntry {
+	p.KeepTags = true
+	return p
+}
+
+func (p *PutEntry) SetTimestamp(timestamp int64) *PutEntry {
+	p.Timestamp = timestamp
+	return p
+}
+
 func (p *PutEntry) Command(ctx context.Context) *redis.StatusCmd {
 	var args []interface{}
 	args = append(args, DMap.PutEntry)
 	args = append(args, p.DMap)
 	args = append(args, p.Key)
 	args = append(args, p.Value)
+	if p.Epoch != 0 {
+		args = append(args, "EP")
+		args = append(args, p.Epoch)
+	}
+	for _, tag := range p.Tags {
+		args = append(args, "TAG")
+		args = append(args, tag)
+	}
+	if p.KeepTags {
+		args = append(args, "KT")
+	}
+	if p.Timestamp != 0 {
+		args = append(args, "TS")
+		args = append(args, p.Timestamp)
+	}
 	return redis.NewStatusCmd(ctx, args...)
 }
 
@@ -205,17 +410,58 @@ func ParsePutEntryCommand(cmd redcon.Command) (*PutEntry, error) {
 		return nil, errWrongNumber(cmd.Args)
 	}
 
-	return NewPutEntry(
+	p := NewPutEntry(
 		util.BytesToString(cmd.Args[1]),
 		util.BytesToString(cmd.Args[2]),
 		cmd.Args[3],
-	), nil
+	)
+
+	args := cmd.Args[4:]
+	for len(args) > 0 {
+		arg := util.BytesToString(args[0])
+		switch arg {
+		case "EP":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			epoch, err := strconv.ParseUint(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetEpoch(epoch)
+			args = args[2:]
+		case "TAG":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			p.Tags = append(p.Tags, util.BytesToString(args[1]))
+			args = args[2:]
+		case "KT":
+			p.SetKeepTags()
+			args = args[1:]
+		case "TS":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			timestamp, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetTimestamp(timestamp)
+			args = args[2:]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return p, nil
 }
 
 type Get struct {
-	DMap string
-	Key  string
-	Raw  bool
+	DMap         string
+	Key          string
+	Raw          bool
+	Linearizable bool
 }
 
 func NewGet(dmap, key string) *Get {
@@ -230,6 +476,11 @@ func (g *Get) SetRaw() *Get {
 	return g
 }
 
+func (g *Get) SetLinearizable() *Get {
+	g.Linearizable = true
+	return g
+}
+
 func (g *Get) Command(ctx context.Context) *redis.StringCmd {
 	var args []interface{}
 	args = append(args, DMap.Get)
@@ -238,6 +489,9 @@ func (g *Get) Command(ctx context.Context) *redis.StringCmd {
 	if g.Raw {
 		args = append(args, "RW")
 	}
+	if g.Linearizable {
+		args = append(args, "LN")
+	}
 	return redis.NewStringCmd(ctx, args...)
 }
 
@@ -251,11 +505,14 @@ func ParseGetCommand(cmd redcon.Command) (*Get, error) {
 		util.BytesToString(cmd.Args[2]),
 	)
 
-	if len(cmd.Args) == 4 {
-		arg := util.BytesToString(cmd.Args[3])
-		if arg == "RW" {
+	for _, tmp := range cmd.Args[3:] {
+		arg := util.BytesToString(tmp)
+		switch arg {
+		case "RW":
 			g.SetRaw()
-		} else {
+		case "LN":
+			g.SetLinearizable()
+		default:
 			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
 		}
 	}
@@ -350,46 +607,89 @@ func ParseDelCommand(cmd redcon.Command) (*Del, error) {
 	return d, nil
 }
 
-type DelEntry struct {
-	Del     *Del
-	Replica bool
+// DelFrom is a Del that is forwarded to the partition owner by another
+// member. Client is the address of the client that issued the Del.
+type DelFrom struct {
+	Del    *Del
+	Client string
 }
 
-func NewDelEntry(dmap, key string) *DelEntry {
-	return &DelEntry{
-		Del: NewDel(dmap, key),
+func NewDelFrom(dmap, client string, keys ...string) *DelFrom {
+	return &DelFrom{
+		Del:    NewDel(dmap, keys...),
+		Client: client,
 	}
 }
 
-func (d *DelEntry) SetReplica() *DelEntry {
-	d.Replica = true
+func (d *DelFrom) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.DelFrom)
+	args = append(args, d.Del.DMap)
+	args = append(args, d.Client)
+	for _, key := range d.Del.Keys {
+		args = append(args, key)
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseDelFromCommand(cmd redcon.Command) (*DelFrom, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	d := NewDelFrom(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Client
+	)
+	for _, key := range cmd.Args[3:] {
+		d.Del.Keys = append(d.Del.Keys, util.BytesToString(key))
+	}
+	return d, nil
+}
+
+type DelByTag struct {
+	DMap  string
+	Tag   string
+	Local bool
+}
+
+func NewDelByTag(dmap, tag string) *DelByTag {
+	return &DelByTag{
+		DMap: dmap,
+		Tag:  tag,
+	}
+}
+
+func (d *DelByTag) SetLocal() *DelByTag {
+	d.Local = true
 	return d
 }
 
-func (d *DelEntry) Command(ctx context.Context) *redis.IntCmd {
-	cmd := d.Del.Command(ctx)
-	args := cmd.Args()
-	args[0] = DMap.DelEntry
-	if d.Replica {
-		args = append(args, "RC")
+func (d *DelByTag) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.DelByTag)
+	args = append(args, d.DMap)
+	args = append(args, d.Tag)
+	if d.Local {
+		args = append(args, "LC")
 	}
 	return redis.NewIntCmd(ctx, args...)
 }
 
-func ParseDelEntryCommand(cmd redcon.Command) (*DelEntry, error) {
+func ParseDelByTagCommand(cmd redcon.Command) (*DelByTag, error) {
 	if len(cmd.Args) < 3 {
 		return nil, errWrongNumber(cmd.Args)
 	}
 
-	d := NewDelEntry(
-		util.BytesToString(cmd.Args[1]),
-		util.BytesToString(cmd.Args[2]),
+	d := NewDelByTag(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Tag
 	)
 
 	if len(cmd.Args) == 4 {
 		arg := util.BytesToString(cmd.Args[3])
-		if arg == "RC" {
-			d.SetReplica()
+		if arg == "LC" {
+			d.SetLocal()
 		} else {
 			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
 		}
@@ -398,91 +698,519 @@ func ParseDelEntryCommand(cmd redcon.Command) (*DelEntry, error) {
 	return d, nil
 }
 
-type PExpire struct {
-	DMap         string
-	Key          string
-	Milliseconds time.Duration
+type Query struct {
+	DMap   string
+	Filter string
+	Local  bool
 }
 
-func NewPExpire(dmap, key string, milliseconds time.Duration) *PExpire {
-	return &PExpire{
-		DMap:         dmap,
-		Key:          key,
-		Milliseconds: milliseconds,
+func NewQuery(dmap, filter string) *Query {
+	return &Query{
+		DMap:   dmap,
+		Filter: filter,
 	}
 }
 
-func (p *PExpire) Command(ctx context.Context) *redis.StatusCmd {
+func (q *Query) SetLocal() *Query {
+	q.Local = true
+	return q
+}
+
+func (q *Query) Command(ctx context.Context) *redis.StringSliceCmd {
 	var args []interface{}
-	args = append(args, DMap.PExpire)
-	args = append(args, p.DMap)
-	args = append(args, p.Key)
-	args = append(args, p.Milliseconds.Milliseconds())
-	return redis.NewStatusCmd(ctx, args...)
+	args = append(args, DMap.Query)
+	args = append(args, q.DMap)
+	args = append(args, q.Filter)
+	if q.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewStringSliceCmd(ctx, args...)
 }
 
-func ParsePExpireCommand(cmd redcon.Command) (*PExpire, error) {
-	if len(cmd.Args) < 4 {
+func ParseQueryCommand(cmd redcon.Command) (*Query, error) {
+	if len(cmd.Args) < 3 {
 		return nil, errWrongNumber(cmd.Args)
 	}
 
-	rawMilliseconds := util.BytesToString(cmd.Args[3])
-	milliseconds, err := strconv.ParseInt(rawMilliseconds, 10, 64)
-	if err != nil {
-		return nil, err
-	}
-	p := NewPExpire(
+	q := NewQuery(
 		util.BytesToString(cmd.Args[1]), // DMap
-		util.BytesToString(cmd.Args[2]), // Key
-		time.Duration(milliseconds*int64(time.Millisecond)),
+		util.BytesToString(cmd.Args[2]), // Filter
 	)
-	return p, nil
+
+	if len(cmd.Args) == 4 {
+		arg := util.BytesToString(cmd.Args[3])
+		if arg == "LC" {
+			q.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return q, nil
 }
 
-type Expire struct {
-	DMap    string
-	Key     string
-	Seconds time.Duration
+// QueryPage returns a page of the result of a query. Cursor is the partition
+// ID that the page starts from, the reply contains the cursor of the next page,
+// zero for the last page.
+type QueryPage struct {
+	DMap   string
+	Filter string
+	Cursor uint64
+	Local  bool
 }
 
-func NewExpire(dmap, key string, seconds time.Duration) *Expire {
-	return &Expire{
-		DMap:    dmap,
-		Key:     key,
-		Seconds: seconds,
+func NewQueryPage(dmap, filter string, cursor uint64) *QueryPage {
+	return &QueryPage{
+		DMap:   dmap,
+		Filter: filter,
+		Cursor: cursor,
 	}
 }
 
-func (e *Expire) Command(ctx context.Context) *redis.StatusCmd {
+func (q *QueryPage) SetLocal() *QueryPage {
+	q.Local = true
+	return q
+}
+
+func (q *QueryPage) Command(ctx context.Context) *redis.ScanCmd {
 	var args []interface{}
-	args = append(args, DMap.Expire)
-	args = append(args, e.DMap)
-	args = append(args, e.Key)
-	args = append(args, e.Seconds.Seconds())
-	return redis.NewStatusCmd(ctx, args...)
+	args = append(args, DMap.QueryPage)
+	args = append(args, q.DMap)
+	args = append(args, q.Filter)
+	args = append(args, q.Cursor)
+	if q.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewScanCmd(ctx, nil, args...)
 }
 
-func ParseExpireCommand(cmd redcon.Command) (*Expire, error) {
+func ParseQueryPageCommand(cmd redcon.Command) (*QueryPage, error) {
 	if len(cmd.Args) < 4 {
 		return nil, errWrongNumber(cmd.Args)
 	}
 
-	rawSeconds := util.BytesToString(cmd.Args[3])
-	seconds, err := strconv.ParseFloat(rawSeconds, 64)
+	cursor, err := strconv.ParseUint(util.BytesToString(cmd.Args[3]), 10, 64)
 	if err != nil {
 		return nil, err
 	}
-	e := NewExpire(
+
+	q := NewQueryPage(
 		util.BytesToString(cmd.Args[1]), // DMap
-		util.BytesToString(cmd.Args[2]), // Key
-		time.Duration(seconds*float64(time.Second)),
+		util.BytesToString(cmd.Args[2]), // Filter
+		cursor,
 	)
-	return e, nil
-}
 
-type Destroy struct {
-	DMap  string
-	Local bool
+	if len(cmd.Args) == 5 {
+		arg := util.BytesToString(cmd.Args[4])
+		if arg == "LC" {
+			q.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return q, nil
+}
+
+type Export struct {
+	DMap   string
+	PartID uint64
+	Cursor uint64
+	// Signature is the signature of the routing table that the export is
+	// started with.
+	Signature uint64
+}
+
+func NewExport(dmap string, partID, cursor, signature uint64) *Export {
+	return &Export{
+		DMap:      dmap,
+		PartID:    partID,
+		Cursor:    cursor,
+		Signature: signature,
+	}
+}
+
+// Command returns a command that returns the partition ID and the cursor of
+// the next page, and the entries of the page.
+func (e *Export) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.Export)
+	args = append(args, e.DMap)
+	args = append(args, e.PartID)
+	args = append(args, e.Cursor)
+	args = append(args, e.Signature)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseExportCommand(cmd redcon.Command) (*Export, error) {
+	if len(cmd.Args) != 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	var args [3]uint64
+	for i := range args {
+		value, err := strconv.ParseUint(util.BytesToString(cmd.Args[i+2]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	return NewExport(
+		util.BytesToString(cmd.Args[1]), // DMap
+		args[0],                         // PartID
+		args[1],                         // Cursor
+		args[2],                         // Signature
+	), nil
+}
+
+type AccessStats struct {
+	DMap    string
+	Count   int
+	Hottest bool
+	Local   bool
+}
+
+func NewAccessStats(dmap string, count int, hottest bool) *AccessStats {
+	return &AccessStats{
+		DMap:    dmap,
+		Count:   count,
+		Hottest: hottest,
+	}
+}
+
+func (a *AccessStats) SetLocal() *AccessStats {
+	a.Local = true
+	return a
+}
+
+func (a *AccessStats) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, DMap.Access)
+	args = append(args, a.DMap)
+	args = append(args, a.Count)
+	if a.Hottest {
+		args = append(args, "HOT")
+	} else {
+		args = append(args, "COLD")
+	}
+	if a.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseAccessStatsCommand(cmd redcon.Command) (*AccessStats, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	count, err := strconv.Atoi(util.BytesToString(cmd.Args[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	var hottest bool
+	switch order := util.BytesToString(cmd.Args[3]); order {
+	case "HOT":
+		hottest = true
+	case "COLD":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, order)
+	}
+
+	a := NewAccessStats(
+		util.BytesToString(cmd.Args[1]), // DMap
+		count,
+		hottest,
+	)
+
+	if len(cmd.Args) == 5 {
+		arg := util.BytesToString(cmd.Args[4])
+		if arg == "LC" {
+			a.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return a, nil
+}
+
+type HotKeys struct {
+	DMap  string
+	Count int
+	Local bool
+}
+
+func NewHotKeys(dmap string, count int) *HotKeys {
+	return &HotKeys{
+		DMap:  dmap,
+		Count: count,
+	}
+}
+
+func (h *HotKeys) SetLocal() *HotKeys {
+	h.Local = true
+	return h
+}
+
+func (h *HotKeys) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, DMap.HotKeys)
+	args = append(args, h.DMap)
+	args = append(args, h.Count)
+	if h.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseHotKeysCommand(cmd redcon.Command) (*HotKeys, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	count, err := strconv.Atoi(util.BytesToString(cmd.Args[2]))
+	if err != nil {
+		return nil, err
+	}
+
+	h := NewHotKeys(
+		util.BytesToString(cmd.Args[1]), // DMap
+		count,
+	)
+
+	if len(cmd.Args) == 4 {
+		arg := util.BytesToString(cmd.Args[3])
+		if arg == "LC" {
+			h.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return h, nil
+}
+
+type ListDMaps struct {
+	Local bool
+}
+
+func NewListDMaps() *ListDMaps {
+	return &ListDMaps{}
+}
+
+func (l *ListDMaps) SetLocal() *ListDMaps {
+	l.Local = true
+	return l
+}
+
+func (l *ListDMaps) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, DMap.ListDMaps)
+	if l.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseListDMapsCommand(cmd redcon.Command) (*ListDMaps, error) {
+	if len(cmd.Args) > 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	l := NewListDMaps()
+	if len(cmd.Args) == 2 {
+		arg := util.BytesToString(cmd.Args[1])
+		if arg == "LC" {
+			l.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return l, nil
+}
+
+type DelEntry struct {
+	Del       *Del
+	Replica   bool
+	Epoch     uint64
+	Timestamp int64
+}
+
+func NewDelEntry(dmap, key string) *DelEntry {
+	return &DelEntry{
+		Del: NewDel(dmap, key),
+	}
+}
+
+func (d *DelEntry) SetReplica() *DelEntry {
+	d.Replica = true
+	return d
+}
+
+func (d *DelEntry) SetEpoch(epoch uint64) *DelEntry {
+	d.Epoch = epoch
+	return d
+}
+
+// SetTimestamp makes the delete conditional, the entry is kept if it's newer
+// than the given timestamp.
+func (d *DelEntry) SetTimestamp(timestamp int64) *DelEntry {
+	d.Timestamp = timestamp
+	return d
+}
+
+func (d *DelEntry) Command(ctx context.Context) *redis.IntCmd {
+	cmd := d.Del.Command(ctx)
+	args := cmd.Args()
+	args[0] = DMap.DelEntry
+	if d.Replica {
+		args = append(args, "RC")
+	}
+	if d.Epoch != 0 {
+		args = append(args, "EP")
+		args = append(args, d.Epoch)
+	}
+	if d.Timestamp != 0 {
+		args = append(args, "TS")
+		args = append(args, d.Timestamp)
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseDelEntryCommand(cmd redcon.Command) (*DelEntry, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	d := NewDelEntry(
+		util.BytesToString(cmd.Args[1]),
+		util.BytesToString(cmd.Args[2]),
+	)
+
+	args := cmd.Args[3:]
+	for len(args) > 0 {
+		arg := util.BytesToString(args[0])
+		switch arg {
+		case "RC":
+			d.SetReplica()
+			args = args[1:]
+		case "EP":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			epoch, err := strconv.ParseUint(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			d.SetEpoch(epoch)
+			args = args[2:]
+		case "TS":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			timestamp, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			d.SetTimestamp(timestamp)
+			args = args[2:]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return d, nil
+}
+
+type PExpire struct {
+	DMap         string
+	Key          string
+	Milliseconds time.Duration
+}
+
+func NewPExpire(dmap, key string, milliseconds time.Duration) *PExpire {
+	return &PExpire{
+		DMap:         dmap,
+		Key:          key,
+		Milliseconds: milliseconds,
+	}
+}
+
+func (p *PExpire) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.PExpire)
+	args = append(args, p.DMap)
+	args = append(args, p.Key)
+	args = append(args, p.Milliseconds.Milliseconds())
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParsePExpireCommand(cmd redcon.Command) (*PExpire, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	rawMilliseconds := util.BytesToString(cmd.Args[3])
+	milliseconds, err := strconv.ParseInt(rawMilliseconds, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPExpire(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		time.Duration(milliseconds*int64(time.Millisecond)),
+	)
+	return p, nil
+}
+
+type Expire struct {
+	DMap    string
+	Key     string
+	Seconds time.Duration
+}
+
+func NewExpire(dmap, key string, seconds time.Duration) *Expire {
+	return &Expire{
+		DMap:    dmap,
+		Key:     key,
+		Seconds: seconds,
+	}
+}
+
+func (e *Expire) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Expire)
+	args = append(args, e.DMap)
+	args = append(args, e.Key)
+	args = append(args, e.Seconds.Seconds())
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseExpireCommand(cmd redcon.Command) (*Expire, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	rawSeconds := util.BytesToString(cmd.Args[3])
+	seconds, err := strconv.ParseFloat(rawSeconds, 64)
+	if err != nil {
+		return nil, err
+	}
+	e := NewExpire(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		time.Duration(seconds*float64(time.Second)),
+	)
+	return e, nil
+}
+
+type Destroy struct {
+	DMap  string
+	Local bool
 }
 
 func NewDestroy(dmap string) *Destroy {
@@ -527,6 +1255,53 @@ func ParseDestroyCommand(cmd redcon.Command) (*Destroy, error) {
 	return d, nil
 }
 
+type Undo struct {
+	DMap  string
+	Local bool
+}
+
+func NewUndo(dmap string) *Undo {
+	return &Undo{
+		DMap: dmap,
+	}
+}
+
+func (u *Undo) SetLocal() *Undo {
+	u.Local = true
+	return u
+}
+
+func (u *Undo) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.Undo)
+	args = append(args, u.DMap)
+	if u.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseUndoCommand(cmd redcon.Command) (*Undo, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	u := NewUndo(
+		util.BytesToString(cmd.Args[1]),
+	)
+
+	if len(cmd.Args) == 3 {
+		arg := util.BytesToString(cmd.Args[2])
+		if arg == "LC" {
+			u.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return u, nil
+}
+
 type Scan struct {
 	PartID  uint64
 	DMap    string
@@ -671,12 +1446,107 @@ func ParseFunctionCommand(cmd redcon.Command) (*Function, error) {
 	), nil
 }
 
+type Execute struct {
+	DMap      string
+	Key       string
+	Processor string
+	Args      []byte
+}
+
+func NewExecute(dmap, key, processor string, args []byte) *Execute {
+	return &Execute{
+		DMap:      dmap,
+		Key:       key,
+		Processor: processor,
+		Args:      args,
+	}
+}
+
+func (e *Execute) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.Execute)
+	args = append(args, e.DMap)
+	args = append(args, e.Key)
+	args = append(args, e.Processor)
+	args = append(args, e.Args)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseExecuteCommand(cmd redcon.Command) (*Execute, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewExecute(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Processor
+		cmd.Args[4],                     // Args
+	), nil
+}
+
+type IncrMany struct {
+	DMap   string
+	Keys   []string
+	Deltas []int
+}
+
+// NewIncrMany creates a new IncrMany command. Use Add to append key/delta pairs.
+func NewIncrMany(dmap string) *IncrMany {
+	return &IncrMany{
+		DMap: dmap,
+	}
+}
+
+func (i *IncrMany) Add(key string, delta int) *IncrMany {
+	i.Keys = append(i.Keys, key)
+	i.Deltas = append(i.Deltas, delta)
+	return i
+}
+
+// Command returns a command that replies the new values in the order of the keys.
+func (i *IncrMany) Command(ctx context.Context) *redis.IntSliceCmd {
+	var args []interface{}
+	args = append(args, DMap.IncrMany)
+	args = append(args, i.DMap)
+	for idx := range i.Keys {
+		args = append(args, i.Keys[idx])
+		args = append(args, i.Deltas[idx])
+	}
+	return redis.NewIntSliceCmd(ctx, args...)
+}
+
+func ParseIncrManyCommand(cmd redcon.Command) (*IncrMany, error) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 != 0 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	i := NewIncrMany(util.BytesToString(cmd.Args[1]))
+	for idx := 2; idx < len(cmd.Args); idx += 2 {
+		delta, err := strconv.Atoi(util.BytesToString(cmd.Args[idx+1]))
+		if err != nil {
+			return nil, err
+		}
+		i.Add(string(cmd.Args[idx]), delta)
+	}
+	return i, nil
+}
+
 type Lock struct {
 	DMap     string
 	Key      string
 	Deadline float64
 	EX       float64
 	PX       int64
+
+	// Block makes the partition owner wait for the lock until the command is
+	// canceled, Deadline is ignored.
+	Block bool
+
+	// Token is the value of the lock, in hex. A random token is generated if
+	// it's empty. The waiters that send the same token keep their place in the
+	// wait queue between the commands.
+	Token string
 }
 
 func NewLock(dmap, key string, deadline float64) *Lock {
@@ -697,6 +1567,16 @@ func (l *Lock) SetPX(px int64) *Lock {
 	return l
 }
 
+func (l *Lock) SetBlock() *Lock {
+	l.Block = true
+	return l
+}
+
+func (l *Lock) SetToken(token string) *Lock {
+	l.Token = token
+	return l
+}
+
 func (l *Lock) Command(ctx context.Context) *redis.StringCmd {
 	var args []interface{}
 	args = append(args, DMap.Lock)
@@ -715,6 +1595,15 @@ func (l *Lock) Command(ctx context.Context) *redis.StringCmd {
 		args = append(args, l.PX)
 	}
 
+	if l.Block {
+		args = append(args, "BLOCK")
+	}
+
+	if l.Token != "" {
+		args = append(args, "TOKEN")
+		args = append(args, l.Token)
+	}
+
 	return redis.NewStringCmd(ctx, args...)
 }
 
@@ -734,28 +1623,38 @@ func ParseLockCommand(cmd redcon.Command) (*Lock, error) {
 		deadline,                        // Deadline
 	)
 
-	// EX or PX are optional.
-	if len(cmd.Args) > 4 {
-		if len(cmd.Args) == 5 {
-			return nil, fmt.Errorf("%w: %s needs a numerical argument", ErrInvalidArgument, util.BytesToString(cmd.Args[5]))
+	// EX, PX, BLOCK and TOKEN are optional.
+	args := cmd.Args[4:]
+	for len(args) > 0 {
+		arg := strings.ToUpper(util.BytesToString(args[0]))
+		if arg == "BLOCK" {
+			l.SetBlock()
+			args = args[1:]
+			continue
+		}
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: %s needs an argument", ErrInvalidArgument, arg)
 		}
 
-		switch arg := strings.ToUpper(util.BytesToString(cmd.Args[4])); arg {
+		switch arg {
 		case "PX":
-			px, err := strconv.ParseInt(util.BytesToString(cmd.Args[5]), 10, 64)
+			px, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
 			if err != nil {
 				return nil, err
 			}
 			l.PX = px
 		case "EX":
-			ex, err := strconv.ParseFloat(util.BytesToString(cmd.Args[5]), 64)
+			ex, err := strconv.ParseFloat(util.BytesToString(args[1]), 64)
 			if err != nil {
 				return nil, err
 			}
 			l.EX = ex
+		case "TOKEN":
+			l.Token = util.BytesToString(args[1])
 		default:
 			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
 		}
+		args = args[2:]
 	}
 
 	return l, nil
@@ -767,32 +1666,100 @@ type Unlock struct {
 	Token string
 }
 
-func NewUnlock(dmap, key, token string) *Unlock {
-	return &Unlock{
-		DMap:  dmap,
-		Key:   key,
-		Token: token,
+func NewUnlock(dmap, key, token string) *Unlock {
+	return &Unlock{
+		DMap:  dmap,
+		Key:   key,
+		Token: token,
+	}
+}
+
+func (u *Unlock) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Unlock)
+	args = append(args, u.DMap)
+	args = append(args, u.Key)
+	args = append(args, u.Token)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseUnlockCommand(cmd redcon.Command) (*Unlock, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewUnlock(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Token
+	), nil
+}
+
+type LockInfo struct {
+	DMap string
+	Key  string
+}
+
+func NewLockInfo(dmap, key string) *LockInfo {
+	return &LockInfo{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+// Command returns a command that replies the msgpack encoded information of
+// the lock.
+func (l *LockInfo) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.LockInfo)
+	args = append(args, l.DMap)
+	args = append(args, l.Key)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseLockInfoCommand(cmd redcon.Command) (*LockInfo, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewLockInfo(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+type ForceUnlock struct {
+	DMap       string
+	Key        string
+	AdminToken string
+}
+
+func NewForceUnlock(dmap, key, adminToken string) *ForceUnlock {
+	return &ForceUnlock{
+		DMap:       dmap,
+		Key:        key,
+		AdminToken: adminToken,
 	}
 }
 
-func (u *Unlock) Command(ctx context.Context) *redis.StatusCmd {
+func (f *ForceUnlock) Command(ctx context.Context) *redis.StatusCmd {
 	var args []interface{}
-	args = append(args, DMap.Unlock)
-	args = append(args, u.DMap)
-	args = append(args, u.Key)
-	args = append(args, u.Token)
+	args = append(args, DMap.ForceUnlock)
+	args = append(args, f.DMap)
+	args = append(args, f.Key)
+	args = append(args, f.AdminToken)
 	return redis.NewStatusCmd(ctx, args...)
 }
 
-func ParseUnlockCommand(cmd redcon.Command) (*Unlock, error) {
+func ParseForceUnlockCommand(cmd redcon.Command) (*ForceUnlock, error) {
 	if len(cmd.Args) < 4 {
 		return nil, errWrongNumber(cmd.Args)
 	}
 
-	return NewUnlock(
+	return NewForceUnlock(
 		util.BytesToString(cmd.Args[1]), // DMap
 		util.BytesToString(cmd.Args[2]), // Key
-		util.BytesToString(cmd.Args[3]), // Token
+		util.BytesToString(cmd.Args[3]), // AdminToken
 	), nil
 }
 
@@ -883,3 +1850,476 @@ func ParsePLockLeaseCommand(cmd redcon.Command) (*PLockLease, error) {
 		timeout,                         // Timeout
 	), nil
 }
+
+const DefaultChangesCount = 100
+
+// Changes reads the change logs of a DMap after the given sequences by
+// partition ID. The sequences are sent as partition ID and sequence pairs.
+type Changes struct {
+	DMap      string
+	Sequences map[uint64]uint64
+	Count     int
+}
+
+func NewChanges(dmap string) *Changes {
+	return &Changes{
+		DMap:      dmap,
+		Sequences: make(map[uint64]uint64),
+	}
+}
+
+func (c *Changes) SetSequence(partID, sequence uint64) *Changes {
+	c.Sequences[partID] = sequence
+	return c
+}
+
+func (c *Changes) SetCount(count int) *Changes {
+	c.Count = count
+	return c
+}
+
+func (c *Changes) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, DMap.Changes)
+	args = append(args, c.DMap)
+	partIDs := make([]uint64, 0, len(c.Sequences))
+	for partID := range c.Sequences {
+		partIDs = append(partIDs, partID)
+	}
+	sort.Slice(partIDs, func(i, j int) bool { return partIDs[i] < partIDs[j] })
+	for _, partID := range partIDs {
+		args = append(args, partID)
+		args = append(args, c.Sequences[partID])
+	}
+	if c.Count != 0 {
+		args = append(args, "COUNT")
+		args = append(args, c.Count)
+	}
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseChangesCommand(cmd redcon.Command) (*Changes, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	c := NewChanges(
+		util.BytesToString(cmd.Args[1]), // DMap
+	)
+
+	args := cmd.Args[2:]
+	for len(args) > 0 {
+		if len(args) < 2 {
+			return nil, errWrongNumber(cmd.Args)
+		}
+		if strings.ToUpper(util.BytesToString(args[0])) == "COUNT" {
+			count, err := strconv.Atoi(util.BytesToString(args[1]))
+			if err != nil {
+				return nil, err
+			}
+			c.SetCount(count)
+			args = args[2:]
+			continue
+		}
+
+		partID, err := strconv.ParseUint(util.BytesToString(args[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, args[0])
+		}
+		sequence, err := strconv.ParseUint(util.BytesToString(args[1]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		c.SetSequence(partID, sequence)
+		args = args[2:]
+	}
+
+	if c.Count == 0 {
+		c.SetCount(DefaultChangesCount)
+	}
+
+	return c, nil
+}
+
+// Checksum returns the checksum of a backup fragment. It's used by the
+// anti-entropy worker.
+type Checksum struct {
+	PartID uint64
+	DMap   string
+}
+
+func NewChecksum(partID uint64, dmap string) *Checksum {
+	return &Checksum{
+		PartID: partID,
+		DMap:   dmap,
+	}
+}
+
+func (c *Checksum) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.Checksum)
+	args = append(args, c.PartID)
+	args = append(args, c.DMap)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseChecksumCommand(cmd redcon.Command) (*Checksum, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	partID, err := strconv.ParseUint(util.BytesToString(cmd.Args[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewChecksum(
+		partID,
+		util.BytesToString(cmd.Args[2]), // DMap
+	), nil
+}
+
+// Digest returns the keys and the timestamps of the entries in a backup
+// fragment. It's used by the anti-entropy worker.
+type Digest struct {
+	PartID uint64
+	DMap   string
+}
+
+func NewDigest(partID uint64, dmap string) *Digest {
+	return &Digest{
+		PartID: partID,
+		DMap:   dmap,
+	}
+}
+
+func (d *Digest) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.Digest)
+	args = append(args, d.PartID)
+	args = append(args, d.DMap)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseDigestCommand(cmd redcon.Command) (*Digest, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	partID, err := strconv.ParseUint(util.BytesToString(cmd.Args[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDigest(
+		partID,
+		util.BytesToString(cmd.Args[2]), // DMap
+	), nil
+}
+
+type Tombstone struct {
+	DMap string
+	Key  string
+}
+
+func NewTombstone(dmap, key string) *Tombstone {
+	return &Tombstone{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+// Command returns a command that replies the msgpack encoded tombstone of the key.
+func (t *Tombstone) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.Tombstone)
+	args = append(args, t.DMap)
+	args = append(args, t.Key)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseTombstoneCommand(cmd redcon.Command) (*Tombstone, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewTombstone(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+type Tx struct {
+	DMap    string
+	Payload []byte
+	Replica bool
+	Epoch   uint64
+}
+
+func NewTx(dmap string, payload []byte) *Tx {
+	return &Tx{
+		DMap:    dmap,
+		Payload: payload,
+	}
+}
+
+// SetReplica marks the payload as the writes of a committed transaction that
+// are applied by a backup owner.
+func (t *Tx) SetReplica() *Tx {
+	t.Replica = true
+	return t
+}
+
+func (t *Tx) SetEpoch(epoch uint64) *Tx {
+	t.Epoch = epoch
+	return t
+}
+
+// Command returns a command that commits the msgpack encoded reads and writes
+// of a transaction on the partition owner.
+func (t *Tx) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Tx)
+	args = append(args, t.DMap)
+	args = append(args, t.Payload)
+	if t.Replica {
+		args = append(args, "RC")
+	}
+	if t.Epoch != 0 {
+		args = append(args, "EP")
+		args = append(args, t.Epoch)
+	}
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseTxCommand(cmd redcon.Command) (*Tx, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	t := NewTx(
+		util.BytesToString(cmd.Args[1]), // DMap
+		cmd.Args[2],                     // Payload
+	)
+
+	args := cmd.Args[3:]
+	for len(args) > 0 {
+		arg := util.BytesToString(args[0])
+		switch arg {
+		case "RC":
+			t.SetReplica()
+			args = args[1:]
+		case "EP":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			epoch, err := strconv.ParseUint(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			t.SetEpoch(epoch)
+			args = args[2:]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+	return t, nil
+}
+
+type Replicate struct {
+	Payload []byte
+}
+
+func NewReplicate(payload []byte) *Replicate {
+	return &Replicate{
+		Payload: payload,
+	}
+}
+
+// Command returns a command that applies a msgpack encoded batch of writes on
+// an analytics replica.
+func (r *Replicate) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Replicate)
+	args = append(args, r.Payload)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseReplicateCommand(cmd redcon.Command) (*Replicate, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewReplicate(cmd.Args[1]), nil
+}
+
+type Migrate struct {
+	Target string
+	Local  bool
+}
+
+func NewMigrate(target string) *Migrate {
+	return &Migrate{
+		Target: target,
+	}
+}
+
+func (m *Migrate) SetLocal() *Migrate {
+	m.Local = true
+	return m
+}
+
+// Command returns a command that copies the DMaps to the cluster of the
+// target member. It returns the number of the copied entries.
+func (m *Migrate) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.Migrate)
+	args = append(args, m.Target)
+	if m.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseMigrateCommand(cmd redcon.Command) (*Migrate, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	m := NewMigrate(
+		util.BytesToString(cmd.Args[1]), // Target
+	)
+
+	if len(cmd.Args) == 3 {
+		arg := util.BytesToString(cmd.Args[2])
+		if arg == "LC" {
+			m.SetLocal()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+
+	return m, nil
+}
+
+type Warmup struct {
+	DMap   string
+	Keys   []string
+	Values [][]byte
+	// TTLs are in milliseconds, zero means no TTL. They are Unix times if
+	// Import is set.
+	TTLs []int64
+
+	// Import is set by NewImport. The entries overwrite the existing keys and
+	// keep their timestamps and tags.
+	Import     bool
+	Timestamps []int64
+	Tags       [][]string
+}
+
+// NewWarmup creates a new Warmup command. Use Add to append the entries.
+func NewWarmup(dmap string) *Warmup {
+	return &Warmup{
+		DMap: dmap,
+	}
+}
+
+// NewImport creates a Warmup command for the imported entries. Use AddImported
+// to append the entries.
+func NewImport(dmap string) *Warmup {
+	return &Warmup{
+		DMap:   dmap,
+		Import: true,
+	}
+}
+
+func (w *Warmup) Add(key string, value []byte, ttl int64) *Warmup {
+	w.Keys = append(w.Keys, key)
+	w.Values = append(w.Values, value)
+	w.TTLs = append(w.TTLs, ttl)
+	return w
+}
+
+func (w *Warmup) AddImported(key string, value []byte, ttl, timestamp int64, tags []string) *Warmup {
+	w.Add(key, value, ttl)
+	w.Timestamps = append(w.Timestamps, timestamp)
+	w.Tags = append(w.Tags, tags)
+	return w
+}
+
+// Command returns a command that stores the entries on the partition owner.
+// It returns the number of the stored entries.
+func (w *Warmup) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	if w.Import {
+		args = append(args, DMap.Import)
+	} else {
+		args = append(args, DMap.Warmup)
+	}
+	args = append(args, w.DMap)
+	for idx := range w.Keys {
+		args = append(args, w.Keys[idx])
+		args = append(args, w.Values[idx])
+		args = append(args, w.TTLs[idx])
+		if w.Import {
+			args = append(args, w.Timestamps[idx])
+			args = append(args, len(w.Tags[idx]))
+			for _, tag := range w.Tags[idx] {
+				args = append(args, tag)
+			}
+		}
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseWarmupCommand(cmd redcon.Command) (*Warmup, error) {
+	if len(cmd.Args) < 5 || (len(cmd.Args)-2)%3 != 0 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	w := NewWarmup(util.BytesToString(cmd.Args[1]))
+	for idx := 2; idx < len(cmd.Args); idx += 3 {
+		ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[idx+2]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		w.Add(string(cmd.Args[idx]), cmd.Args[idx+1], ttl)
+	}
+	return w, nil
+}
+
+func ParseImportCommand(cmd redcon.Command) (*Warmup, error) {
+	if len(cmd.Args) < 7 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	w := NewImport(util.BytesToString(cmd.Args[1]))
+	idx := 2
+	for idx < len(cmd.Args) {
+		if len(cmd.Args)-idx < 5 {
+			return nil, errWrongNumber(cmd.Args)
+		}
+		ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[idx+2]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := strconv.ParseInt(util.BytesToString(cmd.Args[idx+3]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(util.BytesToString(cmd.Args[idx+4]))
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 || len(cmd.Args)-idx-5 < count {
+			return nil, errWrongNumber(cmd.Args)
+		}
+		var tags []string
+		for _, tag := range cmd.Args[idx+5 : idx+5+count] {
+			tags = append(tags, string(tag))
+		}
+		w.AddImported(string(cmd.Args[idx]), cmd.Args[idx+1], ttl, timestamp, tags)
+		idx += 5 + count
+	}
+	return w, nil
+}
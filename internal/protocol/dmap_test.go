@@ -31,6 +31,7 @@ func stringToCommand(s string) redcon.Command {
 
 	s = strings.TrimSuffix(s, ": []")
 	s = strings.TrimSuffix(s, ": 0")
+	s = strings.TrimSuffix(s, ": false")
 	s = strings.TrimSuffix(s, ":")
 	s = strings.TrimSuffix(s, ": ")
 	parsed := strings.Split(s, " ")
@@ -231,6 +232,48 @@ func TestProtocol_PutEntry(t *testing.T) {
 	require.Equal(t, []byte("my-value"), parsed.Value)
 }
 
+func TestProtocol_PutEntry_EP(t *testing.T) {
+	putEntryCmd := NewPutEntry("my-dmap", "my-key", []byte("my-value"))
+	putEntryCmd.SetEpoch(42)
+
+	cmd := stringToCommand(putEntryCmd.Command(context.Background()).String())
+	parsed, err := ParsePutEntryCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, []byte("my-value"), parsed.Value)
+	require.Equal(t, uint64(42), parsed.Epoch)
+}
+
+func TestProtocol_PutEntry_TS(t *testing.T) {
+	putEntryCmd := NewPutEntry("my-dmap", "my-key", []byte("my-value"))
+	putEntryCmd.SetTimestamp(1000)
+
+	cmd := stringToCommand(putEntryCmd.Command(context.Background()).String())
+	parsed, err := ParsePutEntryCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), parsed.Timestamp)
+}
+
+func TestProtocol_PutEntry_TAG_KT(t *testing.T) {
+	putEntryCmd := NewPutEntry("my-dmap", "my-key", []byte("my-value"))
+	putEntryCmd.SetTags("a", "b")
+
+	cmd := stringToCommand(putEntryCmd.Command(context.Background()).String())
+	parsed, err := ParsePutEntryCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, parsed.Tags)
+	require.False(t, parsed.KeepTags)
+
+	putEntryCmd = NewPutEntry("my-dmap", "my-key", []byte("my-value"))
+	putEntryCmd.SetKeepTags()
+
+	cmd = stringToCommand(putEntryCmd.Command(context.Background()).String())
+	parsed, err = ParsePutEntryCommand(cmd)
+	require.NoError(t, err)
+	require.Empty(t, parsed.Tags)
+	require.True(t, parsed.KeepTags)
+}
+
 func TestProtocol_Get(t *testing.T) {
 	getCmd := NewGet("my-dmap", "my-key")
 
@@ -256,6 +299,20 @@ func TestProtocol_Get_RW(t *testing.T) {
 	require.True(t, parsed.Raw)
 }
 
+func TestProtocol_Get_LN(t *testing.T) {
+	getCmd := NewGet("my-dmap", "my-key")
+	getCmd.SetRaw().SetLinearizable()
+
+	cmd := stringToCommand(getCmd.Command(context.Background()).String())
+	parsed, err := ParseGetCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.True(t, parsed.Raw)
+	require.True(t, parsed.Linearizable)
+}
+
 func TestProtocol_GetEntry(t *testing.T) {
 	getEntryCmd := NewGetEntry("my-dmap", "my-key")
 
@@ -292,6 +349,18 @@ func TestProtocol_Del(t *testing.T) {
 	require.Equal(t, []string{"key1", "key2"}, parsed.Keys)
 }
 
+func TestProtocol_DelFrom(t *testing.T) {
+	delFromCmd := NewDelFrom("my-dmap", "127.0.0.1:3320", "key1", "key2")
+
+	cmd := stringToCommand(delFromCmd.Command(context.Background()).String())
+	parsed, err := ParseDelFromCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.Del.DMap)
+	require.Equal(t, "127.0.0.1:3320", parsed.Client)
+	require.Equal(t, []string{"key1", "key2"}, parsed.Del.Keys)
+}
+
 func TestProtocol_DelEntry(t *testing.T) {
 	delEntryCmd := NewDelEntry("my-dmap", "my-key")
 
@@ -317,6 +386,32 @@ func TestProtocol_DelEntry_RC(t *testing.T) {
 	require.True(t, parsed.Replica)
 }
 
+func TestProtocol_DelEntry_RC_EP(t *testing.T) {
+	delEntryCmd := NewDelEntry("my-dmap", "my-key")
+	delEntryCmd.SetReplica().SetEpoch(42)
+
+	cmd := stringToCommand(delEntryCmd.Command(context.Background()).String())
+	parsed, err := ParseDelEntryCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"my-key"}, parsed.Del.Keys)
+	require.True(t, parsed.Replica)
+	require.Equal(t, uint64(42), parsed.Epoch)
+}
+
+func TestProtocol_DelEntry_TS(t *testing.T) {
+	delEntryCmd := NewDelEntry("my-dmap", "my-key")
+	delEntryCmd.SetReplica().SetTimestamp(1234)
+
+	cmd := stringToCommand(delEntryCmd.Command(context.Background()).String())
+	parsed, err := ParseDelEntryCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"my-key"}, parsed.Del.Keys)
+	require.True(t, parsed.Replica)
+	require.Equal(t, int64(1234), parsed.Timestamp)
+}
+
 func TestProtocol_PExpire(t *testing.T) {
 	pexpireCmd := NewPExpire("my-dmap", "my-key", 10*time.Millisecond)
 
@@ -406,6 +501,25 @@ func TestProtocol_Lock_PX(t *testing.T) {
 	require.Equal(t, pxDuration, parsed.PX)
 }
 
+func TestProtocol_Lock_Block_Token(t *testing.T) {
+	lockCmd := NewLock("my-dmap", "my-key", 0)
+	lockCmd.SetPX(250).SetBlock().SetToken("abcd")
+
+	cmd := stringToCommand(lockCmd.Command(context.Background()).String())
+	parsed, err := ParseLockCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(250), parsed.PX)
+	require.True(t, parsed.Block)
+	require.Equal(t, "abcd", parsed.Token)
+
+	t.Run("Missing argument", func(t *testing.T) {
+		cmd := stringToCommand("dm.lock my-dmap my-key 1 TOKEN")
+		_, err := ParseLockCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
 func TestProtocol_Unlock(t *testing.T) {
 	unlockCmd := NewUnlock("my-dmap", "my-key", "token")
 
@@ -418,6 +532,29 @@ func TestProtocol_Unlock(t *testing.T) {
 	require.Equal(t, "token", parsed.Token)
 }
 
+func TestProtocol_LockInfo(t *testing.T) {
+	lockInfoCmd := NewLockInfo("my-dmap", "my-key")
+
+	cmd := stringToCommand(lockInfoCmd.Command(context.Background()).String())
+	parsed, err := ParseLockInfoCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_ForceUnlock(t *testing.T) {
+	forceUnlockCmd := NewForceUnlock("my-dmap", "my-key", "admin-token")
+
+	cmd := stringToCommand(forceUnlockCmd.Command(context.Background()).String())
+	parsed, err := ParseForceUnlockCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, "admin-token", parsed.AdminToken)
+}
+
 func TestProtocol_LockLease(t *testing.T) {
 	timeout := (7 * time.Second).Seconds()
 	unlockCmd := NewLockLease("my-dmap", "my-key", "token", timeout)
@@ -478,3 +615,341 @@ func TestProtocol_Scan_Count_Match_Replica(t *testing.T) {
 	require.Equal(t, 123, parsed.Count)
 	require.Equal(t, "^even:", parsed.Match)
 }
+
+func TestProtocol_ParseChangesCommand(t *testing.T) {
+	changesCmd := NewChanges("my-dmap").
+		SetSequence(3, 42).
+		SetSequence(7, 0).
+		SetCount(5)
+
+	s := changesCmd.Command(context.Background()).String()
+	s = strings.TrimSuffix(s, ": []")
+	cmd := stringToCommand(s)
+	parsed, err := ParseChangesCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, map[uint64]uint64{3: 42, 7: 0}, parsed.Sequences)
+	require.Equal(t, 5, parsed.Count)
+}
+
+func TestProtocol_ParsePutCommand_Tags(t *testing.T) {
+	putCmd := NewPut("my-dmap", "my-key", []byte("my-value"))
+	putCmd.SetTags("product:42", "category:books")
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []string{"product:42", "category:books"}, parsed.Tags)
+}
+
+func TestProtocol_ParsePutCommand_Publish(t *testing.T) {
+	putCmd := NewPut("my-dmap", "my-key", []byte("my-value"))
+	putCmd.SetPublish("my-channel", "my-message")
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.True(t, parsed.Publish)
+	require.Equal(t, "my-channel", parsed.PublishChannel)
+	require.Equal(t, "my-message", parsed.PublishMessage)
+}
+
+func TestProtocol_ParsePutCommand_Timestamp(t *testing.T) {
+	putCmd := NewPut("my-dmap", "my-key", []byte("my-value"))
+	putCmd.SetTimestamp(1652341232142530000)
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1652341232142530000), parsed.Timestamp)
+}
+
+func TestProtocol_ParsePutCommand_Clock(t *testing.T) {
+	putCmd := NewPut("my-dmap", "my-key", []byte("my-value"))
+	putCmd.SetClock(1652341232142530001)
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1652341232142530001), parsed.Clock)
+	require.Zero(t, parsed.Timestamp)
+}
+
+func TestProtocol_ParsePutCommand_Chunked(t *testing.T) {
+	putCmd := NewPut("my-dmap", "my-key", []byte("my-value"))
+	putCmd.SetChunked().SetEX(10)
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.True(t, parsed.Chunked)
+	require.Equal(t, float64(10), parsed.EX)
+}
+
+func TestProtocol_ParsePutCommand_JitterSliding(t *testing.T) {
+	putCmd := NewPut("my-dmap", "my-key", []byte("my-value"))
+	putCmd.SetPX(1000).SetJitter(12.5).SetSliding(5000)
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1000), parsed.PX)
+	require.Equal(t, 12.5, parsed.Jitter)
+	require.Equal(t, int64(5000), parsed.Sliding)
+}
+
+func TestProtocol_DelByTag(t *testing.T) {
+	delByTagCmd := NewDelByTag("my-dmap", "product:42").SetLocal()
+
+	cmd := stringToCommand(delByTagCmd.Command(context.Background()).String())
+	parsed, err := ParseDelByTagCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "product:42", parsed.Tag)
+	require.True(t, parsed.Local)
+}
+
+func TestProtocol_IncrMany(t *testing.T) {
+	incrManyCmd := NewIncrMany("mydmap").Add("foo", 1).Add("bar", -2)
+
+	cmd := stringToCommand(incrManyCmd.Command(context.Background()).String())
+	parsed, err := ParseIncrManyCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, []string{"foo", "bar"}, parsed.Keys)
+	require.Equal(t, []int{1, -2}, parsed.Deltas)
+
+	t.Run("DM.INCRMANY invalid delta", func(t *testing.T) {
+		cmd := stringToCommand("dm.incrmany mydmap foo bar")
+		_, err = ParseIncrManyCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Warmup(t *testing.T) {
+	warmupCmd := NewWarmup("mydmap").
+		Add("foo", []byte("foo-value"), 0).
+		Add("bar", []byte("bar-value"), 1000)
+
+	cmd := stringToCommand(warmupCmd.Command(context.Background()).String())
+	parsed, err := ParseWarmupCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, []string{"foo", "bar"}, parsed.Keys)
+	require.Equal(t, [][]byte{[]byte("foo-value"), []byte("bar-value")}, parsed.Values)
+	require.Equal(t, []int64{0, 1000}, parsed.TTLs)
+
+	t.Run("DM.WARMUP wrong number of arguments", func(t *testing.T) {
+		cmd := stringToCommand("dm.warmup mydmap foo bar")
+		_, err = ParseWarmupCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Import(t *testing.T) {
+	importCmd := NewImport("mydmap").
+		AddImported("foo", []byte("foo-value"), 0, 10, nil).
+		AddImported("bar", []byte("bar-value"), 1000, 20, []string{"a", "b"})
+
+	cmd := stringToCommand(importCmd.Command(context.Background()).String())
+	parsed, err := ParseImportCommand(cmd)
+	require.NoError(t, err)
+
+	require.True(t, parsed.Import)
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, []string{"foo", "bar"}, parsed.Keys)
+	require.Equal(t, [][]byte{[]byte("foo-value"), []byte("bar-value")}, parsed.Values)
+	require.Equal(t, []int64{0, 1000}, parsed.TTLs)
+	require.Equal(t, []int64{10, 20}, parsed.Timestamps)
+	require.Equal(t, [][]string{nil, {"a", "b"}}, parsed.Tags)
+
+	t.Run("DM.IMPORT wrong number of arguments", func(t *testing.T) {
+		cmd := stringToCommand("dm.import mydmap foo bar 0 10 2 a")
+		_, err = ParseImportCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Execute(t *testing.T) {
+	executeCmd := NewExecute("mydmap", "mykey", "myprocessor", []byte("myargs"))
+
+	cmd := stringToCommand(executeCmd.Command(context.Background()).String())
+	parsed, err := ParseExecuteCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, "mykey", parsed.Key)
+	require.Equal(t, "myprocessor", parsed.Processor)
+	require.Equal(t, []byte("myargs"), parsed.Args)
+}
+
+func TestProtocol_AccessStats(t *testing.T) {
+	accessCmd := NewAccessStats("my-dmap", 10, true).SetLocal()
+
+	cmd := stringToCommand(accessCmd.Command(context.Background()).String())
+	parsed, err := ParseAccessStatsCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, 10, parsed.Count)
+	require.True(t, parsed.Hottest)
+	require.True(t, parsed.Local)
+}
+
+func TestProtocol_HotKeys(t *testing.T) {
+	hotKeysCmd := NewHotKeys("my-dmap", 10).SetLocal()
+
+	cmd := stringToCommand(hotKeysCmd.Command(context.Background()).String())
+	parsed, err := ParseHotKeysCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, 10, parsed.Count)
+	require.True(t, parsed.Local)
+}
+
+func TestProtocol_Export(t *testing.T) {
+	exportCmd := NewExport("my-dmap", 7, 42, 1234)
+
+	cmd := stringToCommand(exportCmd.Command(context.Background()).String())
+	parsed, err := ParseExportCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, uint64(7), parsed.PartID)
+	require.Equal(t, uint64(42), parsed.Cursor)
+	require.Equal(t, uint64(1234), parsed.Signature)
+}
+
+func TestProtocol_ListDMaps(t *testing.T) {
+	listCmd := NewListDMaps().SetLocal()
+
+	cmd := stringToCommand(listCmd.Command(context.Background()).String())
+	parsed, err := ParseListDMapsCommand(cmd)
+	require.NoError(t, err)
+	require.True(t, parsed.Local)
+
+	cmd = stringToCommand(NewListDMaps().Command(context.Background()).String())
+	parsed, err = ParseListDMapsCommand(cmd)
+	require.NoError(t, err)
+	require.False(t, parsed.Local)
+}
+
+func TestProtocol_Query(t *testing.T) {
+	queryCmd := NewQuery("my-dmap", "age>=30").SetLocal()
+
+	cmd := stringToCommand(queryCmd.Command(context.Background()).String())
+	parsed, err := ParseQueryCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "age>=30", parsed.Filter)
+	require.True(t, parsed.Local)
+}
+
+func TestProtocol_QueryPage(t *testing.T) {
+	queryPageCmd := NewQueryPage("my-dmap", "age>=30", 42).SetLocal()
+
+	cmd := stringToCommand(queryPageCmd.Command(context.Background()).String())
+	parsed, err := ParseQueryPageCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "age>=30", parsed.Filter)
+	require.Equal(t, uint64(42), parsed.Cursor)
+	require.True(t, parsed.Local)
+}
+
+func TestProtocol_Undo(t *testing.T) {
+	undoCmd := NewUndo("my-dmap").SetLocal()
+
+	cmd := stringToCommand(undoCmd.Command(context.Background()).String())
+	parsed, err := ParseUndoCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.True(t, parsed.Local)
+}
+
+func TestProtocol_Checksum(t *testing.T) {
+	checksumCmd := NewChecksum(123, "my-dmap")
+
+	cmd := stringToCommand(checksumCmd.Command(context.Background()).String())
+	parsed, err := ParseChecksumCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(123), parsed.PartID)
+	require.Equal(t, "my-dmap", parsed.DMap)
+}
+
+func TestProtocol_Digest(t *testing.T) {
+	digestCmd := NewDigest(123, "my-dmap")
+
+	cmd := stringToCommand(digestCmd.Command(context.Background()).String())
+	parsed, err := ParseDigestCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(123), parsed.PartID)
+	require.Equal(t, "my-dmap", parsed.DMap)
+}
+
+func TestProtocol_Tombstone(t *testing.T) {
+	tombstoneCmd := NewTombstone("my-dmap", "my-key")
+
+	cmd := stringToCommand(tombstoneCmd.Command(context.Background()).String())
+	parsed, err := ParseTombstoneCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_Tx(t *testing.T) {
+	txCmd := NewTx("my-dmap", []byte("payload"))
+
+	cmd := stringToCommand(txCmd.Command(context.Background()).String())
+	parsed, err := ParseTxCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, []byte("payload"), parsed.Payload)
+	require.False(t, parsed.Replica)
+
+	txCmd = NewTx("my-dmap", []byte("payload")).SetReplica().SetEpoch(7)
+	cmd = stringToCommand(txCmd.Command(context.Background()).String())
+	parsed, err = ParseTxCommand(cmd)
+	require.NoError(t, err)
+	require.True(t, parsed.Replica)
+	require.Equal(t, uint64(7), parsed.Epoch)
+}
+
+func TestProtocol_Replicate(t *testing.T) {
+	replicateCmd := NewReplicate([]byte("payload"))
+
+	cmd := stringToCommand(replicateCmd.Command(context.Background()).String())
+	parsed, err := ParseReplicateCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, []byte("payload"), parsed.Payload)
+}
+
+func TestProtocol_Migrate(t *testing.T) {
+	migrateCmd := NewMigrate("127.0.0.1:3320").SetLocal()
+
+	cmd := stringToCommand(migrateCmd.Command(context.Background()).String())
+	parsed, err := ParseMigrateCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "127.0.0.1:3320", parsed.Target)
+	require.True(t, parsed.Local)
+}
@@ -0,0 +1,146 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/go-redis/redis/v8"
+	"github.com/tidwall/redcon"
+)
+
+type HSet struct {
+	DMap  string
+	Key   string
+	Field string
+	Value []byte
+}
+
+func NewHSet(dmap, key, field string, value []byte) *HSet {
+	return &HSet{
+		DMap:  dmap,
+		Key:   key,
+		Field: field,
+		Value: value,
+	}
+}
+
+func (h *HSet) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.HSet)
+	args = append(args, h.DMap)
+	args = append(args, h.Key)
+	args = append(args, h.Field)
+	args = append(args, h.Value)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseHSetCommand(cmd redcon.Command) (*HSet, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewHSet(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		string(cmd.Args[3]),             // Field
+		cmd.Args[4],                     // Value
+	), nil
+}
+
+type HDel struct {
+	DMap   string
+	Key    string
+	Fields []string
+}
+
+func NewHDel(dmap, key string, fields ...string) *HDel {
+	return &HDel{
+		DMap:   dmap,
+		Key:    key,
+		Fields: fields,
+	}
+}
+
+func (h *HDel) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.HDel)
+	args = append(args, h.DMap)
+	args = append(args, h.Key)
+	for _, field := range h.Fields {
+		args = append(args, field)
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseHDelCommand(cmd redcon.Command) (*HDel, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	h := NewHDel(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	)
+	for _, field := range cmd.Args[3:] {
+		h.Fields = append(h.Fields, string(field))
+	}
+	return h, nil
+}
+
+type HIncrBy struct {
+	DMap  string
+	Key   string
+	Field string
+	Delta int
+}
+
+func NewHIncrBy(dmap, key, field string, delta int) *HIncrBy {
+	return &HIncrBy{
+		DMap:  dmap,
+		Key:   key,
+		Field: field,
+		Delta: delta,
+	}
+}
+
+func (h *HIncrBy) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.HIncrBy)
+	args = append(args, h.DMap)
+	args = append(args, h.Key)
+	args = append(args, h.Field)
+	args = append(args, h.Delta)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseHIncrByCommand(cmd redcon.Command) (*HIncrBy, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	delta, err := strconv.Atoi(util.BytesToString(cmd.Args[4]))
+	if err != nil {
+		return nil, err
+	}
+	return NewHIncrBy(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		string(cmd.Args[3]),             // Field
+		delta,
+	), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocol_HSet(t *testing.T) {
+	hsetCmd := NewHSet("mydmap", "mykey", "myfield", []byte("myvalue"))
+
+	cmd := stringToCommand(hsetCmd.Command(context.Background()).String())
+	parsed, err := ParseHSetCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, "mykey", parsed.Key)
+	require.Equal(t, "myfield", parsed.Field)
+	require.Equal(t, []byte("myvalue"), parsed.Value)
+}
+
+func TestProtocol_HDel(t *testing.T) {
+	hdelCmd := NewHDel("mydmap", "mykey", "foo", "bar")
+
+	cmd := stringToCommand(hdelCmd.Command(context.Background()).String())
+	parsed, err := ParseHDelCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, "mykey", parsed.Key)
+	require.Equal(t, []string{"foo", "bar"}, parsed.Fields)
+
+	t.Run("DM.HDEL without fields", func(t *testing.T) {
+		cmd := stringToCommand("dm.hdel mydmap mykey")
+		_, err = ParseHDelCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_HIncrBy(t *testing.T) {
+	hincrbyCmd := NewHIncrBy("mydmap", "mykey", "myfield", -10)
+
+	cmd := stringToCommand(hincrbyCmd.Command(context.Background()).String())
+	parsed, err := ParseHIncrByCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "mydmap", parsed.DMap)
+	require.Equal(t, "mykey", parsed.Key)
+	require.Equal(t, "myfield", parsed.Field)
+	require.Equal(t, -10, parsed.Delta)
+}
@@ -0,0 +1,246 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/go-redis/redis/v8"
+	"github.com/tidwall/redcon"
+)
+
+type QueuePush struct {
+	Queue string
+	Value []byte
+}
+
+func NewQueuePush(queue string, value []byte) *QueuePush {
+	return &QueuePush{
+		Queue: queue,
+		Value: value,
+	}
+}
+
+func (q *QueuePush) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Queue.Push)
+	args = append(args, q.Queue)
+	args = append(args, q.Value)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseQueuePushCommand(cmd redcon.Command) (*QueuePush, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewQueuePush(
+		util.BytesToString(cmd.Args[1]), // Queue
+		cmd.Args[2],                     // Value
+	), nil
+}
+
+type QueuePop struct {
+	Queue      string
+	Visibility int64
+}
+
+// NewQueuePop creates a new QueuePop command. The visibility timeout is in milliseconds.
+func NewQueuePop(queue string, visibility int64) *QueuePop {
+	return &QueuePop{
+		Queue:      queue,
+		Visibility: visibility,
+	}
+}
+
+func (q *QueuePop) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, Queue.Pop)
+	args = append(args, q.Queue)
+	args = append(args, q.Visibility)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseQueuePopCommand(cmd redcon.Command) (*QueuePop, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	visibility, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewQueuePop(util.BytesToString(cmd.Args[1]), visibility), nil
+}
+
+type QueueBPop struct {
+	Queue      string
+	Visibility int64
+	Timeout    int64
+}
+
+// NewQueueBPop creates a new QueueBPop command. The visibility timeout and
+// timeout are in milliseconds.
+func NewQueueBPop(queue string, visibility, timeout int64) *QueueBPop {
+	return &QueueBPop{
+		Queue:      queue,
+		Visibility: visibility,
+		Timeout:    timeout,
+	}
+}
+
+func (q *QueueBPop) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, Queue.BPop)
+	args = append(args, q.Queue)
+	args = append(args, q.Visibility)
+	args = append(args, q.Timeout)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseQueueBPopCommand(cmd redcon.Command) (*QueueBPop, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	visibility, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := strconv.ParseInt(util.BytesToString(cmd.Args[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewQueueBPop(util.BytesToString(cmd.Args[1]), visibility, timeout), nil
+}
+
+type QueueAck struct {
+	Queue    string
+	ID       uint64
+	Delivery uint64
+}
+
+func NewQueueAck(queue string, id, delivery uint64) *QueueAck {
+	return &QueueAck{
+		Queue:    queue,
+		ID:       id,
+		Delivery: delivery,
+	}
+}
+
+func (q *QueueAck) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Queue.Ack)
+	args = append(args, q.Queue)
+	args = append(args, q.ID)
+	args = append(args, q.Delivery)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseQueueAckCommand(cmd redcon.Command) (*QueueAck, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	id, err := strconv.ParseUint(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := strconv.ParseUint(util.BytesToString(cmd.Args[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewQueueAck(util.BytesToString(cmd.Args[1]), id, delivery), nil
+}
+
+type QueueLen struct {
+	Queue string
+}
+
+func NewQueueLen(queue string) *QueueLen {
+	return &QueueLen{
+		Queue: queue,
+	}
+}
+
+func (q *QueueLen) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Queue.Len)
+	args = append(args, q.Queue)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseQueueLenCommand(cmd redcon.Command) (*QueueLen, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewQueueLen(util.BytesToString(cmd.Args[1])), nil
+}
+
+type MoveQueue struct {
+	Payload []byte
+}
+
+func NewMoveQueue(payload []byte) *MoveQueue {
+	return &MoveQueue{
+		Payload: payload,
+	}
+}
+
+func (m *MoveQueue) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.MoveQueue)
+	args = append(args, m.Payload)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseMoveQueueCommand(cmd redcon.Command) (*MoveQueue, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewMoveQueue(cmd.Args[1]), nil
+}
+
+type ReplicateQueue struct {
+	Payload []byte
+}
+
+func NewReplicateQueue(payload []byte) *ReplicateQueue {
+	return &ReplicateQueue{
+		Payload: payload,
+	}
+}
+
+func (r *ReplicateQueue) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.ReplicateQueue)
+	args = append(args, r.Payload)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseReplicateQueueCommand(cmd redcon.Command) (*ReplicateQueue, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewReplicateQueue(cmd.Args[1]), nil
+}
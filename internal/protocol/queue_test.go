@@ -0,0 +1,78 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocol_QueuePush(t *testing.T) {
+	pushCmd := NewQueuePush("jobs", []byte("value"))
+
+	cmd := stringToCommand(pushCmd.Command(context.Background()).String())
+	parsed, err := ParseQueuePushCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "jobs", parsed.Queue)
+	require.Equal(t, []byte("value"), parsed.Value)
+}
+
+func TestProtocol_QueuePop(t *testing.T) {
+	popCmd := NewQueuePop("jobs", 1000)
+
+	cmd := stringToCommand(popCmd.Command(context.Background()).String())
+	parsed, err := ParseQueuePopCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "jobs", parsed.Queue)
+	require.Equal(t, int64(1000), parsed.Visibility)
+}
+
+func TestProtocol_QueueBPop(t *testing.T) {
+	bpopCmd := NewQueueBPop("jobs", 1000, 500)
+
+	cmd := stringToCommand(bpopCmd.Command(context.Background()).String())
+	parsed, err := ParseQueueBPopCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "jobs", parsed.Queue)
+	require.Equal(t, int64(1000), parsed.Visibility)
+	require.Equal(t, int64(500), parsed.Timeout)
+}
+
+func TestProtocol_QueueAck(t *testing.T) {
+	ackCmd := NewQueueAck("jobs", 10, 2)
+
+	cmd := stringToCommand(ackCmd.Command(context.Background()).String())
+	parsed, err := ParseQueueAckCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "jobs", parsed.Queue)
+	require.Equal(t, uint64(10), parsed.ID)
+	require.Equal(t, uint64(2), parsed.Delivery)
+}
+
+func TestProtocol_QueueLen(t *testing.T) {
+	lenCmd := NewQueueLen("jobs")
+
+	cmd := stringToCommand(lenCmd.Command(context.Background()).String())
+	parsed, err := ParseQueueLenCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "jobs", parsed.Queue)
+}
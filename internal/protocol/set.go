@@ -0,0 +1,347 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/go-redis/redis/v8"
+	"github.com/tidwall/redcon"
+)
+
+func parseMembers(args [][]byte) []string {
+	var members []string
+	for _, arg := range args {
+		members = append(members, string(arg))
+	}
+	return members
+}
+
+type SAdd struct {
+	Set     string
+	Members []string
+}
+
+func NewSAdd(set string, members ...string) *SAdd {
+	return &SAdd{
+		Set:     set,
+		Members: members,
+	}
+}
+
+func (s *SAdd) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Set.SAdd)
+	args = append(args, s.Set)
+	for _, member := range s.Members {
+		args = append(args, member)
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseSAddCommand(cmd redcon.Command) (*SAdd, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewSAdd(
+		util.BytesToString(cmd.Args[1]), // Set
+		parseMembers(cmd.Args[2:])...,   // Members
+	), nil
+}
+
+type SRem struct {
+	Set     string
+	Members []string
+}
+
+func NewSRem(set string, members ...string) *SRem {
+	return &SRem{
+		Set:     set,
+		Members: members,
+	}
+}
+
+func (s *SRem) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Set.SRem)
+	args = append(args, s.Set)
+	for _, member := range s.Members {
+		args = append(args, member)
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseSRemCommand(cmd redcon.Command) (*SRem, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewSRem(
+		util.BytesToString(cmd.Args[1]), // Set
+		parseMembers(cmd.Args[2:])...,   // Members
+	), nil
+}
+
+type SIsMember struct {
+	Set    string
+	Member string
+}
+
+func NewSIsMember(set, member string) *SIsMember {
+	return &SIsMember{
+		Set:    set,
+		Member: member,
+	}
+}
+
+func (s *SIsMember) Command(ctx context.Context) *redis.BoolCmd {
+	var args []interface{}
+	args = append(args, Set.SIsMember)
+	args = append(args, s.Set)
+	args = append(args, s.Member)
+	return redis.NewBoolCmd(ctx, args...)
+}
+
+func ParseSIsMemberCommand(cmd redcon.Command) (*SIsMember, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewSIsMember(
+		util.BytesToString(cmd.Args[1]), // Set
+		string(cmd.Args[2]),             // Member
+	), nil
+}
+
+type SMembers struct {
+	Set string
+}
+
+func NewSMembers(set string) *SMembers {
+	return &SMembers{
+		Set: set,
+	}
+}
+
+func (s *SMembers) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, Set.SMembers)
+	args = append(args, s.Set)
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseSMembersCommand(cmd redcon.Command) (*SMembers, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewSMembers(util.BytesToString(cmd.Args[1])), nil
+}
+
+type SCard struct {
+	Set string
+}
+
+func NewSCard(set string) *SCard {
+	return &SCard{
+		Set: set,
+	}
+}
+
+func (s *SCard) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Set.SCard)
+	args = append(args, s.Set)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseSCardCommand(cmd redcon.Command) (*SCard, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewSCard(util.BytesToString(cmd.Args[1])), nil
+}
+
+type ZAdd struct {
+	SortedSet string
+	Scores    []float64
+	Members   []string
+}
+
+// NewZAdd creates a new ZAdd command. Use Add to append score/member pairs.
+func NewZAdd(sortedSet string) *ZAdd {
+	return &ZAdd{
+		SortedSet: sortedSet,
+	}
+}
+
+func (z *ZAdd) Add(score float64, member string) *ZAdd {
+	z.Scores = append(z.Scores, score)
+	z.Members = append(z.Members, member)
+	return z
+}
+
+func (z *ZAdd) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, SortedSet.ZAdd)
+	args = append(args, z.SortedSet)
+	for i := range z.Members {
+		args = append(args, z.Scores[i])
+		args = append(args, z.Members[i])
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseZAddCommand(cmd redcon.Command) (*ZAdd, error) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 != 0 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	z := NewZAdd(util.BytesToString(cmd.Args[1]))
+	for i := 2; i < len(cmd.Args); i += 2 {
+		score, err := strconv.ParseFloat(util.BytesToString(cmd.Args[i]), 64)
+		if err != nil {
+			return nil, err
+		}
+		z.Add(score, string(cmd.Args[i+1]))
+	}
+	return z, nil
+}
+
+type ZRangeByScore struct {
+	SortedSet string
+	Min       float64
+	Max       float64
+}
+
+func NewZRangeByScore(sortedSet string, min, max float64) *ZRangeByScore {
+	return &ZRangeByScore{
+		SortedSet: sortedSet,
+		Min:       min,
+		Max:       max,
+	}
+}
+
+// Command returns a command that replies the members and their scores
+// consecutively, like ZRANGEBYSCORE with WITHSCORES.
+func (z *ZRangeByScore) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, SortedSet.ZRangeByScore)
+	args = append(args, z.SortedSet)
+	args = append(args, z.Min)
+	args = append(args, z.Max)
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseZRangeByScoreCommand(cmd redcon.Command) (*ZRangeByScore, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	min, err := strconv.ParseFloat(util.BytesToString(cmd.Args[2]), 64)
+	if err != nil {
+		return nil, err
+	}
+	max, err := strconv.ParseFloat(util.BytesToString(cmd.Args[3]), 64)
+	if err != nil {
+		return nil, err
+	}
+	return NewZRangeByScore(util.BytesToString(cmd.Args[1]), min, max), nil
+}
+
+type ZRank struct {
+	SortedSet string
+	Member    string
+}
+
+func NewZRank(sortedSet, member string) *ZRank {
+	return &ZRank{
+		SortedSet: sortedSet,
+		Member:    member,
+	}
+}
+
+func (z *ZRank) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, SortedSet.ZRank)
+	args = append(args, z.SortedSet)
+	args = append(args, z.Member)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseZRankCommand(cmd redcon.Command) (*ZRank, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewZRank(
+		util.BytesToString(cmd.Args[1]), // SortedSet
+		string(cmd.Args[2]),             // Member
+	), nil
+}
+
+type MoveSet struct {
+	Payload []byte
+}
+
+func NewMoveSet(payload []byte) *MoveSet {
+	return &MoveSet{
+		Payload: payload,
+	}
+}
+
+func (m *MoveSet) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.MoveSet)
+	args = append(args, m.Payload)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseMoveSetCommand(cmd redcon.Command) (*MoveSet, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewMoveSet(cmd.Args[1]), nil
+}
+
+type ReplicateSet struct {
+	Payload []byte
+}
+
+func NewReplicateSet(payload []byte) *ReplicateSet {
+	return &ReplicateSet{
+		Payload: payload,
+	}
+}
+
+func (r *ReplicateSet) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.ReplicateSet)
+	args = append(args, r.Payload)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseReplicateSetCommand(cmd redcon.Command) (*ReplicateSet, error) {
+	if len(cmd.Args) < 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewReplicateSet(cmd.Args[1]), nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocol_SAdd(t *testing.T) {
+	saddCmd := NewSAdd("myset", "foo", "bar")
+
+	cmd := stringToCommand(saddCmd.Command(context.Background()).String())
+	parsed, err := ParseSAddCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "myset", parsed.Set)
+	require.Equal(t, []string{"foo", "bar"}, parsed.Members)
+}
+
+func TestProtocol_SRem(t *testing.T) {
+	sremCmd := NewSRem("myset", "foo")
+
+	cmd := stringToCommand(sremCmd.Command(context.Background()).String())
+	parsed, err := ParseSRemCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "myset", parsed.Set)
+	require.Equal(t, []string{"foo"}, parsed.Members)
+
+	t.Run("SET.SREM without members", func(t *testing.T) {
+		cmd := stringToCommand("set.srem myset")
+		_, err = ParseSRemCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_SIsMember(t *testing.T) {
+	sismemberCmd := NewSIsMember("myset", "foo")
+
+	cmd := stringToCommand(sismemberCmd.Command(context.Background()).String())
+	parsed, err := ParseSIsMemberCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "myset", parsed.Set)
+	require.Equal(t, "foo", parsed.Member)
+}
+
+func TestProtocol_SMembers(t *testing.T) {
+	smembersCmd := NewSMembers("myset")
+
+	cmd := stringToCommand(smembersCmd.Command(context.Background()).String())
+	parsed, err := ParseSMembersCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "myset", parsed.Set)
+}
+
+func TestProtocol_SCard(t *testing.T) {
+	scardCmd := NewSCard("myset")
+
+	cmd := stringToCommand(scardCmd.Command(context.Background()).String())
+	parsed, err := ParseSCardCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "myset", parsed.Set)
+}
+
+func TestProtocol_ZAdd(t *testing.T) {
+	zaddCmd := NewZAdd("leaderboard").Add(1.5, "foo").Add(-2, "bar")
+
+	cmd := stringToCommand(zaddCmd.Command(context.Background()).String())
+	parsed, err := ParseZAddCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "leaderboard", parsed.SortedSet)
+	require.Equal(t, []float64{1.5, -2}, parsed.Scores)
+	require.Equal(t, []string{"foo", "bar"}, parsed.Members)
+
+	t.Run("ZSET.ZADD without member", func(t *testing.T) {
+		cmd := stringToCommand("zset.zadd leaderboard 1.5")
+		_, err = ParseZAddCommand(cmd)
+		require.Error(t, err)
+	})
+
+	t.Run("ZSET.ZADD invalid score", func(t *testing.T) {
+		cmd := stringToCommand("zset.zadd leaderboard foobar foo")
+		_, err = ParseZAddCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_ZRangeByScore(t *testing.T) {
+	zrangeCmd := NewZRangeByScore("leaderboard", 0, 10.5)
+
+	cmd := stringToCommand(zrangeCmd.Command(context.Background()).String())
+	parsed, err := ParseZRangeByScoreCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "leaderboard", parsed.SortedSet)
+	require.Equal(t, float64(0), parsed.Min)
+	require.Equal(t, 10.5, parsed.Max)
+}
+
+func TestProtocol_ZRank(t *testing.T) {
+	zrankCmd := NewZRank("leaderboard", "foo")
+
+	cmd := stringToCommand(zrankCmd.Command(context.Background()).String())
+	parsed, err := ParseZRankCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "leaderboard", parsed.SortedSet)
+	require.Equal(t, "foo", parsed.Member)
+}
+
+func TestProtocol_MoveSet(t *testing.T) {
+	moveSetCmd := NewMoveSet([]byte("payload"))
+
+	cmd := stringToCommand(moveSetCmd.Command(context.Background()).String())
+	parsed, err := ParseMoveSetCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, []byte("payload"), parsed.Payload)
+}
+
+func TestProtocol_ReplicateSet(t *testing.T) {
+	replicateSetCmd := NewReplicateSet([]byte("payload"))
+
+	cmd := stringToCommand(replicateSetCmd.Command(context.Background()).String())
+	parsed, err := ParseReplicateSetCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, []byte("payload"), parsed.Payload)
+}
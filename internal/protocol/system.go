@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/buraksezer/olric/internal/util"
 	"github.com/go-redis/redis/v8"
@@ -58,6 +59,53 @@ func ParsePingCommand(cmd redcon.Command) (*Ping, error) {
 	return p, nil
 }
 
+// Deadline wraps a command with the remaining time until the deadline of the
+// client, in milliseconds: DEADLINE <timeout-ms> <command> [args...]
+// The receiver derives the deadline from its own clock, so the clocks of the
+// client and the member don't have to be in sync.
+type Deadline struct {
+	Timeout time.Duration
+	Command redcon.Command
+}
+
+func NewDeadline(timeout time.Duration) *Deadline {
+	return &Deadline{
+		Timeout: timeout,
+	}
+}
+
+// TimeoutMilliseconds returns the timeout in milliseconds. It's rounded up,
+// so the timeouts below a millisecond don't become zero.
+func (d *Deadline) TimeoutMilliseconds() int64 {
+	if d.Timeout <= 0 {
+		return 0
+	}
+	return int64((d.Timeout + time.Millisecond - 1) / time.Millisecond)
+}
+
+// Wrap returns the arguments of the wrapped command.
+func (d *Deadline) Wrap(args []interface{}) []interface{} {
+	wrapped := make([]interface{}, 0, len(args)+2)
+	wrapped = append(wrapped, Generic.Deadline)
+	wrapped = append(wrapped, d.TimeoutMilliseconds())
+	return append(wrapped, args...)
+}
+
+func ParseDeadlineCommand(cmd redcon.Command) (*Deadline, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	timeout, err := strconv.ParseInt(util.BytesToString(cmd.Args[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewDeadline(time.Duration(timeout) * time.Millisecond)
+	d.Command = redcon.Command{Args: cmd.Args[2:]}
+	return d, nil
+}
+
 type MoveFragment struct {
 	Payload []byte
 }
@@ -163,8 +211,72 @@ func ParseLengthOfPartCommand(cmd redcon.Command) (*LengthOfPart, error) {
 	return l, nil
 }
 
+type RecreateBackups struct{}
+
+func NewRecreateBackups() *RecreateBackups {
+	return &RecreateBackups{}
+}
+
+func (r *RecreateBackups) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.RecreateBackups)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseRecreateBackupsCommand(cmd redcon.Command) (*RecreateBackups, error) {
+	if len(cmd.Args) != 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewRecreateBackups(), nil
+}
+
+type RoutingSignature struct{}
+
+func NewRoutingSignature() *RoutingSignature {
+	return &RoutingSignature{}
+}
+
+func (r *RoutingSignature) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, Internal.RoutingSignature)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseRoutingSignatureCommand(cmd redcon.Command) (*RoutingSignature, error) {
+	if len(cmd.Args) != 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewRoutingSignature(), nil
+}
+
+// Member is sent by the cluster members when they open a connection, the
+// commands on the connection are not client commands anymore.
+type Member struct{}
+
+func NewMember() *Member {
+	return &Member{}
+}
+
+func (m *Member) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.Member)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseMemberCommand(cmd redcon.Command) (*Member, error) {
+	if len(cmd.Args) != 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewMember(), nil
+}
+
 type Stats struct {
 	CollectRuntime bool
+	NoPartitions   bool
+	DMaps          []string
+	Delta          bool
+	Session        string
+	Sequence       uint64
 }
 
 func NewStats() *Stats {
@@ -176,12 +288,42 @@ func (s *Stats) SetCollectRuntime() *Stats {
 	return s
 }
 
+// SetNoPartitions omits the partition and backup statistics.
+func (s *Stats) SetNoPartitions() *Stats {
+	s.NoPartitions = true
+	return s
+}
+
+// SetDMaps only keeps the statistics of the given DMaps.
+func (s *Stats) SetDMaps(dmaps ...string) *Stats {
+	s.DMaps = dmaps
+	return s
+}
+
+// SetDelta asks for the counters that are changed since the given delta of
+// the session, instead of the statistics.
+func (s *Stats) SetDelta(session string, sequence uint64) *Stats {
+	s.Delta = true
+	s.Session = session
+	s.Sequence = sequence
+	return s
+}
+
 func (s *Stats) Command(ctx context.Context) *redis.StringCmd {
 	var args []interface{}
 	args = append(args, Generic.Stats)
 	if s.CollectRuntime {
 		args = append(args, "CR")
 	}
+	if s.NoPartitions {
+		args = append(args, "NP")
+	}
+	for _, name := range s.DMaps {
+		args = append(args, "DMAP", name)
+	}
+	if s.Delta {
+		args = append(args, "DELTA", s.Session, s.Sequence)
+	}
 	return redis.NewStringCmd(ctx, args...)
 }
 
@@ -191,14 +333,75 @@ func ParseStatsCommand(cmd redcon.Command) (*Stats, error) {
 	}
 
 	s := NewStats()
-	if len(cmd.Args) == 2 {
-		arg := util.BytesToString(cmd.Args[1])
-		if arg == "CR" {
+	args := cmd.Args[1:]
+	for len(args) > 0 {
+		arg := util.BytesToString(args[0])
+		switch arg {
+		case "CR":
 			s.SetCollectRuntime()
-		} else {
+			args = args[1:]
+		case "NP":
+			s.SetNoPartitions()
+			args = args[1:]
+		case "DMAP":
+			if len(args) < 2 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			s.DMaps = append(s.DMaps, util.BytesToString(args[1]))
+			args = args[2:]
+		case "DELTA":
+			if len(args) < 3 {
+				return nil, errWrongNumber(cmd.Args)
+			}
+			sequence, err := strconv.ParseUint(util.BytesToString(args[2]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			s.SetDelta(util.BytesToString(args[1]), sequence)
+			args = args[3:]
+		default:
 			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
 		}
 	}
 
 	return s, nil
 }
+
+type SlowLog struct {
+	Count int
+}
+
+func NewSlowLog() *SlowLog {
+	return &SlowLog{}
+}
+
+func (s *SlowLog) SetCount(count int) *SlowLog {
+	s.Count = count
+	return s
+}
+
+func (s *SlowLog) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, Generic.SlowLog)
+	if s.Count > 0 {
+		args = append(args, s.Count)
+	}
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseSlowLogCommand(cmd redcon.Command) (*SlowLog, error) {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	s := NewSlowLog()
+	if len(cmd.Args) == 2 {
+		count, err := strconv.Atoi(util.BytesToString(cmd.Args[1]))
+		if err != nil {
+			return nil, err
+		}
+		s.SetCount(count)
+	}
+
+	return s, nil
+}
@@ -16,8 +16,11 @@ package protocol
 
 import (
 	"context"
-	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProtocol_Ping(t *testing.T) {
@@ -41,6 +44,26 @@ func TestProtocol_Ping_Message(t *testing.T) {
 	require.Equal(t, "message", parsed.Message)
 }
 
+func TestProtocol_Deadline(t *testing.T) {
+	args := NewDeadline(time.Second).Wrap([]interface{}{DMap.Get, "mydmap", "mykey"})
+
+	cmd := stringToCommand(redis.NewStringCmd(context.Background(), args...).String())
+	parsed, err := ParseDeadlineCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, time.Second, parsed.Timeout)
+	getCmd, err := ParseGetCommand(parsed.Command)
+	require.NoError(t, err)
+	require.Equal(t, "mydmap", getCmd.DMap)
+	require.Equal(t, "mykey", getCmd.Key)
+}
+
+func TestProtocol_Deadline_Round_Up(t *testing.T) {
+	require.Equal(t, int64(1), NewDeadline(time.Microsecond).TimeoutMilliseconds())
+	require.Equal(t, int64(2), NewDeadline(1500*time.Microsecond).TimeoutMilliseconds())
+	require.Equal(t, int64(0), NewDeadline(-time.Second).TimeoutMilliseconds())
+}
+
 func TestProtocol_MoveFragment(t *testing.T) {
 	moveFragmentCmd := NewMoveFragment([]byte("payload"))
 
@@ -85,6 +108,30 @@ func TestProtocol_LengthOfPart_RC(t *testing.T) {
 	require.True(t, parsed.Replica)
 }
 
+func TestProtocol_RecreateBackups(t *testing.T) {
+	recreateBackupsCmd := NewRecreateBackups()
+
+	cmd := stringToCommand(recreateBackupsCmd.Command(context.Background()).String())
+	_, err := ParseRecreateBackupsCommand(cmd)
+	require.NoError(t, err)
+}
+
+func TestProtocol_RoutingSignature(t *testing.T) {
+	routingSignatureCmd := NewRoutingSignature()
+
+	cmd := stringToCommand(routingSignatureCmd.Command(context.Background()).String())
+	_, err := ParseRoutingSignatureCommand(cmd)
+	require.NoError(t, err)
+}
+
+func TestProtocol_Member(t *testing.T) {
+	memberCmd := NewMember()
+
+	cmd := stringToCommand(memberCmd.Command(context.Background()).String())
+	_, err := ParseMemberCommand(cmd)
+	require.NoError(t, err)
+}
+
 func TestProtocol_Stats(t *testing.T) {
 	statsCmd := NewStats()
 
@@ -95,6 +142,56 @@ func TestProtocol_Stats(t *testing.T) {
 	require.False(t, parsed.CollectRuntime)
 }
 
+func TestProtocol_Stats_Filter(t *testing.T) {
+	statsCmd := NewStats()
+	statsCmd.SetNoPartitions().SetDMaps("foo", "bar")
+
+	cmd := stringToCommand(statsCmd.Command(context.Background()).String())
+	parsed, err := ParseStatsCommand(cmd)
+	require.NoError(t, err)
+
+	require.False(t, parsed.CollectRuntime)
+	require.True(t, parsed.NoPartitions)
+	require.Equal(t, []string{"foo", "bar"}, parsed.DMaps)
+
+	t.Run("STATS invalid command", func(t *testing.T) {
+		cmd := stringToCommand("stats DMAP")
+		_, err = ParseStatsCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_Stats_Delta(t *testing.T) {
+	statsCmd := NewStats()
+	statsCmd.SetNoPartitions().SetDelta("mysession", 42)
+
+	cmd := stringToCommand(statsCmd.Command(context.Background()).String())
+	parsed, err := ParseStatsCommand(cmd)
+	require.NoError(t, err)
+
+	require.True(t, parsed.NoPartitions)
+	require.True(t, parsed.Delta)
+	require.Equal(t, "mysession", parsed.Session)
+	require.Equal(t, uint64(42), parsed.Sequence)
+
+	t.Run("STATS invalid command", func(t *testing.T) {
+		cmd := stringToCommand("stats DELTA mysession")
+		_, err = ParseStatsCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_SlowLog(t *testing.T) {
+	slowLogCmd := NewSlowLog()
+	slowLogCmd.SetCount(10)
+
+	cmd := stringToCommand(slowLogCmd.Command(context.Background()).String())
+	parsed, err := ParseSlowLogCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, 10, parsed.Count)
+}
+
 func TestProtocol_Stats_CR(t *testing.T) {
 	statsCmd := NewStats()
 	statsCmd.SetCollectRuntime()
@@ -40,32 +40,11 @@ func (s *Service) publishCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	var total int
-	members := s.rt.Discovery().GetMembers()
-	for _, member := range members {
-		if member.CompareByID(s.rt.This()) {
-			count := s.pubsub.Publish(publishCmd.Channel, publishCmd.Message)
-			total += count
-			PublishedTotal.Increase(int64(count))
-			continue
-		}
-
-		pi := protocol.NewPublishInternal(publishCmd.Channel, publishCmd.Message).Command(s.ctx)
-		rc := s.client.Get(member.String())
-		err = rc.Process(s.ctx, pi)
-		if err != nil {
-			protocol.WriteError(conn, err)
-			return
-		}
-		pcount, err := pi.Result()
-		if err != nil {
-			protocol.WriteError(conn, err)
-			return
-		}
-		total += int(pcount)
-		PublishedTotal.Increase(pcount)
+	total, err := s.Publish(s.ctx, publishCmd.Channel, publishCmd.Message)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
 	}
-
 	conn.WriteInt(total)
 }
 
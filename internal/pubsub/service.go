@@ -65,6 +65,35 @@ func (s *Service) RegisterHandlers() {
 
 }
 
+// Publish sends the message to the subscribers of the channel on every
+// cluster member and returns the number of the subscribers that received it.
+func (s *Service) Publish(ctx context.Context, channel, message string) (int, error) {
+	var total int
+	members := s.rt.Discovery().GetMembers()
+	for _, member := range members {
+		if member.CompareByID(s.rt.This()) {
+			count := s.pubsub.Publish(channel, message)
+			total += count
+			PublishedTotal.Increase(int64(count))
+			continue
+		}
+
+		pi := protocol.NewPublishInternal(channel, message).Command(ctx)
+		rc := s.client.Get(member.String())
+		err := rc.Process(ctx, pi)
+		if err != nil {
+			return 0, err
+		}
+		pcount, err := pi.Result()
+		if err != nil {
+			return 0, err
+		}
+		total += int(pcount)
+		PublishedTotal.Increase(pcount)
+	}
+	return total, nil
+}
+
 func NewService(e *environment.Environment) (service.Service, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	ps := &PubSub{
@@ -0,0 +1,224 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) RegisterHandlers() {
+	s.server.ServeMux().HandleFunc(protocol.Queue.Push, s.pushCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Queue.Pop, s.popCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Queue.BPop, s.bpopCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Queue.Ack, s.ackCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Queue.Len, s.lenCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Internal.MoveQueue, s.moveQueueCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Internal.ReplicateQueue, s.replicateQueueCommandHandler)
+}
+
+func writeMessage(conn redcon.Conn, m *Message, err error) {
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	data, err := msgpack.Marshal(m)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteBulk(data)
+}
+
+func (s *Service) pushCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	pushCmd, err := protocol.ParseQueuePushCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	q, err := s.NewQueue(pushCmd.Queue)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	// The argument buffer belongs to redcon.
+	value := make([]byte, len(pushCmd.Value))
+	copy(value, pushCmd.Value)
+	id, err := q.Push(s.ctx, value)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt64(int64(id))
+}
+
+func (s *Service) popCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	popCmd, err := protocol.ParseQueuePopCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	q, err := s.NewQueue(popCmd.Queue)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	m, err := q.Pop(s.ctx, time.Duration(popCmd.Visibility)*time.Millisecond)
+	writeMessage(conn, m, err)
+}
+
+func (s *Service) bpopCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	bpopCmd, err := protocol.ParseQueueBPopCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	q, err := s.NewQueue(bpopCmd.Queue)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ctx, cancel := server.CommandContext(s.ctx, conn)
+	defer cancel()
+
+	visibility := time.Duration(bpopCmd.Visibility) * time.Millisecond
+	wait := time.Duration(bpopCmd.Timeout) * time.Millisecond
+	m, err := q.bpop(ctx, visibility, wait)
+	writeMessage(conn, m, err)
+}
+
+func (s *Service) ackCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	ackCmd, err := protocol.ParseQueueAckCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	q, err := s.NewQueue(ackCmd.Queue)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = q.Ack(s.ctx, ackCmd.ID, ackCmd.Delivery)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (s *Service) lenCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	lenCmd, err := protocol.ParseQueueLenCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	q, err := s.NewQueue(lenCmd.Queue)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	length, err := q.Len(s.ctx)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(length)
+}
+
+func (s *Service) moveQueueCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	moveQueueCmd, err := protocol.ParseMoveQueueCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	qp := &queuePack{}
+	err = msgpack.Unmarshal(moveQueueCmd.Payload, qp)
+	if err != nil {
+		s.log.V(2).Printf("[ERROR] Failed to unmarshal queue pack: %v", err)
+		protocol.WriteError(conn, err)
+		return
+	}
+	if qp.PartID >= s.config.PartitionCount {
+		protocol.WriteError(conn, fmt.Errorf("invalid partition id: %d", qp.PartID))
+		return
+	}
+
+	var part *partitions.Partition
+	if qp.Kind == partitions.PRIMARY {
+		part = s.primary.PartitionByID(qp.PartID)
+		if !part.Owner().CompareByName(s.rt.This()) {
+			protocol.WriteError(conn, fmt.Errorf("partition owner is not this node: %d", qp.PartID))
+			return
+		}
+	} else {
+		part = s.backup.PartitionByID(qp.PartID)
+		if !s.isBackupOwner(part) {
+			protocol.WriteError(conn, fmt.Errorf("backup owner is not this node: %d", qp.PartID))
+			return
+		}
+	}
+
+	s.log.V(2).Printf("[INFO] Received Queue (kind: %s): %s on PartID: %d", qp.Kind, qp.Name, qp.PartID)
+	s.loadOrCreateLog(part, qp.Name).merge(qp)
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (s *Service) isBackupOwner(part *partitions.Partition) bool {
+	for _, owner := range part.Owners() {
+		if owner.CompareByName(s.rt.This()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) replicateQueueCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	replicateQueueCmd, err := protocol.ParseReplicateQueueCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	op := &queueOp{}
+	err = msgpack.Unmarshal(replicateQueueCmd.Payload, op)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	if op.PartID >= s.config.PartitionCount || op.Message == nil {
+		protocol.WriteError(conn, fmt.Errorf("invalid queue change: %s on PartID: %d", op.Name, op.PartID))
+		return
+	}
+
+	part := s.backup.PartitionByID(op.PartID)
+	if !s.isBackupOwner(part) {
+		protocol.WriteError(conn, fmt.Errorf("backup owner is not this node: %d", op.PartID))
+		return
+	}
+	s.loadOrCreateLog(part, op.Name).apply(op)
+	conn.WriteString(protocol.StatusOK)
+}
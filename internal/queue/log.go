@@ -0,0 +1,400 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Message is an item of a queue. Delivery is incremented every time the message
+// is popped, it identifies the consumer that is allowed to acknowledge it.
+type Message struct {
+	ID        uint64 `msgpack:"id"`
+	Delivery  uint64 `msgpack:"delivery"`
+	Value     []byte `msgpack:"value"`
+	VisibleAt int64  `msgpack:"visible_at"`
+}
+
+// queuePack is used to move a queue to the new partition owner and to copy it
+// to the backup owners.
+type queuePack struct {
+	PartID   uint64          `msgpack:"part_id"`
+	Kind     partitions.Kind `msgpack:"kind"`
+	Name     string          `msgpack:"name"`
+	Sequence uint64          `msgpack:"sequence"`
+	Messages []*Message      `msgpack:"messages"`
+}
+
+// queueOp is a change of a single message. The partition owner sends it to the
+// backup owners before applying it locally. Deleted messages are removed from
+// the log, the others replace the stored copy of the message.
+type queueOp struct {
+	PartID   uint64   `msgpack:"part_id"`
+	Name     string   `msgpack:"name"`
+	Sequence uint64   `msgpack:"sequence"`
+	Message  *Message `msgpack:"message"`
+	Deleted  bool     `msgpack:"deleted"`
+}
+
+// queueLog is the append-only log of a queue. It lives in the primary
+// partition that owns the queue name and its backups. Popped messages stay in
+// the log as in-flight until they are acknowledged or their visibility timeout
+// expires.
+type queueLog struct {
+	mtx      sync.Mutex
+	name     string
+	kind     partitions.Kind
+	sequence uint64
+	ready    []*Message
+	inflight map[uint64]*Message
+	inuse    int
+	// notify is closed when messages are added to the log.
+	notify  chan struct{}
+	service *Service
+}
+
+func newQueueLog(s *Service, name string, kind partitions.Kind) *queueLog {
+	return &queueLog{
+		name:     name,
+		kind:     kind,
+		inflight: make(map[uint64]*Message),
+		notify:   make(chan struct{}),
+		service:  s,
+	}
+}
+
+// broadcast wakes up the blocking pops that wait for a message.
+func (l *queueLog) broadcast() {
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// replicate sends a change to the backup owners. Only the primary copy of a
+// queue is replicated.
+func (l *queueLog) replicate(m *Message, deleted bool) error {
+	if l.service == nil || l.kind == partitions.BACKUP {
+		return nil
+	}
+	sequence := l.sequence
+	if m.ID > sequence {
+		// A new message.
+		sequence = m.ID
+	}
+	return l.service.replicate(partitions.HKey("queue", l.name), &queueOp{
+		Name:     l.name,
+		Sequence: sequence,
+		Message:  m,
+		Deleted:  deleted,
+	})
+}
+
+// push appends a message to the log. IDs are derived from the clock, so the
+// messages that are pushed on a new partition owner come after the ones moved
+// from the previous owner.
+func (l *queueLog) push(value []byte) (uint64, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	id := l.sequence + 1
+	if now := uint64(time.Now().UnixNano()); now > id {
+		id = now
+	}
+	m := &Message{ID: id, Value: value}
+	if err := l.replicate(m, false); err != nil {
+		return 0, err
+	}
+	l.sequence = id
+	l.ready = append(l.ready, m)
+	l.inuse += len(value)
+	l.broadcast()
+	return id, nil
+}
+
+// requeueExpired returns the in-flight messages whose visibility timeout
+// expired to the ready messages, in their original order.
+func (l *queueLog) requeueExpired(now int64) {
+	var expired bool
+	for id, m := range l.inflight {
+		if m.VisibleAt <= now {
+			delete(l.inflight, id)
+			m.VisibleAt = 0
+			l.ready = append(l.ready, m)
+			expired = true
+		}
+	}
+	if expired {
+		sort.Slice(l.ready, func(i, j int) bool { return l.ready[i].ID < l.ready[j].ID })
+	}
+}
+
+// pop returns the oldest visible message. If visibility is zero, the message
+// is removed from the log immediately, it doesn't have to be acknowledged.
+func (l *queueLog) pop(visibility time.Duration) (*Message, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.popLocked(time.Now().UnixNano(), visibility)
+}
+
+// popOrWait pops a message like pop. If there is no visible message, it returns
+// a channel that is closed when a message is added to the log and the duration
+// until the next in-flight message becomes visible again. The duration is zero
+// if there is no in-flight message.
+func (l *queueLog) popOrWait(visibility time.Duration) (*Message, <-chan struct{}, time.Duration, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now().UnixNano()
+	m, err := l.popLocked(now, visibility)
+	if !errors.Is(err, ErrQueueEmpty) {
+		return m, nil, 0, err
+	}
+
+	var next int64
+	for _, m := range l.inflight {
+		if next == 0 || m.VisibleAt < next {
+			next = m.VisibleAt
+		}
+	}
+	var wait time.Duration
+	if next != 0 {
+		wait = time.Duration(next - now)
+	}
+	return nil, l.notify, wait, ErrQueueEmpty
+}
+
+func (l *queueLog) popLocked(now int64, visibility time.Duration) (*Message, error) {
+	l.requeueExpired(now)
+	if len(l.ready) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	m := l.ready[0]
+	next := *m
+	next.Delivery++
+	if visibility != 0 {
+		next.VisibleAt = now + visibility.Nanoseconds()
+	}
+	if err := l.replicate(&next, visibility == 0); err != nil {
+		return nil, err
+	}
+
+	*m = next
+	l.ready[0] = nil
+	l.ready = l.ready[1:]
+	if visibility == 0 {
+		l.inuse -= len(m.Value)
+	} else {
+		l.inflight[m.ID] = m
+	}
+
+	result := *m
+	return &result, nil
+}
+
+func (l *queueLog) ack(id, delivery uint64) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	m, ok := l.inflight[id]
+	if !ok || m.Delivery != delivery {
+		// Already acknowledged or delivered to another consumer.
+		return ErrNoSuchMessage
+	}
+	if err := l.replicate(&Message{ID: id}, true); err != nil {
+		return err
+	}
+	delete(l.inflight, id)
+	l.inuse -= len(m.Value)
+	return nil
+}
+
+// apply applies a change that is received from the partition owner to a backup.
+func (l *queueLog) apply(op *queueOp) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	id := op.Message.ID
+	if m, ok := l.inflight[id]; ok {
+		delete(l.inflight, id)
+		l.inuse -= len(m.Value)
+	} else {
+		for i, m := range l.ready {
+			if m.ID == id {
+				l.ready = append(l.ready[:i], l.ready[i+1:]...)
+				l.inuse -= len(m.Value)
+				break
+			}
+		}
+	}
+	if op.Sequence > l.sequence {
+		l.sequence = op.Sequence
+	}
+	if op.Deleted {
+		return
+	}
+
+	m := op.Message
+	if m.VisibleAt != 0 {
+		l.inflight[id] = m
+	} else {
+		i := sort.Search(len(l.ready), func(i int) bool { return l.ready[i].ID > id })
+		l.ready = append(l.ready, nil)
+		copy(l.ready[i+1:], l.ready[i:])
+		l.ready[i] = m
+	}
+	l.inuse += len(m.Value)
+}
+
+func (l *queueLog) length() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return len(l.ready) + len(l.inflight)
+}
+
+// merge adds the messages of a moved queue to the log. Messages that are
+// already in the log are skipped.
+func (l *queueLog) merge(qp *queuePack) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	exists := make(map[uint64]struct{})
+	for _, m := range l.ready {
+		exists[m.ID] = struct{}{}
+	}
+	for id := range l.inflight {
+		exists[id] = struct{}{}
+	}
+
+	for _, m := range qp.Messages {
+		if _, ok := exists[m.ID]; ok {
+			continue
+		}
+		if m.VisibleAt != 0 {
+			l.inflight[m.ID] = m
+		} else {
+			l.ready = append(l.ready, m)
+		}
+		l.inuse += len(m.Value)
+	}
+	sort.Slice(l.ready, func(i, j int) bool { return l.ready[i].ID < l.ready[j].ID })
+	if qp.Sequence > l.sequence {
+		l.sequence = qp.Sequence
+	}
+	l.broadcast()
+}
+
+func (l *queueLog) Name() string {
+	return "Queue"
+}
+
+func (l *queueLog) Stats() storage.Stats {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return storage.Stats{
+		Length: len(l.ready) + len(l.inflight),
+		Inuse:  l.inuse,
+	}
+}
+
+// transfer sends the whole queue to the owners as a queue of the given partition.
+// The caller has to hold the lock.
+func (l *queueLog) transfer(part *partitions.Partition, owners []discovery.Member) error {
+	qp := &queuePack{
+		PartID:   part.ID(),
+		Kind:     part.Kind(),
+		Name:     l.name,
+		Sequence: l.sequence,
+	}
+	qp.Messages = append(qp.Messages, l.ready...)
+	for _, m := range l.inflight {
+		qp.Messages = append(qp.Messages, m)
+	}
+	value, err := msgpack.Marshal(qp)
+	if err != nil {
+		return err
+	}
+
+	for _, owner := range owners {
+		cmd := protocol.NewMoveQueue(value).Command(l.service.ctx)
+		rc := l.service.client.Get(owner.String())
+		err = rc.Process(l.service.ctx, cmd)
+		if err != nil {
+			return err
+		}
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move sends the whole queue to the new partition owner and deletes the local copy.
+func (l *queueLog) Move(part *partitions.Partition, _ string, owners []discovery.Member) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if err := l.transfer(part, owners); err != nil {
+		return err
+	}
+
+	l.ready = nil
+	l.inflight = make(map[uint64]*Message)
+	l.inuse = 0
+	part.Map().Delete(fragmentName(l.name))
+	// The blocking pops retry on the new owner.
+	l.broadcast()
+	return nil
+}
+
+// Copy sends the whole queue to the owners as a queue of the given partition.
+// The local copy is left untouched. It's used to re-create the backups that
+// are lost with a dead member.
+func (l *queueLog) Copy(part *partitions.Partition, _ string, owners []discovery.Member) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.transfer(part, owners)
+}
+
+func (l *queueLog) Compaction() (bool, error) {
+	return false, nil
+}
+
+func (l *queueLog) Destroy() error {
+	return nil
+}
+
+func (l *queueLog) Close() error {
+	return nil
+}
+
+func fragmentName(name string) string {
+	return fmt.Sprintf("queue.%s", name)
+}
+
+var _ partitions.Fragment = (*queueLog)(nil)
@@ -0,0 +1,168 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueLog_PushPop(t *testing.T) {
+	l := newQueueLog(nil, "jobs", partitions.PRIMARY)
+	first, err := l.push([]byte("first"))
+	require.NoError(t, err)
+	second, err := l.push([]byte("second"))
+	require.NoError(t, err)
+	require.Less(t, first, second)
+
+	m, err := l.pop(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, first, m.ID)
+	require.Equal(t, uint64(1), m.Delivery)
+	require.Equal(t, []byte("first"), m.Value)
+
+	m, err = l.pop(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, second, m.ID)
+
+	_, err = l.pop(time.Minute)
+	require.ErrorIs(t, err, ErrQueueEmpty)
+	require.Equal(t, 2, l.length())
+}
+
+func TestQueueLog_Ack(t *testing.T) {
+	l := newQueueLog(nil, "jobs", partitions.PRIMARY)
+	_, err := l.push([]byte("value"))
+	require.NoError(t, err)
+
+	m, err := l.pop(time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, l.ack(m.ID, m.Delivery))
+	require.Equal(t, 0, l.length())
+	require.Equal(t, 0, l.Stats().Inuse)
+
+	require.ErrorIs(t, l.ack(m.ID, m.Delivery), ErrNoSuchMessage)
+}
+
+func TestQueueLog_VisibilityTimeout(t *testing.T) {
+	l := newQueueLog(nil, "jobs", partitions.PRIMARY)
+	first, err := l.push([]byte("first"))
+	require.NoError(t, err)
+	_, err = l.push([]byte("second"))
+	require.NoError(t, err)
+
+	m, err := l.pop(time.Millisecond)
+	require.NoError(t, err)
+	<-time.After(5 * time.Millisecond)
+
+	// The first message is visible again and delivered before the second one.
+	redelivered, err := l.pop(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, first, redelivered.ID)
+	require.Equal(t, uint64(2), redelivered.Delivery)
+
+	// The first consumer cannot acknowledge it anymore.
+	require.ErrorIs(t, l.ack(m.ID, m.Delivery), ErrNoSuchMessage)
+	require.NoError(t, l.ack(redelivered.ID, redelivered.Delivery))
+}
+
+func TestQueueLog_Pop_Without_Visibility(t *testing.T) {
+	l := newQueueLog(nil, "jobs", partitions.PRIMARY)
+	_, err := l.push([]byte("value"))
+	require.NoError(t, err)
+
+	_, err = l.pop(0)
+	require.NoError(t, err)
+	require.Equal(t, 0, l.length())
+}
+
+func TestQueueLog_Merge(t *testing.T) {
+	l := newQueueLog(nil, "jobs", partitions.PRIMARY)
+	id, err := l.push([]byte("local"))
+	require.NoError(t, err)
+
+	qp := &queuePack{
+		Name:     "jobs",
+		Sequence: id + 10,
+		Messages: []*Message{
+			{ID: id - 1, Value: []byte("moved")},
+			{ID: id, Value: []byte("local")},
+		},
+	}
+	l.merge(qp)
+	require.Equal(t, 2, l.length())
+
+	m, err := l.pop(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []byte("moved"), m.Value)
+	next, err := l.push([]byte("new"))
+	require.NoError(t, err)
+	require.Greater(t, next, id+10)
+}
+
+func TestQueueLog_Apply(t *testing.T) {
+	primary := newQueueLog(nil, "jobs", partitions.PRIMARY)
+	backup := newQueueLog(nil, "jobs", partitions.BACKUP)
+	first, err := primary.push([]byte("first"))
+	require.NoError(t, err)
+	second, err := primary.push([]byte("second"))
+	require.NoError(t, err)
+
+	// Changes may arrive in any order, the last one wins.
+	backup.apply(&queueOp{Sequence: second, Message: &Message{ID: second, Value: []byte("second")}})
+	backup.apply(&queueOp{Sequence: first, Message: &Message{ID: first, Value: []byte("first")}})
+	require.Equal(t, 2, backup.length())
+
+	m, err := primary.pop(time.Minute)
+	require.NoError(t, err)
+	backup.apply(&queueOp{Sequence: second, Message: m})
+	require.Equal(t, 2, backup.length())
+
+	// The in-flight message is not visible on the backup.
+	popped, err := backup.pop(time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, second, popped.ID)
+
+	backup.apply(&queueOp{Sequence: second, Message: &Message{ID: first}, Deleted: true})
+	require.Equal(t, 1, backup.length())
+	require.Equal(t, len("second"), backup.Stats().Inuse)
+}
+
+func TestQueueLog_PopOrWait(t *testing.T) {
+	l := newQueueLog(nil, "jobs", partitions.PRIMARY)
+
+	_, notify, next, err := l.popOrWait(time.Minute)
+	require.ErrorIs(t, err, ErrQueueEmpty)
+	require.Equal(t, time.Duration(0), next)
+
+	_, err = l.push([]byte("value"))
+	require.NoError(t, err)
+	select {
+	case <-notify:
+	default:
+		require.Fail(t, "push didn't notify the waiters")
+	}
+
+	_, _, _, err = l.popOrWait(time.Minute)
+	require.NoError(t, err)
+
+	// The in-flight message becomes visible again in a minute.
+	_, _, next, err = l.popOrWait(time.Minute)
+	require.ErrorIs(t, err, ErrQueueEmpty)
+	require.Greater(t, next, 59*time.Second)
+}
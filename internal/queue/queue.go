@@ -0,0 +1,370 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// maxBlockingWait is the longest time that a blocking pop waits on the
+// partition owner in a single request. Longer waits are split into several
+// requests to stay below the read timeout of the internal client.
+const maxBlockingWait = time.Second
+
+// Queue is a FIFO queue that is stored on the owner of the partition that its
+// name belongs to. Multiple consumers can pop messages from a queue. A popped
+// message is hidden from the other consumers until its visibility timeout
+// expires. It has to be acknowledged to be deleted permanently.
+type Queue struct {
+	name string
+	hkey uint64
+	s    *Service
+}
+
+// NewQueue creates and returns a new Queue instance. The queue is created on
+// its partition owner when the first message is pushed.
+func (s *Service) NewQueue(name string) (*Queue, error) {
+	return &Queue{
+		name: name,
+		hkey: partitions.HKey("queue", name),
+		s:    s,
+	}, nil
+}
+
+func (s *Service) loadOrCreateLog(part *partitions.Partition, name string) *queueLog {
+	part.Lock()
+	defer part.Unlock()
+
+	l, ok := part.Map().Load(fragmentName(name))
+	if ok {
+		return l.(*queueLog)
+	}
+	if part.Kind() == partitions.PRIMARY {
+		if l, ok := s.promoteBackup(part, name); ok {
+			return l
+		}
+	}
+	ql := newQueueLog(s, name, part.Kind())
+	part.Map().Store(fragmentName(name), ql)
+	return ql
+}
+
+func (s *Service) loadLog(part *partitions.Partition, name string) (*queueLog, bool) {
+	l, ok := part.Map().Load(fragmentName(name))
+	if ok {
+		return l.(*queueLog), true
+	}
+
+	part.Lock()
+	defer part.Unlock()
+
+	l, ok = part.Map().Load(fragmentName(name))
+	if ok {
+		return l.(*queueLog), true
+	}
+	return s.promoteBackup(part, name)
+}
+
+// promoteBackup moves the backup of a queue to the primary partition. It's the
+// only copy of the queue if the previous partition owner is gone. The caller
+// has to hold the lock of the primary partition.
+func (s *Service) promoteBackup(part *partitions.Partition, name string) (*queueLog, bool) {
+	backup := s.backup.PartitionByID(part.ID())
+	l, ok := backup.Map().Load(fragmentName(name))
+	if !ok {
+		return nil, false
+	}
+	backup.Map().Delete(fragmentName(name))
+
+	ql := l.(*queueLog)
+	ql.mtx.Lock()
+	ql.kind = partitions.PRIMARY
+	ql.mtx.Unlock()
+	part.Map().Store(fragmentName(name), ql)
+	s.log.V(2).Printf("[INFO] Backup of Queue: %s is promoted on PartID: %d", name, part.ID())
+	return ql, true
+}
+
+// replicate sends a change of a queue to the backup owners of its partition.
+// The errors are only logged in the async replication mode.
+func (s *Service) replicate(hkey uint64, op *queueOp) error {
+	owners := s.backup.PartitionOwnersByHKey(hkey)
+	if len(owners) == 0 {
+		return nil
+	}
+
+	op.PartID = s.backup.PartitionByHKey(hkey).ID()
+	value, err := msgpack.Marshal(op)
+	if err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		cmd := protocol.NewReplicateQueue(value).Command(s.ctx)
+		rc := s.client.Get(owner.String())
+		err := rc.Process(s.ctx, cmd)
+		if err == nil {
+			err = cmd.Err()
+		}
+		if err != nil {
+			err = protocol.ConvertError(err)
+			if s.config.ReplicationMode == config.AsyncReplicationMode {
+				s.log.V(3).Printf("[ERROR] Failed to replicate Queue: %s to %s: %v", op.Name, owner, err)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the queue.
+func (q *Queue) Name() string {
+	return q.name
+}
+
+func (q *Queue) owner() (*partitions.Partition, bool) {
+	part := q.s.primary.PartitionByHKey(q.hkey)
+	return part, part.Owner().CompareByName(q.s.rt.This())
+}
+
+// Push appends a message to the end of the queue and returns its ID.
+func (q *Queue) Push(ctx context.Context, value []byte) (uint64, error) {
+	if err := q.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := q.owner()
+	if ok {
+		return q.s.loadOrCreateLog(part, q.name).push(value)
+	}
+
+	cmd := protocol.NewQueuePush(q.name, value).Command(ctx)
+	rc := q.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	id, err := cmd.Uint64()
+	return id, protocol.ConvertError(err)
+}
+
+func decodeMessage(data string) (*Message, error) {
+	m := &Message{}
+	if err := msgpack.Unmarshal([]byte(data), m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Pop returns the oldest visible message of the queue. The message is hidden
+// until the visibility timeout expires. If visibility is zero, the message is
+// deleted immediately. It returns ErrQueueEmpty if there is no visible message.
+func (q *Queue) Pop(ctx context.Context, visibility time.Duration) (*Message, error) {
+	if err := q.s.rt.CheckBootstrap(); err != nil {
+		return nil, err
+	}
+
+	part, ok := q.owner()
+	if ok {
+		l, ok := q.s.loadLog(part, q.name)
+		if !ok {
+			return nil, ErrQueueEmpty
+		}
+		return l.pop(visibility)
+	}
+
+	cmd := protocol.NewQueuePop(q.name, visibility.Milliseconds()).Command(ctx)
+	rc := q.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	data, err := cmd.Result()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return decodeMessage(data)
+}
+
+// BPop is the blocking version of Pop. It waits until a message is visible,
+// timeout expires or ctx is done. Zero timeout means waiting until ctx is done.
+// It returns ErrQueueEmpty if timeout expires and ctx.Err() if ctx is done.
+func (q *Queue) BPop(ctx context.Context, visibility, timeout time.Duration) (*Message, error) {
+	// The deadline is not assigned to ctx. The partition owner returns
+	// ErrQueueEmpty when the wait expires, and the request should not be
+	// canceled by the client at the same time.
+	var deadline time.Time
+	if timeout != 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		wait := maxBlockingWait
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, ErrQueueEmpty
+			}
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+
+		m, err := q.bpop(ctx, visibility, wait)
+		if !errors.Is(err, ErrQueueEmpty) {
+			return m, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.s.ctx.Done():
+			return nil, ErrQueueEmpty
+		default:
+		}
+	}
+}
+
+// bpop waits for a message at most for the given duration on the partition owner.
+func (q *Queue) bpop(ctx context.Context, visibility, wait time.Duration) (*Message, error) {
+	if err := q.s.rt.CheckBootstrap(); err != nil {
+		return nil, err
+	}
+
+	part, ok := q.owner()
+	if ok {
+		return q.waitForMessage(ctx, part, visibility, wait)
+	}
+
+	cmd := protocol.NewQueueBPop(q.name, visibility.Milliseconds(), wait.Milliseconds()).Command(ctx)
+	rc := q.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The request is canceled while waiting on the partition owner.
+			return nil, ctx.Err()
+		}
+		return nil, protocol.ConvertError(err)
+	}
+	data, err := cmd.Result()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return decodeMessage(data)
+}
+
+// waitForMessage pops a message on the partition owner. If the queue is empty,
+// it waits until a message is pushed or an in-flight message becomes visible
+// again. It returns ErrQueueEmpty if wait expires.
+func (q *Queue) waitForMessage(ctx context.Context, part *partitions.Partition, visibility, wait time.Duration) (*Message, error) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		if !part.Owner().CompareByName(q.s.rt.This()) {
+			// The queue is moved. The caller retries on the new owner.
+			return nil, ErrQueueEmpty
+		}
+
+		m, notify, next, err := q.s.loadOrCreateLog(part, q.name).popOrWait(visibility)
+		if !errors.Is(err, ErrQueueEmpty) {
+			return m, err
+		}
+
+		// A nil channel blocks forever if there is no in-flight message.
+		var visible <-chan time.Time
+		var timer *time.Timer
+		if next > 0 {
+			timer = time.NewTimer(next)
+			visible = timer.C
+		}
+
+		err = nil
+		select {
+		case <-notify:
+		case <-visible:
+		case <-timeout.C:
+			err = ErrQueueEmpty
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-q.s.ctx.Done():
+			err = ErrQueueEmpty
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Ack deletes a popped message permanently. It returns ErrNoSuchMessage if the
+// message is already acknowledged or its visibility timeout expired and it has
+// been delivered again.
+func (q *Queue) Ack(ctx context.Context, id, delivery uint64) error {
+	if err := q.s.rt.CheckBootstrap(); err != nil {
+		return err
+	}
+
+	part, ok := q.owner()
+	if ok {
+		l, ok := q.s.loadLog(part, q.name)
+		if !ok {
+			return ErrNoSuchMessage
+		}
+		return l.ack(id, delivery)
+	}
+
+	cmd := protocol.NewQueueAck(q.name, id, delivery).Command(ctx)
+	rc := q.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// Len returns the number of messages in the queue, including the in-flight ones.
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	if err := q.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := q.owner()
+	if ok {
+		l, ok := q.s.loadLog(part, q.name)
+		if !ok {
+			return 0, nil
+		}
+		return l.length(), nil
+	}
+
+	cmd := protocol.NewQueueLen(q.name).Command(ctx)
+	rc := q.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	length, err := cmd.Result()
+	return int(length), protocol.ConvertError(err)
+}
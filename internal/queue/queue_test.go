@@ -0,0 +1,203 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		q1, err := s1.NewQueue("jobs." + strconv.Itoa(i))
+		require.NoError(t, err)
+		_, err = q1.Push(ctx, []byte("value."+strconv.Itoa(i)))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		q2, err := s2.NewQueue("jobs." + strconv.Itoa(i))
+		require.NoError(t, err)
+
+		length, err := q2.Len(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, length)
+
+		m, err := q2.Pop(ctx, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, []byte("value."+strconv.Itoa(i)), m.Value)
+
+		_, err = q2.Pop(ctx, time.Minute)
+		require.ErrorIs(t, err, ErrQueueEmpty)
+
+		require.NoError(t, q2.Ack(ctx, m.ID, m.Delivery))
+		require.ErrorIs(t, q2.Ack(ctx, m.ID, m.Delivery), ErrNoSuchMessage)
+	}
+}
+
+func TestQueue_BPop(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		name := "jobs." + strconv.Itoa(i)
+		q1, err := s1.NewQueue(name)
+		require.NoError(t, err)
+		q2, err := s2.NewQueue(name)
+		require.NoError(t, err)
+
+		_, err = q2.BPop(ctx, 0, 10*time.Millisecond)
+		require.ErrorIs(t, err, ErrQueueEmpty)
+
+		go func() {
+			<-time.After(20 * time.Millisecond)
+			_, err := q1.Push(ctx, []byte(name))
+			require.NoError(t, err)
+		}()
+
+		m, err := q2.BPop(ctx, 0, 5*time.Second)
+		require.NoError(t, err)
+		require.Equal(t, []byte(name), m.Value)
+	}
+}
+
+func TestQueue_Move(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		q1, err := s1.NewQueue("jobs." + strconv.Itoa(i))
+		require.NoError(t, err)
+		for j := 0; j < 3; j++ {
+			_, err = q1.Push(ctx, []byte(strconv.Itoa(j)))
+			require.NoError(t, err)
+		}
+		// Leave one of the messages in-flight.
+		_, err = q1.Pop(ctx, time.Minute)
+		require.NoError(t, err)
+	}
+
+	// This automatically syncs the cluster and moves the queues.
+	s2 := cluster.AddMember(nil).(*Service)
+
+	for i := 0; i < 10; i++ {
+		q2, err := s2.NewQueue("jobs." + strconv.Itoa(i))
+		require.NoError(t, err)
+
+		length, err := q2.Len(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 3, length)
+
+		m, err := q2.Pop(ctx, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, []byte("1"), m.Value)
+	}
+}
+
+func TestQueue_BPop_Context(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	for _, s := range []*Service{s1, s2} {
+		q, err := s.NewQueue("jobs")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err = q.BPop(ctx, 0, 0)
+		cancel()
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueue_Replication(t *testing.T) {
+	newEnvironment := func() *environment.Environment {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		return testcluster.NewEnvironment(c)
+	}
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(newEnvironment()).(*Service)
+	s2 := cluster.AddMember(newEnvironment()).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	q, err := s1.NewQueue("jobs")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = q.Push(ctx, []byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+	}
+	first, err := q.Pop(ctx, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, q.Ack(ctx, first.ID, first.Delivery))
+	_, err = q.Pop(ctx, time.Minute)
+	require.NoError(t, err)
+
+	var backup *queueLog
+	for _, s := range []*Service{s1, s2} {
+		part := s.backup.PartitionByHKey(q.hkey)
+		if l, ok := part.Map().Load(fragmentName("jobs")); ok {
+			backup = l.(*queueLog)
+		}
+	}
+	require.NotNil(t, backup)
+	require.Equal(t, 2, backup.length())
+	require.Len(t, backup.inflight, 1)
+}
+
+func TestQueue_Promote_Backup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	q, err := s.NewQueue("jobs")
+	require.NoError(t, err)
+
+	// The previous owner is gone, only the backup is left on this member.
+	part := s.backup.PartitionByHKey(q.hkey)
+	l := s.loadOrCreateLog(part, "jobs")
+	l.apply(&queueOp{Sequence: 1, Message: &Message{ID: 1, Value: []byte("value")}})
+
+	ctx := context.Background()
+	m, err := q.Pop(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), m.Value)
+
+	_, ok := part.Map().Load(fragmentName("jobs"))
+	require.False(t, ok)
+	id, err := q.Push(ctx, []byte("next"))
+	require.NoError(t, err)
+	require.Greater(t, id, uint64(1))
+}
@@ -0,0 +1,126 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit paces the background operations that move or import the
+// data of many partitions, so they can run against a live cluster. It also
+// throttles the requests of the clients.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spreads the units of work, bytes or entries, over time to keep their
+// rate under the given limit. Every call reserves a time slot in proportion to
+// its size. The zero value is ready to use.
+type Limiter struct {
+	mtx  sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until the caller is allowed to process n units. rate is in units
+// per second, zero or a negative value disables the limit.
+func (l *Limiter) Wait(ctx context.Context, rate int64, n int) error {
+	if rate <= 0 || n <= 0 {
+		return nil
+	}
+	return sleep(ctx, l.reserve(rate, n))
+}
+
+// Reserve takes n units without waiting, the next callers wait for them. rate
+// is in units per second, zero or a negative value disables the limit.
+func (l *Limiter) Reserve(rate int64, n int) {
+	if rate <= 0 || n <= 0 {
+		return
+	}
+	l.reserve(rate, n)
+}
+
+// WaitIdle blocks until the units that are taken by the previous callers are
+// processed. It doesn't reserve anything.
+func (l *Limiter) WaitIdle(ctx context.Context) error {
+	l.mtx.Lock()
+	delay := time.Until(l.next)
+	l.mtx.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return sleep(ctx, delay)
+}
+
+// reserve takes n units and returns the time to wait for the previous ones.
+func (l *Limiter) reserve(rate int64, n int) time.Duration {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	return delay
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	return nil
+}
+
+// Allow reports whether n units can be processed now without exceeding rate
+// units per second. It allows bursts of up to rate units. Nothing is reserved
+// if it returns false. rate is in units per second, zero or a negative value
+// disables the limit.
+func (l *Limiter) Allow(rate int64, n int) bool {
+	if rate <= 0 || n <= 0 {
+		return true
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	next := l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	if next.Sub(now) > time.Second {
+		return false
+	}
+	l.next = next
+	return true
+}
+
+// Idle reports whether the limiter has no reservation in the future, so it's
+// equivalent to a new one.
+func (l *Limiter) Idle() bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return !l.next.After(time.Now())
+}
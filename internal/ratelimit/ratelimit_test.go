@@ -0,0 +1,84 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := &Limiter{}
+	ctx := context.Background()
+
+	start := time.Now()
+	// 1000 units/s, the second call has to wait for the first one.
+	require.NoError(t, l.Wait(ctx, 1000, 100))
+	require.NoError(t, l.Wait(ctx, 1000, 100))
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+
+	t.Run("Canceled", func(t *testing.T) {
+		require.NoError(t, l.Wait(ctx, 1, 10))
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		require.ErrorIs(t, l.Wait(cctx, 1, 10), context.Canceled)
+	})
+
+	t.Run("No limit", func(t *testing.T) {
+		require.NoError(t, l.Wait(ctx, 0, 1<<30))
+	})
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	l := &Limiter{}
+	ctx := context.Background()
+
+	// Reserve doesn't wait, WaitIdle waits for the reserved units.
+	start := time.Now()
+	l.Reserve(1000, 100)
+	require.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+	require.False(t, l.Idle())
+	require.NoError(t, l.WaitIdle(ctx))
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+	require.True(t, l.Idle())
+
+	t.Run("Canceled", func(t *testing.T) {
+		l.Reserve(1, 10)
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		require.ErrorIs(t, l.WaitIdle(cctx), context.Canceled)
+	})
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := &Limiter{}
+
+	// A burst of up to rate units is allowed.
+	for i := 0; i < 10; i++ {
+		require.True(t, l.Allow(10, 1))
+	}
+	require.False(t, l.Allow(10, 1))
+
+	require.Eventually(t, func() bool {
+		return l.Allow(10, 1)
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("No limit", func(t *testing.T) {
+		require.True(t, l.Allow(0, 1<<30))
+	})
+}
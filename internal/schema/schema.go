@@ -0,0 +1,300 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema implements a subset of JSON Schema to validate the values
+// of a DMap. The supported keywords are type, enum, properties, required,
+// additionalProperties, items, minimum, maximum, minLength, maxLength,
+// minItems and maxItems. The other keywords are ignored.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrMismatch is returned when a value doesn't match the schema.
+var ErrMismatch = errors.New("value does not match the schema")
+
+var validTypes = map[string]struct{}{
+	"null":    {},
+	"boolean": {},
+	"object":  {},
+	"array":   {},
+	"number":  {},
+	"integer": {},
+	"string":  {},
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	types                []string
+	enum                 []interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *bool
+	items                *Schema
+	minimum              *float64
+	maximum              *float64
+	minLength            *int
+	maxLength            *int
+	minItems             *int
+	maxItems             *int
+}
+
+type document struct {
+	Type                 interface{}                `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties *bool                      `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+}
+
+// Compile parses a JSON Schema document.
+func Compile(raw string) (*Schema, error) {
+	return compile([]byte(raw))
+}
+
+func compile(raw []byte) (*Schema, error) {
+	var doc document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	s := &Schema{
+		enum:                 doc.Enum,
+		required:             doc.Required,
+		additionalProperties: doc.AdditionalProperties,
+		minimum:              doc.Minimum,
+		maximum:              doc.Maximum,
+		minLength:            doc.MinLength,
+		maxLength:            doc.MaxLength,
+		minItems:             doc.MinItems,
+		maxItems:             doc.MaxItems,
+	}
+
+	switch t := doc.Type.(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid schema: type has to be a string: %v", item)
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("invalid schema: type has to be a string or an array: %v", t)
+	}
+	for _, name := range s.types {
+		if _, ok := validTypes[name]; !ok {
+			return nil, fmt.Errorf("invalid schema: unknown type: %s", name)
+		}
+	}
+
+	if len(doc.Properties) > 0 {
+		s.properties = make(map[string]*Schema)
+		for name, raw := range doc.Properties {
+			p, err := compile(raw)
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = p
+		}
+	}
+	if len(doc.Items) > 0 {
+		items, err := compile(doc.Items)
+		if err != nil {
+			return nil, err
+		}
+		s.items = items
+	}
+	return s, nil
+}
+
+// ValidateBytes decodes a JSON or MessagePack encoded value and validates it.
+func (s *Schema) ValidateBytes(data []byte) error {
+	var value interface{}
+	trimmed := bytes.TrimSpace(data)
+	if json.Valid(trimmed) {
+		if err := json.Unmarshal(trimmed, &value); err != nil {
+			return fmt.Errorf("%w: %v", ErrMismatch, err)
+		}
+	} else if err := msgpack.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: value is not JSON or MessagePack encoded", ErrMismatch)
+	}
+	return s.Validate(value)
+}
+
+// Validate validates a decoded value.
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate("$", value)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if n, ok := toFloat64(value); ok {
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (s *Schema) matchType(actual string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, expected := range s.types {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat64(a); ok {
+		y, ok := toFloat64(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (s *Schema) validate(path string, value interface{}) error {
+	actual := typeOf(value)
+	if !s.matchType(actual) {
+		return fmt.Errorf("%w: %s: expected %v, got %s", ErrMismatch, path, s.types, actual)
+	}
+
+	if s.enum != nil {
+		var found bool
+		for _, item := range s.enum {
+			if equal(item, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s: not one of %v", ErrMismatch, path, s.enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			return fmt.Errorf("%w: %s: shorter than %d", ErrMismatch, path, *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			return fmt.Errorf("%w: %s: longer than %d", ErrMismatch, path, *s.maxLength)
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			return fmt.Errorf("%w: %s: fewer than %d items", ErrMismatch, path, *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			return fmt.Errorf("%w: %s: more than %d items", ErrMismatch, path, *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				if err := s.items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		return s.validateObject(path, v)
+	default:
+		if n, ok := toFloat64(value); ok {
+			if s.minimum != nil && n < *s.minimum {
+				return fmt.Errorf("%w: %s: less than %v", ErrMismatch, path, *s.minimum)
+			}
+			if s.maximum != nil && n > *s.maximum {
+				return fmt.Errorf("%w: %s: greater than %v", ErrMismatch, path, *s.maximum)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}) error {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%w: %s: missing required property: %s", ErrMismatch, path, name)
+		}
+	}
+
+	// Sort the names to return the same error for the same value.
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, ok := s.properties[name]
+		if !ok {
+			if s.additionalProperties != nil && !*s.additionalProperties {
+				return fmt.Errorf("%w: %s: unknown property: %s", ErrMismatch, path, name)
+			}
+			continue
+		}
+		if err := p.validate(path+"."+name, obj[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
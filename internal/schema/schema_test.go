@@ -0,0 +1,92 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["id", "tags"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"score": {"type": "number", "maximum": 10},
+		"name": {"type": "string", "minLength": 1, "maxLength": 8},
+		"status": {"enum": ["active", "passive"]},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+		"parent": {"type": ["integer", "null"]}
+	}
+}`
+
+func TestSchema_ValidateBytes(t *testing.T) {
+	s, err := Compile(testSchema)
+	require.NoError(t, err)
+
+	valid := []string{
+		`{"id": 1, "tags": []}`,
+		`{"id": 1, "score": 9.5, "name": "foo", "status": "active", "tags": ["a", "b"], "parent": null}`,
+		`{"id": 2, "tags": ["a"], "parent": 1}`,
+	}
+	for _, value := range valid {
+		require.NoError(t, s.ValidateBytes([]byte(value)), value)
+	}
+
+	invalid := []string{
+		`[]`,
+		`{"tags": []}`,
+		`{"id": 0, "tags": []}`,
+		`{"id": 1.5, "tags": []}`,
+		`{"id": 1, "tags": [], "score": 11}`,
+		`{"id": 1, "tags": [], "name": ""}`,
+		`{"id": 1, "tags": [], "name": "foobarbaz"}`,
+		`{"id": 1, "tags": [], "status": "deleted"}`,
+		`{"id": 1, "tags": ["a", "b", "c"]}`,
+		`{"id": 1, "tags": [1]}`,
+		`{"id": 1, "tags": [], "parent": "foo"}`,
+		`{"id": 1, "tags": [], "unknown": true}`,
+	}
+	for _, value := range invalid {
+		require.ErrorIs(t, s.ValidateBytes([]byte(value)), ErrMismatch, value)
+	}
+}
+
+func TestSchema_ValidateBytes_Msgpack(t *testing.T) {
+	s, err := Compile(testSchema)
+	require.NoError(t, err)
+
+	value, err := msgpack.Marshal(map[string]interface{}{"id": 1, "tags": []string{"a"}})
+	require.NoError(t, err)
+	require.NoError(t, s.ValidateBytes(value))
+
+	value, err = msgpack.Marshal(map[string]interface{}{"id": -1, "tags": []string{"a"}})
+	require.NoError(t, err)
+	require.ErrorIs(t, s.ValidateBytes(value), ErrMismatch)
+}
+
+func TestSchema_Compile(t *testing.T) {
+	_, err := Compile(`{"type": "foobar"}`)
+	require.Error(t, err)
+
+	_, err = Compile(`{"properties": {"id": {"type": 1}}}`)
+	require.Error(t, err)
+
+	_, err = Compile(`not json`)
+	require.Error(t, err)
+}
@@ -16,10 +16,13 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/roundrobin"
 	"github.com/go-redis/redis/v8"
 )
@@ -29,7 +32,11 @@ type Client struct {
 
 	config     *config.Client
 	clients    map[string]*redis.Client
+	rtts       map[string]*rttEstimator
 	roundRobin *roundrobin.RoundRobin
+
+	// failures counts the consecutive failed health-check pings, by address.
+	failures map[string]int
 }
 
 func NewClient(c *config.Client) *Client {
@@ -43,7 +50,9 @@ func NewClient(c *config.Client) *Client {
 	return &Client{
 		config:     c,
 		clients:    make(map[string]*redis.Client),
+		rtts:       make(map[string]*rttEstimator),
 		roundRobin: roundrobin.New(nil),
+		failures:   make(map[string]int),
 	}
 }
 
@@ -80,12 +89,134 @@ func (c *Client) Get(addr string) *redis.Client {
 
 	opt := c.config.RedisOptions()
 	opt.Addr = addr
+	onConnect := opt.OnConnect
+	opt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		// The member serves the commands on this connection as the commands
+		// of a cluster member, not a client.
+		cmd := protocol.NewMember().Command(ctx)
+		if err := cn.Process(ctx, cmd); err != nil {
+			return protocol.ConvertError(err)
+		}
+		if onConnect != nil {
+			return onConnect(ctx, cn)
+		}
+		return nil
+	}
+	if c.config.AdaptiveTimeout {
+		// The timeouts are assigned to the commands by rttHook.
+		opt.ReadTimeout = c.config.MaxAdaptiveTimeout
+		opt.WriteTimeout = c.config.MaxAdaptiveTimeout
+	}
+	estimator, ok := c.rtts[addr]
+	if !ok {
+		estimator = &rttEstimator{}
+		c.rtts[addr] = estimator
+	}
 	rc = redis.NewClient(opt)
+	rc.AddHook(&rttHook{config: c.config, estimator: estimator})
+	// It has to be added after rttHook to send the adaptive timeout.
+	rc.AddHook(deadlineHook{})
 	c.clients[addr] = rc
 	c.roundRobin.Add(addr)
 	return rc
 }
 
+// Dial creates the pool of the given address and establishes a connection in
+// advance. The pool opens MinIdleConns connections in the background. An error
+// reply of the server is ignored, the connection has been established anyway.
+func (c *Client) Dial(ctx context.Context, addr string) error {
+	rc := c.Get(addr)
+	cmd := protocol.NewPing().Command(ctx)
+	err := rc.Process(ctx, cmd)
+	var rerr redis.Error
+	if errors.As(err, &rerr) {
+		return nil
+	}
+	return err
+}
+
+// HealthCheck pings every member that has a pool. The connection that fails
+// the ping is removed from the pool, the other connections are kept. The pool
+// of a member is closed after HealthCheckFailureThreshold consecutive
+// failures, it's created again on demand. It returns the addresses of the
+// members whose pools have been closed.
+func (c *Client) HealthCheck(ctx context.Context) []string {
+	var closed []string
+	for addr := range c.Addresses() {
+		cmd := protocol.NewPing().Command(ctx)
+		err := c.Get(addr).Process(ctx, cmd)
+		var rerr redis.Error
+		if err == nil || errors.As(err, &rerr) {
+			// The member replied, the connection is healthy.
+			c.mu.Lock()
+			delete(c.failures, addr)
+			c.mu.Unlock()
+			continue
+		}
+		HealthCheckFailuresTotal.Increase(1)
+
+		c.mu.Lock()
+		c.failures[addr]++
+		failures, threshold := c.failures[addr], c.config.HealthCheckFailureThreshold
+		c.mu.Unlock()
+		if failures < threshold {
+			// go-redis has already removed the bad connection from the pool.
+			continue
+		}
+		closed = append(closed, addr)
+		_ = c.Close(addr)
+	}
+	return closed
+}
+
+// PoolStats returns the connection pool statistics, by address.
+func (c *Client) PoolStats() map[string]*redis.PoolStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]*redis.PoolStats)
+	for addr, rc := range c.clients {
+		result[addr] = rc.PoolStats()
+	}
+	return result
+}
+
+// RTTs returns the round-trip time estimates of the members, by address.
+func (c *Client) RTTs() map[string]RTT {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]RTT)
+	for addr, estimator := range c.rtts {
+		result[addr] = estimator.stats(c.config)
+	}
+	return result
+}
+
+// SetConfig replaces the client configuration. Clients are created again with
+// the new configuration on demand. The previous ones are closed after a grace
+// period to let in-flight commands finish.
+func (c *Client) SetConfig(cfg *config.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stale := c.clients
+	grace := c.config.ReadTimeout + c.config.WriteTimeout
+	c.config = cfg
+	c.clients = make(map[string]*redis.Client)
+	c.roundRobin = roundrobin.New(nil)
+	c.failures = make(map[string]int)
+
+	if len(stale) == 0 {
+		return
+	}
+	time.AfterFunc(grace, func() {
+		for _, rc := range stale {
+			_ = rc.Close()
+		}
+	})
+}
+
 func (c *Client) pickNodeRoundRobin() (string, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -120,6 +251,8 @@ func (c *Client) Close(addr string) error {
 		}
 		c.roundRobin.Delete(addr)
 		delete(c.clients, addr)
+		delete(c.rtts, addr)
+		delete(c.failures, addr)
 	}
 
 	return nil
@@ -19,6 +19,7 @@ import (
 	"net"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/protocol"
@@ -141,3 +142,130 @@ func TestServer_Client_Shutdown(t *testing.T) {
 	require.Empty(t, cs.clients)
 	require.Equal(t, 0, cs.roundRobin.Length())
 }
+
+func TestServer_Client_Dial(t *testing.T) {
+	srv := newServer(t)
+	srv.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteBulkString("pong")
+	})
+
+	<-srv.StartedCtx.Done()
+
+	addr := net.JoinHostPort(srv.config.BindAddr, strconv.Itoa(srv.config.BindPort))
+	c := config.NewClient()
+	c.MinIdleConns = 4
+	require.NoError(t, c.Sanitize())
+
+	cs := NewClient(c)
+	require.NoError(t, cs.Dial(context.Background(), addr))
+
+	rc := cs.Get(addr)
+	require.Eventually(t, func() bool {
+		return rc.PoolStats().TotalConns >= 4
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_Client_HealthCheck(t *testing.T) {
+	srv := newServer(t)
+	srv.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteBulkString("pong")
+	})
+
+	<-srv.StartedCtx.Done()
+
+	addr := net.JoinHostPort(srv.config.BindAddr, strconv.Itoa(srv.config.BindPort))
+	c := config.NewClient()
+	c.MaxRetries = -1
+	require.NoError(t, c.Sanitize())
+
+	cs := NewClient(c)
+	ctx := context.Background()
+	require.NoError(t, cs.Dial(ctx, addr))
+	require.Empty(t, cs.HealthCheck(ctx))
+
+	poolStats := cs.PoolStats()
+	require.Contains(t, poolStats, addr)
+	require.NotZero(t, poolStats[addr].TotalConns)
+
+	require.NoError(t, srv.Shutdown(ctx))
+	// The pool is kept until the member fails HealthCheckFailureThreshold
+	// pings in a row.
+	for i := 1; i < c.HealthCheckFailureThreshold; i++ {
+		require.Empty(t, cs.HealthCheck(ctx))
+		require.Contains(t, cs.PoolStats(), addr)
+	}
+	require.Equal(t, []string{addr}, cs.HealthCheck(ctx))
+	require.NotContains(t, cs.PoolStats(), addr)
+	require.NotZero(t, HealthCheckFailuresTotal.Read())
+}
+
+func TestServer_Client_AdaptiveTimeout(t *testing.T) {
+	srv := newServer(t)
+	srv.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+		// A distant member.
+		<-time.After(50 * time.Millisecond)
+		conn.WriteBulkString("pong")
+	})
+
+	<-srv.StartedCtx.Done()
+
+	addr := net.JoinHostPort(srv.config.BindAddr, strconv.Itoa(srv.config.BindPort))
+	c := config.NewClient()
+	c.MaxRetries = -1
+	c.ReadTimeout = 20 * time.Millisecond
+	c.MinAdaptiveTimeout = 10 * time.Millisecond
+	c.MaxAdaptiveTimeout = time.Second
+	c.AdaptiveTimeout = true
+	require.NoError(t, c.Sanitize())
+	require.NoError(t, c.Validate())
+
+	cs := NewClient(c)
+	ctx := context.Background()
+	ping := func() error {
+		cmd := protocol.NewPing().Command(ctx)
+		return cs.Get(addr).Process(ctx, cmd)
+	}
+
+	// The first timeouts double the timeout until the member replies.
+	var err error
+	for i := 0; i < 3; i++ {
+		if err = ping(); err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ping())
+	}
+
+	rtt := cs.RTTs()[addr]
+	require.GreaterOrEqual(t, rtt.SRTT, 50*time.Millisecond)
+	require.Greater(t, rtt.Timeout, rtt.SRTT)
+	require.LessOrEqual(t, rtt.Timeout, time.Second)
+	require.NotZero(t, rtt.Samples)
+}
+
+func TestServer_Client_RTT(t *testing.T) {
+	r := &rttEstimator{}
+	c := config.NewClient()
+	c.MinAdaptiveTimeout = time.Millisecond
+	c.MaxAdaptiveTimeout = time.Second
+
+	// ReadTimeout is used until the first sample, it's above the ceiling.
+	require.Equal(t, c.MaxAdaptiveTimeout, r.timeout(c))
+
+	r.observe(100 * time.Millisecond)
+	require.Equal(t, 100*time.Millisecond, r.srtt)
+	require.Equal(t, 50*time.Millisecond, r.rttvar)
+	require.Equal(t, 300*time.Millisecond, r.timeout(c))
+
+	r.timedOut()
+	require.Equal(t, 600*time.Millisecond, r.timeout(c))
+	r.timedOut()
+	require.Equal(t, time.Second, r.timeout(c))
+
+	r.observe(100 * time.Millisecond)
+	require.Equal(t, 100*time.Millisecond, r.srtt)
+	require.Less(t, r.timeout(c), 300*time.Millisecond)
+}
@@ -0,0 +1,170 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/bufpool"
+)
+
+// maxCoalescedSize is the size of the coalesced responses that are sent to
+// network without waiting for the delay.
+const maxCoalescedSize = 64 << 10
+
+var pool = bufpool.New()
+
+// coalescer buffers the responses on a connection and sends them to network
+// with a single write. The redcon already sends the responses of the pipelined
+// commands together, coalescer merges the responses of the consecutive reads
+// too. The responses are sent immediately if the last read didn't fill the read
+// buffer, no more commands are waiting on the connection then. Otherwise they
+// are sent after the delay or when the buffer is full. The buffers are reused.
+type coalescer struct {
+	mtx         sync.Mutex
+	send        func(b []byte) (int, error)
+	setDeadline func(t time.Time) error
+	delay       time.Duration
+	timer       *time.Timer
+	buf         *bytes.Buffer
+	pending     bool
+	moreInput   bool
+	err         error
+
+	// writeDeadline is the write deadline of the connection, deadline is the
+	// earliest write deadline of the buffered responses. The real write is
+	// done with it.
+	writeDeadline time.Time
+	deadline      time.Time
+}
+
+func newCoalescer(send func(b []byte) (int, error), setDeadline func(t time.Time) error, delay time.Duration) *coalescer {
+	return &coalescer{
+		send:        send,
+		setDeadline: setDeadline,
+		delay:       delay,
+	}
+}
+
+// setMoreInput records whether the last read filled the read buffer, so more
+// commands may be waiting on the connection.
+func (c *coalescer) setMoreInput(more bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.moreInput = more
+}
+
+// setWriteDeadline records the write deadline of the connection.
+func (c *coalescer) setWriteDeadline(t time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.writeDeadline = t
+}
+
+func (c *coalescer) write(b []byte) (int, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.buf == nil {
+		c.buf = pool.Get()
+	}
+	if c.buf.Len() > 0 {
+		CoalescedWritesTotal.Increase(1)
+	}
+	// The caller reuses b, copy it.
+	c.buf.Write(b)
+	if c.deadline.IsZero() || (!c.writeDeadline.IsZero() && c.writeDeadline.Before(c.deadline)) {
+		c.deadline = c.writeDeadline
+	}
+
+	if !c.moreInput || c.buf.Len() >= maxCoalescedSize {
+		c.pending = false
+		if err := c.flush(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if !c.pending {
+		c.pending = true
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.delay, c.flushPending)
+		} else {
+			c.timer.Reset(c.delay)
+		}
+	}
+	return len(b), nil
+}
+
+// flushPending is called by the timer. The responses may have been sent by a
+// write in the meantime.
+func (c *coalescer) flushPending() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !c.pending {
+		return
+	}
+	c.pending = false
+	_ = c.flush()
+}
+
+// flush sends the buffered responses. The lock has to be held by the caller.
+// The error is kept, the next writes return it.
+func (c *coalescer) flush() error {
+	if c.buf == nil {
+		return c.err
+	}
+	defer func() {
+		pool.Put(c.buf)
+		c.buf = nil
+		c.deadline = time.Time{}
+	}()
+
+	if c.buf.Len() == 0 || c.err != nil {
+		return c.err
+	}
+	if !c.deadline.Equal(c.writeDeadline) {
+		// The deadline of the connection has been changed after the
+		// responses were buffered.
+		if c.err = c.setDeadline(c.deadline); c.err != nil {
+			return c.err
+		}
+		defer func() {
+			_ = c.setDeadline(c.writeDeadline)
+		}()
+	}
+	_, c.err = c.send(c.buf.Bytes())
+	return c.err
+}
+
+func (c *coalescer) close() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.pending = false
+	_ = c.flush()
+}
@@ -0,0 +1,156 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSender struct {
+	mtx       sync.Mutex
+	writes    [][]byte
+	deadlines []time.Time
+	deadline  time.Time
+	err       error
+}
+
+func (ts *testSender) setDeadline(t time.Time) error {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+
+	ts.deadline = t
+	return nil
+}
+
+func (ts *testSender) send(b []byte) (int, error) {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+
+	if ts.err != nil {
+		return 0, ts.err
+	}
+	ts.writes = append(ts.writes, append([]byte(nil), b...))
+	ts.deadlines = append(ts.deadlines, ts.deadline)
+	return len(b), nil
+}
+
+func (ts *testSender) Writes() [][]byte {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+
+	return ts.writes
+}
+
+func TestCoalescer(t *testing.T) {
+	t.Run("Coalesce the writes", func(t *testing.T) {
+		ts := &testSender{}
+		c := newCoalescer(ts.send, ts.setDeadline, 20*time.Millisecond)
+		c.setMoreInput(true)
+
+		// The caller reuses the buffer.
+		buf := []byte("foo")
+		_, err := c.write(buf)
+		require.NoError(t, err)
+		copy(buf, "bar")
+		_, err = c.write(buf)
+		require.NoError(t, err)
+		require.Len(t, ts.Writes(), 0)
+
+		require.Eventually(t, func() bool {
+			return len(ts.Writes()) == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, []byte("foobar"), ts.Writes()[0])
+		require.NotEqual(t, int64(0), CoalescedWritesTotal.Read())
+	})
+
+	t.Run("Flush the full buffer", func(t *testing.T) {
+		ts := &testSender{}
+		c := newCoalescer(ts.send, ts.setDeadline, time.Hour)
+		c.setMoreInput(true)
+
+		_, err := c.write(bytes.Repeat([]byte("a"), maxCoalescedSize))
+		require.NoError(t, err)
+		require.Len(t, ts.Writes(), 1)
+	})
+
+	t.Run("Flush on close", func(t *testing.T) {
+		ts := &testSender{}
+		c := newCoalescer(ts.send, ts.setDeadline, time.Hour)
+		c.setMoreInput(true)
+
+		_, err := c.write([]byte("foo"))
+		require.NoError(t, err)
+		c.close()
+		require.Equal(t, [][]byte{[]byte("foo")}, ts.Writes())
+	})
+
+	t.Run("Return the write error", func(t *testing.T) {
+		errBroken := errors.New("broken pipe")
+		ts := &testSender{err: errBroken}
+		c := newCoalescer(ts.send, ts.setDeadline, time.Millisecond)
+		c.setMoreInput(true)
+
+		_, err := c.write([]byte("foo"))
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			_, err = c.write([]byte("bar"))
+			return errors.Is(err, errBroken)
+		}, time.Second, time.Millisecond)
+	})
+	t.Run("Flush when there is no more input", func(t *testing.T) {
+		ts := &testSender{}
+		c := newCoalescer(ts.send, ts.setDeadline, time.Hour)
+		c.setMoreInput(true)
+
+		_, err := c.write([]byte("foo"))
+		require.NoError(t, err)
+		require.Len(t, ts.Writes(), 0)
+
+		// The last read didn't fill the buffer, the responses are sent
+		// without waiting for the delay.
+		c.setMoreInput(false)
+		_, err = c.write([]byte("bar"))
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("foobar")}, ts.Writes())
+	})
+
+	t.Run("Send with the write deadline of the buffered responses", func(t *testing.T) {
+		ts := &testSender{}
+		c := newCoalescer(ts.send, ts.setDeadline, 10*time.Millisecond)
+		c.setMoreInput(true)
+
+		deadline := time.Now().Add(time.Minute)
+		c.setWriteDeadline(deadline)
+		_, err := c.write([]byte("foo"))
+		require.NoError(t, err)
+		// The caller clears the deadline after the write returns.
+		c.setWriteDeadline(time.Time{})
+
+		require.Eventually(t, func() bool {
+			return len(ts.Writes()) == 1
+		}, time.Second, time.Millisecond)
+
+		ts.mtx.Lock()
+		defer ts.mtx.Unlock()
+		require.Equal(t, deadline, ts.deadlines[0])
+		require.True(t, ts.deadline.IsZero())
+	})
+}
@@ -0,0 +1,166 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/go-redis/redis/v8"
+	"github.com/tidwall/redcon"
+)
+
+// deadlineConn carries the deadline of the command that is being served on
+// the connection.
+type deadlineConn struct {
+	redcon.Conn
+	deadline time.Time
+}
+
+// WriteError writes ErrDeadlineExceeded instead of the context errors that are
+// caused by the deadline of the command.
+func (dc *deadlineConn) WriteError(msg string) {
+	if strings.HasSuffix(msg, context.DeadlineExceeded.Error()) && !time.Now().Before(dc.deadline) {
+		DeadlineExceededTotal.Increase(1)
+		protocol.WriteError(dc.Conn, ErrDeadlineExceeded)
+		return
+	}
+	dc.Conn.WriteError(msg)
+}
+
+// CommandContext returns a copy of the parent context that is canceled at the
+// deadline of the command that is being served on conn. The deadline is the
+// earlier one of the client deadline and the command timeout.
+func CommandContext(parent context.Context, conn redcon.Conn) (context.Context, context.CancelFunc) {
+	if dc, ok := conn.(*deadlineConn); ok {
+		return context.WithDeadline(parent, dc.deadline)
+	}
+	return context.WithCancel(parent)
+}
+
+// blockingCommands wait as long as the client asks for, CommandTimeout doesn't
+// apply to them. They can still be bounded with CommandTimeouts.
+var blockingCommands = map[string]struct{}{
+	protocol.DMap.Lock:  {},
+	protocol.Queue.BPop: {},
+}
+
+// commandTimeout returns the server-side timeout of the command.
+func (s *Server) commandTimeout(command string) time.Duration {
+	if timeout, ok := s.commandTimeouts[command]; ok {
+		return timeout
+	}
+	if _, ok := blockingCommands[command]; ok {
+		return 0
+	}
+	return s.config.CommandTimeout
+}
+
+// unwrapDeadline returns the wrapped command and its deadline. The timeout of
+// the client counts from start. The deadline is zero if neither the client
+// nor the configuration sets one.
+func (s *Server) unwrapDeadline(cmd redcon.Command, start time.Time) (redcon.Command, time.Time, error) {
+	var deadline time.Time
+	if strings.EqualFold(util.BytesToString(cmd.Args[0]), protocol.Generic.Deadline) {
+		d, err := protocol.ParseDeadlineCommand(cmd)
+		if err != nil {
+			return cmd, deadline, err
+		}
+		cmd, deadline = d.Command, start.Add(d.Timeout)
+	}
+
+	command := strings.ToLower(util.BytesToString(cmd.Args[0]))
+	if timeout := s.commandTimeout(command); timeout > 0 {
+		if t := start.Add(timeout); deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	return cmd, deadline, nil
+}
+
+// deadlineHook sends the remaining time until the deadline of the context
+// along with the command, so the member doesn't do wasted work after the
+// client has given up.
+type deadlineHook struct{}
+
+func (deadlineHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx, nil
+	}
+	args := cmd.Args()
+	if name, ok := args[0].(string); ok && name == protocol.Generic.Deadline {
+		// The command is processed again, only update its timeout.
+		args[1] = protocol.NewDeadline(time.Until(deadline)).TimeoutMilliseconds()
+		return ctx, nil
+	}
+	wrapCommand(cmd, protocol.NewDeadline(time.Until(deadline)).Wrap(args))
+	return ctx, nil
+}
+
+func (deadlineHook) AfterProcess(_ context.Context, _ redis.Cmder) error {
+	return nil
+}
+
+func (deadlineHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (deadlineHook) AfterProcessPipeline(_ context.Context, _ []redis.Cmder) error {
+	return nil
+}
+
+// wrapCommand replaces the arguments of the command. The redis client doesn't
+// allow to change the arguments, so the command is created again with the new
+// ones. The commands of the other types are sent without a deadline. The scan
+// commands are created without a process function, see protocol.Scan.
+func wrapCommand(cmd redis.Cmder, args []interface{}) {
+	ctx := context.Background()
+	switch c := cmd.(type) {
+	case *redis.Cmd:
+		*c = *redis.NewCmd(ctx, args...)
+	case *redis.StatusCmd:
+		*c = *redis.NewStatusCmd(ctx, args...)
+	case *redis.StringCmd:
+		*c = *redis.NewStringCmd(ctx, args...)
+	case *redis.IntCmd:
+		*c = *redis.NewIntCmd(ctx, args...)
+	case *redis.BoolCmd:
+		*c = *redis.NewBoolCmd(ctx, args...)
+	case *redis.SliceCmd:
+		*c = *redis.NewSliceCmd(ctx, args...)
+	case *redis.StringSliceCmd:
+		*c = *redis.NewStringSliceCmd(ctx, args...)
+	case *redis.IntSliceCmd:
+		*c = *redis.NewIntSliceCmd(ctx, args...)
+	case *redis.ScanCmd:
+		*c = *redis.NewScanCmd(ctx, nil, args...)
+	}
+}
+
+// commandName returns the name of the command, the wrapped one if it carries
+// a deadline.
+func commandName(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if cmd.Name() == protocol.Generic.Deadline && len(args) > 2 {
+		if name, ok := args[2].(string); ok {
+			return name
+		}
+	}
+	return cmd.Name()
+}
@@ -0,0 +1,190 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/redcon"
+)
+
+func TestServer_Deadline(t *testing.T) {
+	srv := newServer(t)
+	deadlines := make(chan time.Time, 1)
+	srv.ServeMux().HandleFunc(protocol.DMap.Get, func(conn redcon.Conn, cmd redcon.Command) {
+		ctx, cancel := CommandContext(context.Background(), conn)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		conn.WriteString(protocol.StatusOK)
+	})
+	<-srv.StartedCtx.Done()
+
+	rdb := redis.NewClient(defaultRedisOptions(srv.config))
+	ctx := context.Background()
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		args := protocol.NewDeadline(0).Wrap([]interface{}{protocol.DMap.Get, "mydmap", "mykey"})
+		cmd := redis.NewStatusCmd(ctx, args...)
+		err := rdb.Process(ctx, cmd)
+		require.ErrorIs(t, protocol.ConvertError(err), ErrDeadlineExceeded)
+		require.NotZero(t, DeadlineExceededTotal.Read())
+	})
+
+	t.Run("Deadline propagated", func(t *testing.T) {
+		start := time.Now()
+		args := protocol.NewDeadline(time.Minute).Wrap([]interface{}{protocol.DMap.Get, "mydmap", "mykey"})
+		cmd := redis.NewStatusCmd(ctx, args...)
+		require.NoError(t, rdb.Process(ctx, cmd))
+		// The deadline counts from the time the member receives the command.
+		deadline := <-deadlines
+		require.False(t, deadline.Before(start.Add(time.Minute)))
+		require.True(t, deadline.Before(time.Now().Add(time.Minute)))
+	})
+
+	t.Run("No deadline", func(t *testing.T) {
+		cmd := protocol.NewGet("mydmap", "mykey").Command(ctx)
+		require.NoError(t, rdb.Process(ctx, cmd))
+		require.True(t, (<-deadlines).IsZero())
+	})
+}
+
+func TestServer_CommandTimeout(t *testing.T) {
+	srv := newServer(t)
+	srv.config.CommandTimeout = time.Minute
+	srv.commandTimeouts[protocol.DMap.Query] = 0
+	deadlines := make(chan time.Time, 1)
+	handler := func(conn redcon.Conn, cmd redcon.Command) {
+		ctx, cancel := CommandContext(context.Background(), conn)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		conn.WriteString(protocol.StatusOK)
+	}
+	srv.ServeMux().HandleFunc(protocol.DMap.Get, handler)
+	srv.ServeMux().HandleFunc(protocol.DMap.Query, handler)
+	<-srv.StartedCtx.Done()
+
+	rdb := redis.NewClient(defaultRedisOptions(srv.config))
+	ctx := context.Background()
+
+	start := time.Now()
+	cmd := protocol.NewGet("mydmap", "mykey").Command(ctx)
+	require.NoError(t, rdb.Process(ctx, cmd))
+	deadline := <-deadlines
+	require.False(t, deadline.Before(start.Add(time.Minute)))
+
+	// The earlier client deadline is used.
+	args := protocol.NewDeadline(time.Second).Wrap([]interface{}{protocol.DMap.Get, "mydmap", "mykey"})
+	require.NoError(t, rdb.Process(ctx, redis.NewStatusCmd(ctx, args...)))
+	require.True(t, (<-deadlines).Before(time.Now().Add(time.Second)))
+
+	// The timeout of the command is disabled.
+	query := redis.NewStatusCmd(ctx, protocol.DMap.Query, "mydmap", "age>=30")
+	require.NoError(t, rdb.Process(ctx, query))
+	require.True(t, (<-deadlines).IsZero())
+}
+
+func TestServer_Deadline_Aborted(t *testing.T) {
+	srv := newServer(t)
+	srv.ServeMux().HandleFunc(protocol.DMap.Get, func(conn redcon.Conn, cmd redcon.Command) {
+		ctx, cancel := CommandContext(context.Background(), conn)
+		defer cancel()
+
+		// A command that waits until the deadline.
+		<-ctx.Done()
+		protocol.WriteError(conn, ctx.Err())
+	})
+	<-srv.StartedCtx.Done()
+
+	rdb := redis.NewClient(defaultRedisOptions(srv.config))
+	ctx := context.Background()
+
+	args := protocol.NewDeadline(50 * time.Millisecond).Wrap([]interface{}{protocol.DMap.Get, "mydmap", "mykey"})
+	err := rdb.Process(ctx, redis.NewStatusCmd(ctx, args...))
+	require.ErrorIs(t, protocol.ConvertError(err), ErrDeadlineExceeded)
+}
+
+func TestServer_Client_Deadline(t *testing.T) {
+	srv := newServer(t)
+	deadlines := make(chan time.Time, 1)
+	srv.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+		ctx, cancel := CommandContext(context.Background(), conn)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		conn.WriteBulkString("pong")
+	})
+	<-srv.StartedCtx.Done()
+
+	addr := net.JoinHostPort(srv.config.BindAddr, strconv.Itoa(srv.config.BindPort))
+	c := config.NewClient()
+	require.NoError(t, c.Sanitize())
+	cs := NewClient(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cmd := protocol.NewPing().Command(ctx)
+	require.NoError(t, cs.Get(addr).Process(ctx, cmd))
+	result, err := cmd.Result()
+	require.NoError(t, err)
+	require.Equal(t, "pong", result)
+
+	// The remaining time is sent, the deadline is at most a millisecond later.
+	expected, _ := ctx.Deadline()
+	require.WithinDuration(t, expected, <-deadlines, 5*time.Millisecond)
+
+	// The command is processed again with the same deadline.
+	require.NoError(t, cs.Get(addr).Process(ctx, cmd))
+	require.WithinDuration(t, expected, <-deadlines, 5*time.Millisecond)
+
+	// The scan commands carry the deadline too.
+	srv.ServeMux().HandleFunc(protocol.DMap.Scan, func(conn redcon.Conn, cmd redcon.Command) {
+		ctx, cancel := CommandContext(context.Background(), conn)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		conn.WriteArray(2)
+		conn.WriteBulkString("0")
+		conn.WriteArray(0)
+	})
+	scan := protocol.NewScan(0, "mydmap", 0).Command(ctx)
+	require.NoError(t, cs.Get(addr).Process(ctx, scan))
+	require.WithinDuration(t, expected, <-deadlines, 5*time.Millisecond)
+}
+
+func TestServer_CommandTimeout_Blocking_Commands(t *testing.T) {
+	srv := newServer(t)
+	srv.config.CommandTimeout = time.Minute
+	require.Zero(t, srv.commandTimeout(protocol.DMap.Lock))
+	require.Zero(t, srv.commandTimeout(protocol.Queue.BPop))
+	require.Equal(t, time.Minute, srv.commandTimeout(protocol.DMap.Get))
+
+	srv.commandTimeouts[protocol.Queue.BPop] = time.Second
+	require.Equal(t, time.Second, srv.commandTimeout(protocol.Queue.BPop))
+}
@@ -0,0 +1,114 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/tidwall/redcon"
+)
+
+// Command is a command that is received by the server, see CommandHook.
+type Command struct {
+	// Name is the lowercase name of the command, e.g. "dm.put".
+	Name string
+
+	// Args is the arguments of the command, without its name. A hook can
+	// replace them, e.g. to add a prefix to the keys.
+	Args [][]byte
+
+	// Client is the address of the connection that sent the command.
+	Client string
+}
+
+// CommandHook is called before the server serves a command. The command is
+// rejected with the error if it returns one.
+type CommandHook func(cmd *Command) error
+
+type commandHooks struct {
+	mtx   sync.RWMutex
+	hooks map[string]CommandHook
+	// sorted by name, the hooks run in this order.
+	ordered []CommandHook
+}
+
+// RegisterCommandHook registers a hook that is called with every client
+// command that is received by the server. The commands of the other members,
+// including the redirected client commands, don't go through the hooks. The
+// hooks run in the order of their names. A hook that was registered with the
+// same name is replaced.
+func (s *Server) RegisterCommandHook(name string, h CommandHook) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	if s.hooks.hooks == nil {
+		s.hooks.hooks = make(map[string]CommandHook)
+	}
+	s.hooks.hooks[name] = h
+	s.hooks.reorder()
+}
+
+// UnregisterCommandHook removes the hook with the given name.
+func (s *Server) UnregisterCommandHook(name string) {
+	s.hooks.mtx.Lock()
+	defer s.hooks.mtx.Unlock()
+
+	delete(s.hooks.hooks, name)
+	s.hooks.reorder()
+}
+
+func (c *commandHooks) reorder() {
+	names := make([]string, 0, len(c.hooks))
+	for name := range c.hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.ordered = make([]CommandHook, 0, len(names))
+	for _, name := range names {
+		c.ordered = append(c.ordered, c.hooks[name])
+	}
+}
+
+// runCommandHooks calls the hooks with the command and returns it with the
+// arguments that are set by the hooks.
+func (s *Server) runCommandHooks(conn redcon.Conn, cmd redcon.Command) (redcon.Command, error) {
+	s.hooks.mtx.RLock()
+	hooks := s.hooks.ordered
+	s.hooks.mtx.RUnlock()
+
+	if len(hooks) == 0 {
+		return cmd, nil
+	}
+
+	c := &Command{
+		Name:   strings.ToLower(util.BytesToString(cmd.Args[0])),
+		Args:   cmd.Args[1:],
+		Client: conn.RemoteAddr(),
+	}
+	for _, h := range hooks {
+		if err := h(c); err != nil {
+			return cmd, err
+		}
+	}
+
+	args := make([][]byte, 0, len(c.Args)+1)
+	args = append(args, cmd.Args[0])
+	cmd.Args = append(args, c.Args...)
+	return cmd, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/redcon"
+)
+
+func TestServer_CommandHooks(t *testing.T) {
+	s := newServer(t)
+
+	s.ServeMux().HandleFunc(protocol.DMap.Get, func(conn redcon.Conn, cmd redcon.Command) {
+		getCmd, err := protocol.ParseGetCommand(cmd)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		conn.WriteBulkString(getCmd.Key)
+	})
+	<-s.StartedCtx.Done()
+
+	var order []string
+	s.RegisterCommandHook("b-prefix", func(cmd *Command) error {
+		order = append(order, "b-prefix")
+		if cmd.Name == protocol.DMap.Get {
+			cmd.Args[1] = append([]byte("tenant:"), cmd.Args[1]...)
+		}
+		return nil
+	})
+	s.RegisterCommandHook("a-audit", func(cmd *Command) error {
+		order = append(order, "a-audit")
+		if string(cmd.Args[0]) == "forbidden" {
+			return errors.New("forbidden DMap")
+		}
+		return nil
+	})
+
+	rdb := redis.NewClient(defaultRedisOptions(s.config))
+	ctx := context.Background()
+
+	cmd := protocol.NewGet("mydmap", "mykey").Command(ctx)
+	require.NoError(t, rdb.Process(ctx, cmd))
+	key, err := cmd.Result()
+	require.NoError(t, err)
+	require.Equal(t, "tenant:mykey", key)
+	require.Equal(t, []string{"a-audit", "b-prefix"}, order)
+
+	cmd = protocol.NewGet("forbidden", "mykey").Command(ctx)
+	err = rdb.Process(ctx, cmd)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "forbidden DMap")
+
+	s.UnregisterCommandHook("a-audit")
+	s.UnregisterCommandHook("b-prefix")
+	cmd = protocol.NewGet("forbidden", "mykey").Command(ctx)
+	require.NoError(t, rdb.Process(ctx, cmd))
+	key, err = cmd.Result()
+	require.NoError(t, err)
+	require.Equal(t, "mykey", key)
+}
+
+func TestServer_CommandHooks_Member_Commands(t *testing.T) {
+	s := newServer(t)
+
+	handler := func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteString(protocol.StatusOK)
+	}
+	s.ServeMux().HandleFunc(protocol.DMap.Put, handler)
+	s.ServeMux().HandleFunc(protocol.DMap.PutEntry, handler)
+	<-s.StartedCtx.Done()
+
+	var calls int
+	s.RegisterCommandHook("audit", func(cmd *Command) error {
+		calls++
+		return nil
+	})
+
+	ctx := context.Background()
+	rdb := redis.NewClient(defaultRedisOptions(s.config))
+	require.NoError(t, rdb.Process(ctx, protocol.NewPutEntry("mydmap", "mykey", []byte("value")).Command(ctx)))
+	require.Equal(t, 0, calls)
+
+	require.NoError(t, rdb.Process(ctx, protocol.NewPut("mydmap", "mykey", []byte("value")).Command(ctx)))
+	require.Equal(t, 1, calls)
+
+	// A redirected client command
+	c := config.NewClient()
+	require.NoError(t, c.Sanitize())
+	rc := NewClient(c).Get(net.JoinHostPort(s.config.BindAddr, strconv.Itoa(s.config.BindPort)))
+	require.NoError(t, rc.Process(ctx, protocol.NewPut("mydmap", "mykey", []byte("value")).Command(ctx)))
+	require.Equal(t, 1, calls)
+}
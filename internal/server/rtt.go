@@ -0,0 +1,208 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/go-redis/redis/v8"
+)
+
+// unsampledCommands wait on the server side or carry large payloads, their
+// durations are not round-trip time samples.
+var unsampledCommands = map[string]struct{}{
+	protocol.Queue.BPop:            {},
+	protocol.DMap.Lock:             {},
+	protocol.Cluster.Compact:       {},
+	protocol.Internal.MoveFragment: {},
+	protocol.Internal.MoveQueue:    {},
+	protocol.Internal.MoveSet:      {},
+}
+
+// RTT is the round-trip time estimate of a cluster member.
+type RTT struct {
+	// SRTT is the smoothed round-trip time.
+	SRTT time.Duration
+
+	// RTTVAR is the round-trip time variation.
+	RTTVAR time.Duration
+
+	// Timeout is the timeout of the commands sent to the member.
+	Timeout time.Duration
+
+	// Samples is the number of the commands that are measured.
+	Samples int64
+}
+
+// maxBackoff limits the number of times the timeout is doubled after the
+// timeouts.
+const maxBackoff = 6
+
+// rttEstimator estimates the round-trip time of a member as described in
+// RFC 6298.
+type rttEstimator struct {
+	mtx     sync.RWMutex
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int64
+	backoff uint
+}
+
+func (r *rttEstimator) observe(sample time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.samples == 0 {
+		r.srtt = sample
+		r.rttvar = sample / 2
+	} else {
+		diff := r.srtt - sample
+		if diff < 0 {
+			diff = -diff
+		}
+		r.rttvar = (3*r.rttvar + diff) / 4
+		r.srtt = (7*r.srtt + sample) / 8
+	}
+	r.samples++
+	r.backoff = 0
+}
+
+// timedOut doubles the timeout until the next sample. Otherwise, a member
+// that is slower than the current timeout would never be sampled.
+func (r *rttEstimator) timedOut() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.backoff < maxBackoff {
+		r.backoff++
+	}
+}
+
+// timeout returns SRTT + 4 * RTTVAR, clamped to the adaptive timeout limits.
+// ReadTimeout is used until the first sample. It's doubled after every
+// timeout until the next sample.
+func (r *rttEstimator) timeout(c *config.Client) time.Duration {
+	r.mtx.RLock()
+	timeout := c.ReadTimeout
+	if r.samples > 0 {
+		timeout = r.srtt + 4*r.rttvar
+	}
+	if timeout < c.MinAdaptiveTimeout {
+		timeout = c.MinAdaptiveTimeout
+	}
+	timeout <<= r.backoff
+	r.mtx.RUnlock()
+
+	if timeout > c.MaxAdaptiveTimeout {
+		timeout = c.MaxAdaptiveTimeout
+	}
+	return timeout
+}
+
+func (r *rttEstimator) stats(c *config.Client) RTT {
+	timeout := c.ReadTimeout
+	if c.AdaptiveTimeout {
+		timeout = r.timeout(c)
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return RTT{
+		SRTT:    r.srtt,
+		RTTVAR:  r.rttvar,
+		Timeout: timeout,
+		Samples: r.samples,
+	}
+}
+
+type rttKey struct{}
+
+type rttState struct {
+	start  time.Time
+	cancel context.CancelFunc
+}
+
+// rttHook measures the commands sent to a member and assigns the adaptive
+// timeout to them, if it's enabled.
+type rttHook struct {
+	config    *config.Client
+	estimator *rttEstimator
+}
+
+func (h *rttHook) begin(ctx context.Context) context.Context {
+	state := &rttState{start: time.Now()}
+	if h.config.AdaptiveTimeout {
+		// The redis client uses the earlier one of the context deadline and
+		// its own timeout, that is MaxAdaptiveTimeout.
+		ctx, state.cancel = context.WithTimeout(ctx, h.estimator.timeout(h.config))
+	}
+	return context.WithValue(ctx, rttKey{}, state)
+}
+
+func (h *rttHook) end(ctx context.Context) *rttState {
+	state, ok := ctx.Value(rttKey{}).(*rttState)
+	if !ok {
+		return nil
+	}
+	if state.cancel != nil {
+		state.cancel()
+	}
+	return state
+}
+
+func (h *rttHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return h.begin(ctx), nil
+}
+
+func (h *rttHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	state := h.end(ctx)
+	if state == nil {
+		return nil
+	}
+	if _, ok := unsampledCommands[commandName(cmd)]; ok {
+		return nil
+	}
+
+	// Only the replies are sampled, a failed command has no round-trip time.
+	var rerr redis.Error
+	var nerr net.Error
+	err := cmd.Err()
+	switch {
+	case err == nil || errors.As(err, &rerr):
+		h.estimator.observe(time.Since(state.start))
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()):
+		h.estimator.timedOut()
+	}
+	return nil
+}
+
+func (h *rttHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return h.begin(ctx), nil
+}
+
+func (h *rttHook) AfterProcessPipeline(ctx context.Context, _ []redis.Cmder) error {
+	// The duration of a pipeline depends on its length, it's not sampled.
+	h.end(ctx)
+	return nil
+}
+
+var _ redis.Hook = (*rttHook)(nil)
@@ -16,13 +16,18 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/buraksezer/olric/internal/checkpoint"
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/internal/util"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/tidwall/redcon"
 )
@@ -42,21 +47,71 @@ var (
 
 	// ReadBytesTotal is total number of bytes read by this server from network.
 	ReadBytesTotal = stats.NewInt64Counter()
+
+	// RejectedCommandsTotal is total number of commands rejected while the server is draining.
+	RejectedCommandsTotal = stats.NewInt64Counter()
+
+	// HealthCheckFailuresTotal is total number of health-check pings that failed.
+	HealthCheckFailuresTotal = stats.NewInt64Counter()
+
+	// CoalescedWritesTotal is total number of responses that are sent to
+	// network with a preceding response in the same write.
+	CoalescedWritesTotal = stats.NewInt64Counter()
+
+	// DeadlineExceededTotal is total number of commands that are rejected or
+	// aborted because their deadline has passed.
+	DeadlineExceededTotal = stats.NewInt64Counter()
 )
 
+// ErrShuttingDown is returned for the commands that are received while the
+// server is draining.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// ErrDeadlineExceeded is returned for the commands whose deadline has passed
+// before they are completed.
+var ErrDeadlineExceeded = errors.New("deadline exceeded")
+
 // Config is a composite type to bundle configuration parameters.
 type Config struct {
 	BindAddr        string
 	BindPort        int
 	KeepAlivePeriod time.Duration
 	IdleClose       time.Duration
+
+	// SlowLogThreshold is the minimum duration of a command to be recorded
+	// in the slow log. Zero disables the slow log.
+	SlowLogThreshold time.Duration
+
+	// SlowLogMaxLen is the number of the slow commands that are kept.
+	SlowLogMaxLen int
+
+	// WriteCoalesceDelay is the maximum duration that a response waits for
+	// the next responses on the same connection, so they are sent to network
+	// with a single write. The responses only wait if more commands are
+	// waiting to be read on the connection. Zero disables coalescing.
+	WriteCoalesceDelay time.Duration
+
+	// CommandTimeout is the maximum duration of a command. The client
+	// deadline is used instead if it's earlier. Zero disables it.
+	CommandTimeout time.Duration
+
+	// CommandTimeouts overrides CommandTimeout for the given commands.
+	CommandTimeouts map[string]time.Duration
 }
 
 type ConnWrapper struct {
 	net.Conn
+	coalescer *coalescer
 }
 
 func (cw *ConnWrapper) Write(b []byte) (n int, err error) {
+	if cw.coalescer != nil {
+		return cw.coalescer.write(b)
+	}
+	return cw.write(b)
+}
+
+func (cw *ConnWrapper) write(b []byte) (n int, err error) {
 	nr, err := cw.Conn.Write(b)
 	if err != nil {
 		return 0, err
@@ -73,12 +128,42 @@ func (cw *ConnWrapper) Read(b []byte) (n int, err error) {
 	}
 
 	ReadBytesTotal.Increase(int64(nr))
+	if cw.coalescer != nil {
+		// A read that fills the buffer may leave more commands on the
+		// connection, their responses are coalesced.
+		cw.coalescer.setMoreInput(nr == len(b))
+	}
 	return nr, nil
 }
 
+// SetDeadline records the write deadline for the coalesced responses.
+func (cw *ConnWrapper) SetDeadline(t time.Time) error {
+	if cw.coalescer != nil {
+		cw.coalescer.setWriteDeadline(t)
+	}
+	return cw.Conn.SetDeadline(t)
+}
+
+// SetWriteDeadline records the write deadline for the coalesced responses.
+func (cw *ConnWrapper) SetWriteDeadline(t time.Time) error {
+	if cw.coalescer != nil {
+		cw.coalescer.setWriteDeadline(t)
+	}
+	return cw.Conn.SetWriteDeadline(t)
+}
+
+// Close sends the coalesced responses before closing the connection.
+func (cw *ConnWrapper) Close() error {
+	if cw.coalescer != nil {
+		cw.coalescer.close()
+	}
+	return cw.Conn.Close()
+}
+
 type ListenerWrapper struct {
 	net.Listener
-	keepAlivePeriod time.Duration
+	keepAlivePeriod    time.Duration
+	writeCoalesceDelay time.Duration
 }
 
 func (lw *ListenerWrapper) Accept() (net.Conn, error) {
@@ -96,7 +181,11 @@ func (lw *ListenerWrapper) Accept() (net.Conn, error) {
 			}
 		}
 	}
-	return &ConnWrapper{conn}, nil
+	cw := &ConnWrapper{Conn: conn}
+	if lw.writeCoalesceDelay > 0 {
+		cw.coalescer = newCoalescer(cw.write, cw.Conn.SetWriteDeadline, lw.writeCoalesceDelay)
+	}
+	return cw, nil
 }
 
 type Server struct {
@@ -111,8 +200,16 @@ type Server struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
+	draining   int32
+	inflight   int64
+	slowLog    *slowLog
+	latencies  latencies
+	hooks      commandHooks
 	// some components of the TCP server should be closed after the listener
 	stopped chan struct{}
+
+	// lowercase command name => timeout
+	commandTimeouts map[string]time.Duration
 }
 
 // New creates and returns a new Server.
@@ -133,6 +230,15 @@ func New(c *Config, l *flog.Logger) *Server {
 		cancel:     cancel,
 	}
 	s.wmux = &ServeMuxWrapper{mux: s.mux}
+	s.commandTimeouts = make(map[string]time.Duration)
+	for command, timeout := range c.CommandTimeouts {
+		s.commandTimeouts[strings.ToLower(command)] = timeout
+	}
+	if c.SlowLogThreshold > 0 && c.SlowLogMaxLen > 0 {
+		s.slowLog = newSlowLog(c.SlowLogThreshold, c.SlowLogMaxLen)
+	}
+	protocol.SetError("SHUTTINGDOWN", ErrShuttingDown)
+	protocol.SetError("DEADLINEEXCEEDED", ErrDeadlineExceeded)
 	return s
 }
 
@@ -159,16 +265,19 @@ func (s *Server) ListenAndServe() error {
 	}
 
 	lw := &ListenerWrapper{
-		Listener:        listener,
-		keepAlivePeriod: s.config.KeepAlivePeriod,
+		Listener:           listener,
+		keepAlivePeriod:    s.config.KeepAlivePeriod,
+		writeCoalesceDelay: s.config.WriteCoalesceDelay,
 	}
 
 	defer close(s.stopped)
 	s.listener = lw
 
 	srv := redcon.NewServer(addr,
-		s.mux.ServeRESP,
+		s.serveRESP,
 		func(conn redcon.Conn) bool {
+			// The connections are accepted while draining, the other members
+			// still send their commands until the server is shut down.
 			ConnectionsTotal.Increase(1)
 			CurrentConnections.Increase(1)
 			return true
@@ -189,6 +298,97 @@ func (s *Server) ListenAndServe() error {
 	return s.server.Serve(lw)
 }
 
+// memberConnection is the context of the connections that are opened by the
+// cluster members, see protocol.Member.
+type memberConnection struct{}
+
+// IsMemberConnection returns true if the connection is opened by a cluster
+// member.
+func IsMemberConnection(conn redcon.Conn) bool {
+	_, ok := conn.Context().(memberConnection)
+	return ok
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// serveRESP keeps track of the in-flight commands and rejects the new client
+// commands with ErrShuttingDown while draining. The commands whose deadline has passed
+// while they are queued are rejected with ErrDeadlineExceeded. It also records
+// the latencies of the commands. The command hooks are called before serving
+// the client commands.
+func (s *Server) serveRESP(conn redcon.Conn, cmd redcon.Command) {
+	start := time.Now()
+	cmd, deadline, err := s.unwrapDeadline(cmd, start)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	name := strings.ToLower(util.BytesToString(cmd.Args[0]))
+	if name == protocol.Internal.Member {
+		conn.SetContext(memberConnection{})
+		conn.WriteString(protocol.StatusOK)
+		return
+	}
+	member := IsMemberConnection(conn) || protocol.IsMemberCommand(name)
+	if !member {
+		// The member-to-member commands are still served while draining,
+		// the partitions are moved to the other members with them. Drain
+		// only waits for the client commands.
+		atomic.AddInt64(&s.inflight, 1)
+		defer atomic.AddInt64(&s.inflight, -1)
+
+		if s.isDraining() {
+			RejectedCommandsTotal.Increase(1)
+			protocol.WriteError(conn, ErrShuttingDown)
+			return
+		}
+	}
+	if !deadline.IsZero() {
+		if !start.Before(deadline) {
+			DeadlineExceededTotal.Increase(1)
+			protocol.WriteError(conn, ErrDeadlineExceeded)
+			return
+		}
+		conn = &deadlineConn{Conn: conn, deadline: deadline}
+	}
+	if !member {
+		// The hooks have already seen the commands that are redirected by
+		// the other members.
+		cmd, err = s.runCommandHooks(conn, cmd)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+	}
+	s.mux.ServeRESP(conn, cmd)
+	s.observeCommand(conn, cmd, start)
+}
+
+// Drain rejects the new client commands with ErrShuttingDown, so the clients
+// can retry them on the other members. The commands of the other members,
+// e.g. replication and partition movements, are served until Shutdown. It
+// waits for the in-flight commands to finish until the context is done.
+func (s *Server) Drain(ctx context.Context) error {
+	atomic.StoreInt32(&s.draining, 1)
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		// The rejected commands are counted as in-flight for a moment, it's
+		// harmless.
+		if atomic.LoadInt64(&s.inflight) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 // Shutdown gracefully shuts down the server without interrupting any active connections.
 // Shutdown works by first closing all open listeners, then closing all idle connections,
 // and then waiting indefinitely for connections to return to idle and then shut down.
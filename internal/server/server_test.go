@@ -20,9 +20,11 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/require"
@@ -113,3 +115,66 @@ func TestServer_RESP_Stats(t *testing.T) {
 	require.NotEqual(t, int64(0), WrittenBytesTotal.Read())
 	require.NotEqual(t, int64(0), ReadBytesTotal.Read())
 }
+
+func TestServer_Drain(t *testing.T) {
+	s := newServer(t)
+
+	release := make(chan struct{})
+	s.ServeMux().HandleFunc(protocol.DMap.Get, func(conn redcon.Conn, cmd redcon.Command) {
+		<-release
+		conn.WriteString(protocol.StatusOK)
+	})
+	<-s.StartedCtx.Done()
+
+	opt := defaultRedisOptions(s.config)
+	opt.PoolSize = 1
+	rdb := redis.NewClient(opt)
+
+	ctx := context.Background()
+	inflight := protocol.NewGet("mydmap", "mykey").Command(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- rdb.Process(ctx, inflight)
+	}()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&s.inflight) == 1
+	}, time.Second, time.Millisecond)
+
+	drainCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Drain(drainCtx), context.DeadlineExceeded)
+
+	// Let the in-flight command finish.
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, s.Drain(ctx))
+
+	cmd := protocol.NewGet("mydmap", "mykey").Command(ctx)
+	err := rdb.Process(ctx, cmd)
+	require.ErrorIs(t, protocol.ConvertError(err), ErrShuttingDown)
+	require.NotEqual(t, int64(0), RejectedCommandsTotal.Read())
+}
+
+func TestServer_Drain_Member_Commands(t *testing.T) {
+	s := newServer(t)
+
+	handler := func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteString(protocol.StatusOK)
+	}
+	s.ServeMux().HandleFunc(protocol.DMap.Put, handler)
+	s.ServeMux().HandleFunc(protocol.DMap.PutEntry, handler)
+	<-s.StartedCtx.Done()
+
+	ctx := context.Background()
+	require.NoError(t, s.Drain(ctx))
+
+	// A member connects after the server started draining.
+	rdb := redis.NewClient(defaultRedisOptions(s.config))
+	cmd := protocol.NewPutEntry("mydmap", "mykey", []byte("value")).Command(ctx)
+	require.NoError(t, rdb.Process(ctx, cmd))
+	require.NoError(t, cmd.Err())
+
+	put := protocol.NewPut("mydmap", "mykey", []byte("value")).Command(ctx)
+	err := rdb.Process(ctx, put)
+	require.ErrorIs(t, protocol.ConvertError(err), ErrShuttingDown)
+}
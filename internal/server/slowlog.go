@@ -0,0 +1,241 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/buraksezer/olric/stats"
+	"github.com/cespare/xxhash/v2"
+	"github.com/tidwall/redcon"
+)
+
+// keyedCommands is the set of the DMap commands that take a key after the
+// name of the DMap.
+var keyedCommands = map[string]struct{}{
+	protocol.DMap.Get:        {},
+	protocol.DMap.GetEntry:   {},
+	protocol.DMap.Put:        {},
+	protocol.DMap.PutEntry:   {},
+	protocol.DMap.Del:        {},
+	protocol.DMap.DelEntry:   {},
+	protocol.DMap.Expire:     {},
+	protocol.DMap.PExpire:    {},
+	protocol.DMap.Lock:       {},
+	protocol.DMap.Unlock:     {},
+	protocol.DMap.LockLease:  {},
+	protocol.DMap.PLockLease: {},
+	protocol.DMap.Function:   {},
+	protocol.DMap.HSet:       {},
+	protocol.DMap.HDel:       {},
+	protocol.DMap.HIncrBy:    {},
+	protocol.DMap.Execute:    {},
+	protocol.DMap.Tombstone:  {},
+}
+
+// SlowLogEntry is a command that took longer than the slow log threshold.
+type SlowLogEntry struct {
+	// ID is the sequence number of the entry, it's unique on a member.
+	ID uint64 `json:"id"`
+
+	// Timestamp is the time when the command was received, in nanoseconds.
+	Timestamp int64 `json:"timestamp"`
+
+	// Duration is the time taken to serve the command.
+	Duration time.Duration `json:"duration"`
+
+	// Command is the name of the command, like dm.put.
+	Command string `json:"command"`
+
+	// DMap is the name of the DMap, if it's a DMap command.
+	DMap string `json:"dmap,omitempty"`
+
+	// KeyHash is the hash of the key, if the command takes a key. The keys
+	// are not recorded as is, they may contain sensitive data.
+	KeyHash uint64 `json:"key_hash,omitempty"`
+
+	// Client is the address of the client that sent the command.
+	Client string `json:"client"`
+
+	// Member is the member that served the command. It's only set in the
+	// slow logs collected from the cluster.
+	Member string `json:"member,omitempty"`
+}
+
+// slowLog keeps the latest slow commands in a ring buffer.
+type slowLog struct {
+	mtx       sync.RWMutex
+	threshold time.Duration
+	entries   []SlowLogEntry
+	next      int
+	seq       uint64
+}
+
+func newSlowLog(threshold time.Duration, maxLen int) *slowLog {
+	return &slowLog{
+		threshold: threshold,
+		entries:   make([]SlowLogEntry, 0, maxLen),
+	}
+}
+
+func (s *slowLog) add(e SlowLogEntry) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.seq++
+	e.ID = s.seq
+	if len(s.entries) < cap(s.entries) {
+		s.entries = append(s.entries, e)
+		return
+	}
+	s.entries[s.next] = e
+	s.next = (s.next + 1) % len(s.entries)
+}
+
+// latest returns the latest n entries, the newest one comes first. It
+// returns all the entries if n is zero or negative.
+func (s *slowLog) latest(n int) []SlowLogEntry {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if n <= 0 || n > len(s.entries) {
+		n = len(s.entries)
+	}
+	result := make([]SlowLogEntry, 0, n)
+	// The newest entry is just before s.next, if the buffer is full.
+	idx := s.next - 1
+	if len(s.entries) < cap(s.entries) {
+		idx = len(s.entries) - 1
+	}
+	for i := 0; i < n; i++ {
+		if idx < 0 {
+			idx = len(s.entries) - 1
+		}
+		result = append(result, s.entries[idx])
+		idx--
+	}
+	return result
+}
+
+// latencyHistogram is the latency distribution of a command. See
+// stats.LatencyBuckets for the bounds of the buckets.
+type latencyHistogram struct {
+	count   int64
+	total   int64
+	buckets []int64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		buckets: make([]int64, len(stats.LatencyBuckets)+1),
+	}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.total, d.Microseconds())
+	idx := len(stats.LatencyBuckets)
+	for i, bound := range stats.LatencyBuckets {
+		if d <= bound {
+			idx = i
+			break
+		}
+	}
+	atomic.AddInt64(&h.buckets[idx], 1)
+}
+
+func (h *latencyHistogram) snapshot() stats.LatencyHistogram {
+	result := stats.LatencyHistogram{
+		Count:             atomic.LoadInt64(&h.count),
+		TotalMicroseconds: atomic.LoadInt64(&h.total),
+		Buckets:           make([]int64, len(h.buckets)),
+	}
+	for i := range h.buckets {
+		result.Buckets[i] = atomic.LoadInt64(&h.buckets[i])
+	}
+	return result
+}
+
+// latencies holds the latency histograms of the registered commands.
+type latencies struct {
+	histograms sync.Map // command name => *latencyHistogram
+}
+
+func (l *latencies) observe(command string, d time.Duration) {
+	h, ok := l.histograms.Load(command)
+	if !ok {
+		h, _ = l.histograms.LoadOrStore(command, newLatencyHistogram())
+	}
+	h.(*latencyHistogram).observe(d)
+}
+
+func (l *latencies) snapshot() map[string]stats.LatencyHistogram {
+	result := make(map[string]stats.LatencyHistogram)
+	l.histograms.Range(func(key, value interface{}) bool {
+		result[key.(string)] = value.(*latencyHistogram).snapshot()
+		return true
+	})
+	return result
+}
+
+// observeCommand records the latency of a command, and adds it to the slow log
+// if it took longer than the threshold.
+func (s *Server) observeCommand(conn redcon.Conn, cmd redcon.Command, start time.Time) {
+	command := strings.ToLower(util.BytesToString(cmd.Args[0]))
+	if _, ok := s.mux.handlers[command]; !ok {
+		// Don't create a histogram for every garbage command name.
+		return
+	}
+
+	d := time.Since(start)
+	s.latencies.observe(command, d)
+	if s.slowLog == nil || d < s.slowLog.threshold {
+		return
+	}
+
+	e := SlowLogEntry{
+		Timestamp: start.UnixNano(),
+		Duration:  d,
+		Command:   command,
+		Client:    conn.RemoteAddr(),
+	}
+	if strings.HasPrefix(command, "dm.") && len(cmd.Args) > 1 {
+		e.DMap = string(cmd.Args[1])
+		if _, ok := keyedCommands[command]; ok && len(cmd.Args) > 2 {
+			e.KeyHash = xxhash.Sum64(cmd.Args[2])
+		}
+	}
+	s.slowLog.add(e)
+}
+
+// SlowLog returns the latest n slow commands, the newest one comes first. It
+// returns all the recorded commands if n is zero or negative.
+func (s *Server) SlowLog(n int) []SlowLogEntry {
+	if s.slowLog == nil {
+		return []SlowLogEntry{}
+	}
+	return s.slowLog.latest(n)
+}
+
+// CommandLatencies returns the latency histograms of the commands served by
+// the server.
+func (s *Server) CommandLatencies() map[string]stats.LatencyHistogram {
+	return s.latencies.snapshot()
+}
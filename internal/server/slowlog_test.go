@@ -0,0 +1,60 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SlowLog_Latest(t *testing.T) {
+	sl := newSlowLog(time.Millisecond, 3)
+	for i := 0; i < 5; i++ {
+		sl.add(SlowLogEntry{Command: protocol.DMap.Put})
+	}
+
+	entries := sl.latest(0)
+	require.Len(t, entries, 3)
+	// The newest one comes first and the oldest ones are dropped.
+	require.Equal(t, uint64(5), entries[0].ID)
+	require.Equal(t, uint64(4), entries[1].ID)
+	require.Equal(t, uint64(3), entries[2].ID)
+
+	entries = sl.latest(1)
+	require.Len(t, entries, 1)
+	require.Equal(t, uint64(5), entries[0].ID)
+}
+
+func TestServer_CommandLatencies(t *testing.T) {
+	s := newServer(t)
+
+	respEcho(t, s)
+
+	latencies := s.CommandLatencies()
+	h, ok := latencies[protocol.DMap.Get]
+	require.True(t, ok)
+	require.Equal(t, int64(1), h.Count)
+	require.Len(t, h.Buckets, len(stats.LatencyBuckets)+1)
+
+	var total int64
+	for _, count := range h.Buckets {
+		total += count
+	}
+	require.Equal(t, h.Count, total)
+}
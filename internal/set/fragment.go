@@ -0,0 +1,591 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	kindSet       = "set"
+	kindSortedSet = "zset"
+)
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string  `msgpack:"member"`
+	Score  float64 `msgpack:"score"`
+}
+
+// setPack is used to move a set or a sorted set to the new partition owner
+// and to copy it to the backup owners. Payload is an exported table of the
+// storage engine.
+type setPack struct {
+	PartID   uint64          `msgpack:"part_id"`
+	PartKind partitions.Kind `msgpack:"part_kind"`
+	Kind     string          `msgpack:"kind"`
+	Name     string          `msgpack:"name"`
+	Payload  []byte          `msgpack:"payload"`
+}
+
+// setOp is a change of a set or a sorted set. The partition owner sends it to
+// the backup owners before applying it locally.
+type setOp struct {
+	PartID  uint64         `msgpack:"part_id"`
+	Kind    string         `msgpack:"kind"`
+	Name    string         `msgpack:"name"`
+	Members []ScoredMember `msgpack:"members"`
+	Deleted bool           `msgpack:"deleted"`
+}
+
+func fragmentName(kind, name string) string {
+	return fmt.Sprintf("%s.%s", kind, name)
+}
+
+// newEngine creates a storage engine instance for a fragment. Sets use the
+// storage engine of DMaps.
+func (s *Service) newEngine() (storage.Engine, error) {
+	c := s.config.DMaps.Engine
+	engine, err := c.Implementation.Fork(storage.NewConfig(c.Config))
+	if err != nil {
+		return nil, err
+	}
+	engine.SetLogger(s.config.Logger)
+	if err := engine.Start(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// sendPack sends the pack to the owners.
+func (s *Service) sendPack(sp *setPack, owners []discovery.Member) error {
+	value, err := msgpack.Marshal(sp)
+	if err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		cmd := protocol.NewMoveSet(value).Command(s.ctx)
+		rc := s.client.Get(owner.String())
+		err = rc.Process(s.ctx, cmd)
+		if err != nil {
+			return err
+		}
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicate sends a change of a set to the backup owners of its partition.
+// The errors are only logged in the async replication mode.
+func (s *Service) replicate(hkey uint64, op *setOp) error {
+	owners := s.backup.PartitionOwnersByHKey(hkey)
+	if len(owners) == 0 {
+		return nil
+	}
+
+	op.PartID = s.backup.PartitionByHKey(hkey).ID()
+	value, err := msgpack.Marshal(op)
+	if err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		cmd := protocol.NewReplicateSet(value).Command(s.ctx)
+		rc := s.client.Get(owner.String())
+		err := rc.Process(s.ctx, cmd)
+		if err == nil {
+			err = cmd.Err()
+		}
+		if err != nil {
+			err = protocol.ConvertError(err)
+			if s.config.ReplicationMode == config.AsyncReplicationMode {
+				s.log.V(3).Printf("[ERROR] Failed to replicate %s: %s to %s: %v", op.Kind, op.Name, owner, err)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// fragment stores the members of a set or a sorted set in a storage engine
+// instance, like the fragments of DMaps. The members are the keys of the
+// entries, the scores of the sorted set members are their values. It lives
+// in the primary partition that owns the name and its backups.
+type fragment struct {
+	mtx      sync.RWMutex
+	kind     string
+	name     string
+	partKind partitions.Kind
+	storage  storage.Engine
+	service  *Service
+}
+
+func (f *fragment) hkey(member string) uint64 {
+	return partitions.HKey(f.name, member)
+}
+
+// promote makes the backup of a set its primary copy.
+func (f *fragment) promote() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.partKind = partitions.PRIMARY
+}
+
+// replicate sends a change to the backup owners. Only the primary copy of a
+// set is replicated.
+func (f *fragment) replicate(members []ScoredMember, deleted bool) error {
+	if f.service == nil || f.partKind == partitions.BACKUP || len(members) == 0 {
+		return nil
+	}
+	return f.service.replicate(partitions.HKey(f.kind, f.name), &setOp{
+		Kind:    f.kind,
+		Name:    f.name,
+		Members: members,
+		Deleted: deleted,
+	})
+}
+
+func (f *fragment) put(sm ScoredMember) error {
+	e := f.storage.NewEntry()
+	e.SetKey(sm.Member)
+	if f.kind == kindSortedSet {
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, math.Float64bits(sm.Score))
+		e.SetValue(value)
+	}
+	e.SetTimestamp(time.Now().UnixNano())
+	return f.storage.Put(f.hkey(sm.Member), e)
+}
+
+func toScoredMember(e storage.Entry) ScoredMember {
+	sm := ScoredMember{Member: e.Key()}
+	if value := e.Value(); len(value) == 8 {
+		sm.Score = math.Float64frombits(binary.BigEndian.Uint64(value))
+	}
+	return sm
+}
+
+// lookup returns the member with its score.
+func (f *fragment) lookup(member string) (ScoredMember, bool, error) {
+	e, err := f.storage.Get(f.hkey(member))
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return ScoredMember{}, false, nil
+	}
+	if err != nil {
+		return ScoredMember{}, false, err
+	}
+	return toScoredMember(e), true, nil
+}
+
+// merge imports an exported table of a moved or copied fragment. The newer
+// entry wins if a member is in both.
+func (f *fragment) merge(payload []byte) error {
+	return f.storage.Import(payload, func(hkey uint64, e storage.Entry) error {
+		current, err := f.storage.Get(hkey)
+		if err == nil && current.Timestamp() >= e.Timestamp() {
+			return nil
+		}
+		if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+			return err
+		}
+		return f.storage.Put(hkey, e)
+	})
+}
+
+// transfer sends the tables of the storage engine to the owners as a fragment
+// of the given partition. The tables are dropped after they are sent.
+func (f *fragment) transfer(engine storage.Engine, part *partitions.Partition, owners []discovery.Member) error {
+	i := engine.TransferIterator()
+	for i.Next() {
+		payload, index, err := i.Export()
+		if err != nil {
+			return err
+		}
+		sp := &setPack{
+			PartID:   part.ID(),
+			PartKind: part.Kind(),
+			Kind:     f.kind,
+			Name:     f.name,
+			Payload:  payload,
+		}
+		if err := f.service.sendPack(sp, owners); err != nil {
+			return err
+		}
+		if err := i.Drop(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// move sends the whole fragment to the new partition owner and deletes the
+// local copy. The caller has to hold the lock.
+func (f *fragment) move(part *partitions.Partition, owners []discovery.Member) error {
+	if err := f.transfer(f.storage, part, owners); err != nil {
+		return err
+	}
+	part.Map().Delete(fragmentName(f.kind, f.name))
+	return nil
+}
+
+// Copy sends the whole fragment to the owners as a fragment of the given
+// partition. The local copy is left untouched. It's used to re-create the
+// backups that are lost with a dead member.
+func (f *fragment) Copy(part *partitions.Partition, _ string, owners []discovery.Member) error {
+	f.mtx.RLock()
+	snapshot, err := f.storage.Fork(nil)
+	if err != nil {
+		f.mtx.RUnlock()
+		return err
+	}
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		err = snapshot.Put(hkey, e)
+		return err == nil
+	})
+	f.mtx.RUnlock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := snapshot.Close(); err != nil {
+			f.service.log.V(3).Printf("[ERROR] Failed to close the snapshot of %s: %v", f.name, err)
+		}
+	}()
+
+	return f.transfer(snapshot, part, owners)
+}
+
+func (f *fragment) Stats() storage.Stats {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.storage.Stats()
+}
+
+func (f *fragment) Compaction() (bool, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.storage.Compaction()
+}
+
+func (f *fragment) Destroy() error {
+	return f.storage.Destroy()
+}
+
+func (f *fragment) Close() error {
+	return f.storage.Close()
+}
+
+// setFragment is an unordered set of strings.
+type setFragment struct {
+	fragment
+}
+
+func newSetFragment(s *Service, name string, partKind partitions.Kind, engine storage.Engine) *setFragment {
+	return &setFragment{
+		fragment: fragment{
+			kind:     kindSet,
+			name:     name,
+			partKind: partKind,
+			storage:  engine,
+			service:  s,
+		},
+	}
+}
+
+// add adds the members and returns the number of members that were not in the set.
+func (f *setFragment) add(members ...string) (int, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var added []ScoredMember
+	seen := make(map[string]struct{})
+	for _, member := range members {
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		seen[member] = struct{}{}
+		if !f.storage.Check(f.hkey(member)) {
+			added = append(added, ScoredMember{Member: member})
+		}
+	}
+	if err := f.replicate(added, false); err != nil {
+		return 0, err
+	}
+	for _, sm := range added {
+		if err := f.put(sm); err != nil {
+			return 0, err
+		}
+	}
+	return len(added), nil
+}
+
+// remove removes the members and returns the number of removed members.
+func (f *setFragment) remove(members ...string) (int, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var removed []ScoredMember
+	seen := make(map[string]struct{})
+	for _, member := range members {
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		seen[member] = struct{}{}
+		if f.storage.Check(f.hkey(member)) {
+			removed = append(removed, ScoredMember{Member: member})
+		}
+	}
+	if err := f.replicate(removed, true); err != nil {
+		return 0, err
+	}
+	for _, sm := range removed {
+		if err := f.storage.Delete(f.hkey(sm.Member)); err != nil {
+			return 0, err
+		}
+	}
+	return len(removed), nil
+}
+
+func (f *setFragment) isMember(member string) bool {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.storage.Check(f.hkey(member))
+}
+
+// list returns the members in lexicographical order.
+func (f *setFragment) list() []string {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	members := make([]string, 0, f.storage.Stats().Length)
+	f.storage.Range(func(_ uint64, e storage.Entry) bool {
+		members = append(members, e.Key())
+		return true
+	})
+	sort.Strings(members)
+	return members
+}
+
+func (f *setFragment) length() int {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.storage.Stats().Length
+}
+
+// merge imports an exported table of a moved or copied set.
+func (f *setFragment) merge(payload []byte) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.fragment.merge(payload)
+}
+
+func (f *setFragment) Name() string {
+	return "Set"
+}
+
+// Move sends the whole set to the new partition owner and deletes the local copy.
+func (f *setFragment) Move(part *partitions.Partition, _ string, owners []discovery.Member) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.move(part, owners)
+}
+
+// sortedSetFragment keeps the members ordered by their scores. Members with
+// the same score are ordered lexicographically.
+type sortedSetFragment struct {
+	fragment
+	// ordered is an index of the members that are stored in the storage engine.
+	ordered []ScoredMember
+}
+
+func newSortedSetFragment(s *Service, name string, partKind partitions.Kind, engine storage.Engine) *sortedSetFragment {
+	return &sortedSetFragment{
+		fragment: fragment{
+			kind:     kindSortedSet,
+			name:     name,
+			partKind: partKind,
+			storage:  engine,
+			service:  s,
+		},
+	}
+}
+
+func less(a, b ScoredMember) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Member < b.Member
+}
+
+// search returns the index of the given member in the ordered list, or the
+// index where it would be inserted.
+func (f *sortedSetFragment) search(sm ScoredMember) int {
+	return sort.Search(len(f.ordered), func(i int) bool {
+		return !less(f.ordered[i], sm)
+	})
+}
+
+func (f *sortedSetFragment) insert(sm ScoredMember) {
+	i := f.search(sm)
+	f.ordered = append(f.ordered, ScoredMember{})
+	copy(f.ordered[i+1:], f.ordered[i:])
+	f.ordered[i] = sm
+}
+
+func (f *sortedSetFragment) delete(sm ScoredMember) {
+	i := f.search(sm)
+	f.ordered = append(f.ordered[:i], f.ordered[i+1:]...)
+}
+
+// add adds the members or updates their scores. It returns the number of
+// members that were not in the sorted set.
+func (f *sortedSetFragment) add(members ...ScoredMember) (int, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	// The last score of a member wins.
+	scores := make(map[string]float64)
+	var unique []string
+	for _, sm := range members {
+		if _, ok := scores[sm.Member]; !ok {
+			unique = append(unique, sm.Member)
+		}
+		scores[sm.Member] = sm.Score
+	}
+
+	var added int
+	var changed []ScoredMember
+	previous := make(map[string]ScoredMember)
+	for _, member := range unique {
+		sm, ok, err := f.lookup(member)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			if sm.Score == scores[member] {
+				continue
+			}
+			previous[member] = sm
+		} else {
+			added++
+		}
+		changed = append(changed, ScoredMember{Member: member, Score: scores[member]})
+	}
+	if err := f.replicate(changed, false); err != nil {
+		return 0, err
+	}
+	for _, sm := range changed {
+		if err := f.put(sm); err != nil {
+			return 0, err
+		}
+		if prev, ok := previous[sm.Member]; ok {
+			f.delete(prev)
+		}
+		f.insert(sm)
+	}
+	return added, nil
+}
+
+// merge imports an exported table of a moved or copied sorted set and
+// rebuilds the index.
+func (f *sortedSetFragment) merge(payload []byte) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if err := f.fragment.merge(payload); err != nil {
+		return err
+	}
+	f.ordered = f.ordered[:0]
+	f.storage.Range(func(_ uint64, e storage.Entry) bool {
+		f.ordered = append(f.ordered, toScoredMember(e))
+		return true
+	})
+	sort.Slice(f.ordered, func(i, j int) bool { return less(f.ordered[i], f.ordered[j]) })
+	return nil
+}
+
+// rangeByScore returns the members whose scores are between min and max,
+// inclusive, in ascending order.
+func (f *sortedSetFragment) rangeByScore(min, max float64) []ScoredMember {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	result := []ScoredMember{}
+	i := sort.Search(len(f.ordered), func(i int) bool {
+		return f.ordered[i].Score >= min
+	})
+	for ; i < len(f.ordered) && f.ordered[i].Score <= max; i++ {
+		result = append(result, f.ordered[i])
+	}
+	return result
+}
+
+// rank returns the zero-based position of the member in ascending order.
+func (f *sortedSetFragment) rank(member string) (int, error) {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	sm, ok, err := f.lookup(member)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrNoSuchMember
+	}
+	return f.search(sm), nil
+}
+
+func (f *sortedSetFragment) Name() string {
+	return "SortedSet"
+}
+
+// Move sends the whole sorted set to the new partition owner and deletes the local copy.
+func (f *sortedSetFragment) Move(part *partitions.Partition, _ string, owners []discovery.Member) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if err := f.move(part, owners); err != nil {
+		return err
+	}
+	f.ordered = nil
+	return nil
+}
+
+var (
+	_ partitions.Fragment = (*setFragment)(nil)
+	_ partitions.Fragment = (*sortedSetFragment)(nil)
+)
@@ -0,0 +1,123 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/hasher"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/kvstore"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func testEngine(t *testing.T) storage.Engine {
+	partitions.SetHashFunc(hasher.NewDefaultHasher())
+	kv, err := kvstore.New(nil)
+	require.NoError(t, err)
+	engine, err := kv.Fork(nil)
+	require.NoError(t, err)
+	require.NoError(t, engine.Start())
+	return engine
+}
+
+func TestSetFragment(t *testing.T) {
+	f := newSetFragment(nil, "myset", partitions.PRIMARY, testEngine(t))
+
+	added, err := f.add("foo", "bar", "foo")
+	require.NoError(t, err)
+	require.Equal(t, 2, added)
+	added, err = f.add("bar")
+	require.NoError(t, err)
+	require.Equal(t, 0, added)
+	require.True(t, f.isMember("foo"))
+	require.Equal(t, []string{"bar", "foo"}, f.list())
+
+	removed, err := f.remove("foo", "baz")
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	require.False(t, f.isMember("foo"))
+	require.Equal(t, 1, f.length())
+}
+
+func TestSortedSetFragment(t *testing.T) {
+	f := newSortedSetFragment(nil, "leaderboard", partitions.PRIMARY, testEngine(t))
+
+	added, err := f.add(
+		ScoredMember{Member: "foo", Score: 10},
+		ScoredMember{Member: "bar", Score: 5},
+		ScoredMember{Member: "baz", Score: 5},
+		ScoredMember{Member: "qux", Score: 20},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 4, added)
+
+	rank, err := f.rank("bar")
+	require.NoError(t, err)
+	require.Equal(t, 0, rank)
+
+	rank, err = f.rank("qux")
+	require.NoError(t, err)
+	require.Equal(t, 3, rank)
+
+	_, err = f.rank("quux")
+	require.ErrorIs(t, err, ErrNoSuchMember)
+
+	// Updates the score, the member is not added again.
+	added, err = f.add(ScoredMember{Member: "qux", Score: 1})
+	require.NoError(t, err)
+	require.Equal(t, 0, added)
+	rank, err = f.rank("qux")
+	require.NoError(t, err)
+	require.Equal(t, 0, rank)
+
+	members := f.rangeByScore(5, 10)
+	require.Equal(t, []ScoredMember{
+		{Member: "bar", Score: 5},
+		{Member: "baz", Score: 5},
+		{Member: "foo", Score: 10},
+	}, members)
+	require.Empty(t, f.rangeByScore(100, 200))
+}
+
+func TestSortedSetFragment_Merge(t *testing.T) {
+	moved := newSortedSetFragment(nil, "leaderboard", partitions.PRIMARY, testEngine(t))
+	_, err := moved.add(
+		ScoredMember{Member: "foo", Score: 1},
+		ScoredMember{Member: "bar", Score: 5},
+	)
+	require.NoError(t, err)
+
+	// Written after the moved copy, it wins.
+	f := newSortedSetFragment(nil, "leaderboard", partitions.PRIMARY, testEngine(t))
+	_, err = f.add(ScoredMember{Member: "foo", Score: 10})
+	require.NoError(t, err)
+
+	i := moved.storage.TransferIterator()
+	require.True(t, i.Next())
+	payload, _, err := i.Export()
+	require.NoError(t, err)
+	require.NoError(t, f.merge(payload))
+
+	require.Equal(t, []ScoredMember{
+		{Member: "bar", Score: 5},
+		{Member: "foo", Score: 10},
+	}, f.rangeByScore(0, 100))
+
+	rank, err := f.rank("foo")
+	require.NoError(t, err)
+	require.Equal(t, 1, rank)
+}
@@ -0,0 +1,325 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) RegisterHandlers() {
+	s.server.ServeMux().HandleFunc(protocol.Set.SAdd, s.saddCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Set.SRem, s.sremCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Set.SIsMember, s.sismemberCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Set.SMembers, s.smembersCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Set.SCard, s.scardCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.SortedSet.ZAdd, s.zaddCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.SortedSet.ZRangeByScore, s.zrangeByScoreCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.SortedSet.ZRank, s.zrankCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Internal.MoveSet, s.moveSetCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Internal.ReplicateSet, s.replicateSetCommandHandler)
+}
+
+func (s *Service) saddCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	saddCmd, err := protocol.ParseSAddCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	st, err := s.NewSet(saddCmd.Set)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	added, err := st.SAdd(s.ctx, saddCmd.Members...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(added)
+}
+
+func (s *Service) sremCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	sremCmd, err := protocol.ParseSRemCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	st, err := s.NewSet(sremCmd.Set)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	removed, err := st.SRem(s.ctx, sremCmd.Members...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(removed)
+}
+
+func (s *Service) sismemberCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	sismemberCmd, err := protocol.ParseSIsMemberCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	st, err := s.NewSet(sismemberCmd.Set)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ok, err := st.SIsMember(s.ctx, sismemberCmd.Member)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	if ok {
+		conn.WriteInt(1)
+		return
+	}
+	conn.WriteInt(0)
+}
+
+func (s *Service) smembersCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	smembersCmd, err := protocol.ParseSMembersCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	st, err := s.NewSet(smembersCmd.Set)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	members, err := st.SMembers(s.ctx)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteArray(len(members))
+	for _, member := range members {
+		conn.WriteBulkString(member)
+	}
+}
+
+func (s *Service) scardCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	scardCmd, err := protocol.ParseSCardCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	st, err := s.NewSet(scardCmd.Set)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	length, err := st.SCard(s.ctx)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(length)
+}
+
+func (s *Service) zaddCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	zaddCmd, err := protocol.ParseZAddCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	z, err := s.NewSortedSet(zaddCmd.SortedSet)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	members := make([]ScoredMember, 0, len(zaddCmd.Members))
+	for i, member := range zaddCmd.Members {
+		members = append(members, ScoredMember{Member: member, Score: zaddCmd.Scores[i]})
+	}
+	added, err := z.ZAdd(s.ctx, members...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(added)
+}
+
+func (s *Service) zrangeByScoreCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	zrangeCmd, err := protocol.ParseZRangeByScoreCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	z, err := s.NewSortedSet(zrangeCmd.SortedSet)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	members, err := z.ZRangeByScore(s.ctx, zrangeCmd.Min, zrangeCmd.Max)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteArray(len(members) * 2)
+	for _, sm := range members {
+		conn.WriteBulkString(sm.Member)
+		conn.WriteBulkString(strconv.FormatFloat(sm.Score, 'g', -1, 64))
+	}
+}
+
+func (s *Service) zrankCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	zrankCmd, err := protocol.ParseZRankCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	z, err := s.NewSortedSet(zrankCmd.SortedSet)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	rank, err := z.ZRank(s.ctx, zrankCmd.Member)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(rank)
+}
+
+func (s *Service) moveSetCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	moveSetCmd, err := protocol.ParseMoveSetCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	sp := &setPack{}
+	err = msgpack.Unmarshal(moveSetCmd.Payload, sp)
+	if err != nil {
+		s.log.V(2).Printf("[ERROR] Failed to unmarshal set pack: %v", err)
+		protocol.WriteError(conn, err)
+		return
+	}
+	if sp.PartID >= s.config.PartitionCount {
+		protocol.WriteError(conn, fmt.Errorf("invalid partition id: %d", sp.PartID))
+		return
+	}
+
+	var part *partitions.Partition
+	if sp.PartKind == partitions.PRIMARY {
+		part = s.primary.PartitionByID(sp.PartID)
+		if !part.Owner().CompareByName(s.rt.This()) {
+			protocol.WriteError(conn, fmt.Errorf("partition owner is not this node: %d", sp.PartID))
+			return
+		}
+	} else {
+		part = s.backup.PartitionByID(sp.PartID)
+		if !s.isBackupOwner(part) {
+			protocol.WriteError(conn, fmt.Errorf("backup owner is not this node: %d", sp.PartID))
+			return
+		}
+	}
+
+	s.log.V(2).Printf("[INFO] Received %s (kind: %s): %s on PartID: %d", sp.Kind, sp.PartKind, sp.Name, sp.PartID)
+	f, err := s.loadOrCreateFragment(part, sp.Kind, sp.Name)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	switch f := f.(type) {
+	case *setFragment:
+		err = f.merge(sp.Payload)
+	case *sortedSetFragment:
+		err = f.merge(sp.Payload)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (s *Service) isBackupOwner(part *partitions.Partition) bool {
+	for _, owner := range part.Owners() {
+		if owner.CompareByName(s.rt.This()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) replicateSetCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	replicateSetCmd, err := protocol.ParseReplicateSetCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	op := &setOp{}
+	err = msgpack.Unmarshal(replicateSetCmd.Payload, op)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	if op.PartID >= s.config.PartitionCount {
+		protocol.WriteError(conn, fmt.Errorf("invalid partition id: %d", op.PartID))
+		return
+	}
+
+	part := s.backup.PartitionByID(op.PartID)
+	if !s.isBackupOwner(part) {
+		protocol.WriteError(conn, fmt.Errorf("backup owner is not this node: %d", op.PartID))
+		return
+	}
+	f, err := s.loadOrCreateFragment(part, op.Kind, op.Name)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	switch f := f.(type) {
+	case *setFragment:
+		members := make([]string, 0, len(op.Members))
+		for _, sm := range op.Members {
+			members = append(members, sm.Member)
+		}
+		if op.Deleted {
+			_, err = f.remove(members...)
+		} else {
+			_, err = f.add(members...)
+		}
+	case *sortedSetFragment:
+		_, err = f.add(op.Members...)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/cluster/routingtable"
+	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/server"
+	"github.com/buraksezer/olric/internal/service"
+	"github.com/buraksezer/olric/pkg/flog"
+)
+
+// ErrNoSuchMember is returned when the member is not in the sorted set.
+var ErrNoSuchMember = errors.New("no such member")
+
+type Service struct {
+	log     *flog.Logger
+	config  *config.Config
+	rt      *routingtable.RoutingTable
+	primary *partitions.Partitions
+	backup  *partitions.Partitions
+	server  *server.Server
+	client  *server.Client
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func registerErrors() {
+	protocol.SetError("NOSUCHMEMBER", ErrNoSuchMember)
+}
+
+func NewService(e *environment.Environment) (service.Service, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		log:     e.Get("logger").(*flog.Logger),
+		config:  e.Get("config").(*config.Config),
+		rt:      e.Get("routingtable").(*routingtable.RoutingTable),
+		primary: e.Get("primary").(*partitions.Partitions),
+		backup:  e.Get("backup").(*partitions.Partitions),
+		server:  e.Get("server").(*server.Server),
+		client:  e.Get("client").(*server.Client),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	registerErrors()
+	s.RegisterHandlers()
+	return s, nil
+}
+
+func (s *Service) Start() error {
+	// dummy implementation
+	return nil
+}
+
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+	case <-done:
+	}
+	return nil
+}
+
+var _ service.Service = (*Service)(nil)
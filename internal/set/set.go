@@ -0,0 +1,258 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// Set is an unordered collection of unique strings. It's stored on the owner
+// of the partition that its name belongs to.
+type Set struct {
+	name string
+	hkey uint64
+	s    *Service
+}
+
+// NewSet creates and returns a new Set instance. The set is created on its
+// partition owner when the first member is added.
+func (s *Service) NewSet(name string) (*Set, error) {
+	return &Set{
+		name: name,
+		hkey: partitions.HKey(kindSet, name),
+		s:    s,
+	}, nil
+}
+
+func (s *Service) loadOrCreateFragment(part *partitions.Partition, kind, name string) (partitions.Fragment, error) {
+	part.Lock()
+	defer part.Unlock()
+
+	f, ok := part.Map().Load(fragmentName(kind, name))
+	if ok {
+		return f.(partitions.Fragment), nil
+	}
+	if part.Kind() == partitions.PRIMARY {
+		if f, ok := s.promoteBackup(part, kind, name); ok {
+			return f, nil
+		}
+	}
+
+	engine, err := s.newEngine()
+	if err != nil {
+		return nil, err
+	}
+	var fg partitions.Fragment
+	switch kind {
+	case kindSet:
+		fg = newSetFragment(s, name, part.Kind(), engine)
+	case kindSortedSet:
+		fg = newSortedSetFragment(s, name, part.Kind(), engine)
+	default:
+		return nil, fmt.Errorf("invalid set kind: %s", kind)
+	}
+	part.Map().Store(fragmentName(kind, name), fg)
+	return fg, nil
+}
+
+func (s *Service) loadFragment(part *partitions.Partition, kind, name string) (partitions.Fragment, bool) {
+	f, ok := part.Map().Load(fragmentName(kind, name))
+	if ok {
+		return f.(partitions.Fragment), true
+	}
+
+	part.Lock()
+	defer part.Unlock()
+
+	f, ok = part.Map().Load(fragmentName(kind, name))
+	if ok {
+		return f.(partitions.Fragment), true
+	}
+	return s.promoteBackup(part, kind, name)
+}
+
+// promoteBackup moves the backup of a set to the primary partition. It's the
+// only copy of the set if the previous partition owner is gone. The caller
+// has to hold the lock of the primary partition.
+func (s *Service) promoteBackup(part *partitions.Partition, kind, name string) (partitions.Fragment, bool) {
+	backup := s.backup.PartitionByID(part.ID())
+	f, ok := backup.Map().Load(fragmentName(kind, name))
+	if !ok {
+		return nil, false
+	}
+	backup.Map().Delete(fragmentName(kind, name))
+
+	fg := f.(partitions.Fragment)
+	fg.(interface{ promote() }).promote()
+	part.Map().Store(fragmentName(kind, name), fg)
+	s.log.V(2).Printf("[INFO] Backup of %s: %s is promoted on PartID: %d", fg.Name(), name, part.ID())
+	return fg, true
+}
+
+func (s *Service) loadOrCreateSet(part *partitions.Partition, name string) (*setFragment, error) {
+	f, err := s.loadOrCreateFragment(part, kindSet, name)
+	if err != nil {
+		return nil, err
+	}
+	return f.(*setFragment), nil
+}
+
+func (s *Service) loadSet(part *partitions.Partition, name string) (*setFragment, bool) {
+	f, ok := s.loadFragment(part, kindSet, name)
+	if !ok {
+		return nil, false
+	}
+	return f.(*setFragment), true
+}
+
+// Name returns the name of the set.
+func (st *Set) Name() string {
+	return st.name
+}
+
+func (st *Set) owner() (*partitions.Partition, bool) {
+	part := st.s.primary.PartitionByHKey(st.hkey)
+	return part, part.Owner().CompareByName(st.s.rt.This())
+}
+
+// SAdd adds the members to the set and returns the number of the members
+// that were not in the set.
+func (st *Set) SAdd(ctx context.Context, members ...string) (int, error) {
+	if err := st.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := st.owner()
+	if ok {
+		f, err := st.s.loadOrCreateSet(part, st.name)
+		if err != nil {
+			return 0, err
+		}
+		return f.add(members...)
+	}
+
+	cmd := protocol.NewSAdd(st.name, members...).Command(ctx)
+	rc := st.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	added, err := cmd.Result()
+	return int(added), protocol.ConvertError(err)
+}
+
+// SRem removes the members from the set and returns the number of the removed members.
+func (st *Set) SRem(ctx context.Context, members ...string) (int, error) {
+	if err := st.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := st.owner()
+	if ok {
+		f, ok := st.s.loadSet(part, st.name)
+		if !ok {
+			return 0, nil
+		}
+		return f.remove(members...)
+	}
+
+	cmd := protocol.NewSRem(st.name, members...).Command(ctx)
+	rc := st.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	removed, err := cmd.Result()
+	return int(removed), protocol.ConvertError(err)
+}
+
+// SIsMember returns true if the member is in the set.
+func (st *Set) SIsMember(ctx context.Context, member string) (bool, error) {
+	if err := st.s.rt.CheckBootstrap(); err != nil {
+		return false, err
+	}
+
+	part, ok := st.owner()
+	if ok {
+		f, ok := st.s.loadSet(part, st.name)
+		if !ok {
+			return false, nil
+		}
+		return f.isMember(member), nil
+	}
+
+	cmd := protocol.NewSIsMember(st.name, member).Command(ctx)
+	rc := st.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	ok, err = cmd.Result()
+	return ok, protocol.ConvertError(err)
+}
+
+// SMembers returns all the members of the set in lexicographical order.
+func (st *Set) SMembers(ctx context.Context) ([]string, error) {
+	if err := st.s.rt.CheckBootstrap(); err != nil {
+		return nil, err
+	}
+
+	part, ok := st.owner()
+	if ok {
+		f, ok := st.s.loadSet(part, st.name)
+		if !ok {
+			return []string{}, nil
+		}
+		return f.list(), nil
+	}
+
+	cmd := protocol.NewSMembers(st.name).Command(ctx)
+	rc := st.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	members, err := cmd.Result()
+	return members, protocol.ConvertError(err)
+}
+
+// SCard returns the number of members in the set.
+func (st *Set) SCard(ctx context.Context) (int, error) {
+	if err := st.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := st.owner()
+	if ok {
+		f, ok := st.s.loadSet(part, st.name)
+		if !ok {
+			return 0, nil
+		}
+		return f.length(), nil
+	}
+
+	cmd := protocol.NewSCard(st.name).Command(ctx)
+	rc := st.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	length, err := cmd.Result()
+	return int(length), protocol.ConvertError(err)
+}
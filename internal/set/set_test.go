@@ -0,0 +1,207 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		st, err := s1.NewSet("myset." + strconv.Itoa(i))
+		require.NoError(t, err)
+		added, err := st.SAdd(ctx, "foo", "bar", "foo")
+		require.NoError(t, err)
+		require.Equal(t, 2, added)
+	}
+
+	for i := 0; i < 10; i++ {
+		st, err := s2.NewSet("myset." + strconv.Itoa(i))
+		require.NoError(t, err)
+
+		ok, err := st.SIsMember(ctx, "foo")
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		members, err := st.SMembers(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"bar", "foo"}, members)
+
+		removed, err := st.SRem(ctx, "foo", "baz")
+		require.NoError(t, err)
+		require.Equal(t, 1, removed)
+
+		length, err := st.SCard(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, length)
+	}
+}
+
+func TestSortedSet_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		z, err := s1.NewSortedSet("leaderboard." + strconv.Itoa(i))
+		require.NoError(t, err)
+		added, err := z.ZAdd(ctx,
+			ScoredMember{Member: "foo", Score: 1.5},
+			ScoredMember{Member: "bar", Score: -2},
+			ScoredMember{Member: "baz", Score: 100},
+		)
+		require.NoError(t, err)
+		require.Equal(t, 3, added)
+	}
+
+	for i := 0; i < 10; i++ {
+		z, err := s2.NewSortedSet("leaderboard." + strconv.Itoa(i))
+		require.NoError(t, err)
+
+		members, err := z.ZRangeByScore(ctx, -10, 10)
+		require.NoError(t, err)
+		require.Equal(t, []ScoredMember{
+			{Member: "bar", Score: -2},
+			{Member: "foo", Score: 1.5},
+		}, members)
+
+		rank, err := z.ZRank(ctx, "baz")
+		require.NoError(t, err)
+		require.Equal(t, 2, rank)
+
+		_, err = z.ZRank(ctx, "qux")
+		require.ErrorIs(t, err, ErrNoSuchMember)
+	}
+}
+
+func TestSet_Move(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		st, err := s1.NewSet("myset." + strconv.Itoa(i))
+		require.NoError(t, err)
+		_, err = st.SAdd(ctx, "foo", "bar")
+		require.NoError(t, err)
+
+		z, err := s1.NewSortedSet("leaderboard." + strconv.Itoa(i))
+		require.NoError(t, err)
+		_, err = z.ZAdd(ctx, ScoredMember{Member: "foo", Score: 1})
+		require.NoError(t, err)
+	}
+
+	// This automatically syncs the cluster and moves the sets.
+	s2 := cluster.AddMember(nil).(*Service)
+
+	for i := 0; i < 10; i++ {
+		st, err := s2.NewSet("myset." + strconv.Itoa(i))
+		require.NoError(t, err)
+		length, err := st.SCard(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, length)
+
+		z, err := s2.NewSortedSet("leaderboard." + strconv.Itoa(i))
+		require.NoError(t, err)
+		rank, err := z.ZRank(ctx, "foo")
+		require.NoError(t, err)
+		require.Equal(t, 0, rank)
+	}
+}
+
+func TestSet_Replication(t *testing.T) {
+	newEnvironment := func() *environment.Environment {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		return testcluster.NewEnvironment(c)
+	}
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(newEnvironment()).(*Service)
+	s2 := cluster.AddMember(newEnvironment()).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	st, err := s1.NewSet("myset")
+	require.NoError(t, err)
+	_, err = st.SAdd(ctx, "foo", "bar", "baz")
+	require.NoError(t, err)
+	_, err = st.SRem(ctx, "baz")
+	require.NoError(t, err)
+
+	z, err := s1.NewSortedSet("leaderboard")
+	require.NoError(t, err)
+	_, err = z.ZAdd(ctx, ScoredMember{Member: "foo", Score: 1}, ScoredMember{Member: "bar", Score: 2})
+	require.NoError(t, err)
+	_, err = z.ZAdd(ctx, ScoredMember{Member: "foo", Score: 3})
+	require.NoError(t, err)
+
+	var backup *setFragment
+	var sortedBackup *sortedSetFragment
+	for _, s := range []*Service{s1, s2} {
+		if f, ok := s.backup.PartitionByHKey(st.hkey).Map().Load(fragmentName(kindSet, "myset")); ok {
+			backup = f.(*setFragment)
+		}
+		if f, ok := s.backup.PartitionByHKey(z.hkey).Map().Load(fragmentName(kindSortedSet, "leaderboard")); ok {
+			sortedBackup = f.(*sortedSetFragment)
+		}
+	}
+	require.NotNil(t, backup)
+	require.Equal(t, []string{"bar", "foo"}, backup.list())
+	require.NotNil(t, sortedBackup)
+	require.Equal(t, []ScoredMember{
+		{Member: "bar", Score: 2},
+		{Member: "foo", Score: 3},
+	}, sortedBackup.rangeByScore(0, 10))
+}
+
+func TestSet_Promote_Backup(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	st, err := s.NewSet("myset")
+	require.NoError(t, err)
+
+	// The previous owner is gone, only the backup is left on this member.
+	part := s.backup.PartitionByHKey(st.hkey)
+	f, err := s.loadOrCreateSet(part, "myset")
+	require.NoError(t, err)
+	_, err = f.add("foo")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ok, err := st.SIsMember(ctx, "foo")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok = part.Map().Load(fragmentName(kindSet, "myset"))
+	require.False(t, ok)
+}
@@ -0,0 +1,170 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package set
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// SortedSet is a collection of unique strings that are ordered by their
+// scores. It's stored on the owner of the partition that its name belongs to.
+type SortedSet struct {
+	name string
+	hkey uint64
+	s    *Service
+}
+
+// NewSortedSet creates and returns a new SortedSet instance. The sorted set is
+// created on its partition owner when the first member is added.
+func (s *Service) NewSortedSet(name string) (*SortedSet, error) {
+	return &SortedSet{
+		name: name,
+		hkey: partitions.HKey(kindSortedSet, name),
+		s:    s,
+	}, nil
+}
+
+func (s *Service) loadOrCreateSortedSet(part *partitions.Partition, name string) (*sortedSetFragment, error) {
+	f, err := s.loadOrCreateFragment(part, kindSortedSet, name)
+	if err != nil {
+		return nil, err
+	}
+	return f.(*sortedSetFragment), nil
+}
+
+func (s *Service) loadSortedSet(part *partitions.Partition, name string) (*sortedSetFragment, bool) {
+	f, ok := s.loadFragment(part, kindSortedSet, name)
+	if !ok {
+		return nil, false
+	}
+	return f.(*sortedSetFragment), true
+}
+
+// Name returns the name of the sorted set.
+func (z *SortedSet) Name() string {
+	return z.name
+}
+
+func (z *SortedSet) owner() (*partitions.Partition, bool) {
+	part := z.s.primary.PartitionByHKey(z.hkey)
+	return part, part.Owner().CompareByName(z.s.rt.This())
+}
+
+// ZAdd adds the members to the sorted set or updates their scores. It returns
+// the number of the members that were not in the sorted set.
+func (z *SortedSet) ZAdd(ctx context.Context, members ...ScoredMember) (int, error) {
+	if err := z.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := z.owner()
+	if ok {
+		f, err := z.s.loadOrCreateSortedSet(part, z.name)
+		if err != nil {
+			return 0, err
+		}
+		return f.add(members...)
+	}
+
+	zaddCmd := protocol.NewZAdd(z.name)
+	for _, sm := range members {
+		zaddCmd.Add(sm.Score, sm.Member)
+	}
+	cmd := zaddCmd.Command(ctx)
+	rc := z.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	added, err := cmd.Result()
+	return int(added), protocol.ConvertError(err)
+}
+
+// parseScoredMembers parses the members and scores that are sent consecutively.
+func parseScoredMembers(result []string) ([]ScoredMember, error) {
+	if len(result)%2 != 0 {
+		return nil, fmt.Errorf("invalid number of elements: %d", len(result))
+	}
+	members := make([]ScoredMember, 0, len(result)/2)
+	for i := 0; i < len(result); i += 2 {
+		score, err := strconv.ParseFloat(result[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ScoredMember{Member: result[i], Score: score})
+	}
+	return members, nil
+}
+
+// ZRangeByScore returns the members whose scores are between min and max,
+// inclusive, ordered from the lowest score to the highest.
+func (z *SortedSet) ZRangeByScore(ctx context.Context, min, max float64) ([]ScoredMember, error) {
+	if err := z.s.rt.CheckBootstrap(); err != nil {
+		return nil, err
+	}
+
+	part, ok := z.owner()
+	if ok {
+		f, ok := z.s.loadSortedSet(part, z.name)
+		if !ok {
+			return []ScoredMember{}, nil
+		}
+		return f.rangeByScore(min, max), nil
+	}
+
+	cmd := protocol.NewZRangeByScore(z.name, min, max).Command(ctx)
+	rc := z.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return parseScoredMembers(result)
+}
+
+// ZRank returns the zero-based rank of the member, ordered from the lowest
+// score to the highest. It returns ErrNoSuchMember if the member is not in
+// the sorted set.
+func (z *SortedSet) ZRank(ctx context.Context, member string) (int, error) {
+	if err := z.s.rt.CheckBootstrap(); err != nil {
+		return 0, err
+	}
+
+	part, ok := z.owner()
+	if ok {
+		f, ok := z.s.loadSortedSet(part, z.name)
+		if !ok {
+			return 0, ErrNoSuchMember
+		}
+		return f.rank(member)
+	}
+
+	cmd := protocol.NewZRank(z.name, member).Command(ctx)
+	rc := z.s.client.Get(part.Owner().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	rank, err := cmd.Result()
+	return int(rank), protocol.ConvertError(err)
+}
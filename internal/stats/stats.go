@@ -63,6 +63,11 @@ func (c *Int64Gauge) Decrease(delta int64) {
 	atomic.AddInt64(&c.gauge, -1*delta)
 }
 
+// Set sets the gauge to the given value.
+func (c *Int64Gauge) Set(value int64) {
+	atomic.StoreInt64(&c.gauge, value)
+}
+
 // Read returns the current value of gauge.
 func (c *Int64Gauge) Read() int64 {
 	return atomic.LoadInt64(&c.gauge)
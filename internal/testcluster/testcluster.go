@@ -26,6 +26,7 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/eventbus"
 	"github.com/buraksezer/olric/internal/locker"
 	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/service"
@@ -56,6 +57,7 @@ func NewEnvironment(c *config.Config) *environment.Environment {
 	e.Set("primary", partitions.New(c.PartitionCount, partitions.PRIMARY))
 	e.Set("backup", partitions.New(c.PartitionCount, partitions.BACKUP))
 	e.Set("locker", locker.New())
+	e.Set("eventbus", eventbus.New())
 	e.Set("server", testutil.NewServer(c))
 	return e
 }
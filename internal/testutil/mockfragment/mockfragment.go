@@ -106,6 +106,19 @@ func (f *MockFragment) Move(part *partitions.Partition, name string, owners []di
 	return nil
 }
 
+func (f *MockFragment) Copy(part *partitions.Partition, name string, owners []discovery.Member) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.result[part.Kind()] = map[uint64]Result{
+		part.ID(): {
+			Name:   name,
+			Owners: owners,
+		},
+	}
+	return nil
+}
+
 func (f *MockFragment) Compaction() (bool, error) {
 	return false, nil
 }
@@ -0,0 +1,45 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"github.com/buraksezer/olric/events"
+	"github.com/buraksezer/olric/internal/eventbus"
+)
+
+// LocalEvents delivers the events of the partitions that are owned by this
+// member: *events.EntryUpdatedEvent, *events.FragmentMigrationEvent,
+// *events.FragmentReceivedEvent and *events.BackupPromotedEvent. The events
+// are not encoded and they never leave the process.
+type LocalEvents struct {
+	sub *eventbus.Subscription
+}
+
+// Events returns the channel that the events are delivered to. It's closed
+// after Close is called or the member is shut down.
+func (l *LocalEvents) Events() <-chan events.Event {
+	return l.sub.Events()
+}
+
+// Dropped returns the number of the events that are dropped because the
+// receiver fell behind.
+func (l *LocalEvents) Dropped() int64 {
+	return l.sub.Dropped()
+}
+
+// Close cancels the subscription.
+func (l *LocalEvents) Close() {
+	l.sub.Close()
+}
@@ -0,0 +1,66 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_SubscribeLocalEvents(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	le := e.SubscribeLocalEvents(0)
+	defer le.Close()
+
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+
+	var operations []string
+	for len(operations) < 2 {
+		select {
+		case ev := <-le.Events():
+			updated, ok := ev.(*events.EntryUpdatedEvent)
+			if !ok {
+				continue
+			}
+			require.Equal(t, events.KindEntryUpdatedEvent, updated.Kind)
+			require.Equal(t, "mydmap", updated.Identifier)
+			require.Equal(t, "mykey", updated.Key)
+			require.Equal(t, db.rt.This().String(), updated.Source)
+			operations = append(operations, updated.Operation)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, received %d events", len(operations))
+		}
+	}
+	require.Equal(t, []string{"put", "delete"}, operations)
+	require.Equal(t, int64(0), le.Dropped())
+
+	le.Close()
+	_, ok := <-le.Events()
+	require.False(t, ok)
+}
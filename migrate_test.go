@@ -0,0 +1,135 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_Migrate(t *testing.T) {
+	source := newTestOlricCluster(t)
+	db1 := source.addMember(t)
+	db2 := source.addMember(t)
+
+	target := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.PartitionCount = 13
+	db3 := target.addMemberWithConfig(t, c, "mydmap")
+	c = testutil.NewConfig()
+	c.PartitionCount = 13
+	target.addMemberWithConfig(t, c, "mydmap")
+
+	ctx := context.Background()
+	e := db1.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ts := time.Now().Add(-time.Hour).UnixNano()
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i, TS(ts+int64(i)))
+		require.NoError(t, err)
+	}
+	_, err = dm.Put(ctx, "with-ttl", "value", EX(time.Hour))
+	require.NoError(t, err)
+	_, err = dm.Put(ctx, "expired", "value", PX(time.Millisecond))
+	require.NoError(t, err)
+	<-time.After(5 * time.Millisecond)
+
+	count, err := e.Migrate(ctx, db3.rt.This().String())
+	require.NoError(t, err)
+	require.Equal(t, 101, count)
+
+	migrated, err := db3.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		gr, err := migrated.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err, fmt.Sprintf("key: %s", testutil.ToKey(i)))
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+		require.Equal(t, ts+int64(i), gr.Timestamp())
+	}
+
+	gr, err := migrated.Get(ctx, "with-ttl")
+	require.NoError(t, err)
+	require.NotEqual(t, int64(0), gr.TTL())
+
+	_, err = migrated.Get(ctx, "expired")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEmbeddedClient_Migrate_Batches(t *testing.T) {
+	// A single partition, so the fragment is sent in several batches.
+	source := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.PartitionCount = 1
+	db1 := source.addMemberWithConfig(t, c, "mydmap")
+
+	target := newTestOlricCluster(t)
+	db2 := target.addMemberWithConfig(t, nil, "mydmap")
+
+	ctx := context.Background()
+	e := db1.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 250; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	count, err := e.Migrate(ctx, db2.rt.This().String())
+	require.NoError(t, err)
+	require.Equal(t, 250, count)
+
+	migrated, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 250; i++ {
+		gr, err := migrated.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
+
+func TestMigrate_Put_TS_ClientConnection(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMemberWithConfig(t, nil, "mydmap")
+
+	ctx := context.Background()
+	rc := redis.NewClient(&redis.Options{Addr: db.rt.This().String()})
+	defer func() {
+		require.NoError(t, rc.Close())
+	}()
+
+	// TS is reserved for the cluster members.
+	cmd := protocol.NewPut("mydmap", "mykey", []byte("myvalue")).SetTimestamp(100).Command(ctx)
+	err := rc.Process(ctx, cmd)
+	require.Error(t, err)
+
+	cmd = protocol.NewPut("mydmap", "mykey", []byte("myvalue")).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	require.NoError(t, err)
+}
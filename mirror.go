@@ -0,0 +1,185 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/pkg/flog"
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	// maxPendingMirrors is the maximum number of the mirrored commands that
+	// are waiting for the target DMap. The commands are dropped if it's exceeded.
+	maxPendingMirrors = 1024
+
+	mirrorTimeout = 5 * time.Second
+)
+
+// MirrorStats is a snapshot of the counters of a DMap that mirrors its
+// writes, see Mirror.
+type MirrorStats struct {
+	// Mirrored is the number of commands that are applied on the target.
+	Mirrored int64
+
+	// Failed is the number of commands that are returned an error by the target.
+	Failed int64
+
+	// Dropped is the number of commands that are not mirrored because there
+	// were too many pending commands.
+	Dropped int64
+}
+
+type mirrorCommand struct {
+	name string
+	f    func(ctx context.Context, target DMap) error
+}
+
+// mirror sends a sample of the writes to a second DMap in the background.
+// The keys are sampled by their hashes, so the writes of a key are either
+// mirrored or skipped all together. The commands are applied in order by a
+// single goroutine, it exits when there is nothing to send.
+type mirror struct {
+	target    DMap
+	threshold uint64
+	log       *flog.Logger
+
+	mtx     sync.Mutex
+	pending []mirrorCommand
+	running bool
+
+	mirrored int64
+	failed   int64
+	dropped  int64
+}
+
+func newMirror(target DMap, sampleRate float64, log *flog.Logger) *mirror {
+	var threshold uint64
+	switch {
+	case sampleRate >= 1:
+		threshold = math.MaxUint64
+	case sampleRate > 0:
+		threshold = uint64(sampleRate * math.MaxUint64)
+	}
+	return &mirror{
+		target:    target,
+		threshold: threshold,
+		log:       log,
+	}
+}
+
+func (m *mirror) sampled(key string) bool {
+	if m.threshold == 0 {
+		return false
+	}
+	if m.threshold == math.MaxUint64 {
+		return true
+	}
+	return xxhash.Sum64String(key) < m.threshold
+}
+
+// send queues the command to run it on the target DMap asynchronously. The
+// errors are not returned to the caller, they are logged and counted.
+func (m *mirror) send(name string, f func(ctx context.Context, target DMap) error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if len(m.pending) >= maxPendingMirrors {
+		atomic.AddInt64(&m.dropped, 1)
+		return
+	}
+	m.pending = append(m.pending, mirrorCommand{name: name, f: f})
+	if !m.running {
+		m.running = true
+		go m.drain()
+	}
+}
+
+func (m *mirror) drain() {
+	for {
+		m.mtx.Lock()
+		if len(m.pending) == 0 {
+			m.running = false
+			m.mtx.Unlock()
+			return
+		}
+		cmd := m.pending[0]
+		m.pending = m.pending[1:]
+		m.mtx.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		err := cmd.f(ctx, m.target)
+		cancel()
+		if err != nil {
+			atomic.AddInt64(&m.failed, 1)
+			m.log.V(3).Printf("[ERROR] Failed to mirror %s to DMap: %s: %v", cmd.name, m.target.Name(), err)
+			continue
+		}
+		atomic.AddInt64(&m.mirrored, 1)
+	}
+}
+
+func (m *mirror) put(key string, value interface{}, options []PutOption) {
+	if !m.sampled(key) {
+		return
+	}
+	m.send("Put", func(ctx context.Context, target DMap) error {
+		_, err := target.Put(ctx, key, value, options...)
+		return err
+	})
+}
+
+func (m *mirror) delete(keys []string) {
+	var sampled []string
+	for _, key := range keys {
+		if m.sampled(key) {
+			sampled = append(sampled, key)
+		}
+	}
+	if len(sampled) == 0 {
+		return
+	}
+	m.send("Delete", func(ctx context.Context, target DMap) error {
+		_, err := target.Delete(ctx, sampled...)
+		return err
+	})
+}
+
+func (m *mirror) expire(key string, timeout time.Duration) {
+	if !m.sampled(key) {
+		return
+	}
+	m.send("Expire", func(ctx context.Context, target DMap) error {
+		err := target.Expire(ctx, key, timeout)
+		if err == ErrKeyNotFound {
+			// The key may have been written before mirroring was started.
+			return nil
+		}
+		return err
+	})
+}
+
+func (m *mirror) stats() MirrorStats {
+	return MirrorStats{
+		Mirrored: atomic.LoadInt64(&m.mirrored),
+		Failed:   atomic.LoadInt64(&m.failed),
+		Dropped:  atomic.LoadInt64(&m.dropped),
+	}
+}
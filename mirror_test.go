@@ -0,0 +1,78 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_DMap_Mirror(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	shadow, err := e.NewDMap("shadow")
+	require.NoError(t, err)
+
+	dm, err := e.NewDMap("mydmap", Mirror(shadow, 1))
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+	_, err = dm.Delete(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+
+	edm := dm.(*EmbeddedDMap)
+	require.Eventually(t, func() bool {
+		return edm.MirrorStats().Mirrored == 11
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = shadow.Get(ctx, testutil.ToKey(0))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	for i := 1; i < 10; i++ {
+		gr, err := shadow.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
+
+func TestEmbeddedClient_DMap_Mirror_Sampling(t *testing.T) {
+	m := newMirror(nil, 0, nil)
+	require.False(t, m.sampled("mykey"))
+
+	m = newMirror(nil, 1, nil)
+	require.True(t, m.sampled("mykey"))
+
+	m = newMirror(nil, 0.5, nil)
+	var sampled int
+	for i := 0; i < 1000; i++ {
+		if m.sampled(testutil.ToKey(i)) {
+			sampled++
+		}
+	}
+	require.InDelta(t, 500, sampled, 100)
+	// The decision is stable for a key.
+	require.Equal(t, m.sampled("mykey"), m.sampled("mykey"))
+}
@@ -0,0 +1,81 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"github.com/buraksezer/olric/config"
+)
+
+// Namespace isolates the DMaps of a tenant. The DMaps of different namespaces
+// never collide even if they have the same name. The quotas and the access
+// rules of a namespace are set by config.Config.Namespaces.
+//
+// A DMap of a namespace is stored with its namespace prefixed name, like
+// "team-a/cache", DMap.Name returns that name. If the namespace has an ACL,
+// EmbeddedClient.NewDMap cannot create its DMaps with that name, they have to
+// be created by a namespace that is opened with one of its tokens.
+type Namespace struct {
+	name   string
+	token  string
+	client *EmbeddedClient
+}
+
+// NamespaceOption is a function for defining options to control the behavior
+// of a namespace.
+type NamespaceOption func(*Namespace)
+
+// WithNamespaceToken sets the access token of the namespace, it's checked
+// against the ACL of the namespace. The DMaps opened with a read-only token
+// return ErrNamespaceReadOnly for the writes.
+func WithNamespaceToken(token string) NamespaceOption {
+	return func(n *Namespace) {
+		n.token = token
+	}
+}
+
+// Namespace returns the namespace with the given name. The name cannot be
+// empty or contain config.NamespaceSeparator.
+func (e *EmbeddedClient) Namespace(name string, options ...NamespaceOption) *Namespace {
+	n := &Namespace{
+		name:   name,
+		client: e,
+	}
+	for _, opt := range options {
+		opt(n)
+	}
+	return n
+}
+
+// Name returns the name of the namespace.
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+// NewDMap creates and returns a new DMap instance in the namespace.
+func (n *Namespace) NewDMap(name string, options ...DMapOption) (DMap, error) {
+	if err := config.ValidateNamespaceName(n.name); err != nil {
+		return nil, err
+	}
+	return n.client.newDMap(n.dmapName(name), n.token, options...)
+}
+
+// DeleteDMap deletes the DMap instance of the namespace from the local process.
+func (n *Namespace) DeleteDMap(name string) error {
+	return n.client.DeleteDMap(n.dmapName(name))
+}
+
+func (n *Namespace) dmapName(name string) string {
+	return n.name + config.NamespaceSeparator + name
+}
@@ -0,0 +1,101 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace_NewDMap(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.Namespaces = map[string]config.Namespace{"team-b": {MaxKeys: 1}}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+
+	dmA, err := e.Namespace("team-a").NewDMap("cache")
+	require.NoError(t, err)
+	require.Equal(t, "team-a/cache", dmA.Name())
+	dmB, err := e.Namespace("team-b").NewDMap("cache")
+	require.NoError(t, err)
+
+	_, err = dmA.Put(ctx, "mykey", "team-a")
+	require.NoError(t, err)
+	_, err = dmB.Put(ctx, "mykey", "team-b")
+	require.NoError(t, err)
+
+	gr, err := dmA.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "team-a", value)
+
+	_, err = dmB.Put(ctx, "otherkey", "team-b")
+	require.ErrorIs(t, err, ErrNamespaceQuotaExceeded)
+
+	st, err := e.Stats(ctx, db.rt.This().String())
+	require.NoError(t, err)
+	require.Equal(t, 1, st.Namespaces["team-a"].Length)
+	require.Equal(t, 1, st.Namespaces["team-b"].Length)
+
+	_, err = e.Namespace("team/c").NewDMap("cache")
+	require.Error(t, err)
+}
+
+func TestNamespace_ACL(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.Namespaces = map[string]config.Namespace{
+		"team-a": {
+			ACL: map[string]config.NamespaceAccess{
+				"writer": config.NamespaceReadWrite,
+				"reader": config.NamespaceReadOnly,
+			},
+		},
+	}
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+
+	// The namespace cannot be bypassed with the prefixed name.
+	_, err := e.NewDMap("team-a/cache")
+	require.ErrorIs(t, err, ErrNamespaceAccessDenied)
+	_, err = e.Namespace("team-a").NewDMap("cache")
+	require.ErrorIs(t, err, ErrNamespaceAccessDenied)
+	_, err = e.Namespace("team-a", WithNamespaceToken("unknown")).NewDMap("cache")
+	require.ErrorIs(t, err, ErrNamespaceAccessDenied)
+
+	writer, err := e.Namespace("team-a", WithNamespaceToken("writer")).NewDMap("cache")
+	require.NoError(t, err)
+	_, err = writer.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	reader, err := e.Namespace("team-a", WithNamespaceToken("reader")).NewDMap("cache")
+	require.NoError(t, err)
+	_, err = reader.Get(ctx, "mykey")
+	require.NoError(t, err)
+	_, err = reader.Put(ctx, "mykey", "othervalue")
+	require.ErrorIs(t, err, ErrNamespaceReadOnly)
+	_, err = reader.Delete(ctx, "mykey")
+	require.ErrorIs(t, err, ErrNamespaceReadOnly)
+}
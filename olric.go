@@ -35,6 +35,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/buraksezer/olric/config"
@@ -43,12 +44,18 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/balancer"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
+	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/dmap"
 	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/eventbus"
+	"github.com/buraksezer/olric/internal/hlc"
 	"github.com/buraksezer/olric/internal/locker"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/pubsub"
+	"github.com/buraksezer/olric/internal/queue"
+	"github.com/buraksezer/olric/internal/schema"
 	"github.com/buraksezer/olric/internal/server"
+	"github.com/buraksezer/olric/internal/set"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/hashicorp/logutils"
 	"github.com/pkg/errors"
@@ -97,6 +104,185 @@ var (
 	// ErrConnRefused returned if the target node refused a connection request.
 	// It is good to call RefreshMetadata to update the underlying data structures.
 	ErrConnRefused = errors.New("connection refused")
+
+	// ErrChangeLogDisabled is returned by Watch if the change log is not
+	// configured for the DMap. See config.DMaps.ChangeLogSize.
+	ErrChangeLogDisabled = errors.New("change log is disabled")
+
+	// ErrSequenceTooOld is returned when the resume token points to mutations
+	// that have already been dropped from the change log of a partition, or
+	// the change log is lost with its partition owner.
+	ErrSequenceTooOld = errors.New("sequence is no longer in the change log")
+
+	// ErrInvalidKey is returned when a key is rejected by KeyPattern or
+	// KeyValidator of the DMap.
+	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrSchemaMismatch is returned when a value doesn't match the ValueSchema
+	// of the DMap.
+	ErrSchemaMismatch = errors.New("value does not match the schema")
+
+	// ErrMemberNotFound is returned when the given member is not in the cluster.
+	ErrMemberNotFound = errors.New("member not found")
+
+	// ErrMemberDrained is returned when a partition is moved to a drained member.
+	ErrMemberDrained = errors.New("member is drained")
+
+	// ErrQueueEmpty is returned when there is no visible message in the queue.
+	ErrQueueEmpty = errors.New("queue is empty")
+
+	// ErrNoSuchMessage is returned when an acknowledged message is not in-flight
+	// anymore. Its visibility timeout may be expired.
+	ErrNoSuchMessage = errors.New("no such message")
+
+	// ErrStaleRead is returned by a DMap with MonotonicReads when a read returns
+	// an older version of the key than a previous read.
+	ErrStaleRead = errors.New("stale read")
+
+	// ErrNoSuchMember is returned when the member is not in the sorted set.
+	ErrNoSuchMember = errors.New("no such member")
+
+	// ErrNotInteger is returned when an increment is applied to a value that
+	// is not an integer.
+	ErrNotInteger = errors.New("value is not an integer")
+
+	// ErrFieldNotFound is returned when the field is not in the hash.
+	ErrFieldNotFound = errors.New("field not found")
+
+	// ErrNotHash is returned when a hash operation is applied to a value that
+	// is not a hash.
+	ErrNotHash = errors.New("value is not a hash")
+
+	// ErrProcessorNotFound is returned by Execute if the entry processor is
+	// not registered on the owner of the key.
+	ErrProcessorNotFound = errors.New("entry processor not found")
+
+	// ErrInvalidFilter is returned by Query when the filter cannot be parsed.
+	ErrInvalidFilter = errors.New("invalid filter")
+
+	// ErrBootstrapQuorum is returned for the writes until the member has seen
+	// config.Config.BootstrapQuorum members.
+	ErrBootstrapQuorum = errors.New("bootstrap quorum has not been reached")
+
+	// ErrShuttingDown is returned when the member is draining before
+	// shutdown. The request can be retried on the other members.
+	ErrShuttingDown = errors.New("server is shutting down")
+
+	// ErrDeadlineExceeded is returned when the deadline of the context or the
+	// server-side timeout of the command has passed before the command is
+	// completed on the partition owner. See config.Config.CommandTimeout.
+	ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+	// ErrAccessStatsDisabled is returned by Hottest and Coldest if the access
+	// statistics are not enabled for the DMap. See config.DMap.AccessSampleRate.
+	ErrAccessStatsDisabled = errors.New("access statistics are disabled")
+
+	// ErrHotKeysDisabled is returned by HotKeys if the hot key tracking is not
+	// enabled for the DMap. See config.DMap.HotKeysWindow.
+	ErrHotKeysDisabled = errors.New("hot key tracking is disabled")
+
+	// ErrNoBackup is returned by Get and GetEntry if the reads are served by
+	// the backup owners but the partition of the key has no backup owner. See
+	// ReadFrom and BackupsOnly.
+	ErrNoBackup = errors.New("no backup owner")
+
+	// ErrSnapshotNotFound is returned by Undo if there is no snapshot of the
+	// destroyed DMap. See config.DMaps.DestroySnapshotRetention.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+
+	// ErrLoaderFailed is returned when the Loader of the DMap returns an error
+	// on a cache miss. See config.DMap.Loader.
+	ErrLoaderFailed = errors.New("loader failed")
+
+	// ErrWriterFailed is returned when the Writer of the DMap returns an error
+	// in write-through mode. See config.DMap.Writer.
+	ErrWriterFailed = errors.New("writer failed")
+
+	// ErrTransformFailed is returned when a stage of the value transformation
+	// pipeline of the DMap returns an error. See config.DMap.Transformers.
+	ErrTransformFailed = errors.New("value transformation failed")
+
+	// ErrStaleEpoch is returned when a write is rejected by the backup owners
+	// because the partition owner that applied it is deposed. It's retried by
+	// the default retry policy.
+	ErrStaleEpoch = errors.New("partition epoch is stale")
+
+	// ErrOutOfMemory is returned when a write would exceed the memory budget
+	// of the storage engine on the partition owner. See config.Engine.
+	ErrOutOfMemory = errors.New("out of memory")
+
+	// ErrTombstonesDisabled is returned by GetTombstone if the DMap has no
+	// tombstone retention configured. See config.DMap.TombstoneRetention.
+	ErrTombstonesDisabled = errors.New("tombstones are disabled")
+
+	// ErrTxConflict is returned by DMap.Tx if a key that is read by the
+	// transaction is modified before it's committed.
+	ErrTxConflict = errors.New("transaction conflict")
+
+	// ErrCrossPartitionTx is returned by DMap.Tx if the keys of a transaction
+	// are on different partitions. Enable HashTags for the DMap to
+	// co-locate the keys with hash tags.
+	ErrCrossPartitionTx = errors.New("keys of a transaction must be on the same partition")
+
+	// ErrTxPutOption is returned by Tx.Put if an option other than EX, PX,
+	// EXAT and PXAT is given.
+	ErrTxPutOption = errors.New("only EX, PX, EXAT and PXAT are supported in transactions")
+
+	// ErrStaleRoutingTable is returned by a linearizable read if the routing
+	// table of the partition owner is not the latest one. See LinearizableReads.
+	ErrStaleRoutingTable = errors.New("routing table is stale")
+
+	// ErrRateLimited is returned if an operation exceeds the rate limits of
+	// the DMap. See config.DMap.RateLimits.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrClockDrift is returned if a member receives a timestamp that is too
+	// far ahead of its wall clock. See config.Config.MaxClockDrift.
+	ErrClockDrift = errors.New("clock drift exceeds the limit")
+
+	// ErrPublisherUnavailable is returned by DMap.SetAndPublish if Pub/Sub is
+	// not available on the partition owner.
+	ErrPublisherUnavailable = errors.New("pub/sub is not available")
+
+	// ErrNamespaceReadOnly is returned by the writes to the DMaps of a
+	// read-only namespace. See config.Namespace.
+	ErrNamespaceReadOnly = errors.New("namespace is read-only")
+
+	// ErrNamespaceQuotaExceeded is returned if a write exceeds the quotas of
+	// the namespace of the DMap. See config.Namespace.
+	ErrNamespaceQuotaExceeded = errors.New("namespace quota exceeded")
+
+	// ErrNamespaceAccessDenied is returned if a DMap of a namespace with an
+	// ACL is created without one of its tokens. See config.Namespace.
+	ErrNamespaceAccessDenied = errors.New("namespace access denied")
+
+	// ErrMaxKeysExceeded is returned if a write of a new key exceeds MaxKeys
+	// of the DMap. See config.QuotaReject.
+	ErrMaxKeysExceeded = errors.New("max keys exceeded")
+
+	// ErrMaxInuseExceeded is returned if a write exceeds MaxInuse of the
+	// DMap. See config.QuotaReject.
+	ErrMaxInuseExceeded = errors.New("max inuse exceeded")
+
+	// ErrValueTooLarge is returned if a value exceeds MaxValueSize of the
+	// DMap.
+	ErrValueTooLarge = errors.New("value too large")
+
+	// ErrChunkedValueOption is returned if a value that exceeds ChunkSize of
+	// the DMap is written with NX, XX or a sliding TTL.
+	ErrChunkedValueOption = errors.New("NX, XX and SLIDING are not supported for chunked values")
+
+	// ErrResponseTooLarge is returned by DMap.Query if the result exceeds
+	// config.Config.MaxResponseSize. Use DMap.QueryPage then.
+	ErrResponseTooLarge = errors.New("response too large")
+
+	// ErrInvalidExport is returned by DMap.Import if the stream is not written
+	// by DMap.Export or its version is not supported.
+	ErrInvalidExport = errors.New("invalid export")
+
+	// ErrTooManyFailures is returned by DMap.GetMulti if more keys than
+	// allowed by MaxFailures cannot be read.
+	ErrTooManyFailures = errors.New("too many failures")
 )
 
 // Olric implements a distributed cache and in-memory key/value data store.
@@ -110,6 +296,10 @@ type Olric struct {
 	log      *flog.Logger
 	hashFunc hasher.Hasher
 
+	// runtime keeps the latest configuration applied by ReloadConfig.
+	runtime   atomic.Value // *config.Config
+	reloadMtx sync.Mutex
+
 	// Logical units to store data
 	primary *partitions.Partitions
 	backup  *partitions.Partitions
@@ -120,9 +310,19 @@ type Olric struct {
 
 	rt       *routingtable.RoutingTable
 	balancer *balancer.Balancer
+	eventBus *eventbus.Bus
 
 	pubsub *pubsub.Service
 	dmap   *dmap.Service
+	queue  *queue.Service
+	set    *set.Service
+
+	// HTTP admin API, nil if config.AdminAddr is empty.
+	admin *adminServer
+
+	// The last counters of the stats delta sessions, by session id.
+	statsSessionsMtx sync.Mutex
+	statsSessions    map[string]*statsSession
 
 	// Structures for flow control
 	ctx    context.Context
@@ -178,6 +378,7 @@ func initializeServices(db *Olric) error {
 		return err
 	}
 	db.pubsub = dt.(*pubsub.Service)
+	db.env.Set("pubsub", db.pubsub)
 
 	dm, err := dmap.NewService(db.env)
 	if err != nil {
@@ -185,6 +386,18 @@ func initializeServices(db *Olric) error {
 	}
 	db.dmap = dm.(*dmap.Service)
 
+	qs, err := queue.NewService(db.env)
+	if err != nil {
+		return err
+	}
+	db.queue = qs.(*queue.Service)
+
+	ss, err := set.NewService(db.env)
+	if err != nil {
+		return err
+	}
+	db.set = ss.(*set.Service)
+
 	return nil
 }
 
@@ -214,6 +427,7 @@ func New(c *config.Config) (*Olric, error) {
 	e.Set("primary", partitions.New(c.PartitionCount, partitions.PRIMARY))
 	e.Set("backup", partitions.New(c.PartitionCount, partitions.BACKUP))
 	e.Set("locker", locker.New())
+	e.Set("eventbus", eventbus.New())
 	ctx, cancel := context.WithCancel(context.Background())
 	db := &Olric{
 		name:     c.MemberlistConfig.Name,
@@ -224,16 +438,25 @@ func New(c *config.Config) (*Olric, error) {
 		client:   client,
 		primary:  e.Get("primary").(*partitions.Partitions),
 		backup:   e.Get("backup").(*partitions.Partitions),
+		eventBus: e.Get("eventbus").(*eventbus.Bus),
 		started:  c.Started,
 		ctx:      ctx,
 		cancel:   cancel,
+
+		statsSessions: make(map[string]*statsSession),
 	}
+	db.runtime.Store(c)
 
 	// Create a Redcon server instance
 	rc := &server.Config{
-		BindAddr:        c.BindAddr,
-		BindPort:        c.BindPort,
-		KeepAlivePeriod: c.KeepAlivePeriod,
+		BindAddr:           c.BindAddr,
+		BindPort:           c.BindPort,
+		KeepAlivePeriod:    c.KeepAlivePeriod,
+		SlowLogThreshold:   c.SlowLogThreshold,
+		SlowLogMaxLen:      c.SlowLogMaxLen,
+		WriteCoalesceDelay: c.WriteCoalesceDelay,
+		CommandTimeout:     c.CommandTimeout,
+		CommandTimeouts:    c.CommandTimeouts,
 	}
 	srv := server.New(rc, flogger)
 	srv.SetPreConditionFunc(db.preconditionFunc)
@@ -248,6 +471,10 @@ func New(c *config.Config) (*Olric, error) {
 
 	db.registerCommandHandlers()
 
+	if c.AdminAddr != "" {
+		db.admin = newAdminServer(db)
+	}
+
 	return db, nil
 }
 
@@ -264,7 +491,16 @@ func (db *Olric) registerCommandHandlers() {
 	db.server.ServeMux().HandleFunc(protocol.Generic.Ping, db.pingCommandHandler)
 	db.server.ServeMux().HandleFunc(protocol.Cluster.RoutingTable, db.clusterRoutingTableCommandHandler)
 	db.server.ServeMux().HandleFunc(protocol.Generic.Stats, db.statsCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Generic.SlowLog, db.slowLogCommandHandler)
 	db.server.ServeMux().HandleFunc(protocol.Cluster.Members, db.clusterMembersCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.PauseRebalancing, db.pauseRebalancingCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.ResumeRebalancing, db.resumeRebalancingCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.MovePartition, db.movePartitionCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.Drain, db.drainCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.RebalanceStatus, db.rebalanceStatusCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.OwnershipHistory, db.ownershipHistoryCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.Compact, db.compactCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.Rebalance, db.rebalanceCommandHandler)
 }
 
 // callStartedCallback checks passed checkpoint count and calls the callback
@@ -295,10 +531,22 @@ func convertClusterError(err error) error {
 	switch {
 	case errors.Is(err, routingtable.ErrClusterQuorum):
 		return ErrClusterQuorum
+	case errors.Is(err, routingtable.ErrBootstrapQuorum):
+		return ErrBootstrapQuorum
 	case errors.Is(err, routingtable.ErrServerGone):
 		return ErrServerGone
 	case errors.Is(err, routingtable.ErrOperationTimeout):
 		return ErrOperationTimeout
+	case errors.Is(err, routingtable.ErrMemberDrained):
+		return ErrMemberDrained
+	case errors.Is(err, routingtable.ErrStaleRoutingTable):
+		return ErrStaleRoutingTable
+	case errors.Is(err, server.ErrShuttingDown):
+		return ErrShuttingDown
+	case errors.Is(err, server.ErrDeadlineExceeded):
+		return ErrDeadlineExceeded
+	case errors.Is(err, discovery.ErrMemberNotFound):
+		return ErrMemberNotFound
 	default:
 		return err
 	}
@@ -365,6 +613,18 @@ func (db *Olric) Start() error {
 		return err
 	}
 
+	// Start distributed queue service
+	if err := db.queue.Start(); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to run the Distributed Queue service: %v", err)
+		return err
+	}
+
+	// Start distributed set service
+	if err := db.set.Start(); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to run the Distributed Set service: %v", err)
+		return err
+	}
+
 	// Warn the user about his/her choice of configuration
 	if db.config.ReplicationMode == config.AsyncReplicationMode && db.config.WriteQuorum > 1 {
 		db.log.V(2).
@@ -372,6 +632,13 @@ func (db *Olric) Start() error {
 				db.config.WriteQuorum)
 	}
 
+	if db.admin != nil {
+		if err := db.admin.start(); err != nil {
+			db.log.V(2).Printf("[ERROR] Failed to run the admin API: %v", err)
+			return err
+		}
+	}
+
 	if db.started != nil {
 		db.wg.Add(1)
 		go db.callStartedCallback()
@@ -400,10 +667,21 @@ func (db *Olric) Shutdown(ctx context.Context) error {
 	default:
 	}
 
+	if db.config.ShutdownDrainTimeout > 0 {
+		db.drainBeforeShutdown(ctx)
+	}
+
 	db.cancel()
 
 	var latestError error
 
+	if db.admin != nil {
+		if err := db.admin.shutdown(ctx); err != nil {
+			db.log.V(2).Printf("[ERROR] Failed to shutdown the admin API: %v", err)
+			latestError = err
+		}
+	}
+
 	if err := db.pubsub.Shutdown(ctx); err != nil {
 		db.log.V(2).Printf("[ERROR] Failed to shutdown PubSub service: %v", err)
 		latestError = err
@@ -414,6 +692,16 @@ func (db *Olric) Shutdown(ctx context.Context) error {
 		latestError = err
 	}
 
+	if err := db.queue.Shutdown(ctx); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to shutdown Queue service: %v", err)
+		latestError = err
+	}
+
+	if err := db.set.Shutdown(ctx); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to shutdown Set service: %v", err)
+		latestError = err
+	}
+
 	if err := db.balancer.Shutdown(ctx); err != nil {
 		db.log.V(2).Printf("[ERROR] Failed to shutdown balancer service: %v", err)
 		latestError = err
@@ -424,6 +712,9 @@ func (db *Olric) Shutdown(ctx context.Context) error {
 		latestError = err
 	}
 
+	// Close the local event subscriptions.
+	db.eventBus.Close()
+
 	// Shutdown Redcon server
 	if err := db.server.Shutdown(ctx); err != nil {
 		db.log.V(2).Printf("[ERROR] Failed to shutdown RESP server: %v", err)
@@ -449,6 +740,17 @@ func (db *Olric) Shutdown(ctx context.Context) error {
 	return latestError
 }
 
+func convertQueueError(err error) error {
+	switch {
+	case errors.Is(err, queue.ErrQueueEmpty):
+		return ErrQueueEmpty
+	case errors.Is(err, queue.ErrNoSuchMessage):
+		return ErrNoSuchMessage
+	default:
+		return convertClusterError(err)
+	}
+}
+
 func convertDMapError(err error) error {
 	switch {
 	case errors.Is(err, dmap.ErrKeyFound):
@@ -471,6 +773,83 @@ func convertDMapError(err error) error {
 		return ErrKeyTooLarge
 	case errors.Is(err, dmap.ErrEntryTooLarge):
 		return ErrEntryTooLarge
+	case errors.Is(err, dmap.ErrChangeLogDisabled):
+		return ErrChangeLogDisabled
+	case errors.Is(err, dmap.ErrSequenceTooOld):
+		return ErrSequenceTooOld
+	case errors.Is(err, dmap.ErrInvalidKey):
+		return ErrInvalidKey
+	case errors.Is(err, schema.ErrMismatch):
+		return ErrSchemaMismatch
+	case errors.Is(err, dmap.ErrNotInteger):
+		return ErrNotInteger
+	case errors.Is(err, dmap.ErrFieldNotFound):
+		return ErrFieldNotFound
+	case errors.Is(err, dmap.ErrNotHash):
+		return ErrNotHash
+	case errors.Is(err, dmap.ErrProcessorNotFound):
+		return ErrProcessorNotFound
+	case errors.Is(err, dmap.ErrInvalidFilter):
+		return ErrInvalidFilter
+	case errors.Is(err, dmap.ErrAccessStatsDisabled):
+		return ErrAccessStatsDisabled
+	case errors.Is(err, dmap.ErrHotKeysDisabled):
+		return ErrHotKeysDisabled
+	case errors.Is(err, dmap.ErrNoBackup):
+		return ErrNoBackup
+	case errors.Is(err, dmap.ErrSnapshotNotFound):
+		return ErrSnapshotNotFound
+	case errors.Is(err, dmap.ErrLoaderFailed):
+		return ErrLoaderFailed
+	case errors.Is(err, dmap.ErrWriterFailed):
+		return ErrWriterFailed
+	case errors.Is(err, dmap.ErrTransformFailed):
+		return ErrTransformFailed
+	case errors.Is(err, dmap.ErrStaleEpoch):
+		return ErrStaleEpoch
+	case errors.Is(err, dmap.ErrOutOfMemory):
+		return ErrOutOfMemory
+	case errors.Is(err, dmap.ErrTombstonesDisabled):
+		return ErrTombstonesDisabled
+	case errors.Is(err, dmap.ErrTxConflict):
+		return ErrTxConflict
+	case errors.Is(err, dmap.ErrCrossPartitionTx):
+		return ErrCrossPartitionTx
+	case errors.Is(err, dmap.ErrTxPutOption):
+		return ErrTxPutOption
+	case errors.Is(err, dmap.ErrRateLimited):
+		return ErrRateLimited
+	case errors.Is(err, hlc.ErrClockDrift):
+		return ErrClockDrift
+	case errors.Is(err, dmap.ErrPublisherUnavailable):
+		return ErrPublisherUnavailable
+	case errors.Is(err, dmap.ErrNamespaceReadOnly):
+		return ErrNamespaceReadOnly
+	case errors.Is(err, dmap.ErrNamespaceQuotaExceeded):
+		return ErrNamespaceQuotaExceeded
+	case errors.Is(err, dmap.ErrNamespaceAccessDenied):
+		return ErrNamespaceAccessDenied
+	case errors.Is(err, dmap.ErrMaxKeysExceeded):
+		return ErrMaxKeysExceeded
+	case errors.Is(err, dmap.ErrMaxInuseExceeded):
+		return ErrMaxInuseExceeded
+	case errors.Is(err, dmap.ErrValueTooLarge):
+		return ErrValueTooLarge
+	case errors.Is(err, dmap.ErrChunkedValueOption):
+		return ErrChunkedValueOption
+	case errors.Is(err, dmap.ErrResponseTooLarge):
+		return ErrResponseTooLarge
+	case errors.Is(err, dmap.ErrInvalidExport):
+		return ErrInvalidExport
+	default:
+		return convertClusterError(err)
+	}
+}
+
+func convertSetError(err error) error {
+	switch {
+	case errors.Is(err, set.ErrNoSuchMember):
+		return ErrNoSuchMember
 	default:
 		return convertClusterError(err)
 	}
@@ -141,3 +141,27 @@ func TestOlricCluster_StartAndShutdown(t *testing.T) {
 		require.Contains(t, st.ClusterMembers, stats.MemberID(member.rt.This().ID))
 	}
 }
+
+func TestOlric_BootstrapQuorum(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.BootstrapQuorum = 2
+	db := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.ErrorIs(t, err, ErrBootstrapQuorum)
+	_, err = dm.Delete(ctx, "mykey")
+	require.ErrorIs(t, err, ErrBootstrapQuorum)
+
+	db2 := cluster.addMember(t)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err = dm.Put(ctx, "mykey", "myvalue")
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,115 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"sync"
+)
+
+const (
+	// Gzip is the ID of the built-in gzip codec.
+	Gzip uint8 = 1
+
+	// Flate is the ID of the built-in DEFLATE codec. It's faster than gzip
+	// and it has a smaller overhead, there is no header and checksum.
+	Flate uint8 = 2
+)
+
+type identity struct{}
+
+func (identity) ID() uint8 { return None }
+
+func (identity) Name() string { return "none" }
+
+func (identity) Encode(value []byte) ([]byte, error) { return value, nil }
+
+func (identity) Decode(value []byte) ([]byte, error) { return value, nil }
+
+type gzipCodec struct {
+	writers sync.Pool
+}
+
+func (g *gzipCodec) ID() uint8 { return Gzip }
+
+func (g *gzipCodec) Name() string { return "gzip" }
+
+func (g *gzipCodec) Encode(value []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	w, ok := g.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		w = gzip.NewWriter(buf)
+	}
+	defer g.writers.Put(w)
+
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *gzipCodec) Decode(value []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+type flateCodec struct {
+	writers sync.Pool
+}
+
+func (f *flateCodec) ID() uint8 { return Flate }
+
+func (f *flateCodec) Name() string { return "flate" }
+
+func (f *flateCodec) Encode(value []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	w, ok := f.writers.Get().(*flate.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		var err error
+		w, err = flate.NewWriter(buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer f.writers.Put(w)
+
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *flateCodec) Decode(value []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(value))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
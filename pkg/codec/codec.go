@@ -0,0 +1,139 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package codec implements a registry of value codecs. A codec transforms the
+// value of an entry before it's stored, e.g. compresses it. The ID of the codec
+// is stored alongside the entry, so the value can be decoded on any node,
+// including the backup owners and the new partition owners after a rebalance.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// None is the ID of the identity codec. Values are stored as they are.
+	None uint8 = 0
+
+	// Chunked is reserved for the manifests of the values that are split into
+	// chunks, see config.DMap.ChunkSize. The DMaps reassemble these values
+	// instead of decoding them, so it cannot be registered.
+	Chunked uint8 = 255
+)
+
+var (
+	// ErrCodecExists is returned by Register if a codec with the same ID or
+	// name has already been registered.
+	ErrCodecExists = errors.New("codec already exists")
+
+	// ErrUnknownCodec is returned when a codec is not registered on this node.
+	ErrUnknownCodec = errors.New("unknown codec")
+)
+
+// Codec defines methods to encode and decode the values of entries. Every node
+// in the cluster must register the same codecs with the same IDs.
+type Codec interface {
+	// ID returns the unique identifier of the codec. It's persisted with
+	// the entries, so it must not change between releases.
+	ID() uint8
+
+	// Name returns the name of the codec. It's used in the configuration.
+	Name() string
+
+	// Encode encodes the given value.
+	Encode(value []byte) ([]byte, error)
+
+	// Decode decodes a value that is encoded by Encode.
+	Decode(value []byte) ([]byte, error)
+}
+
+var registry = struct {
+	mtx    sync.RWMutex
+	ids    map[uint8]Codec
+	byName map[string]Codec
+}{
+	ids:    make(map[uint8]Codec),
+	byName: make(map[string]Codec),
+}
+
+func init() {
+	for _, c := range []Codec{identity{}, &gzipCodec{}, &flateCodec{}} {
+		if err := Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register registers a new codec. Call it before starting the node, it's
+// too late to register a codec after the first entry that uses it is received.
+func Register(c Codec) error {
+	if c == nil {
+		return errors.New("codec cannot be nil")
+	}
+
+	if c.ID() == Chunked {
+		return fmt.Errorf("codec id: %d is reserved", Chunked)
+	}
+
+	registry.mtx.Lock()
+	defer registry.mtx.Unlock()
+
+	if _, ok := registry.ids[c.ID()]; ok {
+		return fmt.Errorf("%w: id: %d", ErrCodecExists, c.ID())
+	}
+	if _, ok := registry.byName[c.Name()]; ok {
+		return fmt.Errorf("%w: name: %s", ErrCodecExists, c.Name())
+	}
+	registry.ids[c.ID()] = c
+	registry.byName[c.Name()] = c
+	return nil
+}
+
+// Get returns the codec registered with the given ID.
+func Get(id uint8) (Codec, error) {
+	registry.mtx.RLock()
+	defer registry.mtx.RUnlock()
+
+	c, ok := registry.ids[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id: %d", ErrUnknownCodec, id)
+	}
+	return c, nil
+}
+
+// GetByName returns the codec registered with the given name.
+func GetByName(name string) (Codec, error) {
+	registry.mtx.RLock()
+	defer registry.mtx.RUnlock()
+
+	c, ok := registry.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+	return c, nil
+}
+
+// Decode decodes the value with the codec registered with the given ID.
+func Decode(id uint8, value []byte) ([]byte, error) {
+	if id == None {
+		return value, nil
+	}
+	c, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decode(value)
+}
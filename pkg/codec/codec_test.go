@@ -0,0 +1,103 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type reverse struct{}
+
+func (reverse) ID() uint8 { return 100 }
+
+func (reverse) Name() string { return "reverse" }
+
+func (reverse) Encode(value []byte) ([]byte, error) {
+	res := make([]byte, len(value))
+	for i := range value {
+		res[len(value)-1-i] = value[i]
+	}
+	return res, nil
+}
+
+func (r reverse) Decode(value []byte) ([]byte, error) {
+	return r.Encode(value)
+}
+
+func TestCodec_Register(t *testing.T) {
+	require.NoError(t, Register(reverse{}))
+	require.ErrorIs(t, Register(reverse{}), ErrCodecExists)
+
+	c, err := GetByName("reverse")
+	require.NoError(t, err)
+	require.Equal(t, uint8(100), c.ID())
+
+	encoded, err := c.Encode([]byte("olric"))
+	require.NoError(t, err)
+
+	decoded, err := Decode(100, encoded)
+	require.NoError(t, err)
+	require.Equal(t, []byte("olric"), decoded)
+
+	_, err = Get(101)
+	require.ErrorIs(t, err, ErrUnknownCodec)
+
+	require.Error(t, Register(reserved{}))
+}
+
+type reserved struct {
+	reverse
+}
+
+func (reserved) ID() uint8 { return Chunked }
+
+func (reserved) Name() string { return "reserved" }
+
+func TestCodec_Gzip(t *testing.T) {
+	c, err := GetByName("gzip")
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("olric"), 1000)
+	for i := 0; i < 2; i++ {
+		// The writers are reused.
+		encoded, err := c.Encode(value)
+		require.NoError(t, err)
+		require.Less(t, len(encoded), len(value))
+
+		decoded, err := Decode(Gzip, encoded)
+		require.NoError(t, err)
+		require.Equal(t, value, decoded)
+	}
+}
+
+func TestCodec_Flate(t *testing.T) {
+	c, err := GetByName("flate")
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("olric"), 1000)
+	for i := 0; i < 2; i++ {
+		// The writers are reused.
+		encoded, err := c.Encode(value)
+		require.NoError(t, err)
+		require.Less(t, len(encoded), len(value))
+
+		decoded, err := Decode(Flate, encoded)
+		require.NoError(t, err)
+		require.Equal(t, value, decoded)
+	}
+}
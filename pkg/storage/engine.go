@@ -29,6 +29,10 @@ var ErrEntryTooLarge = errors.New("entry too large for the configured table size
 // ErrKeyNotFound is an error that indicates that the requested key could not be found in the DB.
 var ErrKeyNotFound = errors.New("key not found")
 
+// ErrOutOfMemory is returned if the storage engine cannot allocate more memory
+// without exceeding its memory budget.
+var ErrOutOfMemory = errors.New("out of memory")
+
 // ErrNotImplemented means that the interface implementation does not support
 // the functionality required to fulfill the request.
 var ErrNotImplemented = errors.New("not implemented yet")
@@ -128,6 +132,10 @@ type Engine interface {
 	// Compaction reorganizes storage tables and reclaims wasted resources.
 	Compaction() (bool, error)
 
+	// Compact reclaims all wasted resources at once, regardless of the
+	// thresholds that Compaction uses. It returns the number of moved entries.
+	Compact() (int, error)
+
 	// Close stops an online storage engine instance. It may free some of allocated
 	// resources. A storage engine implementation should be started again, but it
 	// depends on the implementation.
@@ -46,6 +46,20 @@ type Entry interface {
 
 	LastAccess() int64
 
+	// SetCodec sets the ID of the codec that is used to encode the value.
+	SetCodec(uint8)
+
+	// Codec returns the ID of the codec that is used to encode the value.
+	// Zero means that the value is stored as it is.
+	Codec() uint8
+
+	// SetSliding sets the sliding expiration of the entry, in milliseconds.
+	SetSliding(int64)
+
+	// Sliding returns the sliding expiration of the entry, in milliseconds.
+	// The TTL is reset to it on every read. Zero means that the TTL is fixed.
+	Sliding() int64
+
 	// Encode encodes an entry into a binary form and returns the result.
 	Encode() []byte
 
@@ -0,0 +1,101 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "time"
+
+// ObserverKey is the key of the Observer in the storage engine configuration.
+const ObserverKey = "observer"
+
+// EventKind denotes the type of an Event.
+type EventKind uint8
+
+const (
+	// TableAllocated is reported when a new table is allocated.
+	TableAllocated EventKind = iota + 1
+
+	// TableReused is reported when a recycled table is reused instead of
+	// allocating a new one.
+	TableReused
+
+	// TableReleased is reported when an idle recycled table is released.
+	TableReleased
+
+	// CompactionRun is reported after a table is compacted.
+	CompactionRun
+
+	// EvictionSweep is reported after a sweep of the expired and idle keys.
+	EvictionSweep
+)
+
+// String returns the name of the event kind.
+func (k EventKind) String() string {
+	switch k {
+	case TableAllocated:
+		return "table-allocated"
+	case TableReused:
+		return "table-reused"
+	case TableReleased:
+		return "table-released"
+	case CompactionRun:
+		return "compaction-run"
+	case EvictionSweep:
+		return "eviction-sweep"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something that the storage engine did in the background.
+type Event struct {
+	Kind EventKind
+
+	// Time is when the event has been finished.
+	Time time.Time
+
+	// Duration is the time spent for the compaction runs and eviction sweeps.
+	Duration time.Duration
+
+	// Entries is the number of moved entries for the compaction runs, and the
+	// number of evicted keys for the eviction sweeps.
+	Entries int
+
+	// Size is the size of the table in bytes for the table events.
+	Size uint64
+}
+
+// Observer is called synchronously by the storage engine, it has to return
+// quickly. It's useful to correlate the engine behavior with latency spikes.
+// Set it with ObserverKey in the storage engine configuration.
+type Observer func(Event)
+
+// LoadObserver returns the Observer in the configuration, or nil.
+func LoadObserver(c *Config) Observer {
+	if c == nil {
+		return nil
+	}
+	raw, err := c.Get(ObserverKey)
+	if err != nil {
+		return nil
+	}
+	switch o := raw.(type) {
+	case Observer:
+		return o
+	case func(Event):
+		return o
+	default:
+		return nil
+	}
+}
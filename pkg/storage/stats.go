@@ -25,6 +25,11 @@ type Stats struct {
 	// Deleted portions of allocated memory.
 	Garbage int
 
+	// Total size of the keys and the values of the live entries. The rest of
+	// Inuse is the metadata of the entries.
+	KeyBytes   int
+	ValueBytes int
+
 	// Total number of keys hosted by the engine instance.
 	Length int
 
@@ -255,3 +255,42 @@ L:
 		}
 	}
 }
+
+func TestPubSub_SetAndPublish(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c := db.NewEmbeddedClient()
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	ps, err := c.NewPubSub(ToAddress(db.rt.This().String()))
+	require.NoError(t, err)
+
+	rp := ps.Subscribe(ctx, "my-channel")
+	defer func() {
+		require.NoError(t, rp.Close())
+	}()
+	_, err = rp.ReceiveTimeout(ctx, time.Second)
+	require.NoError(t, err)
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+	err = dm.SetAndPublish(ctx, "mykey", "myvalue", "my-channel", "mykey updated")
+	require.NoError(t, err)
+
+	select {
+	case msg := <-rp.Channel():
+		require.Equal(t, "mykey updated", msg.Payload)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no message received")
+	}
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+}
@@ -0,0 +1,100 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"time"
+
+	"github.com/buraksezer/olric/internal/resp"
+)
+
+// DefaultVisibilityTimeout is the time that a popped message is hidden from
+// the other consumers, if it's not set by VisibilityTimeout.
+const DefaultVisibilityTimeout = 30 * time.Second
+
+type queueConfig struct {
+	visibility time.Duration
+}
+
+// QueueOption is a function for defining options to control behavior of queue instances.
+type QueueOption func(*queueConfig)
+
+// VisibilityTimeout sets the time that a popped message is hidden from the other
+// consumers. If it's not acknowledged in this period, the message is delivered
+// again. Zero means the messages are deleted when they are popped.
+func VisibilityTimeout(d time.Duration) QueueOption {
+	return func(cfg *queueConfig) {
+		cfg.visibility = d
+	}
+}
+
+// QueueMessage is a message popped from a queue.
+type QueueMessage struct {
+	// ID is assigned by the queue, the messages are delivered in the order of their IDs.
+	ID uint64
+
+	// Delivery is the number of times the message has been delivered.
+	Delivery uint64
+
+	value []byte
+}
+
+// Scan decodes the message value into v.
+func (m *QueueMessage) Scan(v interface{}) error {
+	return resp.Scan(m.value, v)
+}
+
+// String returns the message value as string.
+func (m *QueueMessage) String() (string, error) {
+	v := new(string)
+	err := m.Scan(v)
+	if err != nil {
+		return "", err
+	}
+	return *v, nil
+}
+
+// Queue is a distributed FIFO queue. A queue lives on the owner of the
+// partition that its name belongs to, and it's moved with the partition.
+// Every change is replicated to the backup owners before it's applied, a
+// backup owner takes over the queue if the partition owner is gone.
+type Queue interface {
+	// Name exposes name of the queue.
+	Name() string
+
+	// Push appends a message to the end of the queue and returns its ID. value
+	// type is arbitrary.
+	Push(ctx context.Context, value interface{}) (uint64, error)
+
+	// Pop returns the oldest visible message. The message is hidden from the
+	// other consumers until the visibility timeout expires, then it's delivered
+	// again unless it's acknowledged with Ack. It returns ErrQueueEmpty if there
+	// is no visible message.
+	Pop(ctx context.Context) (*QueueMessage, error)
+
+	// BPop is the blocking version of Pop. It waits until a message is visible
+	// or timeout expires. Zero timeout means waiting until ctx is done. It returns
+	// ErrQueueEmpty if timeout expires and ctx.Err() if ctx is done.
+	BPop(ctx context.Context, timeout time.Duration) (*QueueMessage, error)
+
+	// Ack deletes a popped message permanently. It returns ErrNoSuchMessage if
+	// the visibility timeout of the message has expired.
+	Ack(ctx context.Context, m *QueueMessage) error
+
+	// Len returns the number of messages in the queue, including the messages
+	// that are popped but not acknowledged yet.
+	Len(ctx context.Context) (int, error)
+}
@@ -0,0 +1,61 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+// ReadPreference selects the owners that serve the reads, see ReadFrom and
+// WithReadFrom.
+type ReadPreference int
+
+const (
+	// PrimaryOnly reads from the partition owner. It's the default.
+	PrimaryOnly ReadPreference = iota
+
+	// BackupsOnly reads from a backup owner of the partition, this member if
+	// it's one of them. It spreads the read traffic to the backup owners, but
+	// the backups may miss the latest writes. Get returns ErrNoBackup if the
+	// replica count is 1.
+	BackupsOnly
+
+	// Nearest reads from this member if it owns the partition or one of its
+	// backups, otherwise from the owner with the lowest round-trip time. The
+	// value is stale if it's read from a backup owner that missed the latest
+	// writes.
+	Nearest
+)
+
+// ReadFrom sets the owners that serve Get and GetEntry on the DMap. The
+// default is PrimaryOnly. LinearizableReads always reads from the partition
+// owner.
+func ReadFrom(p ReadPreference) DMapOption {
+	return func(cfg *dmapConfig) {
+		cfg.readFrom = p
+	}
+}
+
+type getConfig struct {
+	readFrom ReadPreference
+}
+
+// GetOption is a function for defining options to control behavior of Get
+// and GetEntry.
+type GetOption func(*getConfig)
+
+// WithReadFrom sets the owners that serve a single Get or GetEntry call. It
+// overrides ReadFrom of the DMap.
+func WithReadFrom(p ReadPreference) GetOption {
+	return func(cfg *getConfig) {
+		cfg.readFrom = p
+	}
+}
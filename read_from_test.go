@@ -0,0 +1,93 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_ReadFrom(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	db1 := cluster.addMemberWithConfig(t, c1, "")
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	db2 := cluster.addMemberWithConfig(t, c2, "")
+
+	ctx := context.Background()
+	_, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	e := db1.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap", ReadFrom(BackupsOnly))
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	this := db1.rt.This().String()
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		primary := db1.primary.PartitionByHKey(partitions.HKey("mydmap", key)).Owner().String()
+
+		entry, err := dm.GetEntry(ctx, key)
+		require.NoError(t, err)
+		value, err := entry.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+		require.NotEqual(t, primary, entry.Member)
+
+		entry, err = dm.GetEntry(ctx, key, WithReadFrom(PrimaryOnly))
+		require.NoError(t, err)
+		require.Equal(t, primary, entry.Member)
+
+		// This member is either the partition owner or a backup owner.
+		entry, err = dm.GetEntry(ctx, key, WithReadFrom(Nearest))
+		require.NoError(t, err)
+		require.Equal(t, this, entry.Member)
+	}
+
+	_, err = dm.Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEmbeddedClient_ReadFrom_ErrNoBackup(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	dm, err := db.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	_, err = dm.Get(ctx, "mykey", WithReadFrom(BackupsOnly))
+	require.ErrorIs(t, err, ErrNoBackup)
+
+	gr, err := dm.Get(ctx, "mykey", WithReadFrom(Nearest))
+	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"container/list"
+	"sync"
+)
+
+// readSessionSize is the number of keys remembered by a read session. The
+// least recently read keys are forgotten beyond that.
+const readSessionSize = 1 << 16
+
+type readSessionItem struct {
+	key       string
+	timestamp int64
+}
+
+// readSession keeps the latest timestamp seen for the recently read keys of a
+// DMap. It's used to enforce monotonic reads, see MonotonicReads.
+type readSession struct {
+	mtx   sync.Mutex
+	size  int
+	seen  map[string]*list.Element
+	order *list.List
+}
+
+func newReadSession(size int) *readSession {
+	return &readSession{
+		size:  size,
+		seen:  make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// observe returns ErrStaleRead if the timestamp is older than the latest one
+// seen for the key. Otherwise, it records the timestamp.
+func (r *readSession) observe(key string, timestamp int64) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if elem, ok := r.seen[key]; ok {
+		r.order.MoveToFront(elem)
+		item := elem.Value.(*readSessionItem)
+		if timestamp < item.timestamp {
+			return ErrStaleRead
+		}
+		item.timestamp = timestamp
+		return nil
+	}
+
+	r.seen[key] = r.order.PushFront(&readSessionItem{key: key, timestamp: timestamp})
+	if r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.seen, oldest.Value.(*readSessionItem).key)
+	}
+	return nil
+}
+
+// forget removes the keys from the session. The next read of the keys are
+// accepted regardless of their timestamps.
+func (r *readSession) forget(keys ...string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := r.seen[key]; ok {
+			r.order.Remove(elem)
+			delete(r.seen, key)
+		}
+	}
+}
+
+func (r *readSession) reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.seen = make(map[string]*list.Element)
+	r.order.Init()
+}
@@ -0,0 +1,35 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSession_Evict(t *testing.T) {
+	r := newReadSession(2)
+	require.NoError(t, r.observe("a", 200))
+	require.NoError(t, r.observe("b", 200))
+	// Reading a again makes b the least recently read key.
+	require.ErrorIs(t, r.observe("a", 100), ErrStaleRead)
+	require.NoError(t, r.observe("c", 200))
+
+	require.ErrorIs(t, r.observe("a", 100), ErrStaleRead)
+	require.ErrorIs(t, r.observe("c", 100), ErrStaleRead)
+	// b is forgotten, any timestamp is accepted.
+	require.NoError(t, r.observe("b", 100))
+}
@@ -0,0 +1,371 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/go-redis/redis/v8"
+	"github.com/tidwall/redcon"
+)
+
+func (db *Olric) pauseRebalancingCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParsePauseRebalancing(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	db.balancer.Pause()
+	db.log.V(2).Printf("[INFO] Rebalancing has been paused")
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (db *Olric) resumeRebalancingCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParseResumeRebalancing(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	db.balancer.Resume()
+	db.log.V(2).Printf("[INFO] Rebalancing has been resumed")
+	conn.WriteString(protocol.StatusOK)
+}
+
+// broadcastStatusCommand runs the command on every cluster member.
+func (db *Olric) broadcastStatusCommand(ctx context.Context, newCmd func() *redis.StatusCmd) error {
+	for _, member := range db.rt.Discovery().GetMembers() {
+		cmd := newCmd()
+		rc := db.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return processProtocolError(err)
+		}
+		if err = cmd.Err(); err != nil {
+			return processProtocolError(err)
+		}
+	}
+	return nil
+}
+
+// RebalanceStatus is the state of the partition balancer on a cluster member.
+type RebalanceStatus struct {
+	// Paused is true if rebalancing is paused with PauseRebalancing.
+	Paused bool `json:"paused"`
+
+	// InWindow is false if the member is out of the scheduling window,
+	// see config.Config.BalancerWindowStart.
+	InWindow bool `json:"in_window"`
+
+	// Running is true if the member is moving partitions now.
+	Running bool `json:"running"`
+
+	// PendingPrimaryPartitions and PendingBackupPartitions are the number of
+	// partitions on this member that have to be moved to their new owners.
+	PendingPrimaryPartitions int `json:"pending_primary_partitions"`
+	PendingBackupPartitions  int `json:"pending_backup_partitions"`
+}
+
+func (db *Olric) rebalanceStatus() RebalanceStatus {
+	s := db.balancer.Status()
+	return RebalanceStatus{
+		Paused:                   s.Paused,
+		InWindow:                 s.InWindow,
+		Running:                  s.Running,
+		PendingPrimaryPartitions: s.PendingPrimary,
+		PendingBackupPartitions:  s.PendingBackup,
+	}
+}
+
+func (db *Olric) rebalanceStatusCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParseRebalanceStatus(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	data, err := json.Marshal(db.rebalanceStatus())
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteBulk(data)
+}
+
+// memberRebalanceStatus returns the balancer status of the given member.
+func (db *Olric) memberRebalanceStatus(ctx context.Context, member discovery.Member) (RebalanceStatus, error) {
+	if member.CompareByID(db.rt.This()) {
+		return db.rebalanceStatus(), nil
+	}
+
+	cmd := protocol.NewRebalanceStatus().Command(ctx)
+	rc := db.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return RebalanceStatus{}, processProtocolError(err)
+	}
+	data, err := cmd.Bytes()
+	if err != nil {
+		return RebalanceStatus{}, processProtocolError(err)
+	}
+	var s RebalanceStatus
+	if err = json.Unmarshal(data, &s); err != nil {
+		return RebalanceStatus{}, err
+	}
+	return s, nil
+}
+
+// clusterRebalanceStatus collects the balancer status from all members.
+func (db *Olric) clusterRebalanceStatus(ctx context.Context) (map[string]RebalanceStatus, error) {
+	result := make(map[string]RebalanceStatus)
+	for _, member := range db.rt.Discovery().GetMembers() {
+		s, err := db.memberRebalanceStatus(ctx, member)
+		if err != nil {
+			return nil, err
+		}
+		result[member.String()] = s
+	}
+	return result, nil
+}
+
+// sendToCoordinator runs the command on the cluster coordinator. Placement
+// decisions are only taken by the coordinator.
+func (db *Olric) sendToCoordinator(ctx context.Context, cmd *redis.StatusCmd) error {
+	coordinator := db.rt.Discovery().GetCoordinator()
+	rc := db.client.Get(coordinator.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+func (db *Olric) movePartition(ctx context.Context, partID uint64, member string) error {
+	if db.rt.Discovery().IsCoordinator() {
+		return db.rt.MovePartition(partID, member)
+	}
+	return db.sendToCoordinator(ctx, protocol.NewMovePartition(partID, member).Command(ctx))
+}
+
+// drainMember marks the member as drained on the cluster coordinator. It
+// doesn't wait for the data to be moved.
+func (db *Olric) drainMember(ctx context.Context, member string) error {
+	if db.rt.Discovery().IsCoordinator() {
+		return db.rt.Drain(member)
+	}
+	return db.sendToCoordinator(ctx, protocol.NewDrain(member).Command(ctx))
+}
+
+// drain marks the member as drained and waits until the primary copies and
+// the backups on it are moved to the other members.
+func (db *Olric) drain(ctx context.Context, name string) error {
+	if err := db.drainMember(ctx, name); err != nil {
+		return err
+	}
+	member, err := db.rt.Discovery().FindMemberByName(name)
+	if err != nil {
+		return err
+	}
+	return db.awaitDrain(ctx, member)
+}
+
+// rebalance recalculates the routing table on the cluster coordinator and
+// pushes it to the members. The members run their balancers after the push.
+func (db *Olric) rebalance(ctx context.Context) error {
+	if db.rt.Discovery().IsCoordinator() {
+		db.rt.UpdateEagerly()
+		return nil
+	}
+	return db.sendToCoordinator(ctx, protocol.NewRebalance().Command(ctx))
+}
+
+func (db *Olric) rebalanceCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	_, err := protocol.ParseRebalance(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	err = db.rebalance(db.ctx)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (db *Olric) movePartitionCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	movePartitionCmd, err := protocol.ParseMovePartition(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	err = db.movePartition(db.ctx, movePartitionCmd.PartID, movePartitionCmd.Member)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (db *Olric) drainCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	drainCmd, err := protocol.ParseDrain(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	err = db.drainMember(db.ctx, drainCmd.Member)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+// OwnershipChange is a change in the owners of a partition. Kind is either
+// PRIMARY or BACKUP, Previous and Current are the owner lists before and
+// after the change. Timestamp is in nanoseconds.
+type OwnershipChange struct {
+	PartID    uint64   `json:"part_id"`
+	Kind      string   `json:"kind"`
+	Previous  []string `json:"previous"`
+	Current   []string `json:"current"`
+	Reason    string   `json:"reason"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+func (db *Olric) ownershipHistoryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	historyCmd, err := protocol.ParseOwnershipHistory(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	changes, err := db.rt.OwnershipHistory(historyCmd.PartIDs...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteBulk(data)
+}
+
+func (db *Olric) ownershipHistory(ctx context.Context, partIDs ...uint64) ([]OwnershipChange, error) {
+	var result []OwnershipChange
+	if db.rt.Discovery().IsCoordinator() {
+		changes, err := db.rt.OwnershipHistory(partIDs...)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			result = append(result, OwnershipChange(change))
+		}
+		return result, nil
+	}
+
+	cmd := protocol.NewOwnershipHistory(partIDs...).Command(ctx)
+	rc := db.client.Get(db.rt.Discovery().GetCoordinator().String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	data, err := cmd.Bytes()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// awaitRebalance waits until none of the members has pending partitions. It
+// returns a *ProgressError if the context is canceled before, Total is the
+// highest number of the pending partitions that is observed.
+func (db *Olric) awaitRebalance(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var total, pending int
+	for {
+		statuses, err := db.clusterRebalanceStatus(ctx)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if err == nil {
+			pending = 0
+			for _, s := range statuses {
+				pending += s.PendingPrimaryPartitions + s.PendingBackupPartitions
+			}
+			if pending > total {
+				total = pending
+			}
+			if pending == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &ProgressError{
+				Operation: "rebalance",
+				Done:      total - pending,
+				Total:     total,
+				Err:       ctx.Err(),
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// awaitDrain waits until the drained member has no pending partitions. The
+// routing table is already pushed by drainMember, so the member knows the new
+// owners. It returns a *ProgressError if the context is canceled before.
+func (db *Olric) awaitDrain(ctx context.Context, member discovery.Member) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var total, pending int
+	for {
+		s, err := db.memberRebalanceStatus(ctx, member)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if err == nil {
+			pending = s.PendingPrimaryPartitions + s.PendingBackupPartitions
+			if pending > total {
+				total = pending
+			}
+			if pending == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &ProgressError{
+				Operation: "drain",
+				Done:      total - pending,
+				Total:     total,
+				Err:       ctx.Err(),
+			}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_PauseResumeRebalancing(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	e := db1.NewEmbeddedClient()
+	ctx := context.Background()
+
+	err := e.PauseRebalancing(ctx)
+	require.NoError(t, err)
+	require.True(t, db1.balancer.IsPaused())
+	require.True(t, db2.balancer.IsPaused())
+
+	err = e.ResumeRebalancing(ctx)
+	require.NoError(t, err)
+	require.False(t, db1.balancer.IsPaused())
+	require.False(t, db2.balancer.IsPaused())
+}
+
+func TestEmbeddedClient_MovePartition(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	// db2 is not the coordinator, the request is redirected.
+	e := db2.NewEmbeddedClient()
+
+	var partID uint64
+	for ; partID < db1.config.PartitionCount; partID++ {
+		if db1.primary.PartitionByID(partID).Owner().CompareByID(db1.rt.This()) {
+			break
+		}
+	}
+
+	err := e.MovePartition(ctx, partID, db2.rt.This().String())
+	require.NoError(t, err)
+	for _, db := range []*Olric{db1, db2} {
+		require.True(t, db.primary.PartitionByID(partID).Owner().CompareByID(db2.rt.This()))
+	}
+
+	err = e.MovePartition(ctx, partID, "127.0.0.1:1")
+	require.ErrorIs(t, err, ErrMemberNotFound)
+
+	err = e.MovePartition(ctx, db1.config.PartitionCount, db2.rt.This().String())
+	require.Error(t, err)
+}
+
+func TestEmbeddedClient_Drain(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	e := db1.NewEmbeddedClient()
+	ctx := context.Background()
+
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	err = e.Drain(ctx, db1.rt.This().String())
+	require.NoError(t, err)
+	for partID := uint64(0); partID < db1.config.PartitionCount; partID++ {
+		require.True(t, db1.primary.PartitionByID(partID).Owner().CompareByID(db2.rt.This()))
+	}
+
+	err = e.MovePartition(ctx, 0, db1.rt.This().String())
+	require.ErrorIs(t, err, ErrMemberDrained)
+
+	// Drain returns after the data is moved.
+	status, err := e.RebalanceStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	require.Equal(t, 0, status[db1.rt.This().String()].PendingPrimaryPartitions)
+
+	for i := 0; i < 100; i++ {
+		res, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := res.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
+
+func TestEmbeddedClient_Drain_ContextCanceled(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	e := db1.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(context.Background(), testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	err = e.PauseRebalancing(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err = e.Drain(ctx, db1.rt.This().String())
+	var progressErr *ProgressError
+	require.ErrorAs(t, err, &progressErr)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, "drain", progressErr.Operation)
+	require.NotZero(t, progressErr.Total)
+}
+
+func TestEmbeddedClient_OwnershipHistory(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db2.NewEmbeddedClient()
+
+	var partID uint64
+	for ; partID < db1.config.PartitionCount; partID++ {
+		if db1.primary.PartitionByID(partID).Owner().CompareByID(db1.rt.This()) {
+			break
+		}
+	}
+
+	err := e.MovePartition(ctx, partID, db2.rt.This().String())
+	require.NoError(t, err)
+
+	// db2 is not the coordinator, the request is redirected.
+	changes, err := e.OwnershipHistory(ctx, partID)
+	require.NoError(t, err)
+	require.NotEmpty(t, changes)
+
+	last := changes[len(changes)-1]
+	require.Equal(t, partID, last.PartID)
+	require.Equal(t, fmt.Sprintf("partition moved to: %s", db2.rt.This()), last.Reason)
+
+	all, err := db1.NewEmbeddedClient().OwnershipHistory(ctx)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(all), len(changes))
+}
@@ -0,0 +1,145 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/hashicorp/logutils"
+	"github.com/pkg/errors"
+)
+
+// ErrImmutableConfig is returned by ReloadConfig if the new configuration
+// modifies a field that cannot be changed without restarting the node.
+var ErrImmutableConfig = errors.New("configuration field cannot be changed at runtime")
+
+func checkImmutableFields(current, c *config.Config) error {
+	switch {
+	case current.PartitionCount != c.PartitionCount:
+		return fmt.Errorf("%w: PartitionCount", ErrImmutableConfig)
+	case current.ReplicaCount != c.ReplicaCount:
+		return fmt.Errorf("%w: ReplicaCount", ErrImmutableConfig)
+	case current.ReplicationMode != c.ReplicationMode:
+		return fmt.Errorf("%w: ReplicationMode", ErrImmutableConfig)
+	case current.BindPort != c.BindPort:
+		return fmt.Errorf("%w: BindPort", ErrImmutableConfig)
+	}
+	return nil
+}
+
+// reloadDMapsConfig returns a copy of the current DMaps configuration with
+// the fields that can be modified at runtime taken from dc.
+func reloadDMapsConfig(current, dc *config.DMaps) *config.DMaps {
+	res := *current
+	res.MaxIdleDuration = dc.MaxIdleDuration
+	res.TTLDuration = dc.TTLDuration
+	res.MaxKeys = dc.MaxKeys
+	res.MaxInuse = dc.MaxInuse
+	res.LRUSamples = dc.LRUSamples
+	res.EvictionPolicy = dc.EvictionPolicy
+	res.Codec = dc.Codec
+
+	res.Custom = make(map[string]config.DMap)
+	for name, d := range dc.Custom {
+		if cd, ok := current.Custom[name]; ok {
+			// Storage engine of a DMap is not replaceable.
+			d.Engine = cd.Engine
+		}
+		res.Custom[name] = d
+	}
+	return &res
+}
+
+// runtimeConfig returns the latest configuration applied by reloadConfig.
+func (db *Olric) runtimeConfig() *config.Config {
+	return db.runtime.Load().(*config.Config)
+}
+
+// reloadConfig applies a subset of the given configuration to this node:
+// log level and verbosity, DMap TTL and eviction limits, client timeouts,
+// quorum sizes, rebalancing and import throttles. Other fields are ignored,
+// except the ones that are checked by checkImmutableFields.
+//
+// The subsystems read the reloadable fields from their own copy of the
+// configuration, so the running configuration is never modified. A new copy
+// is built and swapped atomically.
+func (db *Olric) reloadConfig(ctx context.Context, c *config.Config) error {
+	if c == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+	if err := c.Sanitize(); err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	db.reloadMtx.Lock()
+	defer db.reloadMtx.Unlock()
+
+	current := db.runtimeConfig()
+	if err := checkImmutableFields(current, c); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rc := *current
+	rc.DMaps = reloadDMapsConfig(current.DMaps, c.DMaps)
+
+	rc.ReadQuorum = c.ReadQuorum
+	rc.WriteQuorum = c.WriteQuorum
+	rc.MemberCountQuorum = c.MemberCountQuorum
+	rc.QuorumLossMode = c.QuorumLossMode
+
+	rc.MaxConcurrentPartitionTransfers = c.MaxConcurrentPartitionTransfers
+	rc.PartitionTransferBandwidth = c.PartitionTransferBandwidth
+	rc.PartitionTransferEntryRate = c.PartitionTransferEntryRate
+	rc.ImportBandwidth = c.ImportBandwidth
+	rc.ImportEntryRate = c.ImportEntryRate
+	rc.MaxConcurrentImports = c.MaxConcurrentImports
+	rc.BalancerWindowStart = c.BalancerWindowStart
+	rc.BalancerWindowEnd = c.BalancerWindowEnd
+	rc.DeadMemberTimeout = c.DeadMemberTimeout
+
+	rc.Client = c.Client
+	rc.LogLevel = c.LogLevel
+	rc.LogVerbosity = c.LogVerbosity
+
+	if err := db.dmap.ReloadConfig(ctx, &rc); err != nil {
+		return err
+	}
+	db.rt.ReloadConfig(&rc)
+	db.balancer.ReloadConfig(&rc)
+	db.client.SetConfig(rc.Client)
+	db.runtime.Store(&rc)
+
+	if filter, ok := db.config.Logger.Writer().(*logutils.LevelFilter); ok {
+		filter.SetMinLevel(logutils.LogLevel(strings.ToUpper(rc.LogLevel)))
+	}
+	db.log.SetLevel(rc.LogVerbosity)
+	if rc.LogLevel == config.LogLevelDebug {
+		db.log.ShowLineNumber(1)
+	} else {
+		db.log.ShowLineNumber(0)
+	}
+
+	db.log.V(2).Printf("[INFO] Configuration has been reloaded")
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_ReloadConfig(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	c := testutil.NewConfig()
+	c.BindPort = db.config.BindPort
+	c.LogLevel = config.LogLevelWarn
+	c.LogVerbosity = 2
+	c.DMaps.TTLDuration = 100 * time.Millisecond
+	c.Client.ReadTimeout = 10 * time.Second
+	err = e.ReloadConfig(context.Background(), c)
+	require.NoError(t, err)
+
+	require.Equal(t, config.LogLevelWarn, db.runtimeConfig().LogLevel)
+	require.Equal(t, 10*time.Second, db.runtimeConfig().Client.ReadTimeout)
+	require.Equal(t, 100*time.Millisecond, db.runtimeConfig().DMaps.TTLDuration)
+
+	// The new TTLDuration is applied to the existing DMap.
+	ctx := context.Background()
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	<-time.After(200 * time.Millisecond)
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEmbeddedClient_ReloadConfig_Immutable(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+
+	c := testutil.NewConfig()
+	c.BindPort = db.config.BindPort
+	c.PartitionCount = db.config.PartitionCount + 1
+	c.DMaps.TTLDuration = time.Second
+	err := e.ReloadConfig(context.Background(), c)
+	require.ErrorIs(t, err, ErrImmutableConfig)
+	require.Equal(t, time.Duration(0), db.runtimeConfig().DMaps.TTLDuration)
+}
+
+func TestEmbeddedClient_ReloadConfig_Canceled(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+
+	c := testutil.NewConfig()
+	c.BindPort = db.config.BindPort
+	c.DMaps.TTLDuration = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := e.ReloadConfig(ctx, c)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, time.Duration(0), db.runtimeConfig().DMaps.TTLDuration)
+}
+
+func TestEmbeddedClient_ReloadConfig_Concurrent_Access(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ctx.Err() == nil; i++ {
+			_, _ = dm.Put(ctx, testutil.ToKey(i%100), i)
+			_, _ = dm.Get(ctx, testutil.ToKey(i%100))
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		c := testutil.NewConfig()
+		c.BindPort = db.config.BindPort
+		c.DMaps.TTLDuration = time.Duration(i+1) * time.Second
+		c.DMaps.MaxKeys = 1000 + i
+		require.NoError(t, e.ReloadConfig(context.Background(), c))
+	}
+	cancel()
+	<-done
+	require.Equal(t, 10*time.Second, db.runtimeConfig().DMaps.TTLDuration)
+}
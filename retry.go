@@ -0,0 +1,101 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+// defaultRetryableErrors are retried by WithRetry if no error is given.
+var defaultRetryableErrors = []error{
+	ErrConnRefused,
+	ErrServerGone,
+	ErrOperationTimeout,
+	ErrWriteQuorum,
+	ErrReadQuorum,
+	ErrShuttingDown,
+	ErrStaleRoutingTable,
+	ErrStaleEpoch,
+}
+
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	retryable  []error
+}
+
+// WithRetry retries the failed commands up to maxRetries times. The waiting
+// time starts with backoff and doubles after every attempt. The routing table
+// is consulted again on every attempt, so a command is sent to the new owner
+// of the key if the ownership changes in the meantime.
+//
+// Only the errors in retryableErrors are retried. The default ones are
+// ErrConnRefused, ErrServerGone, ErrOperationTimeout, ErrWriteQuorum,
+// ErrReadQuorum and ErrShuttingDown. Get, GetEntry, Put, Delete, Expire and
+// Query are retried, the others are not idempotent.
+func WithRetry(maxRetries int, backoff time.Duration, retryableErrors ...error) EmbeddedClientOption {
+	return func(cfg *embeddedClientConfig) {
+		if len(retryableErrors) == 0 {
+			retryableErrors = defaultRetryableErrors
+		}
+		cfg.retry = &retryPolicy{
+			maxRetries: maxRetries,
+			backoff:    backoff,
+			retryable:  retryableErrors,
+		}
+	}
+}
+
+func (r *retryPolicy) isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, target := range r.retryable {
+		if errors.Is(err, target) {
+			return true
+		}
+		// Connection errors of the internal client are not wrapped.
+		if target == ErrConnRefused && errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+	}
+	return false
+}
+
+// do calls f until it succeeds, returns an error that is not retryable, or
+// the retries are exhausted. It returns the last error of f.
+func (r *retryPolicy) do(ctx context.Context, f func() error) error {
+	err := f()
+	if r == nil {
+		return err
+	}
+
+	backoff := r.backoff
+	for attempt := 0; attempt < r.maxRetries && r.isRetryable(err); attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		err = f()
+	}
+	return err
+}
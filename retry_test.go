@@ -0,0 +1,109 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy(maxRetries int, retryableErrors ...error) *retryPolicy {
+	cfg := &embeddedClientConfig{}
+	WithRetry(maxRetries, time.Millisecond, retryableErrors...)(cfg)
+	return cfg.retry
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	r := testRetryPolicy(3)
+
+	var calls int
+	err := r.do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("put failed: %w", ErrWriteQuorum)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	t.Run("Retries exhausted", func(t *testing.T) {
+		calls = 0
+		err := r.do(context.Background(), func() error {
+			calls++
+			return ErrServerGone
+		})
+		require.ErrorIs(t, err, ErrServerGone)
+		require.Equal(t, 4, calls)
+	})
+
+	t.Run("Not retryable", func(t *testing.T) {
+		calls = 0
+		err := r.do(context.Background(), func() error {
+			calls++
+			return ErrKeyNotFound
+		})
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("Connection refused", func(t *testing.T) {
+		calls = 0
+		err := r.do(context.Background(), func() error {
+			calls++
+			if calls == 1 {
+				return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("Context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		calls = 0
+		err := r.do(ctx, func() error {
+			calls++
+			return ErrServerGone
+		})
+		require.ErrorIs(t, err, ErrServerGone)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryPolicy_Custom_Errors(t *testing.T) {
+	r := testRetryPolicy(1, ErrKeyNotFound)
+	require.True(t, r.isRetryable(ErrKeyNotFound))
+	require.False(t, r.isRetryable(ErrWriteQuorum))
+}
+
+func TestRetryPolicy_Nil(t *testing.T) {
+	var r *retryPolicy
+	var calls int
+	err := r.do(context.Background(), func() error {
+		calls++
+		return ErrServerGone
+	})
+	require.ErrorIs(t, err, ErrServerGone)
+	require.Equal(t, 1, calls)
+}
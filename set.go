@@ -0,0 +1,70 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import "context"
+
+// Set is a distributed set of unique strings. A set lives on the owner of the
+// partition that its name belongs to, and it's moved with the partition. The
+// members are stored in the storage engine of DMaps and every change is
+// replicated to the backup owners.
+type Set interface {
+	// Name exposes name of the set.
+	Name() string
+
+	// SAdd adds the members to the set and returns the number of the members
+	// that were not in the set.
+	SAdd(ctx context.Context, members ...string) (int, error)
+
+	// SRem removes the members from the set and returns the number of the
+	// removed members.
+	SRem(ctx context.Context, members ...string) (int, error)
+
+	// SIsMember returns true if the member is in the set.
+	SIsMember(ctx context.Context, member string) (bool, error)
+
+	// SMembers returns all the members of the set in lexicographical order.
+	SMembers(ctx context.Context) ([]string, error)
+
+	// SCard returns the number of the members in the set.
+	SCard(ctx context.Context) (int, error)
+}
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSet is a distributed set of unique strings that are ordered by their
+// scores. Members with the same score are ordered lexicographically. Like Set,
+// it lives on a single partition owner and it's replicated to the backup owners.
+type SortedSet interface {
+	// Name exposes name of the sorted set.
+	Name() string
+
+	// ZAdd adds the members to the sorted set or updates their scores. It
+	// returns the number of the members that were not in the sorted set.
+	ZAdd(ctx context.Context, members ...ZMember) (int, error)
+
+	// ZRangeByScore returns the members whose scores are between min and max,
+	// inclusive, ordered from the lowest score to the highest.
+	ZRangeByScore(ctx context.Context, min, max float64) ([]ZMember, error)
+
+	// ZRank returns the zero-based rank of the member, ordered from the lowest
+	// score to the highest. It returns ErrNoSuchMember if the member is not in
+	// the sorted set.
+	ZRank(ctx context.Context, member string) (int, error)
+}
@@ -0,0 +1,82 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"time"
+)
+
+// ownsPrimaryPartitions returns true if this member is still the owner of a
+// primary partition in the routing table.
+func (db *Olric) ownsPrimaryPartitions() bool {
+	for partID := uint64(0); partID < db.config.PartitionCount; partID++ {
+		part := db.primary.PartitionByID(partID)
+		if part.OwnerCount() != 0 && part.Owner().CompareByID(db.rt.This()) {
+			return true
+		}
+	}
+	return false
+}
+
+// handOffPartitions drains this member and moves its primary partitions to
+// the new owners.
+func (db *Olric) handOffPartitions(ctx context.Context) error {
+	if len(db.rt.Discovery().GetMembers()) <= 1 {
+		// There is nobody to take over the partitions.
+		return nil
+	}
+	if err := db.drainMember(ctx, db.name); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		// Wait for the new routing table before moving the partitions.
+		if !db.ownsPrimaryPartitions() {
+			db.balancer.BalanceEagerly()
+			if db.balancer.Status().PendingPrimary == 0 {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainBeforeShutdown hands off the partitions, waits for the in-flight
+// requests and flushes the write-behind queues, up to
+// config.ShutdownDrainTimeout. The server rejects the new client requests
+// after the partitions are handed off, the other members can still reach it
+// until shutdown.
+func (db *Olric) drainBeforeShutdown(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, db.config.ShutdownDrainTimeout)
+	defer cancel()
+
+	db.log.V(2).Printf("[INFO] Draining %s before shutdown", db.name)
+	if err := db.handOffPartitions(ctx); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to hand off partitions: %v", err)
+	}
+	if err := db.server.Drain(ctx); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to drain in-flight requests: %v", err)
+	}
+	if err := db.dmap.FlushWriteBehind(ctx); err != nil {
+		db.log.V(2).Printf("[ERROR] Failed to flush write-behind queues: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOlric_Shutdown_Drain(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+
+	c := testutil.NewConfig()
+	c.ShutdownDrainTimeout = 10 * time.Second
+	db2 := cluster.addMemberWithConfig(t, c, "")
+
+	ctx := context.Background()
+	dm, err := db1.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	_, err = db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, db2.Shutdown(ctx))
+
+	// The partitions of db2 are handed off before shutdown, nothing is lost.
+	for i := 0; i < 100; i++ {
+		res, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := res.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
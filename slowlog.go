@@ -0,0 +1,76 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (db *Olric) slowLogCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	slowLogCmd, err := protocol.ParseSlowLogCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	data, err := json.Marshal(db.server.SlowLog(slowLogCmd.Count))
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteBulk(data)
+}
+
+// clusterSlowLog collects the slow logs of all members and returns the latest
+// n entries, the newest one comes first.
+func (db *Olric) clusterSlowLog(ctx context.Context, n int) ([]SlowLogEntry, error) {
+	var result []SlowLogEntry
+	for _, member := range db.rt.Discovery().GetMembers() {
+		var entries []SlowLogEntry
+		if member.CompareByID(db.rt.This()) {
+			entries = db.server.SlowLog(n)
+		} else {
+			cmd := protocol.NewSlowLog().SetCount(n).Command(ctx)
+			rc := db.client.Get(member.String())
+			err := rc.Process(ctx, cmd)
+			if err != nil {
+				return nil, processProtocolError(err)
+			}
+			data, err := cmd.Bytes()
+			if err != nil {
+				return nil, processProtocolError(err)
+			}
+			if err = json.Unmarshal(data, &entries); err != nil {
+				return nil, err
+			}
+		}
+		for i := range entries {
+			entries[i].Member = member.String()
+		}
+		result = append(result, entries...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp > result[j].Timestamp
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
@@ -15,14 +15,20 @@
 package olric
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/internal/kvstore"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/pubsub"
 	"github.com/buraksezer/olric/internal/server"
@@ -35,6 +41,7 @@ func toMember(member discovery.Member) stats.Member {
 		Name:      member.Name,
 		ID:        member.ID,
 		Birthdate: member.Birthdate,
+		Tags:      member.Tags,
 	}
 }
 
@@ -57,6 +64,11 @@ func (db *Olric) collectPartitionMetrics(partID uint64, part *partitions.Partiti
 		p.PreviousOwners = toMembers(owners[:len(owners)-1])
 	}
 	part.Map().Range(func(name, item interface{}) bool {
+		if !strings.HasPrefix(name.(string), "dmap.") {
+			// This fragment belongs to a different data structure.
+			return true
+		}
+
 		f := item.(partitions.Fragment)
 		st := f.Stats()
 		tmp := stats.DMap{
@@ -66,13 +78,38 @@ func (db *Olric) collectPartitionMetrics(partID uint64, part *partitions.Partiti
 		tmp.SlabInfo.Allocated = st.Allocated
 		tmp.SlabInfo.Garbage = st.Garbage
 		tmp.SlabInfo.Inuse = st.Inuse
+		tmp.SlabInfo.Keys = st.KeyBytes
+		tmp.SlabInfo.Values = st.ValueBytes
+		if st.KeyBytes+st.ValueBytes > 0 {
+			tmp.SlabInfo.Overhead = st.Inuse - st.KeyBytes - st.ValueBytes
+		}
 		dmapName := strings.TrimPrefix(name.(string), "dmap.")
 		p.DMaps[dmapName] = tmp
+		p.SlabInfo.Add(tmp.SlabInfo)
 		return true
 	})
 	return p
 }
 
+// tableRatios returns the table utilization and the fragmentation of the
+// storage engines on this member.
+func tableRatios(partitions ...map[stats.PartitionID]stats.Partition) (utilization, fragmentation float64) {
+	var allocated, inuse, garbage int
+	for _, parts := range partitions {
+		for _, part := range parts {
+			for _, dm := range part.DMaps {
+				allocated += dm.SlabInfo.Allocated
+				inuse += dm.SlabInfo.Inuse
+				garbage += dm.SlabInfo.Garbage
+			}
+		}
+	}
+	if allocated == 0 {
+		return 0, 0
+	}
+	return float64(inuse) / float64(allocated), float64(garbage) / float64(allocated)
+}
+
 func (db *Olric) checkPartitionOwnership(part *partitions.Partition) bool {
 	owners := part.Owners()
 	for _, owner := range owners {
@@ -83,7 +120,9 @@ func (db *Olric) checkPartitionOwnership(part *partitions.Partition) bool {
 	return false
 }
 
-func (db *Olric) stats(cfg statsConfig) stats.Stats {
+// stats collects the statistics of this member. It checks the context between
+// the partitions and returns a *ProgressError if it's canceled.
+func (db *Olric) stats(ctx context.Context, cfg statsConfig) (stats.Stats, error) {
 	s := stats.Stats{
 		Cmdline:            os.Args,
 		ReleaseVersion:     ReleaseVersion,
@@ -99,14 +138,52 @@ func (db *Olric) stats(cfg statsConfig) stats.Stats {
 			WrittenBytesTotal:  server.WrittenBytesTotal.Read(),
 			ReadBytesTotal:     server.ReadBytesTotal.Read(),
 			CommandsTotal:      server.CommandsTotal.Read(),
+
+			RejectedCommandsTotal:    server.RejectedCommandsTotal.Read(),
+			HealthCheckFailuresTotal: server.HealthCheckFailuresTotal.Read(),
+			CoalescedWritesTotal:     server.CoalescedWritesTotal.Read(),
+			DeadlineExceededTotal:    server.DeadlineExceededTotal.Read(),
 		},
+		ClientPools: make(map[string]stats.ClientPool),
 		DMaps: stats.DMaps{
-			EntriesTotal: dmap.EntriesTotal.Read(),
-			DeleteHits:   dmap.DeleteHits.Read(),
-			DeleteMisses: dmap.DeleteMisses.Read(),
-			GetMisses:    dmap.GetMisses.Read(),
-			GetHits:      dmap.GetHits.Read(),
-			EvictedTotal: dmap.EvictedTotal.Read(),
+			EntriesTotal:               dmap.EntriesTotal.Read(),
+			DeleteHits:                 dmap.DeleteHits.Read(),
+			DeleteMisses:               dmap.DeleteMisses.Read(),
+			GetMisses:                  dmap.GetMisses.Read(),
+			GetHits:                    dmap.GetHits.Read(),
+			EvictedTotal:               dmap.EvictedTotal.Read(),
+			CanceledOperationsTotal:    dmap.CanceledOperationsTotal.Read(),
+			EvictionSweepsTotal:        dmap.EvictionSweepsTotal.Read(),
+			ActivelyExpiredTotal:       dmap.ActivelyExpiredTotal.Read(),
+			LazilyExpiredTotal:         dmap.LazilyExpiredTotal.Read(),
+			TablesAllocatedTotal:       kvstore.TablesAllocatedTotal.Read(),
+			CompactionRunsTotal:        kvstore.CompactionRunsTotal.Read(),
+			CompactedEntriesTotal:      kvstore.CompactedEntriesTotal.Read(),
+			LoadsTotal:                 dmap.LoadsTotal.Read(),
+			LoadErrorsTotal:            dmap.LoadErrorsTotal.Read(),
+			RefreshesTotal:             dmap.RefreshesTotal.Read(),
+			WritesTotal:                dmap.WritesTotal.Read(),
+			WriteErrorsTotal:           dmap.WriteErrorsTotal.Read(),
+			WriteBehindRetriesTotal:    dmap.WriteBehindRetriesTotal.Read(),
+			WriteBehindQueueLength:     dmap.WriteBehindQueueLength.Read(),
+			StaleEpochRejectionsTotal:  dmap.StaleEpochRejectionsTotal.Read(),
+			AntiEntropyMismatchesTotal: dmap.AntiEntropyMismatchesTotal.Read(),
+			AntiEntropyRepairsTotal:    dmap.AntiEntropyRepairsTotal.Read(),
+			CodecRawBytesTotal:         dmap.CodecRawBytesTotal.Read(),
+			CodecEncodedBytesTotal:     dmap.CodecEncodedBytesTotal.Read(),
+			MemoryLimit:                db.dmap.MemoryBudget().Limit(),
+			MemoryAllocated:            db.dmap.MemoryBudget().Allocated(),
+			OutOfMemoryErrorsTotal:     dmap.OutOfMemoryErrorsTotal.Read(),
+			TxCommitsTotal:             dmap.TxCommitsTotal.Read(),
+			TxConflictsTotal:           dmap.TxConflictsTotal.Read(),
+			TxRollbacksTotal:           dmap.TxRollbacksTotal.Read(),
+			ImportsTotal:               dmap.ImportsTotal.Read(),
+			AnalyticsReplicatedTotal:   dmap.AnalyticsReplicatedTotal.Read(),
+			AnalyticsDroppedTotal:      dmap.AnalyticsDroppedTotal.Read(),
+			RateLimitedTotal:           dmap.RateLimitedTotal.Read(),
+			MigratedEntriesTotal:       dmap.MigratedEntriesTotal.Read(),
+			WarmedUpEntriesTotal:       dmap.WarmedUpEntriesTotal.Read(),
+			ConflictsResolvedTotal:     dmap.ConflictsResolvedTotal.Read(),
 		},
 		PubSub: stats.PubSub{
 			PublishedTotal:      pubsub.PublishedTotal.Read(),
@@ -115,6 +192,32 @@ func (db *Olric) stats(cfg statsConfig) stats.Stats {
 			CurrentPSubscribers: pubsub.CurrentPSubscribers.Read(),
 			PSubscribersTotal:   pubsub.PSubscribersTotal.Read(),
 		},
+		Gossip: stats.Gossip{
+			NumMembers:             db.rt.Discovery().NumMembers(),
+			HealthScore:            db.rt.Discovery().HealthScore(),
+			ProbeFailuresTotal:     discovery.ProbeFailuresTotal.Read(),
+			SuspectTimeoutsTotal:   discovery.SuspectTimeoutsTotal.Read(),
+			RefutedSuspicionsTotal: discovery.RefutedSuspicionsTotal.Read(),
+			SuspectMessagesTotal:   discovery.SuspectMessagesTotal.Read(),
+			DeadMessagesTotal:      discovery.DeadMessagesTotal.Read(),
+			DegradedProbesTotal:    discovery.DegradedProbesTotal.Read(),
+			DroppedMessagesTotal:   discovery.DroppedMessagesTotal.Read(),
+			BroadcastQueueDepth:    discovery.BroadcastQueueDepth.Read(),
+		},
+		CommandLatencies: db.server.CommandLatencies(),
+		SLOs:             make(map[string]stats.SLO),
+	}
+
+	for name, slo := range db.dmap.SLOs() {
+		s.SLOs[name] = stats.SLO{
+			Target:     slo.Target.Nanoseconds(),
+			Objective:  slo.Objective,
+			Window:     slo.Window.Nanoseconds(),
+			Good:       slo.Good,
+			Bad:        slo.Bad,
+			Attainment: slo.Attainment,
+			Violated:   slo.Violated,
+		}
 	}
 
 	if cfg.CollectRuntime {
@@ -136,7 +239,31 @@ func (db *Olric) stats(cfg statsConfig) stats.Stats {
 		return true
 	})
 
+	rtts := db.client.RTTs()
+	for addr, ps := range db.client.PoolStats() {
+		rtt := rtts[addr]
+		s.ClientPools[addr] = stats.ClientPool{
+			Hits:         ps.Hits,
+			Misses:       ps.Misses,
+			Timeouts:     ps.Timeouts,
+			TotalConns:   ps.TotalConns,
+			IdleConns:    ps.IdleConns,
+			StaleConns:   ps.StaleConns,
+			SmoothedRTT:  rtt.SRTT.Nanoseconds(),
+			RTTVariation: rtt.RTTVAR.Nanoseconds(),
+			Timeout:      rtt.Timeout.Nanoseconds(),
+		}
+	}
+
 	for partID := uint64(0); partID < db.config.PartitionCount; partID++ {
+		if err := ctx.Err(); err != nil {
+			return stats.Stats{}, &ProgressError{
+				Operation: "stats",
+				Done:      int(partID),
+				Total:     int(db.config.PartitionCount),
+				Err:       err,
+			}
+		}
 		primary := db.primary.PartitionByID(partID)
 		if db.checkPartitionOwnership(primary) {
 			s.Partitions[stats.PartitionID(partID)] = db.collectPartitionMetrics(partID, primary)
@@ -146,8 +273,164 @@ func (db *Olric) stats(cfg statsConfig) stats.Stats {
 			s.Backups[stats.PartitionID(partID)] = db.collectPartitionMetrics(partID, backup)
 		}
 	}
+	s.DMaps.TableUtilization, s.DMaps.Fragmentation = tableRatios(s.Partitions, s.Backups)
+	s.Namespaces = namespaceStats(s.Partitions)
+	s.DMapMemory = dmapMemory(s.Partitions, s.Backups)
+	filterStats(&s, cfg)
 
-	return s
+	return s, nil
+}
+
+// dmapMemory sums the memory usage of every DMap in the primary and the
+// backup partitions.
+func dmapMemory(primary, backup map[stats.PartitionID]stats.Partition) map[string]stats.DMapMemory {
+	result := make(map[string]stats.DMapMemory)
+	for _, part := range primary {
+		for name, dm := range part.DMaps {
+			m := result[name]
+			m.Primary.Add(dm.SlabInfo)
+			result[name] = m
+		}
+	}
+	for _, part := range backup {
+		for name, dm := range part.DMaps {
+			m := result[name]
+			m.Backup.Add(dm.SlabInfo)
+			result[name] = m
+		}
+	}
+	return result
+}
+
+// filterStats drops the statistics that are filtered out with FilterStats.
+func filterStats(s *stats.Stats, cfg statsConfig) {
+	if cfg.NoPartitions {
+		s.Partitions = make(map[stats.PartitionID]stats.Partition)
+		s.Backups = make(map[stats.PartitionID]stats.Partition)
+	}
+	if len(cfg.DMaps) == 0 {
+		return
+	}
+
+	keep := make(map[string]struct{})
+	for _, name := range cfg.DMaps {
+		keep[name] = struct{}{}
+	}
+	filterPartitions := func(parts map[stats.PartitionID]stats.Partition) {
+		for partID, part := range parts {
+			part.SlabInfo = stats.SlabInfo{}
+			for name, dm := range part.DMaps {
+				if _, ok := keep[name]; !ok {
+					delete(part.DMaps, name)
+					continue
+				}
+				part.SlabInfo.Add(dm.SlabInfo)
+			}
+			if len(part.DMaps) == 0 {
+				delete(parts, partID)
+				continue
+			}
+			parts[partID] = part
+		}
+	}
+	filterPartitions(s.Partitions)
+	filterPartitions(s.Backups)
+	for name := range s.SLOs {
+		if _, ok := keep[name]; !ok {
+			delete(s.SLOs, name)
+		}
+	}
+	for name := range s.DMapMemory {
+		if _, ok := keep[name]; !ok {
+			delete(s.DMapMemory, name)
+		}
+	}
+}
+
+// statsSessionTimeout is the idle time after which a stats delta session is
+// dropped. The next delta of the session returns all the counters.
+const statsSessionTimeout = 5 * time.Minute
+
+// statsSession keeps the counters of the last delta of a session, the next
+// delta is computed against them.
+type statsSession struct {
+	sequence uint64
+	counters map[string]int64
+	lastUsed time.Time
+}
+
+func newStatsSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// statsDelta returns the counters that are changed since the delta of the
+// given cursor. It returns all the counters in a new session if the cursor
+// is not the last delta of its session.
+func (db *Olric) statsDelta(ctx context.Context, cfg statsConfig, cursor stats.DeltaCursor) (stats.Delta, error) {
+	s, err := db.stats(ctx, cfg)
+	if err != nil {
+		return stats.Delta{}, err
+	}
+	counters, err := stats.Counters(s)
+	if err != nil {
+		return stats.Delta{}, err
+	}
+
+	db.statsSessionsMtx.Lock()
+	defer db.statsSessionsMtx.Unlock()
+
+	now := time.Now()
+	for id, session := range db.statsSessions {
+		if now.Sub(session.lastUsed) > statsSessionTimeout {
+			delete(db.statsSessions, id)
+		}
+	}
+
+	var previous map[string]int64
+	session, ok := db.statsSessions[cursor.Session]
+	if ok && session.sequence == cursor.Sequence {
+		previous = session.counters
+	}
+	if !ok {
+		if cursor.Session, err = newStatsSessionID(); err != nil {
+			return stats.Delta{}, err
+		}
+		session = &statsSession{}
+		db.statsSessions[cursor.Session] = session
+	}
+
+	delta := stats.Diff(previous, counters)
+	session.sequence++
+	session.counters = counters
+	session.lastUsed = now
+	delta.Cursor = stats.DeltaCursor{
+		Session:  cursor.Session,
+		Sequence: session.sequence,
+	}
+	return delta, nil
+}
+
+// namespaceStats sums the usage of the DMaps of every namespace in the
+// primary partitions.
+func namespaceStats(parts map[stats.PartitionID]stats.Partition) map[string]stats.Namespace {
+	namespaces := make(map[string]stats.Namespace)
+	for _, part := range parts {
+		for name, dm := range part.DMaps {
+			i := strings.Index(name, config.NamespaceSeparator)
+			if i <= 0 {
+				continue
+			}
+			ns := namespaces[name[:i]]
+			ns.Length += dm.Length
+			ns.Inuse += dm.SlabInfo.Inuse
+			namespaces[name[:i]] = ns
+		}
+	}
+	return namespaces
 }
 
 func (db *Olric) statsCommandHandler(conn redcon.Conn, cmd redcon.Command) {
@@ -157,12 +440,26 @@ func (db *Olric) statsCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
-	sc := statsConfig{}
-	if statsCmd.CollectRuntime {
-		sc.CollectRuntime = true
+	sc := statsConfig{
+		CollectRuntime: statsCmd.CollectRuntime,
+		NoPartitions:   statsCmd.NoPartitions,
+		DMaps:          statsCmd.DMaps,
+	}
+	ctx, cancel := server.CommandContext(db.ctx, conn)
+	defer cancel()
+
+	var result interface{}
+	if statsCmd.Delta {
+		cursor := stats.DeltaCursor{Session: statsCmd.Session, Sequence: statsCmd.Sequence}
+		result, err = db.statsDelta(ctx, sc, cursor)
+	} else {
+		result, err = db.stats(ctx, sc)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
 	}
-	memberStats := db.stats(sc)
-	data, err := json.Marshal(memberStats)
+	data, err := json.Marshal(result)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -0,0 +1,131 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// Counters flattens the integer fields of s into a map by their JSON paths,
+// such as "network.commands_total" or "partitions.12.dmaps.users.length".
+// The other fields, such as the ratios, are skipped.
+func Counters(s Stats) (map[string]int64, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var tree interface{}
+	if err = d.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]int64)
+	flatten(counters, "", tree)
+	return counters, nil
+}
+
+func flatten(counters map[string]int64, path string, node interface{}) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			flatten(counters, join(key), child)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flatten(counters, join(strconv.Itoa(i)), child)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			counters[path] = n
+		}
+	}
+}
+
+// DeltaCursor identifies the counters that a delta is computed against on a
+// member. The zero value asks for all the counters.
+type DeltaCursor struct {
+	// Session is the id of the delta session on the member.
+	Session string `json:"session"`
+
+	// Sequence is the number of the last delta of the session.
+	Sequence uint64 `json:"sequence"`
+}
+
+// Delta is the change in the counters of a member between two snapshots. It's
+// computed on the member.
+type Delta struct {
+	// Cursor is passed to the next call to get the changes since this delta.
+	Cursor DeltaCursor `json:"cursor"`
+
+	// Full is true if Changed holds all the counters, the previous counters
+	// have to be dropped. It's set for the first delta of a session, and if
+	// the cursor doesn't match the last delta of the session on the member.
+	Full bool `json:"full"`
+
+	// Changed holds the new values of the counters that are changed or
+	// added, by their paths.
+	Changed map[string]int64 `json:"changed"`
+
+	// Removed is the sorted list of the counters that are gone, such as the
+	// counters of a destroyed DMap.
+	Removed []string `json:"removed"`
+}
+
+// Diff returns the difference between the previous and the current
+// counters. A nil previous returns all the current counters as changed.
+func Diff(previous, current map[string]int64) Delta {
+	delta := Delta{
+		Full:    previous == nil,
+		Changed: make(map[string]int64),
+	}
+	for path, value := range current {
+		if old, ok := previous[path]; !ok || old != value {
+			delta.Changed[path] = value
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			delta.Removed = append(delta.Removed, path)
+		}
+	}
+	sort.Strings(delta.Removed)
+	return delta
+}
+
+// Apply applies the delta to counters and returns them.
+func (d Delta) Apply(counters map[string]int64) map[string]int64 {
+	if counters == nil || d.Full {
+		counters = make(map[string]int64)
+	}
+	for path, value := range d.Changed {
+		counters[path] = value
+	}
+	for _, path := range d.Removed {
+		delete(counters, path)
+	}
+	return counters
+}
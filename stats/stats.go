@@ -15,7 +15,10 @@
 /*Package stats exposes internal data structures for Stat command*/
 package stats
 
-import "runtime"
+import (
+	"runtime"
+	"time"
+)
 
 type (
 	// PartitionID denotes ID of a partition in the cluster.
@@ -35,6 +38,35 @@ type SlabInfo struct {
 
 	// Total garbage(deleted key/value pairs) space in the append-only byte slice.
 	Garbage int `json:"garbage"`
+
+	// Total size of the keys of the live entries.
+	Keys int `json:"keys"`
+
+	// Total size of the values of the live entries.
+	Values int `json:"values"`
+
+	// Overhead is the metadata of the live entries, such as TTL and
+	// timestamps. Inuse is Keys + Values + Overhead.
+	Overhead int `json:"overhead"`
+}
+
+// Add adds the usage in other to s.
+func (s *SlabInfo) Add(other SlabInfo) {
+	s.Allocated += other.Allocated
+	s.Inuse += other.Inuse
+	s.Garbage += other.Garbage
+	s.Keys += other.Keys
+	s.Values += other.Values
+	s.Overhead += other.Overhead
+}
+
+// DMapMemory is the memory usage of a DMap on a member.
+type DMapMemory struct {
+	// Primary is the usage of the primary copies.
+	Primary SlabInfo `json:"primary"`
+
+	// Backup is the usage of the backup copies.
+	Backup SlabInfo `json:"backup"`
 }
 
 // DMap denotes a distributed map instance on the cluster.
@@ -62,6 +94,9 @@ type Partition struct {
 
 	// DMaps is a map that contains statistics of DMaps in this partition.
 	DMaps map[string]DMap `json:"dmaps"`
+
+	// SlabInfo is the memory usage of the DMaps in this partition.
+	SlabInfo SlabInfo `json:"slab_info"`
 }
 
 // Runtime exposes memory stats and various metrics from Go runtime.
@@ -96,6 +131,9 @@ type Member struct {
 
 	// Birthdate is UNIX time in nanoseconds.
 	Birthdate int64 `json:"birthdate"`
+
+	// Tags is the arbitrary key/value metadata of the member.
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // String returns the member name.
@@ -119,6 +157,53 @@ type Network struct {
 
 	// CommandsTotal is total number of all requests (get, put, etc.).
 	CommandsTotal int64 `json:"commands_total"`
+
+	// RejectedCommandsTotal is total number of requests rejected while the
+	// server is draining before shutdown.
+	RejectedCommandsTotal int64 `json:"rejected_commands_total"`
+
+	// HealthCheckFailuresTotal is total number of failed health-check pings
+	// to the cluster members.
+	HealthCheckFailuresTotal int64 `json:"health_check_failures_total"`
+
+	// CoalescedWritesTotal is total number of responses that are sent to
+	// network with a preceding response in the same write.
+	CoalescedWritesTotal int64 `json:"coalesced_writes_total"`
+
+	// DeadlineExceededTotal is total number of commands that are rejected or
+	// aborted because their deadline has passed.
+	DeadlineExceededTotal int64 `json:"deadline_exceeded_total"`
+}
+
+// ClientPool holds statistics of the connection pool to a cluster member.
+type ClientPool struct {
+	// Hits is number of times a free connection was found in the pool.
+	Hits uint32 `json:"hits"`
+
+	// Misses is number of times a free connection was not found in the pool.
+	Misses uint32 `json:"misses"`
+
+	// Timeouts is number of times a wait timeout occurred.
+	Timeouts uint32 `json:"timeouts"`
+
+	// TotalConns is number of total connections in the pool.
+	TotalConns uint32 `json:"total_conns"`
+
+	// IdleConns is number of idle connections in the pool.
+	IdleConns uint32 `json:"idle_conns"`
+
+	// StaleConns is number of stale connections removed from the pool.
+	StaleConns uint32 `json:"stale_conns"`
+
+	// SmoothedRTT is the round-trip time estimate of the member in nanoseconds.
+	SmoothedRTT int64 `json:"smoothed_rtt"`
+
+	// RTTVariation is the variation of the round-trip time in nanoseconds.
+	RTTVariation int64 `json:"rtt_variation"`
+
+	// Timeout is the read timeout of the commands sent to the member in
+	// nanoseconds. See config.Client.AdaptiveTimeout.
+	Timeout int64 `json:"timeout"`
 }
 
 // DMaps holds global DMap statistics.
@@ -140,6 +225,131 @@ type DMaps struct {
 
 	// EvictedTotal is the number of entries removed from cache to free memory for new entries.
 	EvictedTotal int64 `json:"evicted_total"`
+
+	// CanceledOperationsTotal is the number of scans abandoned by their callers
+	// or stopped because this instance is shutting down.
+	CanceledOperationsTotal int64 `json:"canceled_operations_total"`
+
+	// EvictionSweepsTotal is the number of sweeps run to evict the expired and idle keys.
+	EvictionSweepsTotal int64 `json:"eviction_sweeps_total"`
+
+	// ActivelyExpiredTotal is the number of expired and idle keys removed by the eviction workers.
+	ActivelyExpiredTotal int64 `json:"actively_expired_total"`
+
+	// LazilyExpiredTotal is the number of expired and idle keys removed by the read
+	// requests on the partition owner before the eviction workers removed them.
+	LazilyExpiredTotal int64 `json:"lazily_expired_total"`
+
+	// TablesAllocatedTotal is the number of tables allocated by the storage engine.
+	TablesAllocatedTotal int64 `json:"tables_allocated_total"`
+
+	// CompactionRunsTotal is the number of tables compacted by the storage engine.
+	CompactionRunsTotal int64 `json:"compaction_runs_total"`
+
+	// CompactedEntriesTotal is the number of entries moved by the compaction runs.
+	CompactedEntriesTotal int64 `json:"compacted_entries_total"`
+
+	// LoadsTotal is the number of the Loader calls on cache misses and refreshes.
+	LoadsTotal int64 `json:"loads_total"`
+
+	// LoadErrorsTotal is the number of the Loader calls that returned an error.
+	LoadErrorsTotal int64 `json:"load_errors_total"`
+
+	// RefreshesTotal is the number of the entries refreshed ahead of their expiry.
+	RefreshesTotal int64 `json:"refreshes_total"`
+
+	// WritesTotal is the number of the writes that are passed to the Writers.
+	WritesTotal int64 `json:"writes_total"`
+
+	// WriteErrorsTotal is the number of the Writer calls that returned an error.
+	WriteErrorsTotal int64 `json:"write_errors_total"`
+
+	// WriteBehindRetriesTotal is the number of the writes that are queued
+	// again after a failed write-behind flush.
+	WriteBehindRetriesTotal int64 `json:"write_behind_retries_total"`
+
+	// WriteBehindQueueLength is the number of the writes that are waiting in
+	// the write-behind queues on this member.
+	WriteBehindQueueLength int64 `json:"write_behind_queue_length"`
+
+	// StaleEpochRejectionsTotal is the number of the replicated writes that
+	// are rejected because they carry an older partition epoch.
+	StaleEpochRejectionsTotal int64 `json:"stale_epoch_rejections_total"`
+
+	// AntiEntropyMismatchesTotal is the number of the backup fragments whose
+	// checksums don't match with the primary fragments.
+	AntiEntropyMismatchesTotal int64 `json:"anti_entropy_mismatches_total"`
+
+	// AntiEntropyRepairsTotal is the number of the backup entries that are
+	// repaired by the anti-entropy worker.
+	AntiEntropyRepairsTotal int64 `json:"anti_entropy_repairs_total"`
+
+	// CodecRawBytesTotal is the total size of the values that are stored
+	// encoded by a codec, before they are encoded.
+	CodecRawBytesTotal int64 `json:"codec_raw_bytes_total"`
+
+	// CodecEncodedBytesTotal is the total size of the values that are stored
+	// encoded by a codec, after they are encoded.
+	CodecEncodedBytesTotal int64 `json:"codec_encoded_bytes_total"`
+
+	// MemoryLimit is the memory budget of the storage engine on this member
+	// in bytes. Zero means no limit.
+	MemoryLimit int64 `json:"memory_limit"`
+
+	// MemoryAllocated is the memory allocated by the tables that are charged
+	// to the memory budget, in bytes.
+	MemoryAllocated int64 `json:"memory_allocated"`
+
+	// OutOfMemoryErrorsTotal is the number of the writes rejected because the
+	// memory budget is exhausted.
+	OutOfMemoryErrorsTotal int64 `json:"out_of_memory_errors_total"`
+
+	// TableUtilization is the ratio of the in-use memory to the allocated
+	// memory of the tables on this member.
+	TableUtilization float64 `json:"table_utilization"`
+
+	// Fragmentation is the ratio of the garbage to the allocated memory of
+	// the tables on this member. It's reclaimed by the compaction runs.
+	Fragmentation float64 `json:"fragmentation"`
+
+	// TxCommitsTotal is the number of the transactions committed on this member.
+	TxCommitsTotal int64 `json:"tx_commits_total"`
+
+	// TxConflictsTotal is the number of the transactions rejected because a
+	// key that they read was modified before the commit.
+	TxConflictsTotal int64 `json:"tx_conflicts_total"`
+
+	// TxRollbacksTotal is the number of the transactions rolled back after a
+	// failed write.
+	TxRollbacksTotal int64 `json:"tx_rollbacks_total"`
+
+	// ImportsTotal is the number of the fragments imported by this member,
+	// received from the other members or restored from a snapshot.
+	ImportsTotal int64 `json:"imports_total"`
+
+	// AnalyticsReplicatedTotal is the number of the writes sent to the
+	// analytics replicas by this member.
+	AnalyticsReplicatedTotal int64 `json:"analytics_replicated_total"`
+
+	// AnalyticsDroppedTotal is the number of the writes that are not sent to
+	// the analytics replicas. The DMaps are copied to the replica again then.
+	AnalyticsDroppedTotal int64 `json:"analytics_dropped_total"`
+
+	// RateLimitedTotal is the number of the operations rejected by the rate
+	// limits of the DMaps on this member.
+	RateLimitedTotal int64 `json:"rate_limited_total"`
+
+	// MigratedEntriesTotal is the number of the entries copied to another
+	// cluster by this member, see DM.MIGRATE.
+	MigratedEntriesTotal int64 `json:"migrated_entries_total"`
+
+	// WarmedUpEntriesTotal is the number of the entries stored by DMap.Warmup
+	// on this member.
+	WarmedUpEntriesTotal int64 `json:"warmed_up_entries_total"`
+
+	// ConflictsResolvedTotal is the number of the conflicting versions that
+	// are passed to the ConflictResolvers while merging the partitions.
+	ConflictsResolvedTotal int64 `json:"conflicts_resolved_total"`
 }
 
 // PubSub holds global Pub/Sub statistics.
@@ -160,6 +370,113 @@ type PubSub struct {
 	PSubscribersTotal int64 `json:"psubscribers_total"`
 }
 
+// Gossip holds statistics of the memberlist layer, the failure detector and
+// the gossip protocol. Member flapping usually shows up here first.
+type Gossip struct {
+	// NumMembers is the number of alive members known by the memberlist.
+	NumMembers int `json:"num_members"`
+
+	// HealthScore is the health of this member as seen by the failure detector.
+	// Zero is healthy, higher values mean that this member has problems with
+	// answering probes in time.
+	HealthScore int `json:"health_score"`
+
+	// ProbeFailuresTotal is the number of failure detector probes that got
+	// no ack from the probed member, directly or indirectly, within the probe
+	// interval.
+	ProbeFailuresTotal int64 `json:"probe_failures_total"`
+
+	// SuspectTimeoutsTotal is the number of suspected members that are marked
+	// as failed because the suspicion timed out.
+	SuspectTimeoutsTotal int64 `json:"suspect_timeouts_total"`
+
+	// RefutedSuspicionsTotal is the number of times this member refuted
+	// a suspicion about itself.
+	RefutedSuspicionsTotal int64 `json:"refuted_suspicions_total"`
+
+	// SuspectMessagesTotal is the number of suspect messages processed.
+	SuspectMessagesTotal int64 `json:"suspect_messages_total"`
+
+	// DeadMessagesTotal is the number of dead messages processed.
+	DeadMessagesTotal int64 `json:"dead_messages_total"`
+
+	// DegradedProbesTotal is the number of probes run with a longer interval,
+	// because the health score of this member was degraded.
+	DegradedProbesTotal int64 `json:"degraded_probes_total"`
+
+	// DroppedMessagesTotal is the number of incoming gossip messages dropped
+	// because the handler queue was full.
+	DroppedMessagesTotal int64 `json:"dropped_messages_total"`
+
+	// BroadcastQueueDepth is the number of broadcasts waiting to be gossiped.
+	BroadcastQueueDepth int64 `json:"broadcast_queue_depth"`
+}
+
+// LatencyBuckets holds the upper bounds of the buckets of LatencyHistogram.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram is the distribution of the latencies of a command served
+// by a member.
+type LatencyHistogram struct {
+	// Count is the number of the served commands.
+	Count int64 `json:"count"`
+
+	// TotalMicroseconds is the sum of the latencies, it's useful to calculate
+	// the mean latency.
+	TotalMicroseconds int64 `json:"total_microseconds"`
+
+	// Buckets holds the number of the commands served within the upper bound
+	// of the bucket in LatencyBuckets, and not within the previous one. The
+	// last one is the number of the commands slower than the last bound.
+	Buckets []int64 `json:"buckets"`
+}
+
+// SLO is the latency SLO attainment of a DMap on a member, in the rolling
+// window. See config.DMap.LatencySLO.
+type SLO struct {
+	// Target is the latency target in nanoseconds.
+	Target int64 `json:"target"`
+
+	// Objective is the target ratio of the good operations.
+	Objective float64 `json:"objective"`
+
+	// Window is the rolling window in nanoseconds.
+	Window int64 `json:"window"`
+
+	// Good is the number of the operations that completed within the target.
+	Good int64 `json:"good"`
+
+	// Bad is the number of the operations that exceeded the target.
+	Bad int64 `json:"bad"`
+
+	// Attainment is the ratio of the good operations. It's 1 if there is no
+	// operation in the window.
+	Attainment float64 `json:"attainment"`
+
+	// Violated is true if the attainment is below the objective.
+	Violated bool `json:"violated"`
+}
+
+// Namespace is the usage of the DMaps of a namespace on a member. It counts
+// the primary copies of the keys. See config.Namespace.
+type Namespace struct {
+	// Length is the number of the keys.
+	Length int `json:"length"`
+
+	// Inuse is the in-use memory in bytes.
+	Inuse int `json:"inuse"`
+}
+
 // Stats is a struct that exposes statistics about the current state of a member.
 type Stats struct {
 	// Cmdline holds the command-line arguments, starting with the program name.
@@ -192,9 +509,32 @@ type Stats struct {
 	// Network holds network statistics.
 	Network Network `json:"network"`
 
+	// ClientPools holds statistics of the connection pools to the cluster
+	// members, by member name.
+	ClientPools map[string]ClientPool `json:"client_pools"`
+
 	// DMaps holds global DMap statistics.
 	DMaps DMaps `json:"dmaps"`
 
 	// PubSub holds global Pub/Sub statistics.
 	PubSub PubSub `json:"pub_sub"`
+
+	// Gossip holds memberlist statistics.
+	Gossip Gossip `json:"gossip"`
+
+	// CommandLatencies holds the latency histograms of the commands served
+	// by this member, by the command name.
+	CommandLatencies map[string]LatencyHistogram `json:"command_latencies"`
+
+	// SLOs holds the latency SLO attainments of the DMaps on this member, by
+	// the DMap name.
+	SLOs map[string]SLO `json:"slos"`
+
+	// Namespaces holds the usage of the namespaces on this member, by the
+	// namespace name.
+	Namespaces map[string]Namespace `json:"namespaces"`
+
+	// DMapMemory holds the memory usage of the DMaps on this member,
+	// including the backup copies, by the DMap name.
+	DMapMemory map[string]DMapMemory `json:"dmap_memory"`
 }
@@ -29,3 +29,33 @@ func TestMember_String(t *testing.T) {
 	}
 	require.Equal(t, "foobar", m.String())
 }
+
+func TestStats_CountersAndDiff(t *testing.T) {
+	s := Stats{
+		Partitions: map[PartitionID]Partition{
+			1: {Length: 10, DMaps: map[string]DMap{"foo": {Length: 10}}},
+		},
+		Network: Network{CommandsTotal: 5},
+	}
+	previous, err := Counters(s)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), previous["partitions.1.dmaps.foo.length"])
+	require.Equal(t, int64(5), previous["network.commands_total"])
+	require.NotContains(t, previous, "member.name")
+
+	s.Network.CommandsTotal = 6
+	s.Partitions = nil
+	current, err := Counters(s)
+	require.NoError(t, err)
+
+	delta := Diff(previous, current)
+	require.False(t, delta.Full)
+	require.Equal(t, map[string]int64{"network.commands_total": 6}, delta.Changed)
+	require.Contains(t, delta.Removed, "partitions.1.dmaps.foo.length")
+	require.Equal(t, current, delta.Apply(previous))
+
+	// A full delta replaces the counters.
+	delta = Diff(nil, current)
+	require.True(t, delta.Full)
+	require.Equal(t, current, delta.Apply(previous))
+}
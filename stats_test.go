@@ -49,7 +49,7 @@ func TestOlric_Stats(t *testing.T) {
 	defer cancel()
 
 	for i := 0; i < 100; i++ {
-		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i))
+		_, err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i))
 		require.NoError(t, err)
 	}
 
@@ -84,6 +84,9 @@ func TestOlric_Stats(t *testing.T) {
 	if total != 100 {
 		t.Fatalf("Expected total length of partition in stats is 100. Got: %d", total)
 	}
+	require.Greater(t, s.DMaps.TableUtilization, float64(0))
+	require.Equal(t, float64(0), s.DMaps.Fragmentation)
+	require.Equal(t, int64(0), s.DMaps.MemoryLimit)
 	_, ok := s.ClusterMembers[stats.MemberID(db.rt.This().ID)]
 	if !ok {
 		t.Fatalf("Expected member ID: %d could not be found in ClusterMembers", db.rt.This().ID)
@@ -113,6 +116,182 @@ func TestOlric_Stats_Cluster(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, s.Runtime)
 	require.Equal(t, s.Member.String(), db2.rt.This().String())
+	require.Equal(t, 2, s.Gossip.NumMembers)
+
+	// db has a connection pool to db2, at least for the stats request.
+	s, err = e.Stats(context.Background(), db.rt.This().String())
+	require.NoError(t, err)
+	require.Contains(t, s.ClientPools, db2.rt.This().String())
+	require.NotZero(t, s.ClientPools[db2.rt.This().String()].TotalConns)
+}
+
+func TestOlric_Stats_Filter(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	for _, name := range []string{"mymap-1", "mymap-2"} {
+		_, err := db2.NewEmbeddedClient().NewDMap(name)
+		require.NoError(t, err)
+		dm, err := e.NewDMap(name)
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			_, err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i))
+			require.NoError(t, err)
+		}
+	}
+
+	for _, member := range []*Olric{db, db2} {
+		address := member.rt.This().String()
+
+		s, err := e.Stats(ctx, address, FilterStats(StatsFilter{}))
+		require.NoError(t, err)
+		require.Empty(t, s.Partitions)
+		require.Empty(t, s.Backups)
+		require.NotZero(t, s.DMaps.EntriesTotal)
+
+		s, err = e.Stats(ctx, address, FilterStats(StatsFilter{DMaps: []string{"mymap-2"}, Partitions: true}))
+		require.NoError(t, err)
+		require.NotEmpty(t, s.Partitions)
+		for _, part := range s.Partitions {
+			require.Len(t, part.DMaps, 1)
+			require.Contains(t, part.DMaps, "mymap-2")
+		}
+	}
+}
+
+func TestOlric_Stats_DMapMemory(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.ReplicaCount = 2
+	db := cluster.addMemberWithConfig(t, c, "mymap")
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	db2 := cluster.addMemberWithConfig(t, c2, "mymap")
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mymap")
+	require.NoError(t, err)
+
+	var keys, values int
+	for i := 0; i < 100; i++ {
+		key, value := testutil.ToKey(i), fmt.Sprintf("value-%d", i)
+		_, err = dm.Put(ctx, key, value)
+		require.NoError(t, err)
+		keys += len(key)
+		values += len(value)
+	}
+
+	var total stats.DMapMemory
+	for _, member := range []*Olric{db, db2} {
+		s, err := e.Stats(ctx, member.rt.This().String())
+		require.NoError(t, err)
+
+		m := s.DMapMemory["mymap"]
+		total.Primary.Add(m.Primary)
+		total.Backup.Add(m.Backup)
+
+		var partitions stats.SlabInfo
+		for _, part := range s.Partitions {
+			require.Equal(t, part.DMaps["mymap"].SlabInfo, part.SlabInfo)
+			partitions.Add(part.SlabInfo)
+		}
+		require.Equal(t, m.Primary, partitions)
+	}
+	require.Equal(t, keys, total.Primary.Keys)
+	require.Equal(t, keys, total.Backup.Keys)
+	// The values are encoded by the codec.
+	require.GreaterOrEqual(t, total.Primary.Values, values)
+	require.Equal(t, total.Primary.Values, total.Backup.Values)
+	require.Equal(t, total.Primary.Inuse, total.Primary.Keys+total.Primary.Values+total.Primary.Overhead)
+}
+
+func TestOlric_StatsDelta(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mymap")
+	require.NoError(t, err)
+
+	address := db.rt.This().String()
+	option := FilterStats(StatsFilter{Partitions: true})
+	delta, err := e.StatsDelta(ctx, address, stats.DeltaCursor{}, option)
+	require.NoError(t, err)
+	require.True(t, delta.Full)
+	require.Contains(t, delta.Changed, "network.commands_total")
+	counters := delta.Apply(nil)
+
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	next, err := e.StatsDelta(ctx, address, delta.Cursor, option)
+	require.NoError(t, err)
+	require.False(t, next.Full)
+	require.Equal(t, delta.Cursor.Session, next.Cursor.Session)
+	require.Equal(t, counters["dmaps.entries_total"]+1, next.Changed["dmaps.entries_total"])
+	require.NotContains(t, next.Changed, "member.birthdate")
+	require.Less(t, len(next.Changed), len(counters))
+
+	// A stale cursor returns all the counters.
+	stale, err := e.StatsDelta(ctx, address, delta.Cursor, option)
+	require.NoError(t, err)
+	require.True(t, stale.Full)
+	require.Equal(t, next.Changed["dmaps.entries_total"], stale.Changed["dmaps.entries_total"])
+}
+
+func TestOlric_StatsDelta_Remote(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	e := db1.NewEmbeddedClient()
+	address := db2.rt.This().String()
+
+	delta, err := e.StatsDelta(ctx, address, stats.DeltaCursor{})
+	require.NoError(t, err)
+	require.True(t, delta.Full)
+	require.NotEmpty(t, delta.Cursor.Session)
+	require.Equal(t, db2.rt.This().Birthdate, delta.Changed["member.birthdate"])
+
+	next, err := e.StatsDelta(ctx, address, delta.Cursor)
+	require.NoError(t, err)
+	require.False(t, next.Full)
+	require.NotContains(t, next.Changed, "member.birthdate")
+}
+
+func TestOlric_SubscribeStats(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ch, err := e.SubscribeStats(ctx, db.rt.This().String(), 10*time.Millisecond)
+	require.NoError(t, err)
+
+	delta := <-ch
+	require.Contains(t, delta.Changed, "dmaps.entries_total")
+
+	_, err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		delta := <-ch
+		_, ok := delta.Changed["dmaps.entries_total"]
+		return ok
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	for range ch {
+	}
 }
 
 func TestStats_PubSub(t *testing.T) {
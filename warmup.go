@@ -0,0 +1,73 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"io"
+)
+
+// sliceWarmupSource returns the entries of a slice, see WarmupFromSlice.
+type sliceWarmupSource struct {
+	entries []WarmupEntry
+}
+
+func (s *sliceWarmupSource) Next(_ context.Context) (*WarmupEntry, error) {
+	if len(s.entries) == 0 {
+		return nil, io.EOF
+	}
+	entry := s.entries[0]
+	s.entries = s.entries[1:]
+	return &entry, nil
+}
+
+// WarmupFromSlice returns a WarmupSource that provides the given entries.
+func WarmupFromSlice(entries []WarmupEntry) WarmupSource {
+	return &sliceWarmupSource{entries: entries}
+}
+
+// codecWarmupSource encodes the values of the source with the codec of the
+// client.
+type codecWarmupSource struct {
+	source WarmupSource
+	codec  Codec
+}
+
+func (s *codecWarmupSource) Next(ctx context.Context) (*WarmupEntry, error) {
+	entry, err := s.source.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := s.codec.Encode(entry.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &WarmupEntry{Key: entry.Key, Value: encoded, TTL: entry.TTL}, nil
+}
+
+// Warmup preloads the DMap with the entries of the source. See DMap.Warmup
+// for the details.
+func (dm *EmbeddedDMap) Warmup(ctx context.Context, source WarmupSource) (int, error) {
+	if dm.client.codec != nil {
+		source = &codecWarmupSource{source: source, codec: dm.client.codec}
+	}
+
+	var count int
+	err := dm.client.observe(ctx, dm.command("Warmup"), func(ctx context.Context, _ *ClientCommand) (err error) {
+		count, err = dm.dm.Warmup(ctx, source)
+		return convertDMapError(err)
+	})
+	return count, err
+}
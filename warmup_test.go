@@ -0,0 +1,54 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient_Warmup(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMemberWithConfig(t, nil, "mydmap")
+	cluster.addMemberWithConfig(t, nil, "mydmap")
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var entries []WarmupEntry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, WarmupEntry{Key: testutil.ToKey(i), Value: i, TTL: time.Hour})
+	}
+
+	ctx := context.Background()
+	count, err := dm.Warmup(ctx, WarmupFromSlice(entries))
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+
+	for i := 0; i < 100; i++ {
+		gr, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+		require.NotZero(t, gr.TTL())
+	}
+	require.Equal(t, int64(1), e.Metrics().Commands["Warmup"].Calls)
+}
@@ -0,0 +1,283 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/resp"
+)
+
+// WatchPollInterval is the time between two sequential reads of the change
+// logs, if there is no new mutation in the cluster.
+const WatchPollInterval = 100 * time.Millisecond
+
+// ChangeEvent is a mutation that is read from the change log of a DMap.
+type ChangeEvent struct {
+	// Source is the partition owner that applied the mutation.
+	Source string
+
+	// PartitionID is the partition of the key.
+	PartitionID uint64
+
+	// Sequence is strictly increasing per partition of the DMap. It's moved
+	// with the partition, so it's kept when the partition changes its owner.
+	Sequence uint64
+
+	// Kind is one of put, expire or delete.
+	Kind string
+
+	// Key is the key of the mutated entry.
+	Key string
+
+	// TTL is the expiry of the entry in milliseconds. Zero means no expiry.
+	TTL int64
+
+	// Timestamp is the time of the mutation in nanoseconds.
+	Timestamp int64
+
+	// Token can be passed to Watch to resume the stream right after this event.
+	Token string
+
+	value []byte
+}
+
+// Scan decodes the value of a put event into v. Delete and expire events
+// have no value, ErrNilResponse is returned for them.
+func (c *ChangeEvent) Scan(v interface{}) error {
+	if c.value == nil {
+		return ErrNilResponse
+	}
+	return resp.Scan(c.value, v)
+}
+
+// ChangeStream delivers the mutations of a DMap. Mutations of a key are
+// always delivered in the order they are applied, because a key has a single
+// partition. There is no total order between the keys of different partitions.
+type ChangeStream struct {
+	db     *Olric
+	name   string
+	cursor map[uint64]uint64
+	events chan *ChangeEvent
+	err    error
+	mtx    sync.Mutex
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Events returns a channel that delivers the change events. It's closed when
+// the stream is closed or failed. Check Err after the channel is closed.
+func (cs *ChangeStream) Events() <-chan *ChangeEvent {
+	return cs.events
+}
+
+// Err returns the error that stopped the stream, if there is any.
+func (cs *ChangeStream) Err() error {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	return cs.err
+}
+
+// Close stops the stream and waits for the background goroutine to quit.
+func (cs *ChangeStream) Close() {
+	cs.cancel()
+	cs.wg.Wait()
+}
+
+func (cs *ChangeStream) setErr(err error) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	cs.err = err
+}
+
+func encodeWatchToken(cursor map[uint64]uint64) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeWatchToken(token string) (map[uint64]uint64, error) {
+	cursor := make(map[uint64]uint64)
+	if token == "" {
+		return cursor, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func (db *Olric) fetchChanges(ctx context.Context, addr, name string, cursor map[uint64]uint64) ([]dmap.Mutation, error) {
+	changesCmd := protocol.NewChanges(name)
+	for partID, sequence := range cursor {
+		changesCmd.SetSequence(partID, sequence)
+	}
+	cmd := changesCmd.Command(ctx)
+	rc := db.client.Get(addr)
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	items, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	var mutations []dmap.Mutation
+	for _, item := range items {
+		var m dmap.Mutation
+		if err = m.Decode([]byte(item)); err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, m)
+	}
+	return mutations, nil
+}
+
+func (cs *ChangeStream) deliver(addr string, m dmap.Mutation) error {
+	cs.cursor[m.PartID] = m.Sequence
+	token, err := encodeWatchToken(cs.cursor)
+	if err != nil {
+		return err
+	}
+	e := &ChangeEvent{
+		Source:      addr,
+		PartitionID: m.PartID,
+		Sequence:    m.Sequence,
+		Kind:        string(m.Kind),
+		Key:         m.Key,
+		TTL:         m.TTL,
+		Timestamp:   m.Timestamp,
+		Token:       token,
+		value:       m.Value,
+	}
+	select {
+	case cs.events <- e:
+	case <-cs.ctx.Done():
+		return cs.ctx.Err()
+	}
+	return nil
+}
+
+// cursorsByOwner groups the cursor of the stream by the current partition
+// owners.
+func (cs *ChangeStream) cursorsByOwner() map[string]map[uint64]uint64 {
+	owners := make(map[string]map[uint64]uint64)
+	for partID := uint64(0); partID < cs.db.config.PartitionCount; partID++ {
+		part := cs.db.primary.PartitionByID(partID)
+		if part.OwnerCount() == 0 {
+			continue
+		}
+		addr := part.Owner().String()
+		if _, ok := owners[addr]; !ok {
+			owners[addr] = make(map[uint64]uint64)
+		}
+		owners[addr][partID] = cs.cursor[partID]
+	}
+	return owners
+}
+
+// poll reads the change logs of all partitions once. It returns true if any
+// mutation is delivered.
+func (cs *ChangeStream) poll() (bool, error) {
+	var delivered bool
+	for addr, cursor := range cs.cursorsByOwner() {
+		mutations, err := cs.db.fetchChanges(cs.ctx, addr, cs.name, cursor)
+		if errors.Is(err, ErrChangeLogDisabled) || errors.Is(err, ErrSequenceTooOld) {
+			return false, err
+		}
+		if err != nil {
+			if cs.ctx.Err() != nil {
+				return false, cs.ctx.Err()
+			}
+			// The member may be gone, try again in the next round.
+			cs.db.log.V(6).Printf("[DEBUG] Failed to read the change log of %s on %s: %v", cs.name, addr, err)
+			continue
+		}
+		for _, m := range mutations {
+			if err = cs.deliver(addr, m); err != nil {
+				return false, err
+			}
+			delivered = true
+		}
+	}
+	return delivered, nil
+}
+
+func (cs *ChangeStream) run() {
+	defer cs.wg.Done()
+	defer close(cs.events)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-cs.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		delivered, err := cs.poll()
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				cs.setErr(err)
+			}
+			return
+		}
+		if delivered {
+			// Drain the change logs without waiting.
+			timer.Reset(0)
+			continue
+		}
+		timer.Reset(WatchPollInterval)
+	}
+}
+
+func (db *Olric) watch(ctx context.Context, name, token string) (*ChangeStream, error) {
+	cursor, err := decodeWatchToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cs := &ChangeStream{
+		db:     db,
+		name:   name,
+		cursor: cursor,
+		events: make(chan *ChangeEvent, protocol.DefaultChangesCount),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	cs.wg.Add(1)
+	go cs.run()
+	return cs, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2018-2022 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func readChangeEvents(t *testing.T, cs *ChangeStream, count int) []*ChangeEvent {
+	var result []*ChangeEvent
+	timeout := time.After(5 * time.Second)
+	for len(result) < count {
+		select {
+		case e, ok := <-cs.Events():
+			require.True(t, ok, "change stream is closed: %v", cs.Err())
+			result = append(result, e)
+		case <-timeout:
+			t.Fatalf("timed out, received %d events", len(result))
+		}
+	}
+	return result
+}
+
+func TestEmbeddedClient_Watch(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+
+	newConfig := func() *Olric {
+		c := testutil.NewConfig()
+		c.DMaps.ChangeLogSize = 1024
+		return cluster.addMemberWithConfig(t, c, "mydmap")
+	}
+	db := newConfig()
+	newConfig()
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		_, err = dm.Put(ctx, testutil.ToKey(i), i)
+		require.NoError(t, err)
+	}
+	_, err = dm.Delete(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+
+	cs, err := e.Watch(ctx, "mydmap", "")
+	require.NoError(t, err)
+	defer cs.Close()
+
+	events := readChangeEvents(t, cs, 11)
+	kinds := make(map[string][]string)
+	for _, ev := range events {
+		kinds[ev.Key] = append(kinds[ev.Key], ev.Kind)
+		if ev.Kind == "put" {
+			var value int
+			require.NoError(t, ev.Scan(&value))
+			require.Equal(t, testutil.ToKey(value), ev.Key)
+		}
+	}
+	require.Len(t, kinds, 10)
+	require.Equal(t, []string{"put", "delete"}, kinds[testutil.ToKey(0)])
+
+	// Resume right after the latest event.
+	token := events[len(events)-1].Token
+	cs.Close()
+
+	_, err = dm.Put(ctx, "new-key", "new-value")
+	require.NoError(t, err)
+
+	resumed, err := e.Watch(ctx, "mydmap", token)
+	require.NoError(t, err)
+	defer resumed.Close()
+
+	ev := readChangeEvents(t, resumed, 1)[0]
+	require.Equal(t, "new-key", ev.Key)
+	var value string
+	require.NoError(t, ev.Scan(&value))
+	require.Equal(t, "new-value", value)
+}
+
+func TestEmbeddedClient_Watch_ChangeLogDisabled(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	cs, err := e.Watch(context.Background(), "mydmap", "")
+	require.NoError(t, err)
+	defer cs.Close()
+
+	select {
+	case _, ok := <-cs.Events():
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("change stream is still open")
+	}
+	require.ErrorIs(t, cs.Err(), ErrChangeLogDisabled)
+}